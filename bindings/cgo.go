@@ -41,14 +41,17 @@ type QueryResponse struct {
 }
 
 type CommitResponse struct {
-	DatabasesCreated int     `json:"databases_created,omitempty"`
-	DatabasesDeleted int     `json:"databases_deleted,omitempty"`
-	TablesCreated    int     `json:"tables_created,omitempty"`
-	TablesDeleted    int     `json:"tables_deleted,omitempty"`
-	RecordsWritten   int     `json:"records_written,omitempty"`
-	RecordsDeleted   int     `json:"records_deleted,omitempty"`
-	ExecutionTimeMs  float64 `json:"execution_time_ms"`
-	ExecutionOps     int     `json:"execution_ops"`
+	DatabasesCreated int            `json:"databases_created,omitempty"`
+	DatabasesDeleted int            `json:"databases_deleted,omitempty"`
+	TablesCreated    int            `json:"tables_created,omitempty"`
+	TablesDeleted    int            `json:"tables_deleted,omitempty"`
+	RecordsWritten   int            `json:"records_written,omitempty"`
+	RecordsDeleted   int            `json:"records_deleted,omitempty"`
+	ExecutionTimeMs  float64        `json:"execution_time_ms"`
+	ExecutionOps     int            `json:"execution_ops"`
+	Returning        *QueryResponse `json:"returning,omitempty"`     // Rows requested with RETURNING
+	AffectedKeys     []string       `json:"affected_keys,omitempty"` // Primary keys written or deleted, with report_keys on
+	DryRun           bool           `json:"dry_run,omitempty"`       // Nothing was committed; the counts are what the statement would change
 }
 
 //export commitdb_open_memory
@@ -63,6 +66,7 @@ func commitdb_open_memory() C.int {
 		Name:  "CommitDB Python",
 		Email: "python@commitdb.local",
 	})
+	engine.ImplicitIdentity = true
 
 	handle := nextHandle
 	nextHandle++
@@ -88,6 +92,7 @@ func commitdb_open_file(path *C.char) C.int {
 		Name:  "CommitDB Python",
 		Email: "python@commitdb.local",
 	})
+	engine.ImplicitIdentity = true
 
 	handle := nextHandle
 	nextHandle++
@@ -146,6 +151,15 @@ func commitdb_execute(handle C.int, query *C.char) *C.char {
 			RecordsDeleted:   r.RecordsDeleted,
 			ExecutionTimeMs:  r.ExecutionTimeMs,
 			ExecutionOps:     r.ExecutionOps,
+			AffectedKeys:     r.AffectedKeys,
+			DryRun:           r.DryRun,
+		}
+		if r.Returning != nil {
+			cr.Returning = &QueryResponse{
+				Columns:     r.Returning.Columns,
+				Data:        r.Returning.Data,
+				RecordsRead: r.Returning.RecordsRead,
+			}
 		}
 		data, _ := json.Marshal(cr)
 		resp = Response{
@@ -46,6 +46,7 @@ class CommitResult:
     records_deleted: int = 0
     execution_time_ms: float = 0.0
     execution_ops: int = 0
+    returning: Optional[QueryResult] = None  # Rows requested with RETURNING
 
     @property
     def affected_rows(self) -> int:
@@ -55,6 +56,18 @@ class CommitResult:
                 self.records_written + self.records_deleted)
 
 
+def _returning_result(data: Optional[dict]) -> Optional[QueryResult]:
+    """Build the RETURNING rows of a commit response, if any."""
+    if not data:
+        return None
+    return QueryResult(
+        columns=data.get('columns', []),
+        data=data.get('data', []),
+        records_read=data.get('records_read', 0),
+        execution_time_ms=0.0
+    )
+
+
 class CommitDB:
     """
     CommitDB Python client.
@@ -250,7 +263,8 @@ class CommitDB:
                 records_written=result_data.get('records_written', 0),
                 records_deleted=result_data.get('records_deleted', 0),
                 execution_time_ms=result_data.get('execution_time_ms', 0.0),
-                execution_ops=result_data.get('execution_ops', 0)
+                execution_ops=result_data.get('execution_ops', 0),
+                returning=_returning_result(result_data.get('returning'))
             )
         else:
             # Unknown type, return empty commit result
@@ -465,7 +479,8 @@ class CommitDBLocal:
                 records_written=result_data.get('records_written', 0),
                 records_deleted=result_data.get('records_deleted', 0),
                 execution_time_ms=result_data.get('execution_time_ms', 0.0),
-                execution_ops=result_data.get('execution_ops', 0)
+                execution_ops=result_data.get('execution_ops', 0),
+                returning=_returning_result(result_data.get('returning'))
             )
         else:
             return CommitResult()
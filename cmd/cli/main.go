@@ -2,9 +2,14 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
 
@@ -12,9 +17,11 @@ import (
 	"github.com/nickyhof/CommitDB/core"
 	"github.com/nickyhof/CommitDB/db"
 	"github.com/nickyhof/CommitDB/ps"
+	"github.com/nickyhof/CommitDB/sql"
 )
 
-const (
+// Terminal colors, cleared by -plain
+var (
 	PromptColor  = "\033[36m" // Cyan
 	ErrorColor   = "\033[31m" // Red
 	SuccessColor = "\033[32m" // Green
@@ -31,6 +38,25 @@ type CLI struct {
 	history     []string
 	historyFile string
 	database    string // current database context
+	progress    bool   // a COPY progress line is on screen
+	display     db.DisplayOptions
+	defines     map[string]string // --define variables for imported scripts, checked before the environment
+}
+
+// defineFlags collects repeated --define name=value flags
+type defineFlags map[string]string
+
+func (defines defineFlags) String() string {
+	return fmt.Sprint(map[string]string(defines))
+}
+
+func (defines defineFlags) Set(value string) error {
+	name, val, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected name=value, got %q", value)
+	}
+	defines[name] = val
+	return nil
 }
 
 func main() {
@@ -39,14 +65,24 @@ func main() {
 	sqlFile := flag.String("sqlFile", "", "SQL file to execute (non-interactive)")
 	userName := flag.String("name", "CommitDB", "User name for Git commits")
 	userEmail := flag.String("email", "cli@commitdb.local", "User email for Git commits")
+	maxColumnWidth := flag.Int("maxColumnWidth", 80, "Cut longer values in result tables (0 shows values in full)")
+	plain := flag.Bool("plain", false, "Tab-separated results without colors, borders or banner, for piping")
+	defines := make(defineFlags)
+	flag.Var(defines, "define", "Set ${name} in imported SQL files, as name=value (repeatable; overrides environment variables)")
 	flag.Parse()
 
-	printBanner()
+	if *plain {
+		PromptColor, ErrorColor, SuccessColor, ResetColor, BoldColor = "", "", "", "", ""
+	} else {
+		printBanner()
+	}
 
 	var Instance CommitDB.Instance
 
 	if *baseDir == "" {
-		fmt.Printf("%sUsing memory persistence%s\n", SuccessColor, ResetColor)
+		if !*plain {
+			fmt.Printf("%sUsing memory persistence%s\n", SuccessColor, ResetColor)
+		}
 		persistence, err := ps.NewMemoryPersistence()
 		if err != nil {
 			fmt.Printf("%sError: %v%s\n", ErrorColor, err, ResetColor)
@@ -54,7 +90,9 @@ func main() {
 		}
 		Instance = *CommitDB.Open(&persistence)
 	} else {
-		fmt.Printf("%sUsing file persistence: %s%s\n", SuccessColor, *baseDir, ResetColor)
+		if !*plain {
+			fmt.Printf("%sUsing file persistence: %s%s\n", SuccessColor, *baseDir, ResetColor)
+		}
 		var gitUrlPtr *string
 		if *gitUrl != "" {
 			gitUrlPtr = gitUrl
@@ -71,13 +109,26 @@ func main() {
 		Name:  *userName,
 		Email: *userEmail,
 	})
+	// Without -name or -email, tables with an AUTHOR commit as their author
+	engine.ImplicitIdentity = true
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "name" || f.Name == "email" {
+			engine.ImplicitIdentity = false
+		}
+	})
 
 	cli := &CLI{
 		engine:      engine,
 		history:     make([]string, 0),
 		historyFile: getHistoryPath(),
+		display: db.DisplayOptions{
+			MaxColumnWidth: *maxColumnWidth,
+			Plain:          *plain,
+		},
+		defines: defines,
 	}
 
+	engine.CopyProgress = cli.showCopyProgress
 	cli.loadHistory()
 
 	// Execute SQL file if provided
@@ -118,14 +169,17 @@ func (cli *CLI) run() {
 	var multiLineBuffer strings.Builder
 
 	for {
-		// Show prompt
-		prompt := cli.getPrompt(multiLineBuffer.Len() > 0)
-		fmt.Print(prompt)
+		// Show prompt, except when piping plain output
+		if !cli.display.Plain {
+			fmt.Print(cli.getPrompt(multiLineBuffer.Len() > 0))
+		}
 
 		// Read input
 		input, err := reader.ReadString('\n')
 		if err != nil {
-			fmt.Printf("\n%sGoodbye!%s\n", SuccessColor, ResetColor)
+			if !cli.display.Plain {
+				fmt.Printf("\n%sGoodbye!%s\n", SuccessColor, ResetColor)
+			}
 			return
 		}
 
@@ -147,9 +201,9 @@ func (cli *CLI) run() {
 		// Multi-line support: accumulate until we see a semicolon
 		multiLineBuffer.WriteString(input)
 
-		// Check if the statement is complete (ends with ;)
+		// Check if the statement is complete (ends with ; outside a trigger body)
 		trimmed := strings.TrimSpace(multiLineBuffer.String())
-		if !strings.HasSuffix(trimmed, ";") {
+		if !strings.HasSuffix(trimmed, ";") || sql.InTriggerBody(strings.TrimSuffix(trimmed, ";")) {
 			multiLineBuffer.WriteString(" ")
 			continue
 		}
@@ -166,15 +220,46 @@ func (cli *CLI) run() {
 		cli.addToHistory(sql + ";")
 
 		// Execute SQL
-		result, err := cli.engine.Execute(sql)
+		result, err := cli.execute(sql)
 		if err != nil {
-			fmt.Printf("%s✗ Error: %v%s\n", ErrorColor, err, ResetColor)
+			printError(sql, err)
 		} else {
-			result.Display()
+			db.Print(os.Stdout, result, cli.display)
 		}
 	}
 }
 
+// printError reports a failed statement; syntax errors in single-line
+// statements point at the offending token.
+func printError(query string, err error) {
+	var syntaxErr *sql.SyntaxError
+	if errors.As(err, &syntaxErr) && !strings.Contains(query, "\n") {
+		fmt.Printf("%s✗ Syntax error: %v%s\n", ErrorColor, syntaxErr.Err, ResetColor)
+		fmt.Printf("  %s\n  %s^\n", query, strings.Repeat(" ", syntaxErr.Position-1))
+		return
+	}
+	fmt.Printf("%s✗ Error: %v%s\n", ErrorColor, err, ResetColor)
+}
+
+// execute runs a statement, cancelling it if the user presses Ctrl-C.
+func (cli *CLI) execute(query string) (db.Result, error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	result, err := cli.engine.ExecuteContext(ctx, query)
+	if cli.progress {
+		fmt.Print("\r\033[K")
+		cli.progress = false
+	}
+	return result, err
+}
+
+// showCopyProgress redraws the row count of a running COPY
+func (cli *CLI) showCopyProgress(rows int) {
+	fmt.Printf("\r%d rows copied (Ctrl-C to abort)", rows)
+	cli.progress = true
+}
+
 func (cli *CLI) getPrompt(multiLine bool) string {
 	if multiLine {
 		return fmt.Sprintf("%s   ...>%s ", PromptColor, ResetColor)
@@ -234,6 +319,22 @@ func (cli *CLI) handleCommand(input string) bool {
 	case ".version":
 		fmt.Printf("CommitDB version %s\n", Version)
 
+	case ".conflicts":
+		asJSON := len(parts) > 1 && parts[1] == "json"
+		if err := cli.writeConflicts(os.Stdout, asJSON); err != nil {
+			fmt.Printf("%s✗ Error: %v%s\n", ErrorColor, err, ResetColor)
+		}
+
+	case ".explain", ".plan":
+		// The query keeps its case, unlike the lowercased command
+		query := strings.TrimSpace(strings.TrimSpace(input)[len(parts[0]):])
+		query = strings.TrimSuffix(query, ";")
+		if query == "" {
+			fmt.Printf("%s✗ Usage: %s <select>%s\n", ErrorColor, parts[0], ResetColor)
+		} else if err := cli.writePlan(os.Stdout, query, parts[0] == ".plan"); err != nil {
+			fmt.Printf("%s✗ Error: %v%s\n", ErrorColor, err, ResetColor)
+		}
+
 	case ".import":
 		if len(parts) > 1 {
 			err := cli.importFile(parts[1])
@@ -260,6 +361,9 @@ func (cli *CLI) printHelp() {
 	fmt.Println("  .tables <db>     List tables in a database")
 	fmt.Println("  .use <db>        Set the current database context")
 	fmt.Println("  .import <file>   Execute SQL statements from a file")
+	fmt.Println("  .conflicts       Show pending merge conflicts (add json for JSON)")
+	fmt.Println("  .explain <sql>   Show how a SELECT would read its rows")
+	fmt.Println("  .plan <sql>      Show the query plan of a SELECT as JSON")
 	fmt.Println("  .history         Show command history")
 	fmt.Println("  .clear           Clear the screen")
 	fmt.Println("  .version         Show version info")
@@ -288,7 +392,7 @@ func (cli *CLI) showDatabases() {
 		fmt.Printf("%s✗ Error: %v%s\n", ErrorColor, err, ResetColor)
 		return
 	}
-	result.Display()
+	db.Print(os.Stdout, result, cli.display)
 }
 
 func (cli *CLI) showTables(database string) {
@@ -297,7 +401,89 @@ func (cli *CLI) showTables(database string) {
 		fmt.Printf("%s✗ Error: %v%s\n", ErrorColor, err, ResetColor)
 		return
 	}
-	result.Display()
+	db.Print(os.Stdout, result, cli.display)
+}
+
+// writeConflicts prints the pending merge's conflicts with their base, HEAD
+// and SOURCE values, or the structured export as indented JSON.
+func (cli *CLI) writeConflicts(w io.Writer, asJSON bool) error {
+	pending := cli.engine.GetPendingMerge()
+	if pending == nil {
+		if asJSON {
+			return errors.New("no merge in progress")
+		}
+		fmt.Fprintln(w, "No merge in progress")
+		return nil
+	}
+
+	export := pending.ExportConflicts()
+	if asJSON {
+		data, err := json.MarshalIndent(export, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(data))
+		return nil
+	}
+
+	fmt.Fprintf(w, "Merging %s (%s): %d unresolved conflicts\n", export.SourceBranch, export.MergeID, len(export.Conflicts))
+	for _, conflict := range export.Conflicts {
+		fmt.Fprintf(w, "\n%s%s.%s.%s%s\n", BoldColor, conflict.Database, conflict.Table, conflict.Key, ResetColor)
+		fmt.Fprintf(w, "  base:   %s\n", conflictValue(conflict.Base, "(none)"))
+		fmt.Fprintf(w, "  head:   %s\n", conflictValue(conflict.Head, "(deleted)"))
+		fmt.Fprintf(w, "  source: %s\n", conflictValue(conflict.Source, "(deleted)"))
+	}
+	return nil
+}
+
+// writePlan prints how query would read its rows: the access method, index
+// and estimated rows of its table, then each join's strategy and table size,
+// or the plan as indented JSON.
+func (cli *CLI) writePlan(w io.Writer, query string, asJSON bool) error {
+	plan, err := cli.engine.Plan(query)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		data, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(data))
+		return nil
+	}
+
+	fmt.Fprintf(w, "%s%s%s %s.%s", BoldColor, plan.Access, ResetColor, plan.Database, plan.Table)
+	switch plan.Access {
+	case db.AccessIndex:
+		fmt.Fprintf(w, " using index %s on %s", plan.Index, plan.Column)
+	case db.AccessPrimaryKey:
+		fmt.Fprintf(w, " on %s", plan.Column)
+	}
+	if plan.Access != db.AccessView {
+		fmt.Fprintf(w, " (%s)", rowCount(plan.EstimatedRows))
+	}
+	fmt.Fprintln(w)
+	for _, join := range plan.Joins {
+		fmt.Fprintf(w, "  %s JOIN %s.%s: %s over %s\n", join.Type, join.Database, join.Table, join.Strategy, rowCount(join.EstimatedRows))
+	}
+	return nil
+}
+
+// rowCount formats an estimated number of rows
+func rowCount(rows int) string {
+	if rows == 1 {
+		return "1 row"
+	}
+	return fmt.Sprintf("%d rows", rows)
+}
+
+// conflictValue formats an exported record, using missing for null
+func conflictValue(value json.RawMessage, missing string) string {
+	if string(value) == "null" {
+		return missing
+	}
+	return string(value)
 }
 
 func (cli *CLI) addToHistory(cmd string) {
@@ -383,8 +569,11 @@ func (cli *CLI) importFile(filename string) error {
 		return fmt.Errorf("failed to read file: %w", err)
 	}
 
-	content := string(data)
-	statements := splitStatements(content)
+	content, err := cli.substituteVariables(string(data))
+	if err != nil {
+		return err
+	}
+	statements := sql.SplitStatements(content)
 
 	successCount := 0
 	errorCount := 0
@@ -395,7 +584,7 @@ func (cli *CLI) importFile(filename string) error {
 			continue
 		}
 
-		result, err := cli.engine.Execute(stmt)
+		result, err := cli.execute(stmt)
 		if err != nil {
 			fmt.Printf("%s[%d] ✗ %s%s\n", ErrorColor, i+1, truncate(stmt, 50), ResetColor)
 			fmt.Printf("      Error: %v\n", err)
@@ -424,6 +613,9 @@ func (cli *CLI) importFile(filename string) error {
 				if r.RecordsDeleted > 0 {
 					details = append(details, fmt.Sprintf("%d deleted", r.RecordsDeleted))
 				}
+				if r.DryRun {
+					details = append(details, "dry run, not committed")
+				}
 				detailStr := ""
 				if len(details) > 0 {
 					detailStr = " (" + strings.Join(details, ", ") + ")"
@@ -443,55 +635,55 @@ func (cli *CLI) importFile(filename string) error {
 	return nil
 }
 
-// splitStatements splits SQL content into individual statements
-func splitStatements(content string) []string {
-	var statements []string
-	var current strings.Builder
+// substituteVariables replaces each ${name} in a script with the --define
+// value of name, or else the environment variable. Inside a string literal
+// the value's quotes are doubled so it stays part of the literal; $${ writes
+// a literal ${ instead. -- comments are left untouched. Referencing an
+// undefined variable is an error.
+func (cli *CLI) substituteVariables(content string) (string, error) {
+	var out strings.Builder
 	inString := false
-	stringChar := byte(0)
 
 	for i := 0; i < len(content); i++ {
 		ch := content[i]
-
-		// Handle string literals
-		if (ch == '\'' || ch == '"') && (i == 0 || content[i-1] != '\\') {
-			if !inString {
-				inString = true
-				stringChar = ch
-			} else if ch == stringChar {
-				inString = false
-			}
-		}
-
-		// Handle comments
-		if !inString && ch == '-' && i+1 < len(content) && content[i+1] == '-' {
-			// Skip to end of line
-			for i < len(content) && content[i] != '\n' {
-				i++
+		switch {
+		case ch == '\'':
+			inString = !inString
+		case !inString && ch == '-' && strings.HasPrefix(content[i:], "--"):
+			end := strings.IndexByte(content[i:], '\n')
+			if end < 0 {
+				end = len(content) - i
 			}
+			out.WriteString(content[i : i+end])
+			i += end - 1
 			continue
-		}
-
-		// Statement separator
-		if !inString && ch == ';' {
-			stmt := strings.TrimSpace(current.String())
-			if stmt != "" {
-				statements = append(statements, stmt)
+		case ch == '$' && strings.HasPrefix(content[i:], "$${"):
+			out.WriteString("${")
+			i += 2
+			continue
+		case ch == '$' && strings.HasPrefix(content[i:], "${"):
+			end := strings.IndexByte(content[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable at position %d", i)
+			}
+			name := content[i+2 : i+end]
+			value, ok := cli.defines[name]
+			if !ok {
+				value, ok = os.LookupEnv(name)
+			}
+			if !ok {
+				return "", fmt.Errorf("undefined variable ${%s}", name)
+			}
+			if inString {
+				value = strings.ReplaceAll(value, "'", "''")
 			}
-			current.Reset()
+			out.WriteString(value)
+			i += end
 			continue
 		}
-
-		current.WriteByte(ch)
-	}
-
-	// Handle last statement without semicolon
-	stmt := strings.TrimSpace(current.String())
-	if stmt != "" {
-		statements = append(statements, stmt)
+		out.WriteByte(ch)
 	}
-
-	return statements
+	return out.String(), nil
 }
 
 // truncate shortens a string to max length with ellipsis
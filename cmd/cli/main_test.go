@@ -2,6 +2,9 @@ package main
 
 import (
 	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -184,32 +187,6 @@ func TestVersionVariable(t *testing.T) {
 	}
 }
 
-func TestSplitStatements(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected int
-	}{
-		{"single statement", "SELECT * FROM test", 1},
-		{"two statements", "SELECT * FROM a; SELECT * FROM b", 2},
-		{"with semicolons", "INSERT INTO t VALUES (1); INSERT INTO t VALUES (2);", 2},
-		{"with comments", "-- comment\nSELECT * FROM test", 1},
-		{"multiline", "CREATE TABLE t (\n  id INT,\n  name STRING\n);", 1},
-		{"empty", "", 0},
-		{"only semicolons", ";;;", 0},
-		{"string with semicolon", "INSERT INTO t (s) VALUES ('a;b')", 1},
-	}
-
-	for _, test := range tests {
-		t.Run(test.name, func(t *testing.T) {
-			result := splitStatements(test.input)
-			if len(result) != test.expected {
-				t.Errorf("splitStatements(%q) = %d statements, expected %d", test.input, len(result), test.expected)
-			}
-		})
-	}
-}
-
 func TestTruncate(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -259,6 +236,39 @@ func TestImportFile(t *testing.T) {
 	}
 }
 
+func TestImportFileVariables(t *testing.T) {
+	cli := setupTestCLI(t)
+	cli.defines = map[string]string{"DB": "staging"}
+	t.Setenv("OWNER", "'alice'")
+
+	script := filepath.Join(t.TempDir(), "seed.sql")
+	content := `CREATE DATABASE ${DB};
+CREATE TABLE ${DB}.notes (id INT PRIMARY KEY, owner STRING, body STRING);
+-- ${UNDEFINED} in a comment is ignored
+INSERT INTO ${DB}.notes (id, owner, body) VALUES (1, ${OWNER}, 'for ${OWNER}, not $${OWNER}');
+INSERT INTO ${DB}.notes (id, owner, body) VALUES (2, ${ID}, 'x');
+`
+	if err := os.WriteFile(script, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+	if err := cli.importFile(script); err == nil || !strings.Contains(err.Error(), "undefined variable ${ID}") {
+		t.Fatalf("Expected undefined variable error, got %v", err)
+	}
+
+	t.Setenv("ID", "2")
+	if err := cli.importFile(script); err != nil {
+		t.Fatalf("importFile failed: %v", err)
+	}
+	result, err := cli.engine.Execute("SELECT id, owner, body FROM staging.notes")
+	if err != nil {
+		t.Fatalf("SELECT failed: %v", err)
+	}
+	expected := [][]string{{"1", "alice", "for 'alice', not ${OWNER}"}, {"2", "2", "x"}}
+	if got := result.(db.QueryResult).Data; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
 func TestImportFileNotFound(t *testing.T) {
 	cli := setupTestCLI(t)
 
@@ -277,3 +287,85 @@ func TestImportCommand(t *testing.T) {
 		t.Error("Expected .import to be handled")
 	}
 }
+
+func TestWriteConflicts(t *testing.T) {
+	cli := setupTestCLI(t)
+
+	for _, query := range []string{
+		"CREATE DATABASE mydb",
+		"CREATE TABLE mydb.users (id INT PRIMARY KEY, name STRING)",
+		"INSERT INTO mydb.users (id, name) VALUES (1, 'Original')",
+		"CREATE BRANCH feature",
+		"CHECKOUT feature",
+		"UPDATE mydb.users SET name = 'Feature' WHERE id = 1",
+		"CHECKOUT master",
+		"UPDATE mydb.users SET name = 'Master' WHERE id = 1",
+		"MERGE feature WITH MANUAL RESOLUTION",
+	} {
+		if _, err := cli.engine.Execute(query); err != nil {
+			t.Fatalf("%s failed: %v", query, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := cli.writeConflicts(&buf, false); err != nil {
+		t.Fatalf("writeConflicts failed: %v", err)
+	}
+	output := buf.String()
+	for _, want := range []string{"mydb.users.1", "Original", "Master", "Feature"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+
+	buf.Reset()
+	if err := cli.writeConflicts(&buf, true); err != nil {
+		t.Fatalf("writeConflicts json failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"base": {`) {
+		t.Errorf("Expected JSON export with base value, got:\n%s", buf.String())
+	}
+}
+
+func TestWritePlan(t *testing.T) {
+	cli := setupTestCLI(t)
+
+	for _, query := range []string{
+		"CREATE DATABASE mydb",
+		"CREATE TABLE mydb.users (id INT PRIMARY KEY, email STRING)",
+		"CREATE TABLE mydb.orders (id INT PRIMARY KEY, user_id INT)",
+		"INSERT INTO mydb.users (id, email) VALUES (1, 'A@example.com'), (2, 'b@example.com')",
+		"CREATE INDEX idx_email ON mydb.users(email)",
+	} {
+		if _, err := cli.engine.Execute(query); err != nil {
+			t.Fatalf("%s failed: %v", query, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := cli.writePlan(&buf, "SELECT * FROM mydb.users WHERE email = 'A@example.com'", false); err != nil {
+		t.Fatalf("writePlan failed: %v", err)
+	}
+	if output := buf.String(); !strings.Contains(output, "INDEX") || !strings.Contains(output, "using index idx_email on email (1 row)") {
+		t.Errorf("Expected an index lookup of 1 row, got:\n%s", output)
+	}
+
+	buf.Reset()
+	if err := cli.writePlan(&buf, "SELECT * FROM mydb.users u JOIN mydb.orders o ON u.id = o.user_id", false); err != nil {
+		t.Fatalf("writePlan failed: %v", err)
+	}
+	output := buf.String()
+	for _, want := range []string{"SCAN", "mydb.users (2 rows)", "INNER JOIN mydb.orders: NESTED LOOP over 0 rows"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+
+	buf.Reset()
+	if err := cli.writePlan(&buf, "SELECT * FROM mydb.users WHERE id = 1", true); err != nil {
+		t.Fatalf("writePlan json failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"Access": "PRIMARY KEY"`) {
+		t.Errorf("Expected JSON plan with primary key access, got:\n%s", buf.String())
+	}
+}
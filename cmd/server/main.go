@@ -33,6 +33,12 @@ func main() {
 	tlsCert := flag.String("tls-cert", "", "Path to TLS certificate file (enables TLS)")
 	tlsKey := flag.String("tls-key", "", "Path to TLS private key file")
 
+	// Query limit flags
+	maxRows := flag.Int("max-rows", 0, "Maximum rows a query may return (0 for no limit)")
+	maxResultBytes := flag.Int("max-result-bytes", 0, "Maximum size of a query result in bytes (0 for no limit)")
+	queryTimeout := flag.Duration("query-timeout", 0, "Maximum query execution time, e.g. 30s (0 for no limit)")
+	truncateRows := flag.Bool("truncate-rows", false, "Truncate results to --max-rows instead of failing")
+
 	flag.Parse()
 
 	if *showVersion {
@@ -86,6 +92,13 @@ func main() {
 		server = NewServer(instance, defaultIdentity)
 	}
 
+	server.SetLimits(Limits{
+		MaxRows:        *maxRows,
+		MaxResultBytes: *maxResultBytes,
+		QueryTimeout:   *queryTimeout,
+		TruncateRows:   *truncateRows,
+	})
+
 	addr := fmt.Sprintf(":%d", *port)
 
 	// Start with or without TLS
@@ -14,6 +14,7 @@ type Request struct {
 type Response struct {
 	Success bool            `json:"success"`
 	Error   string          `json:"error,omitempty"`
+	Code    string          `json:"code,omitempty"` // Kind of error: see errorCode
 	Type    string          `json:"type,omitempty"` // "query" or "commit"
 	Result  json.RawMessage `json:"result,omitempty"`
 }
@@ -23,20 +24,27 @@ type QueryResponse struct {
 	Columns         []string   `json:"columns"`
 	Data            [][]string `json:"data"`
 	RecordsRead     int        `json:"records_read"`
+	TotalRecords    int        `json:"total_records,omitempty"` // Rows before LIMIT/OFFSET for paged listings
+	CorruptRows     int        `json:"corrupt_rows,omitempty"`  // Stored rows skipped as undecodable
+	Truncated       bool       `json:"truncated,omitempty"`     // Rows past the server's row cap were dropped
 	ExecutionTimeMs float64    `json:"execution_time_ms"`
 	ExecutionOps    int        `json:"execution_ops"`
+	HasMore         bool       `json:"has_more,omitempty"` // More rows are available via FETCH
 }
 
 // CommitResponse contains mutation operation results.
 type CommitResponse struct {
-	DatabasesCreated int     `json:"databases_created,omitempty"`
-	DatabasesDeleted int     `json:"databases_deleted,omitempty"`
-	TablesCreated    int     `json:"tables_created,omitempty"`
-	TablesDeleted    int     `json:"tables_deleted,omitempty"`
-	RecordsWritten   int     `json:"records_written,omitempty"`
-	RecordsDeleted   int     `json:"records_deleted,omitempty"`
-	ExecutionTimeMs  float64 `json:"execution_time_ms"`
-	ExecutionOps     int     `json:"execution_ops"`
+	DatabasesCreated int            `json:"databases_created,omitempty"`
+	DatabasesDeleted int            `json:"databases_deleted,omitempty"`
+	TablesCreated    int            `json:"tables_created,omitempty"`
+	TablesDeleted    int            `json:"tables_deleted,omitempty"`
+	RecordsWritten   int            `json:"records_written,omitempty"`
+	RecordsDeleted   int            `json:"records_deleted,omitempty"`
+	ExecutionTimeMs  float64        `json:"execution_time_ms"`
+	ExecutionOps     int            `json:"execution_ops"`
+	Returning        *QueryResponse `json:"returning,omitempty"`     // Rows requested with RETURNING
+	AffectedKeys     []string       `json:"affected_keys,omitempty"` // Primary keys written or deleted, with report_keys on
+	DryRun           bool           `json:"dry_run,omitempty"`       // Nothing was committed; the counts are what the statement would change
 }
 
 // AuthResponse contains authentication result.
@@ -2,18 +2,25 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"log"
 	"net"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/nickyhof/CommitDB"
 	"github.com/nickyhof/CommitDB/core"
 	"github.com/nickyhof/CommitDB/db"
+	"github.com/nickyhof/CommitDB/ps"
+	"github.com/nickyhof/CommitDB/sql"
 )
 
 // Server is a TCP SQL server that exposes the CommitDB engine.
@@ -23,10 +30,23 @@ type Server struct {
 	defaultIdentity core.Identity
 	authConfig      *AuthConfig
 	tlsEnabled      bool
+	limits          Limits
 	done            chan struct{}
 	wg              sync.WaitGroup
 }
 
+// Limits caps the work a single query may do so runaway queries cannot
+// exhaust a shared server. Zero values mean no limit.
+type Limits struct {
+	MaxRows        int           // Rows a query may return
+	MaxResultBytes int           // Total size of the values in a query's rows
+	QueryTimeout   time.Duration // Execution time before a query is aborted
+	TruncateRows   bool          // Cut results to MaxRows instead of failing
+}
+
+// ErrResultTooLarge is reported when a query result exceeds a Limits cap.
+var ErrResultTooLarge = db.ErrResultTooLarge
+
 // NewServer creates a new SQL server with the given CommitDB instance.
 // The defaultIdentity is used when auth is disabled or for anonymous connections.
 func NewServer(instance *CommitDB.Instance, identity core.Identity) *Server {
@@ -46,6 +66,27 @@ func NewServerWithAuth(instance *CommitDB.Instance, authConfig *AuthConfig) *Ser
 	}
 }
 
+// SetLimits sets the caps applied to every query. Call it before Start.
+func (s *Server) SetLimits(limits Limits) {
+	s.limits = limits
+}
+
+// newEngine returns an engine for a connection acting as identity. The row
+// cap is handed to the engine so a SELECT stops reading once it is exceeded,
+// as a cap SET max_result_rows can lower but not lift; when results are
+// truncated, the engine keeps one row past the cap so the cursor can tell
+// that rows were dropped.
+func (s *Server) newEngine(identity core.Identity) *db.Engine {
+	engine := s.instance.Engine(identity)
+	engine.MaxResultRowsCap = s.limits.MaxRows
+	if s.limits.TruncateRows && s.limits.MaxRows > 0 {
+		engine.MaxResultRowsCap++
+		engine.TruncateResults = true
+	}
+	engine.MaxResultRows = engine.MaxResultRowsCap
+	return engine
+}
+
 // Start begins listening for connections on the specified address.
 func (s *Server) Start(addr string) error {
 	listener, err := net.Listen("tcp", addr)
@@ -138,9 +179,83 @@ func (s *Server) acceptLoop() {
 
 // connContext holds per-connection state including auth and engine.
 type connContext struct {
-	state  *ConnectionState
-	engine *db.Engine
-	mu     sync.Mutex
+	state     *ConnectionState
+	engine    *db.Engine
+	fetchSize int           // Rows per response; 0 sends results whole
+	cursor    *resultCursor // Rows of the last paged query not yet fetched
+	mu        sync.Mutex
+}
+
+// resultCursor reads the rows of a query from its row iterator, a page at a
+// time when results are paged, so rows that have not been fetched are not
+// held. The query's limits are enforced as rows are read.
+type resultCursor struct {
+	columns   []string
+	next      func() ([]string, error, bool)
+	stop      func()
+	cancel    context.CancelFunc // releases the query's context
+	limits    Limits
+	pending   []string // row read past the last page to learn that more follow
+	rows      int      // rows taken from the iterator
+	bytes     int      // total size of their values
+	truncated bool     // rows past MaxRows were dropped
+}
+
+func newResultCursor(columns []string, rows iter.Seq2[[]string, error], limits Limits, cancel context.CancelFunc) *resultCursor {
+	next, stop := iter.Pull2(rows)
+	return &resultCursor{columns: columns, next: next, stop: stop, cancel: cancel, limits: limits}
+}
+
+// page reads up to size rows, or every remaining row when size is 0, and
+// reports whether more follow them.
+func (c *resultCursor) page(size int) ([][]string, bool, error) {
+	data := [][]string{}
+	if c.pending != nil {
+		data = append(data, c.pending)
+		c.pending = nil
+	}
+	for {
+		row, err, ok := c.next()
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			return data, false, nil
+		}
+		c.rows++
+		if c.limits.MaxRows > 0 && c.rows > c.limits.MaxRows {
+			if c.limits.TruncateRows {
+				c.truncated = true
+				return data, false, nil
+			}
+			return nil, false, fmt.Errorf("%w: more than %d rows", ErrResultTooLarge, c.limits.MaxRows)
+		}
+		for _, value := range row {
+			c.bytes += len(value)
+		}
+		if c.limits.MaxResultBytes > 0 && c.bytes > c.limits.MaxResultBytes {
+			return nil, false, fmt.Errorf("%w: more than %d bytes", ErrResultTooLarge, c.limits.MaxResultBytes)
+		}
+		if size > 0 && len(data) == size {
+			c.pending = row
+			return data, true, nil
+		}
+		data = append(data, row)
+	}
+}
+
+// close stops the cursor's iterator and releases its query.
+func (c *resultCursor) close() {
+	c.stop()
+	c.cancel()
+}
+
+// closeCursor discards the connection's open cursor, if any.
+func (ctx *connContext) closeCursor() {
+	if ctx.cursor != nil {
+		ctx.cursor.close()
+		ctx.cursor = nil
+	}
 }
 
 func (s *Server) handleConnection(conn net.Conn) {
@@ -153,12 +268,15 @@ func (s *Server) handleConnection(conn net.Conn) {
 	ctx := &connContext{
 		state: &ConnectionState{},
 	}
+	defer ctx.closeCursor()
 
 	// If auth is not enabled, pre-authenticate with default identity
 	if s.authConfig == nil || !s.authConfig.Enabled {
 		ctx.state.identity = &s.defaultIdentity
 		ctx.state.authenticated = true
-		ctx.engine = s.instance.Engine(s.defaultIdentity)
+		ctx.engine = s.newEngine(s.defaultIdentity)
+		// Anonymous connections leave table authors in charge of their rows
+		ctx.engine.ImplicitIdentity = true
 	}
 
 	reader := bufio.NewReader(conn)
@@ -207,8 +325,20 @@ func (s *Server) handleConnection(conn net.Conn) {
 			continue
 		}
 
-		// Execute query with connection's engine
-		response := s.executeQueryWithEngine(query, ctx.engine)
+		// Check for FETCHSIZE / FETCH commands
+		upper := strings.ToUpper(query)
+		if strings.HasPrefix(upper, "FETCHSIZE ") {
+			s.sendResponse(conn, s.handleFetchSizeCommand(query, ctx))
+			continue
+		}
+		if upper == "FETCH" {
+			s.sendResponse(conn, s.handleFetchCommand(ctx))
+			continue
+		}
+
+		// Execute query with connection's engine; a new query discards any open cursor
+		ctx.closeCursor()
+		response := s.executeQueryWithEngine(query, ctx)
 		s.sendResponse(conn, response)
 	}
 }
@@ -228,13 +358,63 @@ func (s *Server) handleAuthCommand(query string, ctx *connContext) Response {
 	// If auth succeeded, create engine with new identity
 	if response.Success && ctx.state.identity != nil {
 		ctx.mu.Lock()
-		ctx.engine = s.instance.Engine(*ctx.state.identity)
+		ctx.engine = s.newEngine(*ctx.state.identity)
 		ctx.mu.Unlock()
 	}
 
 	return response
 }
 
+// handleFetchSizeCommand processes FETCHSIZE <n>, which sets how many rows
+// each query response carries. A size of 0 disables paging.
+func (s *Server) handleFetchSizeCommand(query string, ctx *connContext) Response {
+	parts := strings.Fields(query)
+	if len(parts) != 2 {
+		return Response{Success: false, Error: "usage: FETCHSIZE <rows>"}
+	}
+	size, err := strconv.Atoi(parts[1])
+	if err != nil || size < 0 {
+		return Response{Success: false, Error: fmt.Sprintf("invalid fetch size: %s", parts[1])}
+	}
+	ctx.fetchSize = size
+	ctx.closeCursor()
+	return Response{Success: true, Type: "fetchsize"}
+}
+
+// handleFetchCommand sends the next page of the connection's open cursor.
+func (s *Server) handleFetchCommand(ctx *connContext) Response {
+	if ctx.cursor == nil {
+		return Response{Success: false, Error: "no open cursor: run a query first"}
+	}
+	return s.nextPage(ctx, QueryResponse{})
+}
+
+// nextPage reads up to fetchSize rows from the cursor, or all of them when
+// paging is off, and closes the cursor once it is drained or fails. Metrics from qr other than the row count are
+// carried on the page as-is.
+func (s *Server) nextPage(ctx *connContext, qr QueryResponse) Response {
+	cursor := ctx.cursor
+	rows, more, err := cursor.page(ctx.fetchSize)
+	if err != nil {
+		ctx.closeCursor()
+		return errorResponse(err)
+	}
+	qr.Columns = cursor.columns
+	qr.Data = rows
+	qr.RecordsRead = len(rows)
+	qr.Truncated = qr.Truncated || cursor.truncated
+	qr.HasMore = more
+	if !more {
+		ctx.closeCursor()
+	}
+	data, _ := json.Marshal(qr)
+	return Response{
+		Success: true,
+		Type:    "query",
+		Result:  data,
+	}
+}
+
 func (s *Server) sendResponse(conn net.Conn, response Response) {
 	data, err := EncodeResponse(response)
 	if err != nil {
@@ -248,30 +428,71 @@ func (s *Server) sendResponse(conn net.Conn, response Response) {
 	}
 }
 
-func (s *Server) executeQueryWithEngine(query string, engine *db.Engine) Response {
-	result, err := engine.Execute(query)
-	if err != nil {
-		return Response{
-			Success: false,
-			Error:   err.Error(),
+// errorCode classifies an engine error for clients: "syntax_error",
+// "not_found", "constraint_violation", "result_too_large", "timeout", or ""
+// for anything else.
+func errorCode(err error) string {
+	var syntaxErr *sql.SyntaxError
+	var constraintErr *db.ConstraintError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return "syntax_error"
+	case errors.Is(err, ps.ErrDatabaseNotFound), errors.Is(err, ps.ErrTableNotFound), errors.Is(err, ps.ErrViewNotFound):
+		return "not_found"
+	case errors.As(err, &constraintErr):
+		return "constraint_violation"
+	case errors.Is(err, ErrResultTooLarge):
+		return "result_too_large"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return ""
+	}
+}
+
+// errorResponse reports a failed statement.
+func errorResponse(err error) Response {
+	return Response{
+		Success: false,
+		Error:   err.Error(),
+		Code:    errorCode(err),
+	}
+}
+
+func (s *Server) executeQueryWithEngine(query string, ctx *connContext) Response {
+	execCtx, cancel := context.WithCancel(context.Background())
+	if s.limits.QueryTimeout > 0 {
+		execCtx, cancel = context.WithTimeout(context.Background(), s.limits.QueryTimeout)
+	}
+	// A cursor left open keeps reading rows under the query's context
+	defer func() {
+		if ctx.cursor == nil {
+			cancel()
 		}
+	}()
+
+	// A streamed SELECT's rows are read from storage as they are sent
+	result, rows, err := ctx.engine.ExecuteRows(execCtx, query)
+	if err != nil {
+		return errorResponse(err)
 	}
 
 	switch r := result.(type) {
 	case db.QueryResult:
 		qr := QueryResponse{
-			Columns:         r.Columns,
-			Data:            r.Data,
-			RecordsRead:     r.RecordsRead,
+			TotalRecords:    r.TotalRecords,
+			CorruptRows:     r.CorruptRows,
+			Truncated:       r.Truncated,
 			ExecutionTimeMs: r.ExecutionTimeMs,
 			ExecutionOps:    r.ExecutionOps,
 		}
-		data, _ := json.Marshal(qr)
-		return Response{
-			Success: true,
-			Type:    "query",
-			Result:  data,
+		// Rows are read through a cursor, checking the limits row by row; a
+		// paged result's cursor is left open for FETCH while rows remain
+		if rows == nil {
+			rows = dataRows(r.Data)
 		}
+		ctx.cursor = newResultCursor(r.Columns, rows, s.limits, cancel)
+		return s.nextPage(ctx, qr)
 
 	case db.CommitResult:
 		cr := CommitResponse{
@@ -283,6 +504,15 @@ func (s *Server) executeQueryWithEngine(query string, engine *db.Engine) Respons
 			RecordsDeleted:   r.RecordsDeleted,
 			ExecutionTimeMs:  r.ExecutionTimeMs,
 			ExecutionOps:     r.ExecutionOps,
+			AffectedKeys:     r.AffectedKeys,
+			DryRun:           r.DryRun,
+		}
+		if r.Returning != nil {
+			cr.Returning = &QueryResponse{
+				Columns:     r.Returning.Columns,
+				Data:        r.Returning.Data,
+				RecordsRead: r.Returning.RecordsRead,
+			}
 		}
 		data, _ := json.Marshal(cr)
 		return Response{
@@ -298,3 +528,14 @@ func (s *Server) executeQueryWithEngine(query string, engine *db.Engine) Respons
 		}
 	}
 }
+
+// dataRows iterates the rows of a built result.
+func dataRows(data [][]string) iter.Seq2[[]string, error] {
+	return func(yield func([]string, error) bool) {
+		for _, row := range data {
+			if !yield(row, nil) {
+				return
+			}
+		}
+	}
+}
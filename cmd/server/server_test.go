@@ -9,9 +9,13 @@ import (
 	"crypto/x509/pkix"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
+	"fmt"
 	"math/big"
 	"net"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -168,6 +172,9 @@ func TestServerError(t *testing.T) {
 	if resp.Error == "" {
 		t.Error("Expected error message")
 	}
+	if resp.Code != "not_found" {
+		t.Errorf("Expected code not_found, got %q", resp.Code)
+	}
 }
 
 func TestServerSyntaxError(t *testing.T) {
@@ -181,6 +188,9 @@ func TestServerSyntaxError(t *testing.T) {
 	if resp.Error == "" {
 		t.Error("Expected error message")
 	}
+	if resp.Code != "syntax_error" {
+		t.Errorf("Expected code syntax_error, got %q", resp.Code)
+	}
 }
 
 func TestServerPersistentConnection(t *testing.T) {
@@ -226,6 +236,241 @@ func TestServerPersistentConnection(t *testing.T) {
 	}
 }
 
+func TestServerFetchSizePaging(t *testing.T) {
+	server, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	conn, err := net.DialTimeout("tcp", server.Addr(), 2*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	send := func(query string) Response {
+		if _, err := conn.Write([]byte(query + "\n")); err != nil {
+			t.Fatalf("Failed to send query '%s': %v", query, err)
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read response for '%s': %v", query, err)
+		}
+		var resp Response
+		if err := json.Unmarshal([]byte(line), &resp); err != nil {
+			t.Fatalf("Failed to parse response for '%s': %v", query, err)
+		}
+		if !resp.Success {
+			t.Fatalf("Query '%s' failed: %s", query, resp.Error)
+		}
+		return resp
+	}
+
+	send("CREATE DATABASE pagedb")
+	send("CREATE TABLE pagedb.items (id INT PRIMARY KEY)")
+	for i := 1; i <= 25; i++ {
+		send(fmt.Sprintf("INSERT INTO pagedb.items (id) VALUES (%d)", i))
+	}
+	send("FETCHSIZE 10")
+
+	var pages []int
+	resp := send("SELECT * FROM pagedb.items")
+	for {
+		var qr QueryResponse
+		if err := json.Unmarshal(resp.Result, &qr); err != nil {
+			t.Fatalf("Failed to parse query result: %v", err)
+		}
+		if len(qr.Columns) != 1 || qr.Columns[0] != "id" {
+			t.Errorf("Expected columns [id] on every page, got %v", qr.Columns)
+		}
+		pages = append(pages, len(qr.Data))
+		if !qr.HasMore {
+			break
+		}
+		resp = send("FETCH")
+	}
+
+	if fmt.Sprint(pages) != "[10 10 5]" {
+		t.Errorf("Expected pages of [10 10 5], got %v", pages)
+	}
+
+	// The cursor is closed once drained
+	if _, err := conn.Write([]byte("FETCH\n")); err != nil {
+		t.Fatalf("Failed to send FETCH: %v", err)
+	}
+	line, _ := reader.ReadString('\n')
+	var resp2 Response
+	json.Unmarshal([]byte(line), &resp2)
+	if resp2.Success {
+		t.Error("Expected FETCH without an open cursor to fail")
+	}
+}
+
+func TestServerFetchStreamsRows(t *testing.T) {
+	persistence, err := ps.NewMemoryPersistence()
+	if err != nil {
+		t.Fatalf("Failed to create persistence: %v", err)
+	}
+	instance := CommitDB.Open(&persistence)
+	identity := core.Identity{Name: "test", Email: "test@test.com"}
+	server := NewServer(instance, identity)
+
+	engine := server.newEngine(identity)
+	for _, query := range []string{"CREATE DATABASE streamdb", "CREATE TABLE streamdb.items (id INT PRIMARY KEY, name STRING)"} {
+		if _, err := engine.Execute(query); err != nil {
+			t.Fatalf("Query '%s' failed: %v", query, err)
+		}
+	}
+	var values []string
+	for i := 1; i <= 1000; i++ {
+		values = append(values, fmt.Sprintf("(%d, 'item%d')", i, i))
+	}
+	if _, err := engine.Execute("INSERT INTO streamdb.items (id, name) VALUES " + strings.Join(values, ", ")); err != nil {
+		t.Fatalf("Failed to insert rows: %v", err)
+	}
+
+	ctx := &connContext{state: &ConnectionState{}, engine: engine, fetchSize: 100}
+	defer ctx.closeCursor()
+	resp := server.executeQueryWithEngine("SELECT * FROM streamdb.items", ctx)
+	sent := 0
+	for {
+		if !resp.Success {
+			t.Fatalf("Page failed: %s", resp.Error)
+		}
+		var qr QueryResponse
+		if err := json.Unmarshal(resp.Result, &qr); err != nil {
+			t.Fatalf("Failed to parse query result: %v", err)
+		}
+		if len(qr.Data) != 100 || qr.Data[0][0] != strconv.Itoa(sent+1) {
+			t.Fatalf("Expected 100 rows from id %d, got %d rows", sent+1, len(qr.Data))
+		}
+		sent += len(qr.Data)
+		if !qr.HasMore {
+			break
+		}
+		// Each FETCH reads its page, plus the row that shows more follow
+		if ctx.cursor.rows != sent+1 {
+			t.Fatalf("Expected %d rows read after sending %d, got %d", sent+1, sent, ctx.cursor.rows)
+		}
+		resp = server.handleFetchCommand(ctx)
+	}
+	if sent != 1000 || ctx.cursor != nil {
+		t.Errorf("Expected all 1000 rows and a closed cursor, got %d rows", sent)
+	}
+}
+
+func TestResultCursorLimits(t *testing.T) {
+	// Each row holds 4 bytes; read counts the rows taken from the source
+	read := 0
+	rows := func(yield func([]string, error) bool) {
+		for i := 0; i < 100; i++ {
+			read++
+			if !yield([]string{"abcd"}, nil) {
+				return
+			}
+		}
+	}
+
+	cursor := newResultCursor([]string{"v"}, rows, Limits{MaxResultBytes: 10}, func() {})
+	_, _, err := cursor.page(0)
+	cursor.close()
+	if !errors.Is(err, ErrResultTooLarge) || read != 3 {
+		t.Errorf("Expected the byte cap to stop reading at the third row, got %v after %d rows", err, read)
+	}
+
+	read = 0
+	cursor = newResultCursor([]string{"v"}, rows, Limits{MaxRows: 5, TruncateRows: true}, func() {})
+	data, more, err := cursor.page(0)
+	cursor.close()
+	if err != nil || len(data) != 5 || more || !cursor.truncated || read != 6 {
+		t.Errorf("Expected 5 rows cut at the sixth, got %d rows after reading %d (more %v, truncated %v, %v)", len(data), read, more, cursor.truncated, err)
+	}
+}
+
+func TestServerLimits(t *testing.T) {
+	persistence, err := ps.NewMemoryPersistence()
+	if err != nil {
+		t.Fatalf("Failed to create persistence: %v", err)
+	}
+	instance := CommitDB.Open(&persistence)
+	identity := core.Identity{Name: "test", Email: "test@test.com"}
+
+	start := func(limits Limits) *Server {
+		server := NewServer(instance, identity)
+		server.SetLimits(limits)
+		if err := server.Start(":0"); err != nil {
+			t.Fatalf("Failed to start server: %v", err)
+		}
+		t.Cleanup(func() { server.Stop() })
+		return server
+	}
+
+	strict := start(Limits{MaxRows: 3})
+	sendQuery(t, strict.Addr(), "CREATE DATABASE limitdb")
+	sendQuery(t, strict.Addr(), "CREATE TABLE limitdb.items (id INT PRIMARY KEY, name STRING)")
+	for i := 1; i <= 5; i++ {
+		sendQuery(t, strict.Addr(), fmt.Sprintf("INSERT INTO limitdb.items (id, name) VALUES (%d, 'item%d')", i, i))
+	}
+
+	resp := sendQuery(t, strict.Addr(), "SELECT * FROM limitdb.items")
+	if resp.Success || resp.Code != "result_too_large" || !strings.Contains(resp.Error, "stopped after scanning 4 rows") {
+		t.Errorf("Expected the scan to stop with result_too_large, got success=%v code=%q error=%q", resp.Success, resp.Code, resp.Error)
+	}
+	if resp := sendQuery(t, strict.Addr(), "SELECT * FROM limitdb.items WHERE id <= 3"); !resp.Success {
+		t.Errorf("Expected query within the row cap to succeed: %s", resp.Error)
+	}
+
+	// A session can lower the server's row cap but not lift it
+	engine := strict.newEngine(identity)
+	if _, err := engine.Execute("SET max_result_rows = DEFAULT"); err != nil {
+		t.Fatalf("SET max_result_rows = DEFAULT failed: %v", err)
+	}
+	if _, err := engine.Execute("SELECT * FROM limitdb.items"); !errors.Is(err, ErrResultTooLarge) {
+		t.Errorf("Expected the server's row cap to survive DEFAULT, got %v", err)
+	}
+	if _, err := engine.Execute("SET max_result_rows = 10"); err == nil {
+		t.Error("Expected raising max_result_rows above the server's cap to fail")
+	}
+	if _, err := engine.Execute("SET max_result_rows = 2"); err != nil {
+		t.Fatalf("SET max_result_rows = 2 failed: %v", err)
+	}
+	if _, err := engine.Execute("SELECT * FROM limitdb.items WHERE id <= 3"); !errors.Is(err, ErrResultTooLarge) {
+		t.Errorf("Expected the session's lower cap to apply, got %v", err)
+	}
+
+	truncating := start(Limits{MaxRows: 3, TruncateRows: true})
+	resp = sendQuery(t, truncating.Addr(), "SELECT * FROM limitdb.items")
+	if !resp.Success {
+		t.Fatalf("Expected truncated query to succeed: %s", resp.Error)
+	}
+	var qr QueryResponse
+	if err := json.Unmarshal(resp.Result, &qr); err != nil {
+		t.Fatalf("Failed to parse query result: %v", err)
+	}
+	if len(qr.Data) != 3 || qr.RecordsRead != 3 || !qr.Truncated {
+		t.Errorf("Expected 3 rows marked truncated, got %d rows (records_read %d, truncated %v)", len(qr.Data), qr.RecordsRead, qr.Truncated)
+	}
+	resp = sendQuery(t, truncating.Addr(), "SELECT * FROM limitdb.items ORDER BY name DESC")
+	qr = QueryResponse{}
+	if err := json.Unmarshal(resp.Result, &qr); err != nil || len(qr.Data) != 3 || qr.Data[0][0] != "5" || !qr.Truncated {
+		t.Errorf("Expected the first 3 sorted rows marked truncated, got %+v (%v)", qr, err)
+	}
+	resp = sendQuery(t, truncating.Addr(), "SELECT * FROM limitdb.items WHERE id <= 3")
+	qr = QueryResponse{}
+	if err := json.Unmarshal(resp.Result, &qr); err != nil || len(qr.Data) != 3 || qr.Truncated {
+		t.Errorf("Expected 3 rows within the cap not to be truncated, got %+v (%v)", qr, err)
+	}
+
+	small := start(Limits{MaxResultBytes: 10})
+	if resp := sendQuery(t, small.Addr(), "SELECT name FROM limitdb.items"); resp.Code != "result_too_large" {
+		t.Errorf("Expected result_too_large for byte cap, got code=%q error=%q", resp.Code, resp.Error)
+	}
+
+	timed := start(Limits{QueryTimeout: time.Nanosecond})
+	if resp := sendQuery(t, timed.Addr(), "SELECT * FROM limitdb.items"); resp.Code != "timeout" {
+		t.Errorf("Expected timeout error, got code=%q error=%q", resp.Code, resp.Error)
+	}
+}
+
 // setupAuthTestServer creates a server with authentication enabled
 func setupAuthTestServer(t *testing.T, secret string) (*Server, func()) {
 	persistence, err := ps.NewMemoryPersistence()
@@ -11,16 +11,35 @@ const (
 	DateType
 	TimestampType
 	JsonType
+	BlobType // Arbitrary bytes, stored base64-encoded
 )
 
 type Column struct {
-	Name       string     `json:"name"`
-	Type       ColumnType `json:"type"`
-	PrimaryKey bool       `json:"primaryKey"`
+	Name        string     `json:"name"`
+	Type        ColumnType `json:"type"`
+	PrimaryKey  bool       `json:"primaryKey"`
+	Default     *string    `json:"default,omitempty"`     // Value written when INSERT omits the column; nil means NULL
+	DefaultExpr string     `json:"defaultExpr,omitempty"` // Function call such as NOW() evaluated instead of Default
+	NotNull     bool       `json:"notNull,omitempty"`     // NOT NULL: INSERT and UPDATE reject NULL values
+	Comment     string     `json:"comment,omitempty"`
 }
 
 type Table struct {
 	Database string   `json:"database"`
 	Name     string   `json:"name"`
 	Columns  []Column `json:"columns"`
+	Fanout   int      `json:"fanout,omitempty"` // Hash-prefix directory levels for row blobs; 0 stores rows flat
+	Comment  string   `json:"comment,omitempty"`
+
+	// Author, when set, is the identity the engine commits row writes to
+	// this table as, e.g. a service account for automated loads.
+	Author *Identity `json:"author,omitempty"`
+
+	// Checks are the conditions of CHECK constraints, as written, that rows
+	// written by INSERT and UPDATE must not make false.
+	Checks []string `json:"checks,omitempty"`
+
+	// Renames maps former column names to their current names, so rows
+	// written before ALTER TABLE ... RENAME COLUMN still read correctly.
+	Renames map[string]string `json:"renames,omitempty"`
 }
@@ -0,0 +1,13 @@
+package core
+
+import "time"
+
+// Trigger runs SQL statements after every INSERT, UPDATE or DELETE on a table
+type Trigger struct {
+	Database  string    `json:"database"`
+	Name      string    `json:"name"`
+	Table     string    `json:"table"` // Table in Database whose writes fire the trigger
+	Event     string    `json:"event"` // "INSERT", "UPDATE" or "DELETE"
+	Body      string    `json:"body"`  // Statements run once per affected row, separated by ';'
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -10,5 +10,12 @@ type View struct {
 	Materialized bool      `json:"materialized"` // True if this is a materialized view
 	Columns      []Column  `json:"columns"`      // Inferred column schema
 	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"` // Last refresh time for materialized views
+	UpdatedAt    time.Time `json:"updated_at"`         // Last refresh time for materialized views
+	Snapshot     string    `json:"snapshot,omitempty"` // Transaction the materialized data was computed from
+
+	// AutoRefresh refreshes a materialized view after every write to a table
+	// its query reads, or, with a RefreshInterval, when it is read after the
+	// interval has passed since the last refresh
+	AutoRefresh     bool          `json:"auto_refresh,omitempty"`
+	RefreshInterval time.Duration `json:"refresh_interval,omitempty"`
 }
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"github.com/nickyhof/CommitDB/sql"
+)
+
+// SelectBuilder builds a sql.SelectStatement without writing SQL. Values are
+// stored as given, so nothing needs quoting or escaping.
+//
+//	statement := db.Select("name", "email").
+//	    From("mydb", "users").
+//	    Where("age", sql.GreaterThanOperator, "25").
+//	    OrderBy("name", false).
+//	    Statement()
+//	result, err := engine.ExecuteStatement(statement)
+type SelectBuilder struct {
+	statement sql.SelectStatement
+}
+
+// Select starts a query for the given columns; no columns selects all of them.
+func Select(columns ...string) *SelectBuilder {
+	return &SelectBuilder{statement: sql.SelectStatement{Columns: append([]string{}, columns...)}}
+}
+
+// From sets the table (or view) to query.
+func (builder *SelectBuilder) From(database, table string) *SelectBuilder {
+	builder.statement.Database = database
+	builder.statement.Table = table
+	return builder
+}
+
+// Distinct removes duplicate rows from the result.
+func (builder *SelectBuilder) Distinct() *SelectBuilder {
+	builder.statement.Distinct = true
+	return builder
+}
+
+// DistinctOn keeps only the first row, in ORDER BY order, for each distinct
+// combination of the given columns.
+func (builder *SelectBuilder) DistinctOn(columns ...string) *SelectBuilder {
+	builder.statement.DistinctOn = append([]string{}, columns...)
+	return builder
+}
+
+// Aggregate adds an aggregate such as COUNT(*) or SUM(amount) to the select
+// list. An empty alias names the column FUNCTION(column).
+func (builder *SelectBuilder) Aggregate(function, column, alias string) *SelectBuilder {
+	builder.statement.Aggregates = append(builder.statement.Aggregates, sql.AggregateExpr{
+		Function: function,
+		Column:   column,
+		Alias:    alias,
+	})
+	return builder
+}
+
+// Where adds a condition joined to the previous ones with AND.
+func (builder *SelectBuilder) Where(column string, operator sql.WhereOperator, value string) *SelectBuilder {
+	return builder.addCondition(sql.LogicalAnd, sql.WhereCondition{Left: column, Operator: operator, Right: value})
+}
+
+// OrWhere adds a condition joined to the previous ones with OR.
+func (builder *SelectBuilder) OrWhere(column string, operator sql.WhereOperator, value string) *SelectBuilder {
+	return builder.addCondition(sql.LogicalOr, sql.WhereCondition{Left: column, Operator: operator, Right: value})
+}
+
+// WhereIn adds a column IN (values...) condition joined with AND.
+func (builder *SelectBuilder) WhereIn(column string, values ...string) *SelectBuilder {
+	return builder.addCondition(sql.LogicalAnd, sql.WhereCondition{Left: column, Operator: sql.InOperator, InValues: values, InSet: sql.InValueSet(values)})
+}
+
+// WhereNull adds a column IS NULL condition joined with AND.
+func (builder *SelectBuilder) WhereNull(column string) *SelectBuilder {
+	return builder.addCondition(sql.LogicalAnd, sql.WhereCondition{Left: column, Operator: sql.IsNullOperator})
+}
+
+// WhereNotNull adds a column IS NOT NULL condition joined with AND.
+func (builder *SelectBuilder) WhereNotNull(column string) *SelectBuilder {
+	return builder.addCondition(sql.LogicalAnd, sql.WhereCondition{Left: column, Operator: sql.IsNotNullOperator})
+}
+
+func (builder *SelectBuilder) addCondition(logicalOp sql.LogicalOperator, condition sql.WhereCondition) *SelectBuilder {
+	where := &builder.statement.Where
+	if len(where.Conditions) > 0 {
+		where.LogicalOps = append(where.LogicalOps, logicalOp)
+	}
+	where.Conditions = append(where.Conditions, condition)
+	return builder
+}
+
+// GroupBy groups rows by the given columns.
+func (builder *SelectBuilder) GroupBy(columns ...string) *SelectBuilder {
+	builder.statement.GroupBy = append(builder.statement.GroupBy, columns...)
+	return builder
+}
+
+// OrderBy adds a sort column; later calls break ties of earlier ones.
+func (builder *SelectBuilder) OrderBy(column string, descending bool) *SelectBuilder {
+	builder.statement.OrderBy = append(builder.statement.OrderBy, sql.OrderByClause{Column: column, Descending: descending})
+	return builder
+}
+
+// Limit caps the number of rows returned.
+func (builder *SelectBuilder) Limit(limit int) *SelectBuilder {
+	builder.statement.Limit = limit
+	return builder
+}
+
+// Offset skips the first rows of the result.
+func (builder *SelectBuilder) Offset(offset int) *SelectBuilder {
+	builder.statement.Offset = offset
+	return builder
+}
+
+// Statement returns the built statement, ready for Engine.ExecuteStatement.
+func (builder *SelectBuilder) Statement() sql.SelectStatement {
+	return builder.statement
+}
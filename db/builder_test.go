@@ -0,0 +1,79 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nickyhof/CommitDB/sql"
+)
+
+func TestSelectBuilderMatchesSQL(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+
+	tests := []struct {
+		name    string
+		query   string
+		builder *SelectBuilder
+	}{
+		{
+			"filter and order",
+			"SELECT name, age FROM testdb.users WHERE age > 26 ORDER BY age DESC",
+			Select("name", "age").From("testdb", "users").Where("age", sql.GreaterThanOperator, "26").OrderBy("age", true),
+		},
+		{
+			"or and limit",
+			"SELECT * FROM testdb.users WHERE name = 'Alice' OR name = 'Charlie' LIMIT 1 OFFSET 1",
+			Select().From("testdb", "users").Where("name", sql.EqualsOperator, "Alice").OrWhere("name", sql.EqualsOperator, "Charlie").Limit(1).Offset(1),
+		},
+		{
+			"in list",
+			"SELECT id FROM testdb.users WHERE name IN ('Bob', 'Charlie')",
+			Select("id").From("testdb", "users").WhereIn("name", "Bob", "Charlie"),
+		},
+		{
+			"aggregate",
+			"SELECT COUNT(*) AS total, SUM(age) FROM testdb.users",
+			Select().From("testdb", "users").Aggregate("COUNT", "*", "total").Aggregate("SUM", "age", ""),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expected, err := engine.Execute(test.query)
+			if err != nil {
+				t.Fatalf("SQL query failed: %v", err)
+			}
+			actual, err := engine.ExecuteStatement(test.builder.Statement())
+			if err != nil {
+				t.Fatalf("Built query failed: %v", err)
+			}
+
+			want, got := expected.(QueryResult), actual.(QueryResult)
+			if !reflect.DeepEqual(got.Columns, want.Columns) || !reflect.DeepEqual(got.Data, want.Data) {
+				t.Errorf("Built query returned %v %v, SQL returned %v %v", got.Columns, got.Data, want.Columns, want.Data)
+			}
+		})
+	}
+}
+
+func TestSelectBuilderValuesNeedNoQuoting(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+
+	result, err := engine.ExecuteStatement(Select("id").From("testdb", "users").Where("name", sql.EqualsOperator, "x' OR '1'='1").Statement())
+	if err != nil {
+		t.Fatalf("Built query failed: %v", err)
+	}
+	if qr := result.(QueryResult); len(qr.Data) != 0 {
+		t.Errorf("Expected the value to be compared literally, got %v", qr.Data)
+	}
+
+	result, err = engine.ExecuteStatement(Select("id").From("testdb", "users").Where("name", sql.EqualsOperator, "Bob").Statement())
+	if err != nil {
+		t.Fatalf("Built query failed: %v", err)
+	}
+	if qr := result.(QueryResult); len(qr.Data) != 1 || qr.Data[0][0] != "2" {
+		t.Errorf("Expected row 2, got %v", qr.Data)
+	}
+}
@@ -0,0 +1,195 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/nickyhof/CommitDB/core"
+	"github.com/nickyhof/CommitDB/op"
+	"github.com/nickyhof/CommitDB/ps"
+	"github.com/nickyhof/CommitDB/sql"
+)
+
+// checkFinding is one problem reported by CHECK DATABASE. Key is empty for
+// problems with a table rather than a row.
+type checkFinding struct {
+	database string
+	table    string
+	key      string
+	problem  string
+}
+
+// executeCheckDatabaseStatement verifies that every table schema parses,
+// every row is a JSON object whose values fit the schema, and every index
+// agrees with the rows it covers. Nothing is written; each problem found is a
+// row of the result, so a healthy database returns no rows.
+func (engine *Engine) executeCheckDatabaseStatement(statement sql.CheckDatabaseStatement) (QueryResult, error) {
+	startTime := time.Now()
+
+	databases := []string{statement.Database}
+	if statement.Database == "" {
+		databases = engine.Persistence.ListDatabases()
+		slices.Sort(databases)
+	} else if _, err := engine.Persistence.GetDatabase(statement.Database); err != nil {
+		return QueryResult{}, err
+	}
+
+	var findings []checkFinding
+	rowsChecked := 0
+	for _, database := range databases {
+		tables := engine.Persistence.ListTables(database)
+		slices.Sort(tables)
+		for _, name := range tables {
+			tableFindings, rows := engine.checkTable(database, name)
+			findings = append(findings, tableFindings...)
+			rowsChecked += rows
+		}
+	}
+
+	data := make([][]string, len(findings))
+	for i, finding := range findings {
+		data[i] = []string{finding.database, finding.table, finding.key, finding.problem}
+	}
+
+	return QueryResult{
+		Columns:         []string{"database", "table", "key", "problem"},
+		Data:            data,
+		RecordsRead:     len(data),
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    rowsChecked,
+	}, nil
+}
+
+// checkTable returns the problems found in one table and the number of rows
+// it read.
+func (engine *Engine) checkTable(database, name string) ([]checkFinding, int) {
+	var findings []checkFinding
+	report := func(key, format string, args ...any) {
+		findings = append(findings, checkFinding{database, name, key, fmt.Sprintf(format, args...)})
+	}
+
+	tableOp, err := op.GetTable(database, name, engine.Persistence)
+	if err != nil {
+		report("", "schema is unreadable: %v", err)
+		return findings, 0
+	}
+	table := tableOp.Table
+	pk, err := tableOp.PrimaryKey()
+	if err != nil {
+		report("", "schema has no primary key")
+		return findings, 0
+	}
+
+	schema := make(map[string]bool, len(table.Columns))
+	for _, col := range table.Columns {
+		schema[col.Name] = true
+	}
+
+	rows := make(map[string]map[string]string)
+	rowsChecked := 0
+	for key, rawData := range tableOp.Scan() {
+		rowsChecked++
+
+		var row map[string]string
+		if err := json.Unmarshal(rawData, &row); err != nil {
+			report(key, "row is not valid JSON: %v", err)
+			rows[key] = nil
+			continue
+		}
+		normalizeRow(row, table)
+		rows[key] = row
+
+		if value, ok := row[*pk]; !ok || value != key {
+			report(key, "primary key %s does not match the row key", *pk)
+		}
+		for _, col := range table.Columns {
+			value, ok := row[col.Name]
+			if !ok {
+				if col.NotNull {
+					report(key, "NOT NULL column %s is missing", col.Name)
+				}
+				continue
+			}
+			if err := checkColumnValue(table, col.Name, value); err != nil {
+				report(key, "%v", err)
+			}
+		}
+		var unknown []string
+		for column := range row {
+			if !schema[column] {
+				unknown = append(unknown, column)
+			}
+		}
+		slices.Sort(unknown)
+		for _, column := range unknown {
+			report(key, "column %s is not in the schema", column)
+		}
+	}
+
+	findings = append(findings, engine.checkIndexes(table, rows)...)
+	return findings, rowsChecked
+}
+
+// checkIndexes compares each index of table with rows, the table's rows by
+// key, nil for rows that could not be read. Every non-NULL value must be
+// indexed under its row's key, and every indexed key must name a row holding
+// that value.
+func (engine *Engine) checkIndexes(table core.Table, rows map[string]map[string]string) []checkFinding {
+	var findings []checkFinding
+	report := func(key, format string, args ...any) {
+		findings = append(findings, checkFinding{table.Database, table.Name, key, fmt.Sprintf(format, args...)})
+	}
+
+	keys := make([]string, 0, len(rows))
+	for key := range rows {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	indexManager := ps.NewIndexManager(engine.Persistence, engine.Identity)
+	if err := indexManager.LoadIndexes(table.Database, table.Name, table.Columns); err != nil {
+		report("", "indexes are unreadable: %v", err)
+		return findings
+	}
+
+	for _, col := range table.Columns {
+		idx, exists := indexManager.GetIndex(table.Database, table.Name, col.Name)
+		if !exists {
+			// LoadIndexes skips index files it cannot parse
+			path := fmt.Sprintf("%s/%s.index.%s", table.Database, table.Name, col.Name)
+			if _, err := engine.Persistence.ReadFileDirect(path); err == nil {
+				report("", "index on %s is not valid JSON", col.Name)
+			}
+			continue
+		}
+
+		for _, key := range keys {
+			value, ok := rows[key][col.Name]
+			if !ok || value == sql.NullValue {
+				continue
+			}
+			if !slices.Contains(idx.Lookup(value), key) {
+				report(key, "index %s is missing value '%s'", idx.Name, value)
+			}
+		}
+
+		values := make([]string, 0, len(idx.Entries))
+		for value := range idx.Entries {
+			values = append(values, value)
+		}
+		slices.Sort(values)
+		for _, value := range values {
+			for _, key := range idx.Entries[value] {
+				row, ok := rows[key]
+				if !ok {
+					report(key, "index %s lists a row that does not exist", idx.Name)
+				} else if row != nil && row[col.Name] != value {
+					report(key, "index %s lists value '%s' the row does not hold", idx.Name, value)
+				}
+			}
+		}
+	}
+	return findings
+}
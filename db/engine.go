@@ -1,15 +1,21 @@
 package db
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"maps"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/nickyhof/CommitDB/core"
 	"github.com/nickyhof/CommitDB/op"
@@ -20,6 +26,31 @@ import (
 type Engine struct {
 	*ps.Persistence
 	QueryContext
+	functions    map[string]Function // registered with RegisterFunction
+	transaction  *transaction        // open BEGIN block, nil outside one
+	triggerDepth int                 // triggers currently running, to stop runaway recursion
+	nesting      int                 // statements currently executing, including nested ones
+	written      []string            // "database.table" written by the running statement, for view auto-refresh when it ends
+	warnings     []string            // raised by the last statement, for SHOW WARNINGS
+}
+
+// transaction is an open BEGIN block. Its writes are held in the write-behind
+// buffer of a session of the persistence, private to the engine, until COMMIT
+// turns them into one commit. A READ ONLY block instead reads every table as
+// of the commit that was HEAD at BEGIN.
+type transaction struct {
+	base       *ps.Persistence // the engine's persistence outside the block
+	written    []string        // "database.table" of written tables, for view auto-refresh at COMMIT
+	readOnly   bool            // BEGIN READ ONLY
+	snapshot   string          // transaction ID READ ONLY reads are pinned to
+	savepoints []savepoint     // open savepoints, oldest first
+}
+
+// savepoint is a SAVEPOINT of the open transaction: the state of its
+// buffered writes when the savepoint was set.
+type savepoint struct {
+	name string
+	mark ps.WriteMark
 }
 
 func NewEngine(persistence *ps.Persistence, identity core.Identity) *Engine {
@@ -30,21 +61,83 @@ func NewEngine(persistence *ps.Persistence, identity core.Identity) *Engine {
 }
 
 func (engine *Engine) Execute(query string) (Result, error) {
-	parser := sql.NewParser(query)
-	statement, err := parser.Parse()
+	return engine.ExecuteContext(context.Background(), query)
+}
+
+// ExecuteContext executes a statement, aborting SELECT scans and long-running
+// COPY imports and exports when ctx is cancelled. An aborted import commits
+// nothing.
+func (engine *Engine) ExecuteContext(ctx context.Context, query string) (Result, error) {
+	statement, err := engine.parse(query)
 	if err != nil {
 		return nil, err
 	}
+	return engine.ExecuteStatementContext(ctx, statement)
+}
+
+// parse parses a statement with the engine's IN list limit.
+func (engine *Engine) parse(query string) (sql.Statement, error) {
+	parser := sql.NewParser(query)
+	if engine.MaxInValues != 0 {
+		parser.MaxInValues = engine.MaxInValues
+	}
+	return parser.Parse()
+}
+
+// ExecuteStatement executes an already parsed or built statement, such as
+// one produced by Select.
+func (engine *Engine) ExecuteStatement(statement sql.Statement) (Result, error) {
+	return engine.ExecuteStatementContext(context.Background(), statement)
+}
+
+// ExecuteStatementContext is ExecuteStatement with cancellation of SELECT
+// scans and COPY imports and exports, as for ExecuteContext.
+func (engine *Engine) ExecuteStatementContext(ctx context.Context, statement sql.Statement) (Result, error) {
+	// Warnings belong to the outermost statement, so statements run by its
+	// triggers or view refreshes add to them rather than clearing them
+	if engine.nesting == 0 && statement.Type() != sql.ShowWarningsStatementType {
+		engine.warnings = nil
+	}
+	engine.nesting++
+	defer func() { engine.nesting-- }()
+
+	if engine.transaction != nil && engine.transaction.readOnly && !readOnlyStatement(statement) {
+		return nil, errors.New("cannot write in a READ ONLY transaction")
+	}
+	if engine.transaction != nil && !readOnlyStatement(statement) && !transactionalStatement(statement) {
+		return nil, errors.New("only INSERT, UPDATE, DELETE and COPY can write inside a transaction; COMMIT or ROLLBACK first")
+	}
 
 	switch statement.Type() {
 	case sql.SelectStatementType:
-		return engine.executeSelectStatement(statement.(sql.SelectStatement))
+		return engine.executeSelect(ctx, engine.pinSnapshot(statement.(sql.SelectStatement)), engine.maxResultRows())
 	case sql.InsertStatementType:
-		return engine.executeInsertStatement(statement.(sql.InsertStatement))
+		insert := statement.(sql.InsertStatement)
+		result, err := engine.executeTriggeredWrite(insert.Database, insert.Table, "INSERT", insert.Returning, func(returning []string) (CommitResult, error) {
+			insert.Returning = returning
+			return engine.executeInsertStatement(insert)
+		})
+		return engine.afterWrite(insert.Database, insert.Table, result, err)
 	case sql.UpdateStatementType:
-		return engine.executeUpdateStatement(statement.(sql.UpdateStatement))
+		update := statement.(sql.UpdateStatement)
+		if update.DryRun {
+			return engine.executeUpdateStatement(update)
+		}
+		result, err := engine.executeTriggeredWrite(update.Database, update.Table, "UPDATE", update.Returning, func(returning []string) (CommitResult, error) {
+			update.Returning = returning
+			return engine.executeUpdateStatement(update)
+		})
+		return engine.afterWrite(update.Database, update.Table, result, err)
 	case sql.DeleteStatementType:
-		return engine.executeDeleteStatement(statement.(sql.DeleteStatement))
+		deleteStmt := statement.(sql.DeleteStatement)
+		if deleteStmt.DryRun {
+			return engine.executeDeleteStatement(deleteStmt)
+		}
+		result, err := engine.executeTriggeredWrite(deleteStmt.Database, deleteStmt.Table, "DELETE", deleteStmt.Returning, func(returning []string) (CommitResult, error) {
+			deleteStmt.Returning = returning
+			return engine.executeDeleteStatement(deleteStmt)
+		})
+		return engine.afterWrite(deleteStmt.Database, deleteStmt.Table, result, err)
 	case sql.CreateTableStatementType:
 		return engine.executeCreateTableStatement(statement.(sql.CreateTableStatement))
 	case sql.DropTableStatementType:
@@ -59,12 +152,22 @@ func (engine *Engine) Execute(query string) (Result, error) {
 		return engine.executeDropIndexStatement(statement.(sql.DropIndexStatement))
 	case sql.AlterTableStatementType:
 		return engine.executeAlterTableStatement(statement.(sql.AlterTableStatement))
+	case sql.AlterDatabaseStatementType:
+		return engine.executeAlterDatabaseStatement(statement.(sql.AlterDatabaseStatement))
 	case sql.BeginStatementType:
-		return engine.executeBeginStatement()
+		return engine.executeBeginStatement(statement.(sql.BeginStatement))
 	case sql.CommitStatementType:
-		return engine.executeCommitStatement()
+		result, err := engine.executeCommitStatement()
+		if err != nil {
+			return result, err
+		}
+		return result, engine.refreshWritten()
 	case sql.RollbackStatementType:
-		return engine.executeRollbackStatement()
+		return engine.executeRollbackStatement(statement.(sql.RollbackStatement))
+	case sql.SavepointStatementType:
+		return engine.executeSavepointStatement(statement.(sql.SavepointStatement))
+	case sql.ReleaseSavepointStatementType:
+		return engine.executeReleaseSavepointStatement(statement.(sql.ReleaseSavepointStatement))
 	case sql.DescribeStatementType:
 		return engine.executeDescribeStatement(statement.(sql.DescribeStatement))
 	case sql.ShowDatabasesStatementType:
@@ -81,8 +184,10 @@ func (engine *Engine) Execute(query string) (Result, error) {
 		return engine.executeMergeStatement(statement.(sql.MergeStatement))
 	case sql.ShowBranchesStatementType:
 		return engine.executeShowBranchesStatement(statement.(sql.ShowBranchesStatement))
+	case sql.ShowMergeBaseStatementType:
+		return engine.executeShowMergeBaseStatement(statement.(sql.ShowMergeBaseStatement))
 	case sql.ShowMergeConflictsStatementType:
-		return engine.executeShowMergeConflictsStatement()
+		return engine.executeShowMergeConflictsStatement(statement.(sql.ShowMergeConflictsStatement))
 	case sql.ResolveConflictStatementType:
 		return engine.executeResolveConflictStatement(statement.(sql.ResolveConflictStatement))
 	case sql.CommitMergeStatementType:
@@ -102,7 +207,7 @@ func (engine *Engine) Execute(query string) (Result, error) {
 	case sql.FetchStatementType:
 		return engine.executeFetchStatement(statement.(sql.FetchStatement))
 	case sql.CopyStatementType:
-		return engine.executeCopyStatement(statement.(sql.CopyStatement))
+		return engine.executeCopyStatement(ctx, statement.(sql.CopyStatement))
 	case sql.CreateShareStatementType:
 		return engine.executeCreateShareStatement(statement.(sql.CreateShareStatement))
 	case sql.SyncShareStatementType:
@@ -117,262 +222,663 @@ func (engine *Engine) Execute(query string) (Result, error) {
 		return engine.executeDropViewStatement(statement.(sql.DropViewStatement))
 	case sql.ShowViewsStatementType:
 		return engine.executeShowViewsStatement(statement.(sql.ShowViewsStatement))
+	case sql.ShowCreateTableStatementType:
+		return engine.executeShowCreateTableStatement(statement.(sql.ShowCreateTableStatement))
+	case sql.ShowCreateViewStatementType:
+		return engine.executeShowCreateViewStatement(statement.(sql.ShowCreateViewStatement))
+	case sql.CheckDatabaseStatementType:
+		return engine.executeCheckDatabaseStatement(statement.(sql.CheckDatabaseStatement))
 	case sql.RefreshViewStatementType:
 		return engine.executeRefreshViewStatement(statement.(sql.RefreshViewStatement))
+	case sql.RepairTableStatementType:
+		return engine.executeRepairTableStatement(statement.(sql.RepairTableStatement))
+	case sql.FlushStatementType:
+		return engine.executeFlushStatement()
+	case sql.ShowTableStatusStatementType:
+		return engine.executeShowTableStatusStatement(statement.(sql.ShowTableStatusStatement))
+	case sql.ShowStatsStatementType:
+		return engine.executeShowStatsStatement()
+	case sql.CreateTriggerStatementType:
+		return engine.executeCreateTriggerStatement(statement.(sql.CreateTriggerStatement))
+	case sql.DropTriggerStatementType:
+		return engine.executeDropTriggerStatement(statement.(sql.DropTriggerStatement))
+	case sql.ShowTriggersStatementType:
+		return engine.executeShowTriggersStatement(statement.(sql.ShowTriggersStatement))
+	case sql.ShowWarningsStatementType:
+		return engine.executeShowWarningsStatement()
+	case sql.SetVariableStatementType:
+		return engine.executeSetVariableStatement(statement.(sql.SetVariableStatement))
+	case sql.ShowVariablesStatementType:
+		return engine.executeShowVariablesStatement()
 	default:
 		return nil, fmt.Errorf("unsupported statement type: %v", statement.Type())
 	}
 }
 
-func (engine *Engine) executeSelectStatement(statement sql.SelectStatement) (QueryResult, error) {
+// ExecuteBatch splits a script into semicolon-separated statements and executes
+// them in order, stopping at the first statement that fails. It returns the
+// results of the statements that succeeded.
+func (engine *Engine) ExecuteBatch(script string) ([]Result, error) {
+	return engine.executeBatch(script, false)
+}
+
+// ExecuteBatchContinue executes every statement in a script, even after a
+// failure. Failed statements leave a nil entry in the results and their
+// errors are joined into the returned error.
+func (engine *Engine) ExecuteBatchContinue(script string) ([]Result, error) {
+	return engine.executeBatch(script, true)
+}
+
+func (engine *Engine) executeBatch(script string, continueOnError bool) ([]Result, error) {
+	var results []Result
+	var errs []error
+	for i, statement := range sql.SplitStatements(script) {
+		result, err := engine.Execute(statement)
+		if err != nil {
+			err = fmt.Errorf("statement %d: %w", i+1, err)
+			if !continueOnError {
+				return results, err
+			}
+			errs = append(errs, err)
+			result = nil
+		}
+		results = append(results, result)
+	}
+	return results, errors.Join(errs...)
+}
+
+// readRow decodes a stored row. A row that is not valid JSON is an error under
+// StrictReads; otherwise it is counted in corrupt and a nil row is returned.
+func (engine *Engine) readRow(database, table, key string, rawData []byte, corrupt *int) (map[string]string, error) {
+	var row map[string]string
+	if err := json.Unmarshal(rawData, &row); err != nil {
+		if engine.StrictReads {
+			return nil, fmt.Errorf("corrupt row %s in %s.%s: %w", key, database, table, err)
+		}
+		engine.warn("skipped corrupt row %s in %s.%s", key, database, table)
+		*corrupt++
+		return nil, nil
+	}
+	return row, nil
+}
+
+// queryShares holds the shares a query reads, each opened once: opening a
+// share twice in one query deadlocks if a SYNC SHARE is waiting in between.
+type queryShares map[string]*ps.Persistence
+
+func (shares queryShares) open(persistence *ps.Persistence, name string) (*ps.Persistence, error) {
+	if share, ok := shares[name]; ok {
+		return share, nil
+	}
+	share, err := persistence.OpenSharePersistence(name)
+	if err != nil {
+		return nil, err
+	}
+	shares[name] = share
+	return share, nil
+}
+
+// close releases the shares so they can be synced again
+func (shares queryShares) close() {
+	for _, share := range shares {
+		share.Close()
+	}
+}
+
+// executeSelectStatement runs statement with no cap on its rows, as views,
+// subqueries and other statements reading a query need.
+func (engine *Engine) executeSelectStatement(ctx context.Context, statement sql.SelectStatement) (QueryResult, error) {
+	return engine.executeSelect(ctx, statement, 0)
+}
+
+// executeSelect runs statement, failing with ErrResultTooLarge when it returns
+// more than maxRows rows, or keeping the first maxRows under TruncateResults;
+// 0 means no limit.
+func (engine *Engine) executeSelect(ctx context.Context, statement sql.SelectStatement, maxRows int) (QueryResult, error) {
+	if maxRows > 0 && engine.TruncateResults {
+		return engine.selectTruncated(ctx, statement, maxRows)
+	}
+	result, err := engine.selectRows(ctx, statement, maxRows)
+	if err == nil && maxRows > 0 && len(result.Data) > maxRows {
+		return QueryResult{}, fmt.Errorf("%w: %d rows exceeds the limit of %d", ErrResultTooLarge, len(result.Data), maxRows)
+	}
+	return result, err
+}
+
+// selectRows builds the result of statement. With maxRows above 0, a read
+// whose matching rows all become result rows stops once more than maxRows
+// of them would be returned.
+func (engine *Engine) selectRows(ctx context.Context, statement sql.SelectStatement, maxRows int) (QueryResult, error) {
 	startTime := time.Now()
 	rowsScanned := 0
+	corruptRows := 0
+
+	if statement.CountAll && len(statement.Aggregates) == 0 {
+		statement.Columns = nil
+		statement.Aggregates = []sql.AggregateExpr{{Function: "COUNT", Column: "*"}}
+	}
+	if err := validateAggregation(statement); err != nil {
+		return QueryResult{}, err
+	}
+
+	// Rows of a single table hold plain column names, so WHERE reads a
+	// column qualified by the table or its alias by its name
+	if len(statement.Joins) == 0 {
+		statement.Where = unqualifyWhere(statement.Where, tableQualifiers(statement.Database, statement.Table, statement.TableAlias))
+	}
+
+	shares := make(queryShares)
+	defer shares.close()
 
 	// Determine which persistence to use - share or local
 	persistence := engine.Persistence
 	if statement.Share != "" {
-		sharePersistence, err := engine.Persistence.OpenSharePersistence(statement.Share)
+		sharePersistence, err := shares.open(engine.Persistence, statement.Share)
 		if err != nil {
 			return QueryResult{}, fmt.Errorf("failed to access share '%s': %w", statement.Share, err)
 		}
 		persistence = sharePersistence
 	}
 
-	// Handle time-travel queries (AS OF 'transaction')
-	if statement.AsOf != "" {
-		return engine.executeTimeTravelSelect(statement, persistence, startTime)
-	}
+	var results []map[string]string
+	var sourceColumns []string
+	var sourceTable *core.Table // nil when reading from a view
 
 	// Check if this is a view instead of a table
 	view, err := persistence.GetView(statement.Database, statement.Table)
 	if err == nil {
-		// This is a view - redirect the query
-		if view.Materialized {
-			// For materialized views, read cached data
-			return engine.executeMaterializedViewQuery(view, statement, startTime)
+		// This is a view - its output rows feed the rest of the select pipeline,
+		// so the outer query's WHERE, ORDER BY, aggregates, etc. apply on top
+		if statement.AsOf != "" {
+			sourceColumns, results, err = engine.readViewRowsAsOf(ctx, view, statement.AsOf)
+		} else {
+			// A refresh commits, so inside a transaction the view is read as it is
+			if persistence == engine.Persistence && engine.transaction == nil && refreshDue(view, time.Now()) {
+				if err := engine.refreshView(view); err != nil {
+					return QueryResult{}, fmt.Errorf("failed to refresh view %s.%s: %w", view.Database, view.Name, err)
+				}
+			}
+			sourceColumns, results, err = engine.readViewRows(ctx, view)
 		}
-		// For regular views, execute the underlying query
-		// Note: This creates a new query based on the view definition
-		return engine.executeViewQuery(view, statement, startTime)
-	}
+		if err != nil {
+			return QueryResult{}, err
+		}
+		rowsScanned += len(results)
+	} else if statement.AsOf != "" {
+		// Time-travel query: this table and every joined table are read as
+		// they were at the transaction
+		table, rows, err := engine.readTableAsOf(ctx, persistence, statement.Database, statement.Table, statement.AsOf, &rowsScanned, &corruptRows)
+		if err != nil {
+			return QueryResult{}, err
+		}
+		sourceTable = table
+		for _, column := range table.Columns {
+			sourceColumns = append(sourceColumns, column.Name)
+		}
+		results = rows
+		if len(statement.OrderBy) == 0 {
+			sortResults(results, primaryKeyOrder(*table))
+		}
+	} else {
+		tableOp, err := op.GetTable(statement.Database, statement.Table, persistence)
+		if err != nil {
+			return QueryResult{}, err
+		}
+		sourceTable = &tableOp.Table
 
-	tableOp, err := op.GetTable(statement.Database, statement.Table, persistence)
-	if err != nil {
-		return QueryResult{}, err
+		for _, column := range tableOp.Table.Columns {
+			sourceColumns = append(sourceColumns, column.Name)
+		}
+
+		// SELECT COUNT(*) needs no rows, only how many there are
+		if countsOnly(statement) {
+			return engine.executeCountSelect(ctx, statement, tableOp, persistence, startTime)
+		}
+
+		// Each row matching WHERE is a result row unless rows are joined,
+		// grouped or collapsed, so such reads can stop once over the cap
+		capped := maxRows > 0 && len(statement.Joins) == 0 && len(statement.GroupBy) == 0 &&
+			len(statement.Aggregates) == 0 && len(statement.HavingAggregates) == 0 &&
+			!statement.Distinct && len(statement.DistinctOn) == 0 &&
+			(!limited(statement) || statement.Limit > maxRows)
+		matched := 0
+		err = engine.readTableRows(ctx, statement, tableOp, persistence, &rowsScanned, &corruptRows, func(row map[string]string) error {
+			normalizeRow(row, tableOp.Table)
+			decodeBlobs(row, tableOp.Table)
+			results = append(results, row)
+			if capped && matchesWhereClause(row, statement.Where, engine.Collation) {
+				if matched++; matched-statement.Offset > maxRows {
+					return fmt.Errorf("query stopped after scanning %d rows: %w: more than %d rows", rowsScanned, ErrResultTooLarge, maxRows)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return QueryResult{}, err
+		}
+
+		// Without ORDER BY, rows come back in primary key order rather than
+		// storage order, which depends on index lookups and the table layout
+		if len(statement.OrderBy) == 0 {
+			sortResults(results, primaryKeyOrder(tableOp.Table))
+		}
 	}
 
-	// Determine columns to select
+	// Determine columns to select. With joins, SELECT * reads each table's
+	// columns by their qualified keys, so same-named columns keep their values.
 	columns := []string{}
+	var keys []string
 	if len(statement.Columns) == 0 {
-		for _, column := range tableOp.Table.Columns {
-			columns = append(columns, column.Name)
-		}
+		columns = append(columns, sourceColumns...)
+		keys = qualifiedKeys(statement.Database, statement.Table, sourceColumns, len(statement.Joins) > 0)
 	} else {
 		columns = append(columns, statement.Columns...)
 	}
 
-	// Try to use an index for WHERE clause optimization
-	var results []map[string]string
-	indexUsed := false
-
-	if len(statement.Where.Conditions) > 0 && len(statement.Joins) == 0 {
-		// Load indexes for this table
-		indexManager := ps.NewIndexManager(persistence, engine.Identity)
-		indexManager.LoadIndexes(statement.Database, statement.Table, tableOp.Table.Columns)
-
-		// Check if any WHERE condition can use an index (simple equality for now)
-		for _, cond := range statement.Where.Conditions {
-			if cond.Operator == sql.EqualsOperator {
-				if idx, found := indexManager.GetIndex(statement.Database, statement.Table, cond.Left); found {
-					// Use index lookup!
-					primaryKeys := idx.Lookup(cond.Right)
-					for _, pk := range primaryKeys {
-						rowsScanned++
-						rawData, exists := tableOp.Get(pk)
-						if !exists {
-							continue
-						}
-						var jsonData map[string]string
-						if err := json.Unmarshal(rawData, &jsonData); err != nil {
-							continue
-						}
-						results = append(results, jsonData)
-					}
-					indexUsed = true
-					break // Only use first matching index
-				}
-			}
-		}
+	// Joined rows carry qualifier.column keys for each table's columns
+	if len(statement.Joins) > 0 {
+		qualifyRows(results, sourceColumns, tableQualifiers(statement.Database, statement.Table, statement.TableAlias))
 	}
 
-	// Fall back to full scan if no index was used
-	if !indexUsed {
-		for _, rawData := range tableOp.Scan() {
-			rowsScanned++
-
-			var jsonData map[string]string
-			err := json.Unmarshal(rawData, &jsonData)
-			if err != nil {
-				return QueryResult{}, err
-			}
-
-			results = append(results, jsonData)
-		}
-	}
+	// Columns a reference may name: the source's plus every joined table's
+	availableColumns := append([]string{}, sourceColumns...)
+	qualifiedColumns := make(map[string][]string)
+	addTableQualifiers(qualifiedColumns, statement.Database, statement.Table, statement.TableAlias, sourceColumns)
 
 	// Execute JOINs
 	for _, join := range statement.Joins {
-		var joinTableOp *op.TableOp
-		var err error
-
-		// Check if this is a share table (3-level naming)
-		if join.Share != "" {
-			// Open share persistence
-			sharePersistence, shareErr := engine.Persistence.OpenSharePersistence(join.Share)
-			if shareErr != nil {
-				return QueryResult{}, fmt.Errorf("failed to open share '%s' for join: %w", join.Share, shareErr)
+		var joinTable core.Table
+		var joinRows []map[string]string
+		if statement.AsOf != "" {
+			// Joined tables are read at the same transaction. Its ID names a
+			// commit of one repository, so they must come from the same one.
+			if join.Share != statement.Share {
+				return QueryResult{}, fmt.Errorf("AS OF cannot join %s.%s from another repository", join.Database, join.Table)
 			}
-			joinTableOp, err = op.GetTable(join.Database, join.Table, sharePersistence)
+			table, rows, err := engine.readTableAsOf(ctx, persistence, join.Database, join.Table, statement.AsOf, &rowsScanned, &corruptRows)
+			if err != nil {
+				return QueryResult{}, fmt.Errorf("join: %w", err)
+			}
+			joinTable, joinRows = *table, rows
 		} else {
-			joinTableOp, err = op.GetTable(join.Database, join.Table, engine.Persistence)
-		}
+			var joinTableOp *op.TableOp
+			var err error
 
-		if err != nil {
+			// Check if this is a share table (3-level naming)
 			if join.Share != "" {
-				return QueryResult{}, fmt.Errorf("join table not found: %s.%s.%s", join.Share, join.Database, join.Table)
+				// Open share persistence
+				sharePersistence, shareErr := shares.open(engine.Persistence, join.Share)
+				if shareErr != nil {
+					return QueryResult{}, fmt.Errorf("failed to open share '%s' for join: %w", join.Share, shareErr)
+				}
+				joinTableOp, err = op.GetTable(join.Database, join.Table, sharePersistence)
+			} else {
+				joinTableOp, err = op.GetTable(join.Database, join.Table, engine.Persistence)
 			}
-			return QueryResult{}, fmt.Errorf("join table not found: %s.%s", join.Database, join.Table)
-		}
 
-		// Scan join table
-		var joinRows []map[string]string
-		for _, rawData := range joinTableOp.Scan() {
-			rowsScanned++
-			var jsonData map[string]string
-			if err := json.Unmarshal(rawData, &jsonData); err != nil {
-				continue
+			if errors.Is(err, ps.ErrTableNotFound) {
+				if join.Share != "" {
+					return QueryResult{}, fmt.Errorf("join %w: %s.%s.%s", ps.ErrTableNotFound, join.Share, join.Database, join.Table)
+				}
+				return QueryResult{}, fmt.Errorf("join %w: %s.%s", ps.ErrTableNotFound, join.Database, join.Table)
+			} else if err != nil {
+				return QueryResult{}, err
+			}
+			joinTable = joinTableOp.Table
+
+			// Scan join table
+			for key, rawData := range joinTableOp.Scan() {
+				if err := ctx.Err(); err != nil {
+					return QueryResult{}, fmt.Errorf("query aborted after scanning %d rows: %w", rowsScanned, err)
+				}
+				rowsScanned++
+				jsonData, err := engine.readRow(join.Database, join.Table, key, rawData, &corruptRows)
+				if err != nil {
+					return QueryResult{}, err
+				}
+				if jsonData == nil {
+					continue
+				}
+				normalizeRow(jsonData, joinTable)
+				decodeBlobs(jsonData, joinTable)
+				joinRows = append(joinRows, jsonData)
 			}
-			joinRows = append(joinRows, jsonData)
 		}
+		// Rows matching the same left row keep primary key order, not storage order
+		sortResults(joinRows, primaryKeyOrder(joinTable))
+
+		var joinColumns []string
+		for _, col := range joinTable.Columns {
+			joinColumns = append(joinColumns, col.Name)
+		}
+		qualifyRows(joinRows, joinColumns, tableQualifiers(join.Database, join.Table, join.TableAlias))
 
 		// Perform the join
 		results = executeJoin(results, joinRows, join)
 
 		// Add join table columns to output columns if selecting *
 		if len(statement.Columns) == 0 {
-			for _, col := range joinTableOp.Table.Columns {
-				columns = append(columns, col.Name)
+			columns = append(columns, joinColumns...)
+			keys = append(keys, qualifiedKeys(join.Database, join.Table, joinColumns, true)...)
+		}
+		availableColumns = append(availableColumns, joinColumns...)
+		addTableQualifiers(qualifiedColumns, join.Database, join.Table, join.TableAlias, joinColumns)
+	}
+
+	if err := validateColumnReferences(statement, availableColumns, qualifiedColumns); err != nil {
+		return QueryResult{}, err
+	}
+
+	// Expand qualified wildcards such as u.* to that table's columns
+	if len(statement.Columns) > 0 {
+		keys, err = expandSelectColumns(&statement, qualifiedColumns)
+		if err != nil {
+			return QueryResult{}, err
+		}
+		columns = append([]string{}, statement.Columns...)
+	}
+
+	// Literal columns carry the same constant in every row
+	if len(statement.Literals) > 0 {
+		for _, row := range results {
+			for name, value := range statement.Literals {
+				row[name] = value
 			}
 		}
+		for name := range statement.Literals {
+			availableColumns = append(availableColumns, name)
+		}
 	}
 
 	// Apply WHERE clause filtering (after joins)
 	if len(statement.Where.Conditions) > 0 {
 		var filtered []map[string]string
 		for _, row := range results {
-			if matchesWhereClause(row, statement.Where) {
+			if matchesWhereClause(row, statement.Where, engine.Collation) {
 				filtered = append(filtered, row)
 			}
 		}
 		results = filtered
 	}
 
-	// Apply DISTINCT if requested
-	if statement.Distinct {
-		results = applyDistinct(results, columns)
+	// The transaction the rows were read at
+	readTransaction := engine.Persistence.LatestTransaction()
+	if statement.AsOf != "" {
+		readTransaction = ps.Transaction{Id: statement.AsOf}
+	}
+
+	// A HAVING aggregate groups the rows even when none is selected
+	aggregated := len(statement.Aggregates) > 0 || len(statement.HavingAggregates) > 0
+
+	// Function results feed GROUP BY and aggregate arguments like columns
+	if aggregated {
+		if err := engine.addComputedColumns(results, statement); err != nil {
+			return QueryResult{}, err
+		}
+	}
+
+	// Apply DISTINCT if requested. Aggregate output is one row per group,
+	// so it is already distinct and the input rows must not be collapsed.
+	if statement.Distinct && !aggregated {
+		results = applyDistinct(results, keys)
 	}
 
 	// Apply ORDER BY if present
 	if len(statement.OrderBy) > 0 {
-		sortResults(results, statement.OrderBy)
+		orderBy := statement.OrderBy
+		if len(statement.Joins) == 0 {
+			orderBy = unqualifyOrderBy(orderBy, tableQualifiers(statement.Database, statement.Table, statement.TableAlias))
+		}
+		if sourceTable != nil {
+			orderBy = withPrimaryKeyTieBreak(orderBy, *sourceTable)
+		}
+		sortResults(results, orderBy)
 	}
 
-	// Handle COUNT(*) - return count before LIMIT/OFFSET
-	if statement.CountAll {
-		countResult := [][]string{{strconv.Itoa(len(results))}}
-		return QueryResult{
-			Transaction:     engine.Persistence.LatestTransaction(),
-			Columns:         []string{"COUNT(*)"},
-			Data:            countResult,
-			RecordsRead:     len(results),
-			ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
-			ExecutionOps:    rowsScanned,
-		}, nil
+	// DISTINCT ON keeps the first row of each key in the order just applied
+	if len(statement.DistinctOn) > 0 && !aggregated {
+		results = applyDistinct(results, statement.DistinctOn)
 	}
 
-	// Handle aggregate functions (SUM, AVG, MIN, MAX)
-	if len(statement.Aggregates) > 0 {
-		return executeAggregates(results, statement, engine.Persistence.LatestTransaction(), startTime, rowsScanned)
+	// Handle aggregate functions (COUNT, SUM, AVG, MIN, MAX)
+	if aggregated {
+		engine.warnNonNumeric(results, statement.Aggregates)
+		result, err := executeAggregates(results, statement, engine.Collation, readTransaction, startTime, rowsScanned)
+		result.CorruptRows = corruptRows
+		return result, err
 	}
 
 	// Handle string functions
 	if len(statement.Functions) > 0 {
-		return executeStringFunctions(results, statement, engine.Persistence.LatestTransaction(), startTime, rowsScanned)
-	}
-
-	// Apply OFFSET
-	if statement.Offset > 0 {
-		if statement.Offset >= len(results) {
-			results = []map[string]string{}
-		} else {
-			results = results[statement.Offset:]
-		}
+		result, err := engine.executeStringFunctions(results, statement, availableColumns, readTransaction, startTime, rowsScanned)
+		result.CorruptRows = corruptRows
+		return result, err
 	}
 
-	// Apply LIMIT
-	if statement.Limit > 0 && len(results) > statement.Limit {
-		results = results[:statement.Limit]
-	}
+	results = pageRows(results, statement)
 
 	// Convert results to column-based output
 	outputData := make([][]string, len(results))
 	for i, row := range results {
 		outputData[i] = make([]string, len(columns))
-		for j, col := range columns {
-			outputData[i][j] = row[col]
+		for j, key := range keys {
+			outputData[i][j] = getColumnValue(row, key)
 		}
 	}
 
 	return QueryResult{
-		Transaction:     engine.Persistence.LatestTransaction(),
+		Transaction:     readTransaction,
 		Columns:         columns,
 		Data:            outputData,
 		RecordsRead:     len(outputData),
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		CorruptRows:     corruptRows,
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    rowsScanned,
+	}, nil
+}
+
+// limited reports whether statement has a LIMIT, which may be LIMIT 0.
+func limited(statement sql.SelectStatement) bool {
+	return statement.Limit > 0 || statement.LimitSet
+}
+
+// pageRows applies the OFFSET and LIMIT of statement to rows.
+func pageRows[T any](rows []T, statement sql.SelectStatement) []T {
+	rows = rows[min(statement.Offset, len(rows)):]
+	if limited(statement) && len(rows) > statement.Limit {
+		rows = rows[:statement.Limit]
+	}
+	return rows
+}
+
+// readTableRows reads the source rows of statement by primary key, index
+// lookup or full scan, passing each decoded row to visit as stored. An error
+// from visit stops the read and is returned.
+func (engine *Engine) readTableRows(ctx context.Context, statement sql.SelectStatement, tableOp *op.TableOp, persistence *ps.Persistence, rowsScanned, corruptRows *int, visit func(row map[string]string) error) error {
+	path := engine.chooseAccessPath(statement, tableOp, persistence)
+	if path.method == AccessScan {
+		for key, rawData := range tableOp.Scan() {
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("query aborted after scanning %d rows: %w", *rowsScanned, err)
+			}
+			*rowsScanned++
+
+			jsonData, err := engine.readRow(statement.Database, statement.Table, key, rawData, corruptRows)
+			if err != nil {
+				return err
+			}
+			if jsonData != nil {
+				if err := visit(jsonData); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	lookupKeys := []string{path.value}
+	if path.method == AccessIndex {
+		lookupKeys = path.index.Lookup(path.value)
+	}
+	for _, key := range lookupKeys {
+		*rowsScanned++
+		rawData, exists := tableOp.Get(key)
+		if !exists {
+			continue
+		}
+		jsonData, err := engine.readRow(statement.Database, statement.Table, key, rawData, corruptRows)
+		if err != nil {
+			return err
+		}
+		if jsonData != nil {
+			if err := visit(jsonData); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// countsOnly reports whether statement selects nothing but COUNT(*) of its
+// source table's rows, so they can be counted instead of collected. OFFSET
+// and LIMIT can drop the count's row, so they leave it to executeAggregates.
+func countsOnly(statement sql.SelectStatement) bool {
+	if len(statement.Aggregates) == 0 || len(statement.Columns) > 0 || len(statement.Functions) > 0 ||
+		len(statement.Joins) > 0 || len(statement.GroupBy) > 0 || len(statement.Computed) > 0 ||
+		len(statement.Having.Conditions) > 0 || len(statement.HavingAggregates) > 0 ||
+		statement.Offset > 0 || limited(statement) {
+		return false
+	}
+	for _, agg := range statement.Aggregates {
+		if agg.Function != "COUNT" || agg.Column != "*" || agg.Distinct || len(agg.Filter.Conditions) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// executeCountSelect answers a countsOnly statement without retaining rows.
+// Without WHERE, the table's tree entries are counted and no row is read, so
+// corrupt rows count too; otherwise matching rows are counted as they are
+// read.
+func (engine *Engine) executeCountSelect(ctx context.Context, statement sql.SelectStatement, tableOp *op.TableOp, persistence *ps.Persistence, startTime time.Time) (QueryResult, error) {
+	var sourceColumns []string
+	for _, column := range tableOp.Table.Columns {
+		sourceColumns = append(sourceColumns, column.Name)
+	}
+	qualifiedColumns := make(map[string][]string)
+	addTableQualifiers(qualifiedColumns, statement.Database, statement.Table, statement.TableAlias, sourceColumns)
+	if err := validateColumnReferences(statement, sourceColumns, qualifiedColumns); err != nil {
+		return QueryResult{}, err
+	}
+
+	count, rowsScanned, corruptRows := 0, 0, 0
+	if len(statement.Where.Conditions) == 0 {
+		count = tableOp.Count()
+	} else {
+		err := engine.readTableRows(ctx, statement, tableOp, persistence, &rowsScanned, &corruptRows, func(row map[string]string) error {
+			normalizeRow(row, tableOp.Table)
+			decodeBlobs(row, tableOp.Table)
+			if matchesWhereClause(row, statement.Where, engine.Collation) {
+				count++
+			}
+			return nil
+		})
+		if err != nil {
+			return QueryResult{}, err
+		}
+	}
+
+	var columns, row []string
+	for _, agg := range statement.Aggregates {
+		name := agg.Name()
+		if agg.Alias != "" {
+			name = agg.Alias
+		}
+		columns = append(columns, name)
+		row = append(row, strconv.Itoa(count))
+	}
+
+	return QueryResult{
+		Transaction:     engine.Persistence.LatestTransaction(),
+		Columns:         columns,
+		Data:            [][]string{row},
+		RecordsRead:     count,
+		CorruptRows:     corruptRows,
+		ExecutionTimeMs: elapsedMs(startTime),
 		ExecutionOps:    rowsScanned,
 	}, nil
 }
 
+// aggregateGroup holds the GROUP BY values of a group and its rows.
+type aggregateGroup struct {
+	values  []string
+	present []bool // false where the GROUP BY value is NULL
+	rows    []map[string]string
+}
+
+// valuesKey encodes a row's values of columns as a map key. Each value is
+// length-prefixed, so values containing any separator cannot collide, and
+// an absent (NULL) value is marked so it stays apart from the empty string.
+func valuesKey(row map[string]string, columns []string) string {
+	var key strings.Builder
+	for _, column := range columns {
+		value, ok := row[column]
+		if !ok {
+			key.WriteByte('-')
+			continue
+		}
+		key.WriteString(strconv.Itoa(len(value)))
+		key.WriteByte(':')
+		key.WriteString(value)
+	}
+	return key.String()
+}
+
 // executeAggregates handles SUM, AVG, MIN, MAX aggregate functions
-func executeAggregates(results []map[string]string, statement sql.SelectStatement, txn ps.Transaction, startTime time.Time, opCount int) (QueryResult, error) {
-	// Group results if GROUP BY is present
-	groups := make(map[string][]map[string]string)
+func executeAggregates(results []map[string]string, statement sql.SelectStatement, collation Collation, txn ps.Transaction, startTime time.Time, opCount int) (QueryResult, error) {
+	// Group results if GROUP BY is present, keeping groups in first-seen order
+	var groups []*aggregateGroup
 
 	if len(statement.GroupBy) > 0 {
+		index := make(map[string]*aggregateGroup)
 		for _, row := range results {
-			// Build group key
-			keyParts := make([]string, len(statement.GroupBy))
-			for i, col := range statement.GroupBy {
-				keyParts[i] = row[col]
+			key := valuesKey(row, statement.GroupBy)
+			group, ok := index[key]
+			if !ok {
+				group = &aggregateGroup{values: make([]string, len(statement.GroupBy)), present: make([]bool, len(statement.GroupBy))}
+				for i, col := range statement.GroupBy {
+					group.values[i], group.present[i] = row[col]
+				}
+				index[key] = group
+				groups = append(groups, group)
 			}
-			key := strings.Join(keyParts, "|")
-			groups[key] = append(groups[key], row)
+			group.rows = append(group.rows, row)
 		}
 	} else {
 		// Single group for all rows
-		groups[""] = results
+		groups = []*aggregateGroup{{rows: results}}
 	}
 
 	// Calculate aggregates for each group
 	var outputColumns []string
 	var outputData [][]string
 
-	// Add GROUP BY columns first
-	outputColumns = append(outputColumns, statement.GroupBy...)
+	// Add GROUP BY columns first, using the alias of a selected function
+	// grouped by its call (SELECT YEAR(d) AS y ... GROUP BY YEAR(d))
+	for _, col := range statement.GroupBy {
+		for _, fn := range statement.Functions {
+			if fn.Alias != "" && fn.Name() == col {
+				col = fn.Alias
+				break
+			}
+		}
+		outputColumns = append(outputColumns, col)
+	}
 
 	// Add aggregate columns
 	for _, agg := range statement.Aggregates {
-		colName := agg.Function + "(" + agg.Column + ")"
+		colName := agg.Name()
 		if agg.Alias != "" {
 			colName = agg.Alias
 		}
@@ -380,19 +886,39 @@ func executeAggregates(results []map[string]string, statement sql.SelectStatemen
 	}
 
 	// Process each group
-	for groupKey, groupRows := range groups {
-		row := make([]string, 0)
+	for _, group := range groups {
+		groupRows := group.rows
 
 		// Add GROUP BY values
-		if len(statement.GroupBy) > 0 {
-			keyParts := strings.Split(groupKey, "|")
-			row = append(row, keyParts...)
-		}
+		row := append([]string{}, group.values...)
 
 		// Calculate each aggregate
 		for _, agg := range statement.Aggregates {
-			value := calculateAggregate(groupRows, agg.Function, agg.Column)
-			row = append(row, value)
+			row = append(row, calculateGroupAggregate(groupRows, agg, collation))
+		}
+
+		// HAVING compares the group's outputs: GROUP BY columns, aggregates
+		// by alias or call, and any aggregate only HAVING names
+		if len(statement.Having.Conditions) > 0 {
+			values := make(map[string]string)
+			for i, col := range statement.GroupBy {
+				if group.present[i] {
+					values[col] = group.values[i]
+					values[outputColumns[i]] = group.values[i]
+				}
+			}
+			for i, agg := range statement.Aggregates {
+				values[agg.Name()] = row[len(statement.GroupBy)+i]
+				values[outputColumns[len(statement.GroupBy)+i]] = row[len(statement.GroupBy)+i]
+			}
+			for _, agg := range statement.HavingAggregates {
+				if _, ok := values[agg.Name()]; !ok {
+					values[agg.Name()] = calculateGroupAggregate(groupRows, agg, collation)
+				}
+			}
+			if !matchesWhereClause(values, statement.Having, collation) {
+				continue
+			}
 		}
 
 		outputData = append(outputData, row)
@@ -401,19 +927,59 @@ func executeAggregates(results []map[string]string, statement sql.SelectStatemen
 	return QueryResult{
 		Transaction:     txn,
 		Columns:         outputColumns,
-		Data:            outputData,
+		Data:            pageRows(outputData, statement),
 		RecordsRead:     len(results),
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
 		ExecutionOps:    opCount,
 	}, nil
 }
 
-// calculateAggregate calculates a single aggregate function over a set of rows
-func calculateAggregate(rows []map[string]string, function, column string) string {
-	if len(rows) == 0 {
-		return "0"
+// calculateGroupAggregate calculates an aggregate over a group's rows,
+// restricted to its FILTER rows if any. A DISTINCT aggregate sees each
+// non-NULL value of its column once.
+func calculateGroupAggregate(rows []map[string]string, agg sql.AggregateExpr, collation Collation) string {
+	if len(agg.Filter.Conditions) > 0 {
+		var filtered []map[string]string
+		for _, row := range rows {
+			if matchesWhereClause(row, agg.Filter, collation) {
+				filtered = append(filtered, row)
+			}
+		}
+		rows = filtered
 	}
-
+	if agg.Distinct {
+		seen := make(map[string]bool)
+		var distinct []map[string]string
+		for _, row := range rows {
+			value, ok := row[agg.Column]
+			if !ok || seen[value] {
+				continue
+			}
+			seen[value] = true
+			distinct = append(distinct, row)
+		}
+		rows = distinct
+	}
+	return calculateAggregate(rows, agg.Function, agg.Column)
+}
+
+// calculateAggregate calculates a single aggregate function over a set of
+// rows. Rows where the column is NULL are skipped, so COUNT(col) counts the
+// rows with a value while COUNT(*) counts every row.
+func calculateAggregate(rows []map[string]string, function, column string) string {
+	if column != "*" {
+		var present []map[string]string
+		for _, row := range rows {
+			if _, ok := row[column]; ok {
+				present = append(present, row)
+			}
+		}
+		rows = present
+	}
+	if len(rows) == 0 {
+		return "0"
+	}
+
 	switch function {
 	case "COUNT":
 		return strconv.Itoa(len(rows))
@@ -488,54 +1054,83 @@ func calculateAggregate(rows []map[string]string, function, column string) strin
 	}
 }
 
-// executeStringFunctions handles string functions like UPPER, LOWER, CONCAT, SUBSTRING, TRIM, LENGTH, REPLACE
-func executeStringFunctions(results []map[string]string, statement sql.SelectStatement, txn ps.Transaction, startTime time.Time, opCount int) (QueryResult, error) {
-	// Apply OFFSET
-	if statement.Offset > 0 {
-		if statement.Offset >= len(results) {
-			results = []map[string]string{}
-		} else {
-			results = results[statement.Offset:]
+// executeStringFunctions handles string functions like UPPER, LOWER, CONCAT, SUBSTRING, TRIM, LENGTH, REPLACE.
+// Functions are evaluated in select-list order, and an argument may name the
+// alias of an earlier function unless a column of the same name exists.
+func (engine *Engine) executeStringFunctions(results []map[string]string, statement sql.SelectStatement, columns []string, txn ps.Transaction, startTime time.Time, opCount int) (QueryResult, error) {
+	if err := engine.checkFunctions(statement.Functions); err != nil {
+		return QueryResult{}, err
+	}
+	results = pageRows(results, statement)
+
+	// Output columns follow the select list: each function goes after the
+	// columns written before it. Statements built without FunctionsAfter
+	// list the functions first.
+	type selectItem struct {
+		function bool
+		index    int
+	}
+	var items []selectItem
+	next := 0
+	for i := range statement.Functions {
+		if i < len(statement.FunctionsAfter) {
+			for ; next < min(statement.FunctionsAfter[i], len(statement.Columns)); next++ {
+				items = append(items, selectItem{index: next})
+			}
 		}
+		items = append(items, selectItem{function: true, index: i})
 	}
-
-	// Apply LIMIT
-	if statement.Limit > 0 && len(results) > statement.Limit {
-		results = results[:statement.Limit]
+	for ; next < len(statement.Columns); next++ {
+		items = append(items, selectItem{index: next})
 	}
 
-	// Build output columns (function results + additional columns)
-	var outputColumns []string
-	for _, fn := range statement.Functions {
-		if fn.Alias != "" {
-			outputColumns = append(outputColumns, fn.Alias)
+	outputColumns := make([]string, len(items))
+	for i, item := range items {
+		if !item.function {
+			outputColumns[i] = statement.Columns[item.index]
+		} else if fn := statement.Functions[item.index]; fn.Alias != "" {
+			outputColumns[i] = fn.Alias
 		} else {
-			outputColumns = append(outputColumns, fn.Function+"("+strings.Join(fn.Args, ", ")+")")
+			outputColumns[i] = fn.Name()
 		}
 	}
-	// Add any regular columns
-	for _, col := range statement.Columns {
-		outputColumns = append(outputColumns, col)
+
+	// Aliases later functions can read
+	aliases := make(map[string]bool)
+	for _, fn := range statement.Functions {
+		if fn.Alias != "" && !slices.Contains(columns, fn.Alias) {
+			aliases[fn.Alias] = true
+		}
 	}
 
 	// Evaluate functions for each row
 	outputData := make([][]string, len(results))
+	functionValues := make([]string, len(statement.Functions))
 	for i, row := range results {
-		rowData := make([]string, len(outputColumns))
-		colIdx := 0
-
 		// Evaluate each function
-		for _, fn := range statement.Functions {
-			rowData[colIdx] = evalStringFunction(fn, row)
-			colIdx++
+		values := row
+		if len(aliases) > 0 {
+			values = maps.Clone(row)
 		}
-
-		// Add regular column values
-		for _, col := range statement.Columns {
-			rowData[colIdx] = row[col]
-			colIdx++
+		for j, fn := range statement.Functions {
+			value, err := engine.evalFunction(fn, values)
+			if err != nil {
+				return QueryResult{}, err
+			}
+			if aliases[fn.Alias] {
+				values[fn.Alias] = value
+			}
+			functionValues[j] = value
 		}
 
+		rowData := make([]string, len(items))
+		for j, item := range items {
+			if item.function {
+				rowData[j] = functionValues[item.index]
+			} else {
+				rowData[j] = getColumnValue(row, statement.Columns[item.index])
+			}
+		}
 		outputData[i] = rowData
 	}
 
@@ -544,22 +1139,14 @@ func executeStringFunctions(results []map[string]string, statement sql.SelectSta
 		Columns:         outputColumns,
 		Data:            outputData,
 		RecordsRead:     len(outputData),
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
 		ExecutionOps:    opCount,
 	}, nil
 }
 
 // evalStringFunction evaluates a string function on a row
 func evalStringFunction(fn sql.FunctionExpr, row map[string]string) string {
-	// Resolve arguments (column names get value from row, literals stay as-is)
-	args := make([]string, len(fn.Args))
-	for i, arg := range fn.Args {
-		if val, ok := row[arg]; ok {
-			args[i] = val
-		} else {
-			args[i] = arg // literal value
-		}
-	}
+	args := functionArgs(fn, row)
 
 	switch fn.Function {
 	case "UPPER":
@@ -604,6 +1191,17 @@ func evalStringFunction(fn sql.FunctionExpr, row map[string]string) string {
 		if len(args) >= 3 {
 			return strings.ReplaceAll(args[0], args[1], args[2])
 		}
+	case "TO_BASE64":
+		if len(args) >= 1 {
+			return base64.StdEncoding.EncodeToString([]byte(args[0]))
+		}
+	case "FROM_BASE64":
+		// FROM_BASE64(str) - invalid base64 yields an empty string
+		if len(args) >= 1 {
+			if raw, err := base64.StdEncoding.DecodeString(args[0]); err == nil {
+				return string(raw)
+			}
+		}
 	// Date functions
 	case "NOW":
 		return time.Now().Format("2006-01-02 15:04:05")
@@ -729,6 +1327,16 @@ func evalStringFunction(fn sql.FunctionExpr, row map[string]string) string {
 		if len(args) >= 2 {
 			return jsonContains(args[0], args[1])
 		}
+	case "JSON_PRETTY":
+		// JSON_PRETTY(json) - returns indented JSON
+		if len(args) >= 1 {
+			return jsonFormat(args[0], true)
+		}
+	case "JSON_COMPACT":
+		// JSON_COMPACT(json) - returns JSON without insignificant whitespace
+		if len(args) >= 1 {
+			return jsonFormat(args[0], false)
+		}
 	}
 	return ""
 }
@@ -860,17 +1468,41 @@ func jsonContains(jsonStr, value string) string {
 	return "0"
 }
 
+// jsonFormat re-encodes JSON either indented or compact; invalid JSON is returned as-is
+func jsonFormat(jsonStr string, pretty bool) string {
+	var buf bytes.Buffer
+	var err error
+	if pretty {
+		err = json.Indent(&buf, []byte(jsonStr), "", "  ")
+	} else {
+		err = json.Compact(&buf, []byte(jsonStr))
+	}
+	if err != nil {
+		return jsonStr
+	}
+	return buf.String()
+}
+
+// dateTimeFormats are the layouts parseDateTime accepts, in the order tried.
+// Fractional seconds are accepted after the seconds of any layout.
+var dateTimeFormats = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02",
+	"2006/01/02",
+	"01/02/2006",
+	"Jan 2, 2006",
+}
+
+// timestampFormat is the canonical form TIMESTAMP values are stored in.
+// Fractional seconds are kept, without trailing zeros.
+const timestampFormat = "2006-01-02 15:04:05.999999999"
+
 // parseDateTime parses various date/time formats
 func parseDateTime(s string) (time.Time, error) {
-	formats := []string{
-		"2006-01-02 15:04:05",
-		"2006-01-02",
-		"2006/01/02",
-		"01/02/2006",
-		"Jan 2, 2006",
-		time.RFC3339,
-	}
-	for _, format := range formats {
+	for _, format := range dateTimeFormats {
 		if t, err := time.Parse(format, s); err == nil {
 			return t, nil
 		}
@@ -878,6 +1510,17 @@ func parseDateTime(s string) (time.Time, error) {
 	return time.Time{}, fmt.Errorf("unable to parse date: %s", s)
 }
 
+// canonicalTimestamp parses a TIMESTAMP value in any accepted format and
+// returns it in the stored form, converted to UTC when it carries an offset.
+func canonicalTimestamp(column, value string) (string, error) {
+	t, err := parseDateTime(value)
+	if err != nil {
+		return "", constraintErrorf(column, "invalid TIMESTAMP format for column %s: %s (expected YYYY-MM-DD HH:MM:SS, "+
+			"YYYY-MM-DDTHH:MM:SS with an optional Z or +HH:MM offset, or a date such as YYYY-MM-DD)", column, value)
+	}
+	return t.UTC().Format(timestampFormat), nil
+}
+
 // addToDate adds an interval to a date
 func addToDate(t time.Time, interval int, unit string) time.Time {
 	switch unit {
@@ -1000,17 +1643,17 @@ func mergeRows(left, right map[string]string) map[string]string {
 }
 
 // matchesWhereClause evaluates all conditions in the WHERE clause
-func matchesWhereClause(row map[string]string, where sql.WhereClause) bool {
+func matchesWhereClause(row map[string]string, where sql.WhereClause, collation Collation) bool {
 	if len(where.Conditions) == 0 {
 		return true
 	}
 
 	// Evaluate first condition
-	result := evaluateCondition(row, where.Conditions[0])
+	result := evaluateCondition(row, where.Conditions[0], collation)
 
 	// Apply logical operators for remaining conditions
 	for i := 1; i < len(where.Conditions); i++ {
-		condResult := evaluateCondition(row, where.Conditions[i])
+		condResult := evaluateCondition(row, where.Conditions[i], collation)
 
 		if i-1 < len(where.LogicalOps) {
 			switch where.LogicalOps[i-1] {
@@ -1028,21 +1671,47 @@ func matchesWhereClause(row map[string]string, where sql.WhereClause) bool {
 	return result
 }
 
-// evaluateCondition evaluates a single WHERE condition
-func evaluateCondition(row map[string]string, cond sql.WhereCondition) bool {
+// primaryKeyLookup returns the key a WHERE clause pins the primary key to
+// with equality, when every condition must hold (no OR). Case-insensitive
+// equality can match keys stored in another case, so it always scans.
+func primaryKeyLookup(where sql.WhereClause, table core.Table, collation Collation) (string, bool) {
+	if collation == CollationCaseInsensitive || slices.Contains(where.LogicalOps, sql.LogicalOr) {
+		return "", false
+	}
+	for _, column := range table.Columns {
+		if !column.PrimaryKey || column.Type == core.BlobType {
+			continue
+		}
+		for _, cond := range where.Conditions {
+			if cond.Left == column.Name && cond.Operator == sql.EqualsOperator && !cond.Negated {
+				return cond.Right, true
+			}
+		}
+	}
+	return "", false
+}
+
+// evaluateCondition evaluates a single WHERE condition; string equality
+// and LIKE follow the collation
+func evaluateCondition(row map[string]string, cond sql.WhereCondition, collation Collation) bool {
 	value, exists := row[cond.Left]
 
+	// Comparisons against NULL never match, even when negated
+	if !exists && cond.Operator != sql.IsNullOperator && cond.Operator != sql.IsNotNullOperator {
+		return false
+	}
+
 	var result bool
 
 	switch cond.Operator {
 	case sql.IsNullOperator:
-		result = !exists || value == ""
+		result = !exists
 	case sql.IsNotNullOperator:
-		result = exists && value != ""
+		result = exists
 	case sql.EqualsOperator:
-		result = value == cond.Right
+		result = collation.equal(value, cond.Right)
 	case sql.NotEqualsOperator:
-		result = value != cond.Right
+		result = !collation.equal(value, cond.Right)
 	case sql.LessThanOperator:
 		result = compareValues(value, cond.Right) < 0
 	case sql.GreaterThanOperator:
@@ -1052,14 +1721,28 @@ func evaluateCondition(row map[string]string, cond sql.WhereCondition) bool {
 	case sql.GreaterThanOrEqualOperator:
 		result = compareValues(value, cond.Right) >= 0
 	case sql.LikeOperator:
-		result = matchLike(value, cond.Right)
+		result = matchLike(value, cond.Right, collation.likeCaseSensitive())
+	case sql.LikeAnyOperator:
+		result = slices.ContainsFunc(cond.InValues, func(pattern string) bool {
+			return matchLike(value, pattern, collation.likeCaseSensitive())
+		})
+	case sql.LikeAllOperator:
+		result = !slices.ContainsFunc(cond.InValues, func(pattern string) bool {
+			return !matchLike(value, pattern, collation.likeCaseSensitive())
+		})
 	case sql.InOperator:
-		result = false
-		for _, v := range cond.InValues {
-			if value == v {
-				result = true
-				break
-			}
+		// Case-insensitive matches cannot use the exact-value set
+		if cond.InSet != nil && collation != CollationCaseInsensitive {
+			_, result = cond.InSet[value]
+		} else {
+			result = slices.ContainsFunc(cond.InValues, func(v string) bool {
+				return collation.equal(value, v)
+			})
+		}
+		// A NULL in the list makes a value it does not match unknown rather
+		// than false, so neither IN nor NOT IN matches it
+		if !result && inListHasNull(cond) {
+			return false
 		}
 	default:
 		result = false
@@ -1073,6 +1756,15 @@ func evaluateCondition(row map[string]string, cond sql.WhereCondition) bool {
 	return result
 }
 
+// inListHasNull reports whether an IN condition lists NULL
+func inListHasNull(cond sql.WhereCondition) bool {
+	if cond.InSet != nil {
+		_, ok := cond.InSet[sql.NullValue]
+		return ok
+	}
+	return slices.Contains(cond.InValues, sql.NullValue)
+}
+
 // compareValues compares two values, trying numeric comparison first, then string
 func compareValues(a, b string) int {
 	// Try numeric comparison first
@@ -1093,12 +1785,24 @@ func compareValues(a, b string) int {
 }
 
 // matchLike performs simple LIKE pattern matching with % wildcards
-func matchLike(value, pattern string) bool {
+func matchLike(value, pattern string, caseSensitive bool) bool {
 	// Handle simple cases
 	if pattern == "%" {
 		return true
 	}
 
+	if caseSensitive {
+		switch {
+		case strings.HasPrefix(pattern, "%") && strings.HasSuffix(pattern, "%"):
+			return strings.Contains(value, pattern[1:len(pattern)-1])
+		case strings.HasPrefix(pattern, "%"):
+			return strings.HasSuffix(value, pattern[1:])
+		case strings.HasSuffix(pattern, "%"):
+			return strings.HasPrefix(value, pattern[:len(pattern)-1])
+		}
+		return value == pattern
+	}
+
 	// Check for patterns like %text%, %text, text%
 	if strings.HasPrefix(pattern, "%") && strings.HasSuffix(pattern, "%") {
 		// Contains match
@@ -1124,12 +1828,7 @@ func applyDistinct(results []map[string]string, columns []string) []map[string]s
 	var distinct []map[string]string
 
 	for _, row := range results {
-		// Create a key from the selected column values
-		var keyParts []string
-		for _, col := range columns {
-			keyParts = append(keyParts, row[col])
-		}
-		key := strings.Join(keyParts, "\x00")
+		key := valuesKey(row, columns)
 
 		if !seen[key] {
 			seen[key] = true
@@ -1140,6 +1839,308 @@ func applyDistinct(results []map[string]string, columns []string) []map[string]s
 	return distinct
 }
 
+// tableQualifiers returns the names a query may use to qualify a table's
+// columns: database.name, its name and its alias.
+func tableQualifiers(database, table, alias string) []string {
+	qualifiers := []string{database + "." + table, table}
+	if alias != "" {
+		qualifiers = append(qualifiers, alias)
+	}
+	return qualifiers
+}
+
+// addTableQualifiers registers each of the table's qualifiers for its columns.
+func addTableQualifiers(qualified map[string][]string, database, table, alias string, columns []string) {
+	for _, qualifier := range tableQualifiers(database, table, alias) {
+		qualified[qualifier] = columns
+	}
+}
+
+// qualifyRows adds a qualifier.column entry for every column value of the
+// rows, so a joined table's columns stay apart from same-named ones.
+func qualifyRows(rows []map[string]string, columns []string, qualifiers []string) {
+	for _, row := range rows {
+		for _, column := range columns {
+			value, ok := row[column]
+			if !ok {
+				continue
+			}
+			for _, qualifier := range qualifiers {
+				row[qualifier+"."+column] = value
+			}
+		}
+	}
+}
+
+// qualifiedKeys returns the row keys of a table's columns: database.table.column
+// when the rows were qualified for a join, otherwise the column names.
+func qualifiedKeys(database, table string, columns []string, qualified bool) []string {
+	if !qualified {
+		return append([]string{}, columns...)
+	}
+	keys := make([]string, len(columns))
+	for i, column := range columns {
+		keys[i] = database + "." + table + "." + column
+	}
+	return keys
+}
+
+// expandSelectColumns expands the qualified wildcards of statement.Columns,
+// moving FunctionsAfter so functions keep their place among the columns. It
+// returns the row key of each column, as expandQualifiedWildcards does.
+func expandSelectColumns(statement *sql.SelectStatement, qualified map[string][]string) ([]string, error) {
+	columns, keys, err := expandQualifiedWildcards(statement.Columns, qualified)
+	if err != nil || len(columns) == len(statement.Columns) {
+		statement.Columns = columns
+		return keys, err
+	}
+	if len(statement.FunctionsAfter) > 0 {
+		after := make([]int, len(statement.FunctionsAfter))
+		for i, written := range statement.FunctionsAfter {
+			before, _, _ := expandQualifiedWildcards(statement.Columns[:written], qualified)
+			after[i] = len(before)
+		}
+		statement.FunctionsAfter = after
+	}
+	statement.Columns = columns
+	return keys, nil
+}
+
+// expandQualifiedWildcards replaces each qualifier.* entry with the columns
+// of the table that qualifier names. It also returns the row key of each
+// column, qualifier.column for expanded ones, for reading joined rows.
+func expandQualifiedWildcards(columns []string, qualified map[string][]string) ([]string, []string, error) {
+	var expanded, keys []string
+	for _, column := range columns {
+		qualifier, ok := strings.CutSuffix(column, ".*")
+		if !ok {
+			expanded = append(expanded, column)
+			keys = append(keys, column)
+			continue
+		}
+		tableColumns, ok := qualified[qualifier]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown table %s in %s", qualifier, column)
+		}
+		expanded = append(expanded, tableColumns...)
+		for _, tableColumn := range tableColumns {
+			keys = append(keys, qualifier+"."+tableColumn)
+		}
+	}
+	return expanded, keys, nil
+}
+
+// validateColumnReferences checks that every column the statement reads is one
+// of the available columns, so typos fail instead of yielding empty values.
+// A qualified name (alias.column) must name a column of the table its
+// qualifier refers to in qualified, which maps each qualifier of the source
+// and joined tables to their columns. A name that more than one of the
+// tables has is ambiguous unless qualified.
+func validateColumnReferences(statement sql.SelectStatement, available []string, qualified map[string][]string) error {
+	known := make(map[string]bool, len(available))
+	ambiguous := make(map[string]bool)
+	for _, column := range available {
+		// After a join, a name more than one table has must be qualified
+		ambiguous[column] = known[column]
+		known[column] = true
+	}
+	// Function results are computed columns for GROUP BY and aggregates
+	for _, fn := range append(slices.Clip(statement.Functions), statement.Computed...) {
+		known[fn.Name()] = true
+		if fn.Alias != "" {
+			known[fn.Alias] = true
+		}
+	}
+	// Literal select-list entries are constant columns under their own name
+	if len(statement.Literals) > 0 && (len(statement.Aggregates) > 0 || len(statement.HavingAggregates) > 0) {
+		return errors.New("literal columns cannot be combined with aggregates")
+	}
+	for name := range statement.Literals {
+		if known[name] {
+			return fmt.Errorf("literal column %s conflicts with a column of the same name", name)
+		}
+		known[name] = true
+	}
+
+	// HAVING and ORDER BY may also name an aggregate or function output
+	outputs := make(map[string]bool)
+	for _, agg := range statement.Aggregates {
+		outputs[agg.Name()] = true
+		if agg.Alias != "" {
+			outputs[agg.Alias] = true
+		}
+	}
+	for _, fn := range statement.Functions {
+		if fn.Alias != "" {
+			outputs[fn.Alias] = true
+		}
+	}
+
+	check := func(column string) error {
+		if ambiguous[column] {
+			return fmt.Errorf("ambiguous column %s: qualify it with its table name or alias", column)
+		}
+		if known[column] {
+			return nil
+		}
+		if i := strings.LastIndex(column, "."); i >= 0 {
+			tableColumns, ok := qualified[column[:i]]
+			if !ok {
+				return fmt.Errorf("unknown table %s in %s", column[:i], column)
+			}
+			if slices.Contains(tableColumns, column[i+1:]) {
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown column %s", column)
+	}
+	checkWhere := func(where sql.WhereClause) error {
+		for _, cond := range where.Conditions {
+			if err := check(cond.Left); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, column := range statement.Columns {
+		if qualifier, ok := strings.CutSuffix(column, ".*"); ok {
+			if _, ok := qualified[qualifier]; !ok {
+				return fmt.Errorf("unknown table %s in %s", qualifier, column)
+			}
+			continue
+		}
+		if err := check(column); err != nil {
+			return err
+		}
+	}
+	// A function may read the alias of an earlier one, but not of itself or
+	// a later one
+	for i, fn := range statement.Functions {
+		for j, arg := range fn.Args {
+			if fn.IsLiteral(j) || slices.Contains(available, arg) {
+				continue
+			}
+			later := slices.IndexFunc(statement.Functions[i:], func(other sql.FunctionExpr) bool { return other.Alias == arg })
+			if later >= 0 && !slices.ContainsFunc(statement.Functions[:i], func(other sql.FunctionExpr) bool { return other.Alias == arg }) {
+				return fmt.Errorf("%s refers to alias %s before it is defined", fn.Name(), arg)
+			}
+			if err := check(arg); err != nil {
+				return fmt.Errorf("%s: %w", fn.Name(), err)
+			}
+		}
+	}
+	for _, fn := range statement.Computed {
+		for j, arg := range fn.Args {
+			if fn.IsLiteral(j) {
+				continue
+			}
+			if err := check(arg); err != nil {
+				return fmt.Errorf("%s: %w", fn.Name(), err)
+			}
+		}
+	}
+	for _, agg := range append(slices.Clip(statement.Aggregates), statement.HavingAggregates...) {
+		if agg.Column != "*" {
+			if err := check(agg.Column); err != nil {
+				return err
+			}
+		}
+		if err := checkWhere(agg.Filter); err != nil {
+			return err
+		}
+	}
+	for _, join := range statement.Joins {
+		if err := check(join.LeftCol); err != nil {
+			return err
+		}
+		if err := check(join.RightCol); err != nil {
+			return err
+		}
+	}
+	if err := checkWhere(statement.Where); err != nil {
+		return err
+	}
+	for _, column := range statement.GroupBy {
+		if err := check(column); err != nil {
+			return err
+		}
+	}
+	for _, column := range statement.DistinctOn {
+		if err := check(column); err != nil {
+			return err
+		}
+	}
+	for _, agg := range statement.HavingAggregates {
+		outputs[agg.Name()] = true
+	}
+	for _, cond := range statement.Having.Conditions {
+		if outputs[cond.Left] {
+			continue
+		}
+		if err := check(cond.Left); err != nil {
+			return err
+		}
+	}
+	for _, clause := range statement.OrderBy {
+		if outputs[clause.Column] {
+			continue
+		}
+		if err := check(clause.Column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// primaryKeyOrder returns the default ordering of a table: ascending by primary key.
+func primaryKeyOrder(table core.Table) []sql.OrderByClause {
+	for _, col := range table.Columns {
+		if col.PrimaryKey {
+			return []sql.OrderByClause{{Column: col.Name}}
+		}
+	}
+	return nil
+}
+
+// withPrimaryKeyTieBreak appends the table's primary key to orderBy, so rows
+// with equal sort values come back in the same order on every backend.
+func withPrimaryKeyTieBreak(orderBy []sql.OrderByClause, table core.Table) []sql.OrderByClause {
+	return append(slices.Clip(orderBy), primaryKeyOrder(table)...)
+}
+
+// unqualifyOrderBy strips the source table's qualifiers from ORDER BY
+// columns, since rows are only keyed by qualified names when there are joins.
+// ORDER BY u.name then sorts by name, as SELECT u.name selects it.
+func unqualifyOrderBy(orderBy []sql.OrderByClause, qualifiers []string) []sql.OrderByClause {
+	resolved := slices.Clone(orderBy)
+	for i, clause := range resolved {
+		for _, qualifier := range qualifiers {
+			if column, ok := strings.CutPrefix(clause.Column, qualifier+"."); ok {
+				resolved[i].Column = column
+				break
+			}
+		}
+	}
+	return resolved
+}
+
+// unqualifyWhere strips qualifiers of the queried table from the columns of
+// where, as unqualifyOrderBy does for ORDER BY.
+func unqualifyWhere(where sql.WhereClause, qualifiers []string) sql.WhereClause {
+	resolved := where
+	resolved.Conditions = slices.Clone(where.Conditions)
+	for i, cond := range resolved.Conditions {
+		for _, qualifier := range qualifiers {
+			if column, ok := strings.CutPrefix(cond.Left, qualifier+"."); ok {
+				resolved.Conditions[i].Left = column
+				break
+			}
+		}
+	}
+	return resolved
+}
+
 // sortResults sorts the results by ORDER BY clauses
 func sortResults(results []map[string]string, orderBy []sql.OrderByClause) {
 	sort.SliceStable(results, func(i, j int) bool {
@@ -1167,10 +2168,6 @@ func (engine *Engine) executeInsertStatement(statement sql.InsertStatement) (Com
 		return CommitResult{}, err
 	}
 
-	if len(statement.Columns) != len(tableOp.Table.Columns) {
-		return CommitResult{}, fmt.Errorf("statement column length does not match table column count")
-	}
-
 	pk, err := tableOp.PrimaryKey()
 	if err != nil {
 		return CommitResult{}, err
@@ -1182,65 +2179,69 @@ func (engine *Engine) executeInsertStatement(statement sql.InsertStatement) (Com
 		columnTypes[col.Name] = col.Type
 	}
 
-	var txn ps.Transaction
-	recordsWritten := 0
-
-	// Process each row in the bulk insert
-	for _, valueRow := range statement.ValueRows {
-		if len(statement.Columns) != len(valueRow) {
-			return CommitResult{}, fmt.Errorf("value count does not match column count")
+	// Columns may be omitted; they take their DEFAULT or are left NULL
+	for _, column := range statement.Columns {
+		if _, ok := columnTypes[column]; !ok {
+			return CommitResult{}, fmt.Errorf("column %s does not exist", column)
 		}
+	}
 
-		data := make(map[string]interface{})
-
-		for index, column := range statement.Columns {
-			value := valueRow[index]
-
-			// Handle NOW() function - expand to current timestamp
-			if strings.ToUpper(value) == "NOW()" {
-				colType := columnTypes[column]
-				if colType == core.DateType {
-					value = time.Now().Format("2006-01-02")
-				} else {
-					value = time.Now().Format("2006-01-02 15:04:05")
-				}
-			}
+	returningColumns, err := resolveReturningColumns(statement.Returning, tableOp.Table)
+	if err != nil {
+		return CommitResult{}, err
+	}
 
-			// Validate DATE/TIMESTAMP format
-			colType := columnTypes[column]
-			if colType == core.DateType {
-				if _, err := parseDateTime(value); err != nil {
-					// Try common date formats
-					if !isValidDateFormat(value) {
-						return CommitResult{}, fmt.Errorf("invalid DATE format for column %s: %s (expected YYYY-MM-DD)", column, value)
-					}
-				}
-			} else if colType == core.TimestampType {
-				if _, err := parseDateTime(value); err != nil {
-					return CommitResult{}, fmt.Errorf("invalid TIMESTAMP format for column %s: %s (expected YYYY-MM-DD HH:MM:SS)", column, value)
-				}
-			} else if colType == core.JsonType {
-				// Validate JSON format
-				var js interface{}
-				if err := json.Unmarshal([]byte(value), &js); err != nil {
-					return CommitResult{}, fmt.Errorf("invalid JSON format for column %s: %s", column, err.Error())
-				}
-			}
+	var txn ps.Transaction
+	var writtenRows []map[string]string
+	var affectedKeys []string
+	recordsWritten := 0
 
-			data[column] = value
+	// Every row is built and validated, and the indexes staged, before the
+	// first one is written
+	rows := make([]map[string]string, len(statement.ValueRows))
+	records := make(map[string][]byte, len(statement.ValueRows))
+	encoded := make([][]byte, len(statement.ValueRows))
+	for i, valueRow := range statement.ValueRows {
+		data, err := engine.insertRow(tableOp.Table, *pk, statement.Columns, valueRow)
+		if err != nil {
+			return CommitResult{}, err
 		}
-
-		pkValue := data[*pk].(string)
-		jsonData, err := json.Marshal(data)
+		jsonData, err := marshalRow(data, tableOp.Table)
 		if err != nil {
 			return CommitResult{}, err
 		}
+		rows[i], encoded[i] = data, jsonData
+		records[data[*pk]] = jsonData
+	}
+	saveIndexes, err := engine.stageRecordIndexUpdates(tableOp, records)
+	if err != nil {
+		return CommitResult{}, err
+	}
 
-		txn, err = tableOp.Put(pkValue, jsonData, engine.Identity)
+	// Process each row in the bulk insert
+	for i, data := range rows {
+		pkValue := data[*pk]
+		rowTxn, err := tableOp.Put(pkValue, encoded[i], engine.author(tableOp.Table))
 		if err != nil {
 			return CommitResult{}, err
 		}
-		recordsWritten++
+		// Rewriting a row with the values it already holds makes no commit
+		if !rowTxn.Unchanged {
+			recordsWritten++
+			if engine.ReportKeys {
+				affectedKeys = append(affectedKeys, pkValue)
+			}
+		}
+		if !rowTxn.Unchanged || recordsWritten == 0 {
+			txn = rowTxn
+		}
+		if returningColumns != nil {
+			decodeBlobs(data, tableOp.Table)
+			writtenRows = append(writtenRows, data)
+		}
+	}
+	if err := saveIndexes(); err != nil {
+		return CommitResult{}, err
 	}
 
 	return CommitResult{
@@ -1251,22 +2252,280 @@ func (engine *Engine) executeInsertStatement(statement sql.InsertStatement) (Com
 		TablesDeleted:    0,
 		RecordsWritten:   recordsWritten,
 		RecordsDeleted:   0,
-		ExecutionTimeMs:  float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs:  elapsedMs(startTime),
 		ExecutionOps:     recordsWritten,
+		Returning:        returningResult(returningColumns, writtenRows),
+		rows:             writtenRows,
+		AffectedKeys:     affectedKeys,
 	}, nil
 }
 
-// isValidDateFormat checks if the string is a valid date format
-func isValidDateFormat(s string) bool {
-	dateFormats := []string{
-		"2006-01-02",
-		"2006/01/02",
-		"01/02/2006",
-		"01-02-2006",
+// insertRow builds the stored row for one VALUES row of an INSERT into
+// table: it fills in DEFAULT values, expands NOW() and validates the values
+// against the column types, NOT NULL and CHECK constraints.
+func (engine *Engine) insertRow(table core.Table, pk string, columns []string, valueRow []string) (map[string]string, error) {
+	columnTypes := make(map[string]core.ColumnType)
+	for _, col := range table.Columns {
+		columnTypes[col.Name] = col.Type
 	}
-	for _, format := range dateFormats {
-		if _, err := time.Parse(format, s); err == nil {
-			return true
+
+	if len(columns) != len(valueRow) {
+		return nil, fmt.Errorf("value count does not match column count")
+	}
+
+	// Columns given the DEFAULT keyword are treated as omitted
+	if slices.Contains(valueRow, sql.DefaultValue) {
+		var explicit []string
+		var explicitValues []string
+		for i, value := range valueRow {
+			if value != sql.DefaultValue {
+				explicit = append(explicit, columns[i])
+				explicitValues = append(explicitValues, value)
+			}
+		}
+		columns, valueRow = explicit, explicitValues
+	}
+
+	data := make(map[string]string)
+
+	// Omitted columns take their DEFAULT value. A DEFAULT function is
+	// evaluated for each row and validated like a value given explicitly.
+	givenColumns := columns
+	for _, column := range table.Columns {
+		if slices.Contains(givenColumns, column.Name) {
+			continue
+		}
+		if column.DefaultExpr != "" {
+			value, err := engine.evalDefault(column)
+			if err != nil {
+				return nil, err
+			}
+			columns = append(slices.Clip(columns), column.Name)
+			valueRow = append(slices.Clip(valueRow), value)
+		} else if column.Default != nil {
+			value, err := storedValue(column.Name, column.Type, *column.Default)
+			if err != nil {
+				return nil, err
+			}
+			data[column.Name] = value
+		}
+	}
+
+	for index, column := range columns {
+		value := valueRow[index]
+		var err error
+
+		// NULL columns are left out of the stored row
+		if value == sql.NullValue {
+			if column == pk {
+				return nil, constraintErrorf(column, "primary key %s cannot be NULL", column)
+			}
+			continue
+		}
+
+		// Validate DATE/TIMESTAMP format
+		colType := columnTypes[column]
+		value = expandNow(value, colType)
+		if colType == core.DateType {
+			if _, err := parseDateTime(value); err != nil {
+				// Try common date formats
+				if !isValidDateFormat(value) {
+					return nil, constraintErrorf(column, "invalid DATE format for column %s: %s (expected YYYY-MM-DD)", column, value)
+				}
+			}
+		} else if colType == core.JsonType {
+			// Validate JSON format
+			var js interface{}
+			if err := json.Unmarshal([]byte(value), &js); err != nil {
+				return nil, constraintErrorf(column, "invalid JSON format for column %s: %s", column, err.Error())
+			}
+		}
+
+		value, err = storedValue(column, colType, value)
+		if err != nil {
+			return nil, err
+		}
+		data[column] = value
+	}
+
+	if _, ok := data[pk]; !ok {
+		return nil, constraintErrorf(pk, "primary key %s cannot be NULL", pk)
+	}
+	// NOT NULL columns must be given a value or have a DEFAULT
+	for _, column := range table.Columns {
+		if _, ok := data[column.Name]; column.NotNull && !ok {
+			return nil, constraintErrorf(column.Name, "column %s cannot be NULL", column.Name)
+		}
+	}
+	if err := engine.checkRow(table, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// expandNow replaces sql.NowValue with the current date or timestamp, as
+// suits the column type.
+func expandNow(value string, colType core.ColumnType) string {
+	if value != sql.NowValue {
+		return value
+	}
+	if colType == core.DateType {
+		return time.Now().Format("2006-01-02")
+	}
+	return time.Now().Format("2006-01-02 15:04:05")
+}
+
+// defaultValue returns what the DEFAULT keyword sets column to: its DEFAULT
+// function's result or DEFAULT value, or sql.NullValue when it has neither.
+func (engine *Engine) defaultValue(column core.Column) (string, error) {
+	if column.DefaultExpr != "" {
+		value, err := engine.evalDefault(column)
+		if err != nil {
+			return "", err
+		}
+		return expandNow(value, column.Type), nil
+	}
+	if column.Default != nil {
+		return *column.Default, nil
+	}
+	return sql.NullValue, nil
+}
+
+// columnDefault returns the stored value of column in a row that leaves it
+// out, as COPY fills rows: its DEFAULT function, evaluated for each call, or
+// its DEFAULT value. It reports false when the column has neither.
+func (engine *Engine) columnDefault(column core.Column) (string, bool, error) {
+	if column.DefaultExpr == "" && column.Default == nil {
+		return "", false, nil
+	}
+	value, err := engine.defaultValue(column)
+	if err != nil {
+		return "", false, err
+	}
+	value, err = storedValue(column.Name, column.Type, value)
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+// evalDefault evaluates the DEFAULT function of column. NOW() is returned as
+// sql.NowValue, so it expands to a date or a timestamp to suit the column.
+func (engine *Engine) evalDefault(column core.Column) (string, error) {
+	fn, err := sql.ParseFunction(column.DefaultExpr)
+	if err != nil {
+		return "", err
+	}
+	if fn.Function == "NOW" && len(fn.Args) == 0 {
+		if _, ok := engine.functions[fn.Function]; !ok {
+			return sql.NowValue, nil
+		}
+	}
+	return engine.evalFunction(fn, map[string]string{})
+}
+
+// checkRow reports the first CHECK constraint of table that the stored row
+// makes false. As in standard SQL, a check on a NULL column passes. A stored
+// constraint means the same to every engine: it compares with the default
+// collation and calls only built-in functions, whatever the session sets.
+func (engine *Engine) checkRow(table core.Table, row map[string]string) error {
+	if len(table.Checks) == 0 {
+		return nil
+	}
+	decoded := maps.Clone(row)
+	decodeBlobs(decoded, table)
+	for _, check := range table.Checks {
+		where, functions, err := sql.ParseCheck(check)
+		if err != nil {
+			return err
+		}
+
+		// The columns the check reads, in order
+		var columns []string
+		for _, fn := range functions {
+			columns = append(columns, fn.Args...)
+		}
+		for _, condition := range where.Conditions {
+			columns = append(columns, condition.Left)
+		}
+		columns = slices.DeleteFunc(columns, func(name string) bool {
+			return !slices.ContainsFunc(table.Columns, func(column core.Column) bool { return column.Name == name })
+		})
+		if slices.ContainsFunc(columns, func(name string) bool { _, ok := decoded[name]; return !ok }) {
+			continue
+		}
+
+		values := maps.Clone(decoded)
+		for _, fn := range functions {
+			values[fn.Name()] = evalStringFunction(fn, decoded)
+		}
+		if !matchesWhereClause(values, where, CollationDefault) {
+			column := ""
+			if len(columns) > 0 {
+				column = columns[0]
+			}
+			return constraintErrorf(column, "CHECK constraint (%s) violated", check)
+		}
+	}
+	return nil
+}
+
+// resolveReturningColumns expands a RETURNING column list against the table,
+// returning nil when the statement has no RETURNING clause.
+func resolveReturningColumns(returning []string, table core.Table) ([]string, error) {
+	if len(returning) == 0 {
+		return nil, nil
+	}
+
+	var tableColumns []string
+	for _, col := range table.Columns {
+		tableColumns = append(tableColumns, col.Name)
+	}
+	if len(returning) == 1 && returning[0] == "*" {
+		return tableColumns, nil
+	}
+
+	for _, col := range returning {
+		if !slices.Contains(tableColumns, col) {
+			return nil, fmt.Errorf("unknown column %s in RETURNING", col)
+		}
+	}
+	return returning, nil
+}
+
+// returningResult builds the RETURNING payload of a write from the affected rows.
+func returningResult(columns []string, rows []map[string]string) *QueryResult {
+	if columns == nil {
+		return nil
+	}
+
+	data := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		values := make([]string, len(columns))
+		for i, col := range columns {
+			values[i] = row[col]
+		}
+		data = append(data, values)
+	}
+	return &QueryResult{
+		Columns:      columns,
+		Data:         data,
+		RecordsRead:  len(rows),
+		ExecutionOps: len(rows),
+	}
+}
+
+// isValidDateFormat checks if the string is a valid date format
+func isValidDateFormat(s string) bool {
+	dateFormats := []string{
+		"2006-01-02",
+		"2006/01/02",
+		"01/02/2006",
+		"01-02-2006",
+	}
+	for _, format := range dateFormats {
+		if _, err := time.Parse(format, s); err == nil {
+			return true
 		}
 	}
 	return false
@@ -1285,59 +2544,130 @@ func (engine *Engine) executeUpdateStatement(statement sql.UpdateStatement) (Com
 		return CommitResult{}, err
 	}
 
-	// TODO: Add support for multiple conditions in the WHERE clause including non-PK columns
+	returningColumns, err := resolveReturningColumns(statement.Returning, tableOp.Table)
+	if err != nil {
+		return CommitResult{}, err
+	}
 
-	if len(statement.Where.Conditions) > 0 {
-		where := statement.Where.Conditions[0]
+	if len(statement.Where.Conditions) == 0 {
+		return CommitResult{}, fmt.Errorf("no WHERE clause provided in the UPDATE statement")
+	}
 
-		if where.Left != *pk {
-			return CommitResult{}, fmt.Errorf("currently only support primary key updates")
+	// Check the new values once, before any row is read
+	values := make(map[string]string, len(statement.Updates))
+	for _, update := range statement.Updates {
+		if update.Value == sql.DefaultValue {
+			i := slices.IndexFunc(tableOp.Table.Columns, func(column core.Column) bool { return column.Name == update.Column })
+			if i < 0 {
+				return CommitResult{}, fmt.Errorf("column %s does not exist", update.Column)
+			}
+			update.Value, err = engine.defaultValue(tableOp.Table.Columns[i])
+			if err != nil {
+				return CommitResult{}, err
+			}
 		}
-
-		rawData, exists := tableOp.GetString(where.Right)
-		if !exists {
-			return CommitResult{}, errors.New("record not found")
+		if update.Value == sql.NullValue {
+			if update.Column == *pk {
+				return CommitResult{}, constraintErrorf(update.Column, "primary key %s cannot be NULL", update.Column)
+			}
+			if isNotNullColumn(tableOp.Table, update.Column) {
+				return CommitResult{}, constraintErrorf(update.Column, "column %s cannot be NULL", update.Column)
+			}
+			values[update.Column] = sql.NullValue
+			continue
 		}
-
-		var jsonData map[string]string
-		err = json.Unmarshal([]byte(rawData), &jsonData)
+		value, err := storedValue(update.Column, columnType(tableOp.Table, update.Column), update.Value)
 		if err != nil {
 			return CommitResult{}, err
 		}
+		values[update.Column] = value
+	}
 
-		for _, update := range statement.Updates {
-			jsonData[update.Column] = update.Value
-		}
+	changes, opCount, err := engine.matchingRows(tableOp, *pk, statement.Where, statement.Limit)
+	if err != nil {
+		return CommitResult{}, err
+	}
+	if len(changes) == 0 && isPrimaryKeyLookup(statement.Where, *pk) && statement.Where.Conditions[0].Operator == sql.EqualsOperator {
+		return CommitResult{}, errors.New("record not found")
+	}
 
-		newData, err := json.Marshal(jsonData)
+	// Rows already holding the new values are left as they are
+	records := make(map[string][]byte)
+	for i := range changes {
+		change := &changes[i]
+		change.newRow = maps.Clone(change.oldRow)
+		for column, value := range values {
+			if value == sql.NullValue {
+				delete(change.newRow, column)
+			} else {
+				change.newRow[column] = value
+			}
+		}
+		if maps.Equal(change.oldRow, change.newRow) {
+			continue
+		}
+		// Rows are stored under their primary key, which UPDATE cannot move
+		if change.newRow[*pk] != change.key {
+			return CommitResult{}, constraintErrorf(*pk, "primary key %s cannot be changed by UPDATE", *pk)
+		}
+		if err := engine.checkRow(tableOp.Table, change.newRow); err != nil {
+			return CommitResult{}, err
+		}
+		newData, err := marshalRow(change.newRow, tableOp.Table)
 		if err != nil {
 			return CommitResult{}, err
 		}
+		records[change.key] = newData
+	}
+
+	saveIndexes, err := engine.stageRowIndexUpdates(tableOp.Table, changes)
+	if err != nil {
+		return CommitResult{}, err
+	}
 
-		txn, err := tableOp.Put(where.Right, newData, engine.Identity)
+	// Every changed row is written in a single commit; a dry run stops
+	// short of it, once the rows and index changes have been checked
+	txn := ps.Transaction{Unchanged: true}
+	if len(records) > 0 && !statement.DryRun {
+		opCount++
+		txn, err = tableOp.PutAll(records, engine.author(tableOp.Table))
 		if err != nil {
 			return CommitResult{}, err
 		}
+		if err := saveIndexes(); err != nil {
+			return CommitResult{}, err
+		}
+	}
 
-		return CommitResult{
-			Transaction:      txn,
-			DatabasesCreated: 0,
-			DatabasesDeleted: 0,
-			TablesCreated:    0,
-			TablesDeleted:    0,
-			RecordsWritten:   1,
-			RecordsDeleted:   0,
-			ExecutionTimeMs:  float64(time.Since(startTime).Milliseconds()),
-			ExecutionOps:     1, // 1 record updated
-		}, nil
-	} else {
-		return CommitResult{}, fmt.Errorf("no WHERE clause provided in the UPDATE statement")
+	var updatedRows []map[string]string
+	var affectedKeys []string
+	for _, change := range changes {
+		decodeBlobs(change.newRow, tableOp.Table)
+		updatedRows = append(updatedRows, change.newRow)
+		if _, written := records[change.key]; written && engine.ReportKeys {
+			affectedKeys = append(affectedKeys, change.key)
+		}
 	}
+
+	return CommitResult{
+		Transaction:      txn,
+		DatabasesCreated: 0,
+		DatabasesDeleted: 0,
+		TablesCreated:    0,
+		TablesDeleted:    0,
+		RecordsWritten:   len(records),
+		RecordsDeleted:   0,
+		ExecutionTimeMs:  elapsedMs(startTime),
+		ExecutionOps:     opCount,
+		Returning:        returningResult(returningColumns, updatedRows),
+		rows:             updatedRows,
+		AffectedKeys:     affectedKeys,
+		DryRun:           statement.DryRun,
+	}, nil
 }
 
 func (engine *Engine) executeDeleteStatement(statement sql.DeleteStatement) (CommitResult, error) {
 	startTime := time.Now()
-	opCount := 1
 
 	tableOp, err := op.GetTable(statement.Database, statement.Table, engine.Persistence)
 	if err != nil {
@@ -1349,46 +2679,321 @@ func (engine *Engine) executeDeleteStatement(statement sql.DeleteStatement) (Com
 		return CommitResult{}, err
 	}
 
-	// TODO: Add support for multiple conditions in the WHERE clause including non-PK columns
+	returningColumns, err := resolveReturningColumns(statement.Returning, tableOp.Table)
+	if err != nil {
+		return CommitResult{}, err
+	}
 
-	if len(statement.Where.Conditions) > 0 {
-		where := statement.Where.Conditions[0]
+	if len(statement.Where.Conditions) == 0 {
+		return CommitResult{}, fmt.Errorf("no WHERE clause provided in the DELETE statement")
+	}
 
-		if where.Left != *pk {
-			return CommitResult{}, fmt.Errorf("currently only support primary key deletes")
-		}
+	// Capture the rows before they are removed so their index entries can
+	// be dropped and RETURNING can report them
+	changes, opCount, err := engine.matchingRows(tableOp, *pk, statement.Where, statement.Limit)
+	if err != nil {
+		return CommitResult{}, err
+	}
+
+	saveIndexes, err := engine.stageRowIndexUpdates(tableOp.Table, changes)
+	if err != nil {
+		return CommitResult{}, err
+	}
 
+	// Every row is removed in a single commit, except in a dry run
+	txn := ps.Transaction{Unchanged: true}
+	if len(changes) > 0 && !statement.DryRun {
+		keys := make([]string, len(changes))
+		for i, change := range changes {
+			keys[i] = change.key
+		}
 		opCount++
-		txn, err := tableOp.Delete(where.Right, engine.Identity)
+		txn, err = tableOp.DeleteAll(keys, engine.author(tableOp.Table))
 		if err != nil {
 			return CommitResult{}, err
 		}
+		if err := saveIndexes(); err != nil {
+			return CommitResult{}, err
+		}
+	}
 
-		return CommitResult{
-			Transaction:      txn,
-			DatabasesCreated: 0,
-			DatabasesDeleted: 0,
-			TablesCreated:    0,
-			TablesDeleted:    0,
-			RecordsWritten:   0,
-			RecordsDeleted:   1,
-			ExecutionTimeMs:  float64(time.Since(startTime).Milliseconds()),
-			ExecutionOps:     1, // 1 record updated
-		}, nil
+	var deletedRows []map[string]string
+	var affectedKeys []string
+	for _, change := range changes {
+		if returningColumns != nil {
+			decodeBlobs(change.oldRow, tableOp.Table)
+		}
+		deletedRows = append(deletedRows, change.oldRow)
+		if engine.ReportKeys {
+			affectedKeys = append(affectedKeys, change.key)
+		}
+	}
+
+	return CommitResult{
+		Transaction:      txn,
+		DatabasesCreated: 0,
+		DatabasesDeleted: 0,
+		TablesCreated:    0,
+		TablesDeleted:    0,
+		RecordsWritten:   0,
+		RecordsDeleted:   len(deletedRows),
+		ExecutionTimeMs:  elapsedMs(startTime),
+		ExecutionOps:     opCount,
+		Returning:        returningResult(returningColumns, deletedRows),
+		rows:             deletedRows,
+		AffectedKeys:     affectedKeys,
+		DryRun:           statement.DryRun,
+	}, nil
+}
+
+// isPrimaryKeyLookup reports whether where is a single primary key = or IN
+// condition, whose rows can be read by key instead of by scanning.
+func isPrimaryKeyLookup(where sql.WhereClause, pk string) bool {
+	if len(where.Conditions) != 1 {
+		return false
+	}
+	condition := where.Conditions[0]
+	return condition.Left == pk && !condition.Negated &&
+		(condition.Operator == sql.EqualsOperator || condition.Operator == sql.InOperator)
+}
+
+// matchingRows returns the stored rows an UPDATE or DELETE applies to as
+// changes without a newRow, in primary key order and at most limit of them
+// when limit is positive. A primary key lookup reads the listed keys, unless
+// the collation is case-insensitive; any other WHERE reads the rows
+// chooseAccessPath picks for a SELECT with the same WHERE, probing an index
+// when one applies and otherwise scanning the table. It also returns the
+// number of rows read.
+func (engine *Engine) matchingRows(tableOp *op.TableOp, pk string, where sql.WhereClause, limit int) ([]rowChange, int, error) {
+	var changes []rowChange
+	rowsRead, corruptRows := 0, 0
+	match := func(key string, rawData []byte) error {
+		rowsRead++
+		row, err := engine.readRow(tableOp.Table.Database, tableOp.Table.Name, key, rawData, &corruptRows)
+		if err != nil || row == nil {
+			return err
+		}
+		normalizeRow(row, tableOp.Table)
+		decoded := maps.Clone(row)
+		decodeBlobs(decoded, tableOp.Table)
+		if matchesWhereClause(decoded, where, engine.Collation) {
+			changes = append(changes, rowChange{key: key, oldRow: row})
+		}
+		return nil
+	}
+
+	var keys []string
+	lookup := true
+	// Under case-insensitive collation a key may match stored keys that
+	// differ in case, so only a scan finds them all, as in primaryKeyLookup
+	if isPrimaryKeyLookup(where, pk) && engine.Collation != CollationCaseInsensitive {
+		keys = []string{where.Conditions[0].Right}
+		if where.Conditions[0].Operator == sql.InOperator {
+			keys = where.Conditions[0].InValues
+		}
 	} else {
-		return CommitResult{}, fmt.Errorf("no WHERE clause provided in the DELETE statement")
+		statement := sql.SelectStatement{Database: tableOp.Table.Database, Table: tableOp.Table.Name, Where: where}
+		switch path := engine.chooseAccessPath(statement, tableOp, engine.Persistence); path.method {
+		case AccessPrimaryKey:
+			keys = []string{path.value}
+		case AccessIndex:
+			keys = path.index.Lookup(path.value)
+		default:
+			lookup = false
+		}
 	}
+
+	if lookup {
+		seen := make(map[string]bool)
+		for _, key := range keys {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if rawData, exists := tableOp.Get(key); exists {
+				if err := match(key, rawData); err != nil {
+					return nil, rowsRead, err
+				}
+			}
+		}
+	} else {
+		for key, rawData := range tableOp.Scan() {
+			if err := match(key, rawData); err != nil {
+				return nil, rowsRead, err
+			}
+		}
+	}
+
+	slices.SortStableFunc(changes, func(a, b rowChange) int {
+		return compareValues(a.key, b.key)
+	})
+	if limit > 0 && len(changes) > limit {
+		changes = changes[:limit]
+	}
+	return changes, rowsRead, nil
+}
+
+// stageIndexUpdates moves the row stored under key from its old column values
+// to its new ones in every index on the table; a nil newRow removes the row.
+// Unique violations are reported before anything is written, so callers stage
+// first, write the row and then call the returned function to persist the
+// indexes that changed.
+func (engine *Engine) stageIndexUpdates(table core.Table, key string, oldRow, newRow map[string]string) (func() error, error) {
+	return engine.stageRowIndexUpdates(table, []rowChange{{key: key, oldRow: oldRow, newRow: newRow}})
+}
+
+// rowChange is a row stored under key moving from oldRow to newRow; a nil
+// newRow removes the row.
+type rowChange struct {
+	key            string
+	oldRow, newRow map[string]string
+}
+
+// stageRowIndexUpdates is stageIndexUpdates for several rows, whose index
+// changes are persisted together.
+func (engine *Engine) stageRowIndexUpdates(table core.Table, changes []rowChange) (func() error, error) {
+	indexManager, indexes, err := engine.loadTableIndexes(table)
+	if err != nil {
+		return nil, err
+	}
+	return stageIndexChanges(indexManager, indexes, changes)
+}
+
+// stageRecordIndexUpdates stages the index changes of storing records, by
+// primary key, over the rows the table holds now, as INSERT and COPY do.
+// Stored rows are only read when the table has indexes.
+func (engine *Engine) stageRecordIndexUpdates(tableOp *op.TableOp, records map[string][]byte) (func() error, error) {
+	indexManager, indexes, err := engine.loadTableIndexes(tableOp.Table)
+	if err != nil {
+		return nil, err
+	}
+	if len(indexes) == 0 {
+		return func() error { return nil }, nil
+	}
+
+	changes := make([]rowChange, 0, len(records))
+	for _, key := range slices.Sorted(maps.Keys(records)) {
+		change := rowChange{key: key}
+		if err := json.Unmarshal(records[key], &change.newRow); err != nil {
+			return nil, err
+		}
+		if stored, exists := tableOp.Get(key); exists && json.Unmarshal(stored, &change.oldRow) == nil {
+			normalizeRow(change.oldRow, tableOp.Table)
+		}
+		changes = append(changes, change)
+	}
+	return stageIndexChanges(indexManager, indexes, changes)
+}
+
+// loadTableIndexes returns the indexes on a table's columns, in column order
+func (engine *Engine) loadTableIndexes(table core.Table) (*ps.IndexManager, []*ps.Index, error) {
+	indexManager := ps.NewIndexManager(engine.Persistence, engine.author(table))
+	if err := indexManager.LoadIndexes(table.Database, table.Name, table.Columns); err != nil {
+		return nil, nil, err
+	}
+	var indexes []*ps.Index
+	for _, column := range table.Columns {
+		if idx, found := indexManager.GetIndex(table.Database, table.Name, column.Name); found {
+			indexes = append(indexes, idx)
+		}
+	}
+	return indexManager, indexes, nil
+}
+
+// stageIndexChanges applies changes to indexes in memory and returns the
+// function that persists the indexes that changed.
+func stageIndexChanges(indexManager *ps.IndexManager, indexes []*ps.Index, changes []rowChange) (func() error, error) {
+	var changed []*ps.Index
+	for _, idx := range indexes {
+		indexChanged := false
+		for _, change := range changes {
+			oldValue, hadOld := change.oldRow[idx.Column]
+			newValue, hasNew := change.newRow[idx.Column]
+			if hadOld == hasNew && oldValue == newValue {
+				continue
+			}
+
+			if hadOld {
+				idx.Delete(oldValue, change.key)
+			}
+			if hasNew {
+				if err := idx.Insert(newValue, change.key); err != nil {
+					return nil, constraintErrorf(idx.Column, "%v", err)
+				}
+			}
+			indexChanged = true
+		}
+		if indexChanged {
+			changed = append(changed, idx)
+		}
+	}
+
+	return func() error {
+		for _, idx := range changed {
+			if err := indexManager.SaveIndex(idx); err != nil {
+				return fmt.Errorf("failed to save index: %v", err)
+			}
+		}
+		return nil
+	}, nil
 }
 
 func (engine *Engine) executeCreateTableStatement(statement sql.CreateTableStatement) (CommitResult, error) {
 	startTime := time.Now()
 	opCount := 1
 
-	txn, _, err := op.CreateTable(core.Table{
+	var functions []sql.FunctionExpr
+	for _, column := range statement.Columns {
+		if column.Default != nil && column.Type == core.JsonType && !json.Valid([]byte(*column.Default)) {
+			return CommitResult{}, fmt.Errorf("invalid JSON default for column %s: %s", column.Name, *column.Default)
+		}
+		if column.DefaultExpr != "" {
+			fn, err := sql.ParseFunction(column.DefaultExpr)
+			if err != nil {
+				return CommitResult{}, err
+			}
+			functions = append(functions, fn)
+		}
+	}
+	for _, check := range statement.Checks {
+		_, checkFunctions, err := sql.ParseCheck(check)
+		if err != nil {
+			return CommitResult{}, err
+		}
+		for _, fn := range checkFunctions {
+			if !builtinFunctions[fn.Function] {
+				return CommitResult{}, fmt.Errorf("CHECK constraints can only call built-in functions, not %s", fn.Function)
+			}
+		}
+	}
+	if err := engine.checkFunctions(functions); err != nil {
+		return CommitResult{}, err
+	}
+
+	table := core.Table{
 		Database: statement.Database,
 		Name:     statement.Table,
 		Columns:  statement.Columns,
-	}, engine.Persistence, engine.Identity)
+		Fanout:   statement.Fanout,
+		Comment:  statement.Comment,
+		Checks:   statement.Checks,
+		Author:   statement.Author,
+	}
+
+	var txn *ps.Transaction
+	var err error
+	var records map[string][]byte
+	if len(statement.ValueRows) == 0 {
+		txn, _, err = op.CreateTable(table, engine.Persistence, engine.Identity)
+	} else {
+		// Seed rows are written in the same commit as the table
+		records, err = engine.seedRecords(table, statement.ValueRows)
+		if err != nil {
+			return CommitResult{}, err
+		}
+		opCount += len(records)
+		txn, _, err = op.CreateTableWithRecords(table, records, engine.Persistence, engine.Identity)
+	}
 	if err != nil {
 		return CommitResult{}, err
 	}
@@ -1399,13 +3004,42 @@ func (engine *Engine) executeCreateTableStatement(statement sql.CreateTableState
 		DatabasesDeleted: 0,
 		TablesCreated:    1,
 		TablesDeleted:    0,
-		RecordsWritten:   0,
+		RecordsWritten:   len(records),
 		RecordsDeleted:   0,
-		ExecutionTimeMs:  float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs:  elapsedMs(startTime),
 		ExecutionOps:     opCount,
 	}, nil
 }
 
+// seedRecords builds the stored records of CREATE TABLE ... VALUES rows,
+// which list every column in table order. As with INSERT, a later row with
+// the same primary key replaces an earlier one.
+func (engine *Engine) seedRecords(table core.Table, valueRows [][]string) (map[string][]byte, error) {
+	pk, err := (&op.TableOp{Table: table}).PrimaryKey()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]string, len(table.Columns))
+	for i, column := range table.Columns {
+		columns[i] = column.Name
+	}
+
+	records := make(map[string][]byte, len(valueRows))
+	for _, valueRow := range valueRows {
+		data, err := engine.insertRow(table, *pk, columns, valueRow)
+		if err != nil {
+			return nil, err
+		}
+		jsonData, err := marshalRow(data, table)
+		if err != nil {
+			return nil, err
+		}
+		records[data[*pk]] = jsonData
+	}
+	return records, nil
+}
+
 func (engine *Engine) executeDropTableStatement(statement sql.DropTableStatement) (CommitResult, error) {
 	startTime := time.Now()
 	opCount := 1
@@ -1413,19 +3047,26 @@ func (engine *Engine) executeDropTableStatement(statement sql.DropTableStatement
 	tableOp, err := op.GetTable(statement.Database, statement.Table, engine.Persistence)
 	if err != nil {
 		// If IF EXISTS was specified, don't error on missing table
-		if statement.IfExists {
+		if statement.IfExists && errors.Is(err, ps.ErrTableNotFound) {
 			return CommitResult{
-				ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+				ExecutionTimeMs: elapsedMs(startTime),
 				ExecutionOps:    opCount,
 			}, nil
 		}
 		return CommitResult{}, err
 	}
 
-	opCount++
-	txn, err := tableOp.DropTable(engine.Identity)
-	if err != nil {
-		return CommitResult{}, err
+	// A dry run reports the table and its rows without dropping them
+	txn := ps.Transaction{Unchanged: true}
+	recordsDeleted := 0
+	if statement.DryRun {
+		recordsDeleted = tableOp.Count()
+	} else {
+		opCount++
+		txn, err = tableOp.DropTable(engine.Identity)
+		if err != nil {
+			return CommitResult{}, err
+		}
 	}
 
 	return CommitResult{
@@ -1435,9 +3076,10 @@ func (engine *Engine) executeDropTableStatement(statement sql.DropTableStatement
 		TablesCreated:    0,
 		TablesDeleted:    1,
 		RecordsWritten:   0,
-		RecordsDeleted:   0,
-		ExecutionTimeMs:  float64(time.Since(startTime).Milliseconds()),
+		RecordsDeleted:   recordsDeleted,
+		ExecutionTimeMs:  elapsedMs(startTime),
 		ExecutionOps:     opCount,
+		DryRun:           statement.DryRun,
 	}, nil
 }
 
@@ -1458,7 +3100,7 @@ func (engine *Engine) executeCreateDatabaseStatement(statement sql.CreateDatabas
 		TablesDeleted:    0,
 		RecordsWritten:   0,
 		RecordsDeleted:   0,
-		ExecutionTimeMs:  float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs:  elapsedMs(startTime),
 		ExecutionOps:     opCount,
 	}, nil
 }
@@ -1470,19 +3112,29 @@ func (engine *Engine) executeDropDatabaseStatement(statement sql.DropDatabaseSta
 	databaseOp, err := op.GetDatabase(statement.Database, engine.Persistence)
 	if err != nil {
 		// If IF EXISTS was specified, don't error on missing database
-		if statement.IfExists {
+		if statement.IfExists && errors.Is(err, ps.ErrDatabaseNotFound) {
 			return CommitResult{
-				ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+				ExecutionTimeMs: elapsedMs(startTime),
 				ExecutionOps:    opCount,
 			}, nil
 		}
 		return CommitResult{}, err
 	}
 
-	opCount++
-	txn, err := databaseOp.DropDatabase(engine.Identity)
-	if err != nil {
-		return CommitResult{}, err
+	// A dry run reports the database's tables and rows without dropping them
+	txn := ps.Transaction{Unchanged: true}
+	tablesDeleted, recordsDeleted := 0, 0
+	if statement.DryRun {
+		for _, table := range engine.Persistence.ListTables(statement.Database) {
+			tablesDeleted++
+			recordsDeleted += len(engine.Persistence.ListRecordKeys(statement.Database, table))
+		}
+	} else {
+		opCount++
+		txn, err = databaseOp.DropDatabase(engine.Identity)
+		if err != nil {
+			return CommitResult{}, err
+		}
 	}
 
 	return CommitResult{
@@ -1490,11 +3142,33 @@ func (engine *Engine) executeDropDatabaseStatement(statement sql.DropDatabaseSta
 		DatabasesCreated: 0,
 		DatabasesDeleted: 1,
 		TablesCreated:    0,
-		TablesDeleted:    0,
+		TablesDeleted:    tablesDeleted,
 		RecordsWritten:   0,
-		RecordsDeleted:   0,
-		ExecutionTimeMs:  float64(time.Since(startTime).Milliseconds()),
+		RecordsDeleted:   recordsDeleted,
+		ExecutionTimeMs:  elapsedMs(startTime),
 		ExecutionOps:     opCount,
+		DryRun:           statement.DryRun,
+	}, nil
+}
+
+// executeAlterDatabaseStatement renames a database in one commit. View
+// queries and trigger bodies that name the database are rewritten to the new
+// name along with it.
+func (engine *Engine) executeAlterDatabaseStatement(statement sql.AlterDatabaseStatement) (CommitResult, error) {
+	startTime := time.Now()
+
+	rewrite := func(definition string) string {
+		return sql.RenameDatabaseReferences(definition, statement.Database, statement.NewName)
+	}
+	txn, err := engine.Persistence.RenameDatabase(statement.Database, statement.NewName, rewrite, engine.Identity)
+	if err != nil {
+		return CommitResult{}, err
+	}
+
+	return CommitResult{
+		Transaction:     txn,
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    1,
 	}, nil
 }
 
@@ -1514,7 +3188,7 @@ func (engine *Engine) executeShowDatabasesStatement(statement sql.ShowDatabasesS
 		Columns:         []string{"name"},
 		Data:            data,
 		RecordsRead:     len(databases),
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
 		ExecutionOps:    len(databases),
 	}, nil
 }
@@ -1535,11 +3209,74 @@ func (engine *Engine) executeShowTablesStatement(statement sql.ShowTablesStateme
 		Columns:         []string{"name"},
 		Data:            data,
 		RecordsRead:     len(tables),
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    len(tables),
+	}, nil
+}
+
+// executeShowTableStatusStatement reports each table's row count, record
+// storage size in bytes, the last commit that changed it and its comment.
+func (engine *Engine) executeShowTableStatusStatement(statement sql.ShowTableStatusStatement) (QueryResult, error) {
+	startTime := time.Now()
+
+	tables := engine.Persistence.ListTables(statement.Database)
+
+	data := make([][]string, len(tables))
+	for i, table := range tables {
+		stats, err := engine.Persistence.TableStats(statement.Database, table)
+		if err != nil {
+			return QueryResult{}, err
+		}
+		schema, err := engine.Persistence.GetTable(statement.Database, table)
+		if err != nil {
+			return QueryResult{}, err
+		}
+		data[i] = []string{
+			table,
+			strconv.Itoa(stats.Rows),
+			strconv.FormatInt(stats.Size, 10),
+			stats.LastModified.Id,
+			stats.LastModified.When.Format("2006-01-02 15:04:05"),
+			schema.Comment,
+		}
+	}
+
+	return QueryResult{
+		Transaction:     engine.Persistence.LatestTransaction(),
+		Columns:         []string{"Name", "Rows", "Size", "LastModified", "When", "Comment"},
+		Data:            data,
+		RecordsRead:     len(tables),
+		ExecutionTimeMs: elapsedMs(startTime),
 		ExecutionOps:    len(tables),
 	}, nil
 }
 
+// executeShowStatsStatement reports the repository's object counts, pack
+// size in bytes and number of commits as a single row.
+func (engine *Engine) executeShowStatsStatement() (QueryResult, error) {
+	startTime := time.Now()
+
+	stats, err := engine.Persistence.RepositoryStats()
+	if err != nil {
+		return QueryResult{}, err
+	}
+
+	return QueryResult{
+		Transaction: engine.Persistence.LatestTransaction(),
+		Columns:     []string{"Objects", "LooseObjects", "Packs", "PackSize", "Commits"},
+		Data: [][]string{{
+			strconv.Itoa(stats.Objects),
+			strconv.Itoa(stats.LooseObjects),
+			strconv.Itoa(stats.Packs),
+			strconv.FormatInt(stats.PackSize, 10),
+			strconv.Itoa(stats.Commits),
+		}},
+		RecordsRead:     1,
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    1,
+	}, nil
+}
+
 func (engine *Engine) executeCreateIndexStatement(statement sql.CreateIndexStatement) (CommitResult, error) {
 	startTime := time.Now()
 	opCount := 0
@@ -1547,7 +3284,7 @@ func (engine *Engine) executeCreateIndexStatement(statement sql.CreateIndexState
 	// Get table to scan existing data
 	tableOp, err := op.GetTable(statement.Database, statement.Table, engine.Persistence)
 	if err != nil {
-		return CommitResult{}, fmt.Errorf("table not found: %v", err)
+		return CommitResult{}, err
 	}
 
 	// Create index manager
@@ -1573,7 +3310,7 @@ func (engine *Engine) executeCreateIndexStatement(statement sql.CreateIndexState
 		}
 
 		if err := idx.Insert(columnValue, pk); err != nil {
-			return CommitResult{}, fmt.Errorf("failed to build index: %v", err)
+			return CommitResult{}, constraintErrorf(statement.Column, "failed to build index: %v", err)
 		}
 	}
 
@@ -1584,7 +3321,7 @@ func (engine *Engine) executeCreateIndexStatement(statement sql.CreateIndexState
 
 	return CommitResult{
 		Transaction:     engine.Persistence.LatestTransaction(),
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
 		ExecutionOps:    opCount,
 	}, nil
 }
@@ -1606,7 +3343,7 @@ func (engine *Engine) executeDropIndexStatement(statement sql.DropIndexStatement
 
 	return CommitResult{
 		Transaction:     engine.Persistence.LatestTransaction(),
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
 		ExecutionOps:    opCount,
 	}, nil
 }
@@ -1618,7 +3355,7 @@ func (engine *Engine) executeAlterTableStatement(statement sql.AlterTableStateme
 	// Get existing table
 	table, err := engine.Persistence.GetTable(statement.Database, statement.Table)
 	if err != nil {
-		return CommitResult{}, fmt.Errorf("table %s.%s does not exist", statement.Database, statement.Table)
+		return CommitResult{}, err
 	}
 
 	switch statement.Action {
@@ -1629,12 +3366,31 @@ func (engine *Engine) executeAlterTableStatement(statement sql.AlterTableStateme
 				return CommitResult{}, fmt.Errorf("column %s already exists", statement.ColumnName)
 			}
 		}
-		// Parse column type
-		colType := parseColumnType(statement.ColumnType)
-		table.Columns = append(table.Columns, core.Column{
-			Name: statement.ColumnName,
-			Type: colType,
-		})
+		// A former name taken by a new column no longer maps to the renamed one
+		if _, renamed := table.Renames[statement.ColumnName]; renamed {
+			moved, err := engine.retireRename(*table, statement.ColumnName)
+			if err != nil {
+				return CommitResult{}, err
+			}
+			opCount += moved
+		}
+		column := core.Column{
+			Name:    statement.ColumnName,
+			Type:    parseColumnType(statement.ColumnType),
+			NotNull: statement.NotNull,
+			Default: statement.Default,
+		}
+		// Existing rows take the DEFAULT; without one they would be NULL
+		if column.Default != nil {
+			filled, err := engine.fillColumn(*table, column)
+			if err != nil {
+				return CommitResult{}, err
+			}
+			opCount += filled
+		} else if column.NotNull && len(engine.Persistence.ListRecordKeys(statement.Database, statement.Table)) > 0 {
+			return CommitResult{}, constraintErrorf(column.Name, "cannot add NOT NULL column %s without a DEFAULT to a table that has rows", column.Name)
+		}
+		table.Columns = append(table.Columns, column)
 
 	case "DROP":
 		// Find and remove column
@@ -1689,6 +3445,27 @@ func (engine *Engine) executeAlterTableStatement(statement sql.AlterTableStateme
 		if !found {
 			return CommitResult{}, fmt.Errorf("column %s does not exist", statement.ColumnName)
 		}
+		if _, renamed := table.Renames[statement.NewColumnName]; renamed {
+			moved, err := engine.retireRename(*table, statement.NewColumnName)
+			if err != nil {
+				return CommitResult{}, err
+			}
+			opCount += moved
+		}
+
+		// Record the rename so existing rows map to the new name on read
+		if table.Renames == nil {
+			table.Renames = make(map[string]string)
+		}
+		for oldName, current := range table.Renames {
+			if current == statement.ColumnName {
+				table.Renames[oldName] = statement.NewColumnName
+			}
+		}
+		table.Renames[statement.ColumnName] = statement.NewColumnName
+
+	case "AUTHOR":
+		table.Author = statement.Author
 
 	default:
 		return CommitResult{}, fmt.Errorf("unknown ALTER action: %s", statement.Action)
@@ -1696,6 +3473,9 @@ func (engine *Engine) executeAlterTableStatement(statement sql.AlterTableStateme
 
 	// Update table schema
 	message := fmt.Sprintf("ALTER TABLE %s.%s %s COLUMN %s", statement.Database, statement.Table, statement.Action, statement.ColumnName)
+	if statement.Action == "AUTHOR" {
+		message = fmt.Sprintf("ALTER TABLE %s.%s SET AUTHOR", statement.Database, statement.Table)
+	}
 	txn, err := engine.Persistence.UpdateTable(*table, engine.Identity, message)
 	if err != nil {
 		return CommitResult{}, err
@@ -1704,11 +3484,306 @@ func (engine *Engine) executeAlterTableStatement(statement sql.AlterTableStateme
 	return CommitResult{
 		Transaction:     txn,
 		TablesAltered:   1,
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
 		ExecutionOps:    opCount,
 	}, nil
 }
 
+// storedValue validates a value written to a column and returns the form kept
+// in the row. JSON strings cannot hold arbitrary bytes, so BLOB values are
+// base64-encoded and every other column must be valid UTF-8. TIMESTAMP values
+// are kept in one canonical form whichever accepted format they arrive in.
+func storedValue(column string, colType core.ColumnType, value string) (string, error) {
+	switch colType {
+	case core.BlobType:
+		return base64.StdEncoding.EncodeToString([]byte(value)), nil
+	case core.TimestampType:
+		return canonicalTimestamp(column, expandNow(value, colType))
+	}
+	if !utf8.ValidString(value) {
+		return "", constraintErrorf(column, "invalid UTF-8 in column %s (use a BLOB column for binary data)", column)
+	}
+	return value, nil
+}
+
+// decodeBlobs replaces the stored base64 form of BLOB columns with their bytes.
+// Values that are not valid base64, e.g. written before a MODIFY COLUMN to
+// BLOB, are left as they are.
+func decodeBlobs(row map[string]string, table core.Table) {
+	for _, col := range table.Columns {
+		if col.Type != core.BlobType {
+			continue
+		}
+		if value, ok := row[col.Name]; ok {
+			if raw, err := base64.StdEncoding.DecodeString(value); err == nil {
+				row[col.Name] = string(raw)
+			}
+		}
+	}
+}
+
+// columnTypeName returns the SQL name of a column type, as DESCRIBE shows it.
+func columnTypeName(colType core.ColumnType) string {
+	switch colType {
+	case core.StringType:
+		return "STRING"
+	case core.IntType:
+		return "INT"
+	case core.FloatType:
+		return "FLOAT"
+	case core.BoolType:
+		return "BOOL"
+	case core.TextType:
+		return "TEXT"
+	case core.DateType:
+		return "DATE"
+	case core.TimestampType:
+		return "TIMESTAMP"
+	case core.JsonType:
+		return "JSON"
+	case core.BlobType:
+		return "BLOB"
+	}
+	return ""
+}
+
+// columnType returns the type of the named column, defaulting to STRING.
+func columnType(table core.Table, name string) core.ColumnType {
+	for _, col := range table.Columns {
+		if col.Name == name {
+			return col.Type
+		}
+	}
+	return core.StringType
+}
+
+// isBlobColumn reports whether the named column is a BLOB.
+func isBlobColumn(table core.Table, name string) bool {
+	return columnType(table, name) == core.BlobType
+}
+
+// author returns the identity row writes to table are committed as: the
+// table's AUTHOR when it has one and the engine's identity is implicit,
+// otherwise the engine's identity.
+func (engine *Engine) author(table core.Table) core.Identity {
+	if table.Author != nil && engine.ImplicitIdentity {
+		return *table.Author
+	}
+	return engine.Identity
+}
+
+// isNotNullColumn reports whether the named column is declared NOT NULL.
+func isNotNullColumn(table core.Table, name string) bool {
+	for _, col := range table.Columns {
+		if col.Name == name {
+			return col.NotNull
+		}
+	}
+	return false
+}
+
+// normalizeRow maps values stored under renamed column names onto the
+// current names. It reports whether the row was changed.
+func normalizeRow(row map[string]string, table core.Table) bool {
+	changed := false
+	for oldName, current := range table.Renames {
+		value, exists := row[oldName]
+		if !exists {
+			continue
+		}
+		if _, hasCurrent := row[current]; !hasCurrent {
+			row[current] = value
+		}
+		delete(row, oldName)
+		changed = true
+	}
+	return changed
+}
+
+// retireRename removes oldName from table.Renames, before a column takes that
+// name again. Rows still holding values under oldName are rewritten first,
+// in one commit, with the values under the column's current name, so they
+// are not read as values of the new column. It returns the rows rewritten.
+func (engine *Engine) retireRename(table core.Table, oldName string) (int, error) {
+	current := table.Renames[oldName]
+	delete(table.Renames, oldName)
+
+	tableOp, err := op.GetTable(table.Database, table.Name, engine.Persistence)
+	if err != nil {
+		return 0, err
+	}
+	moved := make(map[string][]byte)
+	for key, rawData := range tableOp.Scan() {
+		var row map[string]string
+		if err := json.Unmarshal(rawData, &row); err != nil {
+			return 0, fmt.Errorf("row %s is not valid JSON: %w", key, err)
+		}
+		value, exists := row[oldName]
+		if !exists {
+			continue
+		}
+		if _, hasCurrent := row[current]; !hasCurrent {
+			row[current] = value
+		}
+		delete(row, oldName)
+		data, err := marshalRow(row, table)
+		if err != nil {
+			return 0, err
+		}
+		moved[key] = data
+	}
+	if len(moved) > 0 {
+		if _, err := engine.Persistence.SaveRecord(table.Database, table.Name, moved, engine.Identity); err != nil {
+			return 0, err
+		}
+	}
+	return len(moved), nil
+}
+
+// fillColumn sets column, which table is gaining, to its DEFAULT value in
+// every existing row, in one commit. It returns the rows rewritten.
+func (engine *Engine) fillColumn(table core.Table, column core.Column) (int, error) {
+	table.Columns = append(slices.Clip(table.Columns), column)
+	if err := checkColumnValue(table, column.Name, *column.Default); err != nil {
+		return 0, err
+	}
+	value, err := storedValue(column.Name, column.Type, *column.Default)
+	if err != nil {
+		return 0, err
+	}
+
+	tableOp, err := op.GetTable(table.Database, table.Name, engine.Persistence)
+	if err != nil {
+		return 0, err
+	}
+	filled := make(map[string][]byte)
+	for key, rawData := range tableOp.Scan() {
+		var row map[string]string
+		if err := json.Unmarshal(rawData, &row); err != nil {
+			return 0, fmt.Errorf("row %s is not valid JSON: %w", key, err)
+		}
+		row[column.Name] = value
+		data, err := marshalRow(row, table)
+		if err != nil {
+			return 0, err
+		}
+		filled[key] = data
+	}
+	if len(filled) > 0 {
+		if _, err := engine.Persistence.SaveRecord(table.Database, table.Name, filled, engine.Identity); err != nil {
+			return 0, err
+		}
+	}
+	return len(filled), nil
+}
+
+// marshalRow encodes a row for storage as one line of JSON with its keys in
+// the table's column order, so a row reads in schema order and fields keep
+// their places when it is rewritten. A line diff shows the whole row changed;
+// a token diff such as git diff --word-diff-regex='[^,:{}]+' shows just the
+// changed values. Keys that are not columns follow in sorted order.
+func marshalRow(row map[string]string, table core.Table) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	written := 0
+	writeField := func(name, value string) error {
+		if written > 0 {
+			buf.WriteByte(',')
+		}
+		written++
+		encodedName, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+		encodedValue, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		buf.Write(encodedName)
+		buf.WriteByte(':')
+		buf.Write(encodedValue)
+		return nil
+	}
+
+	for _, column := range table.Columns {
+		if value, ok := row[column.Name]; ok {
+			if err := writeField(column.Name, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(row)) {
+		if slices.ContainsFunc(table.Columns, func(column core.Column) bool { return column.Name == name }) {
+			continue
+		}
+		if err := writeField(name, row[name]); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// executeRepairTableStatement rewrites rows whose stored keys drifted from the
+// schema: renamed columns are moved to their current names and keys of dropped
+// columns are removed. Missing columns are NULL and stay absent. All repaired
+// rows are written in a single commit.
+func (engine *Engine) executeRepairTableStatement(statement sql.RepairTableStatement) (CommitResult, error) {
+	startTime := time.Now()
+
+	tableOp, err := op.GetTable(statement.Database, statement.Table, engine.Persistence)
+	if err != nil {
+		return CommitResult{}, err
+	}
+
+	schema := make(map[string]bool, len(tableOp.Table.Columns))
+	for _, col := range tableOp.Table.Columns {
+		schema[col.Name] = true
+	}
+
+	rowsScanned := 0
+	repaired := make(map[string][]byte)
+	for key, rawData := range tableOp.Scan() {
+		rowsScanned++
+
+		var row map[string]string
+		if err := json.Unmarshal(rawData, &row); err != nil {
+			return CommitResult{}, fmt.Errorf("row %s is not valid JSON: %w", key, err)
+		}
+
+		changed := normalizeRow(row, tableOp.Table)
+		for name := range row {
+			if !schema[name] {
+				delete(row, name)
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		data, err := marshalRow(row, tableOp.Table)
+		if err != nil {
+			return CommitResult{}, err
+		}
+		repaired[key] = data
+	}
+
+	result := CommitResult{
+		RecordsWritten: len(repaired),
+		ExecutionOps:   rowsScanned,
+	}
+	if len(repaired) > 0 {
+		txn, err := engine.Persistence.SaveRecord(statement.Database, statement.Table, repaired, engine.Identity)
+		if err != nil {
+			return CommitResult{}, err
+		}
+		result.Transaction = txn
+	}
+	result.ExecutionTimeMs = elapsedMs(startTime)
+	return result, nil
+}
+
 // parseColumnType converts string type to core.ColumnType
 func parseColumnType(typeName string) core.ColumnType {
 	switch strings.ToUpper(typeName) {
@@ -1728,49 +3803,224 @@ func parseColumnType(typeName string) core.ColumnType {
 		return core.TimestampType
 	case "JSON":
 		return core.JsonType
+	case "BLOB", "BINARY":
+		return core.BlobType
 	default:
 		return core.StringType
 	}
 }
 
-func (engine *Engine) executeBeginStatement() (CommitResult, error) {
+// executeBeginStatement opens a transaction. Until COMMIT, record writes to
+// any table of any database are buffered in a session private to the engine
+// instead of committed, so they land in a single commit or are discarded
+// together by ROLLBACK. Other engines on the same persistence neither see nor
+// take part in them. BEGIN READ ONLY pins reads to the current HEAD until
+// COMMIT or ROLLBACK releases it.
+func (engine *Engine) executeBeginStatement(statement sql.BeginStatement) (CommitResult, error) {
+	startTime := time.Now()
+
+	if engine.transaction != nil {
+		return CommitResult{}, errors.New("a transaction is already in progress")
+	}
+
+	if statement.ReadOnly {
+		snapshot := engine.Persistence.LatestTransaction()
+		engine.transaction = &transaction{readOnly: true, snapshot: snapshot.Id}
+		return CommitResult{
+			Transaction:     snapshot,
+			ExecutionTimeMs: elapsedMs(startTime),
+			ExecutionOps:    1,
+		}, nil
+	}
+
+	// Earlier buffered writes of this engine are not part of the transaction
+	if _, err := engine.Persistence.Flush(); err != nil {
+		return CommitResult{}, err
+	}
+
+	engine.transaction = &transaction{base: engine.Persistence}
+	engine.Persistence = engine.Persistence.Session()
+	engine.Persistence.EnableWriteBehind(ps.WriteBehind{})
+
+	return CommitResult{
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    1,
+	}, nil
+}
+
+// executeCommitStatement commits the writes of the open transaction as one
+// commit and leaves the tables it wrote for refreshWritten, so the views over
+// them refresh once the running statement ends. Without an open transaction
+// every write is already committed.
+func (engine *Engine) executeCommitStatement() (CommitResult, error) {
+	startTime := time.Now()
+
+	if engine.transaction == nil || engine.transaction.readOnly {
+		engine.transaction = nil
+		return CommitResult{
+			Transaction:     engine.Persistence.LatestTransaction(),
+			ExecutionTimeMs: elapsedMs(startTime),
+			ExecutionOps:    1,
+		}, nil
+	}
+
+	pending := engine.Persistence.PendingWrites()
+	txn, err := engine.Persistence.Flush()
+	if err != nil {
+		return CommitResult{}, err
+	}
+	if txn.Unchanged {
+		pending = 0
+	}
+	written := engine.transaction.written
+	engine.endTransaction()
+
+	for _, name := range written {
+		engine.written = addWritten(engine.written, name)
+	}
+	return CommitResult{
+		Transaction:     txn,
+		RecordsWritten:  pending,
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    1,
+	}, nil
+}
+
+// executeRollbackStatement discards the writes of the open transaction.
+// Without an open transaction there is nothing to roll back. ROLLBACK TO
+// only discards the writes made since the savepoint, which stays set, and
+// forgets the savepoints set after it; the transaction stays open.
+func (engine *Engine) executeRollbackStatement(statement sql.RollbackStatement) (CommitResult, error) {
+	startTime := time.Now()
+
+	if statement.Savepoint == "" {
+		if engine.transaction != nil {
+			engine.endTransaction()
+		}
+		return CommitResult{
+			ExecutionTimeMs: elapsedMs(startTime),
+			ExecutionOps:    1,
+		}, nil
+	}
+
+	i, err := engine.findSavepoint(statement.Savepoint)
+	if err != nil {
+		return CommitResult{}, err
+	}
+	engine.transaction.savepoints = engine.transaction.savepoints[:i+1]
+	engine.Persistence.RollbackWrites(engine.transaction.savepoints[i].mark)
+
+	return CommitResult{
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    1,
+	}, nil
+}
+
+// executeSavepointStatement sets a savepoint in the open transaction.
+// Reusing a name moves the savepoint, as in ps.TransactionBuilder.
+func (engine *Engine) executeSavepointStatement(statement sql.SavepointStatement) (CommitResult, error) {
+	startTime := time.Now()
+
+	if engine.transaction == nil {
+		return CommitResult{}, errors.New("SAVEPOINT can only be used in a transaction")
+	}
+	engine.transaction.savepoints = slices.DeleteFunc(engine.transaction.savepoints, func(sp savepoint) bool {
+		return strings.EqualFold(sp.name, statement.Name)
+	})
+	engine.transaction.savepoints = append(engine.transaction.savepoints, savepoint{
+		name: statement.Name,
+		mark: engine.Persistence.MarkWrites(),
+	})
+
+	return CommitResult{
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    1,
+	}, nil
+}
+
+// executeReleaseSavepointStatement forgets a savepoint and the savepoints
+// set after it. Their writes stay part of the transaction.
+func (engine *Engine) executeReleaseSavepointStatement(statement sql.ReleaseSavepointStatement) (CommitResult, error) {
 	startTime := time.Now()
 
-	// Create a new transaction builder
-	_, err := engine.Persistence.BeginTransaction()
+	i, err := engine.findSavepoint(statement.Name)
 	if err != nil {
 		return CommitResult{}, err
 	}
+	engine.transaction.savepoints = engine.transaction.savepoints[:i]
 
 	return CommitResult{
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
 		ExecutionOps:    1,
 	}, nil
 }
 
-func (engine *Engine) executeCommitStatement() (CommitResult, error) {
-	startTime := time.Now()
-
-	// Note: In a full implementation, we'd track the current transaction and commit it
-	// For now, this is a no-op since each statement auto-commits
+// findSavepoint returns the position of the savepoint named name in the
+// open transaction.
+func (engine *Engine) findSavepoint(name string) (int, error) {
+	if engine.transaction == nil {
+		return 0, errors.New("no transaction is in progress")
+	}
+	for i := len(engine.transaction.savepoints) - 1; i >= 0; i-- {
+		if strings.EqualFold(engine.transaction.savepoints[i].name, name) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("savepoint %s does not exist", name)
+}
 
-	return CommitResult{
-		Transaction:     engine.Persistence.LatestTransaction(),
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
-		ExecutionOps:    1,
-	}, nil
+// readOnlyStatement reports whether a statement may run in a READ ONLY
+// transaction: it reads data or settings, or ends the transaction or sets
+// savepoints in it.
+func readOnlyStatement(statement sql.Statement) bool {
+	switch statement.Type() {
+	case sql.SelectStatementType, sql.CommitStatementType, sql.RollbackStatementType, sql.BeginStatementType,
+		sql.DescribeStatementType, sql.ShowDatabasesStatementType, sql.ShowTablesStatementType,
+		sql.ShowIndexesStatementType, sql.ShowBranchesStatementType, sql.ShowMergeBaseStatementType,
+		sql.ShowMergeConflictsStatementType, sql.ShowRemotesStatementType, sql.ShowSharesStatementType,
+		sql.ShowViewsStatementType, sql.ShowCreateTableStatementType, sql.ShowCreateViewStatementType, sql.ShowTableStatusStatementType, sql.ShowTriggersStatementType,
+		sql.ShowWarningsStatementType, sql.ShowVariablesStatementType, sql.ShowStatsStatementType, sql.SetVariableStatementType,
+		sql.CheckDatabaseStatementType, sql.SavepointStatementType, sql.ReleaseSavepointStatementType:
+		return true
+	}
+	return false
 }
 
-func (engine *Engine) executeRollbackStatement() (CommitResult, error) {
-	startTime := time.Now()
+// transactionalStatement reports whether a statement may write inside a
+// BEGIN block. Schema changes, view refreshes and the like commit on their
+// own, which would commit the block's buffered writes with them.
+func transactionalStatement(statement sql.Statement) bool {
+	switch statement.Type() {
+	case sql.InsertStatementType, sql.UpdateStatementType, sql.DeleteStatementType, sql.CopyStatementType:
+		return true
+	}
+	return false
+}
 
-	// Note: In a full implementation, we'd track the current transaction and rollback
-	// For now, this is a no-op
+// pinSnapshot reads a SELECT as of the snapshot of an open READ ONLY
+// transaction. Queries with their own AS OF, or that read a share, whose
+// commits the snapshot does not name, are left as they are.
+func (engine *Engine) pinSnapshot(statement sql.SelectStatement) sql.SelectStatement {
+	if engine.transaction == nil || engine.transaction.snapshot == "" || statement.AsOf != "" || statement.Share != "" {
+		return statement
+	}
+	for _, join := range statement.Joins {
+		if join.Share != "" {
+			return statement
+		}
+	}
+	statement.AsOf = engine.transaction.snapshot
+	return statement
+}
 
-	return CommitResult{
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
-		ExecutionOps:    1,
-	}, nil
+// endTransaction closes the open transaction, dropping whatever its session
+// still buffers, and returns the engine to its own persistence.
+func (engine *Engine) endTransaction() {
+	txn := engine.transaction
+	engine.transaction = nil
+	if txn.base != nil {
+		engine.Persistence = txn.base
+	}
 }
 
 func (engine *Engine) executeDescribeStatement(statement sql.DescribeStatement) (QueryResult, error) {
@@ -1785,61 +4035,144 @@ func (engine *Engine) executeDescribeStatement(statement sql.DescribeStatement)
 	// Build column info
 	var data [][]string
 	for _, col := range tableOp.Table.Columns {
-		typeStr := ""
-		switch col.Type {
-		case core.StringType:
-			typeStr = "STRING"
-		case core.IntType:
-			typeStr = "INT"
-		case core.FloatType:
-			typeStr = "FLOAT"
-		case core.BoolType:
-			typeStr = "BOOL"
-		case core.TextType:
-			typeStr = "TEXT"
-		case core.DateType:
-			typeStr = "DATE"
-		case core.TimestampType:
-			typeStr = "TIMESTAMP"
-		case core.JsonType:
-			typeStr = "JSON"
-		}
+		typeStr := columnTypeName(col.Type)
 
 		pkStr := "NO"
 		if col.PrimaryKey {
 			pkStr = "YES"
 		}
 
-		data = append(data, []string{col.Name, typeStr, pkStr})
+		data = append(data, []string{col.Name, typeStr, pkStr, col.Comment})
 	}
 
 	return QueryResult{
 		Transaction:     engine.Persistence.LatestTransaction(),
-		Columns:         []string{"Column", "Type", "PrimaryKey"},
+		Columns:         []string{"Column", "Type", "PrimaryKey", "Comment"},
 		Data:            data,
 		RecordsRead:     len(data),
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
 		ExecutionOps:    opCount,
 	}, nil
 }
 
+// executeShowCreateTableStatement rebuilds the CREATE TABLE statement for a
+// table's stored schema: its columns with their constraints and comments,
+// CHECK constraints, table comment, author and fanout. Indexes are created
+// separately and listed by SHOW INDEXES.
+func (engine *Engine) executeShowCreateTableStatement(statement sql.ShowCreateTableStatement) (QueryResult, error) {
+	startTime := time.Now()
+
+	table, err := engine.Persistence.GetTable(statement.Database, statement.Table)
+	if err != nil {
+		return QueryResult{}, err
+	}
+
+	var definitions []string
+	for _, col := range table.Columns {
+		definition := col.Name + " " + columnTypeName(col.Type)
+		if col.PrimaryKey {
+			definition += " PRIMARY KEY"
+		}
+		if col.NotNull {
+			definition += " NOT NULL"
+		}
+		if col.DefaultExpr != "" {
+			definition += " DEFAULT " + col.DefaultExpr
+		} else if col.Default != nil {
+			definition += " DEFAULT " + quoteSQLString(*col.Default)
+		}
+		if col.Comment != "" {
+			definition += " COMMENT " + quoteSQLString(col.Comment)
+		}
+		definitions = append(definitions, definition)
+	}
+	for _, check := range table.Checks {
+		definitions = append(definitions, "CHECK ("+check+")")
+	}
+
+	create := fmt.Sprintf("CREATE TABLE %s.%s (%s)", table.Database, table.Name, strings.Join(definitions, ", "))
+	if table.Comment != "" {
+		create += " COMMENT " + quoteSQLString(table.Comment)
+	}
+	if table.Author != nil {
+		create += " AUTHOR " + quoteSQLString(fmt.Sprintf("%s <%s>", table.Author.Name, table.Author.Email))
+	}
+	if table.Fanout > 0 {
+		create += fmt.Sprintf(" WITH FANOUT %d", table.Fanout)
+	}
+
+	return QueryResult{
+		Transaction:     engine.Persistence.LatestTransaction(),
+		Columns:         []string{"name", "create_statement"},
+		Data:            [][]string{{table.Name, create}},
+		RecordsRead:     1,
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    1,
+	}, nil
+}
+
+// quoteSQLString quotes value as a SQL string literal, doubling its quotes
+func quoteSQLString(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
 func (engine *Engine) executeShowIndexesStatement(statement sql.ShowIndexesStatement) (QueryResult, error) {
 	startTime := time.Now()
 
-	// Get table to find columns
-	tableOp, err := op.GetTable(statement.Database, statement.Table, engine.Persistence)
+	// SHOW INDEXES IN database lists every table's indexes with the table name
+	if statement.Table == "" {
+		var data [][]string
+		for _, table := range engine.Persistence.ListTables(statement.Database) {
+			rows, err := engine.tableIndexRows(statement.Database, table)
+			if err != nil {
+				return QueryResult{}, err
+			}
+			for _, row := range rows {
+				data = append(data, append([]string{table}, row...))
+			}
+		}
+		return QueryResult{
+			Transaction:     engine.Persistence.LatestTransaction(),
+			Columns:         []string{"Table", "Name", "Column", "Unique"},
+			Data:            data,
+			RecordsRead:     len(data),
+			ExecutionTimeMs: elapsedMs(startTime),
+			ExecutionOps:    len(data),
+		}, nil
+	}
+
+	data, err := engine.tableIndexRows(statement.Database, statement.Table)
 	if err != nil {
 		return QueryResult{}, err
 	}
 
+	return QueryResult{
+		Transaction:     engine.Persistence.LatestTransaction(),
+		Columns:         []string{"Name", "Column", "Unique"},
+		Data:            data,
+		RecordsRead:     len(data),
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    len(data),
+	}, nil
+}
+
+// tableIndexRows returns the name, column and uniqueness of each index on
+// a table, in column order
+func (engine *Engine) tableIndexRows(database, table string) ([][]string, error) {
+	// Get table to find columns
+	tableOp, err := op.GetTable(database, table, engine.Persistence)
+	if err != nil {
+		return nil, err
+	}
+
 	// Load indexes
 	indexManager := ps.NewIndexManager(engine.Persistence, engine.Identity)
-	indexManager.LoadIndexes(statement.Database, statement.Table, tableOp.Table.Columns)
+	indexManager.LoadIndexes(database, table, tableOp.Table.Columns)
 
 	// Build index info
 	var data [][]string
 	for _, col := range tableOp.Table.Columns {
-		idx, exists := indexManager.GetIndex(statement.Database, statement.Table, col.Name)
+		idx, exists := indexManager.GetIndex(database, table, col.Name)
 		if exists {
 			uniqueStr := "NO"
 			if idx.Unique {
@@ -1848,15 +4181,7 @@ func (engine *Engine) executeShowIndexesStatement(statement sql.ShowIndexesState
 			data = append(data, []string{idx.Name, col.Name, uniqueStr})
 		}
 	}
-
-	return QueryResult{
-		Transaction:     engine.Persistence.LatestTransaction(),
-		Columns:         []string{"Name", "Column", "Unique"},
-		Data:            data,
-		RecordsRead:     len(data),
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
-		ExecutionOps:    len(data),
-	}, nil
+	return data, nil
 }
 
 // Branching execution methods
@@ -1876,7 +4201,7 @@ func (engine *Engine) executeCreateBranchStatement(statement sql.CreateBranchSta
 
 	return CommitResult{
 		Transaction:     engine.Persistence.LatestTransaction(),
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
 		ExecutionOps:    1,
 	}, nil
 }
@@ -1891,7 +4216,7 @@ func (engine *Engine) executeCheckoutStatement(statement sql.CheckoutStatement)
 
 	return CommitResult{
 		Transaction:     engine.Persistence.LatestTransaction(),
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
 		ExecutionOps:    1,
 	}, nil
 }
@@ -1926,13 +4251,15 @@ func (engine *Engine) executeMergeStatement(statement sql.MergeStatement) (Resul
 			Columns:         []string{"Database", "Table", "Key", "HEAD", "SOURCE"},
 			Data:            data,
 			RecordsRead:     len(data),
-			ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+			TotalRecords:    len(data),
+			ExecutionTimeMs: elapsedMs(startTime),
+			ExecutionOps:    len(data),
 		}, nil
 	}
 
 	return CommitResult{
 		Transaction:     result.Transaction,
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
 		ExecutionOps:    1,
 	}, nil
 }
@@ -1961,12 +4288,34 @@ func (engine *Engine) executeShowBranchesStatement(statement sql.ShowBranchesSta
 		Columns:         []string{"Branch", "Current"},
 		Data:            data,
 		RecordsRead:     len(data),
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
 		ExecutionOps:    len(data),
 	}, nil
 }
 
-func (engine *Engine) executeShowMergeConflictsStatement() (QueryResult, error) {
+// executeShowMergeBaseStatement reports the common ancestor a merge of the two
+// branches would start from.
+func (engine *Engine) executeShowMergeBaseStatement(statement sql.ShowMergeBaseStatement) (QueryResult, error) {
+	startTime := time.Now()
+
+	base, err := engine.Persistence.MergeBase(statement.BranchA, statement.BranchB)
+	if err != nil {
+		return QueryResult{}, err
+	}
+
+	return QueryResult{
+		Columns:         []string{"Commit", "When", "Author"},
+		Data:            [][]string{{base.Id, base.When.Format("2006-01-02 15:04:05"), base.Author}},
+		RecordsRead:     1,
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    1,
+	}, nil
+}
+
+// executeShowMergeConflictsStatement lists unresolved conflicts of the pending
+// merge, one page at a time when LIMIT/OFFSET is given. TotalRecords always
+// reports the number of unresolved conflicts.
+func (engine *Engine) executeShowMergeConflictsStatement(statement sql.ShowMergeConflictsStatement) (QueryResult, error) {
 	startTime := time.Now()
 
 	pending := engine.Persistence.GetPendingMerge()
@@ -1975,12 +4324,23 @@ func (engine *Engine) executeShowMergeConflictsStatement() (QueryResult, error)
 			Columns:         []string{"Database", "Table", "Key", "HEAD", "SOURCE"},
 			Data:            [][]string{},
 			RecordsRead:     0,
-			ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+			ExecutionTimeMs: elapsedMs(startTime),
+			ExecutionOps:    0,
 		}, nil
 	}
 
-	data := make([][]string, len(pending.Unresolved))
-	for i, conflict := range pending.Unresolved {
+	conflicts := pending.Unresolved
+	if statement.Offset >= len(conflicts) {
+		conflicts = nil
+	} else {
+		conflicts = conflicts[statement.Offset:]
+	}
+	if statement.Limit > 0 && len(conflicts) > statement.Limit {
+		conflicts = conflicts[:statement.Limit]
+	}
+
+	data := make([][]string, len(conflicts))
+	for i, conflict := range conflicts {
 		data[i] = []string{
 			conflict.Database,
 			conflict.Table,
@@ -1994,7 +4354,9 @@ func (engine *Engine) executeShowMergeConflictsStatement() (QueryResult, error)
 		Columns:         []string{"Database", "Table", "Key", "HEAD", "SOURCE"},
 		Data:            data,
 		RecordsRead:     len(data),
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		TotalRecords:    len(pending.Unresolved),
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    len(data),
 	}, nil
 }
 
@@ -2006,6 +4368,10 @@ func (engine *Engine) executeResolveConflictStatement(statement sql.ResolveConfl
 		return QueryResult{}, fmt.Errorf("no pending merge")
 	}
 
+	if statement.All {
+		return engine.resolveAllConflicts(pending, statement.Resolution, startTime)
+	}
+
 	var resolution []byte
 	switch statement.Resolution {
 	case "HEAD":
@@ -2039,7 +4405,33 @@ func (engine *Engine) executeResolveConflictStatement(statement sql.ResolveConfl
 		Columns:         []string{"Resolved", "Remaining"},
 		Data:            [][]string{{fmt.Sprintf("%s.%s.%s", statement.Database, statement.Table, statement.Key), fmt.Sprintf("%d", remaining)}},
 		RecordsRead:     1,
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    1,
+	}, nil
+}
+
+// resolveAllConflicts resolves every unresolved conflict with the HEAD or
+// SOURCE side.
+func (engine *Engine) resolveAllConflicts(pending *ps.PendingMerge, side string, startTime time.Time) (QueryResult, error) {
+	// ResolveConflict removes entries from Unresolved, so iterate over a copy
+	conflicts := append([]ps.RecordConflict{}, pending.Unresolved...)
+	for _, c := range conflicts {
+		resolution := c.HeadVal
+		if side == "SOURCE" {
+			resolution = c.SourceVal
+		}
+		if err := engine.Persistence.ResolveConflict(c.Database, c.Table, c.Key, resolution); err != nil {
+			return QueryResult{}, err
+		}
+	}
+
+	remaining := len(engine.Persistence.GetPendingMerge().Unresolved)
+	return QueryResult{
+		Columns:         []string{"Resolved", "Remaining"},
+		Data:            [][]string{{fmt.Sprintf("%d", len(conflicts)), fmt.Sprintf("%d", remaining)}},
+		RecordsRead:     1,
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    len(conflicts),
 	}, nil
 }
 
@@ -2053,7 +4445,7 @@ func (engine *Engine) executeCommitMergeStatement() (CommitResult, error) {
 
 	return CommitResult{
 		Transaction:     txn,
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
 		ExecutionOps:    1,
 	}, nil
 }
@@ -2070,7 +4462,32 @@ func (engine *Engine) executeAbortMergeStatement() (QueryResult, error) {
 		Columns:         []string{"Status"},
 		Data:            [][]string{{"Merge aborted"}},
 		RecordsRead:     1,
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    1,
+	}, nil
+}
+
+// executeFlushStatement commits the writes buffered by persistence
+// write-behind; without write-behind there is nothing to flush.
+func (engine *Engine) executeFlushStatement() (CommitResult, error) {
+	startTime := time.Now()
+
+	deleted := engine.Persistence.PendingDeletes()
+	written := engine.Persistence.PendingWrites() - deleted
+	txn, err := engine.Persistence.Flush()
+	if err != nil {
+		return CommitResult{}, err
+	}
+	if txn.Unchanged {
+		written, deleted = 0, 0
+	}
+
+	return CommitResult{
+		Transaction:     txn,
+		RecordsWritten:  written,
+		RecordsDeleted:  deleted,
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    1,
 	}, nil
 }
 
@@ -2086,7 +4503,8 @@ func (engine *Engine) executeAddRemoteStatement(statement sql.AddRemoteStatement
 		Columns:         []string{"Status"},
 		Data:            [][]string{{fmt.Sprintf("Remote '%s' added", statement.Name)}},
 		RecordsRead:     1,
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    1,
 	}, nil
 }
 
@@ -2108,7 +4526,8 @@ func (engine *Engine) executeShowRemotesStatement() (QueryResult, error) {
 		Columns:         []string{"Name", "URLs"},
 		Data:            data,
 		RecordsRead:     len(remotes),
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    len(remotes),
 	}, nil
 }
 
@@ -2124,7 +4543,8 @@ func (engine *Engine) executeDropRemoteStatement(statement sql.DropRemoteStateme
 		Columns:         []string{"Status"},
 		Data:            [][]string{{fmt.Sprintf("Remote '%s' removed", statement.Name)}},
 		RecordsRead:     1,
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    1,
 	}, nil
 }
 
@@ -2141,7 +4561,8 @@ func (engine *Engine) executePushStatement(statement sql.PushStatement) (QueryRe
 		Columns:         []string{"Status"},
 		Data:            [][]string{{fmt.Sprintf("Pushed to '%s'", statement.Remote)}},
 		RecordsRead:     1,
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    1,
 	}, nil
 }
 
@@ -2158,7 +4579,8 @@ func (engine *Engine) executePullStatement(statement sql.PullStatement) (QueryRe
 		Columns:         []string{"Status"},
 		Data:            [][]string{{fmt.Sprintf("Pulled from '%s'", statement.Remote)}},
 		RecordsRead:     1,
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    1,
 	}, nil
 }
 
@@ -2175,7 +4597,8 @@ func (engine *Engine) executeFetchStatement(statement sql.FetchStatement) (Query
 		Columns:         []string{"Status"},
 		Data:            [][]string{{fmt.Sprintf("Fetched from '%s'", statement.Remote)}},
 		RecordsRead:     1,
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    1,
 	}, nil
 }
 
@@ -2226,7 +4649,8 @@ func (engine *Engine) executeCreateShareStatement(statement sql.CreateShareState
 		Columns:         []string{"Status"},
 		Data:            [][]string{{fmt.Sprintf("Share '%s' created from '%s'", statement.Name, statement.URL)}},
 		RecordsRead:     1,
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    1,
 	}, nil
 }
 
@@ -2243,7 +4667,8 @@ func (engine *Engine) executeSyncShareStatement(statement sql.SyncShareStatement
 		Columns:         []string{"Status"},
 		Data:            [][]string{{fmt.Sprintf("Share '%s' synced", statement.Name)}},
 		RecordsRead:     1,
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    1,
 	}, nil
 }
 
@@ -2259,7 +4684,8 @@ func (engine *Engine) executeDropShareStatement(statement sql.DropShareStatement
 		Columns:         []string{"Status"},
 		Data:            [][]string{{fmt.Sprintf("Share '%s' dropped", statement.Name)}},
 		RecordsRead:     1,
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    1,
 	}, nil
 }
 
@@ -2280,27 +4706,47 @@ func (engine *Engine) executeShowSharesStatement() (QueryResult, error) {
 		Columns:         []string{"Name", "URL"},
 		Data:            data,
 		RecordsRead:     len(shares),
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    len(shares),
 	}, nil
 }
 
 // executeCopyStatement handles COPY INTO for bulk CSV import/export
-func (engine *Engine) executeCopyStatement(statement sql.CopyStatement) (Result, error) {
+func (engine *Engine) executeCopyStatement(ctx context.Context, statement sql.CopyStatement) (Result, error) {
 	startTime := time.Now()
 
 	if statement.Direction == "INTO_TABLE" {
-		// Import: Read CSV file into table
-		return engine.executeCopyIntoTable(statement, startTime)
+		// Import: Read CSV or JSON file into table
+		if statement.Format == "PARQUET" {
+			return nil, errors.New("COPY cannot import PARQUET files; PARQUET is an export format")
+		}
+		return engine.executeCopyIntoTable(ctx, statement, startTime)
 	} else if statement.Direction == "INTO_FILE" {
-		// Export: Write table to CSV file
-		return engine.executeCopyIntoFile(statement, startTime)
+		// Export: Write a table or query result to CSV or Parquet file
+		if statement.Format == "JSON" {
+			return nil, errors.New("COPY cannot export JSON files; JSON is an import format")
+		}
+		if statement.OnConflict != "" {
+			return nil, errors.New("COPY ON_CONFLICT applies to imports only")
+		}
+		return engine.executeCopyIntoFile(ctx, statement, startTime)
 	}
 
 	return nil, errors.New("invalid COPY direction")
 }
 
-// executeCopyIntoTable imports CSV data into a table
-func (engine *Engine) executeCopyIntoTable(statement sql.CopyStatement, startTime time.Time) (Result, error) {
+// copyProgressInterval is how many rows COPY processes between progress reports.
+const copyProgressInterval = 1000
+
+// reportCopyProgress calls the engine's CopyProgress callback, if any.
+func (engine *Engine) reportCopyProgress(rows int) {
+	if engine.CopyProgress != nil {
+		engine.CopyProgress(rows)
+	}
+}
+
+// executeCopyIntoTable imports CSV or JSON data into a table
+func (engine *Engine) executeCopyIntoTable(ctx context.Context, statement sql.CopyStatement, startTime time.Time) (Result, error) {
 	// Build S3 config if credentials provided
 	var cfg *s3Config
 	if statement.S3AccessKey != "" || statement.S3SecretKey != "" || statement.S3Region != "" {
@@ -2318,12 +4764,6 @@ func (engine *Engine) executeCopyIntoTable(statement sql.CopyStatement, startTim
 	}
 	defer reader.Close()
 
-	// Create CSV reader
-	csvReader := csv.NewReader(reader)
-	if len(statement.Delimiter) == 1 {
-		csvReader.Comma = rune(statement.Delimiter[0])
-	}
-
 	// Get table info
 	tableOp, err := op.GetTable(statement.Database, statement.Table, engine.Persistence)
 	if err != nil {
@@ -2335,44 +4775,158 @@ func (engine *Engine) executeCopyIntoTable(statement sql.CopyStatement, startTim
 		return nil, err
 	}
 
-	// Get column names from table
-	tableColumns := make([]string, len(tableOp.Table.Columns))
-	for i, col := range tableOp.Table.Columns {
-		tableColumns[i] = col.Name
+	// Batch all records for a single commit
+	resolve := copyConflictResolver(statement.OnConflict, tableOp)
+	var records map[string][]byte
+	if statement.Format == "JSON" {
+		records, err = engine.readJSONRecords(ctx, reader, tableOp.Table, *pk, resolve)
+	} else {
+		records, err = engine.readCSVRecords(ctx, reader, statement, tableOp.Table, *pk, resolve)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return CommitResult{
+			RecordsWritten:  0,
+			ExecutionTimeMs: elapsedMs(startTime),
+			ExecutionOps:    len(records),
+		}, nil
+	}
+
+	engine.reportCopyProgress(len(records))
+
+	saveIndexes, err := engine.stageRecordIndexUpdates(tableOp, records)
+	if err != nil {
+		return nil, err
+	}
+
+	// Insert all records in a single atomic transaction
+	txn, err := tableOp.PutAll(records, engine.author(tableOp.Table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert records: %v", err)
+	}
+	if err := saveIndexes(); err != nil {
+		return nil, err
+	}
+	written := len(records)
+	if txn.Unchanged {
+		written = 0
+	}
+
+	return CommitResult{
+		Transaction:     txn,
+		RecordsWritten:  written,
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    len(records),
+	}, nil
+}
+
+// copyResolver decides what an imported row does to the stored row with the
+// same primary key. It returns the row to store, or false to skip the row.
+// supplied reports whether the file gave a value, possibly NULL, for a column.
+type copyResolver func(key string, data map[string]string, supplied func(column string) bool) (map[string]string, bool, error)
+
+// copyConflictResolver returns the resolver for a COPY ON_CONFLICT policy:
+// without one, imported rows replace stored rows; IGNORE keeps stored rows;
+// UPDATE sets the columns the file supplies and keeps the others.
+func copyConflictResolver(policy string, tableOp *op.TableOp) copyResolver {
+	return func(key string, data map[string]string, supplied func(string) bool) (map[string]string, bool, error) {
+		if policy == "" {
+			return data, true, nil
+		}
+		stored, exists := tableOp.Get(key)
+		if !exists {
+			return data, true, nil
+		}
+		if policy == "IGNORE" {
+			return nil, false, nil
+		}
+
+		var row map[string]string
+		if err := json.Unmarshal(stored, &row); err != nil {
+			return nil, false, fmt.Errorf("corrupt row %s in %s.%s: %w", key, tableOp.Table.Database, tableOp.Table.Name, err)
+		}
+		normalizeRow(row, tableOp.Table)
+		for _, column := range tableOp.Table.Columns {
+			if !supplied(column.Name) {
+				continue
+			}
+			if value, ok := data[column.Name]; ok {
+				row[column.Name] = value
+			} else {
+				delete(row, column.Name)
+			}
+		}
+		return row, true, nil
+	}
+}
+
+// readCSVRecords reads CSV rows into stored records keyed by primary key.
+// Values map to the table's columns by the header names, or by position
+// without a header. Columns the file lacks take their DEFAULT or are NULL.
+// A row whose primary key repeats an earlier one replaces it, with a warning.
+// resolve settles rows whose primary key is already stored, and the rows it
+// keeps must pass the table's CHECK constraints.
+func (engine *Engine) readCSVRecords(ctx context.Context, reader io.Reader, statement sql.CopyStatement, table core.Table, pk string, resolve copyResolver) (map[string][]byte, error) {
+	// Create CSV reader
+	csvReader := csv.NewReader(reader)
+	if len(statement.Delimiter) == 1 {
+		csvReader.Comma = rune(statement.Delimiter[0])
 	}
 
 	// Determine columns from header or use table columns
-	var columnNames []string
+	var header []string
 	if statement.Header {
 		// Read header row first
 		headerRow, err := csvReader.Read()
 		if err != nil {
 			if err == io.EOF {
-				return CommitResult{
-					RecordsWritten:  0,
-					ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
-				}, nil
+				return nil, nil
 			}
 			return nil, fmt.Errorf("failed to read CSV header: %v", err)
 		}
-		columnNames = headerRow
-	} else {
-		columnNames = tableColumns
+		header = headerRow
+	}
+	positions, err := csvColumnPositions(header, table, pk)
+	if err != nil {
+		return nil, err
+	}
+	fieldCount := len(table.Columns)
+	if header != nil {
+		fieldCount = len(header)
 	}
 
-	// Validate column count
-	if len(columnNames) != len(tableColumns) {
-		return nil, fmt.Errorf("CSV columns (%d) don't match table columns (%d)", len(columnNames), len(tableColumns))
+	// Columns missing from the file take their DEFAULT value in every row,
+	// or their DEFAULT function evaluated for each row
+	defaults := make(map[string]string)
+	var defaultFunctions []core.Column
+	for j, column := range table.Columns {
+		if positions[j] >= 0 {
+			continue
+		}
+		if column.DefaultExpr != "" {
+			defaultFunctions = append(defaultFunctions, column)
+		} else if value, ok, err := engine.columnDefault(column); err != nil {
+			return nil, err
+		} else if ok {
+			defaults[column.Name] = value
+		}
 	}
 
-	// Batch all records for a single commit
 	records := make(map[string][]byte)
+	rowNums := make(map[string]int) // row each primary key was last read from
 	rowNum := 1
 	if statement.Header {
 		rowNum = 2 // Account for header row in error messages
 	}
 
 	for {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("COPY aborted after %d rows, nothing committed: %w", len(records), err)
+		}
+
 		row, err := csvReader.Read()
 		if err == io.EOF {
 			break
@@ -2381,48 +4935,120 @@ func (engine *Engine) executeCopyIntoTable(statement sql.CopyStatement, startTim
 			return nil, fmt.Errorf("failed to read row %d: %v", rowNum, err)
 		}
 
-		if len(row) != len(columnNames) {
-			return nil, fmt.Errorf("row %d has %d values, expected %d", rowNum, len(row), len(columnNames))
+		if len(row) != fieldCount {
+			return nil, fmt.Errorf("row %d has %d values, expected %d", rowNum, len(row), fieldCount)
 		}
 
-		data := make(map[string]interface{})
-		// Use table column names (not CSV header names) so primary key lookup works
-		for j, colName := range tableColumns {
-			data[colName] = row[j]
+		data := maps.Clone(defaults)
+		for _, column := range defaultFunctions {
+			value, _, err := engine.columnDefault(column)
+			if err != nil {
+				return nil, err
+			}
+			data[column.Name] = value
+		}
+		// BLOB columns arrive base64-encoded, as COPY exports them, and
+		// TIMESTAMP values are kept in canonical form as INSERT keeps them
+		for j, column := range table.Columns {
+			if positions[j] < 0 {
+				continue
+			}
+			value := row[positions[j]]
+			if column.Type == core.BlobType {
+				if _, err := base64.StdEncoding.DecodeString(value); err != nil {
+					return nil, constraintErrorf(column.Name, "row %d has invalid base64 in BLOB column %s", rowNum, column.Name)
+				}
+			} else if column.Type == core.TimestampType {
+				if value, err = canonicalTimestamp(column.Name, value); err != nil {
+					return nil, fmt.Errorf("row %d: %w", rowNum, err)
+				}
+			} else if !utf8.ValidString(value) {
+				return nil, constraintErrorf(column.Name, "row %d has invalid UTF-8 in column %s", rowNum, column.Name)
+			}
+			data[column.Name] = value
 		}
 
-		pkValue := data[*pk].(string)
-		jsonData, err := json.Marshal(data)
+		pkValue, ok := data[pk]
+		if !ok || pkValue == "" {
+			return nil, constraintErrorf(pk, "row %d missing primary key column %s", rowNum, pk)
+		}
+		data, keep, err := resolve(pkValue, data, func(name string) bool {
+			j := slices.IndexFunc(table.Columns, func(column core.Column) bool { return column.Name == name })
+			return j >= 0 && positions[j] >= 0
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !keep {
+			rowNum++
+			continue
+		}
+		if err := engine.checkRow(table, data); err != nil {
+			return nil, fmt.Errorf("row %d: %w", rowNum, err)
+		}
+		jsonData, err := marshalRow(data, table)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal row %d: %v", rowNum, err)
 		}
 
+		if earlier, ok := rowNums[pkValue]; ok {
+			engine.warn("COPY skipped row %d: row %d has the same primary key %s", earlier, rowNum, pkValue)
+		}
+		rowNums[pkValue] = rowNum
 		records[pkValue] = jsonData
 		rowNum++
+		if len(records)%copyProgressInterval == 0 {
+			engine.reportCopyProgress(len(records))
+		}
 	}
+	return records, nil
+}
 
-	if len(records) == 0 {
-		return CommitResult{
-			RecordsWritten:  0,
-			ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
-		}, nil
+// csvColumnPositions returns, for each table column, the index of the CSV
+// field holding it, or -1 when the file lacks the column. Header names pick
+// the columns, and may list a subset of them in any order; a header naming a
+// column the table lacks is an error. Without a header, values map by
+// position.
+func csvColumnPositions(header []string, table core.Table, pk string) ([]int, error) {
+	positions := make([]int, len(table.Columns))
+	for j := range positions {
+		positions[j] = j
+	}
+	if header == nil {
+		return positions, nil
 	}
 
-	// Insert all records in a single atomic transaction
-	txn, err := tableOp.PutAll(records, engine.Identity)
-	if err != nil {
-		return nil, fmt.Errorf("failed to insert records: %v", err)
+	for j := range positions {
+		positions[j] = -1
+	}
+	for i, name := range header {
+		j := slices.IndexFunc(table.Columns, func(column core.Column) bool { return column.Name == name })
+		if j < 0 {
+			return nil, fmt.Errorf("CSV header names column %s, which %s.%s does not have (use HEADER = FALSE for a file without a header)", name, table.Database, table.Name)
+		}
+		if positions[j] >= 0 {
+			return nil, fmt.Errorf("CSV header names column %s twice", name)
+		}
+		positions[j] = i
 	}
 
-	return CommitResult{
-		Transaction:     txn,
-		RecordsWritten:  len(records),
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
-	}, nil
+	for j, column := range table.Columns {
+		if positions[j] >= 0 {
+			continue
+		}
+		if column.Name == pk {
+			return nil, constraintErrorf(pk, "CSV header is missing primary key column %s", pk)
+		}
+		if column.NotNull && column.Default == nil {
+			return nil, constraintErrorf(column.Name, "CSV header is missing column %s, which is NOT NULL without a DEFAULT", column.Name)
+		}
+	}
+	return positions, nil
 }
 
-// executeCopyIntoFile exports table data to a CSV file
-func (engine *Engine) executeCopyIntoFile(statement sql.CopyStatement, startTime time.Time) (Result, error) {
+// executeCopyIntoFile exports table data to a CSV or Parquet file, or the
+// result of a query to a CSV file
+func (engine *Engine) executeCopyIntoFile(ctx context.Context, statement sql.CopyStatement, startTime time.Time) (Result, error) {
 	// Build S3 config if credentials provided
 	var cfg *s3Config
 	if statement.S3AccessKey != "" || statement.S3SecretKey != "" || statement.S3Region != "" {
@@ -2438,20 +5064,64 @@ func (engine *Engine) executeCopyIntoFile(statement sql.CopyStatement, startTime
 	if err != nil {
 		return nil, fmt.Errorf("failed to open destination: %v", err)
 	}
-	defer writer.Close()
 
-	// Create CSV writer
-	csvWriter := csv.NewWriter(writer)
-	if len(statement.Delimiter) == 1 {
-		csvWriter.Comma = rune(statement.Delimiter[0])
-	}
-	defer csvWriter.Flush()
+	// On failure, abort uploads so a partial export is never published
+	finished := false
+	defer func() {
+		if !finished {
+			if aborter, ok := writer.(writeAborter); ok {
+				aborter.abort(errors.New("COPY did not finish"))
+			}
+			writer.Close()
+		}
+	}()
 
-	// Get table data
-	tableOp, err := op.GetTable(statement.Database, statement.Table, engine.Persistence)
+	var recordsWritten int
+	if statement.Query != nil && statement.Format == "PARQUET" {
+		recordsWritten, err = engine.writeQueryParquet(ctx, writer, statement)
+	} else if statement.Query != nil {
+		recordsWritten, err = engine.writeQueryCSV(ctx, writer, statement)
+	} else {
+		// Get table data, from the share when the source names one
+		persistence := engine.Persistence
+		if statement.Share != "" {
+			shares := make(queryShares)
+			defer shares.close()
+			persistence, err = shares.open(engine.Persistence, statement.Share)
+			if err != nil {
+				return nil, fmt.Errorf("failed to access share '%s': %w", statement.Share, err)
+			}
+		}
+		var tableOp *op.TableOp
+		tableOp, err = op.GetTable(statement.Database, statement.Table, persistence)
+		if err != nil {
+			return nil, err
+		}
+		if statement.Format == "PARQUET" {
+			recordsWritten, err = engine.writeParquet(ctx, writer, tableOp)
+		} else {
+			recordsWritten, err = engine.writeCSV(ctx, writer, statement, tableOp)
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
+	finished = true
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write destination: %v", err)
+	}
+
+	return CommitResult{
+		RecordsWritten:  recordsWritten,
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    recordsWritten,
+	}, nil
+}
+
+// writeCSV writes every row of a table to w as CSV and returns the number of
+// rows written.
+func (engine *Engine) writeCSV(ctx context.Context, w io.Writer, statement sql.CopyStatement, tableOp *op.TableOp) (int, error) {
+	csvWriter := newCopyCSVWriter(w, statement)
 
 	// Get column names
 	columnNames := make([]string, len(tableOp.Table.Columns))
@@ -2462,16 +5132,20 @@ func (engine *Engine) executeCopyIntoFile(statement sql.CopyStatement, startTime
 	// Write header if requested
 	if statement.Header {
 		if err := csvWriter.Write(columnNames); err != nil {
-			return nil, fmt.Errorf("failed to write header: %v", err)
+			return 0, fmt.Errorf("failed to write header: %v", err)
 		}
 	}
 
 	// Scan all rows
 	recordsWritten := 0
 	for _, payload := range tableOp.Scan() {
+		if err := ctx.Err(); err != nil {
+			return 0, fmt.Errorf("COPY aborted after %d rows: %w", recordsWritten, err)
+		}
+
 		var data map[string]interface{}
 		if err := json.Unmarshal(payload, &data); err != nil {
-			return nil, fmt.Errorf("failed to parse row: %v", err)
+			return 0, fmt.Errorf("failed to parse row: %v", err)
 		}
 
 		// Build row in column order
@@ -2483,15 +5157,65 @@ func (engine *Engine) executeCopyIntoFile(statement sql.CopyStatement, startTime
 		}
 
 		if err := csvWriter.Write(csvRow); err != nil {
-			return nil, fmt.Errorf("failed to write row: %v", err)
+			return 0, fmt.Errorf("failed to write row: %v", err)
 		}
 		recordsWritten++
+		if recordsWritten%copyProgressInterval == 0 {
+			engine.reportCopyProgress(recordsWritten)
+		}
 	}
+	engine.reportCopyProgress(recordsWritten)
 
-	return CommitResult{
-		RecordsWritten:  recordsWritten,
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
-	}, nil
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		return 0, fmt.Errorf("failed to write rows: %v", err)
+	}
+	return recordsWritten, nil
+}
+
+// writeQueryCSV runs the query of a COPY INTO 'file' FROM (SELECT ...) and
+// writes its result columns and rows to w as CSV, returning the number of
+// rows written.
+func (engine *Engine) writeQueryCSV(ctx context.Context, w io.Writer, statement sql.CopyStatement) (int, error) {
+	result, err := engine.executeSelectStatement(ctx, *statement.Query)
+	if err != nil {
+		return 0, err
+	}
+
+	csvWriter := newCopyCSVWriter(w, statement)
+	if statement.Header {
+		if err := csvWriter.Write(result.Columns); err != nil {
+			return 0, fmt.Errorf("failed to write header: %v", err)
+		}
+	}
+
+	for i, row := range result.Data {
+		if err := ctx.Err(); err != nil {
+			return 0, fmt.Errorf("COPY aborted after %d rows: %w", i, err)
+		}
+		if err := csvWriter.Write(row); err != nil {
+			return 0, fmt.Errorf("failed to write row: %v", err)
+		}
+		if (i+1)%copyProgressInterval == 0 {
+			engine.reportCopyProgress(i + 1)
+		}
+	}
+	engine.reportCopyProgress(len(result.Data))
+
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		return 0, fmt.Errorf("failed to write rows: %v", err)
+	}
+	return len(result.Data), nil
+}
+
+// newCopyCSVWriter returns a CSV writer using the COPY statement's delimiter
+func newCopyCSVWriter(w io.Writer, statement sql.CopyStatement) *csv.Writer {
+	csvWriter := csv.NewWriter(w)
+	if len(statement.Delimiter) == 1 {
+		csvWriter.Comma = rune(statement.Delimiter[0])
+	}
+	return csvWriter
 }
 
 // View execution methods
@@ -2507,24 +5231,41 @@ func (engine *Engine) executeCreateViewStatement(statement sql.CreateViewStateme
 		Materialized: statement.Materialized,
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
+
+		AutoRefresh:     statement.AutoRefresh,
+		RefreshInterval: statement.RefreshInterval,
 	}
 
-	// Store the view definition
-	txn, err := engine.Persistence.CreateView(view, engine.Identity)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create view: %w", err)
+	existing, err := engine.Persistence.GetView(statement.Database, statement.ViewName)
+	if err == nil {
+		if !statement.OrReplace {
+			return nil, fmt.Errorf("view %s.%s already exists", statement.Database, statement.ViewName)
+		}
+		view.CreatedAt = existing.CreatedAt
+	} else if !errors.Is(err, ps.ErrViewNotFound) {
+		return nil, err
 	}
 
-	// If materialized, run the query and cache results
+	// If materialized, run the query before storing anything so a failing
+	// query doesn't leave an empty view behind
+	var rows []map[string]string
 	if statement.Materialized {
-		if err := engine.refreshMaterializedView(statement.Database, statement.ViewName, statement.SelectQuery); err != nil {
+		if rows, err = engine.materializeView(&view); err != nil {
 			return nil, fmt.Errorf("failed to populate materialized view: %w", err)
 		}
 	}
 
+	// Store the definition and its cache, or drop the cache of a replaced
+	// materialized view, in one commit
+	txn, err := engine.Persistence.ReplaceView(view, rows, engine.Identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create view: %w", err)
+	}
+
 	return CommitResult{
 		Transaction:     txn,
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    1,
 	}, nil
 }
 
@@ -2532,330 +5273,408 @@ func (engine *Engine) executeDropViewStatement(statement sql.DropViewStatement)
 	startTime := time.Now()
 
 	// Check if view exists
-	view, err := engine.Persistence.GetView(statement.Database, statement.ViewName)
+	_, err := engine.Persistence.GetView(statement.Database, statement.ViewName)
 	if err != nil {
-		if statement.IfExists {
+		if statement.IfExists && errors.Is(err, ps.ErrViewNotFound) {
 			return CommitResult{
-				ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+				ExecutionTimeMs: elapsedMs(startTime),
+				ExecutionOps:    1,
 			}, nil
 		}
-		return nil, fmt.Errorf("view %s.%s does not exist", statement.Database, statement.ViewName)
+		return nil, err
 	}
 
-	// Drop view definition
+	// Drop view definition and any materialized data in a single commit
 	txn, err := engine.Persistence.DropView(statement.Database, statement.ViewName, engine.Identity)
 	if err != nil {
 		return nil, fmt.Errorf("failed to drop view: %w", err)
 	}
 
-	// If materialized, also delete cached data
-	if view.Materialized {
-		engine.Persistence.DeleteMaterializedViewData(statement.Database, statement.ViewName, engine.Identity)
-	}
-
 	return CommitResult{
 		Transaction:     txn,
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    1,
 	}, nil
 }
 
+// executeShowViewsStatement lists the views of one database, or, without
+// IN, of every database with a leading database column.
 func (engine *Engine) executeShowViewsStatement(statement sql.ShowViewsStatement) (QueryResult, error) {
 	startTime := time.Now()
 
-	views, err := engine.Persistence.ListViews(statement.Database)
-	if err != nil {
-		return QueryResult{}, err
+	databases := []string{statement.Database}
+	columns := []string{"name", "materialized", "query"}
+	if statement.Database == "" {
+		databases = engine.Persistence.ListDatabases()
+		slices.Sort(databases)
+		columns = append([]string{"database"}, columns...)
 	}
 
-	columns := []string{"name", "materialized", "query"}
 	var data [][]string
-
-	for _, view := range views {
-		materialized := "NO"
-		if view.Materialized {
-			materialized = "YES"
+	for _, database := range databases {
+		views, err := engine.Persistence.ListViews(database)
+		if err != nil {
+			return QueryResult{}, err
+		}
+		for _, view := range views {
+			row := []string{view.Name, formatMaterialized(view), view.Query}
+			if statement.Database == "" {
+				row = append([]string{database}, row...)
+			}
+			data = append(data, row)
 		}
-		data = append(data, []string{view.Name, materialized, view.Query})
 	}
 
 	return QueryResult{
 		Columns:         columns,
 		Data:            data,
 		RecordsRead:     len(data),
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    len(data),
+	}, nil
+}
+
+// executeShowCreateViewStatement shows a view's stored query and the CREATE
+// VIEW statement that recreates it.
+func (engine *Engine) executeShowCreateViewStatement(statement sql.ShowCreateViewStatement) (QueryResult, error) {
+	startTime := time.Now()
+
+	view, err := engine.Persistence.GetView(statement.Database, statement.ViewName)
+	if err != nil {
+		return QueryResult{}, err
+	}
+
+	create := "CREATE "
+	if view.Materialized {
+		create += "MATERIALIZED "
+	}
+	create += fmt.Sprintf("VIEW %s.%s ", view.Database, view.Name)
+	if view.AutoRefresh {
+		create += "WITH AUTO REFRESH "
+		if view.RefreshInterval > 0 {
+			create += fmt.Sprintf("EVERY '%s' ", view.RefreshInterval)
+		}
+	}
+	create += "AS " + view.Query
+
+	return QueryResult{
+		Columns:         []string{"name", "materialized", "query", "create_statement"},
+		Data:            [][]string{{view.Name, formatMaterialized(*view), view.Query, create}},
+		RecordsRead:     1,
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    1,
 	}, nil
 }
 
+// formatMaterialized is the materialized column of SHOW VIEWS and SHOW CREATE VIEW
+func formatMaterialized(view core.View) string {
+	if view.Materialized {
+		return "YES"
+	}
+	return "NO"
+}
+
 func (engine *Engine) executeRefreshViewStatement(statement sql.RefreshViewStatement) (Result, error) {
 	startTime := time.Now()
 
 	// Get view definition
 	view, err := engine.Persistence.GetView(statement.Database, statement.ViewName)
 	if err != nil {
-		return nil, fmt.Errorf("view %s.%s does not exist", statement.Database, statement.ViewName)
+		return nil, err
 	}
 
 	if !view.Materialized {
 		return nil, fmt.Errorf("view %s.%s is not a materialized view", statement.Database, statement.ViewName)
 	}
 
-	// Refresh the cached data
-	if err := engine.refreshMaterializedView(statement.Database, statement.ViewName, view.Query); err != nil {
+	if err := engine.refreshView(view); err != nil {
 		return nil, err
 	}
 
-	// Update view timestamp
-	view.UpdatedAt = time.Now()
-	engine.Persistence.UpdateView(*view, engine.Identity)
-
 	return CommitResult{
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    1,
 	}, nil
 }
 
-func (engine *Engine) refreshMaterializedView(database, viewName, query string) error {
-	// Execute the query
-	result, err := engine.Execute(query)
+// refreshView recomputes a materialized view's cached data and stores it
+// with the updated definition, holding the new columns, snapshot and refresh
+// time, in one commit.
+func (engine *Engine) refreshView(view *core.View) error {
+	rows, err := engine.materializeView(view)
 	if err != nil {
-		return fmt.Errorf("failed to execute view query: %w", err)
+		return err
 	}
 
-	queryResult, ok := result.(QueryResult)
-	if !ok {
-		return fmt.Errorf("view query must be a SELECT statement")
+	view.UpdatedAt = time.Now()
+	if _, err := engine.Persistence.RefreshView(*view, rows, engine.Identity); err != nil {
+		return fmt.Errorf("failed to store materialized view data: %w", err)
 	}
+	return nil
+}
 
-	// Convert Data ([][]string) to rows ([]map[string]string) for storage
-	rows := make([]map[string]string, len(queryResult.Data))
-	for i, row := range queryResult.Data {
-		rowMap := make(map[string]string)
-		for j, col := range queryResult.Columns {
-			if j < len(row) {
-				rowMap[col] = row[j]
+// afterWrite notes the written table and, once the outermost statement is
+// done with it, refreshes the views over it with refreshWritten. A refresh
+// commits, so inside a transaction it waits for COMMIT, and writes made by
+// triggers wait for the statement that fired them. The write has already
+// been committed when a refresh fails, so its result is returned along with
+// the error.
+func (engine *Engine) afterWrite(database, table string, result Result, err error) (Result, error) {
+	if err != nil {
+		return result, err
+	}
+	if engine.transaction != nil {
+		engine.transaction.written = addWritten(engine.transaction.written, database+"."+table)
+		return result, nil
+	}
+	engine.written = addWritten(engine.written, database+"."+table)
+	if engine.nesting > 1 {
+		return result, nil
+	}
+	return result, engine.refreshWritten()
+}
+
+// addWritten adds a "database.table" name to written unless it is there.
+func addWritten(written []string, name string) []string {
+	if slices.Contains(written, name) {
+		return written
+	}
+	return append(written, name)
+}
+
+// refreshWritten refreshes the auto-refresh materialized views, in any
+// database, that read from a table in engine.written, each once however many
+// of them it reads, and then forgets the tables. Views with a refresh
+// interval are left to refresh when read.
+func (engine *Engine) refreshWritten() error {
+	written := engine.written
+	engine.written = nil
+	if len(written) == 0 {
+		return nil
+	}
+
+	// Views in any database may read the tables
+	databases := engine.Persistence.ListDatabases()
+	slices.Sort(databases)
+	for _, viewDatabase := range databases {
+		views, err := engine.Persistence.ListViews(viewDatabase)
+		if err != nil {
+			return err
+		}
+		for _, view := range views {
+			if !view.Materialized || !view.AutoRefresh || view.RefreshInterval > 0 {
+				continue
+			}
+			if !slices.ContainsFunc(written, func(name string) bool {
+				database, table, _ := strings.Cut(name, ".")
+				return viewReadsTable(view, database, table)
+			}) {
+				continue
+			}
+			if err := engine.refreshView(&view); err != nil {
+				return fmt.Errorf("failed to refresh view %s.%s after write: %w", view.Database, view.Name, err)
 			}
 		}
-		rows[i] = rowMap
 	}
+	return nil
+}
 
-	// Store cached data
-	_, err = engine.Persistence.WriteMaterializedViewData(database, viewName, rows, engine.Identity)
+// viewReadsTable reports whether a view's query selects from or joins the
+// given local table.
+func viewReadsTable(view core.View, database, table string) bool {
+	stmt, err := sql.NewParser(view.Query).Parse()
 	if err != nil {
-		return fmt.Errorf("failed to store materialized view data: %w", err)
+		return false
+	}
+	selectStmt, ok := stmt.(sql.SelectStatement)
+	if !ok {
+		return false
 	}
 
-	return nil
+	if selectStmt.Share == "" && selectStmt.Database == database && selectStmt.Table == table {
+		return true
+	}
+	for _, join := range selectStmt.Joins {
+		if join.Share == "" && join.Database == database && join.Table == table {
+			return true
+		}
+	}
+	return false
 }
 
-// executeViewQuery executes a query against a regular (non-materialized) view
-func (engine *Engine) executeViewQuery(view *core.View, originalStatement sql.SelectStatement, startTime time.Time) (QueryResult, error) {
-	// Execute the view's underlying query
+// refreshDue reports whether a materialized view with a refresh interval is
+// stale and should be refreshed before it is read.
+func refreshDue(view *core.View, now time.Time) bool {
+	return view.Materialized && view.AutoRefresh && view.RefreshInterval > 0 &&
+		now.Sub(view.UpdatedAt) >= view.RefreshInterval
+}
+
+// materializeView runs the view query and returns its rows, setting the
+// view's output columns in query order and the transaction the rows were
+// computed from. Nothing is stored.
+func (engine *Engine) materializeView(view *core.View) ([]map[string]string, error) {
+	snapshot := engine.Persistence.LatestTransaction().Id
+
+	// Execute the query
 	result, err := engine.Execute(view.Query)
 	if err != nil {
-		return QueryResult{}, fmt.Errorf("failed to execute view query: %w", err)
+		return nil, fmt.Errorf("failed to execute view query: %w", err)
 	}
 
 	queryResult, ok := result.(QueryResult)
 	if !ok {
-		return QueryResult{}, fmt.Errorf("view query must be a SELECT statement")
+		return nil, fmt.Errorf("view query must be a SELECT statement")
 	}
 
-	// Update timing
-	queryResult.ExecutionTimeMs = float64(time.Since(startTime).Milliseconds())
-	return queryResult, nil
+	view.Columns = make([]core.Column, len(queryResult.Columns))
+	for i, col := range queryResult.Columns {
+		view.Columns[i] = core.Column{Name: col}
+	}
+	view.Snapshot = snapshot
+
+	return resultToRows(queryResult), nil
 }
 
-// executeMaterializedViewQuery reads from cached materialized view data
-func (engine *Engine) executeMaterializedViewQuery(view *core.View, originalStatement sql.SelectStatement, startTime time.Time) (QueryResult, error) {
-	// Read cached data
-	rows, err := engine.Persistence.ReadMaterializedViewData(view.Database, view.Name)
+// readViewRows produces the output of a view as rows keyed by column name.
+// Regular views run their stored SELECT through the full select pipeline
+// (so JOINs, GROUP BY and aggregates work); materialized views read cached data.
+func (engine *Engine) readViewRows(ctx context.Context, view *core.View) ([]string, []map[string]string, error) {
+	if view.Materialized {
+		rows, err := engine.Persistence.ReadMaterializedViewData(view.Database, view.Name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read materialized view data: %w", err)
+		}
+
+		// Prefer the column order recorded on refresh, fall back to sorted keys
+		var columns []string
+		for _, col := range view.Columns {
+			columns = append(columns, col.Name)
+		}
+		if len(columns) == 0 && len(rows) > 0 {
+			for col := range rows[0] {
+				columns = append(columns, col)
+			}
+			sort.Strings(columns)
+		}
+
+		return columns, rows, nil
+	}
+
+	parser := sql.NewParser(view.Query)
+	stmt, err := parser.Parse()
 	if err != nil {
-		return QueryResult{}, fmt.Errorf("failed to read materialized view data: %w", err)
+		return nil, nil, fmt.Errorf("failed to parse view query: %w", err)
 	}
 
-	// Determine columns (from first row or view definition)
-	var columns []string
-	if len(rows) > 0 {
-		for col := range rows[0] {
-			columns = append(columns, col)
-		}
+	selectStmt, ok := stmt.(sql.SelectStatement)
+	if !ok {
+		return nil, nil, fmt.Errorf("view query must be a SELECT statement")
 	}
 
-	// Convert rows to Data format
-	var data [][]string
-	for _, row := range rows {
-		rowData := make([]string, len(columns))
-		for i, col := range columns {
-			rowData[i] = row[col]
-		}
-		data = append(data, rowData)
+	queryResult, err := engine.executeSelectStatement(ctx, selectStmt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to execute view query: %w", err)
 	}
 
-	return QueryResult{
-		Columns:         columns,
-		Data:            data,
-		RecordsRead:     len(data),
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
-	}, nil
+	return queryResult.Columns, resultToRows(queryResult), nil
 }
 
-// executeTimeTravelSelect executes a SELECT query against data as it existed at a specific transaction.
-func (engine *Engine) executeTimeTravelSelect(statement sql.SelectStatement, persistence *ps.Persistence, startTime time.Time) (QueryResult, error) {
-	transactionID := statement.AsOf
-
-	// Check if this is a view instead of a table
-	view, err := persistence.GetView(statement.Database, statement.Table)
-	if err == nil {
-		// This is a view - handle time-travel for views
-		if view.Materialized {
-			// For materialized views, get cached data at that transaction
-			return engine.executeTimeTravelMaterializedView(view, transactionID, startTime)
+// resultToRows converts a QueryResult's column-based data to row maps
+func resultToRows(result QueryResult) []map[string]string {
+	rows := make([]map[string]string, len(result.Data))
+	for i, row := range result.Data {
+		rowMap := make(map[string]string)
+		for j, col := range result.Columns {
+			if j < len(row) {
+				rowMap[col] = row[j]
+			}
 		}
-		// For regular views, execute view query with AS OF propagated
-		return engine.executeTimeTravelRegularView(view, transactionID)
+		rows[i] = rowMap
 	}
+	return rows
+}
 
-	// Get table schema at that transaction
-	table, err := persistence.GetTableAtTransaction(statement.Database, statement.Table, transactionID)
+// readTableAsOf reads a table's schema and rows as they existed at a specific transaction.
+func (engine *Engine) readTableAsOf(ctx context.Context, persistence *ps.Persistence, database, tableName, transactionID string, rowsScanned, corruptRows *int) (*core.Table, []map[string]string, error) {
+	table, err := persistence.GetTableAtTransaction(database, tableName, transactionID)
 	if err != nil {
-		return QueryResult{}, fmt.Errorf("failed to get table at transaction %s: %w", transactionID, err)
-	}
-
-	// Determine columns to select
-	columns := []string{}
-	if len(statement.Columns) == 0 {
-		for _, column := range table.Columns {
-			columns = append(columns, column.Name)
-		}
-	} else {
-		columns = append(columns, statement.Columns...)
+		return nil, nil, fmt.Errorf("failed to get table at transaction %s: %w", transactionID, err)
 	}
 
-	// Get all record keys at that transaction
-	keys, err := persistence.ListRecordsAtTransaction(statement.Database, statement.Table, transactionID)
+	keys, err := persistence.ListRecordsAtTransaction(database, tableName, transactionID)
 	if err != nil {
-		return QueryResult{}, fmt.Errorf("failed to list records at transaction %s: %w", transactionID, err)
+		return nil, nil, fmt.Errorf("failed to list records at transaction %s: %w", transactionID, err)
 	}
 
-	// Read each record at that transaction
-	var results []map[string]string
+	var rows []map[string]string
 	for _, key := range keys {
-		rawData, exists, err := persistence.GetRecordAtTransaction(statement.Database, statement.Table, key, transactionID)
+		if err := ctx.Err(); err != nil {
+			return nil, nil, fmt.Errorf("query aborted after scanning %d rows: %w", *rowsScanned, err)
+		}
+		rawData, exists, err := persistence.GetRecordAtTransaction(database, tableName, key, transactionID)
 		if err != nil {
-			return QueryResult{}, err
+			return nil, nil, err
 		}
 		if !exists {
 			continue
 		}
+		*rowsScanned++
 
-		var jsonData map[string]string
-		if err := json.Unmarshal(rawData, &jsonData); err != nil {
+		jsonData, err := engine.readRow(database, tableName, key, rawData, corruptRows)
+		if err != nil {
+			return nil, nil, err
+		}
+		if jsonData == nil {
 			continue
 		}
-		results = append(results, jsonData)
+		normalizeRow(jsonData, *table)
+		decodeBlobs(jsonData, *table)
+		rows = append(rows, jsonData)
 	}
+	return table, rows, nil
+}
 
-	// Apply WHERE filter
-	if len(statement.Where.Conditions) > 0 {
-		var filtered []map[string]string
-		for _, row := range results {
-			if matchesWhereClause(row, statement.Where) {
-				filtered = append(filtered, row)
-			}
+// readViewRowsAsOf returns a view's columns and rows as they were at a
+// transaction: a materialized view's cached data at that commit, or a
+// regular view's query run with the AS OF applied.
+func (engine *Engine) readViewRowsAsOf(ctx context.Context, view *core.View, transactionID string) ([]string, []map[string]string, error) {
+	if view.Materialized {
+		rows, err := engine.Persistence.GetMaterializedViewDataAtTransaction(view.Database, view.Name, transactionID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get materialized view data at transaction %s: %w", transactionID, err)
 		}
-		results = filtered
-	}
-
-	// Apply ORDER BY
-	if len(statement.OrderBy) > 0 {
-		sortResults(results, statement.OrderBy)
-	}
-
-	// Apply LIMIT and OFFSET
-	if statement.Offset > 0 && statement.Offset < len(results) {
-		results = results[statement.Offset:]
-	} else if statement.Offset >= len(results) {
-		results = nil
-	}
-
-	if statement.Limit > 0 && statement.Limit < len(results) {
-		results = results[:statement.Limit]
-	}
 
-	// Build result data
-	var data [][]string
-	for _, row := range results {
-		rowData := make([]string, len(columns))
-		for i, col := range columns {
-			rowData[i] = row[col]
+		// Prefer the column order recorded on refresh, fall back to sorted keys
+		var columns []string
+		for _, col := range view.Columns {
+			columns = append(columns, col.Name)
+		}
+		if len(columns) == 0 && len(rows) > 0 {
+			for col := range rows[0] {
+				columns = append(columns, col)
+			}
+			sort.Strings(columns)
 		}
-		data = append(data, rowData)
+		return columns, rows, nil
 	}
 
-	return QueryResult{
-		Columns:         columns,
-		Data:            data,
-		RecordsRead:     len(results),
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
-		Transaction:     ps.Transaction{Id: transactionID},
-	}, nil
-}
-
-// executeTimeTravelRegularView handles time-travel queries on regular (non-materialized) views.
-// It parses the view's underlying query and injects the AS OF clause.
-func (engine *Engine) executeTimeTravelRegularView(view *core.View, transactionID string) (QueryResult, error) {
-	// Parse the view's underlying query
 	parser := sql.NewParser(view.Query)
 	stmt, err := parser.Parse()
 	if err != nil {
-		return QueryResult{}, fmt.Errorf("failed to parse view query: %w", err)
+		return nil, nil, fmt.Errorf("failed to parse view query: %w", err)
 	}
 
 	selectStmt, ok := stmt.(sql.SelectStatement)
 	if !ok {
-		return QueryResult{}, fmt.Errorf("view query must be a SELECT statement")
+		return nil, nil, fmt.Errorf("view query must be a SELECT statement")
 	}
 
 	// Inject the AS OF clause into the underlying query
 	selectStmt.AsOf = transactionID
 
-	// Execute the modified query
-	return engine.executeSelectStatement(selectStmt)
-}
-
-// executeTimeTravelMaterializedView handles time-travel queries on materialized views.
-// It reads the cached view data as it existed at the specified transaction.
-func (engine *Engine) executeTimeTravelMaterializedView(view *core.View, transactionID string, startTime time.Time) (QueryResult, error) {
-	// Read materialized view data at that transaction
-	rows, err := engine.Persistence.GetMaterializedViewDataAtTransaction(view.Database, view.Name, transactionID)
+	queryResult, err := engine.executeSelectStatement(ctx, selectStmt)
 	if err != nil {
-		return QueryResult{}, fmt.Errorf("failed to get materialized view data at transaction %s: %w", transactionID, err)
-	}
-
-	// Determine columns from the data
-	var columns []string
-	if len(rows) > 0 {
-		for col := range rows[0] {
-			columns = append(columns, col)
-		}
-		// Sort for consistent ordering
-		sort.Strings(columns)
-	}
-
-	// Convert rows to Data format
-	var data [][]string
-	for _, row := range rows {
-		rowData := make([]string, len(columns))
-		for i, col := range columns {
-			rowData[i] = row[col]
-		}
-		data = append(data, rowData)
+		return nil, nil, fmt.Errorf("failed to execute view query: %w", err)
 	}
-
-	return QueryResult{
-		Columns:         columns,
-		Data:            data,
-		RecordsRead:     len(data),
-		ExecutionTimeMs: float64(time.Since(startTime).Milliseconds()),
-		Transaction:     ps.Transaction{Id: transactionID},
-	}, nil
+	return queryResult.Columns, resultToRows(queryResult), nil
 }
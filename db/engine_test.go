@@ -1,10 +1,20 @@
 package db
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/nickyhof/CommitDB/core"
 	"github.com/nickyhof/CommitDB/ps"
+	"github.com/nickyhof/CommitDB/sql"
 )
 
 func setupTestEngine(t *testing.T) *Engine {
@@ -59,206 +69,3118 @@ func TestEngineSelectWithWhere(t *testing.T) {
 	}
 }
 
-func TestEngineSelectOrderBy(t *testing.T) {
+func TestEngineSelectPrimaryKeyLookup(t *testing.T) {
 	engine := setupTestEngine(t)
 	insertTestData(t, engine)
 
-	result, err := engine.Execute("SELECT * FROM testdb.users ORDER BY age DESC")
+	tests := []struct {
+		where   string
+		want    []string
+		scanned int // rows read from storage
+	}{
+		{"id = 2", []string{"2"}, 1},
+		{"id = 99", nil, 1},
+		{"id = 2 AND name = 'Alice'", nil, 1},
+		{"age > 20 AND id = 3", []string{"3"}, 1},
+		{"id = 2 OR id = 3", []string{"2", "3"}, 3},
+		{"id != 2", []string{"1", "3"}, 3},
+	}
+	for _, test := range tests {
+		result, err := engine.Execute("SELECT id FROM testdb.users WHERE " + test.where)
+		if err != nil {
+			t.Fatalf("WHERE %s: %v", test.where, err)
+		}
+		qr := result.(QueryResult)
+		var ids []string
+		for _, row := range qr.Data {
+			ids = append(ids, row[0])
+		}
+		if !slices.Equal(ids, test.want) {
+			t.Errorf("WHERE %s: expected %v, got %v", test.where, test.want, ids)
+		}
+		if qr.ExecutionOps != test.scanned {
+			t.Errorf("WHERE %s: expected %d rows read, got %d", test.where, test.scanned, qr.ExecutionOps)
+		}
+	}
+}
+
+func TestEngineUpdateDeleteMaintainIndexes(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+	if _, err := engine.Execute("CREATE INDEX idx_name ON testdb.users(name)"); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	result, err := engine.Execute("UPDATE testdb.users SET name = 'Robert' WHERE id = 2")
 	if err != nil {
-		t.Fatalf("Failed to execute SELECT: %v", err)
+		t.Fatalf("Failed to execute UPDATE: %v", err)
+	}
+	if cr := result.(CommitResult); cr.RecordsWritten != 1 {
+		t.Errorf("Expected 1 record written, got %d", cr.RecordsWritten)
 	}
 
-	qr := result.(QueryResult)
-	if len(qr.Data) < 1 {
-		t.Fatal("Expected at least 1 row")
+	if _, err := engine.Execute("DELETE FROM testdb.users WHERE id = 3"); err != nil {
+		t.Fatalf("Failed to execute DELETE: %v", err)
 	}
 
-	// Charlie (35) should be first
-	firstRow := qr.Data[0]
-	found := false
-	for _, val := range firstRow {
-		if val == "Charlie" {
-			found = true
-			break
-		}
+	indexManager := ps.NewIndexManager(engine.Persistence, engine.Identity)
+	table, err := engine.Persistence.GetTable("testdb", "users")
+	if err != nil {
+		t.Fatalf("Failed to get table: %v", err)
 	}
+	if err := indexManager.LoadIndexes("testdb", "users", table.Columns); err != nil {
+		t.Fatalf("Failed to load indexes: %v", err)
+	}
+	idx, found := indexManager.GetIndex("testdb", "users", "name")
 	if !found {
-		t.Error("Expected Charlie to be first with ORDER BY age DESC")
+		t.Fatal("Expected index on name")
+	}
+
+	want := map[string][]string{"Alice": {"1"}, "Robert": {"2"}}
+	if !reflect.DeepEqual(idx.Entries, want) {
+		t.Errorf("Expected index entries %v, got %v", want, idx.Entries)
+	}
+
+	result, err = engine.Execute("SELECT id, age FROM testdb.users WHERE name = 'Robert'")
+	if err != nil {
+		t.Fatalf("Failed to execute SELECT: %v", err)
+	}
+	if data := result.(QueryResult).Data; !reflect.DeepEqual(data, [][]string{{"2", "25"}}) {
+		t.Errorf("Expected Robert as id 2, got %v", data)
 	}
 }
 
-func TestEngineSelectLimit(t *testing.T) {
+func TestEngineUpdateUniqueIndexViolation(t *testing.T) {
 	engine := setupTestEngine(t)
 	insertTestData(t, engine)
+	if _, err := engine.Execute("CREATE UNIQUE INDEX idx_name ON testdb.users(name)"); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
 
-	result, err := engine.Execute("SELECT * FROM testdb.users LIMIT 2")
+	_, err := engine.Execute("UPDATE testdb.users SET name = 'Alice' WHERE id = 2")
+	var constraintErr *ConstraintError
+	if !errors.As(err, &constraintErr) {
+		t.Fatalf("Expected ConstraintError, got %v", err)
+	}
+
+	result, err := engine.Execute("SELECT name FROM testdb.users WHERE id = 2")
 	if err != nil {
 		t.Fatalf("Failed to execute SELECT: %v", err)
 	}
-
-	qr := result.(QueryResult)
-	if len(qr.Data) != 2 {
-		t.Errorf("Expected 2 records with LIMIT 2, got %d", len(qr.Data))
+	if data := result.(QueryResult).Data; !reflect.DeepEqual(data, [][]string{{"Bob"}}) {
+		t.Errorf("Expected the row to be unchanged, got %v", data)
 	}
 }
 
-func TestEngineCount(t *testing.T) {
+func TestEngineWritesMaintainIndexes(t *testing.T) {
 	engine := setupTestEngine(t)
+	if _, err := engine.Execute("CREATE INDEX idx_name ON testdb.users(name)"); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
 	insertTestData(t, engine)
 
-	result, err := engine.Execute("SELECT COUNT(*) FROM testdb.users")
+	csvPath := filepath.Join(t.TempDir(), "users.csv")
+	if err := os.WriteFile(csvPath, []byte("id,name,age\n4,Dave,40\n5,Eve,45\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	if _, err := engine.Execute("COPY INTO testdb.users FROM '" + csvPath + "'"); err != nil {
+		t.Fatalf("COPY failed: %v", err)
+	}
+	stmt, err := engine.PrepareInsert("testdb", "users")
 	if err != nil {
-		t.Fatalf("Failed to execute COUNT: %v", err)
+		t.Fatalf("PrepareInsert failed: %v", err)
+	}
+	if err := stmt.Add("6", "Frank", "50"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if _, err := stmt.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	// Replacing a row moves its index entry
+	if _, err := engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (1, 'Alicia', 31)"); err != nil {
+		t.Fatalf("INSERT failed: %v", err)
 	}
 
-	qr := result.(QueryResult)
-	if len(qr.Data) != 1 || len(qr.Data[0]) != 1 {
-		t.Fatal("Expected single count result")
+	for _, query := range []string{
+		"DELETE FROM testdb.users WHERE name = 'Bob'",
+		"DELETE FROM testdb.users WHERE name = 'Eve'",
+		"UPDATE testdb.users SET age = 51 WHERE name = 'Frank'",
+		"UPDATE testdb.users SET age = 32 WHERE name = 'Alicia'",
+	} {
+		result, err := engine.Execute(query)
+		if err != nil {
+			t.Fatalf("%s failed: %v", query, err)
+		}
+		cr := result.(CommitResult)
+		if cr.RecordsWritten+cr.RecordsDeleted != 1 {
+			t.Errorf("%s: expected 1 row changed, got %+v", query, cr)
+		}
 	}
-	if qr.Data[0][0] != "3" {
-		t.Errorf("Expected count of 3, got %s", qr.Data[0][0])
+
+	result, err := engine.Execute("CHECK DATABASE testdb")
+	if err != nil {
+		t.Fatalf("CHECK DATABASE failed: %v", err)
+	}
+	if data := result.(QueryResult).Data; len(data) != 0 {
+		t.Errorf("Expected consistent indexes, got %v", data)
+	}
+
+	// A table recreated under the same name does not inherit the indexes
+	if _, err := engine.Execute("DROP TABLE testdb.users"); err != nil {
+		t.Fatalf("DROP TABLE failed: %v", err)
+	}
+	if _, err := engine.Execute("CREATE TABLE testdb.users (id INT PRIMARY KEY, name STRING, age INT)"); err != nil {
+		t.Fatalf("CREATE TABLE failed: %v", err)
+	}
+	result, err = engine.Execute("SHOW INDEXES ON testdb.users")
+	if err != nil {
+		t.Fatalf("SHOW INDEXES failed: %v", err)
+	}
+	if data := result.(QueryResult).Data; len(data) != 0 {
+		t.Errorf("Expected no indexes on the recreated table, got %v", data)
 	}
 }
 
-func TestEngineUpdate(t *testing.T) {
+func TestEngineInsertUniqueIndexViolation(t *testing.T) {
 	engine := setupTestEngine(t)
 	insertTestData(t, engine)
+	if _, err := engine.Execute("CREATE UNIQUE INDEX idx_name ON testdb.users(name)"); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
 
-	// Update by primary key
-	_, err := engine.Execute("UPDATE testdb.users SET age = 31 WHERE id = 1")
+	_, err := engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (4, 'Dave', 40), (5, 'Alice', 45)")
+	var constraintErr *ConstraintError
+	if !errors.As(err, &constraintErr) {
+		t.Fatalf("Expected ConstraintError, got %v", err)
+	}
+
+	result, err := engine.Execute("SELECT COUNT(*) FROM testdb.users")
 	if err != nil {
-		t.Fatalf("Failed to execute UPDATE: %v", err)
+		t.Fatalf("Failed to execute SELECT: %v", err)
 	}
+	if data := result.(QueryResult).Data; !reflect.DeepEqual(data, [][]string{{"3"}}) {
+		t.Errorf("Expected no rows inserted, got %v", data)
+	}
+}
 
-	// Verify update
-	result, _ := engine.Execute("SELECT * FROM testdb.users WHERE id = 1")
+func TestEngineShowIndexesInDatabase(t *testing.T) {
+	engine := setupTestEngine(t)
+	for _, query := range []string{
+		"CREATE TABLE testdb.orders (id INT PRIMARY KEY, user_id INT, status STRING)",
+		"CREATE TABLE testdb.empty (id INT PRIMARY KEY)",
+		"CREATE UNIQUE INDEX idx_name ON testdb.users(name)",
+		"CREATE INDEX idx_user ON testdb.orders(user_id)",
+		"CREATE INDEX idx_status ON testdb.orders(status)",
+	} {
+		if _, err := engine.Execute(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+
+	result, err := engine.Execute("SHOW INDEXES IN testdb")
+	if err != nil {
+		t.Fatalf("SHOW INDEXES IN failed: %v", err)
+	}
 	qr := result.(QueryResult)
+	expected := [][]string{
+		{"orders", "idx_user", "user_id", "NO"},
+		{"orders", "idx_status", "status", "NO"},
+		{"users", "idx_name", "name", "YES"},
+	}
+	if !reflect.DeepEqual(qr.Columns, []string{"Table", "Name", "Column", "Unique"}) || !reflect.DeepEqual(qr.Data, expected) {
+		t.Errorf("Expected %v, got %v %v", expected, qr.Columns, qr.Data)
+	}
 
-	// Find age column value
-	ageIdx := -1
-	for i, col := range qr.Columns {
-		if col == "age" {
-			ageIdx = i
-			break
+	result, err = engine.Execute("SHOW INDEXES ON testdb.orders")
+	if err != nil {
+		t.Fatalf("SHOW INDEXES ON failed: %v", err)
+	}
+	if got := result.(QueryResult).Data; len(got) != 2 {
+		t.Errorf("Expected the two orders indexes, got %v", got)
+	}
+}
+
+func TestEngineNullVersusEmpty(t *testing.T) {
+	engine := setupTestEngine(t)
+	_, _ = engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (1, NULL, 30), (2, '', 25), (3, 'Charlie', NULL)")
+
+	ids := func(query string) []string {
+		result, err := engine.Execute(query)
+		if err != nil {
+			t.Fatalf("Failed to execute %q: %v", query, err)
+		}
+		var ids []string
+		for _, row := range result.(QueryResult).Data {
+			ids = append(ids, row[0])
 		}
+		return ids
 	}
 
-	if ageIdx >= 0 && len(qr.Data) > 0 {
-		if qr.Data[0][ageIdx] != "31" {
-			t.Errorf("Expected age to be updated to 31, got %s", qr.Data[0][ageIdx])
+	tests := []struct {
+		where string
+		want  []string
+	}{
+		{"name IS NULL", []string{"1"}},
+		{"name = ''", []string{"2"}},
+		{"name IS NOT NULL", []string{"2", "3"}},
+		{"age IS NULL", []string{"3"}},
+	}
+	for _, test := range tests {
+		if got := ids("SELECT id FROM testdb.users WHERE " + test.where); !slices.Equal(got, test.want) {
+			t.Errorf("WHERE %s: expected %v, got %v", test.where, test.want, got)
 		}
 	}
+
+	// Setting a column to NULL removes the value, not just its contents
+	_, _ = engine.Execute("UPDATE testdb.users SET name = NULL WHERE id = 2")
+	if got := ids("SELECT id FROM testdb.users WHERE name IS NULL"); !slices.Equal(got, []string{"1", "2"}) {
+		t.Errorf("Expected ids 1 and 2 to be NULL after UPDATE, got %v", got)
+	}
+
+	if _, err := engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (NULL, 'Dan', 40)"); err == nil {
+		t.Error("Expected error for NULL primary key")
+	}
+
+	// Only the keywords are special: quoted 'NULL' and 'NOW()' are plain strings
+	_, _ = engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (4, 'NULL', 1), (5, 'NOW()', 2)")
+	if got := ids("SELECT id FROM testdb.users WHERE name = 'NOW()' OR name = 'NULL'"); !slices.Equal(got, []string{"4", "5"}) {
+		t.Errorf("Expected the quoted strings to be stored as written, got %v", got)
+	}
 }
 
-func TestEngineDelete(t *testing.T) {
+func TestEngineNullGroupsAndDistinct(t *testing.T) {
 	engine := setupTestEngine(t)
-	insertTestData(t, engine)
+	_, _ = engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (1, NULL, 30), (2, '', 25), (3, '', NULL), (4, NULL, 40), (5, 'Eve', NULL)")
 
-	// Delete by primary key
-	_, err := engine.Execute("DELETE FROM testdb.users WHERE id = 2")
-	if err != nil {
-		t.Fatalf("Failed to execute DELETE: %v", err)
+	data := func(query string) [][]string {
+		t.Helper()
+		result, err := engine.Execute(query)
+		if err != nil {
+			t.Fatalf("Failed to execute %q: %v", query, err)
+		}
+		return result.(QueryResult).Data
 	}
 
-	// Verify deletion
-	result, _ := engine.Execute("SELECT COUNT(*) FROM testdb.users")
-	qr := result.(QueryResult)
-	if qr.Data[0][0] != "2" {
-		t.Errorf("Expected 2 records after delete, got %s", qr.Data[0][0])
+	// NULL and '' are separate groups and separate distinct values
+	if got, want := data("SELECT name, COUNT(*) FROM testdb.users GROUP BY name"), [][]string{{"", "2"}, {"", "2"}, {"Eve", "1"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GROUP BY: expected %v, got %v", want, got)
+	}
+	if got := data("SELECT DISTINCT name FROM testdb.users"); len(got) != 3 {
+		t.Errorf("DISTINCT: expected 3 values, got %v", got)
+	}
+	if got, want := data("SELECT DISTINCT ON (name) id FROM testdb.users ORDER BY id"), [][]string{{"1"}, {"2"}, {"5"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DISTINCT ON: expected %v, got %v", want, got)
+	}
+
+	// Aggregates over a column skip its NULLs; COUNT(*) counts every row
+	got := data("SELECT COUNT(*), COUNT(name), COUNT(DISTINCT name), COUNT(age), SUM(age), MIN(age), MAX(age) FROM testdb.users")
+	if want := [][]string{{"5", "3", "2", "3", "95", "25", "40"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Aggregates: expected %v, got %v", want, got)
 	}
 }
 
-func TestEngineDistinct(t *testing.T) {
+func TestEngineNotInNullSemantics(t *testing.T) {
 	engine := setupTestEngine(t)
+	_, _ = engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (1, 'Alice', 30), (2, '', 25), (3, NULL, 35), (4, 'Bob', 40)")
 
-	// Insert duplicates
-	_, _ = engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (1, 'Alice', 30)")
-	_, _ = engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (2, 'Alice', 30)")
-	_, _ = engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (3, 'Bob', 25)")
+	ids := func(query string) []string {
+		result, err := engine.Execute(query)
+		if err != nil {
+			t.Fatalf("Failed to execute %q: %v", query, err)
+		}
+		var ids []string
+		for _, row := range result.(QueryResult).Data {
+			ids = append(ids, row[0])
+		}
+		return ids
+	}
 
-	result, err := engine.Execute("SELECT DISTINCT name FROM testdb.users")
-	if err != nil {
-		t.Fatalf("Failed to execute DISTINCT: %v", err)
+	tests := []struct {
+		where string
+		want  []string
+	}{
+		// The empty string is a value; a NULL column matches neither IN nor NOT IN
+		{"name IN ('Alice', '')", []string{"1", "2"}},
+		{"name NOT IN ('Alice')", []string{"2", "4"}},
+		{"name NOT IN ('', 'Bob')", []string{"1"}},
+		{"NOT name IN ('Alice')", []string{"2", "4"}},
+		// A NULL in the list leaves unmatched values unknown
+		{"name IN ('Alice', NULL)", []string{"1"}},
+		{"name NOT IN ('Alice', NULL)", nil},
+		{"name NOT IN (NULL)", nil},
+		{"id NOT IN (1, NULL) OR age = 40", []string{"4"}},
+	}
+	for _, test := range tests {
+		if got := ids("SELECT id FROM testdb.users WHERE " + test.where); !slices.Equal(got, test.want) {
+			t.Errorf("WHERE %s: expected %v, got %v", test.where, test.want, got)
+		}
 	}
 
-	qr := result.(QueryResult)
-	if len(qr.Data) != 2 {
-		t.Errorf("Expected 2 distinct names, got %d", len(qr.Data))
+	if _, err := engine.Execute("DELETE FROM testdb.users WHERE id IN (1, NULL)"); err != nil {
+		t.Fatalf("DELETE failed: %v", err)
+	}
+	if got := ids("SELECT id FROM testdb.users"); !slices.Equal(got, []string{"2", "3", "4"}) {
+		t.Errorf("Expected only id 1 to be deleted, got %v", got)
 	}
 }
 
-func TestEngineShowDatabases(t *testing.T) {
+func TestEngineNotNull(t *testing.T) {
 	engine := setupTestEngine(t)
+	if _, err := engine.Execute("CREATE TABLE testdb.contacts (id INT PRIMARY KEY, email STRING NOT NULL, status STRING NOT NULL DEFAULT 'new', phone STRING)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
 
-	result, err := engine.Execute("SHOW DATABASES")
+	// Empty strings are values; NULL in a nullable column is allowed
+	if _, err := engine.Execute("INSERT INTO testdb.contacts (id, email, phone) VALUES (1, '', NULL)"); err != nil {
+		t.Fatalf("Failed to insert empty NOT NULL value: %v", err)
+	}
+
+	for _, query := range []string{
+		"INSERT INTO testdb.contacts (id, email) VALUES (2, NULL)",
+		"INSERT INTO testdb.contacts (id, phone) VALUES (2, '555')",
+		"INSERT INTO testdb.contacts (id, email, status) VALUES (2, 'a@b.c', NULL)",
+		"UPDATE testdb.contacts SET email = NULL WHERE id = 1",
+	} {
+		_, err := engine.Execute(query)
+		var constraintErr *ConstraintError
+		if !errors.As(err, &constraintErr) || !strings.Contains(err.Error(), "cannot be NULL") {
+			t.Errorf("%s: expected NOT NULL constraint error, got %v", query, err)
+		}
+	}
+
+	result, err := engine.Execute("SELECT id, email, status FROM testdb.contacts WHERE phone IS NULL")
 	if err != nil {
-		t.Fatalf("Failed to execute SHOW DATABASES: %v", err)
+		t.Fatalf("Failed to execute SELECT: %v", err)
+	}
+	if data := result.(QueryResult).Data; !reflect.DeepEqual(data, [][]string{{"1", "", "new"}}) {
+		t.Errorf("Expected only the first contact, unchanged, got %v", data)
 	}
 
-	qr := result.(QueryResult)
-	if qr.RecordsRead < 1 {
-		t.Error("Expected at least 1 database")
+	// A NOT NULL column added to a table with rows needs a DEFAULT to fill them
+	_, err = engine.Execute("ALTER TABLE testdb.contacts ADD COLUMN region STRING NOT NULL")
+	var constraintErr *ConstraintError
+	if !errors.As(err, &constraintErr) || constraintErr.Column != "region" {
+		t.Errorf("Expected NOT NULL without DEFAULT to be rejected, got %v", err)
+	}
+	if _, err := engine.Execute("ALTER TABLE testdb.contacts ADD COLUMN region STRING NOT NULL DEFAULT 'eu'"); err != nil {
+		t.Fatalf("Failed to add NOT NULL column with DEFAULT: %v", err)
+	}
+	if _, err := engine.Execute("INSERT INTO testdb.contacts (id, email, region) VALUES (2, 'a@b.c', NULL)"); err == nil {
+		t.Error("Expected NULL in the added NOT NULL column to be rejected")
+	}
+	if _, err := engine.Execute("INSERT INTO testdb.contacts (id, email) VALUES (3, 'c@d.e')"); err != nil {
+		t.Fatalf("Failed to insert with the added column's DEFAULT: %v", err)
+	}
+	result, err = engine.Execute("SELECT id, region FROM testdb.contacts")
+	if err != nil {
+		t.Fatalf("Failed to execute SELECT: %v", err)
+	}
+	if data := result.(QueryResult).Data; !reflect.DeepEqual(data, [][]string{{"1", "eu"}, {"3", "eu"}}) {
+		t.Errorf("Expected existing and new rows to take the DEFAULT, got %v", data)
+	}
+	if _, err := engine.Execute("ALTER TABLE testdb.contacts ADD COLUMN visits INT DEFAULT 'many'"); err == nil {
+		t.Error("Expected a DEFAULT of the wrong type to be rejected")
 	}
 }
 
-func TestEngineShowTables(t *testing.T) {
+func TestEngineSelectOrderBy(t *testing.T) {
 	engine := setupTestEngine(t)
+	insertTestData(t, engine)
 
-	result, err := engine.Execute("SHOW TABLES IN testdb")
+	result, err := engine.Execute("SELECT * FROM testdb.users ORDER BY age DESC")
 	if err != nil {
-		t.Fatalf("Failed to execute SHOW TABLES: %v", err)
+		t.Fatalf("Failed to execute SELECT: %v", err)
 	}
 
 	qr := result.(QueryResult)
-	if qr.RecordsRead < 1 {
-		t.Error("Expected at least 1 table")
+	if len(qr.Data) < 1 {
+		t.Fatal("Expected at least 1 row")
+	}
+
+	// Charlie (35) should be first
+	firstRow := qr.Data[0]
+	found := false
+	for _, val := range firstRow {
+		if val == "Charlie" {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Error("Expected Charlie to be first with ORDER BY age DESC")
 	}
 }
 
-func TestEngineDescribe(t *testing.T) {
+func TestEngineSelectOrderByQualified(t *testing.T) {
 	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+	_, _ = engine.Execute("CREATE TABLE testdb.orders (id INT PRIMARY KEY, user_id INT, name STRING)")
+	_, _ = engine.Execute("INSERT INTO testdb.orders (id, user_id, name) VALUES (1, 3, 'b'), (2, 1, 'c'), (3, 2, 'a')")
 
-	result, err := engine.Execute("DESCRIBE testdb.users")
-	if err != nil {
-		t.Fatalf("Failed to execute DESCRIBE: %v", err)
+	tests := []struct {
+		query    string
+		expected [][]string
+	}{
+		// Both tables have a name column; the qualifier picks the right one
+		{"SELECT u.name, o.name FROM testdb.users u JOIN testdb.orders o ON u.id = o.user_id ORDER BY o.name DESC",
+			[][]string{{"Alice", "c"}, {"Charlie", "b"}, {"Bob", "a"}}},
+		{"SELECT users.name FROM testdb.users JOIN testdb.orders ON users.id = orders.user_id ORDER BY testdb.orders.name",
+			[][]string{{"Bob"}, {"Charlie"}, {"Alice"}}},
+		// Without a join, rows are keyed by plain column names
+		{"SELECT u.name FROM testdb.users u ORDER BY u.age DESC", [][]string{{"Charlie"}, {"Alice"}, {"Bob"}}},
+		{"SELECT name FROM testdb.users ORDER BY testdb.users.name DESC", [][]string{{"Charlie"}, {"Bob"}, {"Alice"}}},
 	}
 
-	qr := result.(QueryResult)
-	if qr.RecordsRead != 3 {
-		t.Errorf("Expected 3 columns in DESCRIBE, got %d", qr.RecordsRead)
+	for _, test := range tests {
+		result, err := engine.Execute(test.query)
+		if err != nil {
+			t.Fatalf("%s: %v", test.query, err)
+		}
+		if got := result.(QueryResult).Data; !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.query, test.expected, got)
+		}
+	}
+
+	// A column both tables have must be qualified after a join
+	for _, query := range []string{
+		"SELECT u.name FROM testdb.users u JOIN testdb.orders o ON u.id = o.user_id ORDER BY name",
+		"SELECT name FROM testdb.users u JOIN testdb.orders o ON u.id = o.user_id",
+		"SELECT u.name FROM testdb.users u JOIN testdb.orders o ON u.id = o.user_id WHERE id = 1",
+	} {
+		if _, err := engine.Execute(query); err == nil || !strings.Contains(err.Error(), "ambiguous column") {
+			t.Errorf("%s: expected an ambiguous column error, got %v", query, err)
+		}
+	}
+	result, err := engine.Execute("SELECT o.*, u.name FROM testdb.users u JOIN testdb.orders o ON u.id = o.user_id ORDER BY age")
+	if err != nil {
+		t.Fatalf("Failed to sort by a column only one table has: %v", err)
+	}
+	if got := len(result.(QueryResult).Data); got != 3 {
+		t.Errorf("Expected 3 rows, got %d", got)
 	}
 }
 
-func TestEngineBeginCommit(t *testing.T) {
+func TestEngineSelectLiteralColumns(t *testing.T) {
 	engine := setupTestEngine(t)
+	insertTestData(t, engine)
 
-	_, err := engine.Execute("BEGIN")
+	result, err := engine.Execute("SELECT id, 'active' AS status, 1 AS version, 'it''s' FROM testdb.users ORDER BY id")
 	if err != nil {
-		t.Fatalf("Failed to execute BEGIN: %v", err)
+		t.Fatalf("Failed to execute SELECT: %v", err)
+	}
+	qr := result.(QueryResult)
+	if want := []string{"id", "status", "version", "'it''s'"}; !reflect.DeepEqual(qr.Columns, want) {
+		t.Errorf("Expected columns %v, got %v", want, qr.Columns)
+	}
+	expected := [][]string{{"1", "active", "1", "it's"}, {"2", "active", "1", "it's"}, {"3", "active", "1", "it's"}}
+	if !reflect.DeepEqual(qr.Data, expected) {
+		t.Errorf("Expected %v, got %v", expected, qr.Data)
 	}
 
-	_, err = engine.Execute("COMMIT")
+	// Literals mix with function calls
+	result, err = engine.Execute("SELECT UPPER(name), 'x' AS tag FROM testdb.users WHERE id = 1")
 	if err != nil {
-		t.Fatalf("Failed to execute COMMIT: %v", err)
+		t.Fatalf("Failed to execute SELECT: %v", err)
+	}
+	if got := result.(QueryResult).Data; !reflect.DeepEqual(got, [][]string{{"ALICE", "x"}}) {
+		t.Errorf("Expected [[ALICE x]], got %v", got)
+	}
+
+	for _, query := range []string{
+		"SELECT id, 'x' AS name FROM testdb.users",
+		"SELECT COUNT(*), 'x' AS tag FROM testdb.users",
+	} {
+		if _, err := engine.Execute(query); err == nil {
+			t.Errorf("%s: expected an error", query)
+		}
 	}
 }
 
-func TestEngineRollback(t *testing.T) {
+func TestEngineAggregateValidation(t *testing.T) {
 	engine := setupTestEngine(t)
+	insertTestData(t, engine)
 
-	_, err := engine.Execute("ROLLBACK")
-	if err != nil {
-		t.Fatalf("Failed to execute ROLLBACK: %v", err)
+	invalid := map[string]string{
+		"SELECT name, COUNT(*) FROM testdb.users":                      "column name must appear in GROUP BY",
+		"SELECT name, age, COUNT(*) FROM testdb.users GROUP BY name":   "column age must appear in GROUP BY",
+		"SELECT UPPER(name), COUNT(*) FROM testdb.users GROUP BY name": "UPPER(name) must appear in GROUP BY",
+		"SELECT DISTINCT ON (name) COUNT(*) FROM testdb.users":         "DISTINCT ON cannot be combined with aggregates",
+		"SELECT COUNT(*) OVER () FROM testdb.users":                    "window functions (OVER) are not supported",
+	}
+	for query, message := range invalid {
+		_, err := engine.Execute(query)
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("%s: expected error containing %q, got %v", query, message, err)
+		}
+		if err := engine.Validate(query); err == nil {
+			t.Errorf("Validate(%s): expected an error", query)
+		}
+	}
+
+	valid := map[string][][]string{
+		"SELECT age, COUNT(*) FROM testdb.users GROUP BY age ORDER BY age":              {{"25", "1"}, {"30", "1"}, {"35", "1"}},
+		"SELECT UPPER(name) AS n, COUNT(*) FROM testdb.users GROUP BY n ORDER BY n":     {{"ALICE", "1"}, {"BOB", "1"}, {"CHARLIE", "1"}},
+		"SELECT name FROM testdb.users GROUP BY name HAVING COUNT(*) > 0 ORDER BY name": {{"Alice"}, {"Bob"}, {"Charlie"}},
+		"SELECT u.age, MAX(id) FROM testdb.users u GROUP BY age HAVING MAX(id) > 2":     {{"35", "3"}},
+	}
+	for query, expected := range valid {
+		result, err := engine.Execute(query)
+		if err != nil {
+			t.Errorf("%s: %v", query, err)
+			continue
+		}
+		if got := result.(QueryResult).Data; !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: expected %v, got %v", query, expected, got)
+		}
 	}
 }
 
-func TestEngineCreateDropIndex(t *testing.T) {
+func TestEngineSelectLimit(t *testing.T) {
 	engine := setupTestEngine(t)
+	insertTestData(t, engine)
 
-	_, err := engine.Execute("CREATE INDEX idx_name ON testdb.users(name)")
+	result, err := engine.Execute("SELECT * FROM testdb.users LIMIT 2")
 	if err != nil {
-		t.Fatalf("Failed to CREATE INDEX: %v", err)
+		t.Fatalf("Failed to execute SELECT: %v", err)
 	}
 
-	_, _ = engine.Execute("DROP INDEX idx_name ON testdb.users")
+	qr := result.(QueryResult)
+	if len(qr.Data) != 2 {
+		t.Errorf("Expected 2 records with LIMIT 2, got %d", len(qr.Data))
+	}
+}
+
+func TestEngineSelectOffsetFetch(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+
+	tests := []struct {
+		query    string
+		expected [][]string
+	}{
+		{"SELECT name FROM testdb.users OFFSET 1", [][]string{{"Bob"}, {"Charlie"}}},
+		{"SELECT name FROM testdb.users LIMIT 0", [][]string{}},
+		{"SELECT name FROM testdb.users FETCH FIRST 2 ROWS ONLY", [][]string{{"Alice"}, {"Bob"}}},
+		{"SELECT name FROM testdb.users ORDER BY age OFFSET 1 ROW FETCH NEXT 1 ROW ONLY", [][]string{{"Alice"}}},
+	}
+
+	for _, test := range tests {
+		result, err := engine.Execute(test.query)
+		if err != nil {
+			t.Fatalf("%s: %v", test.query, err)
+		}
+		if got := result.(QueryResult).Data; !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.query, test.expected, got)
+		}
+	}
+
+	if _, err := engine.Execute("SELECT * FROM testdb.users LIMIT 1 FETCH FIRST 2 ROWS ONLY"); err == nil {
+		t.Error("Expected error combining LIMIT and FETCH FIRST")
+	}
+}
+
+func TestEngineKeywordCase(t *testing.T) {
+	engine := setupTestEngine(t)
+	_, err := engine.Execute("create table testdb.events (id int primary key, key string, date date)")
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	_, err = engine.Execute("Insert Into testdb.events (id, key, date) Values (1, 'b', '2024-01-02'), (2, 'a', '2024-01-01'), (3, 'c', '2024-01-03')")
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	result, err := engine.Execute("sElEcT key, date fRoM testdb.events WhErE date >= '2024-01-02' oRdEr bY key DeSc")
+	if err != nil {
+		t.Fatalf("Failed to execute mixed-case SELECT: %v", err)
+	}
+
+	want := [][]string{{"c", "2024-01-03"}, {"b", "2024-01-02"}}
+	if data := result.(QueryResult).Data; !slices.EqualFunc(data, want, slices.Equal) {
+		t.Errorf("Expected %v, got %v", want, data)
+	}
+}
+
+func TestEngineSelectDefaultOrder(t *testing.T) {
+	engine := setupTestEngine(t)
+	_, _ = engine.Execute("CREATE TABLE testdb.spread (id INT PRIMARY KEY, name STRING, age INT) WITH FANOUT 1")
+
+	for _, table := range []string{"users", "spread"} {
+		for _, id := range []string{"10", "2", "33", "1", "4"} {
+			_, _ = engine.Execute("INSERT INTO testdb." + table + " (id, name, age) VALUES (" + id + ", 'n" + id + "', 20)")
+		}
+
+		var previous [][]string
+		for i := 0; i < 3; i++ {
+			result, err := engine.Execute("SELECT id FROM testdb." + table)
+			if err != nil {
+				t.Fatalf("Failed to execute SELECT: %v", err)
+			}
+			data := result.(QueryResult).Data
+			if previous != nil && !slices.EqualFunc(data, previous, slices.Equal) {
+				t.Errorf("%s: scan %d returned %v, previous scan returned %v", table, i, data, previous)
+			}
+			previous = data
+		}
+
+		var ids []string
+		for _, row := range previous {
+			ids = append(ids, row[0])
+		}
+		if want := []string{"1", "2", "4", "10", "33"}; !slices.Equal(ids, want) {
+			t.Errorf("%s: expected primary key order %v, got %v", table, want, ids)
+		}
+	}
+}
+
+func TestEngineCount(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+
+	result, err := engine.Execute("SELECT COUNT(*) FROM testdb.users")
+	if err != nil {
+		t.Fatalf("Failed to execute COUNT: %v", err)
+	}
+
+	qr := result.(QueryResult)
+	if len(qr.Data) != 1 || len(qr.Data[0]) != 1 {
+		t.Fatal("Expected single count result")
+	}
+	if qr.Data[0][0] != "3" {
+		t.Errorf("Expected count of 3, got %s", qr.Data[0][0])
+	}
+}
+
+func TestEngineCountFastPath(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+	if _, err := engine.Execute("CREATE INDEX idx_age ON testdb.users(age)"); err != nil {
+		t.Fatalf("Failed to CREATE INDEX: %v", err)
+	}
+	_, _ = engine.Execute("UPDATE testdb.users SET age = 30 WHERE id = 2")
+
+	tests := []struct {
+		query    string
+		columns  []string
+		expected [][]string
+	}{
+		{"SELECT COUNT(*) AS total, COUNT(*) FROM testdb.users", []string{"total", "COUNT(*)"}, [][]string{{"3", "3"}}},
+		{"SELECT COUNT(*) FROM testdb.users WHERE age > 30", []string{"COUNT(*)"}, [][]string{{"1"}}},
+		{"SELECT COUNT(*) FROM testdb.users WHERE age = 30", []string{"COUNT(*)"}, [][]string{{"2"}}},
+		{"SELECT COUNT(*) FROM testdb.users WHERE id = 3", []string{"COUNT(*)"}, [][]string{{"1"}}},
+		{"SELECT COUNT(*) FROM testdb.users WHERE id = 9", []string{"COUNT(*)"}, [][]string{{"0"}}},
+		{"SELECT COUNT(*) FROM testdb.users LIMIT 0", []string{"COUNT(*)"}, [][]string{}},
+		{"SELECT COUNT(*) FROM testdb.users OFFSET 1", []string{"COUNT(*)"}, [][]string{}},
+		{"SELECT COUNT(*) FROM testdb.users WHERE age = 30 LIMIT 1", []string{"COUNT(*)"}, [][]string{{"2"}}},
+		{"SELECT age, COUNT(*) FROM testdb.users GROUP BY age LIMIT 1 OFFSET 1", []string{"age", "COUNT(*)"}, [][]string{{"35", "1"}}},
+	}
+
+	for _, test := range tests {
+		result, err := engine.Execute(test.query)
+		if err != nil {
+			t.Fatalf("%s: %v", test.query, err)
+		}
+		qr := result.(QueryResult)
+		if !reflect.DeepEqual(qr.Columns, test.columns) || !reflect.DeepEqual(qr.Data, test.expected) {
+			t.Errorf("%s: expected %v %v, got %v %v", test.query, test.columns, test.expected, qr.Columns, qr.Data)
+		}
+	}
+
+	// Rows written in an open transaction are counted
+	_, _ = engine.Execute("BEGIN")
+	_, _ = engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (4, 'Dave', 40)")
+	_, _ = engine.Execute("DELETE FROM testdb.users WHERE id = 1")
+	result, err := engine.Execute("SELECT COUNT(*) FROM testdb.users")
+	_, _ = engine.Execute("ROLLBACK")
+	if err != nil {
+		t.Fatalf("Failed to COUNT in transaction: %v", err)
+	}
+	if got := result.(QueryResult).Data[0][0]; got != "3" {
+		t.Errorf("Expected count of 3 in transaction, got %s", got)
+	}
+
+	if _, err := engine.Execute("SELECT COUNT(*) FROM testdb.users WHERE agee = 30"); err == nil {
+		t.Error("Expected error for unknown WHERE column")
+	}
+}
+
+func TestEngineAggregateFilter(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+
+	result, err := engine.Execute("SELECT COUNT(*) FILTER (WHERE age > 28) AS older, COUNT(*) FILTER (WHERE age <= 28) AS younger, SUM(age) FILTER (WHERE age > 28) FROM testdb.users")
+	if err != nil {
+		t.Fatalf("Failed to execute filtered aggregates: %v", err)
+	}
+
+	qr := result.(QueryResult)
+	if len(qr.Columns) != 3 || qr.Columns[0] != "older" || qr.Columns[1] != "younger" {
+		t.Fatalf("Unexpected columns: %v", qr.Columns)
+	}
+	if len(qr.Data) != 1 {
+		t.Fatalf("Expected single result row, got %d", len(qr.Data))
+	}
+	if got := qr.Data[0]; got[0] != "2" || got[1] != "1" || got[2] != "65" {
+		t.Errorf("Expected [2 1 65], got %v", got)
+	}
+}
+
+func TestEngineCountStarGrouping(t *testing.T) {
+	engine := setupTestEngine(t)
+	_, _ = engine.Execute("CREATE TABLE testdb.orders (id INT PRIMARY KEY, region STRING)")
+	_, _ = engine.Execute("INSERT INTO testdb.orders (id, region) VALUES (1, 'eu'), (2, 'us'), (3, 'eu')")
+
+	tests := []struct {
+		query    string
+		expected [][]string
+	}{
+		{"SELECT COUNT(*) FROM testdb.orders", [][]string{{"3"}}},
+		{"SELECT DISTINCT COUNT(*) FROM testdb.orders", [][]string{{"3"}}},
+		{"SELECT COUNT(*) FROM testdb.orders GROUP BY region", [][]string{{"eu", "2"}, {"us", "1"}}},
+		{"SELECT region, COUNT(*) FROM testdb.orders GROUP BY region", [][]string{{"eu", "2"}, {"us", "1"}}},
+		{"SELECT DISTINCT region, COUNT(*) FROM testdb.orders GROUP BY region", [][]string{{"eu", "2"}, {"us", "1"}}},
+		{"SELECT COUNT(*) FROM testdb.orders WHERE region = 'none'", [][]string{{"0"}}},
+	}
+
+	for _, test := range tests {
+		result, err := engine.Execute(test.query)
+		if err != nil {
+			t.Fatalf("%s: %v", test.query, err)
+		}
+		if got := result.(QueryResult).Data; !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.query, test.expected, got)
+		}
+	}
+
+	// The deprecated CountAll flag still runs as COUNT(*)
+	result, err := engine.ExecuteStatement(sql.SelectStatement{Database: "testdb", Table: "orders", CountAll: true})
+	if err != nil {
+		t.Fatalf("CountAll: %v", err)
+	}
+	if got := result.(QueryResult).Data; !reflect.DeepEqual(got, [][]string{{"3"}}) {
+		t.Errorf("CountAll: expected [[3]], got %v", got)
+	}
+}
+
+func TestEngineCountDistinctHaving(t *testing.T) {
+	engine := setupTestEngine(t)
+	_, _ = engine.Execute("CREATE TABLE testdb.assignments (id INT PRIMARY KEY, dept STRING, project STRING)")
+	// Distinct projects per dept: eng 3 (of 5 rows), ops 2 (of 3), hr 1 (of 1 with a project)
+	_, _ = engine.Execute("INSERT INTO testdb.assignments (id, dept, project) VALUES " +
+		"(1, 'eng', 'api'), (2, 'eng', 'web'), (3, 'eng', 'api'), (4, 'eng', 'db'), (5, 'eng', 'web'), " +
+		"(6, 'ops', 'ci'), (7, 'ops', 'ci'), (8, 'ops', 'infra'), (9, 'hr', 'hiring')")
+	_, _ = engine.Execute("INSERT INTO testdb.assignments (id, dept) VALUES (10, 'hr')")
+
+	tests := []struct {
+		query    string
+		expected [][]string
+	}{
+		{"SELECT dept, COUNT(DISTINCT project) FROM testdb.assignments GROUP BY dept", [][]string{{"eng", "3"}, {"ops", "2"}, {"hr", "1"}}},
+		{"SELECT dept, COUNT(DISTINCT project) FROM testdb.assignments GROUP BY dept HAVING COUNT(DISTINCT project) > 2", [][]string{{"eng", "3"}}},
+		{"SELECT dept, COUNT(DISTINCT project) AS projects FROM testdb.assignments GROUP BY dept HAVING projects >= 2", [][]string{{"eng", "3"}, {"ops", "2"}}},
+		// HAVING may compare an aggregate that is not selected
+		{"SELECT dept, COUNT(DISTINCT project) FROM testdb.assignments GROUP BY dept HAVING COUNT(*) = 2", [][]string{{"hr", "1"}}},
+		{"SELECT dept FROM testdb.assignments GROUP BY dept HAVING COUNT(DISTINCT project) = 2", [][]string{{"ops"}}},
+		// WHERE filters rows before they are deduplicated and grouped
+		{"SELECT dept, COUNT(DISTINCT project) FROM testdb.assignments WHERE id > 2 GROUP BY dept HAVING COUNT(DISTINCT project) >= 2 AND dept != 'ops'", [][]string{{"eng", "3"}}},
+		{"SELECT COUNT(DISTINCT dept), COUNT(*) FROM testdb.assignments", [][]string{{"3", "10"}}},
+	}
+
+	for _, test := range tests {
+		result, err := engine.Execute(test.query)
+		if err != nil {
+			t.Fatalf("%s: %v", test.query, err)
+		}
+		if got := result.(QueryResult).Data; !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.query, test.expected, got)
+		}
+	}
+
+	if _, err := engine.Execute("SELECT dept FROM testdb.assignments GROUP BY dept HAVING COUNT(DISTINCT projct) > 1"); err == nil || !strings.Contains(err.Error(), "unknown column projct") {
+		t.Errorf("Expected unknown column error, got %v", err)
+	}
+}
+
+func TestEngineUnknownColumn(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+
+	for _, query := range []string{
+		"SELECT naem FROM testdb.users",
+		"SELECT * FROM testdb.users WHERE agee > 20",
+		"SELECT * FROM testdb.users ORDER BY nmae",
+		"SELECT nmae, COUNT(*) FROM testdb.users GROUP BY nmae",
+		"SELECT SUM(agee) FROM testdb.users",
+		"SELECT UPPER(naem) FROM testdb.users",
+		"SELECT YEAR(creatd), COUNT(*) FROM testdb.users GROUP BY YEAR(creatd)",
+		"SELECT u.naem FROM testdb.users u",
+		"SELECT * FROM testdb.users u WHERE u.agee > 20",
+		"SELECT LOWER(u.naem) FROM testdb.users u",
+	} {
+		_, err := engine.Execute(query)
+		if err == nil || !strings.Contains(err.Error(), "unknown column") {
+			t.Errorf("%s: expected unknown column error, got %v", query, err)
+		}
+	}
+
+	// Qualifiers must name the source table, a joined table or an alias
+	for _, query := range []string{
+		"SELECT x.name FROM testdb.users u",
+		"SELECT * FROM testdb.users WHERE x.age > 20",
+		"SELECT UPPER(x.name) FROM testdb.users",
+	} {
+		_, err := engine.Execute(query)
+		if err == nil || !strings.Contains(err.Error(), "unknown table x") {
+			t.Errorf("%s: expected unknown table error, got %v", query, err)
+		}
+	}
+
+	// Qualified arguments read the row; literal arguments are taken as written
+	result, err := engine.Execute("SELECT UPPER(u.name), CONCAT(name, 'name') AS tagged FROM testdb.users u WHERE u.id = 1")
+	if err != nil {
+		t.Fatalf("Failed to select: %v", err)
+	}
+	if want := [][]string{{"ALICE", "Alicename"}}; !slices.EqualFunc(result.(QueryResult).Data, want, slices.Equal) {
+		t.Errorf("Expected %v, got %v", want, result.(QueryResult).Data)
+	}
+
+	// Aggregate aliases may be used in ORDER BY
+	if _, err := engine.Execute("SELECT name, COUNT(*) AS total FROM testdb.users GROUP BY name ORDER BY total"); err != nil {
+		t.Errorf("Expected ORDER BY aggregate alias to be accepted, got %v", err)
+	}
+}
+
+func TestEngineQualifiedWildcard(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+	_, _ = engine.Execute("CREATE TABLE testdb.orders (order_id INT PRIMARY KEY, user_id INT, total INT)")
+	_, _ = engine.Execute("INSERT INTO testdb.orders (order_id, user_id, total) VALUES (10, 1, 99), (11, 2, 15)")
+
+	tests := []struct {
+		query   string
+		columns []string
+	}{
+		{"SELECT u.*, o.total FROM testdb.users u JOIN testdb.orders o ON u.id = o.user_id", []string{"id", "name", "age", "o.total"}},
+		{"SELECT users.*, orders.total FROM testdb.users JOIN testdb.orders ON users.id = orders.user_id", []string{"id", "name", "age", "orders.total"}},
+	}
+
+	for _, test := range tests {
+		result, err := engine.Execute(test.query)
+		if err != nil {
+			t.Fatalf("%s: %v", test.query, err)
+		}
+		qr := result.(QueryResult)
+		if !reflect.DeepEqual(qr.Columns, test.columns) {
+			t.Errorf("%s: expected columns %v, got %v", test.query, test.columns, qr.Columns)
+		}
+		if !reflect.DeepEqual(qr.Data, [][]string{{"1", "Alice", "30", "99"}, {"2", "Bob", "25", "15"}}) {
+			t.Errorf("%s: unexpected data %v", test.query, qr.Data)
+		}
+	}
+
+	if _, err := engine.Execute("SELECT x.* FROM testdb.users u"); err == nil || !strings.Contains(err.Error(), "unknown table x") {
+		t.Errorf("Expected unknown table error, got %v", err)
+	}
+}
+
+func TestEngineCrossDatabaseJoin(t *testing.T) {
+	engine := setupTestEngine(t)
+	for _, query := range []string{
+		"CREATE DATABASE sales",
+		"CREATE DATABASE crm",
+		"CREATE TABLE sales.orders (id INT PRIMARY KEY, customer_id INT, total INT)",
+		"CREATE TABLE crm.customers (id INT PRIMARY KEY, name STRING)",
+		"INSERT INTO sales.orders (id, customer_id, total) VALUES (1, 10, 100), (2, 20, 200), (3, 10, 300)",
+		"INSERT INTO crm.customers (id, name) VALUES (10, 'Alice'), (20, 'Bob')",
+	} {
+		if _, err := engine.Execute(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+
+	tests := []struct {
+		query    string
+		expected [][]string
+	}{
+		// Both tables have an id column; each keeps its own value
+		{"SELECT * FROM sales.orders o JOIN crm.customers c ON o.customer_id = c.id",
+			[][]string{{"1", "10", "100", "10", "Alice"}, {"2", "20", "200", "20", "Bob"}, {"3", "10", "300", "10", "Alice"}}},
+		{"SELECT o.id, c.name FROM sales.orders o JOIN crm.customers c ON o.customer_id = c.id",
+			[][]string{{"1", "Alice"}, {"2", "Bob"}, {"3", "Alice"}}},
+		{"SELECT sales.orders.id, crm.customers.name FROM sales.orders JOIN crm.customers ON sales.orders.customer_id = crm.customers.id",
+			[][]string{{"1", "Alice"}, {"2", "Bob"}, {"3", "Alice"}}},
+		{"SELECT o.*, c.name FROM sales.orders o JOIN crm.customers c ON o.customer_id = c.id",
+			[][]string{{"1", "10", "100", "Alice"}, {"2", "20", "200", "Bob"}, {"3", "10", "300", "Alice"}}},
+	}
+
+	for _, test := range tests {
+		result, err := engine.Execute(test.query)
+		if err != nil {
+			t.Fatalf("%s: %v", test.query, err)
+		}
+		if got := result.(QueryResult).Data; !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.query, test.expected, got)
+		}
+	}
+}
+
+func TestEngineCorruptRowReported(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+
+	if _, err := engine.Persistence.SaveRecord("testdb", "users", map[string][]byte{"4": []byte("{not json")}, engine.Identity); err != nil {
+		t.Fatalf("Failed to store corrupt row: %v", err)
+	}
+
+	for _, query := range []string{"SELECT * FROM testdb.users", "SELECT COUNT(*) FROM testdb.users WHERE id > 0"} {
+		result, err := engine.Execute(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		if qr := result.(QueryResult); qr.CorruptRows != 1 {
+			t.Errorf("%s: expected 1 corrupt row reported, got %d", query, qr.CorruptRows)
+		}
+		if warnings := engine.Warnings(); len(warnings) != 1 || warnings[0] != "skipped corrupt row 4 in testdb.users" {
+			t.Errorf("%s: expected a corrupt row warning, got %v", query, warnings)
+		}
+	}
+
+	// COUNT(*) without WHERE counts stored rows without reading them
+	result, err := engine.Execute("SELECT COUNT(*) FROM testdb.users")
+	if err != nil {
+		t.Fatalf("COUNT failed: %v", err)
+	}
+	if qr := result.(QueryResult); qr.Data[0][0] != "4" || qr.CorruptRows != 0 {
+		t.Errorf("Expected 4 stored rows and none read, got %v with %d corrupt", qr.Data, qr.CorruptRows)
+	}
+
+	engine.StrictReads = true
+	_, err = engine.Execute("SELECT * FROM testdb.users")
+	if err == nil || !strings.Contains(err.Error(), "corrupt row 4 in testdb.users") {
+		t.Errorf("Expected corrupt row error in strict mode, got %v", err)
+	}
+}
+
+func TestEngineInvalidUTF8(t *testing.T) {
+	engine := setupTestEngine(t)
+
+	_, err := engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (1, '\xff\xfe', 30)")
+	if err == nil || !strings.Contains(err.Error(), "invalid UTF-8 in column name") {
+		t.Errorf("Expected invalid UTF-8 error on INSERT, got %v", err)
+	}
+
+	_, _ = engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (1, 'Alice', 30)")
+	_, err = engine.Execute("UPDATE testdb.users SET name = '\xc3\x28' WHERE id = 1")
+	if err == nil || !strings.Contains(err.Error(), "invalid UTF-8") {
+		t.Errorf("Expected invalid UTF-8 error on UPDATE, got %v", err)
+	}
+}
+
+func TestEngineTimestampFormats(t *testing.T) {
+	engine := setupTestEngine(t)
+	if _, err := engine.Execute("CREATE TABLE testdb.events (id INT PRIMARY KEY, at TIMESTAMP)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"2024-06-15 14:30:00", "2024-06-15 14:30:00"},
+		{"2024-06-15T14:30:00Z", "2024-06-15 14:30:00"},
+		{"2024-06-15T14:30:00", "2024-06-15 14:30:00"},
+		{"2024-06-15T16:30:00+02:00", "2024-06-15 14:30:00"},
+		{"2024-06-15T14:30:00.250Z", "2024-06-15 14:30:00.25"},
+		{"2024-06-15", "2024-06-15 00:00:00"},
+	}
+	for i, test := range tests {
+		id := strconv.Itoa(i + 1)
+		if _, err := engine.Execute("INSERT INTO testdb.events (id, at) VALUES (" + id + ", '" + test.value + "')"); err != nil {
+			t.Fatalf("Failed to insert %s: %v", test.value, err)
+		}
+		result, err := engine.Execute("SELECT at FROM testdb.events WHERE id = " + id)
+		if err != nil {
+			t.Fatalf("Failed to select: %v", err)
+		}
+		if got := result.(QueryResult).Data[0][0]; got != test.want {
+			t.Errorf("Expected %s stored as %s, got %s", test.value, test.want, got)
+		}
+	}
+
+	_, err := engine.Execute("INSERT INTO testdb.events (id, at) VALUES (99, '15.06.2024')")
+	if err == nil || !strings.Contains(err.Error(), "YYYY-MM-DDTHH:MM:SS") {
+		t.Errorf("Expected error listing accepted formats, got %v", err)
+	}
+
+	// UPDATE, COPY and PrepareInsert store the same canonical form
+	if _, err := engine.Execute("UPDATE testdb.events SET at = '2024-07-01T08:00:00+02:00' WHERE id = 1"); err != nil {
+		t.Fatalf("UPDATE failed: %v", err)
+	}
+	csvPath := filepath.Join(t.TempDir(), "events.csv")
+	if err := os.WriteFile(csvPath, []byte("id,at\n20,2024-07-02T09:00:00Z\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	if _, err := engine.Execute("COPY INTO testdb.events FROM '" + csvPath + "'"); err != nil {
+		t.Fatalf("COPY failed: %v", err)
+	}
+	stmt, err := engine.PrepareInsert("testdb", "events", "id", "at")
+	if err != nil {
+		t.Fatalf("PrepareInsert failed: %v", err)
+	}
+	if err := stmt.Add("21", "2024-07-03T10:00:00-01:00"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if _, err := stmt.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	result, err := engine.Execute("SELECT id, at FROM testdb.events WHERE id = 1 OR id >= 20")
+	if err != nil {
+		t.Fatalf("Failed to select: %v", err)
+	}
+	want := [][]string{{"1", "2024-07-01 06:00:00"}, {"20", "2024-07-02 09:00:00"}, {"21", "2024-07-03 11:00:00"}}
+	if got := result.(QueryResult).Data; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestEngineBlobRoundTrip(t *testing.T) {
+	engine := setupTestEngine(t)
+	if _, err := engine.Execute("CREATE TABLE testdb.files (id INT PRIMARY KEY, data BLOB)"); err != nil {
+		t.Fatalf("Failed to create BLOB table: %v", err)
+	}
+
+	// Every byte value, including NUL and quotes that SQL literals cannot hold
+	binary := make([]byte, 256)
+	for i := range binary {
+		binary[i] = byte(i)
+	}
+	_, err := engine.ExecuteStatement(sql.InsertStatement{
+		Database:  "testdb",
+		Table:     "files",
+		Columns:   []string{"id", "data"},
+		ValueRows: [][]string{{"1", string(binary)}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert binary data: %v", err)
+	}
+	if _, err := engine.Execute("INSERT INTO testdb.files (id, data) VALUES (2, '\xff\x80')"); err != nil {
+		t.Fatalf("Failed to insert binary literal: %v", err)
+	}
+
+	result, err := engine.Execute("SELECT data FROM testdb.files ORDER BY id")
+	if err != nil {
+		t.Fatalf("Failed to select BLOB: %v", err)
+	}
+	qr := result.(QueryResult)
+	if len(qr.Data) != 2 || qr.Data[0][0] != string(binary) || qr.Data[1][0] != "\xff\x80" {
+		t.Errorf("BLOB values did not round-trip: %q", qr.Data)
+	}
+
+	result, err = engine.Execute("SELECT id FROM testdb.files WHERE data = '\xff\x80'")
+	if err != nil {
+		t.Fatalf("Failed to filter on BLOB: %v", err)
+	}
+	if qr := result.(QueryResult); len(qr.Data) != 1 || qr.Data[0][0] != "2" {
+		t.Errorf("Expected WHERE on BLOB to match row 2, got %v", qr.Data)
+	}
+}
+
+func TestEngineBase64Functions(t *testing.T) {
+	engine := setupTestEngine(t)
+	if _, err := engine.Execute("CREATE TABLE testdb.images (id INT PRIMARY KEY, data BINARY)"); err != nil {
+		t.Fatalf("Failed to create BINARY table: %v", err)
+	}
+
+	// NUL, 0xff and a quote cannot be written as a SQL literal
+	if _, err := engine.Execute("INSERT INTO testdb.images (id, data) VALUES (1, FROM_BASE64('AP8n'))"); err != nil {
+		t.Fatalf("Failed to insert with FROM_BASE64: %v", err)
+	}
+
+	result, err := engine.Execute("SELECT TO_BASE64(data), FROM_BASE64('aGk=') FROM testdb.images")
+	if err != nil {
+		t.Fatalf("Failed to select base64 functions: %v", err)
+	}
+	qr := result.(QueryResult)
+	if len(qr.Data) != 1 || qr.Data[0][0] != "AP8n" || qr.Data[0][1] != "hi" {
+		t.Errorf("Expected [AP8n hi], got %q", qr.Data)
+	}
+
+	result, err = engine.Execute("SELECT data FROM testdb.images WHERE id = 1")
+	if err != nil {
+		t.Fatalf("Failed to select BLOB: %v", err)
+	}
+	if qr := result.(QueryResult); len(qr.Data) != 1 || qr.Data[0][0] != "\x00\xff'" {
+		t.Errorf("Expected raw bytes, got %q", qr.Data)
+	}
+
+	if _, err := engine.Execute("INSERT INTO testdb.images (id, data) VALUES (2, FROM_BASE64('not base64!'))"); err == nil {
+		t.Error("Expected error for invalid base64")
+	}
+}
+
+func TestEngineFunctionAliasReference(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+
+	// The second function reads the first one's alias
+	result, err := engine.Execute("SELECT CONCAT(name, '-', age) AS label, UPPER(label) AS shout FROM testdb.users WHERE id = 1")
+	if err != nil {
+		t.Fatalf("Failed to select with an alias reference: %v", err)
+	}
+	want := [][]string{{"Alice-30", "ALICE-30"}}
+	if data := result.(QueryResult).Data; !slices.EqualFunc(data, want, slices.Equal) {
+		t.Errorf("Expected %v, got %v", want, data)
+	}
+
+	// A column of the same name wins over an alias
+	result, err = engine.Execute("SELECT UPPER(name) AS age, LENGTH(age) AS digits FROM testdb.users WHERE id = 1")
+	if err != nil {
+		t.Fatalf("Failed to select with a shadowing alias: %v", err)
+	}
+	if data := result.(QueryResult).Data; data[0][1] != "2" {
+		t.Errorf("Expected LENGTH of the age column, got %v", data)
+	}
+
+	if _, err := engine.Execute("SELECT UPPER(label) AS shout, CONCAT(name, age) AS label FROM testdb.users"); err == nil || !strings.Contains(err.Error(), "before it is defined") {
+		t.Errorf("Expected error for a forward alias reference, got %v", err)
+	}
+}
+
+func TestEngineFunctionKeepsSelectPosition(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+
+	result, err := engine.Execute("SELECT name, UPPER(name) AS u, id FROM testdb.users WHERE id = 1")
+	if err != nil {
+		t.Fatalf("Failed to select: %v", err)
+	}
+	qr := result.(QueryResult)
+	if want := []string{"name", "u", "id"}; !slices.Equal(qr.Columns, want) {
+		t.Errorf("Expected columns %v, got %v", want, qr.Columns)
+	}
+	if want := [][]string{{"Alice", "ALICE", "1"}}; !slices.EqualFunc(qr.Data, want, slices.Equal) {
+		t.Errorf("Expected %v, got %v", want, qr.Data)
+	}
+
+	// Qualified columns next to a function read the row like any column
+	result, err = engine.Execute("SELECT u.id, LOWER(name) FROM testdb.users u WHERE id = 2")
+	if err != nil {
+		t.Fatalf("Failed to select: %v", err)
+	}
+	if want := [][]string{{"2", "bob"}}; !slices.EqualFunc(result.(QueryResult).Data, want, slices.Equal) {
+		t.Errorf("Expected %v, got %v", want, result.(QueryResult).Data)
+	}
+}
+
+func TestEngineGroupByPipeValues(t *testing.T) {
+	engine := setupTestEngine(t)
+	_, _ = engine.Execute("CREATE TABLE testdb.paths (id INT PRIMARY KEY, a STRING, b STRING)")
+	_, _ = engine.Execute("INSERT INTO testdb.paths (id, a, b) VALUES (1, 'x|y', 'z'), (2, 'x', 'y|z'), (3, 'x|y', 'z')")
+
+	result, err := engine.Execute("SELECT a, b, COUNT(*) FROM testdb.paths GROUP BY a, b")
+	if err != nil {
+		t.Fatalf("Failed to execute GROUP BY: %v", err)
+	}
+
+	qr := result.(QueryResult)
+	want := [][]string{{"x|y", "z", "2"}, {"x", "y|z", "1"}}
+	if !slices.EqualFunc(qr.Data, want, slices.Equal) {
+		t.Errorf("Expected groups %v, got %v", want, qr.Data)
+	}
+}
+
+func TestEngineUpdate(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+
+	// Update by primary key
+	_, err := engine.Execute("UPDATE testdb.users SET age = 31 WHERE id = 1")
+	if err != nil {
+		t.Fatalf("Failed to execute UPDATE: %v", err)
+	}
+
+	// Verify update
+	result, _ := engine.Execute("SELECT * FROM testdb.users WHERE id = 1")
+	qr := result.(QueryResult)
+
+	// Find age column value
+	ageIdx := -1
+	for i, col := range qr.Columns {
+		if col == "age" {
+			ageIdx = i
+			break
+		}
+	}
+
+	if ageIdx >= 0 && len(qr.Data) > 0 {
+		if qr.Data[0][ageIdx] != "31" {
+			t.Errorf("Expected age to be updated to 31, got %s", qr.Data[0][ageIdx])
+		}
+	}
+}
+
+func TestEngineUpdateUnchanged(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+
+	before := engine.Persistence.LatestTransaction()
+	result, err := engine.Execute("UPDATE testdb.users SET age = 30 WHERE id = 1")
+	if err != nil {
+		t.Fatalf("Failed to execute UPDATE: %v", err)
+	}
+	cr := result.(CommitResult)
+	if !cr.Transaction.Unchanged || cr.Transaction.Id != "" || cr.RecordsWritten != 0 {
+		t.Errorf("Expected an unchanged result with no records written, got %+v", cr)
+	}
+	if after := engine.Persistence.LatestTransaction(); after.Id != before.Id {
+		t.Errorf("Expected no commit, HEAD moved from %s to %s", before.Id, after.Id)
+	}
+
+	var out strings.Builder
+	cr.Print(&out, DisplayOptions{})
+	if !strings.HasPrefix(out.String(), "0 records changed, no commit") {
+		t.Errorf("Expected no-op summary, got %q", out.String())
+	}
+
+	result, err = engine.Execute("UPDATE testdb.users SET age = 31 WHERE id = 1")
+	if err != nil {
+		t.Fatalf("Failed to execute UPDATE: %v", err)
+	}
+	if cr := result.(CommitResult); cr.Transaction.Unchanged || cr.RecordsWritten != 1 {
+		t.Errorf("Expected a real change to commit, got %+v", cr)
+	}
+}
+
+func TestEngineDelete(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+
+	// Delete by primary key
+	_, err := engine.Execute("DELETE FROM testdb.users WHERE id = 2")
+	if err != nil {
+		t.Fatalf("Failed to execute DELETE: %v", err)
+	}
+
+	// Verify deletion
+	result, _ := engine.Execute("SELECT COUNT(*) FROM testdb.users")
+	qr := result.(QueryResult)
+	if qr.Data[0][0] != "2" {
+		t.Errorf("Expected 2 records after delete, got %s", qr.Data[0][0])
+	}
+}
+
+func TestEngineDeleteUpdateLimit(t *testing.T) {
+	engine := setupTestEngine(t)
+	if _, err := engine.Execute("CREATE TABLE testdb.logs (id INT PRIMARY KEY, level STRING)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	for i := 1; i <= 6; i++ {
+		level := "debug"
+		if i%3 == 0 {
+			level = "info"
+		}
+		if _, err := engine.Execute("INSERT INTO testdb.logs (id, level) VALUES (" + strconv.Itoa(i) + ", '" + level + "')"); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+	ids := func() [][]string {
+		t.Helper()
+		result, err := engine.Execute("SELECT id FROM testdb.logs WHERE level = 'debug'")
+		if err != nil {
+			t.Fatalf("SELECT failed: %v", err)
+		}
+		return result.(QueryResult).Data
+	}
+
+	// Four rows match; the first three by primary key are deleted
+	result, err := engine.Execute("DELETE FROM testdb.logs WHERE level = 'debug' LIMIT 3")
+	if err != nil {
+		t.Fatalf("Failed to execute DELETE: %v", err)
+	}
+	if deleted := result.(CommitResult).RecordsDeleted; deleted != 3 {
+		t.Errorf("Expected 3 records deleted, got %d", deleted)
+	}
+	if got := ids(); !reflect.DeepEqual(got, [][]string{{"5"}}) {
+		t.Errorf("Expected only row 5 left at debug, got %v", got)
+	}
+
+	result, err = engine.Execute("UPDATE testdb.logs SET level = 'debug' WHERE level = 'info' LIMIT 1")
+	if err != nil {
+		t.Fatalf("Failed to execute UPDATE: %v", err)
+	}
+	if written := result.(CommitResult).RecordsWritten; written != 1 {
+		t.Errorf("Expected 1 record written, got %d", written)
+	}
+	if got := ids(); !reflect.DeepEqual(got, [][]string{{"3"}, {"5"}}) {
+		t.Errorf("Expected rows 3 and 5 at debug, got %v", got)
+	}
+}
+
+func TestEngineStoredRowKeyOrder(t *testing.T) {
+	engine := setupTestEngine(t)
+	if _, err := engine.Execute("INSERT INTO testdb.users (age, name, id) VALUES (30, 'Alice', 1)"); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	stored := func() string {
+		t.Helper()
+		data, exists := engine.GetRecord("testdb", "users", "1")
+		if !exists {
+			t.Fatal("Expected row 1 to exist")
+		}
+		return string(data)
+	}
+
+	// Keys follow the schema's column order, not the INSERT's or the alphabet's
+	if got := stored(); got != `{"id":"1","name":"Alice","age":"30"}` {
+		t.Errorf("Expected keys in column order, got %s", got)
+	}
+
+	// Rewriting the same logical row yields the same blob
+	if _, err := engine.Execute("UPDATE testdb.users SET name = 'Alicia' WHERE id = 1"); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+	first := stored()
+	if _, err := engine.Execute("UPDATE testdb.users SET age = 31, name = 'Bob' WHERE id = 1"); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+	if _, err := engine.Execute("UPDATE testdb.users SET name = 'Alicia', age = 30 WHERE id = 1"); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+	if second := stored(); second != first {
+		t.Errorf("Expected identical blobs for the same row, got %s and %s", first, second)
+	}
+}
+
+func TestEngineUpdateDeleteUseIndex(t *testing.T) {
+	engine := setupTestEngine(t)
+	if _, err := engine.Execute("CREATE TABLE testdb.customers (id INT PRIMARY KEY, region STRING, tier STRING)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	regions := []string{"West", "East", "North", "South"}
+	for i := 1; i <= 8; i++ {
+		if _, err := engine.Execute("INSERT INTO testdb.customers (id, region, tier) VALUES (" + strconv.Itoa(i) + ", '" + regions[i%4] + "', 'basic')"); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	// Without an index the UPDATE reads all 8 rows, plus the write
+	result, err := engine.Execute("UPDATE testdb.customers SET tier = 'silver' WHERE region = 'East'")
+	if err != nil {
+		t.Fatalf("Failed to execute UPDATE: %v", err)
+	}
+	scanOps := result.(CommitResult).ExecutionOps
+	if scanOps != 9 {
+		t.Errorf("Expected a full scan of 8 rows and a write, got %d ops", scanOps)
+	}
+
+	if _, err := engine.Execute("CREATE INDEX idx_region ON testdb.customers(region)"); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	result, err = engine.Execute("UPDATE testdb.customers SET tier = 'gold' WHERE region = 'West'")
+	if err != nil {
+		t.Fatalf("Failed to execute UPDATE: %v", err)
+	}
+	cr := result.(CommitResult)
+	if cr.RecordsWritten != 2 {
+		t.Errorf("Expected 2 records written, got %d", cr.RecordsWritten)
+	}
+	if cr.ExecutionOps >= scanOps {
+		t.Errorf("Expected the index lookup to read fewer rows than a scan, got %d ops", cr.ExecutionOps)
+	}
+	result, err = engine.Execute("SELECT id FROM testdb.customers WHERE tier = 'gold'")
+	if err != nil {
+		t.Fatalf("SELECT failed: %v", err)
+	}
+	if got := result.(QueryResult).Data; !reflect.DeepEqual(got, [][]string{{"4"}, {"8"}}) {
+		t.Errorf("Expected rows 4 and 8 at gold, got %v", got)
+	}
+
+	// The index follows the update, so a second lookup finds the new value
+	result, err = engine.Execute("DELETE FROM testdb.customers WHERE region = 'East' AND tier = 'silver'")
+	if err != nil {
+		t.Fatalf("Failed to execute DELETE: %v", err)
+	}
+	if cr := result.(CommitResult); cr.RecordsDeleted != 2 || cr.ExecutionOps != 3 {
+		t.Errorf("Expected 2 rows deleted after reading 2, got %d deleted in %d ops", cr.RecordsDeleted, cr.ExecutionOps)
+	}
+
+	// OR can match rows outside the index lookup, so it scans
+	result, err = engine.Execute("UPDATE testdb.customers SET tier = 'basic' WHERE region = 'West' OR id = 3")
+	if err != nil {
+		t.Fatalf("Failed to execute UPDATE: %v", err)
+	}
+	if written := result.(CommitResult).RecordsWritten; written != 2 {
+		t.Errorf("Expected rows 4 and 8 reset and row 3 unchanged, got %d written", written)
+	}
+}
+
+func TestEngineDeleteInList(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+	_, _ = engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (4, 'Dave', 40)")
+	if _, err := engine.Execute("CREATE INDEX idx_name ON testdb.users(name)"); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	// 9 does not exist and is ignored
+	result, err := engine.Execute("DELETE FROM testdb.users WHERE id IN (1, 3, 9)")
+	if err != nil {
+		t.Fatalf("Failed to execute DELETE: %v", err)
+	}
+	cr := result.(CommitResult)
+	if cr.RecordsDeleted != 2 {
+		t.Errorf("Expected 2 records deleted, got %d", cr.RecordsDeleted)
+	}
+
+	// Both rows are gone in the delete's own transaction
+	result, err = engine.Execute("SELECT id FROM testdb.users AS OF '" + cr.Transaction.Id + "'")
+	if err != nil {
+		t.Fatalf("Failed to read delete transaction: %v", err)
+	}
+	if got := result.(QueryResult).Data; !reflect.DeepEqual(got, [][]string{{"2"}, {"4"}}) {
+		t.Errorf("Expected rows 2 and 4 at the delete transaction, got %v", got)
+	}
+
+	// The index no longer holds the deleted rows
+	indexManager := ps.NewIndexManager(engine.Persistence, engine.Identity)
+	table, err := engine.Persistence.GetTable("testdb", "users")
+	if err != nil {
+		t.Fatalf("Failed to get table: %v", err)
+	}
+	if err := indexManager.LoadIndexes("testdb", "users", table.Columns); err != nil {
+		t.Fatalf("Failed to load indexes: %v", err)
+	}
+	idx, found := indexManager.GetIndex("testdb", "users", "name")
+	if !found {
+		t.Fatal("Expected index on name")
+	}
+	for name, expected := range map[string][]string{"Alice": nil, "Charlie": nil, "Bob": {"2"}} {
+		if got := idx.Lookup(name); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected index entry %v for %s, got %v", expected, name, got)
+		}
+	}
+}
+
+func TestEngineDistinct(t *testing.T) {
+	engine := setupTestEngine(t)
+
+	// Insert duplicates
+	_, _ = engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (1, 'Alice', 30)")
+	_, _ = engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (2, 'Alice', 30)")
+	_, _ = engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (3, 'Bob', 25)")
+
+	result, err := engine.Execute("SELECT DISTINCT name FROM testdb.users")
+	if err != nil {
+		t.Fatalf("Failed to execute DISTINCT: %v", err)
+	}
+
+	qr := result.(QueryResult)
+	if len(qr.Data) != 2 {
+		t.Errorf("Expected 2 distinct names, got %d", len(qr.Data))
+	}
+}
+
+func TestEngineDistinctOn(t *testing.T) {
+	engine := setupTestEngine(t)
+	_, _ = engine.Execute("CREATE TABLE testdb.orders (id INT PRIMARY KEY, customer STRING, created DATE)")
+	_, _ = engine.Execute("INSERT INTO testdb.orders (id, customer, created) VALUES " +
+		"(1, 'alice', '2024-01-05'), (2, 'bob', '2024-02-01'), (3, 'alice', '2024-03-10'), " +
+		"(4, 'carol', '2024-01-20'), (5, 'bob', '2024-01-15'), (6, 'alice', '2024-02-28')")
+
+	result, err := engine.Execute("SELECT DISTINCT ON (customer) id, customer, created FROM testdb.orders ORDER BY customer, created DESC")
+	if err != nil {
+		t.Fatalf("Failed to execute DISTINCT ON: %v", err)
+	}
+
+	want := [][]string{
+		{"3", "alice", "2024-03-10"},
+		{"2", "bob", "2024-02-01"},
+		{"4", "carol", "2024-01-20"},
+	}
+	if got := result.(QueryResult).Data; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected latest order per customer %v, got %v", want, got)
+	}
+
+	if _, err := engine.Execute("SELECT DISTINCT ON (missing) * FROM testdb.orders"); err == nil || !strings.Contains(err.Error(), "unknown column missing") {
+		t.Errorf("Expected unknown column error, got %v", err)
+	}
+}
+
+func TestEngineShowDatabases(t *testing.T) {
+	engine := setupTestEngine(t)
+
+	result, err := engine.Execute("SHOW DATABASES")
+	if err != nil {
+		t.Fatalf("Failed to execute SHOW DATABASES: %v", err)
+	}
+
+	qr := result.(QueryResult)
+	if qr.RecordsRead < 1 {
+		t.Error("Expected at least 1 database")
+	}
+}
+
+func TestEngineShowTables(t *testing.T) {
+	engine := setupTestEngine(t)
+
+	result, err := engine.Execute("SHOW TABLES IN testdb")
+	if err != nil {
+		t.Fatalf("Failed to execute SHOW TABLES: %v", err)
+	}
+
+	qr := result.(QueryResult)
+	if qr.RecordsRead < 1 {
+		t.Error("Expected at least 1 table")
+	}
+}
+
+func TestEngineShowTableStatus(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+	_, _ = engine.Execute("CREATE TABLE testdb.empty (id INT PRIMARY KEY)")
+
+	result, err := engine.Execute("SHOW TABLE STATUS IN testdb")
+	if err != nil {
+		t.Fatalf("Failed to execute SHOW TABLE STATUS: %v", err)
+	}
+
+	qr := result.(QueryResult)
+	status := make(map[string][]string)
+	for _, row := range qr.Data {
+		status[row[0]] = row
+	}
+	if users := status["users"]; users == nil || users[1] != "3" || users[2] == "0" || users[3] == "" {
+		t.Errorf("Expected users with 3 rows, a size and a commit, got %v", users)
+	}
+	if empty := status["empty"]; empty == nil || empty[1] != "0" || empty[2] != "0" {
+		t.Errorf("Expected empty table with 0 rows and 0 bytes, got %v", empty)
+	}
+}
+
+func TestEngineShowStats(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+
+	result, err := engine.Execute("SHOW STATS")
+	if err != nil {
+		t.Fatalf("Failed to execute SHOW STATS: %v", err)
+	}
+	qr := result.(QueryResult)
+	if len(qr.Data) != 1 || len(qr.Data[0]) != len(qr.Columns) {
+		t.Fatalf("Expected one row of %v, got %v", qr.Columns, qr.Data)
+	}
+	stats := make(map[string]int)
+	for i, column := range qr.Columns {
+		stats[column], _ = strconv.Atoi(qr.Data[0][i])
+	}
+	// CREATE DATABASE, CREATE TABLE and three inserts
+	if stats["Commits"] != 5 {
+		t.Errorf("Expected 5 commits, got %d", stats["Commits"])
+	}
+	if stats["Objects"] < stats["Commits"] || stats["LooseObjects"] != stats["Objects"] {
+		t.Errorf("Expected loose objects for every commit, got %v", stats)
+	}
+}
+
+func TestEngineDescribe(t *testing.T) {
+	engine := setupTestEngine(t)
+
+	result, err := engine.Execute("DESCRIBE testdb.users")
+	if err != nil {
+		t.Fatalf("Failed to execute DESCRIBE: %v", err)
+	}
+
+	qr := result.(QueryResult)
+	if qr.RecordsRead != 3 {
+		t.Errorf("Expected 3 columns in DESCRIBE, got %d", qr.RecordsRead)
+	}
+}
+
+func TestEngineTableComments(t *testing.T) {
+	engine := setupTestEngine(t)
+	if _, err := engine.Execute("CREATE TABLE testdb.orders (id INT PRIMARY KEY, total FLOAT COMMENT 'Amount in cents') COMMENT 'Customer orders'"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	result, err := engine.Execute("DESCRIBE testdb.orders")
+	if err != nil {
+		t.Fatalf("Failed to execute DESCRIBE: %v", err)
+	}
+	qr := result.(QueryResult)
+	if qr.Columns[3] != "Comment" || qr.Data[0][3] != "" || qr.Data[1][3] != "Amount in cents" {
+		t.Errorf("Expected column comment in DESCRIBE, got %v %v", qr.Columns, qr.Data)
+	}
+
+	result, err = engine.Execute("SHOW TABLE STATUS IN testdb")
+	if err != nil {
+		t.Fatalf("Failed to execute SHOW TABLE STATUS: %v", err)
+	}
+	for _, row := range result.(QueryResult).Data {
+		if row[0] == "orders" && row[5] != "Customer orders" {
+			t.Errorf("Expected table comment in SHOW TABLE STATUS, got %v", row)
+		}
+	}
+}
+
+func TestEngineShowCreateTable(t *testing.T) {
+	engine := setupTestEngine(t)
+	create := "CREATE TABLE testdb.orders (id INT PRIMARY KEY, status STRING NOT NULL DEFAULT 'new', " +
+		"placed TIMESTAMP DEFAULT NOW(), total FLOAT COMMENT 'Amount in cents, it''s tax-free', CHECK (total >= 0)) " +
+		"COMMENT 'Customer orders' AUTHOR 'Loader <loader@example.com>' WITH FANOUT 2"
+	if _, err := engine.Execute(create); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	showCreate := func() string {
+		t.Helper()
+		result, err := engine.Execute("SHOW CREATE TABLE testdb.orders")
+		if err != nil {
+			t.Fatalf("SHOW CREATE TABLE failed: %v", err)
+		}
+		return result.(QueryResult).Data[0][1]
+	}
+	shown := showCreate()
+	want := "CREATE TABLE testdb.orders (id INT PRIMARY KEY, status STRING NOT NULL DEFAULT 'new', " +
+		"placed TIMESTAMP DEFAULT NOW(), total FLOAT COMMENT 'Amount in cents, it''s tax-free', CHECK (total >= 0)) " +
+		"COMMENT 'Customer orders' AUTHOR 'Loader <loader@example.com>' WITH FANOUT 2"
+	if shown != want {
+		t.Errorf("Expected %q, got %q", want, shown)
+	}
+
+	// The statement recreates the same schema
+	if _, err := engine.Execute("DROP TABLE testdb.orders"); err != nil {
+		t.Fatalf("DROP TABLE failed: %v", err)
+	}
+	if _, err := engine.Execute(shown); err != nil {
+		t.Fatalf("Failed to run the shown statement: %v", err)
+	}
+	if got := showCreate(); got != shown {
+		t.Errorf("Expected the recreated table to show %q, got %q", shown, got)
+	}
+
+	if _, err := engine.Execute("SHOW CREATE TABLE testdb.missing"); !errors.Is(err, ps.ErrTableNotFound) {
+		t.Errorf("Expected ErrTableNotFound, got %v", err)
+	}
+}
+
+func TestEngineBeginCommit(t *testing.T) {
+	engine := setupTestEngine(t)
+
+	_, err := engine.Execute("BEGIN")
+	if err != nil {
+		t.Fatalf("Failed to execute BEGIN: %v", err)
+	}
+
+	_, err = engine.Execute("COMMIT")
+	if err != nil {
+		t.Fatalf("Failed to execute COMMIT: %v", err)
+	}
+}
+
+func TestEngineRollback(t *testing.T) {
+	engine := setupTestEngine(t)
+
+	_, err := engine.Execute("ROLLBACK")
+	if err != nil {
+		t.Fatalf("Failed to execute ROLLBACK: %v", err)
+	}
+}
+
+func TestEngineCommitHook(t *testing.T) {
+	engine := setupTestEngine(t)
+	var events []ps.CommitEvent
+	engine.AddCommitHook(func(event ps.CommitEvent) { events = append(events, event) })
+
+	result, err := engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (2, 'Bob', 25)")
+	if err != nil {
+		t.Fatalf("INSERT failed: %v", err)
+	}
+	expected := []ps.TableChange{{Database: "testdb", Table: "users", Written: []string{"2"}}}
+	if len(events) != 1 || events[0].Transaction.Id != result.(CommitResult).Transaction.Id || !reflect.DeepEqual(events[0].Changes, expected) {
+		t.Fatalf("Expected %+v in %s, got %+v", expected, result.(CommitResult).Transaction.Id, events)
+	}
+
+	// Schema changes are not reported
+	if _, err := engine.Execute("CREATE TABLE testdb.orders (id INT PRIMARY KEY)"); err != nil {
+		t.Fatalf("CREATE TABLE failed: %v", err)
+	}
+	if _, err := engine.Execute("DELETE FROM testdb.users WHERE id = 2"); err != nil {
+		t.Fatalf("DELETE failed: %v", err)
+	}
+	expected = []ps.TableChange{{Database: "testdb", Table: "users", Deleted: []string{"2"}}}
+	if len(events) != 2 || !reflect.DeepEqual(events[1].Changes, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, events[1:])
+	}
+}
+
+func TestEngineTransactionAcrossDatabases(t *testing.T) {
+	engine := setupTestEngine(t)
+	_, _ = engine.Execute("CREATE DATABASE otherdb")
+	_, _ = engine.Execute("CREATE TABLE otherdb.orders (id INT PRIMARY KEY, item STRING)")
+	if _, err := engine.Execute("CREATE INDEX idx_item ON otherdb.orders(item)"); err != nil {
+		t.Fatalf("Failed to CREATE INDEX: %v", err)
+	}
+
+	count := func(query string) int {
+		t.Helper()
+		result, err := engine.Execute(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		return len(result.(QueryResult).Data)
+	}
+	commits := func() int { return len(engine.TransactionsFrom(engine.LatestTransaction().Id)) }
+
+	before := commits()
+	for _, query := range []string{
+		"BEGIN",
+		"INSERT INTO testdb.users (id, name, age) VALUES (1, 'Alice', 30)",
+		"INSERT INTO otherdb.orders (id, item) VALUES (1, 'book')",
+		"UPDATE testdb.users SET age = 31 WHERE id = 1",
+		"UPDATE otherdb.orders SET item = 'novel' WHERE id = 1",
+	} {
+		if _, err := engine.Execute(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	if got := count("SELECT * FROM testdb.users WHERE age = 31"); got != 1 {
+		t.Errorf("Expected the transaction to read its own writes, got %d rows", got)
+	}
+	if commits() != before {
+		t.Fatalf("Expected no commits before COMMIT, got %d", commits()-before)
+	}
+	if _, err := engine.Execute("BEGIN"); err == nil {
+		t.Error("Expected error for nested BEGIN")
+	}
+
+	result, err := engine.Execute("COMMIT")
+	if err != nil {
+		t.Fatalf("Failed to COMMIT: %v", err)
+	}
+	if commits() != before+1 {
+		t.Fatalf("Expected exactly one commit, got %d", commits()-before)
+	}
+	if txn := result.(CommitResult).Transaction; txn.Id != engine.LatestTransaction().Id {
+		t.Errorf("Expected COMMIT to return the transaction's commit, got %v", txn)
+	}
+	if got := count("SELECT * FROM testdb.users") + count("SELECT * FROM otherdb.orders WHERE item = 'novel'"); got != 2 {
+		t.Errorf("Expected both inserts to be committed, got %d rows", got)
+	}
+	if engine.PendingWrites() != 0 {
+		t.Errorf("Expected write-behind to be disabled after COMMIT, got %d pending writes", engine.PendingWrites())
+	}
+
+	before = commits()
+	for _, query := range []string{
+		"BEGIN",
+		"INSERT INTO testdb.users (id, name, age) VALUES (2, 'Bob', 25)",
+		"INSERT INTO otherdb.orders (id, item) VALUES (2, 'pen')",
+		"UPDATE otherdb.orders SET item = 'pencil' WHERE id = 1",
+		"ROLLBACK",
+	} {
+		if _, err := engine.Execute(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	if commits() != before {
+		t.Errorf("Expected ROLLBACK to commit nothing, got %d commits", commits()-before)
+	}
+	if got := count("SELECT * FROM testdb.users") + count("SELECT * FROM otherdb.orders"); got != 2 {
+		t.Errorf("Expected ROLLBACK to discard both inserts, got %d rows", got)
+	}
+	if got := count("SELECT * FROM otherdb.orders WHERE item = 'novel'"); got != 1 {
+		t.Errorf("Expected ROLLBACK to discard the update, got %d rows", got)
+	}
+}
+
+func TestEngineTransactionIsolation(t *testing.T) {
+	engineA := setupTestEngine(t)
+	engineB := NewEngine(engineA.Persistence, engineA.Identity)
+
+	count := func(engine *Engine, query string) int {
+		t.Helper()
+		result, err := engine.Execute(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		return len(result.(QueryResult).Data)
+	}
+	exec := func(engine *Engine, query string) {
+		t.Helper()
+		if _, err := engine.Execute(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+
+	exec(engineA, "BEGIN")
+	exec(engineA, "INSERT INTO testdb.users (id, name, age) VALUES (1, 'Alice', 30)")
+	exec(engineB, "INSERT INTO testdb.users (id, name, age) VALUES (2, 'Bob', 25)")
+	if got := count(engineB, "SELECT * FROM testdb.users"); got != 1 {
+		t.Errorf("Expected B not to see A's uncommitted row, got %d rows", got)
+	}
+
+	// A BEGIN on B leaves A's transaction open
+	exec(engineB, "BEGIN")
+	exec(engineB, "ROLLBACK")
+	exec(engineA, "ROLLBACK")
+	if got := count(engineB, "SELECT id FROM testdb.users"); got != 1 {
+		t.Errorf("Expected only B's committed row to remain, got %d rows", got)
+	}
+
+	exec(engineA, "BEGIN")
+	for _, query := range []string{
+		"CREATE TABLE testdb.extra (id INT PRIMARY KEY)",
+		"CREATE INDEX idx_name ON testdb.users(name)",
+		"FLUSH",
+	} {
+		if _, err := engineA.Execute(query); err == nil {
+			t.Errorf("Expected %s to be rejected inside a transaction", query)
+		}
+	}
+	exec(engineA, "ROLLBACK")
+}
+
+func TestEngineTransactionIndexMerge(t *testing.T) {
+	engineA := setupTestEngine(t)
+	engineB := NewEngine(engineA.Persistence, engineA.Identity)
+	exec := func(engine *Engine, query string) {
+		t.Helper()
+		if _, err := engine.Execute(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	exec(engineA, "CREATE INDEX idx_name ON testdb.users(name)")
+	exec(engineA, "CREATE UNIQUE INDEX idx_age ON testdb.users(age)")
+
+	// Both transactions index a row; each COMMIT keeps the other's entries
+	exec(engineA, "BEGIN")
+	exec(engineB, "BEGIN")
+	exec(engineA, "INSERT INTO testdb.users (id, name, age) VALUES (1, 'Alice', 30)")
+	exec(engineB, "INSERT INTO testdb.users (id, name, age) VALUES (2, 'Bob', 25)")
+	exec(engineA, "COMMIT")
+	exec(engineB, "COMMIT")
+	for _, name := range []string{"Alice", "Bob"} {
+		result, err := engineA.Execute("SELECT id FROM testdb.users WHERE name = '" + name + "'")
+		if err != nil {
+			t.Fatalf("Index lookup failed: %v", err)
+		}
+		if data := result.(QueryResult).Data; len(data) != 1 {
+			t.Errorf("Expected the index to find %s, got %v", name, data)
+		}
+	}
+	result, err := engineA.Execute("CHECK DATABASE testdb")
+	if err != nil {
+		t.Fatalf("CHECK DATABASE failed: %v", err)
+	}
+	if data := result.(QueryResult).Data; len(data) != 0 {
+		t.Errorf("Expected a clean CHECK DATABASE, got %v", data)
+	}
+
+	// A unique value committed by another transaction fails the COMMIT
+	exec(engineA, "BEGIN")
+	exec(engineA, "INSERT INTO testdb.users (id, name, age) VALUES (3, 'Charlie', 35)")
+	exec(engineB, "INSERT INTO testdb.users (id, name, age) VALUES (4, 'Dana', 35)")
+	if _, err := engineA.Execute("COMMIT"); err == nil || !strings.Contains(err.Error(), "unique") {
+		t.Errorf("Expected COMMIT to fail on the unique index, got %v", err)
+	}
+	exec(engineA, "ROLLBACK")
+}
+
+func TestEngineSavepoints(t *testing.T) {
+	engine := setupTestEngine(t)
+	exec := func(query string) {
+		t.Helper()
+		if _, err := engine.Execute(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	ids := func() []string {
+		t.Helper()
+		result, err := engine.Execute("SELECT id FROM testdb.users ORDER BY id")
+		if err != nil {
+			t.Fatalf("SELECT failed: %v", err)
+		}
+		var ids []string
+		for _, row := range result.(QueryResult).Data {
+			ids = append(ids, row[0])
+		}
+		return ids
+	}
+
+	if _, err := engine.Execute("SAVEPOINT a"); err == nil {
+		t.Error("Expected SAVEPOINT outside a transaction to fail")
+	}
+
+	exec("BEGIN")
+	exec("INSERT INTO testdb.users (id, name, age) VALUES (1, 'Alice', 30)")
+	exec("SAVEPOINT a")
+	exec("INSERT INTO testdb.users (id, name, age) VALUES (2, 'Bob', 25)")
+	exec("SAVEPOINT b")
+	exec("DELETE FROM testdb.users WHERE id = 1")
+	exec("ROLLBACK TO SAVEPOINT b")
+	if got, want := ids(), []string{"1", "2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v after ROLLBACK TO b, got %v", want, got)
+	}
+
+	// Rolling back to a keeps a but forgets b
+	exec("ROLLBACK TO a")
+	if got, want := ids(), []string{"1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v after ROLLBACK TO a, got %v", want, got)
+	}
+	if _, err := engine.Execute("ROLLBACK TO b"); err == nil {
+		t.Error("Expected savepoint b to be gone")
+	}
+
+	exec("INSERT INTO testdb.users (id, name, age) VALUES (3, 'Charlie', 35)")
+	exec("RELEASE SAVEPOINT a")
+	if _, err := engine.Execute("ROLLBACK TO a"); err == nil {
+		t.Error("Expected savepoint a to be released")
+	}
+	exec("COMMIT")
+	if got, want := ids(), []string{"1", "3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v after COMMIT, got %v", want, got)
+	}
+}
+
+func TestEngineReadOnlyTransaction(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+	// A second session writing to the same database
+	writer := NewEngine(engine.Persistence, engine.Identity)
+
+	selectAll := func() [][]string {
+		t.Helper()
+		result, err := engine.Execute("SELECT id, name, age FROM testdb.users ORDER BY id")
+		if err != nil {
+			t.Fatalf("SELECT failed: %v", err)
+		}
+		return result.(QueryResult).Data
+	}
+
+	result, err := engine.Execute("BEGIN READ ONLY")
+	if err != nil {
+		t.Fatalf("Failed to BEGIN READ ONLY: %v", err)
+	}
+	if snapshot := result.(CommitResult).Transaction.Id; snapshot != engine.LatestTransaction().Id {
+		t.Errorf("Expected BEGIN READ ONLY to report HEAD %s, got %s", engine.LatestTransaction().Id, snapshot)
+	}
+	first := selectAll()
+
+	if _, err := writer.Execute("INSERT INTO testdb.users (id, name, age) VALUES (4, 'Dana', 40)"); err != nil {
+		t.Fatalf("Concurrent INSERT failed: %v", err)
+	}
+	if _, err := writer.Execute("UPDATE testdb.users SET age = 31 WHERE id = 1"); err != nil {
+		t.Fatalf("Concurrent UPDATE failed: %v", err)
+	}
+	if second := selectAll(); !reflect.DeepEqual(first, second) {
+		t.Errorf("Expected the pinned session to read the same rows, got %v then %v", first, second)
+	}
+	if _, err := engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (5, 'Eve', 22)"); err == nil || !strings.Contains(err.Error(), "READ ONLY") {
+		t.Errorf("Expected INSERT in a READ ONLY transaction to fail, got %v", err)
+	}
+
+	if _, err := engine.Execute("COMMIT"); err != nil {
+		t.Fatalf("Failed to COMMIT: %v", err)
+	}
+	if rows := selectAll(); len(rows) != 4 || rows[0][2] != "31" {
+		t.Errorf("Expected COMMIT to release the snapshot, got %v", rows)
+	}
+}
+
+func TestEngineReadOnlyTransactionView(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+	if _, err := engine.Execute("CREATE VIEW testdb.people AS SELECT id, name, age FROM testdb.users"); err != nil {
+		t.Fatalf("CREATE VIEW failed: %v", err)
+	}
+	if _, err := engine.Execute("CREATE MATERIALIZED VIEW testdb.cached_people AS SELECT id, name, age FROM testdb.users"); err != nil {
+		t.Fatalf("CREATE MATERIALIZED VIEW failed: %v", err)
+	}
+	if _, err := engine.Execute("BEGIN READ ONLY"); err != nil {
+		t.Fatalf("Failed to BEGIN READ ONLY: %v", err)
+	}
+
+	// The outer WHERE, aggregates and ORDER BY / LIMIT apply to the pinned view rows
+	for _, view := range []string{"people", "cached_people"} {
+		result, err := engine.Execute("SELECT COUNT(*) FROM testdb." + view + " WHERE age > 26")
+		if err != nil {
+			t.Fatalf("%s: COUNT failed: %v", view, err)
+		}
+		if data := result.(QueryResult).Data; len(data) != 1 || data[0][0] != "2" {
+			t.Errorf("%s: expected a count of 2, got %v", view, data)
+		}
+		result, err = engine.Execute("SELECT name FROM testdb." + view + " WHERE age > 26 ORDER BY age DESC LIMIT 1")
+		if err != nil {
+			t.Fatalf("%s: SELECT failed: %v", view, err)
+		}
+		if qr := result.(QueryResult); !reflect.DeepEqual(qr.Columns, []string{"name"}) || !reflect.DeepEqual(qr.Data, [][]string{{"Charlie"}}) {
+			t.Errorf("%s: expected [name] [[Charlie]], got %v %v", view, qr.Columns, qr.Data)
+		}
+	}
+}
+
+func TestEngineFlush(t *testing.T) {
+	engine := setupTestEngine(t)
+	engine.Persistence.EnableWriteBehind(ps.WriteBehind{})
+	insertTestData(t, engine)
+	if _, err := engine.Execute("DELETE FROM testdb.users WHERE id = 1"); err != nil {
+		t.Fatalf("DELETE failed: %v", err)
+	}
+
+	result, err := engine.Execute("FLUSH")
+	if err != nil {
+		t.Fatalf("FLUSH failed: %v", err)
+	}
+	if commit := result.(CommitResult); commit.RecordsWritten != 3 || commit.RecordsDeleted != 1 {
+		t.Errorf("Expected 3 writes and 1 delete flushed, got %d and %d", commit.RecordsWritten, commit.RecordsDeleted)
+	}
+	result, err = engine.Execute("FLUSH")
+	if err != nil {
+		t.Fatalf("Second FLUSH failed: %v", err)
+	}
+	if commit := result.(CommitResult); commit.RecordsWritten != 0 || commit.RecordsDeleted != 0 || !commit.Transaction.Unchanged {
+		t.Errorf("Expected an empty FLUSH to change nothing, got %+v", commit)
+	}
+}
+
+func TestEngineDryRun(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+	head := engine.LatestTransaction().Id
+
+	result, err := engine.Execute("DRY RUN DELETE FROM testdb.users WHERE age > 26")
+	if err != nil {
+		t.Fatalf("DRY RUN DELETE failed: %v", err)
+	}
+	if commit := result.(CommitResult); commit.RecordsDeleted != 2 || !commit.DryRun {
+		t.Errorf("Expected DRY RUN DELETE to report 2 rows as a dry run, got %+v", commit)
+	}
+	var out strings.Builder
+	result.(CommitResult).Print(&out, DisplayOptions{})
+	if !strings.HasPrefix(out.String(), "DRY RUN: 2 record(s) deleted, no commit") {
+		t.Errorf("Expected a dry run summary, got %q", out.String())
+	}
+	result, err = engine.Execute("DRY RUN UPDATE testdb.users SET age = 40 WHERE name = 'Bob'")
+	if err != nil {
+		t.Fatalf("DRY RUN UPDATE failed: %v", err)
+	}
+	if written := result.(CommitResult).RecordsWritten; written != 1 {
+		t.Errorf("Expected DRY RUN UPDATE to report 1 row, got %d", written)
+	}
+	result, err = engine.Execute("DRY RUN DROP DATABASE testdb")
+	if err != nil {
+		t.Fatalf("DRY RUN DROP DATABASE failed: %v", err)
+	}
+	if commit := result.(CommitResult); commit.TablesDeleted != 1 || commit.RecordsDeleted != 3 {
+		t.Errorf("Expected DRY RUN DROP DATABASE to report 1 table and 3 rows, got %d and %d", commit.TablesDeleted, commit.RecordsDeleted)
+	}
+
+	if engine.LatestTransaction().Id != head {
+		t.Error("Expected DRY RUN to commit nothing")
+	}
+	result, err = engine.Execute("DELETE FROM testdb.users WHERE id = 3")
+	if err != nil {
+		t.Fatalf("DELETE failed: %v", err)
+	}
+	if result.(CommitResult).DryRun {
+		t.Error("Expected a real DELETE not to be reported as a dry run")
+	}
+	result, err = engine.Execute("SELECT * FROM testdb.users WHERE age = 25")
+	if err != nil {
+		t.Fatalf("SELECT failed: %v", err)
+	}
+	if rows := len(result.(QueryResult).Data); rows != 1 {
+		t.Errorf("Expected the data to be unchanged, got %d rows with age 25", rows)
+	}
+	if _, err := engine.Execute("DRY RUN INSERT INTO testdb.users (id, name, age) VALUES (4, 'Dana', 40)"); err == nil {
+		t.Error("Expected DRY RUN INSERT to fail to parse")
+	}
+}
+
+func TestEngineReportKeys(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+
+	// Off by default
+	result, err := engine.Execute("DELETE FROM testdb.users WHERE id = 1")
+	if err != nil {
+		t.Fatalf("DELETE failed: %v", err)
+	}
+	if keys := result.(CommitResult).AffectedKeys; keys != nil {
+		t.Errorf("Expected no keys without report_keys, got %v", keys)
+	}
+
+	if _, err := engine.Execute("SET report_keys = TRUE"); err != nil {
+		t.Fatalf("SET report_keys failed: %v", err)
+	}
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"INSERT INTO testdb.users (id, name, age) VALUES (4, 'Dana', 41), (5, 'Eve', 22)", []string{"4", "5"}},
+		// Bob already has age 25, so only Eve is rewritten
+		{"UPDATE testdb.users SET age = 25 WHERE age < 30", []string{"5"}},
+		{"DELETE FROM testdb.users WHERE age > 26", []string{"3", "4"}},
+	}
+	for _, test := range tests {
+		result, err := engine.Execute(test.query)
+		if err != nil {
+			t.Fatalf("%s: %v", test.query, err)
+		}
+		if keys := result.(CommitResult).AffectedKeys; !reflect.DeepEqual(keys, test.expected) {
+			t.Errorf("%s: expected keys %v, got %v", test.query, test.expected, keys)
+		}
+	}
+}
+
+func TestEngineRenameDatabase(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+	mustExecute := func(query string) Result {
+		t.Helper()
+		result, err := engine.Execute(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		return result
+	}
+	mustExecute("CREATE INDEX idx_name ON testdb.users(name)")
+	mustExecute("CREATE TABLE testdb.audit (id INT PRIMARY KEY, name STRING)")
+	mustExecute("CREATE TRIGGER log_users AFTER INSERT ON testdb.users BEGIN INSERT INTO testdb.audit (id, name) VALUES (NEW.id, NEW.name); END")
+	mustExecute("CREATE VIEW testdb.adults AS SELECT name FROM testdb.users WHERE age > 26")
+	mustExecute("CREATE DATABASE reports")
+	mustExecute("CREATE VIEW reports.young AS SELECT name FROM testdb.users WHERE age < 26")
+
+	mustExecute("ALTER DATABASE testdb RENAME TO appdb")
+
+	result := mustExecute("SELECT id, name, age FROM appdb.users ORDER BY id")
+	expected := [][]string{{"1", "Alice", "30"}, {"2", "Bob", "25"}, {"3", "Charlie", "35"}}
+	if got := result.(QueryResult).Data; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	if _, err := engine.Execute("SELECT * FROM testdb.users"); err == nil {
+		t.Error("Expected the old database name to be gone")
+	}
+	tables := engine.Persistence.ListTables("appdb")
+	slices.Sort(tables)
+	if !reflect.DeepEqual(tables, []string{"audit", "users"}) {
+		t.Errorf("Expected tables [audit users], got %v", tables)
+	}
+
+	// The index, views and trigger follow the database
+	indexManager := ps.NewIndexManager(engine.Persistence, engine.Identity)
+	if err := indexManager.LoadIndexes("appdb", "users", []core.Column{{Name: "name"}}); err != nil {
+		t.Fatalf("LoadIndexes failed: %v", err)
+	}
+	if idx, found := indexManager.GetIndex("appdb", "users", "name"); !found || idx.Database != "appdb" || !reflect.DeepEqual(idx.Lookup("Bob"), []string{"2"}) {
+		t.Errorf("Expected idx_name to move to appdb, got %+v", idx)
+	}
+	result = mustExecute("SELECT name FROM appdb.adults ORDER BY name")
+	if got := result.(QueryResult).Data; !reflect.DeepEqual(got, [][]string{{"Alice"}, {"Charlie"}}) {
+		t.Errorf("Expected the moved view to read the renamed table, got %v", got)
+	}
+	result = mustExecute("SELECT name FROM reports.young")
+	if got := result.(QueryResult).Data; !reflect.DeepEqual(got, [][]string{{"Bob"}}) {
+		t.Errorf("Expected the other database's view to follow the rename, got %v", got)
+	}
+	mustExecute("INSERT INTO appdb.users (id, name, age) VALUES (4, 'Dana', 41)")
+	result = mustExecute("SELECT name FROM appdb.audit")
+	if got := result.(QueryResult).Data; !reflect.DeepEqual(got, [][]string{{"Dana"}}) {
+		t.Errorf("Expected the trigger to write to the renamed database, got %v", got)
+	}
+
+	for _, query := range []string{
+		"ALTER DATABASE appdb RENAME TO reports",
+		"ALTER DATABASE testdb RENAME TO other",
+	} {
+		if _, err := engine.Execute(query); err == nil {
+			t.Errorf("%s: expected an error", query)
+		}
+	}
+}
+
+func TestEngineQuotedStrings(t *testing.T) {
+	engine := setupTestEngine(t)
+	if _, err := engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (1, 'O''Brien; \\n', 30)"); err != nil {
+		t.Fatalf("INSERT failed: %v", err)
+	}
+	result, err := engine.Execute("SELECT name FROM testdb.users WHERE name = 'O''Brien; \\n'")
+	if err != nil {
+		t.Fatalf("SELECT failed: %v", err)
+	}
+	if data := result.(QueryResult).Data; len(data) != 1 || data[0][0] != `O'Brien; \n` {
+		t.Errorf("Expected O'Brien; \\n, got %v", data)
+	}
+}
+
+func TestEngineCreateTableWithValues(t *testing.T) {
+	engine := setupTestEngine(t)
+	commits := func() int { return len(engine.TransactionsFrom(engine.LatestTransaction().Id)) }
+
+	before := commits()
+	previous := engine.LatestTransaction().Id
+	result, err := engine.Execute("CREATE TABLE testdb.colors (id INT PRIMARY KEY, name STRING, hex STRING DEFAULT '000000') WITH FANOUT 2 VALUES (1, 'red', 'ff0000'), (2, 'black', NULL)")
+	if err != nil {
+		t.Fatalf("Failed to create table with values: %v", err)
+	}
+	commitResult := result.(CommitResult)
+	if commitResult.TablesCreated != 1 || commitResult.RecordsWritten != 2 {
+		t.Errorf("Expected 1 table and 2 records, got %d and %d", commitResult.TablesCreated, commitResult.RecordsWritten)
+	}
+	if commits() != before+1 {
+		t.Fatalf("Expected exactly one commit, got %d", commits()-before)
+	}
+
+	// The single commit holds both the schema and the rows
+	txn := commitResult.Transaction.Id
+	if _, err := engine.GetTableAtTransaction("testdb", "colors", txn); err != nil {
+		t.Errorf("Expected the table in the commit: %v", err)
+	}
+	if _, exists, _ := engine.GetRecordAtTransaction("testdb", "colors", "1", txn); !exists {
+		t.Error("Expected the seed row in the same commit")
+	}
+	if _, err := engine.GetTableAtTransaction("testdb", "colors", previous); err == nil {
+		t.Error("Expected no table before the commit")
+	}
+
+	result, err = engine.Execute("SELECT name, hex FROM testdb.colors ORDER BY id")
+	if err != nil {
+		t.Fatalf("Failed to select seed rows: %v", err)
+	}
+	data := result.(QueryResult).Data
+	if len(data) != 2 || data[0][1] != "ff0000" || data[1][0] != "black" {
+		t.Errorf("Unexpected seed rows: %v", data)
+	}
+
+	if _, err := engine.Execute("CREATE TABLE testdb.bad (id INT PRIMARY KEY, name STRING) VALUES (1)"); err == nil {
+		t.Error("Expected error for a seed row with too few values")
+	}
+	if tables := engine.ListTables("testdb"); slices.Contains(tables, "bad") {
+		t.Error("Expected a failed seed to create no table")
+	}
+}
+
+func TestEngineFunctionDefaultAndCheck(t *testing.T) {
+	engine := setupTestEngine(t)
+
+	_, err := engine.Execute("CREATE TABLE testdb.items (id INT PRIMARY KEY, name STRING, price FLOAT CHECK (price >= 0), created TIMESTAMP DEFAULT NOW(), CHECK (LENGTH(name) > 0))")
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	// DEFAULT NOW() fills in the time of the insert
+	before := time.Now().Truncate(time.Second)
+	if _, err := engine.Execute("INSERT INTO testdb.items (id, name, price) VALUES (1, 'pen', 2)"); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	result, err := engine.Execute("SELECT created FROM testdb.items WHERE id = 1")
+	if err != nil {
+		t.Fatalf("Failed to select: %v", err)
+	}
+	created, err := time.ParseInLocation("2006-01-02 15:04:05", result.(QueryResult).Data[0][0], time.Local)
+	if err != nil || created.Before(before) {
+		t.Errorf("Expected created to default to the insert time, got %q", result.(QueryResult).Data[0][0])
+	}
+
+	// CHECK (LENGTH(name) > 0) rejects an empty name
+	_, err = engine.Execute("INSERT INTO testdb.items (id, name, price) VALUES (2, '', 1)")
+	var constraintErr *ConstraintError
+	if !errors.As(err, &constraintErr) || constraintErr.Column != "name" {
+		t.Errorf("Expected a CHECK violation on name, got %v", err)
+	}
+	if _, err := engine.Execute("INSERT INTO testdb.items (id, name, price) VALUES (3, 'ink', -1)"); err == nil {
+		t.Error("Expected a CHECK violation for a negative price")
+	}
+	if _, err := engine.Execute("UPDATE testdb.items SET price = -5 WHERE id = 1"); err == nil {
+		t.Error("Expected UPDATE to enforce CHECK")
+	}
+
+	// A check on a NULL column passes
+	if _, err := engine.Execute("INSERT INTO testdb.items (id, name) VALUES (4, 'cap')"); err != nil {
+		t.Errorf("Expected a NULL price to pass its CHECK: %v", err)
+	}
+
+	if _, err := engine.Execute("CREATE TABLE testdb.bad (id INT PRIMARY KEY, name STRING CHECK (NOPE(name) > 0))"); err == nil {
+		t.Error("Expected error for a CHECK calling an unknown function")
+	}
+
+	// Stored checks ignore the functions and collation of the session
+	engine.RegisterFunction("score", func(args []string) (string, error) { return "1", nil })
+	if _, err := engine.Execute("CREATE TABLE testdb.bad (id INT PRIMARY KEY, name STRING CHECK (SCORE(name) > 0))"); err == nil {
+		t.Error("Expected error for a CHECK calling a registered function")
+	}
+	if _, err := engine.Execute("CREATE TABLE testdb.tags (id INT PRIMARY KEY, name STRING CHECK (name <> 'none'))"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	engine.Collation = CollationCaseInsensitive
+	if _, err := engine.Execute("INSERT INTO testdb.tags (id, name) VALUES (1, 'NONE')"); err != nil {
+		t.Errorf("Expected the CHECK to compare case-sensitively, got %v", err)
+	}
+	engine.Collation = CollationDefault
+}
+
+func TestEngineCopyDefaultAndCheck(t *testing.T) {
+	engine := setupTestEngine(t)
+	if _, err := engine.Execute("CREATE TABLE testdb.items (id INT PRIMARY KEY, name STRING, price FLOAT CHECK (price >= 0), created TIMESTAMP DEFAULT NOW())"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	// Columns the file lacks take their DEFAULT function, as with INSERT
+	csvPath := write("items.csv", "id,name,price\n1,pen,2\n")
+	jsonPath := write("items.json", `[{"id": 2, "name": "ink", "price": 3}]`)
+	if _, err := engine.Execute("COPY INTO testdb.items FROM '" + csvPath + "' WITH (HEADER = TRUE)"); err != nil {
+		t.Fatalf("COPY from CSV failed: %v", err)
+	}
+	if _, err := engine.Execute("COPY INTO testdb.items FROM '" + jsonPath + "' WITH (FORMAT = 'JSON')"); err != nil {
+		t.Fatalf("COPY from JSON failed: %v", err)
+	}
+	result, err := engine.Execute("SELECT COUNT(*) FROM testdb.items WHERE created IS NOT NULL")
+	if err != nil {
+		t.Fatalf("SELECT failed: %v", err)
+	}
+	if data := result.(QueryResult).Data; data[0][0] != "2" {
+		t.Errorf("Expected both rows to get a created time, got %v", data)
+	}
+
+	// Rows that fail a CHECK abort the import
+	var constraintErr *ConstraintError
+	_, err = engine.Execute("COPY INTO testdb.items FROM '" + write("bad.csv", "id,name,price\n3,cap,-1\n") + "' WITH (HEADER = TRUE)")
+	if !errors.As(err, &constraintErr) || constraintErr.Column != "price" {
+		t.Errorf("Expected a CHECK violation on price from CSV, got %v", err)
+	}
+	_, err = engine.Execute("COPY INTO testdb.items FROM '" + write("bad.json", `{"id": 3, "name": "cap", "price": -1}`) + "' WITH (FORMAT = 'JSON')")
+	if !errors.As(err, &constraintErr) || constraintErr.Column != "price" {
+		t.Errorf("Expected a CHECK violation on price from JSON, got %v", err)
+	}
+}
+
+func TestEngineDefaultKeyword(t *testing.T) {
+	engine := setupTestEngine(t)
+	if _, err := engine.Execute("CREATE TABLE testdb.tasks (id INT PRIMARY KEY, status STRING DEFAULT 'open', note STRING, due DATE DEFAULT NOW())"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	if _, err := engine.Execute("INSERT INTO testdb.tasks (id, status, note, due) VALUES (1, DEFAULT, DEFAULT, '2024-01-31')"); err != nil {
+		t.Fatalf("Failed to insert DEFAULT: %v", err)
+	}
+	result, err := engine.Execute("SELECT status, note FROM testdb.tasks WHERE note IS NULL")
+	if err != nil {
+		t.Fatalf("Failed to select: %v", err)
+	}
+	want := [][]string{{"open", ""}}
+	if data := result.(QueryResult).Data; !slices.EqualFunc(data, want, slices.Equal) {
+		t.Errorf("Expected the DEFAULT status and a NULL note, got %v", data)
+	}
+
+	// UPDATE ... SET col = DEFAULT restores the default
+	if _, err := engine.Execute("UPDATE testdb.tasks SET status = 'done', note = 'x' WHERE id = 1"); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+	if _, err := engine.Execute("UPDATE testdb.tasks SET status = DEFAULT, note = DEFAULT, due = DEFAULT WHERE id = 1"); err != nil {
+		t.Fatalf("Failed to update to DEFAULT: %v", err)
+	}
+	result, err = engine.Execute("SELECT status, due FROM testdb.tasks WHERE note IS NULL")
+	if err != nil {
+		t.Fatalf("Failed to select: %v", err)
+	}
+	data := result.(QueryResult).Data
+	if len(data) != 1 || data[0][0] != "open" || data[0][1] != time.Now().Format("2006-01-02") {
+		t.Errorf("Expected the defaults back, got %v", data)
+	}
+
+	if _, err := engine.Execute("INSERT INTO testdb.tasks (id, status) VALUES (DEFAULT, 'open')"); err == nil {
+		t.Error("Expected error for a DEFAULT primary key without a default")
+	}
+}
+
+func TestEngineTableAuthor(t *testing.T) {
+	engine := setupTestEngine(t)
+	engine.ImplicitIdentity = true
+	bot := "Loader Bot <bot@example.com>"
+
+	if _, err := engine.Execute("CREATE TABLE testdb.feed (id INT PRIMARY KEY, value STRING) AUTHOR '" + bot + "'"); err != nil {
+		t.Fatalf("Failed to create table with author: %v", err)
+	}
+	if _, err := engine.Execute("CREATE INDEX idx_value ON testdb.feed(value)"); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	// Row writes to the table are committed as its author
+	for _, query := range []string{
+		"INSERT INTO testdb.feed (id, value) VALUES (1, 'a')",
+		"UPDATE testdb.feed SET value = 'b' WHERE id = 1",
+		"DELETE FROM testdb.feed WHERE id = 1",
+	} {
+		if _, err := engine.Execute(query); err != nil {
+			t.Fatalf("Failed to execute %s: %v", query, err)
+		}
+		if author := engine.LatestTransaction().Author; author != bot {
+			t.Errorf("%s: expected author %s, got %s", query, bot, author)
+		}
+	}
+
+	// Other tables keep the engine's identity
+	if _, err := engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (1, 'Alice', 30)"); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if author := engine.LatestTransaction().Author; author != "test <test@test.com>" {
+		t.Errorf("Expected the engine identity, got %s", author)
+	}
+
+	// An identity the caller chose takes precedence over the table's
+	engine.ImplicitIdentity = false
+	if _, err := engine.Execute("INSERT INTO testdb.feed (id, value) VALUES (3, 'd')"); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if author := engine.LatestTransaction().Author; author != "test <test@test.com>" {
+		t.Errorf("Expected an explicit identity to override the table author, got %s", author)
+	}
+	engine.ImplicitIdentity = true
+
+	// SET AUTHOR NULL removes the override
+	if _, err := engine.Execute("ALTER TABLE testdb.feed SET AUTHOR NULL"); err != nil {
+		t.Fatalf("Failed to clear author: %v", err)
+	}
+	if _, err := engine.Execute("INSERT INTO testdb.feed (id, value) VALUES (2, 'c')"); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if author := engine.LatestTransaction().Author; author != "test <test@test.com>" {
+		t.Errorf("Expected the engine identity after SET AUTHOR NULL, got %s", author)
+	}
+}
+
+func TestEngineMaxInValues(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+
+	result, err := engine.Execute("SELECT name FROM testdb.users WHERE age IN (" + strings.Repeat("1, ", 50) + "30, 35) ORDER BY id")
+	if err != nil {
+		t.Fatalf("Failed to select with a long IN list: %v", err)
+	}
+	want := [][]string{{"Alice"}, {"Charlie"}}
+	if data := result.(QueryResult).Data; !slices.EqualFunc(data, want, slices.Equal) {
+		t.Errorf("Expected %v, got %v", want, data)
+	}
+
+	engine.MaxInValues = 3
+	if _, err := engine.Execute("SELECT name FROM testdb.users WHERE id IN (1, 2, 3, 4)"); err == nil || !strings.Contains(err.Error(), "more than 3 values") {
+		t.Errorf("Expected the IN list limit error, got %v", err)
+	}
+	engine.MaxInValues = -1
+	if _, err := engine.Execute("SELECT name FROM testdb.users WHERE id IN (" + strings.Repeat("1, ", sql.DefaultMaxInValues) + "2)"); err != nil {
+		t.Errorf("Expected no limit with MaxInValues < 0: %v", err)
+	}
+}
+
+// BenchmarkLargeInList compares searching a 10,000 value IN list in order
+// with the set the parser builds for it.
+func BenchmarkLargeInList(b *testing.B) {
+	values := make([]string, 10000)
+	for i := range values {
+		values[i] = strconv.Itoa(i)
+	}
+	rows := make([]map[string]string, 1000)
+	for i := range rows {
+		rows[i] = map[string]string{"id": strconv.Itoa(i * 13)}
+	}
+
+	for _, bench := range []struct {
+		name string
+		cond sql.WhereCondition
+	}{
+		{"list", sql.WhereCondition{Left: "id", Operator: sql.InOperator, InValues: values}},
+		{"set", sql.WhereCondition{Left: "id", Operator: sql.InOperator, InValues: values, InSet: sql.InValueSet(values)}},
+	} {
+		where := sql.WhereClause{Conditions: []sql.WhereCondition{bench.cond}}
+		b.Run(bench.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, row := range rows {
+					matchesWhereClause(row, where, CollationDefault)
+				}
+			}
+		})
+	}
+}
+
+func TestEngineCreateDropIndex(t *testing.T) {
+	engine := setupTestEngine(t)
+
+	_, err := engine.Execute("CREATE INDEX idx_name ON testdb.users(name)")
+	if err != nil {
+		t.Fatalf("Failed to CREATE INDEX: %v", err)
+	}
+
+	_, _ = engine.Execute("DROP INDEX idx_name ON testdb.users")
+}
+
+func TestEngineNoIndexHint(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+
+	if _, err := engine.Execute("CREATE INDEX idx_name ON testdb.users(name)"); err != nil {
+		t.Fatalf("Failed to CREATE INDEX: %v", err)
+	}
+
+	result, err := engine.Execute("SELECT * FROM testdb.users WHERE name = 'Alice'")
+	if err != nil {
+		t.Fatalf("Indexed SELECT failed: %v", err)
+	}
+	indexed := result.(QueryResult)
+
+	result, err = engine.Execute("SELECT /*+ NO_INDEX */ * FROM testdb.users WHERE name = 'Alice'")
+	if err != nil {
+		t.Fatalf("Hinted SELECT failed: %v", err)
+	}
+	scanned := result.(QueryResult)
+
+	if len(indexed.Data) != 1 || len(scanned.Data) != 1 {
+		t.Fatalf("Expected 1 row from both queries, got %d and %d", len(indexed.Data), len(scanned.Data))
+	}
+	if indexed.ExecutionOps != 1 {
+		t.Errorf("Expected index lookup to read 1 row, got %d", indexed.ExecutionOps)
+	}
+	if scanned.ExecutionOps != 3 {
+		t.Errorf("Expected NO_INDEX to scan all 3 rows, got %d", scanned.ExecutionOps)
+	}
+}
+
+func TestEngineEmptyStatement(t *testing.T) {
+	engine := setupTestEngine(t)
+
+	for _, query := range []string{"", "   \n\t", "-- comment", ";", " ; -- done\n"} {
+		_, err := engine.Execute(query)
+		if !errors.Is(err, sql.ErrEmptyStatement) {
+			t.Errorf("Execute(%q): expected ErrEmptyStatement, got %v", query, err)
+		}
+	}
+
+	// A comment before a statement is not an empty statement
+	if _, err := engine.Execute("-- list users\nSELECT * FROM testdb.users;"); err != nil {
+		t.Errorf("Expected commented statement to run, got %v", err)
+	}
+}
+
+func TestEngineExecuteBatch(t *testing.T) {
+	engine := setupTestEngine(t)
+
+	results, err := engine.ExecuteBatch(`
+		CREATE TABLE testdb.items (id INT PRIMARY KEY, name STRING);
+		-- seed data
+		INSERT INTO testdb.items (id, name) VALUES (1, 'a;b');
+		SELECT * FROM testdb.items;
+	`)
+	if err != nil {
+		t.Fatalf("ExecuteBatch failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	if results[0].Type() != CommitResultType || results[1].Type() != CommitResultType {
+		t.Error("Expected commit results for CREATE and INSERT")
+	}
+	qr, ok := results[2].(QueryResult)
+	if !ok || len(qr.Data) != 1 || qr.Data[0][1] != "a;b" {
+		t.Errorf("Expected SELECT to return the inserted row, got %v", results[2])
+	}
+
+	// Stops at the first failure
+	results, err = engine.ExecuteBatch("SELECT * FROM testdb.missing; SELECT * FROM testdb.items")
+	if err == nil || len(results) != 0 {
+		t.Errorf("Expected batch to stop at first error, got %d results, err %v", len(results), err)
+	}
+
+	// Continues past failures, leaving nil results
+	results, err = engine.ExecuteBatchContinue("SELECT * FROM testdb.missing; SELECT * FROM testdb.items")
+	if err == nil || len(results) != 2 || results[0] != nil || results[1] == nil {
+		t.Errorf("Expected both statements to run, got %v, err %v", results, err)
+	}
+}
+
+func TestEngineCollation(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+
+	count := func(query string) int {
+		result, err := engine.Execute(query)
+		if err != nil {
+			t.Fatalf("Failed to execute %q: %v", query, err)
+		}
+		return len(result.(QueryResult).Data)
+	}
+
+	tests := []struct {
+		collation Collation
+		like      int
+		equals    int
+	}{
+		{CollationDefault, 1, 0},
+		{CollationCaseSensitive, 0, 0},
+		{CollationCaseInsensitive, 1, 1},
+	}
+
+	for _, test := range tests {
+		engine.Collation = test.collation
+		if got := count("SELECT * FROM testdb.users WHERE name LIKE 'al%'"); got != test.like {
+			t.Errorf("collation %d: LIKE matched %d rows, expected %d", test.collation, got, test.like)
+		}
+		if got := count("SELECT * FROM testdb.users WHERE name = 'alice'"); got != test.equals {
+			t.Errorf("collation %d: = matched %d rows, expected %d", test.collation, got, test.equals)
+		}
+	}
+}
+
+func TestEngineCaseInsensitivePrimaryKeyWrites(t *testing.T) {
+	engine := setupTestEngine(t)
+	if _, err := engine.Execute("CREATE TABLE testdb.codes (code STRING PRIMARY KEY, label STRING)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	if _, err := engine.Execute("INSERT INTO testdb.codes (code, label) VALUES ('ABC', 'first'), ('XYZ', 'second')"); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	engine.Collation = CollationCaseInsensitive
+
+	result, err := engine.Execute("UPDATE testdb.codes SET label = 'changed' WHERE code = 'abc'")
+	if err != nil {
+		t.Fatalf("UPDATE failed: %v", err)
+	}
+	if written := result.(CommitResult).RecordsWritten; written != 1 {
+		t.Errorf("Expected UPDATE to match ABC, got %d written", written)
+	}
+	result, err = engine.Execute("DELETE FROM testdb.codes WHERE code IN ('xyz')")
+	if err != nil {
+		t.Fatalf("DELETE failed: %v", err)
+	}
+	if deleted := result.(CommitResult).RecordsDeleted; deleted != 1 {
+		t.Errorf("Expected DELETE to match XYZ, got %d deleted", deleted)
+	}
+}
+
+func TestEngineUpdatePrimaryKeyRejected(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+
+	_, err := engine.Execute("UPDATE testdb.users SET id = 5 WHERE id = 3")
+	var constraintErr *ConstraintError
+	if !errors.As(err, &constraintErr) || constraintErr.Column != "id" {
+		t.Fatalf("Expected a constraint error for id, got %v", err)
+	}
+	// Setting the key it already has is allowed
+	if _, err := engine.Execute("UPDATE testdb.users SET id = 3, age = 36 WHERE id = 3"); err != nil {
+		t.Errorf("Expected an unchanged primary key to be accepted, got %v", err)
+	}
+
+	result, err := engine.Execute("SELECT id, age FROM testdb.users WHERE id = 3")
+	if err != nil {
+		t.Fatalf("SELECT failed: %v", err)
+	}
+	if data := result.(QueryResult).Data; !reflect.DeepEqual(data, [][]string{{"3", "36"}}) {
+		t.Errorf("Expected row 3 to keep its key, got %v", data)
+	}
+}
+
+func TestEngineLikeAnyAll(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+
+	names := func(query string) []string {
+		t.Helper()
+		result, err := engine.Execute(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		var names []string
+		for _, row := range result.(QueryResult).Data {
+			names = append(names, row[0])
+		}
+		return names
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"SELECT name FROM testdb.users WHERE name LIKE ANY ('a%', 'C%') ORDER BY name", []string{"Alice", "Charlie"}},
+		{"SELECT name FROM testdb.users WHERE name LIKE ANY ('z%')", nil},
+		{"SELECT name FROM testdb.users WHERE name LIKE ALL ('%a%', '%e') ORDER BY name", []string{"Alice", "Charlie"}},
+		{"SELECT name FROM testdb.users WHERE name LIKE ALL ('%a%', 'b%')", nil},
+		{"SELECT name FROM testdb.users WHERE NOT name LIKE ANY ('a%', 'c%')", []string{"Bob"}},
+		{"SELECT name FROM testdb.users WHERE name LIKE ANY ('b%') OR age = 35 ORDER BY name", []string{"Bob", "Charlie"}},
+	}
+	for _, test := range tests {
+		if got := names(test.query); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.query, test.expected, got)
+		}
+	}
+}
+
+func TestEngineShowBranchesMetrics(t *testing.T) {
+	engine := setupTestEngine(t)
+
+	result, err := engine.Execute("SHOW BRANCHES")
+	if err != nil {
+		t.Fatalf("Failed to execute SHOW BRANCHES: %v", err)
+	}
+
+	qr := result.(QueryResult)
+	if qr.ExecutionTimeMs <= 0 {
+		t.Errorf("Expected non-zero execution time, got %v", qr.ExecutionTimeMs)
+	}
+	if qr.ExecutionOps != len(qr.Data) {
+		t.Errorf("Expected %d ops, got %d", len(qr.Data), qr.ExecutionOps)
+	}
+}
+
+func TestEngineShowViews(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+	for _, query := range []string{
+		"CREATE VIEW testdb.adults AS SELECT name, age FROM testdb.users WHERE age >= 30 AND name <> 'it''s'",
+		"CREATE DATABASE reports",
+		"CREATE MATERIALIZED VIEW reports.ages WITH AUTO REFRESH AS SELECT age, COUNT(*) FROM testdb.users GROUP BY age",
+	} {
+		if _, err := engine.Execute(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+
+	result, err := engine.Execute("SHOW CREATE VIEW testdb.adults")
+	if err != nil {
+		t.Fatalf("SHOW CREATE VIEW failed: %v", err)
+	}
+	query := "SELECT name, age FROM testdb.users WHERE age >= 30 AND name <> 'it''s'"
+	expected := [][]string{{"adults", "NO", query, "CREATE VIEW testdb.adults AS " + query}}
+	if got := result.(QueryResult).Data; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	result, err = engine.Execute("SHOW CREATE VIEW reports.ages")
+	if err != nil {
+		t.Fatalf("SHOW CREATE VIEW failed: %v", err)
+	}
+	if got := result.(QueryResult).Data[0][3]; got != "CREATE MATERIALIZED VIEW reports.ages WITH AUTO REFRESH AS SELECT age, COUNT(*) FROM testdb.users GROUP BY age" {
+		t.Errorf("Unexpected CREATE statement %q", got)
+	}
+
+	result, err = engine.Execute("SHOW VIEWS")
+	if err != nil {
+		t.Fatalf("SHOW VIEWS failed: %v", err)
+	}
+	qr := result.(QueryResult)
+	if want := []string{"database", "name", "materialized", "query"}; !reflect.DeepEqual(qr.Columns, want) {
+		t.Errorf("Expected columns %v, got %v", want, qr.Columns)
+	}
+	var views []string
+	for _, row := range qr.Data {
+		views = append(views, row[0]+"."+row[1]+" "+row[2])
+	}
+	if want := []string{"reports.ages YES", "testdb.adults NO"}; !reflect.DeepEqual(views, want) {
+		t.Errorf("Expected views %v, got %v", want, views)
+	}
+
+	if _, err := engine.Execute("SHOW CREATE VIEW testdb.missing"); !errors.Is(err, ps.ErrViewNotFound) {
+		t.Errorf("Expected ErrViewNotFound, got %v", err)
+	}
+}
+
+func TestEngineCheckDatabase(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+	if _, err := engine.Execute("CREATE INDEX idx_name ON testdb.users(name)"); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	result, err := engine.Execute("CHECK DATABASE testdb")
+	if err != nil {
+		t.Fatalf("CHECK DATABASE failed: %v", err)
+	}
+	qr := result.(QueryResult)
+	if want := []string{"database", "table", "key", "problem"}; !reflect.DeepEqual(qr.Columns, want) {
+		t.Errorf("Expected columns %v, got %v", want, qr.Columns)
+	}
+	if len(qr.Data) != 0 {
+		t.Errorf("Expected a clean result, got %v", qr.Data)
+	}
+
+	corrupt := map[string][]byte{
+		"4": []byte(`{"id":"4","name":"Dan","age":"old"}`),
+		"9": []byte("{bad"),
+	}
+	if _, err := engine.Persistence.SaveRecord("testdb", "users", corrupt, engine.Identity); err != nil {
+		t.Fatalf("Failed to write corrupted rows: %v", err)
+	}
+	before := engine.Persistence.LatestTransaction().Id
+
+	result, err = engine.Execute("CHECK DATABASE")
+	if err != nil {
+		t.Fatalf("CHECK DATABASE failed: %v", err)
+	}
+	var keys []string
+	for _, row := range result.(QueryResult).Data {
+		keys = append(keys, row[2])
+		if row[0] != "testdb" || row[1] != "users" {
+			t.Errorf("Unexpected finding location %v", row)
+		}
+	}
+	if want := []string{"4", "9", "4"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("Expected findings for rows %v, got %v", want, result.(QueryResult).Data)
+	}
+	if after := engine.Persistence.LatestTransaction().Id; after != before {
+		t.Error("CHECK DATABASE should not write a commit")
+	}
+
+	if _, err := engine.Execute("CHECK DATABASE missing"); !errors.Is(err, ps.ErrDatabaseNotFound) {
+		t.Errorf("Expected ErrDatabaseNotFound, got %v", err)
+	}
+}
+
+func TestEngineMaterializedViewSnapshot(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+
+	before := engine.Persistence.LatestTransaction().Id
+	if _, err := engine.Execute("CREATE MATERIALIZED VIEW testdb.names AS SELECT name FROM testdb.users"); err != nil {
+		t.Fatalf("Failed to create view: %v", err)
+	}
+
+	view, err := engine.Persistence.GetView("testdb", "names")
+	if err != nil {
+		t.Fatalf("Failed to get view: %v", err)
+	}
+	if view.Query != "SELECT name FROM testdb.users" || !view.Materialized {
+		t.Errorf("Unexpected view definition: %+v", view)
+	}
+	if view.Snapshot != before {
+		t.Errorf("Expected snapshot %s, got %s", before, view.Snapshot)
+	}
+
+	_, _ = engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (4, 'Dave', 40)")
+	inserted := engine.Persistence.LatestTransaction().Id
+	if _, err := engine.Execute("REFRESH VIEW testdb.names"); err != nil {
+		t.Fatalf("Failed to refresh view: %v", err)
+	}
+
+	view, err = engine.Persistence.GetView("testdb", "names")
+	if err != nil {
+		t.Fatalf("Failed to get view: %v", err)
+	}
+	if view.Snapshot != inserted {
+		t.Errorf("Expected snapshot %s after refresh, got %s", inserted, view.Snapshot)
+	}
+}
+
+func TestEngineMaterializedViewAutoRefresh(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+
+	names := func(view string) []string {
+		result, err := engine.Execute("SELECT name FROM testdb." + view + " ORDER BY name")
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", view, err)
+		}
+		var names []string
+		for _, row := range result.(QueryResult).Data {
+			names = append(names, row[0])
+		}
+		return names
+	}
+
+	for _, query := range []string{
+		"CREATE MATERIALIZED VIEW testdb.adults WITH AUTO REFRESH AS SELECT name FROM testdb.users WHERE age >= 30",
+		"CREATE MATERIALIZED VIEW testdb.manual AS SELECT name FROM testdb.users WHERE age >= 30",
+		"CREATE MATERIALIZED VIEW testdb.hourly WITH AUTO REFRESH EVERY '1h' AS SELECT name FROM testdb.users WHERE age >= 30",
+		"CREATE DATABASE reports",
+		"CREATE MATERIALIZED VIEW reports.adults WITH AUTO REFRESH AS SELECT name FROM testdb.users WHERE age >= 30",
+		"INSERT INTO testdb.users (id, name, age) VALUES (4, 'Dave', 40)",
+		"UPDATE testdb.users SET age = 20 WHERE id = 1",
+	} {
+		if _, err := engine.Execute(query); err != nil {
+			t.Fatalf("Failed to execute %q: %v", query, err)
+		}
+	}
+
+	if got, want := names("adults"), []string{"Charlie", "Dave"}; !slices.Equal(got, want) {
+		t.Errorf("Expected auto-refreshed view %v, got %v", want, got)
+	}
+	// Views in other databases over the table refresh too
+	result, err := engine.Execute("SELECT name FROM reports.adults ORDER BY name")
+	if err != nil {
+		t.Fatalf("Failed to read reports.adults: %v", err)
+	}
+	if got, want := result.(QueryResult).Data, [][]string{{"Charlie"}, {"Dave"}}; !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("Expected auto-refreshed view in another database %v, got %v", want, got)
+	}
+	stale := []string{"Alice", "Charlie"}
+	if got := names("manual"); !slices.Equal(got, stale) {
+		t.Errorf("Expected manual view to stay %v, got %v", stale, got)
+	}
+	if got := names("hourly"); !slices.Equal(got, stale) {
+		t.Errorf("Expected interval view to stay %v within its interval, got %v", stale, got)
+	}
+
+	if _, err := engine.Execute("DELETE FROM testdb.users WHERE id = 3"); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if got, want := names("adults"), []string{"Dave"}; !slices.Equal(got, want) {
+		t.Errorf("Expected auto-refreshed view %v after delete, got %v", want, got)
+	}
+
+	// Once the interval has passed the next read refreshes the view
+	view, _ := engine.Persistence.GetView("testdb", "hourly")
+	view.UpdatedAt = view.UpdatedAt.Add(-2 * time.Hour)
+	if _, err := engine.Persistence.UpdateView(*view, engine.Identity); err != nil {
+		t.Fatalf("Failed to update view: %v", err)
+	}
+	if got, want := names("hourly"), []string{"Dave"}; !slices.Equal(got, want) {
+		t.Errorf("Expected interval view %v after its interval, got %v", want, got)
+	}
+}
+
+func TestEngineSessionVariables(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+
+	matches := func() int {
+		t.Helper()
+		result, err := engine.Execute("SELECT * FROM testdb.users WHERE name LIKE 'alice'")
+		if err != nil {
+			t.Fatalf("SELECT failed: %v", err)
+		}
+		return len(result.(QueryResult).Data)
+	}
+	variables := func() map[string]string {
+		t.Helper()
+		result, err := engine.Execute("SHOW VARIABLES")
+		if err != nil {
+			t.Fatalf("SHOW VARIABLES failed: %v", err)
+		}
+		values := make(map[string]string)
+		for _, row := range result.(QueryResult).Data {
+			values[row[0]] = row[1]
+		}
+		return values
+	}
+
+	if matches() != 1 || variables()["case_sensitive"] != "DEFAULT" {
+		t.Fatalf("Expected case-insensitive LIKE by default, got %v", variables())
+	}
+	if _, err := engine.Execute("SET case_sensitive = TRUE"); err != nil {
+		t.Fatalf("SET failed: %v", err)
+	}
+	if got := matches(); got != 0 || engine.Collation != CollationCaseSensitive {
+		t.Errorf("Expected case-sensitive LIKE to match nothing, got %d rows", got)
+	}
+	if got := variables()["case_sensitive"]; got != "TRUE" {
+		t.Errorf("Expected SHOW VARIABLES to list case_sensitive TRUE, got %q", got)
+	}
+
+	if _, err := engine.Execute("SET strict_reads = 'on'"); err != nil || !engine.StrictReads {
+		t.Errorf("Expected SET strict_reads to enable StrictReads (%v)", err)
+	}
+	if _, err := engine.Execute("SET case_sensitive = DEFAULT"); err != nil || engine.Collation != CollationDefault {
+		t.Errorf("Expected SET ... = DEFAULT to restore the default collation (%v)", err)
+	}
+
+	if got := variables(); got["max_in_values"] != "10000" || got["max_result_rows"] != "0" {
+		t.Errorf("Expected the default limits, got %v", got)
+	}
+	if _, err := engine.Execute("SET max_in_values = 2"); err != nil || engine.MaxInValues != 2 {
+		t.Fatalf("Expected SET max_in_values to set MaxInValues (%v)", err)
+	}
+	if _, err := engine.Execute("SELECT * FROM testdb.users WHERE id IN (1, 2, 3)"); err == nil {
+		t.Error("Expected an IN list over max_in_values to be rejected")
+	}
+	if _, err := engine.Execute("SET max_in_values = 0"); err != nil || variables()["max_in_values"] != "0" {
+		t.Errorf("Expected max_in_values 0 to remove the limit (%v)", err)
+	}
+	if _, err := engine.Execute("SELECT * FROM testdb.users WHERE id IN (1, 2, 3)"); err != nil {
+		t.Errorf("Expected an unlimited IN list to be accepted, got %v", err)
+	}
+	if _, err := engine.Execute("SET max_result_rows = 2"); err != nil || engine.MaxResultRows != 2 {
+		t.Fatalf("Expected SET max_result_rows to set MaxResultRows (%v)", err)
+	}
+	if _, err := engine.Execute("SELECT * FROM testdb.users"); !errors.Is(err, ErrResultTooLarge) {
+		t.Errorf("Expected three rows to exceed max_result_rows, got %v", err)
+	}
+	if _, err := engine.Execute("SET max_result_rows = DEFAULT"); err != nil || engine.MaxResultRows != 0 {
+		t.Errorf("Expected DEFAULT to remove the row limit (%v)", err)
+	}
+
+	// A cap set by the application can be lowered but not lifted
+	engine.MaxResultRowsCap = 2
+	if _, err := engine.Execute("SELECT * FROM testdb.users"); !errors.Is(err, ErrResultTooLarge) {
+		t.Errorf("Expected MaxResultRowsCap to apply without MaxResultRows, got %v", err)
+	}
+	if _, err := engine.Execute("SET max_result_rows = 5"); err == nil {
+		t.Error("Expected raising max_result_rows above the cap to fail")
+	}
+	if _, err := engine.Execute("SET max_result_rows = 1"); err != nil || variables()["max_result_rows"] != "1" {
+		t.Errorf("Expected max_result_rows to go below the cap (%v)", err)
+	}
+	if _, err := engine.Execute("SET max_result_rows = 0"); err != nil || variables()["max_result_rows"] != "2" {
+		t.Errorf("Expected max_result_rows 0 to restore the cap (%v)", err)
+	}
+	engine.MaxResultRowsCap, engine.MaxResultRows = 0, 0
+
+	for _, query := range []string{"SET no_such_setting = 1", "SET case_sensitive = 'maybe'", "SET max_result_rows = -1", "SET max_in_values = 'many'"} {
+		if _, err := engine.Execute(query); err == nil {
+			t.Errorf("Expected error for %q", query)
+		}
+	}
+}
+
+func TestEngineMaxResultRows(t *testing.T) {
+	engine := setupTestEngine(t)
+	for i := 1; i <= 10; i++ {
+		if _, err := engine.Execute(fmt.Sprintf("INSERT INTO testdb.users (id, name, age) VALUES (%d, 'user%d', %d)", i, i, 20+i)); err != nil {
+			t.Fatalf("INSERT failed: %v", err)
+		}
+	}
+	engine.MaxResultRows = 2
+
+	// The scan stops at the first row over the cap
+	_, err := engine.Execute("SELECT * FROM testdb.users")
+	if !errors.Is(err, ErrResultTooLarge) || !strings.Contains(err.Error(), "after scanning 3 rows") {
+		t.Errorf("Expected the scan to stop after 3 rows, got %v", err)
+	}
+	if _, err := engine.Execute("SELECT name FROM testdb.users WHERE age > 27 ORDER BY age"); !errors.Is(err, ErrResultTooLarge) {
+		t.Errorf("Expected too many matching rows to be rejected, got %v", err)
+	}
+	if _, err := engine.Execute("SELECT DISTINCT age FROM testdb.users"); !errors.Is(err, ErrResultTooLarge) {
+		t.Errorf("Expected a large DISTINCT result to be rejected, got %v", err)
+	}
+
+	for _, query := range []string{
+		"SELECT * FROM testdb.users WHERE age > 28",
+		"SELECT * FROM testdb.users LIMIT 2",
+		"SELECT * FROM testdb.users ORDER BY id LIMIT 5 OFFSET 8",
+		"SELECT COUNT(*), MAX(age) FROM testdb.users",
+		"SELECT COUNT(*) FROM testdb.users",
+	} {
+		if _, err := engine.Execute(query); err != nil {
+			t.Errorf("Expected %s to fit the cap: %v", query, err)
+		}
+	}
+
+	// Under TruncateResults the first rows are kept instead
+	engine.TruncateResults = true
+	for _, query := range []string{"SELECT id FROM testdb.users", "SELECT id FROM testdb.users ORDER BY age"} {
+		result, err := engine.Execute(query)
+		if err != nil {
+			t.Fatalf("Expected %s to be truncated: %v", query, err)
+		}
+		qr := result.(QueryResult)
+		if fmt.Sprint(qr.Data) != "[[1] [2]]" || qr.RecordsRead != 2 || !qr.Truncated {
+			t.Errorf("%s: expected the first 2 rows, truncated, got %v (records %d, truncated %v)", query, qr.Data, qr.RecordsRead, qr.Truncated)
+		}
+	}
+	if result, err := engine.Execute("SELECT id FROM testdb.users LIMIT 2"); err != nil || result.(QueryResult).Truncated {
+		t.Errorf("Expected a result within the cap not to be truncated (%v)", err)
+	}
 }
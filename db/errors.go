@@ -0,0 +1,26 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrResultTooLarge is returned when a SELECT would return more rows than
+// QueryContext.MaxResultRows allows.
+var ErrResultTooLarge = errors.New("result too large")
+
+// ConstraintError is returned when a written value breaks a column
+// constraint: a NULL primary key, a value that is not valid for the column
+// type, or a duplicate in a unique index.
+type ConstraintError struct {
+	Column string
+	Reason string
+}
+
+func (e *ConstraintError) Error() string {
+	return e.Reason
+}
+
+func constraintErrorf(column, format string, args ...any) error {
+	return &ConstraintError{Column: column, Reason: fmt.Sprintf(format, args...)}
+}
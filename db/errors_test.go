@@ -0,0 +1,76 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nickyhof/CommitDB/ps"
+	"github.com/nickyhof/CommitDB/sql"
+)
+
+func TestNotFoundErrors(t *testing.T) {
+	engine := setupTestEngine(t)
+
+	tests := []struct {
+		query  string
+		target error
+	}{
+		{"SELECT * FROM testdb.missing", ps.ErrTableNotFound},
+		{"SELECT * FROM testdb.users u JOIN testdb.missing m ON u.id = m.id", ps.ErrTableNotFound},
+		{"INSERT INTO testdb.missing (id) VALUES (1)", ps.ErrTableNotFound},
+		{"ALTER TABLE testdb.missing ADD COLUMN email STRING", ps.ErrTableNotFound},
+		{"DROP DATABASE missingdb", ps.ErrDatabaseNotFound},
+		{"DROP VIEW testdb.missing", ps.ErrViewNotFound},
+	}
+
+	for _, test := range tests {
+		_, err := engine.Execute(test.query)
+		if !errors.Is(err, test.target) {
+			t.Errorf("%s: expected %v, got %v", test.query, test.target, err)
+		}
+	}
+}
+
+func TestSyntaxErrorPosition(t *testing.T) {
+	engine := setupTestEngine(t)
+
+	_, err := engine.Execute("SELECT * FROM testdb.users WHERE")
+	var syntaxErr *sql.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("Expected a *sql.SyntaxError, got %v", err)
+	}
+	if syntaxErr.Position != 33 {
+		t.Errorf("Expected position 33 (end of input), got %d", syntaxErr.Position)
+	}
+
+	if _, err := engine.Execute("   "); !errors.Is(err, sql.ErrEmptyStatement) || errors.As(err, &syntaxErr) {
+		t.Errorf("Expected empty input to return ErrEmptyStatement only, got %v", err)
+	}
+}
+
+func TestConstraintError(t *testing.T) {
+	engine := setupTestEngine(t)
+	_, _ = engine.Execute("CREATE TABLE testdb.events (id INT PRIMARY KEY, day DATE, payload JSON)")
+
+	tests := []struct {
+		query  string
+		column string
+	}{
+		{"INSERT INTO testdb.users (id, name) VALUES (NULL, 'Alice')", "id"},
+		{"INSERT INTO testdb.users (name) VALUES ('Alice')", "id"},
+		{"INSERT INTO testdb.events (id, day) VALUES (1, 'yesterday')", "day"},
+		{"INSERT INTO testdb.events (id, payload) VALUES (2, '{broken')", "payload"},
+	}
+
+	for _, test := range tests {
+		_, err := engine.Execute(test.query)
+		var constraintErr *ConstraintError
+		if !errors.As(err, &constraintErr) {
+			t.Errorf("%s: expected a *ConstraintError, got %v", test.query, err)
+			continue
+		}
+		if constraintErr.Column != test.column {
+			t.Errorf("%s: expected column %s, got %s", test.query, test.column, constraintErr.Column)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nickyhof/CommitDB/sql"
+)
+
+// Function is a scalar function callable from SQL. It receives the values of
+// its arguments for one row (column values, or literals as written) and
+// returns the value for that row. Returning an error fails the query, which
+// is also how a function should reject a wrong number of arguments.
+type Function func(args []string) (string, error)
+
+// builtinFunctions are the functions evalStringFunction implements.
+var builtinFunctions = map[string]bool{
+	"UPPER": true, "LOWER": true, "CONCAT": true, "SUBSTRING": true, "TRIM": true,
+	"LENGTH": true, "REPLACE": true, "TO_BASE64": true, "FROM_BASE64": true,
+	"NOW": true, "DATE": true, "YEAR": true, "MONTH": true, "DAY": true,
+	"HOUR": true, "MINUTE": true, "SECOND": true, "DATE_ADD": true, "DATE_SUB": true,
+	"DATEDIFF": true, "DATE_FORMAT": true,
+	"JSON_EXTRACT": true, "JSON_KEYS": true, "JSON_LENGTH": true, "JSON_TYPE": true,
+	"JSON_CONTAINS": true, "JSON_PRETTY": true, "JSON_COMPACT": true,
+}
+
+// RegisterFunction makes fn callable from SELECT as name(arg, ...). Names are
+// case-insensitive; a registered function takes precedence over a built-in
+// of the same name. Register functions before running queries concurrently.
+//
+//	engine.RegisterFunction("GEOHASH", func(args []string) (string, error) {
+//	    if len(args) != 2 {
+//	        return "", errors.New("expected latitude and longitude")
+//	    }
+//	    return geohash(args[0], args[1])
+//	})
+func (engine *Engine) RegisterFunction(name string, fn Function) {
+	if engine.functions == nil {
+		engine.functions = make(map[string]Function)
+	}
+	engine.functions[strings.ToUpper(name)] = fn
+}
+
+// checkFunctions reports the first function the statement calls that is
+// neither registered nor built in.
+func (engine *Engine) checkFunctions(functions []sql.FunctionExpr) error {
+	for _, fn := range functions {
+		if _, ok := engine.functions[fn.Function]; !ok && !builtinFunctions[fn.Function] {
+			return fmt.Errorf("unknown function %s", fn.Function)
+		}
+	}
+	return nil
+}
+
+// addComputedColumns stores the result of every selected and computed
+// function in each row, under its Name() and alias, so GROUP BY and aggregates
+// can read function results like columns.
+func (engine *Engine) addComputedColumns(rows []map[string]string, statement sql.SelectStatement) error {
+	functions := append(slices.Clip(statement.Functions), statement.Computed...)
+	if err := engine.checkFunctions(functions); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		for _, fn := range functions {
+			value, err := engine.evalFunction(fn, row)
+			if err != nil {
+				return err
+			}
+			row[fn.Name()] = value
+			if fn.Alias != "" {
+				row[fn.Alias] = value
+			}
+		}
+	}
+	return nil
+}
+
+// evalFunction evaluates fn on a row, preferring a registered function over
+// the built-in one.
+func (engine *Engine) evalFunction(fn sql.FunctionExpr, row map[string]string) (string, error) {
+	registered, ok := engine.functions[fn.Function]
+	if !ok {
+		return evalStringFunction(fn, row), nil
+	}
+	value, err := registered(functionArgs(fn, row))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", fn.Function, err)
+	}
+	return value, nil
+}
+
+// functionArgs resolves function arguments: column names take the row's
+// value, read without their qualifier when the row is not joined, and
+// literals and anything else are taken as written.
+func functionArgs(fn sql.FunctionExpr, row map[string]string) []string {
+	args := make([]string, len(fn.Args))
+	for i, arg := range fn.Args {
+		if fn.IsLiteral(i) {
+			args[i] = arg
+		} else if val, ok := row[arg]; ok {
+			args[i] = val
+		} else if val, ok := row[unqualifiedName(arg)]; ok {
+			args[i] = val
+		} else {
+			args[i] = arg // literal value
+		}
+	}
+	return args
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRegisterFunction(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+
+	engine.RegisterFunction("initials", func(args []string) (string, error) {
+		if len(args) != 1 {
+			return "", errors.New("expected 1 argument")
+		}
+		return args[0][:1] + ".", nil
+	})
+
+	result, err := engine.Execute("SELECT INITIALS(name) AS short, age FROM testdb.users ORDER BY age")
+	if err != nil {
+		t.Fatalf("Query with registered function failed: %v", err)
+	}
+	qr := result.(QueryResult)
+	if !reflect.DeepEqual(qr.Columns, []string{"short", "age"}) {
+		t.Errorf("Unexpected columns %v", qr.Columns)
+	}
+	expected := [][]string{{"B.", "25"}, {"A.", "30"}, {"C.", "35"}}
+	if !reflect.DeepEqual(qr.Data, expected) {
+		t.Errorf("Expected %v, got %v", expected, qr.Data)
+	}
+
+	_, err = engine.Execute("SELECT initials(name, 'x') FROM testdb.users")
+	if err == nil || !strings.Contains(err.Error(), "INITIALS: expected 1 argument") {
+		t.Errorf("Expected the function's error, got %v", err)
+	}
+
+	_, err = engine.Execute("SELECT missing(name) FROM testdb.users")
+	if err == nil || !strings.Contains(err.Error(), "unknown function MISSING") {
+		t.Errorf("Expected unknown function error, got %v", err)
+	}
+}
+
+func TestRegisterFunctionOverridesBuiltin(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+
+	engine.RegisterFunction("UPPER", func(args []string) (string, error) {
+		return "<" + args[0] + ">", nil
+	})
+
+	result, err := engine.Execute("SELECT UPPER(name) FROM testdb.users WHERE id = 1")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if qr := result.(QueryResult); len(qr.Data) != 1 || qr.Data[0][0] != "<Alice>" {
+		t.Errorf("Expected registered UPPER to be used, got %v", qr.Data)
+	}
+}
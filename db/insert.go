@@ -0,0 +1,177 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nickyhof/CommitDB/core"
+	"github.com/nickyhof/CommitDB/op"
+	"github.com/nickyhof/CommitDB/sql"
+)
+
+// PreparedInsert collects rows for one table from Go code and writes them in
+// a single commit, without parsing SQL for each row. Values are strings as in
+// an INSERT; sql.NullValue, sql.DefaultValue and sql.NowValue stand for NULL,
+// DEFAULT and NOW().
+//
+//	stmt, err := engine.PrepareInsert("mydb", "users", "id", "name")
+//	for _, user := range users {
+//	    if err := stmt.Add(user.ID, user.Name); err != nil {
+//	        return err
+//	    }
+//	}
+//	result, err := stmt.Commit()
+type PreparedInsert struct {
+	engine    *Engine
+	table     core.Table
+	pk        string
+	columns   []string
+	valueRows [][]string
+	rows      map[string][]byte // stored rows by primary key
+	keys      []string          // primary keys in the order first added
+}
+
+// PrepareInsert starts a batch of rows for database.table. Values are given
+// in the order of columns, or of the table's columns when none are named.
+func (engine *Engine) PrepareInsert(database, table string, columns ...string) (*PreparedInsert, error) {
+	tableOp, err := op.GetTable(database, table, engine.Persistence)
+	if err != nil {
+		return nil, err
+	}
+	pk, err := tableOp.PrimaryKey()
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		for _, col := range tableOp.Table.Columns {
+			columns = append(columns, col.Name)
+		}
+	} else if err := checkTableColumns(tableOp.Table, columns); err != nil {
+		return nil, err
+	}
+
+	return &PreparedInsert{
+		engine:  engine,
+		table:   tableOp.Table,
+		pk:      *pk,
+		columns: append([]string{}, columns...),
+		rows:    make(map[string][]byte),
+	}, nil
+}
+
+// Add validates a row against the column types and constraints, as INSERT
+// does, and adds it to the batch. A rejected row leaves the batch unchanged.
+// A later row with the same primary key replaces an earlier one.
+func (stmt *PreparedInsert) Add(values ...string) error {
+	if len(values) != len(stmt.columns) {
+		return fmt.Errorf("expected %d values, got %d", len(stmt.columns), len(values))
+	}
+	for i, name := range stmt.columns {
+		if values[i] == sql.DefaultValue {
+			continue
+		}
+		if err := checkColumnValue(stmt.table, name, values[i]); err != nil {
+			return err
+		}
+	}
+
+	row, err := stmt.engine.insertRow(stmt.table, stmt.pk, stmt.columns, values)
+	if err != nil {
+		return err
+	}
+	data, err := marshalRow(row, stmt.table)
+	if err != nil {
+		return err
+	}
+
+	key := row[stmt.pk]
+	if _, exists := stmt.rows[key]; !exists {
+		stmt.keys = append(stmt.keys, key)
+	}
+	stmt.rows[key] = data
+	stmt.valueRows = append(stmt.valueRows, append([]string{}, values...))
+	return nil
+}
+
+// Len returns the number of rows in the batch, counting rows that share a
+// primary key once.
+func (stmt *PreparedInsert) Len() int {
+	return len(stmt.keys)
+}
+
+// Commit writes the batch in one commit and empties it. Tables with INSERT
+// triggers, and engines inside a BEGIN block, run the rows as an INSERT
+// statement instead, so triggers fire and the rows join the open transaction.
+func (stmt *PreparedInsert) Commit() (CommitResult, error) {
+	if len(stmt.keys) == 0 {
+		return CommitResult{}, errors.New("no rows to insert")
+	}
+	defer stmt.Rollback()
+
+	engine := stmt.engine
+	triggers, err := engine.tableTriggers(stmt.table.Database, stmt.table.Name, "INSERT")
+	if err != nil {
+		return CommitResult{}, err
+	}
+	if len(triggers) > 0 || engine.transaction != nil {
+		result, err := engine.ExecuteStatement(sql.InsertStatement{
+			Database:  stmt.table.Database,
+			Table:     stmt.table.Name,
+			Columns:   stmt.columns,
+			ValueRows: stmt.valueRows,
+		})
+		if err != nil {
+			return CommitResult{}, err
+		}
+		return result.(CommitResult), nil
+	}
+
+	startTime := time.Now()
+	tableOp, err := op.GetTable(stmt.table.Database, stmt.table.Name, engine.Persistence)
+	if err != nil {
+		return CommitResult{}, err
+	}
+	saveIndexes, err := engine.stageRecordIndexUpdates(tableOp, stmt.rows)
+	if err != nil {
+		return CommitResult{}, err
+	}
+
+	batch, err := engine.Persistence.BeginTransaction()
+	if err != nil {
+		return CommitResult{}, err
+	}
+	for _, key := range stmt.keys {
+		if err := batch.AddWrite(stmt.table.Database, stmt.table.Name, key, stmt.rows[key]); err != nil {
+			return CommitResult{}, err
+		}
+	}
+	txn, err := batch.Commit(engine.author(stmt.table))
+	if err != nil {
+		return CommitResult{}, err
+	}
+	if err := saveIndexes(); err != nil {
+		return CommitResult{}, err
+	}
+
+	result := CommitResult{
+		Transaction:     txn,
+		RecordsWritten:  len(stmt.keys),
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    len(stmt.keys),
+	}
+	if engine.ReportKeys {
+		result.AffectedKeys = append([]string{}, stmt.keys...)
+	}
+	if _, err := engine.afterWrite(stmt.table.Database, stmt.table.Name, result, nil); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// Rollback discards the rows added so far; the statement can be reused.
+func (stmt *PreparedInsert) Rollback() {
+	stmt.valueRows = nil
+	stmt.rows = make(map[string][]byte)
+	stmt.keys = nil
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/nickyhof/CommitDB/sql"
+)
+
+func TestPreparedInsertBatch(t *testing.T) {
+	engine := setupTestEngine(t)
+	if _, err := engine.Execute("CREATE TABLE testdb.events (id INT PRIMARY KEY, name STRING NOT NULL, score FLOAT, active BOOL DEFAULT 'true')"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	commits := func() int { return len(engine.TransactionsFrom(engine.LatestTransaction().Id)) }
+
+	stmt, err := engine.PrepareInsert("testdb", "events", "id", "name", "score", "active")
+	if err != nil {
+		t.Fatalf("PrepareInsert failed: %v", err)
+	}
+	const rows = 10000
+	for i := 1; i <= rows; i++ {
+		if err := stmt.Add(strconv.Itoa(i), "event "+strconv.Itoa(i), strconv.Itoa(i)+".5", sql.DefaultValue); err != nil {
+			t.Fatalf("Add %d failed: %v", i, err)
+		}
+	}
+
+	var constraintErr *ConstraintError
+	if err := stmt.Add("x", "bad", "1.0", "true"); !errors.As(err, &constraintErr) || constraintErr.Column != "id" {
+		t.Errorf("Expected a constraint error for id, got %v", err)
+	}
+	if err := stmt.Add("1", sql.NullValue, "1.0", "true"); err == nil {
+		t.Error("Expected NULL in a NOT NULL column to be rejected")
+	}
+	if err := stmt.Add("1", "too few"); err == nil {
+		t.Error("Expected a value count mismatch to be rejected")
+	}
+	if stmt.Len() != rows {
+		t.Fatalf("Expected %d rows in the batch, got %d", rows, stmt.Len())
+	}
+
+	before := commits()
+	result, err := stmt.Commit()
+	if err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if got := commits() - before; got != 1 {
+		t.Errorf("Expected one commit, got %d", got)
+	}
+	if result.RecordsWritten != rows || result.Transaction.Id != engine.LatestTransaction().Id {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if stmt.Len() != 0 {
+		t.Errorf("Expected Commit to empty the batch, got %d rows", stmt.Len())
+	}
+
+	count, err := engine.Execute("SELECT COUNT(*) FROM testdb.events")
+	if err != nil {
+		t.Fatalf("COUNT failed: %v", err)
+	}
+	if got := count.(QueryResult).Data[0][0]; got != strconv.Itoa(rows) {
+		t.Errorf("Expected %d rows, got %s", rows, got)
+	}
+	selected, err := engine.Execute("SELECT id, name, score, active FROM testdb.events WHERE score > 9999")
+	if err != nil {
+		t.Fatalf("SELECT failed: %v", err)
+	}
+	if got, want := selected.(QueryResult).Data, [][]string{{"9999", "event 9999", "9999.5", "true"}, {"10000", "event 10000", "10000.5", "true"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	if _, err := stmt.Commit(); err == nil {
+		t.Error("Expected committing an empty batch to fail")
+	}
+}
+
+func TestPreparedInsertInTransaction(t *testing.T) {
+	engine := setupTestEngine(t)
+
+	stmt, err := engine.PrepareInsert("testdb", "users")
+	if err != nil {
+		t.Fatalf("PrepareInsert failed: %v", err)
+	}
+	if _, err := engine.PrepareInsert("testdb", "users", "id", "missing"); err == nil {
+		t.Error("Expected an unknown column to be rejected")
+	}
+
+	if _, err := engine.Execute("BEGIN"); err != nil {
+		t.Fatalf("BEGIN failed: %v", err)
+	}
+	if err := stmt.Add("1", "Alice", "30"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if _, err := stmt.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if _, err := engine.Execute("ROLLBACK"); err != nil {
+		t.Fatalf("ROLLBACK failed: %v", err)
+	}
+
+	result, err := engine.Execute("SELECT * FROM testdb.users")
+	if err != nil {
+		t.Fatalf("SELECT failed: %v", err)
+	}
+	if got := len(result.(QueryResult).Data); got != 0 {
+		t.Errorf("Expected the rolled back rows to be gone, got %d", got)
+	}
+}
@@ -0,0 +1,183 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/nickyhof/CommitDB/core"
+)
+
+// readJSONRecords reads a JSON array of objects, or objects one after the
+// other as in JSON Lines, into stored records keyed by primary key. Object
+// keys must name table columns. Missing keys and nulls are NULL, or the
+// column's DEFAULT when it has one. JSON columns store the value's JSON,
+// so nested objects and arrays are kept; other columns take strings,
+// numbers and booleans. As with CSV, a repeated primary key replaces the
+// earlier row with a warning. resolve settles rows whose primary key is
+// already stored, and the rows it keeps must pass the table's CHECK
+// constraints.
+func (engine *Engine) readJSONRecords(ctx context.Context, reader io.Reader, table core.Table, pk string, resolve copyResolver) (map[string][]byte, error) {
+	buffered := bufio.NewReader(reader)
+	decoder := json.NewDecoder(buffered)
+
+	// A leading '[' wraps the objects in an array
+	inArray := false
+	if first, err := peekNonSpace(buffered); err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read JSON: %v", err)
+	} else if first == '[' {
+		if _, err := decoder.Token(); err != nil {
+			return nil, fmt.Errorf("failed to read JSON: %v", err)
+		}
+		inArray = true
+	}
+
+	columns := make(map[string]core.Column, len(table.Columns))
+	for _, col := range table.Columns {
+		columns[col.Name] = col
+	}
+
+	records := make(map[string][]byte)
+	rowNums := make(map[string]int) // row each primary key was last read from
+	for rowNum := 1; ; rowNum++ {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("COPY aborted after %d rows, nothing committed: %w", len(records), err)
+		}
+		if inArray && !decoder.More() {
+			break
+		}
+
+		var object map[string]json.RawMessage
+		if err := decoder.Decode(&object); err == io.EOF && !inArray {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read row %d: %v", rowNum, err)
+		}
+		if object == nil {
+			return nil, fmt.Errorf("row %d is not a JSON object", rowNum)
+		}
+
+		data := make(map[string]string)
+		for key, raw := range object {
+			column, ok := columns[key]
+			if !ok {
+				return nil, fmt.Errorf("row %d has unknown column %s", rowNum, key)
+			}
+			value, isNull, err := jsonColumnValue(column, raw)
+			if err != nil {
+				return nil, constraintErrorf(column.Name, "row %d: %v", rowNum, err)
+			}
+			if !isNull {
+				data[column.Name] = value
+			}
+		}
+		for _, column := range table.Columns {
+			if _, ok := data[column.Name]; ok {
+				continue
+			}
+			if value, ok, err := engine.columnDefault(column); err != nil {
+				return nil, err
+			} else if ok {
+				data[column.Name] = value
+			} else if column.NotNull {
+				return nil, constraintErrorf(column.Name, "row %d: column %s cannot be NULL", rowNum, column.Name)
+			}
+		}
+
+		pkValue, ok := data[pk]
+		if !ok || pkValue == "" {
+			return nil, constraintErrorf(pk, "row %d missing primary key column %s", rowNum, pk)
+		}
+		data, keep, err := resolve(pkValue, data, func(name string) bool {
+			_, ok := object[name]
+			return ok
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !keep {
+			continue
+		}
+		if err := engine.checkRow(table, data); err != nil {
+			return nil, fmt.Errorf("row %d: %w", rowNum, err)
+		}
+		jsonData, err := marshalRow(data, table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal row %d: %v", rowNum, err)
+		}
+
+		if earlier, ok := rowNums[pkValue]; ok {
+			engine.warn("COPY skipped row %d: row %d has the same primary key %s", earlier, rowNum, pkValue)
+		}
+		rowNums[pkValue] = rowNum
+		records[pkValue] = jsonData
+		if len(records)%copyProgressInterval == 0 {
+			engine.reportCopyProgress(len(records))
+		}
+	}
+
+	if inArray {
+		if _, err := decoder.Token(); err != nil {
+			return nil, fmt.Errorf("failed to read JSON: %v", err)
+		}
+	}
+	return records, nil
+}
+
+// jsonColumnValue converts a JSON value to the stored form of a column and
+// reports whether it is null.
+func jsonColumnValue(column core.Column, raw json.RawMessage) (string, bool, error) {
+	raw = bytes.TrimSpace(raw)
+	if string(raw) == "null" {
+		return "", true, nil
+	}
+	if column.Type == core.JsonType {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, raw); err != nil {
+			return "", false, err
+		}
+		return compact.String(), false, nil
+	}
+
+	var value string
+	switch raw[0] {
+	case '{', '[':
+		return "", false, fmt.Errorf("column %s cannot hold a nested JSON value", column.Name)
+	case '"':
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return "", false, err
+		}
+	default: // numbers and booleans keep their JSON text
+		value = string(raw)
+	}
+
+	// BLOB columns arrive base64-encoded, as with CSV
+	if column.Type == core.BlobType {
+		if _, err := base64.StdEncoding.DecodeString(value); err != nil {
+			return "", false, fmt.Errorf("invalid base64 in BLOB column %s", column.Name)
+		}
+		return value, false, nil
+	}
+	value, err := storedValue(column.Name, column.Type, value)
+	return value, false, err
+}
+
+// peekNonSpace returns the first byte after any whitespace without
+// consuming it.
+func peekNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != ' ' && b != '\t' && b != '\n' && b != '\r' {
+			return b, reader.UnreadByte()
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"strconv"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+
+	"github.com/nickyhof/CommitDB/core"
+	"github.com/nickyhof/CommitDB/op"
+	"github.com/nickyhof/CommitDB/sql"
+)
+
+// parquetSchema maps a table's columns to an Arrow schema for Parquet
+// export. Primary key and NOT NULL columns are required, the rest nullable.
+func parquetSchema(table core.Table) *arrow.Schema {
+	fields := make([]arrow.Field, len(table.Columns))
+	for i, col := range table.Columns {
+		var dataType arrow.DataType
+		switch col.Type {
+		case core.IntType:
+			dataType = arrow.PrimitiveTypes.Int64
+		case core.FloatType:
+			dataType = arrow.PrimitiveTypes.Float64
+		case core.BoolType:
+			dataType = arrow.FixedWidthTypes.Boolean
+		case core.DateType:
+			dataType = arrow.FixedWidthTypes.Date32
+		case core.TimestampType:
+			dataType = arrow.FixedWidthTypes.Timestamp_us
+		case core.BlobType:
+			dataType = arrow.BinaryTypes.Binary
+		default: // STRING, TEXT, JSON
+			dataType = arrow.BinaryTypes.String
+		}
+		fields[i] = arrow.Field{Name: col.Name, Type: dataType, Nullable: !col.PrimaryKey && !col.NotNull}
+	}
+	return arrow.NewSchema(fields, nil)
+}
+
+// appendParquetValue appends a stored value to a column builder, converting
+// it to the column's Parquet type. Missing and empty values of non-string
+// columns are written as NULL.
+func appendParquetValue(builder array.Builder, col core.Column, value string, ok bool) error {
+	if !ok || (value == "" && col.Type != core.StringType && col.Type != core.TextType) {
+		builder.AppendNull()
+		return nil
+	}
+
+	switch b := builder.(type) {
+	case *array.Int64Builder:
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("column %s: invalid INT value %q", col.Name, value)
+		}
+		b.Append(v)
+	case *array.Float64Builder:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("column %s: invalid FLOAT value %q", col.Name, value)
+		}
+		b.Append(v)
+	case *array.BooleanBuilder:
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("column %s: invalid BOOL value %q", col.Name, value)
+		}
+		b.Append(v)
+	case *array.Date32Builder:
+		t, err := parseDateTime(value)
+		if err != nil {
+			return fmt.Errorf("column %s: invalid DATE value %q", col.Name, value)
+		}
+		b.Append(arrow.Date32FromTime(t))
+	case *array.TimestampBuilder:
+		t, err := parseDateTime(value)
+		if err != nil {
+			return fmt.Errorf("column %s: invalid TIMESTAMP value %q", col.Name, value)
+		}
+		ts, err := arrow.TimestampFromTime(t.UTC(), arrow.Microsecond)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", col.Name, err)
+		}
+		b.Append(ts)
+	case *array.BinaryBuilder:
+		raw, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return fmt.Errorf("column %s: invalid BLOB value", col.Name)
+		}
+		b.Append(raw)
+	case *array.StringBuilder:
+		b.Append(value)
+	}
+	return nil
+}
+
+// writeParquet writes every row of a table to w as a Parquet file and returns
+// the number of rows written.
+func (engine *Engine) writeParquet(ctx context.Context, w io.Writer, tableOp *op.TableOp) (int, error) {
+	rows := func(yield func(map[string]string, error) bool) {
+		for _, payload := range tableOp.Scan() {
+			var row map[string]string
+			if err := json.Unmarshal(payload, &row); err != nil {
+				yield(nil, fmt.Errorf("failed to parse row: %v", err))
+				return
+			}
+			normalizeRow(row, tableOp.Table)
+			if !yield(row, nil) {
+				return
+			}
+		}
+	}
+	return engine.writeParquetRows(ctx, w, tableOp.Table, rows)
+}
+
+// writeQueryParquet runs the query of a COPY INTO 'file' FROM (SELECT ...) and
+// writes its result to w as a Parquet file, returning the number of rows
+// written. A result column named after a column of a queried table, with or
+// without its qualifier, takes that column's type; other columns, such as
+// aggregates and function results, are strings. Every column is nullable.
+func (engine *Engine) writeQueryParquet(ctx context.Context, w io.Writer, statement sql.CopyStatement) (int, error) {
+	result, err := engine.executeSelectStatement(ctx, *statement.Query)
+	if err != nil {
+		return 0, err
+	}
+
+	types := make(map[string]core.ColumnType)
+	addTypes := func(share, database, table, alias string) {
+		if share != "" {
+			return // tables of shares are left untyped
+		}
+		tableOp, err := op.GetTable(database, table, engine.Persistence)
+		if err != nil {
+			return // views and missing tables leave their columns untyped
+		}
+		addColumnTypes(types, tableOp.Table, tableQualifiers(database, table, alias))
+	}
+	query := statement.Query
+	addTypes(query.Share, query.Database, query.Table, query.TableAlias)
+	for _, join := range query.Joins {
+		addTypes(join.Share, join.Database, join.Table, join.TableAlias)
+	}
+
+	table := core.Table{Columns: make([]core.Column, len(result.Columns))}
+	for i, name := range result.Columns {
+		colType, ok := types[name]
+		if !ok {
+			colType = core.StringType
+		}
+		table.Columns[i] = core.Column{Name: name, Type: colType}
+	}
+
+	// Result rows hold BLOB bytes decoded; appendParquetValue takes them as stored
+	rows := func(yield func(map[string]string, error) bool) {
+		for _, values := range result.Data {
+			row := make(map[string]string, len(values))
+			for i, value := range values {
+				if table.Columns[i].Type == core.BlobType && value != "" {
+					value = base64.StdEncoding.EncodeToString([]byte(value))
+				}
+				row[table.Columns[i].Name] = value
+			}
+			if !yield(row, nil) {
+				return
+			}
+		}
+	}
+	return engine.writeParquetRows(ctx, w, table, rows)
+}
+
+// writeParquetRows writes rows, stored values by column name, to w as a
+// Parquet file typed from table's columns, one row group per
+// copyProgressInterval rows, and returns the number of rows written.
+func (engine *Engine) writeParquetRows(ctx context.Context, w io.Writer, table core.Table, rows iter.Seq2[map[string]string, error]) (int, error) {
+	schema := parquetSchema(table)
+	props := parquet.NewWriterProperties(parquet.WithCompression(compress.Codecs.Snappy))
+	// The parquet writer closes writers it is given; the caller closes w
+	fileWriter, err := pqarrow.NewFileWriter(schema, struct{ io.Writer }{w}, props, pqarrow.DefaultWriterProps())
+	if err != nil {
+		return 0, fmt.Errorf("failed to create parquet writer: %v", err)
+	}
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer builder.Release()
+
+	flush := func() error {
+		record := builder.NewRecordBatch()
+		defer record.Release()
+		if record.NumRows() == 0 {
+			return nil
+		}
+		return fileWriter.Write(record)
+	}
+
+	recordsWritten := 0
+	for row, err := range rows {
+		if err != nil {
+			return 0, err
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, fmt.Errorf("COPY aborted after %d rows: %w", recordsWritten, err)
+		}
+
+		for i, col := range table.Columns {
+			value, ok := row[col.Name]
+			if err := appendParquetValue(builder.Field(i), col, value, ok); err != nil {
+				return 0, err
+			}
+		}
+		recordsWritten++
+		if recordsWritten%copyProgressInterval == 0 {
+			if err := flush(); err != nil {
+				return 0, fmt.Errorf("failed to write rows: %v", err)
+			}
+			engine.reportCopyProgress(recordsWritten)
+		}
+	}
+	engine.reportCopyProgress(recordsWritten)
+
+	if err := flush(); err != nil {
+		return 0, fmt.Errorf("failed to write rows: %v", err)
+	}
+	if err := fileWriter.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write parquet footer: %v", err)
+	}
+	return recordsWritten, nil
+}
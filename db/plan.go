@@ -0,0 +1,178 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/nickyhof/CommitDB/op"
+	"github.com/nickyhof/CommitDB/ps"
+	"github.com/nickyhof/CommitDB/sql"
+)
+
+// AccessMethod is how a query reads the rows of its source table.
+type AccessMethod string
+
+const (
+	// AccessPrimaryKey reads the single row a WHERE equality on the primary key names.
+	AccessPrimaryKey AccessMethod = "PRIMARY KEY"
+	// AccessIndex reads the rows an index lookup on a WHERE equality returns.
+	AccessIndex AccessMethod = "INDEX"
+	// AccessScan reads every row of the table.
+	AccessScan AccessMethod = "SCAN"
+	// AccessView reads the output of a view.
+	AccessView AccessMethod = "VIEW"
+)
+
+// JoinNestedLoop compares every row of the joined table with every row
+// joined so far. It is the only join strategy.
+const JoinNestedLoop = "NESTED LOOP"
+
+// QueryPlan describes how a SELECT would read its rows, as decided by the
+// same logic that executes it.
+type QueryPlan struct {
+	Database string
+	Table    string
+	Access   AccessMethod
+	Column   string // column looked up by PRIMARY KEY and INDEX access
+	Index    string // name of the index INDEX access probes
+	// EstimatedRows is the number of rows the access reads: exact for
+	// PRIMARY KEY and INDEX access, the current table size for a scan and
+	// zero for a view.
+	EstimatedRows int
+	Joins         []JoinPlan
+}
+
+// JoinPlan describes how a JOIN reads the joined table.
+type JoinPlan struct {
+	Type          string // INNER, LEFT, RIGHT
+	Database      string
+	Table         string
+	Strategy      string
+	EstimatedRows int // rows of the joined table, all of which are scanned
+}
+
+// Plan reports how the SELECT query would be executed without running it.
+func (engine *Engine) Plan(query string) (*QueryPlan, error) {
+	parser := sql.NewParser(query)
+	statement, err := parser.Parse()
+	if err != nil {
+		return nil, err
+	}
+	return engine.PlanStatement(statement)
+}
+
+// PlanStatement is Plan for an already parsed or built statement.
+func (engine *Engine) PlanStatement(statement sql.Statement) (*QueryPlan, error) {
+	selectStatement, ok := statement.(sql.SelectStatement)
+	if !ok {
+		return nil, errors.New("only SELECT statements can be planned")
+	}
+
+	shares := make(queryShares)
+	defer shares.close()
+
+	persistence := engine.Persistence
+	if selectStatement.Share != "" {
+		sharePersistence, err := shares.open(engine.Persistence, selectStatement.Share)
+		if err != nil {
+			return nil, fmt.Errorf("failed to access share '%s': %w", selectStatement.Share, err)
+		}
+		persistence = sharePersistence
+	}
+
+	plan := &QueryPlan{Database: selectStatement.Database, Table: selectStatement.Table}
+	if _, err := persistence.GetView(selectStatement.Database, selectStatement.Table); err == nil {
+		plan.Access = AccessView
+	} else {
+		tableOp, err := op.GetTable(selectStatement.Database, selectStatement.Table, persistence)
+		if err != nil {
+			return nil, err
+		}
+		path := accessPath{method: AccessScan}
+		if selectStatement.AsOf == "" {
+			path = engine.chooseAccessPath(selectStatement, tableOp, persistence)
+		}
+		plan.Access = path.method
+		switch path.method {
+		case AccessPrimaryKey:
+			plan.Column = path.column
+			if _, exists := tableOp.Get(path.value); exists {
+				plan.EstimatedRows = 1
+			}
+		case AccessIndex:
+			plan.Column = path.column
+			plan.Index = path.index.Name
+			plan.EstimatedRows = len(path.index.Lookup(path.value))
+		default:
+			plan.EstimatedRows = tableOp.Count()
+		}
+	}
+
+	for _, join := range selectStatement.Joins {
+		joinPersistence := engine.Persistence
+		if join.Share != "" {
+			sharePersistence, err := shares.open(engine.Persistence, join.Share)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open share '%s' for join: %w", join.Share, err)
+			}
+			joinPersistence = sharePersistence
+		}
+		joinTableOp, err := op.GetTable(join.Database, join.Table, joinPersistence)
+		if errors.Is(err, ps.ErrTableNotFound) {
+			return nil, fmt.Errorf("join %w: %s.%s", ps.ErrTableNotFound, join.Database, join.Table)
+		} else if err != nil {
+			return nil, err
+		}
+		plan.Joins = append(plan.Joins, JoinPlan{
+			Type:          join.Type,
+			Database:      join.Database,
+			Table:         join.Table,
+			Strategy:      JoinNestedLoop,
+			EstimatedRows: joinTableOp.Count(),
+		})
+	}
+	return plan, nil
+}
+
+// accessPath is how a SELECT reads its source table's rows.
+type accessPath struct {
+	method AccessMethod
+	column string    // column of a PRIMARY KEY or INDEX lookup
+	value  string    // primary key or indexed value looked up
+	index  *ps.Index // index probed by INDEX access
+}
+
+// chooseAccessPath picks how statement reads tableOp's rows: a direct read
+// when WHERE pins the primary key, a lookup when it compares an indexed
+// column for equality without any OR, otherwise a full scan. Joins and the NO_INDEX hint
+// always scan.
+func (engine *Engine) chooseAccessPath(statement sql.SelectStatement, tableOp *op.TableOp, persistence *ps.Persistence) accessPath {
+	scan := accessPath{method: AccessScan}
+	if len(statement.Joins) > 0 || statement.NoIndex {
+		return scan
+	}
+
+	// The primary key is the storage key, so equality on it is a direct read
+	if key, ok := primaryKeyLookup(statement.Where, tableOp.Table, engine.Collation); ok {
+		return accessPath{method: AccessPrimaryKey, column: primaryKeyOrder(tableOp.Table)[0].Column, value: key}
+	}
+	// An index lookup finds every match only when all conditions must hold
+	if len(statement.Where.Conditions) == 0 || slices.Contains(statement.Where.LogicalOps, sql.LogicalOr) {
+		return scan
+	}
+
+	indexManager := ps.NewIndexManager(persistence, engine.Identity)
+	indexManager.LoadIndexes(statement.Database, statement.Table, tableOp.Table.Columns)
+
+	// Index keys are exact, so case-insensitive equality must scan
+	for _, cond := range statement.Where.Conditions {
+		if cond.Operator == sql.EqualsOperator && !cond.Negated && engine.Collation != CollationCaseInsensitive && !isBlobColumn(tableOp.Table, cond.Left) {
+			if idx, found := indexManager.GetIndex(statement.Database, statement.Table, cond.Left); found {
+				// Only the first matching index is used
+				return accessPath{method: AccessIndex, column: cond.Left, value: cond.Right, index: idx}
+			}
+		}
+	}
+	return scan
+}
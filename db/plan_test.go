@@ -0,0 +1,47 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnginePlan(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+	_, _ = engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (4, 'Dave', 30)")
+	if _, err := engine.Execute("CREATE INDEX idx_age ON testdb.users(age)"); err != nil {
+		t.Fatalf("Failed to CREATE INDEX: %v", err)
+	}
+	_, _ = engine.Execute("CREATE TABLE testdb.orders (order_id INT PRIMARY KEY, user_id INT)")
+
+	tests := []struct {
+		query    string
+		expected QueryPlan
+	}{
+		{"SELECT * FROM testdb.users WHERE age = 30",
+			QueryPlan{Database: "testdb", Table: "users", Access: AccessIndex, Column: "age", Index: "idx_age", EstimatedRows: 2}},
+		{"SELECT * FROM testdb.users WHERE id = 2",
+			QueryPlan{Database: "testdb", Table: "users", Access: AccessPrimaryKey, Column: "id", EstimatedRows: 1}},
+		{"SELECT * FROM testdb.users WHERE name = 'Bob'",
+			QueryPlan{Database: "testdb", Table: "users", Access: AccessScan, EstimatedRows: 4}},
+		{"SELECT /*+ NO_INDEX */ * FROM testdb.users WHERE age = 30",
+			QueryPlan{Database: "testdb", Table: "users", Access: AccessScan, EstimatedRows: 4}},
+		{"SELECT * FROM testdb.users u JOIN testdb.orders o ON u.id = o.user_id WHERE age = 30",
+			QueryPlan{Database: "testdb", Table: "users", Access: AccessScan, EstimatedRows: 4,
+				Joins: []JoinPlan{{Type: "INNER", Database: "testdb", Table: "orders", Strategy: JoinNestedLoop}}}},
+	}
+
+	for _, test := range tests {
+		plan, err := engine.Plan(test.query)
+		if err != nil {
+			t.Fatalf("%s: %v", test.query, err)
+		}
+		if !reflect.DeepEqual(*plan, test.expected) {
+			t.Errorf("%s: expected %+v, got %+v", test.query, test.expected, *plan)
+		}
+	}
+
+	if _, err := engine.Plan("DELETE FROM testdb.users WHERE id = 1"); err == nil {
+		t.Error("Expected error planning a DELETE")
+	}
+}
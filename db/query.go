@@ -1,7 +1,69 @@
 package db
 
-import "github.com/nickyhof/CommitDB/core"
+import (
+	"strings"
+
+	"github.com/nickyhof/CommitDB/core"
+)
+
+// Collation controls how string equality and LIKE treat letter case.
+type Collation int
+
+const (
+	// CollationDefault compares = and IN exactly and LIKE case-insensitively.
+	CollationDefault Collation = iota
+	// CollationCaseSensitive compares =, IN and LIKE exactly.
+	CollationCaseSensitive
+	// CollationCaseInsensitive ignores case for =, IN and LIKE.
+	CollationCaseInsensitive
+)
+
+// equal reports whether two values are equal under the collation.
+func (collation Collation) equal(a, b string) bool {
+	if collation == CollationCaseInsensitive {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// likeCaseSensitive reports whether LIKE patterns match letter case exactly.
+func (collation Collation) likeCaseSensitive() bool {
+	return collation == CollationCaseSensitive
+}
 
 type QueryContext struct {
-	Identity core.Identity
+	Identity  core.Identity
+	Collation Collation
+	// CopyProgress, if set, is called with the number of rows COPY has
+	// processed so far, periodically and once more when all rows are read.
+	CopyProgress func(rows int)
+	// StrictReads makes SELECT fail on a stored row that is not valid JSON.
+	// Otherwise such rows are skipped and counted in QueryResult.CorruptRows.
+	StrictReads bool
+	// MaxInValues is the longest IN list a query may use; 0 keeps
+	// sql.DefaultMaxInValues and a negative value removes the limit.
+	MaxInValues int
+	// ReportKeys makes INSERT, UPDATE and DELETE list the primary keys of the
+	// rows they write or delete in CommitResult.AffectedKeys. It is off by
+	// default so large mutations do not collect every key.
+	ReportKeys bool
+	// MaxResultRows makes a SELECT fail with ErrResultTooLarge when its
+	// result would have more rows. A single-table SELECT without aggregates
+	// or DISTINCT stops reading once enough rows match, rather than after
+	// building the result. 0 means no limit.
+	MaxResultRows int
+	// MaxResultRowsCap is a row cap set by the embedding application, such
+	// as a server's, that applies whatever MaxResultRows is: a SELECT fails
+	// or is truncated past the lower of the two. SET max_result_rows may only
+	// lower it, and 0 or DEFAULT restore it. 0 means no cap.
+	MaxResultRowsCap int
+	// TruncateResults makes a SELECT over MaxResultRows return its first
+	// MaxResultRows rows with QueryResult.Truncated set, instead of failing.
+	// A SELECT that ExecuteRows would stream stops reading at the row after
+	// them.
+	TruncateResults bool
+	// ImplicitIdentity marks Identity as a fallback the caller did not
+	// choose, such as a server's identity for anonymous connections. Row
+	// writes to a table with an AUTHOR are then committed as that author.
+	ImplicitIdentity bool
 }
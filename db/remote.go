@@ -2,6 +2,7 @@
 package db
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -14,6 +15,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 // s3Config contains S3 authentication configuration
@@ -182,41 +184,146 @@ func openS3Reader(url string, cfg *s3Config) (io.ReadCloser, error) {
 	return resp.Body, nil
 }
 
-// s3Writer wraps S3 upload in a WriteCloser interface
+// s3PartSize is the multipart upload part size for S3 writes. S3 requires
+// every part except the last to be at least 5 MiB.
+const s3PartSize = 8 << 20
+
+// writeAborter is implemented by writers that can discard a partial upload
+type writeAborter interface {
+	abort(err error)
+}
+
+// s3UploadAPI is the subset of the S3 client used by s3Writer
+type s3UploadAPI interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+}
+
+// s3Writer streams writes to S3 as a multipart upload, holding at most one
+// part in memory. Objects smaller than one part are sent with a single PutObject.
 type s3Writer struct {
-	ctx    context.Context
-	client *s3.Client
-	bucket string
-	key    string
-	buffer []byte
-	closed bool
+	ctx      context.Context
+	client   s3UploadAPI
+	bucket   string
+	key      string
+	partSize int
+	buffer   []byte
+	uploadID *string
+	parts    []types.CompletedPart
+	err      error
+	closed   bool
 }
 
 func (w *s3Writer) Write(p []byte) (n int, err error) {
 	if w.closed {
 		return 0, fmt.Errorf("writer is closed")
 	}
-	w.buffer = append(w.buffer, p...)
-	return len(p), nil
+	if w.err != nil {
+		return 0, w.err
+	}
+	for len(p) > 0 {
+		chunk := min(len(p), w.partSize-len(w.buffer))
+		w.buffer = append(w.buffer, p[:chunk]...)
+		p = p[chunk:]
+		n += chunk
+
+		if len(w.buffer) == w.partSize {
+			if err := w.uploadPart(); err != nil {
+				w.abort(err)
+				return n, w.err
+			}
+		}
+	}
+	return n, nil
+}
+
+// uploadPart sends the buffered bytes as the next part, starting the
+// multipart upload on first use.
+func (w *s3Writer) uploadPart() error {
+	if w.uploadID == nil {
+		out, err := w.client.CreateMultipartUpload(w.ctx, &s3.CreateMultipartUploadInput{
+			Bucket: aws.String(w.bucket),
+			Key:    aws.String(w.key),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to start S3 multipart upload: %w", err)
+		}
+		w.uploadID = out.UploadId
+	}
+
+	partNumber := int32(len(w.parts) + 1)
+	out, err := w.client.UploadPart(w.ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(w.bucket),
+		Key:        aws.String(w.key),
+		UploadId:   w.uploadID,
+		PartNumber: aws.Int32(partNumber),
+		Body:       bytes.NewReader(w.buffer),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload part %d to S3: %w", partNumber, err)
+	}
+
+	w.parts = append(w.parts, types.CompletedPart{ETag: out.ETag, PartNumber: aws.Int32(partNumber)})
+	w.buffer = w.buffer[:0]
+	return nil
+}
+
+// abort records err and cancels any multipart upload in progress, so S3
+// does not keep the uploaded parts.
+func (w *s3Writer) abort(err error) {
+	w.err = err
+	if w.uploadID != nil {
+		_, _ = w.client.AbortMultipartUpload(w.ctx, &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(w.bucket),
+			Key:      aws.String(w.key),
+			UploadId: w.uploadID,
+		})
+	}
 }
 
 func (w *s3Writer) Close() error {
 	if w.closed {
-		return nil
+		return w.err
 	}
 	w.closed = true
+	if w.err != nil {
+		return w.err
+	}
+
+	// Small objects never started a multipart upload
+	if w.uploadID == nil {
+		_, err := w.client.PutObject(w.ctx, &s3.PutObjectInput{
+			Bucket: aws.String(w.bucket),
+			Key:    aws.String(w.key),
+			Body:   bytes.NewReader(w.buffer),
+		})
+		if err != nil {
+			w.err = fmt.Errorf("failed to upload to S3: %w", err)
+		}
+		return w.err
+	}
+
+	// The last part may be smaller than partSize
+	if len(w.buffer) > 0 {
+		if err := w.uploadPart(); err != nil {
+			w.abort(err)
+			return w.err
+		}
+	}
 
-	// Upload the buffered content
-	_, err := w.client.PutObject(w.ctx, &s3.PutObjectInput{
-		Bucket: aws.String(w.bucket),
-		Key:    aws.String(w.key),
-		Body:   strings.NewReader(string(w.buffer)),
+	_, err := w.client.CompleteMultipartUpload(w.ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(w.bucket),
+		Key:             aws.String(w.key),
+		UploadId:        w.uploadID,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: w.parts},
 	})
 	if err != nil {
-		return fmt.Errorf("failed to upload to S3: %w", err)
+		w.abort(fmt.Errorf("failed to complete S3 multipart upload: %w", err))
 	}
-
-	return nil
+	return w.err
 }
 
 // openS3Writer opens a writer for an S3 object
@@ -233,11 +340,11 @@ func openS3Writer(url string, cfg *s3Config) (io.WriteCloser, error) {
 	}
 
 	return &s3Writer{
-		ctx:    ctx,
-		client: client,
-		bucket: bucket,
-		key:    key,
-		buffer: make([]byte, 0),
+		ctx:      ctx,
+		client:   client,
+		bucket:   bucket,
+		key:      key,
+		partSize: s3PartSize,
 	}, nil
 }
 
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// mockS3 records uploads made through s3UploadAPI
+type mockS3 struct {
+	putObjects [][]byte
+	parts      [][]byte
+	completed  bool
+	aborted    bool
+	failPart   int // 1-based part number to fail, 0 for none
+}
+
+func (m *mockS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	body, _ := io.ReadAll(params.Body)
+	m.putObjects = append(m.putObjects, body)
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (m *mockS3) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-1")}, nil
+}
+
+func (m *mockS3) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	if int(*params.PartNumber) == m.failPart {
+		return nil, errors.New("part rejected")
+	}
+	body, _ := io.ReadAll(params.Body)
+	m.parts = append(m.parts, body)
+	return &s3.UploadPartOutput{ETag: aws.String(strconv.Itoa(len(m.parts)))}, nil
+}
+
+func (m *mockS3) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	m.completed = len(params.MultipartUpload.Parts) == len(m.parts)
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (m *mockS3) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	m.aborted = true
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func newMockS3Writer(client *mockS3, partSize int) *s3Writer {
+	return &s3Writer{
+		ctx:      context.Background(),
+		client:   client,
+		bucket:   "bucket",
+		key:      "export.csv",
+		partSize: partSize,
+	}
+}
+
+func TestS3WriterMultipart(t *testing.T) {
+	client := &mockS3{}
+	w := newMockS3Writer(client, 1024)
+
+	line := []byte("1,some row value,another column\n")
+	var written bytes.Buffer
+	for i := 0; i < 1000; i++ {
+		if _, err := w.Write(line); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		written.Write(line)
+		if len(w.buffer) > w.partSize {
+			t.Fatalf("Buffered %d bytes, expected at most %d", len(w.buffer), w.partSize)
+		}
+	}
+
+	// Parts are uploaded while writing, not at Close
+	if len(client.parts) == 0 {
+		t.Fatal("Expected parts to be uploaded before Close")
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if !client.completed || len(client.putObjects) != 0 {
+		t.Fatalf("Expected a completed multipart upload, got completed=%v putObjects=%d", client.completed, len(client.putObjects))
+	}
+	for i, part := range client.parts[:len(client.parts)-1] {
+		if len(part) != 1024 {
+			t.Errorf("Part %d has %d bytes, expected 1024", i+1, len(part))
+		}
+	}
+	if got := bytes.Join(client.parts, nil); !bytes.Equal(got, written.Bytes()) {
+		t.Error("Uploaded parts do not match written data")
+	}
+}
+
+func TestS3WriterSmallObject(t *testing.T) {
+	client := &mockS3{}
+	w := newMockS3Writer(client, 1024)
+
+	_, _ = w.Write([]byte("id,name\n1,Alice\n"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if len(client.putObjects) != 1 || len(client.parts) != 0 {
+		t.Fatalf("Expected a single PutObject, got %d puts and %d parts", len(client.putObjects), len(client.parts))
+	}
+	if string(client.putObjects[0]) != "id,name\n1,Alice\n" {
+		t.Errorf("Unexpected object body %q", client.putObjects[0])
+	}
+}
+
+func TestS3WriterAbortsOnFailure(t *testing.T) {
+	client := &mockS3{failPart: 2}
+	w := newMockS3Writer(client, 16)
+
+	_, err := w.Write(bytes.Repeat([]byte("x"), 64))
+	if err == nil {
+		t.Fatal("Expected Write to fail when a part is rejected")
+	}
+	if !client.aborted {
+		t.Error("Expected the multipart upload to be aborted")
+	}
+	if err := w.Close(); err == nil {
+		t.Error("Expected Close to report the upload error")
+	}
+	if client.completed {
+		t.Error("Expected the failed upload not to be completed")
+	}
+}
+
+func TestS3WriterAbortBeforeClose(t *testing.T) {
+	client := &mockS3{}
+	w := newMockS3Writer(client, 1024)
+
+	_, _ = w.Write([]byte("partial"))
+	w.abort(errors.New("cancelled"))
+	if err := w.Close(); err == nil {
+		t.Error("Expected Close to report the abort")
+	}
+	if len(client.putObjects) != 0 {
+		t.Error("Expected an aborted export not to be uploaded")
+	}
+}
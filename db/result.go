@@ -2,8 +2,10 @@ package db
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/nickyhof/CommitDB/ps"
 )
@@ -20,11 +22,36 @@ type Result interface {
 	Display()
 }
 
+// Print writes result to w with options, as its Display does to stdout with
+// the default options.
+func Print(w io.Writer, result Result, options DisplayOptions) {
+	switch result := result.(type) {
+	case QueryResult:
+		result.Print(w, options)
+	case CommitResult:
+		result.Print(w, options)
+	}
+}
+
+// DisplayOptions controls how Print renders a result.
+type DisplayOptions struct {
+	// MaxColumnWidth cuts longer values to this many characters, ending them
+	// with an ellipsis; 0 shows values in full
+	MaxColumnWidth int
+	// Plain writes rows as tab-separated values under a header line, without
+	// borders, padding or the stats line, for piping into other tools.
+	// Values are never cut in plain mode.
+	Plain bool
+}
+
 type QueryResult struct {
 	Transaction     ps.Transaction
 	Columns         []string
 	Data            [][]string
 	RecordsRead     int
+	TotalRecords    int  // Rows available before LIMIT/OFFSET, for paged listings; 0 otherwise
+	CorruptRows     int  // Stored rows skipped because they could not be decoded
+	Truncated       bool // Rows past QueryContext.MaxResultRows were dropped, under TruncateResults
 	ExecutionTimeMs float64
 	ExecutionOps    int
 }
@@ -40,6 +67,11 @@ type CommitResult struct {
 	RecordsDeleted   int
 	ExecutionTimeMs  float64
 	ExecutionOps     int
+	Returning        *QueryResult // Rows requested with RETURNING; nil otherwise
+	AffectedKeys     []string     // Primary keys INSERT, UPDATE or DELETE wrote or deleted, with QueryContext.ReportKeys; nil otherwise
+	DryRun           bool         // A DRY RUN statement: the counts are what it would change, and nothing was committed
+
+	rows []map[string]string // the RETURNING rows as stored, a NULL column absent, for triggers
 }
 
 func (result QueryResult) Type() ResultType {
@@ -50,6 +82,12 @@ func (result CommitResult) Type() ResultType {
 	return CommitResultType
 }
 
+// elapsedMs returns the time since start in fractional milliseconds, so fast
+// statements do not report zero.
+func elapsedMs(start time.Time) float64 {
+	return float64(time.Since(start).Nanoseconds()) / 1e6
+}
+
 // formatDuration formats a duration in human-readable form
 func formatDuration(secs float64) string {
 	if secs < 0.001 {
@@ -85,13 +123,37 @@ func (result CommitResult) ExecutionTime() string {
 	return formatDuration(result.ExecutionTimeMs / 1000)
 }
 
+// printRows writes result rows as a bordered table, or as tab-separated
+// values in plain mode
+func printRows(w io.Writer, columns []string, rows [][]string, options DisplayOptions) {
+	if options.Plain {
+		fmt.Fprintln(w, strings.Join(columns, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		return
+	}
+
+	data := NewTable(w)
+	data.SetMaxWidth(options.MaxColumnWidth)
+	data.Header(columns)
+	data.Bulk(rows)
+	data.Render()
+}
+
+// Display prints the result to stdout with the default options
 func (result QueryResult) Display() {
+	result.Print(os.Stdout, DisplayOptions{})
+}
+
+// Print writes the result rows followed by a stats line
+func (result QueryResult) Print(w io.Writer, options DisplayOptions) {
 	// Show data table first if there is data
 	if len(result.Data) > 0 {
-		data := NewTable(os.Stdout)
-		data.Header(result.Columns)
-		data.Bulk(result.Data)
-		data.Render()
+		printRows(w, result.Columns, result.Data, options)
+	}
+	if options.Plain {
+		return
 	}
 
 	// Calculate throughput
@@ -108,10 +170,30 @@ func (result QueryResult) Display() {
 	}
 
 	// Show compact stats line after data
-	fmt.Printf("%d rows (%s%s)\n", result.RecordsRead, result.ExecutionTime(), throughputStr)
+	if result.TotalRecords > result.RecordsRead {
+		fmt.Fprintf(w, "%d of %d rows (%s%s)\n", result.RecordsRead, result.TotalRecords, result.ExecutionTime(), throughputStr)
+	} else {
+		fmt.Fprintf(w, "%d rows (%s%s)\n", result.RecordsRead, result.ExecutionTime(), throughputStr)
+	}
+	if result.CorruptRows > 0 {
+		fmt.Fprintf(w, "Warning: %d corrupt rows skipped\n", result.CorruptRows)
+	}
 }
 
+// Display prints the result to stdout with the default options
 func (result CommitResult) Display() {
+	result.Print(os.Stdout, DisplayOptions{})
+}
+
+// Print writes any RETURNING rows followed by a summary of the changes
+func (result CommitResult) Print(w io.Writer, options DisplayOptions) {
+	if result.Returning != nil && len(result.Returning.Data) > 0 {
+		printRows(w, result.Returning.Columns, result.Returning.Data, options)
+	}
+	if options.Plain {
+		return
+	}
+
 	var parts []string
 
 	if result.DatabasesCreated > 0 {
@@ -149,9 +231,16 @@ func (result CommitResult) Display() {
 		}
 	}
 
-	if len(parts) == 0 {
-		fmt.Printf("OK (%s%s)\n", result.ExecutionTime(), throughputStr)
+	if result.DryRun {
+		if len(parts) == 0 {
+			parts = append(parts, "0 records changed")
+		}
+		fmt.Fprintf(w, "DRY RUN: %s, no commit (%s%s)\n", strings.Join(parts, ", "), result.ExecutionTime(), throughputStr)
+	} else if len(parts) == 0 && result.Transaction.Unchanged {
+		fmt.Fprintf(w, "0 records changed, no commit (%s%s)\n", result.ExecutionTime(), throughputStr)
+	} else if len(parts) == 0 {
+		fmt.Fprintf(w, "OK (%s%s)\n", result.ExecutionTime(), throughputStr)
 	} else {
-		fmt.Printf("%s (%s%s)\n", strings.Join(parts, ", "), result.ExecutionTime(), throughputStr)
+		fmt.Fprintf(w, "%s (%s%s)\n", strings.Join(parts, ", "), result.ExecutionTime(), throughputStr)
 	}
 }
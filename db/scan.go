@@ -0,0 +1,177 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query executes a SELECT and appends its rows to dest, a pointer to a slice
+// of structs, as QueryResult.Scan does.
+//
+//	type User struct {
+//	    ID     int    `db:"id"`
+//	    Name   string `db:"name"`
+//	    Active bool   `db:"active"`
+//	}
+//	var users []User
+//	err := engine.Query("SELECT * FROM mydb.users", &users)
+func (engine *Engine) Query(query string, dest any) error {
+	result, err := engine.Execute(query)
+	if err != nil {
+		return err
+	}
+	queryResult, ok := result.(QueryResult)
+	if !ok {
+		return errors.New("Query needs a statement that returns rows")
+	}
+	return queryResult.Scan(dest)
+}
+
+// Scan appends the result rows to dest, a pointer to a slice of structs or
+// of struct pointers. Each column goes to the field tagged `db:"column"`, or
+// else the exported field whose name matches the column ignoring case.
+// Fields tagged `db:"-"` and columns without a field are skipped.
+//
+// Values are converted to the field's type: strings, integers, floats,
+// bools, time.Time for DATE and TIMESTAMP values and []byte for BLOBs. An
+// empty value, which is how NULL reads, leaves the field's zero value, or
+// nil in a pointer field.
+func (result QueryResult) Scan(dest any) error {
+	slice := reflect.ValueOf(dest)
+	if slice.Kind() != reflect.Pointer || slice.IsNil() || slice.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("Scan needs a pointer to a slice of structs, got %T", dest)
+	}
+	slice = slice.Elem()
+
+	elemType := slice.Type().Elem()
+	structType := elemType
+	if elemType.Kind() == reflect.Pointer {
+		structType = elemType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return fmt.Errorf("Scan needs a pointer to a slice of structs, got %T", dest)
+	}
+
+	// Field index path for each result column, nil when it has no field
+	fields := make([][]int, len(result.Columns))
+	for i, column := range result.Columns {
+		fields[i] = scanField(structType, column)
+	}
+
+	for rowNum, row := range result.Data {
+		item := reflect.New(structType).Elem()
+		for i, value := range row {
+			if i >= len(fields) || fields[i] == nil {
+				continue
+			}
+			if err := setScanValue(item.FieldByIndex(fields[i]), value); err != nil {
+				return fmt.Errorf("row %d, column %s: %w", rowNum+1, result.Columns[i], err)
+			}
+		}
+		if elemType.Kind() == reflect.Pointer {
+			item = item.Addr()
+		}
+		slice.Set(reflect.Append(slice, item))
+	}
+	return nil
+}
+
+// scanField returns the index path of the struct field a column scans into,
+// preferring a db tag over a field name match
+func scanField(structType reflect.Type, column string) []int {
+	var byName []int
+	for _, field := range reflect.VisibleFields(structType) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		tag, hasTag := field.Tag.Lookup("db")
+		if tag == "-" {
+			continue
+		}
+		if hasTag && tag == column {
+			return field.Index
+		}
+		if !hasTag && byName == nil && strings.EqualFold(field.Name, column) {
+			byName = field.Index
+		}
+	}
+	return byName
+}
+
+var (
+	timeType  = reflect.TypeFor[time.Time]()
+	bytesType = reflect.TypeFor[[]byte]()
+)
+
+// setScanValue converts a result value to the type of field and stores it
+func setScanValue(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Pointer {
+		if value == "" {
+			field.SetZero()
+			return nil
+		}
+		target := reflect.New(field.Type().Elem())
+		if err := setScanValue(target.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(target)
+		return nil
+	}
+
+	switch field.Type() {
+	case timeType:
+		if value == "" {
+			field.SetZero()
+			return nil
+		}
+		t, err := parseDateTime(value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	case bytesType:
+		field.SetBytes([]byte(value))
+		return nil
+	}
+
+	if value == "" && field.Kind() != reflect.String {
+		field.SetZero()
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("cannot convert %q to %s", value, field.Type())
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("cannot convert %q to %s", value, field.Type())
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("cannot convert %q to %s", value, field.Type())
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("cannot convert %q to %s", value, field.Type())
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEngineQueryScan(t *testing.T) {
+	engine := setupTestEngine(t)
+	if _, err := engine.Execute("CREATE TABLE testdb.members (id INT PRIMARY KEY, name STRING, active BOOL, joined DATE, score FLOAT)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	for _, query := range []string{
+		"INSERT INTO testdb.members (id, name, active, joined, score) VALUES (1, 'Alice', 'true', '2024-01-15', '9.5')",
+		"INSERT INTO testdb.members (id, name, active) VALUES (2, 'Bob', 'false')",
+	} {
+		if _, err := engine.Execute(query); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	type User struct {
+		ID      int       `db:"id"`
+		Name    string    `db:"name"`
+		Active  bool      `db:"active"`
+		Joined  time.Time `db:"joined"`
+		Score   *float64  // matched by name
+		Ignored string    `db:"-"`
+	}
+
+	var users []User
+	if err := engine.Query("SELECT * FROM testdb.members ORDER BY id", &users); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	score := 9.5
+	expected := []User{
+		{ID: 1, Name: "Alice", Active: true, Joined: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), Score: &score},
+		{ID: 2, Name: "Bob"},
+	}
+	if !reflect.DeepEqual(users, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, users)
+	}
+
+	var pointers []*User
+	if err := engine.Query("SELECT id, name FROM testdb.members WHERE id = 2", &pointers); err != nil {
+		t.Fatalf("Query into pointers failed: %v", err)
+	}
+	if len(pointers) != 1 || pointers[0].Name != "Bob" {
+		t.Errorf("Expected Bob, got %+v", pointers)
+	}
+
+	var wrong []struct {
+		Name int `db:"name"`
+	}
+	err := engine.Query("SELECT name FROM testdb.members", &wrong)
+	if err == nil || !strings.Contains(err.Error(), `row 1, column name: cannot convert "Alice" to int`) {
+		t.Errorf("Expected conversion error, got %v", err)
+	}
+	if err := engine.Query("SELECT * FROM testdb.members", users); err == nil {
+		t.Error("Expected an error for a non-pointer destination")
+	}
+}
@@ -0,0 +1,244 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"slices"
+	"time"
+
+	"github.com/nickyhof/CommitDB/core"
+	"github.com/nickyhof/CommitDB/op"
+	"github.com/nickyhof/CommitDB/ps"
+	"github.com/nickyhof/CommitDB/sql"
+)
+
+// ExecuteRows executes a statement as ExecuteContext does, except that the
+// rows of a SELECT reading one table without ORDER BY, aggregates, DISTINCT
+// or functions come back as an iterator rather than in the result: each row
+// is read from storage when the iterator reaches it, so a caller paging
+// through a large result holds only the table's primary keys. The
+// QueryResult then has the columns and read transaction but no Data. For any
+// other statement rows is nil.
+//
+// Rows are read as of the commit that was HEAD when the statement ran, so
+// commits made while the rows are iterated do not show up part way through;
+// with write-behind enabled, as inside a transaction, they are read from its
+// buffered state instead. Iteration fails with ErrResultTooLarge after
+// MaxResultRows rows, or ends there under TruncateResults, and stops when ctx
+// is cancelled.
+func (engine *Engine) ExecuteRows(ctx context.Context, query string) (Result, iter.Seq2[[]string, error], error) {
+	statement, err := engine.parse(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	if selectStatement, ok := statement.(sql.SelectStatement); ok && streamable(selectStatement) {
+		if engine.nesting == 0 {
+			engine.warnings = nil
+		}
+		result, rows, err := engine.streamSelect(ctx, engine.pinSnapshot(selectStatement), engine.maxResultRows(), &readCounts{})
+		if err != nil {
+			return nil, nil, err
+		}
+		if rows != nil {
+			return result, rows, nil
+		}
+	}
+	result, err := engine.ExecuteStatementContext(ctx, statement)
+	return result, nil, err
+}
+
+// streamable reports whether each row statement reads from its table is at
+// most one result row, in primary key order, so result rows can be produced
+// as the table is read.
+func streamable(statement sql.SelectStatement) bool {
+	return statement.Share == "" && len(statement.Joins) == 0 && len(statement.OrderBy) == 0 &&
+		len(statement.Aggregates) == 0 && len(statement.HavingAggregates) == 0 && !statement.CountAll &&
+		len(statement.GroupBy) == 0 && len(statement.Having.Conditions) == 0 && len(statement.Computed) == 0 &&
+		!statement.Distinct && len(statement.DistinctOn) == 0 && len(statement.Functions) == 0
+}
+
+// readCounts tallies the stored rows a streamed read has scanned and the
+// corrupt ones it skipped.
+type readCounts struct {
+	scanned int
+	corrupt int
+}
+
+// streamSelect returns the result and row iterator of a streamable
+// statement, whose rows stop after maxRows as for MaxResultRows; 0 means no
+// limit. The iterator adds to counts as it reads. Views and lookups by
+// primary key or index, which read few rows, return no iterator and are left
+// to selectRows.
+func (engine *Engine) streamSelect(ctx context.Context, statement sql.SelectStatement, maxRows int, counts *readCounts) (QueryResult, iter.Seq2[[]string, error], error) {
+	startTime := time.Now()
+	persistence := engine.Persistence
+	if _, err := persistence.GetView(statement.Database, statement.Table); err == nil {
+		return QueryResult{}, nil, nil
+	}
+	statement.Where = unqualifyWhere(statement.Where, tableQualifiers(statement.Database, statement.Table, statement.TableAlias))
+
+	// Without buffered writes to see, rows are read at the current commit
+	readTransaction := ps.Transaction{Id: statement.AsOf}
+	_, buffering := persistence.WriteBehindOptions()
+	if statement.AsOf == "" {
+		readTransaction = persistence.LatestTransaction()
+	}
+
+	var table *core.Table
+	var recordKeys []string
+	var read func(key string) ([]byte, bool, error)
+	if statement.AsOf == "" {
+		tableOp, err := op.GetTable(statement.Database, statement.Table, persistence)
+		if err != nil {
+			return QueryResult{}, nil, err
+		}
+		if engine.chooseAccessPath(statement, tableOp, persistence).method != AccessScan {
+			return QueryResult{}, nil, nil
+		}
+		if buffering || readTransaction.Id == "" {
+			table, recordKeys = &tableOp.Table, tableOp.Keys()
+			read = func(key string) ([]byte, bool, error) {
+				rawData, exists := tableOp.Get(key)
+				return rawData, exists, nil
+			}
+		}
+	}
+	if table == nil {
+		var err error
+		table, err = persistence.GetTableAtTransaction(statement.Database, statement.Table, readTransaction.Id)
+		if err != nil {
+			return QueryResult{}, nil, fmt.Errorf("failed to get table at transaction %s: %w", readTransaction.Id, err)
+		}
+		recordKeys, err = persistence.ListRecordsAtTransaction(statement.Database, statement.Table, readTransaction.Id)
+		if err != nil {
+			return QueryResult{}, nil, fmt.Errorf("failed to list records at transaction %s: %w", readTransaction.Id, err)
+		}
+		read = func(key string) ([]byte, bool, error) {
+			return persistence.GetRecordAtTransaction(statement.Database, statement.Table, key, readTransaction.Id)
+		}
+	}
+	// The storage key is the primary key, so sorted keys give primary key order
+	slices.SortStableFunc(recordKeys, compareValues)
+
+	var sourceColumns []string
+	for _, column := range table.Columns {
+		sourceColumns = append(sourceColumns, column.Name)
+	}
+	qualifiedColumns := make(map[string][]string)
+	addTableQualifiers(qualifiedColumns, statement.Database, statement.Table, statement.TableAlias, sourceColumns)
+	if err := validateColumnReferences(statement, sourceColumns, qualifiedColumns); err != nil {
+		return QueryResult{}, nil, err
+	}
+	columns, keys := append([]string{}, sourceColumns...), sourceColumns
+	if len(statement.Columns) > 0 {
+		var err error
+		if keys, err = expandSelectColumns(&statement, qualifiedColumns); err != nil {
+			return QueryResult{}, nil, err
+		}
+		columns = append([]string{}, statement.Columns...)
+	}
+
+	rows := func(yield func([]string, error) bool) {
+		skipped, returned := 0, 0
+		for _, key := range recordKeys {
+			if limited(statement) && returned == statement.Limit {
+				return
+			}
+			if err := ctx.Err(); err != nil {
+				yield(nil, fmt.Errorf("query aborted after scanning %d rows: %w", counts.scanned, err))
+				return
+			}
+			rawData, exists, err := read(key)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !exists {
+				continue
+			}
+			counts.scanned++
+
+			row, err := engine.readRow(statement.Database, statement.Table, key, rawData, &counts.corrupt)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if row == nil {
+				continue
+			}
+			normalizeRow(row, *table)
+			decodeBlobs(row, *table)
+			for name, value := range statement.Literals {
+				row[name] = value
+			}
+			if !matchesWhereClause(row, statement.Where, engine.Collation) {
+				continue
+			}
+			if skipped < statement.Offset {
+				skipped++
+				continue
+			}
+			if returned++; maxRows > 0 && returned > maxRows {
+				if !engine.TruncateResults {
+					yield(nil, fmt.Errorf("query stopped after scanning %d rows: %w: more than %d rows", counts.scanned, ErrResultTooLarge, maxRows))
+				}
+				return
+			}
+
+			values := make([]string, len(keys))
+			for i, key := range keys {
+				values[i] = getColumnValue(row, key)
+			}
+			if !yield(values, nil) {
+				return
+			}
+		}
+	}
+
+	return QueryResult{
+		Transaction:     readTransaction,
+		Columns:         columns,
+		ExecutionTimeMs: elapsedMs(startTime),
+	}, rows, nil
+}
+
+// selectTruncated runs statement keeping its first maxRows rows. A statement
+// that can be streamed stops reading at the row after them; others are built
+// whole and then cut.
+func (engine *Engine) selectTruncated(ctx context.Context, statement sql.SelectStatement, maxRows int) (QueryResult, error) {
+	if streamable(statement) {
+		startTime := time.Now()
+		var counts readCounts
+		result, rows, err := engine.streamSelect(ctx, statement, 0, &counts)
+		if err != nil {
+			return QueryResult{}, err
+		}
+		if rows != nil {
+			result.Data = [][]string{}
+			for row, err := range rows {
+				if err != nil {
+					return QueryResult{}, err
+				}
+				if len(result.Data) == maxRows {
+					result.Truncated = true
+					break
+				}
+				result.Data = append(result.Data, row)
+			}
+			result.RecordsRead = len(result.Data)
+			result.CorruptRows = counts.corrupt
+			result.ExecutionOps = counts.scanned
+			result.ExecutionTimeMs = elapsedMs(startTime)
+			return result, nil
+		}
+	}
+
+	result, err := engine.selectRows(ctx, statement, 0)
+	if err == nil && len(result.Data) > maxRows {
+		result.Data = result.Data[:maxRows]
+		result.RecordsRead = maxRows
+		result.Truncated = true
+	}
+	return result, err
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"reflect"
+	"testing"
+)
+
+func TestEngineExecuteRows(t *testing.T) {
+	engine := setupTestEngine(t)
+	if _, err := engine.Execute("CREATE TABLE testdb.events (id INT PRIMARY KEY, kind STRING, note STRING)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	// Inserted out of order, so primary key order differs from insertion order
+	for _, id := range []int{3, 10, 1, 2, 7, 5, 4, 9, 8, 6} {
+		kind := "a"
+		if id%2 == 0 {
+			kind = "b"
+		}
+		if _, err := engine.Execute(fmt.Sprintf("INSERT INTO testdb.events (id, kind) VALUES (%d, '%s')", id, kind)); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	collect := func(rows iter.Seq2[[]string, error]) [][]string {
+		var data [][]string
+		for row, err := range rows {
+			if err != nil {
+				t.Fatalf("Iteration failed: %v", err)
+			}
+			data = append(data, row)
+		}
+		return data
+	}
+
+	// Streamed rows match the built result
+	for _, query := range []string{
+		"SELECT * FROM testdb.events",
+		"SELECT id, note FROM testdb.events WHERE kind = 'b'",
+		"SELECT e.id, 'x' AS tag FROM testdb.events e WHERE e.id > 2 LIMIT 3 OFFSET 2",
+	} {
+		result, rows, err := engine.ExecuteRows(context.Background(), query)
+		if err != nil {
+			t.Fatalf("ExecuteRows(%q) failed: %v", query, err)
+		}
+		if rows == nil {
+			t.Fatalf("Expected %q to stream its rows", query)
+		}
+		built, err := engine.Execute(query)
+		if err != nil {
+			t.Fatalf("Execute(%q) failed: %v", query, err)
+		}
+		expected := built.(QueryResult)
+		if got := result.(QueryResult).Columns; !reflect.DeepEqual(got, expected.Columns) {
+			t.Errorf("%s: expected columns %v, got %v", query, expected.Columns, got)
+		}
+		if got := collect(rows); !reflect.DeepEqual(got, expected.Data) {
+			t.Errorf("%s: expected rows %v, got %v", query, expected.Data, got)
+		}
+	}
+
+	// Sorted, grouped and key lookup reads are built whole
+	for _, query := range []string{
+		"SELECT * FROM testdb.events ORDER BY kind",
+		"SELECT kind, COUNT(*) FROM testdb.events GROUP BY kind",
+		"SELECT * FROM testdb.events WHERE id = 4",
+	} {
+		result, rows, err := engine.ExecuteRows(context.Background(), query)
+		if err != nil {
+			t.Fatalf("ExecuteRows(%q) failed: %v", query, err)
+		}
+		if rows != nil || len(result.(QueryResult).Data) == 0 {
+			t.Errorf("Expected %q to return a built result", query)
+		}
+	}
+
+	// Rows are read at the commit that was HEAD when the query ran
+	_, rows, err := engine.ExecuteRows(context.Background(), "SELECT id FROM testdb.events")
+	if err != nil {
+		t.Fatalf("ExecuteRows failed: %v", err)
+	}
+	if _, err := engine.Execute("DELETE FROM testdb.events WHERE id = 9"); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if got := collect(rows); len(got) != 10 || got[8][0] != "9" {
+		t.Errorf("Expected the 10 rows as of the query, got %v", got)
+	}
+
+	// The row cap stops iteration once exceeded
+	engine.MaxResultRows = 4
+	_, rows, err = engine.ExecuteRows(context.Background(), "SELECT id FROM testdb.events")
+	if err != nil {
+		t.Fatalf("ExecuteRows failed: %v", err)
+	}
+	read := 0
+	for _, err := range rows {
+		if err != nil {
+			if !errors.Is(err, ErrResultTooLarge) {
+				t.Errorf("Expected ErrResultTooLarge, got %v", err)
+			}
+			break
+		}
+		read++
+	}
+	if read != 4 {
+		t.Errorf("Expected 4 rows before the cap, got %d", read)
+	}
+}
@@ -3,14 +3,21 @@ package db
 import (
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
-// SimpleTable provides basic table formatting without external dependencies
+// ellipsis marks a cell cut to the table's maximum column width
+const ellipsis = "…"
+
+// SimpleTable provides basic table formatting without external dependencies.
+// Columns whose values are all numbers are right-aligned.
 type SimpleTable struct {
-	writer  io.Writer
-	headers []string
-	rows    [][]string
+	writer   io.Writer
+	headers  []string
+	rows     [][]string
+	maxWidth int // Longest cell shown in full; 0 for no limit
 }
 
 // NewTable creates a new table writer
@@ -36,14 +43,28 @@ func (t *SimpleTable) Bulk(rows [][]string) {
 	t.rows = append(t.rows, rows...)
 }
 
+// SetMaxWidth cuts cells longer than width characters, ending them with an
+// ellipsis. A width of 0 shows every cell in full.
+func (t *SimpleTable) SetMaxWidth(width int) {
+	t.maxWidth = width
+}
+
 // Render outputs the formatted table
 func (t *SimpleTable) Render() {
 	if len(t.headers) == 0 && len(t.rows) == 0 {
 		return
 	}
 
+	// Cut long cells before measuring, so widths fit the shown values
+	headers := t.truncateRow(t.headers)
+	rows := make([][]string, len(t.rows))
+	for i, row := range t.rows {
+		rows[i] = t.truncateRow(row)
+	}
+
 	// Calculate column widths
-	colWidths := t.calculateWidths()
+	colWidths := calculateWidths(headers, rows)
+	numeric := numericColumns(rows, len(colWidths))
 
 	// Build separator line
 	separator := t.buildSeparator(colWidths)
@@ -52,24 +73,67 @@ func (t *SimpleTable) Render() {
 	fmt.Fprintln(t.writer, separator)
 
 	// Print headers
-	if len(t.headers) > 0 {
-		fmt.Fprintln(t.writer, t.formatRow(t.headers, colWidths))
+	if len(headers) > 0 {
+		fmt.Fprintln(t.writer, formatRow(headers, colWidths, nil))
 		fmt.Fprintln(t.writer, separator)
 	}
 
 	// Print rows
-	for _, row := range t.rows {
-		fmt.Fprintln(t.writer, t.formatRow(row, colWidths))
+	for _, row := range rows {
+		fmt.Fprintln(t.writer, formatRow(row, colWidths, numeric))
 	}
 
 	fmt.Fprintln(t.writer, separator)
 }
 
+// truncateRow returns the row with cells over the maximum width cut short
+func (t *SimpleTable) truncateRow(row []string) []string {
+	if t.maxWidth <= 0 {
+		return row
+	}
+	cut := make([]string, len(row))
+	for i, cell := range row {
+		cut[i] = truncateCell(cell, t.maxWidth)
+	}
+	return cut
+}
+
+// truncateCell cuts a value to width characters, the last being an ellipsis
+func truncateCell(cell string, width int) string {
+	if utf8.RuneCountInString(cell) <= width {
+		return cell
+	}
+	runes := []rune(cell)
+	return string(runes[:width-1]) + ellipsis
+}
+
+// numericColumns reports which columns hold only numbers, ignoring empty
+// cells. A column with no values at all is not numeric.
+func numericColumns(rows [][]string, numCols int) []bool {
+	numeric := make([]bool, numCols)
+	for i := range numeric {
+		seen := false
+		numeric[i] = true
+		for _, row := range rows {
+			if i >= len(row) || row[i] == "" {
+				continue
+			}
+			seen = true
+			if _, err := strconv.ParseFloat(row[i], 64); err != nil {
+				numeric[i] = false
+				break
+			}
+		}
+		numeric[i] = numeric[i] && seen
+	}
+	return numeric
+}
+
 // calculateWidths determines the width needed for each column
-func (t *SimpleTable) calculateWidths() []int {
+func calculateWidths(headers []string, rows [][]string) []int {
 	// Determine number of columns
-	numCols := len(t.headers)
-	for _, row := range t.rows {
+	numCols := len(headers)
+	for _, row := range rows {
 		if len(row) > numCols {
 			numCols = len(row)
 		}
@@ -78,17 +142,17 @@ func (t *SimpleTable) calculateWidths() []int {
 	widths := make([]int, numCols)
 
 	// Check header widths
-	for i, h := range t.headers {
-		if len(h) > widths[i] {
-			widths[i] = len(h)
+	for i, h := range headers {
+		if n := utf8.RuneCountInString(h); n > widths[i] {
+			widths[i] = n
 		}
 	}
 
 	// Check row widths
-	for _, row := range t.rows {
+	for _, row := range rows {
 		for i, cell := range row {
-			if i < numCols && len(cell) > widths[i] {
-				widths[i] = len(cell)
+			if n := utf8.RuneCountInString(cell); i < numCols && n > widths[i] {
+				widths[i] = n
 			}
 		}
 	}
@@ -112,16 +176,21 @@ func (t *SimpleTable) buildSeparator(widths []int) string {
 	return "+" + strings.Join(parts, "+") + "+"
 }
 
-// formatRow formats a single row with proper padding
-func (t *SimpleTable) formatRow(row []string, widths []int) string {
+// formatRow formats a single row with proper padding. Columns marked in
+// rightAlign are padded on the left.
+func formatRow(row []string, widths []int, rightAlign []bool) string {
 	parts := make([]string, len(widths))
 	for i, w := range widths {
 		cell := ""
 		if i < len(row) {
 			cell = row[i]
 		}
-		// Left-align with padding
-		parts[i] = " " + cell + strings.Repeat(" ", w-len(cell)+1)
+		padding := strings.Repeat(" ", w-utf8.RuneCountInString(cell))
+		if i < len(rightAlign) && rightAlign[i] {
+			parts[i] = " " + padding + cell + " "
+		} else {
+			parts[i] = " " + cell + padding + " "
+		}
 	}
 	return "|" + strings.Join(parts, "|") + "|"
 }
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTableMaxWidth(t *testing.T) {
+	var buf bytes.Buffer
+	table := NewTable(&buf)
+	table.SetMaxWidth(10)
+	table.Header([]string{"id", "bio"})
+	table.Bulk([][]string{
+		{"1", "Short"},
+		{"250", "A much longer biography than fits"},
+	})
+	table.Render()
+
+	want := strings.Join([]string{
+		"+-----+------------+",
+		"| id  | bio        |",
+		"+-----+------------+",
+		"|   1 | Short      |",
+		"| 250 | A much lo… |",
+		"+-----+------------+",
+	}, "\n") + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Expected truncated, right-aligned table:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestQueryResultPrintPlain(t *testing.T) {
+	result := QueryResult{
+		Columns:     []string{"id", "name"},
+		Data:        [][]string{{"1", "Alice"}, {"2", "A name longer than the limit"}},
+		RecordsRead: 2,
+	}
+
+	var buf bytes.Buffer
+	Print(&buf, result, DisplayOptions{Plain: true, MaxColumnWidth: 5})
+
+	want := "id\tname\n1\tAlice\n2\tA name longer than the limit\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Expected plain output %q, got %q", want, got)
+	}
+}
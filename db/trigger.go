@@ -0,0 +1,243 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nickyhof/CommitDB/core"
+	"github.com/nickyhof/CommitDB/op"
+	"github.com/nickyhof/CommitDB/ps"
+	"github.com/nickyhof/CommitDB/sql"
+)
+
+// maxTriggerDepth bounds how deeply triggers may fire further triggers, so a
+// trigger that writes to its own table fails instead of recursing forever
+const maxTriggerDepth = 16
+
+// triggerRowName is the name trigger bodies use to reference the affected
+// row: NEW for inserted and updated rows, OLD for deleted ones
+func triggerRowName(event string) string {
+	if event == "DELETE" {
+		return "OLD"
+	}
+	return "NEW"
+}
+
+func (engine *Engine) executeCreateTriggerStatement(statement sql.CreateTriggerStatement) (Result, error) {
+	startTime := time.Now()
+
+	tableOp, err := op.GetTable(statement.Database, statement.Table, engine.Persistence)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := engine.Persistence.GetTrigger(statement.Database, statement.Name); err == nil {
+		return nil, fmt.Errorf("trigger %s.%s already exists", statement.Database, statement.Name)
+	}
+
+	// Check the body against a placeholder row so mistakes surface now
+	// rather than on the first write
+	placeholder := make(map[string]string)
+	for _, col := range tableOp.Table.Columns {
+		placeholder[col.Name] = ""
+	}
+	if _, err := triggerStatements(statement.Body, statement.Event, placeholder); err != nil {
+		return nil, err
+	}
+
+	txn, err := engine.Persistence.CreateTrigger(core.Trigger{
+		Database:  statement.Database,
+		Name:      statement.Name,
+		Table:     statement.Table,
+		Event:     statement.Event,
+		Body:      statement.Body,
+		CreatedAt: time.Now(),
+	}, engine.Identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trigger: %w", err)
+	}
+
+	return CommitResult{
+		Transaction:     txn,
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    1,
+	}, nil
+}
+
+func (engine *Engine) executeDropTriggerStatement(statement sql.DropTriggerStatement) (Result, error) {
+	startTime := time.Now()
+
+	if _, err := engine.Persistence.GetTrigger(statement.Database, statement.Name); err != nil {
+		if statement.IfExists && errors.Is(err, ps.ErrTriggerNotFound) {
+			return CommitResult{
+				ExecutionTimeMs: elapsedMs(startTime),
+				ExecutionOps:    1,
+			}, nil
+		}
+		return nil, err
+	}
+
+	txn, err := engine.Persistence.DropTrigger(statement.Database, statement.Name, engine.Identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to drop trigger: %w", err)
+	}
+
+	return CommitResult{
+		Transaction:     txn,
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    1,
+	}, nil
+}
+
+func (engine *Engine) executeShowTriggersStatement(statement sql.ShowTriggersStatement) (QueryResult, error) {
+	startTime := time.Now()
+
+	triggers, err := engine.Persistence.ListTriggers(statement.Database)
+	if err != nil {
+		return QueryResult{}, err
+	}
+
+	var data [][]string
+	for _, trigger := range triggers {
+		data = append(data, []string{trigger.Name, trigger.Table, trigger.Event, trigger.Body})
+	}
+
+	return QueryResult{
+		Columns:         []string{"name", "table", "event", "body"},
+		Data:            data,
+		RecordsRead:     len(data),
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    len(data),
+	}, nil
+}
+
+// executeTriggeredWrite runs an INSERT, UPDATE or DELETE through run and then
+// the table's AFTER triggers for event, once per affected row. run is given
+// the RETURNING columns to use; the affected rows are read back through
+// RETURNING *. Outside a BEGIN block the statement and the writes of its
+// triggers commit together, and a failing trigger discards them all. Inside
+// one, the error is returned and the block is left for ROLLBACK.
+func (engine *Engine) executeTriggeredWrite(database, table, event string, returning []string, run func(returning []string) (CommitResult, error)) (CommitResult, error) {
+	triggers, err := engine.tableTriggers(database, table, event)
+	if err != nil {
+		return CommitResult{}, err
+	}
+	if len(triggers) == 0 {
+		return run(returning)
+	}
+	if engine.triggerDepth >= maxTriggerDepth {
+		return CommitResult{}, fmt.Errorf("triggers nested more than %d deep", maxTriggerDepth)
+	}
+
+	implicit := engine.transaction == nil
+	if implicit {
+		if _, err := engine.executeBeginStatement(sql.BeginStatement{}); err != nil {
+			return CommitResult{}, err
+		}
+	}
+
+	result, err := engine.runTriggers(database, table, event, triggers, returning, run)
+	if !implicit {
+		return result, err
+	}
+	if err != nil {
+		if _, rollbackErr := engine.executeRollbackStatement(sql.RollbackStatement{}); rollbackErr != nil {
+			return CommitResult{}, errors.Join(err, rollbackErr)
+		}
+		return CommitResult{}, err
+	}
+	commit, err := engine.executeCommitStatement()
+	if err != nil {
+		return CommitResult{}, err
+	}
+	result.Transaction = commit.Transaction
+	return result, nil
+}
+
+// runTriggers runs the triggering statement and then triggers for each row
+// it affected, returning the statement's result with the RETURNING rows the
+// caller asked for.
+func (engine *Engine) runTriggers(database, table, event string, triggers []core.Trigger, returning []string, run func(returning []string) (CommitResult, error)) (CommitResult, error) {
+	tableOp, err := op.GetTable(database, table, engine.Persistence)
+	if err != nil {
+		return CommitResult{}, err
+	}
+	returningColumns, err := resolveReturningColumns(returning, tableOp.Table)
+	if err != nil {
+		return CommitResult{}, err
+	}
+
+	result, err := run([]string{"*"})
+	if err != nil {
+		return CommitResult{}, err
+	}
+
+	rows := result.rows
+	result.Returning = returningResult(returningColumns, rows)
+
+	engine.triggerDepth++
+	defer func() { engine.triggerDepth-- }()
+
+	for _, row := range rows {
+		// A column the stored row lacks is NULL; '' stays an empty string
+		bindRow := make(map[string]string, len(row))
+		for _, col := range tableOp.Table.Columns {
+			value, ok := row[col.Name]
+			if !ok {
+				value = sql.NullValue
+			}
+			bindRow[col.Name] = value
+		}
+
+		for _, trigger := range triggers {
+			statements, err := triggerStatements(trigger.Body, event, bindRow)
+			if err != nil {
+				return CommitResult{}, fmt.Errorf("trigger %s.%s: %w", trigger.Database, trigger.Name, err)
+			}
+			for _, statement := range statements {
+				if _, err := engine.ExecuteStatement(statement); err != nil {
+					return CommitResult{}, fmt.Errorf("trigger %s.%s: %w", trigger.Database, trigger.Name, err)
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// tableTriggers returns the triggers on a table that fire after event
+func (engine *Engine) tableTriggers(database, table, event string) ([]core.Trigger, error) {
+	triggers, err := engine.Persistence.ListTriggers(database)
+	if err != nil {
+		return nil, err
+	}
+	var matching []core.Trigger
+	for _, trigger := range triggers {
+		if trigger.Table == table && trigger.Event == event {
+			matching = append(matching, trigger)
+		}
+	}
+	return matching, nil
+}
+
+// triggerStatements binds a trigger body to an affected row and parses its
+// statements, which must be INSERT, UPDATE or DELETE.
+func triggerStatements(body, event string, row map[string]string) ([]sql.Statement, error) {
+	var statements []sql.Statement
+	for _, query := range sql.SplitStatements(body) {
+		bound, err := sql.BindRowReferences(query, triggerRowName(event), row)
+		if err != nil {
+			return nil, err
+		}
+		statement, err := sql.NewParser(bound).Parse()
+		if err != nil {
+			return nil, err
+		}
+		switch statement.Type() {
+		case sql.InsertStatementType, sql.UpdateStatementType, sql.DeleteStatementType:
+			statements = append(statements, statement)
+		default:
+			return nil, fmt.Errorf("trigger bodies may only contain INSERT, UPDATE and DELETE statements")
+		}
+	}
+	return statements, nil
+}
@@ -0,0 +1,186 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/nickyhof/CommitDB/ps"
+)
+
+func setupTriggerEngine(t *testing.T) *Engine {
+	t.Helper()
+	engine := setupTestEngine(t)
+	for _, query := range []string{
+		"CREATE TABLE testdb.audit (id INT PRIMARY KEY, name STRING, action STRING)",
+		"CREATE TRIGGER log_inserts AFTER INSERT ON testdb.users BEGIN INSERT INTO testdb.audit (id, name, action) VALUES (NEW.id, NEW.name, 'insert') END",
+	} {
+		if _, err := engine.Execute(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	return engine
+}
+
+func queryRows(t *testing.T, engine *Engine, query string) [][]string {
+	t.Helper()
+	result, err := engine.Execute(query)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return result.(QueryResult).Data
+}
+
+func TestEngineTriggerAfterInsert(t *testing.T) {
+	engine := setupTriggerEngine(t)
+	commits := func() int { return len(engine.TransactionsFrom(engine.LatestTransaction().Id)) }
+	before := commits()
+
+	result, err := engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (1, 'Alice', 30), (2, 'Bob', 25) RETURNING name")
+	if err != nil {
+		t.Fatalf("INSERT failed: %v", err)
+	}
+	commit := result.(CommitResult)
+	if commit.RecordsWritten != 2 || commit.Returning == nil || !reflect.DeepEqual(commit.Returning.Data, [][]string{{"Alice"}, {"Bob"}}) {
+		t.Errorf("Expected the statement's own result, got %+v", commit)
+	}
+
+	expected := [][]string{{"1", "Alice", "insert"}, {"2", "Bob", "insert"}}
+	if rows := queryRows(t, engine, "SELECT id, name, action FROM testdb.audit ORDER BY id"); !reflect.DeepEqual(rows, expected) {
+		t.Errorf("Expected audit rows %v, got %v", expected, rows)
+	}
+
+	// The rows and their audit entries land in one commit
+	if got := commits(); got != before+1 || commit.Transaction.Id != engine.LatestTransaction().Id {
+		t.Errorf("Expected a single new commit %s, got %d", commit.Transaction.Id, got-before)
+	}
+}
+
+func TestEngineTriggerUpdateDeleteAndShow(t *testing.T) {
+	engine := setupTriggerEngine(t)
+	for _, query := range []string{
+		"CREATE TRIGGER log_updates AFTER UPDATE ON testdb.users BEGIN UPDATE testdb.audit SET action = 'update' WHERE id = NEW.id END",
+		"CREATE TRIGGER log_deletes AFTER DELETE ON testdb.users BEGIN DELETE FROM testdb.audit WHERE id = OLD.id END",
+		"INSERT INTO testdb.users (id, name, age) VALUES (1, 'Alice', 30)",
+		"INSERT INTO testdb.users (id, name, age) VALUES (2, 'Bob', 25)",
+		"UPDATE testdb.users SET age = 31 WHERE id = 1",
+		"DELETE FROM testdb.users WHERE id = 2",
+	} {
+		if _, err := engine.Execute(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+
+	expected := [][]string{{"1", "update"}}
+	if rows := queryRows(t, engine, "SELECT id, action FROM testdb.audit"); !reflect.DeepEqual(rows, expected) {
+		t.Errorf("Expected audit rows %v, got %v", expected, rows)
+	}
+
+	rows := queryRows(t, engine, "SHOW TRIGGERS IN testdb")
+	if len(rows) != 3 || rows[0][0] != "log_deletes" || rows[0][2] != "DELETE" {
+		t.Errorf("Unexpected SHOW TRIGGERS output %v", rows)
+	}
+
+	if _, err := engine.Execute("DROP TRIGGER testdb.log_inserts"); err != nil {
+		t.Fatalf("DROP TRIGGER failed: %v", err)
+	}
+	engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (3, 'Carol', 40)")
+	if rows := queryRows(t, engine, "SELECT id FROM testdb.audit WHERE id = 3"); len(rows) != 0 {
+		t.Errorf("Expected no audit row after DROP TRIGGER, got %v", rows)
+	}
+	if _, err := engine.Execute("DROP TRIGGER testdb.log_inserts"); !errors.Is(err, ps.ErrTriggerNotFound) {
+		t.Errorf("Expected ErrTriggerNotFound, got %v", err)
+	}
+	if _, err := engine.Execute("DROP TRIGGER IF EXISTS testdb.log_inserts"); err != nil {
+		t.Errorf("DROP TRIGGER IF EXISTS failed: %v", err)
+	}
+
+	// Dropping the table drops its triggers
+	if _, err := engine.Execute("DROP TABLE testdb.users"); err != nil {
+		t.Fatalf("DROP TABLE failed: %v", err)
+	}
+	if rows := queryRows(t, engine, "SHOW TRIGGERS IN testdb"); len(rows) != 0 {
+		t.Errorf("Expected no triggers after DROP TABLE, got %v", rows)
+	}
+}
+
+func TestEngineTriggerNullString(t *testing.T) {
+	engine := setupTriggerEngine(t)
+	if _, err := engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (1, NULL, 30), (2, '', 25)"); err != nil {
+		t.Fatalf("INSERT failed: %v", err)
+	}
+
+	// NEW.name binds NULL for the NULL string and '' for the empty one
+	if rows := queryRows(t, engine, "SELECT id FROM testdb.audit WHERE name IS NULL"); !reflect.DeepEqual(rows, [][]string{{"1"}}) {
+		t.Errorf("Expected row 1 audited with a NULL name, got %v", rows)
+	}
+	if rows := queryRows(t, engine, "SELECT id FROM testdb.audit WHERE name = ''"); !reflect.DeepEqual(rows, [][]string{{"2"}}) {
+		t.Errorf("Expected row 2 audited with an empty name, got %v", rows)
+	}
+}
+
+func TestEngineTriggerFailureRollsBack(t *testing.T) {
+	engine := setupTriggerEngine(t)
+	// A trigger writing a NULL primary key fails after the INSERT is applied
+	if _, err := engine.Execute("CREATE TRIGGER bad AFTER INSERT ON testdb.users BEGIN INSERT INTO testdb.audit (id, name) VALUES (NULL, NEW.name) END"); err != nil {
+		t.Fatalf("CREATE TRIGGER failed: %v", err)
+	}
+
+	if _, err := engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (1, 'Alice', 30)"); err == nil {
+		t.Fatal("Expected the failing trigger to fail the INSERT")
+	}
+	if rows := queryRows(t, engine, "SELECT * FROM testdb.users"); len(rows) != 0 {
+		t.Errorf("Expected the INSERT to be rolled back, got %v", rows)
+	}
+	if rows := queryRows(t, engine, "SELECT * FROM testdb.audit"); len(rows) != 0 {
+		t.Errorf("Expected the audit writes to be rolled back, got %v", rows)
+	}
+}
+
+func TestEngineTriggerValidation(t *testing.T) {
+	engine := setupTriggerEngine(t)
+	for _, query := range []string{
+		"CREATE TRIGGER log_inserts AFTER INSERT ON testdb.users BEGIN DELETE FROM testdb.audit WHERE id = NEW.id END",
+		"CREATE TRIGGER t AFTER INSERT ON testdb.missing BEGIN DELETE FROM testdb.audit WHERE id = NEW.id END",
+		"CREATE TRIGGER t AFTER INSERT ON testdb.users BEGIN DELETE FROM testdb.audit WHERE id = NEW.nope END",
+		"CREATE TRIGGER t AFTER DELETE ON testdb.users BEGIN DELETE FROM testdb.audit WHERE id = NEW.id END",
+		"CREATE TRIGGER t AFTER INSERT ON testdb.users BEGIN SELECT * FROM testdb.audit END",
+	} {
+		if _, err := engine.Execute(query); err == nil {
+			t.Errorf("Expected error for %q", query)
+		}
+	}
+
+	// A trigger that writes to its own table stops at the nesting limit
+	if _, err := engine.Execute("CREATE TRIGGER loop AFTER UPDATE ON testdb.users BEGIN UPDATE testdb.users SET age = '1' WHERE id = NEW.id END"); err != nil {
+		t.Fatalf("CREATE TRIGGER failed: %v", err)
+	}
+	engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (1, 'Alice', 30)")
+	if _, err := engine.Execute("UPDATE testdb.users SET age = 31 WHERE id = 1"); err == nil {
+		t.Error("Expected recursive trigger to fail")
+	}
+	if rows := queryRows(t, engine, "SELECT age FROM testdb.users WHERE id = 1"); !reflect.DeepEqual(rows, [][]string{{"30"}}) {
+		t.Errorf("Expected the recursive update to be rolled back, got %v", rows)
+	}
+}
+
+func TestEngineTriggerRefreshesViewsOnce(t *testing.T) {
+	engine := setupTriggerEngine(t)
+	if _, err := engine.Execute("CREATE MATERIALIZED VIEW testdb.logged WITH AUTO REFRESH AS SELECT u.name, a.action FROM testdb.users u JOIN testdb.audit a ON u.id = a.id"); err != nil {
+		t.Fatalf("Failed to create view: %v", err)
+	}
+	commits := func() int { return len(engine.TransactionsFrom(engine.LatestTransaction().Id)) }
+	before := commits()
+
+	if _, err := engine.Execute("INSERT INTO testdb.users (id, name, age) VALUES (1, 'Alice', 30)"); err != nil {
+		t.Fatalf("INSERT failed: %v", err)
+	}
+	if rows := queryRows(t, engine, "SELECT * FROM testdb.logged"); !reflect.DeepEqual(rows, [][]string{{"Alice", "insert"}}) {
+		t.Errorf("Expected the refreshed view, got %v", rows)
+	}
+
+	// The view reads both written tables but is refreshed once, in one commit
+	if got := commits(); got != before+2 {
+		t.Errorf("Expected the write and one refresh commit, got %d commits", got-before)
+	}
+}
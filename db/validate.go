@@ -0,0 +1,343 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/nickyhof/CommitDB/core"
+	"github.com/nickyhof/CommitDB/op"
+	"github.com/nickyhof/CommitDB/ps"
+	"github.com/nickyhof/CommitDB/sql"
+)
+
+// Validate parses query and checks it against the current schema without
+// executing it. The tables and columns a SELECT, INSERT, UPDATE or DELETE
+// references must exist, and INSERT and UPDATE values must fit their
+// columns' types. Other statements are only parsed.
+func (engine *Engine) Validate(query string) error {
+	parser := sql.NewParser(query)
+	statement, err := parser.Parse()
+	if err != nil {
+		return err
+	}
+	return engine.ValidateStatement(statement)
+}
+
+// ValidateStatement is Validate for an already parsed or built statement.
+func (engine *Engine) ValidateStatement(statement sql.Statement) error {
+	switch stmt := statement.(type) {
+	case sql.SelectStatement:
+		return engine.validateSelect(stmt)
+	case sql.InsertStatement:
+		table, err := engine.validationTable(stmt.Database, stmt.Table)
+		if err != nil {
+			return err
+		}
+		for _, row := range stmt.ValueRows {
+			if len(row) != len(stmt.Columns) {
+				return fmt.Errorf("value count does not match column count")
+			}
+			for i, name := range stmt.Columns {
+				if err := checkColumnValue(table, name, row[i]); err != nil {
+					return err
+				}
+			}
+		}
+		return checkTableColumns(table, stmt.Returning)
+	case sql.UpdateStatement:
+		table, err := engine.validationTable(stmt.Database, stmt.Table)
+		if err != nil {
+			return err
+		}
+		for _, update := range stmt.Updates {
+			if err := checkColumnValue(table, update.Column, update.Value); err != nil {
+				return err
+			}
+		}
+		if err := checkTableColumns(table, append(whereColumns(stmt.Where), stmt.Returning...)); err != nil {
+			return err
+		}
+		return checkWhereValues(stmt.Where, tableColumnTypes(table))
+	case sql.DeleteStatement:
+		table, err := engine.validationTable(stmt.Database, stmt.Table)
+		if err != nil {
+			return err
+		}
+		if err := checkTableColumns(table, append(whereColumns(stmt.Where), stmt.Returning...)); err != nil {
+			return err
+		}
+		return checkWhereValues(stmt.Where, tableColumnTypes(table))
+	}
+	return nil
+}
+
+// validationTable returns the schema of a table a statement writes to
+func (engine *Engine) validationTable(database, table string) (core.Table, error) {
+	tableOp, err := op.GetTable(database, table, engine.Persistence)
+	if err != nil {
+		return core.Table{}, err
+	}
+	return tableOp.Table, nil
+}
+
+// validateSelect checks a SELECT's source, joins and column references the
+// way executing it would, without reading rows. The outer columns of a
+// query over a view are only checked when the view records its columns;
+// otherwise the view's own query is validated. Time-travel queries read an
+// older schema and are only checked for their source.
+func (engine *Engine) validateSelect(statement sql.SelectStatement) error {
+	if err := validateAggregation(statement); err != nil {
+		return err
+	}
+
+	shares := make(queryShares)
+	defer shares.close()
+
+	persistence := engine.Persistence
+	if statement.Share != "" {
+		sharePersistence, err := shares.open(engine.Persistence, statement.Share)
+		if err != nil {
+			return fmt.Errorf("failed to access share '%s': %w", statement.Share, err)
+		}
+		persistence = sharePersistence
+	}
+
+	var sourceColumns []string
+	types := make(map[string]core.ColumnType) // columns whose type is known
+	checkColumns := statement.AsOf == ""
+	if view, err := persistence.GetView(statement.Database, statement.Table); err == nil {
+		for _, col := range view.Columns {
+			sourceColumns = append(sourceColumns, col.Name)
+		}
+		if len(sourceColumns) == 0 {
+			checkColumns = false
+			viewStatement, err := sql.NewParser(view.Query).Parse()
+			if err != nil {
+				return fmt.Errorf("failed to parse view query: %w", err)
+			}
+			if err := engine.ValidateStatement(viewStatement); err != nil {
+				return fmt.Errorf("view %s.%s: %w", view.Database, view.Name, err)
+			}
+		}
+	} else {
+		tableOp, err := op.GetTable(statement.Database, statement.Table, persistence)
+		if err != nil {
+			return err
+		}
+		sourceColumns = tableColumnNames(tableOp.Table)
+		addColumnTypes(types, tableOp.Table, tableQualifiers(statement.Database, statement.Table, statement.TableAlias))
+	}
+
+	availableColumns := slices.Clone(sourceColumns)
+	qualifiedColumns := make(map[string][]string)
+	addTableQualifiers(qualifiedColumns, statement.Database, statement.Table, statement.TableAlias, sourceColumns)
+
+	for _, join := range statement.Joins {
+		joinPersistence := engine.Persistence
+		if join.Share != "" {
+			sharePersistence, err := shares.open(engine.Persistence, join.Share)
+			if err != nil {
+				return fmt.Errorf("failed to open share '%s' for join: %w", join.Share, err)
+			}
+			joinPersistence = sharePersistence
+		}
+		joinTableOp, err := op.GetTable(join.Database, join.Table, joinPersistence)
+		if errors.Is(err, ps.ErrTableNotFound) {
+			return fmt.Errorf("join %w: %s.%s", ps.ErrTableNotFound, join.Database, join.Table)
+		} else if err != nil {
+			return err
+		}
+		joinColumns := tableColumnNames(joinTableOp.Table)
+		addColumnTypes(types, joinTableOp.Table, tableQualifiers(join.Database, join.Table, join.TableAlias))
+		availableColumns = append(availableColumns, joinColumns...)
+		addTableQualifiers(qualifiedColumns, join.Database, join.Table, join.TableAlias, joinColumns)
+	}
+
+	if !checkColumns {
+		return nil
+	}
+	if err := validateColumnReferences(statement, availableColumns, qualifiedColumns); err != nil {
+		return err
+	}
+	return checkWhereValues(statement.Where, types)
+}
+
+// validateAggregation checks how a SELECT mixes aggregates with other
+// expressions. An aggregate query returns one row per GROUP BY group (one row
+// without GROUP BY), so every column and function call it selects must be a
+// GROUP BY expression, and DISTINCT ON, which picks rows rather than groups,
+// cannot apply to it.
+func validateAggregation(statement sql.SelectStatement) error {
+	if len(statement.Aggregates) == 0 && len(statement.HavingAggregates) == 0 {
+		return nil
+	}
+	if len(statement.DistinctOn) > 0 {
+		return errors.New("DISTINCT ON cannot be combined with aggregates")
+	}
+
+	grouped := func(name string) bool {
+		return slices.ContainsFunc(statement.GroupBy, func(group string) bool {
+			return group == name || unqualifiedName(group) == unqualifiedName(name)
+		})
+	}
+	for _, column := range statement.Columns {
+		if _, literal := statement.Literals[column]; !literal && !grouped(column) {
+			return fmt.Errorf("column %s must appear in GROUP BY or be used in an aggregate", column)
+		}
+	}
+	for _, fn := range statement.Functions {
+		if !grouped(fn.Name()) && (fn.Alias == "" || !grouped(fn.Alias)) {
+			return fmt.Errorf("%s must appear in GROUP BY or be used in an aggregate", fn.Name())
+		}
+	}
+	return nil
+}
+
+// unqualifiedName returns the column part of a qualified name such as u.name
+func unqualifiedName(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// tableColumnNames returns the names of a table's columns in order
+func tableColumnNames(table core.Table) []string {
+	names := make([]string, len(table.Columns))
+	for i, col := range table.Columns {
+		names[i] = col.Name
+	}
+	return names
+}
+
+// whereColumns returns the columns a WHERE clause compares
+func whereColumns(where sql.WhereClause) []string {
+	var columns []string
+	for _, cond := range where.Conditions {
+		columns = append(columns, cond.Left)
+	}
+	return columns
+}
+
+// tableColumnTypes returns the type of each column of table by name
+func tableColumnTypes(table core.Table) map[string]core.ColumnType {
+	types := make(map[string]core.ColumnType, len(table.Columns))
+	addColumnTypes(types, table, nil)
+	return types
+}
+
+// addColumnTypes records the type of each column of table in types, by its
+// name and by each qualifier.column. A name already recorded, such as a
+// column of an earlier table in a join, keeps its type.
+func addColumnTypes(types map[string]core.ColumnType, table core.Table, qualifiers []string) {
+	for _, col := range table.Columns {
+		for _, qualifier := range qualifiers {
+			types[qualifier+"."+col.Name] = col.Type
+		}
+		if _, seen := types[col.Name]; !seen {
+			types[col.Name] = col.Type
+		}
+	}
+}
+
+// checkWhereValues reports the first value a WHERE clause compares with a
+// column that the column's type cannot hold, such as 'abc' for an INT
+// column, which would otherwise quietly match nothing. Columns missing from
+// types are not checked, nor are LIKE patterns and JSON and text columns.
+func checkWhereValues(where sql.WhereClause, types map[string]core.ColumnType) error {
+	for _, cond := range where.Conditions {
+		colType, ok := types[cond.Left]
+		if !ok {
+			continue
+		}
+		var values []string
+		switch cond.Operator {
+		case sql.EqualsOperator, sql.NotEqualsOperator, sql.LessThanOperator, sql.GreaterThanOperator,
+			sql.LessThanOrEqualOperator, sql.GreaterThanOrEqualOperator:
+			values = []string{cond.Right}
+		case sql.InOperator:
+			values = cond.InValues
+		}
+		for _, value := range values {
+			if !comparableValue(colType, value) {
+				return fmt.Errorf("value '%s' cannot be compared with %s column %s", value, columnTypeName(colType), cond.Left)
+			}
+		}
+	}
+	return nil
+}
+
+// comparableValue reports whether value can be compared with a column of
+// colType. INT columns may be compared with any number.
+func comparableValue(colType core.ColumnType, value string) bool {
+	if value == sql.NullValue || value == sql.NowValue {
+		return true
+	}
+	switch colType {
+	case core.IntType, core.FloatType:
+		_, err := strconv.ParseFloat(value, 64)
+		return err == nil
+	case core.BoolType:
+		_, err := strconv.ParseBool(value)
+		return err == nil
+	case core.DateType:
+		_, err := parseDateTime(value)
+		return err == nil || isValidDateFormat(value)
+	case core.TimestampType:
+		_, err := parseDateTime(value)
+		return err == nil
+	default:
+		return true
+	}
+}
+
+// checkTableColumns reports the first name that is not a column of table;
+// "*" names every column.
+func checkTableColumns(table core.Table, names []string) error {
+	for _, name := range names {
+		if name != "*" && !slices.Contains(tableColumnNames(table), name) {
+			return fmt.Errorf("unknown column %s", name)
+		}
+	}
+	return nil
+}
+
+// checkColumnValue reports whether value can be written to the named column
+// of table. NULL and NOW() fit any column.
+func checkColumnValue(table core.Table, name, value string) error {
+	if err := checkTableColumns(table, []string{name}); err != nil {
+		return err
+	}
+	if value == sql.NullValue || value == sql.NowValue {
+		return nil
+	}
+
+	colType := columnType(table, name)
+	var valid bool
+	switch colType {
+	case core.IntType:
+		_, err := strconv.ParseInt(value, 10, 64)
+		valid = err == nil
+	case core.FloatType:
+		_, err := strconv.ParseFloat(value, 64)
+		valid = err == nil
+	case core.BoolType:
+		_, err := strconv.ParseBool(value)
+		valid = err == nil
+	case core.DateType:
+		_, err := parseDateTime(value)
+		valid = err == nil || isValidDateFormat(value)
+	case core.TimestampType:
+		_, err := parseDateTime(value)
+		valid = err == nil
+	case core.JsonType:
+		valid = json.Valid([]byte(value))
+	default:
+		valid = true
+	}
+	if !valid {
+		return constraintErrorf(name, "value '%s' is not a valid %s for column %s", value, columnTypeName(colType), name)
+	}
+	return nil
+}
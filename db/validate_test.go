@@ -0,0 +1,81 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nickyhof/CommitDB/ps"
+)
+
+func TestEngineValidate(t *testing.T) {
+	engine := setupTestEngine(t)
+	insertTestData(t, engine)
+	_, _ = engine.Execute("CREATE TABLE testdb.orders (order_id INT PRIMARY KEY, user_id INT, placed DATE, meta JSON)")
+	_, _ = engine.Execute("CREATE VIEW testdb.adults AS SELECT name FROM testdb.users WHERE age > 18")
+	before := engine.LatestTransaction().Id
+
+	valid := []string{
+		"SELECT name, age FROM testdb.users WHERE age > 25 ORDER BY name",
+		"SELECT u.*, o.placed FROM testdb.users u JOIN testdb.orders o ON u.id = o.user_id",
+		"SELECT age, COUNT(*) AS total FROM testdb.users GROUP BY age HAVING total > 1",
+		"SELECT * FROM testdb.adults",
+		"SELECT UPPER(u.name), CONCAT(name, 'x') FROM testdb.users u WHERE u.age > 1",
+		"SELECT * FROM testdb.users WHERE age > 25 AND name = 'Alice' AND id IN (1, 2)",
+		"SELECT * FROM testdb.orders WHERE placed >= '2026-01-01' AND meta = 'x'",
+		"INSERT INTO testdb.orders (order_id, user_id, placed, meta) VALUES (1, 2, '2026-01-02', '{\"a\": 1}')",
+		"INSERT INTO testdb.orders (order_id, placed) VALUES (2, NOW())",
+		"UPDATE testdb.users SET age = 31 WHERE name = 'Alice'",
+		"DELETE FROM testdb.users WHERE id = 1 RETURNING name",
+		"CREATE TABLE testdb.other (id INT PRIMARY KEY)",
+	}
+	for _, query := range valid {
+		if err := engine.Validate(query); err != nil {
+			t.Errorf("%s: unexpected error %v", query, err)
+		}
+	}
+
+	invalid := []string{
+		"SELEC * FROM testdb.users",
+		"SELECT * FROM testdb.missing",
+		"SELECT nmae FROM testdb.users",
+		"SELECT * FROM testdb.users u JOIN testdb.missing m ON u.id = m.user_id",
+		"SELECT * FROM testdb.users WHERE agee > 1",
+		"SELECT UPPER(nmae) FROM testdb.users",
+		"SELECT x.name FROM testdb.users u",
+		"SELECT u.placed FROM testdb.users u JOIN testdb.orders o ON u.id = o.user_id",
+		"INSERT INTO testdb.missing (id) VALUES (1)",
+		"INSERT INTO testdb.orders (order_id, nope) VALUES (1, 2)",
+		"INSERT INTO testdb.orders (order_id, user_id) VALUES (1, 'two')",
+		"INSERT INTO testdb.orders (order_id, placed) VALUES (1, 'yesterday')",
+		"INSERT INTO testdb.orders (order_id, meta) VALUES (1, '{bad')",
+		"UPDATE testdb.users SET agee = 1 WHERE id = 1",
+		"UPDATE testdb.users SET age = 1 WHERE nmae = 'x'",
+		"DELETE FROM testdb.users WHERE idd = 1",
+		"SELECT * FROM testdb.users WHERE age = 'abc'",
+		"SELECT * FROM testdb.users u WHERE u.id IN (1, 'x')",
+		"SELECT * FROM testdb.users u JOIN testdb.orders o ON u.id = o.user_id WHERE o.placed > 'soon'",
+		"UPDATE testdb.users SET age = 1 WHERE id = 'one'",
+		"DELETE FROM testdb.users WHERE age <= 'old'",
+	}
+	for _, query := range invalid {
+		if err := engine.Validate(query); err == nil {
+			t.Errorf("%s: expected error", query)
+		}
+	}
+
+	if err := engine.Validate("SELECT * FROM testdb.missing"); !errors.Is(err, ps.ErrTableNotFound) {
+		t.Errorf("Expected ErrTableNotFound, got %v", err)
+	}
+	var constraintErr *ConstraintError
+	if err := engine.Validate("INSERT INTO testdb.orders (order_id, user_id) VALUES (1, 'two')"); !errors.As(err, &constraintErr) || constraintErr.Column != "user_id" {
+		t.Errorf("Expected ConstraintError on user_id, got %v", err)
+	}
+
+	// Nothing was executed
+	if engine.LatestTransaction().Id != before {
+		t.Error("Expected Validate not to commit anything")
+	}
+	if result, _ := engine.Execute("SELECT * FROM testdb.orders"); len(result.(QueryResult).Data) != 0 {
+		t.Error("Expected Validate not to insert rows")
+	}
+}
@@ -0,0 +1,206 @@
+package db
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nickyhof/CommitDB/sql"
+)
+
+// sessionVariable is an engine setting that SET and SHOW VARIABLES expose by
+// name. Each engine is one session, so settings apply to its connection only.
+type sessionVariable struct {
+	get func(engine *Engine) string
+	set func(engine *Engine, value string) error
+}
+
+// sessionVariables are the settings SET accepts, by lower-cased name
+var sessionVariables = map[string]sessionVariable{
+	// case_sensitive selects the Collation: TRUE compares =, IN and LIKE
+	// exactly, FALSE ignores case for all three, DEFAULT compares = and IN
+	// exactly and LIKE case-insensitively
+	"case_sensitive": {
+		get: func(engine *Engine) string {
+			switch engine.Collation {
+			case CollationCaseSensitive:
+				return "TRUE"
+			case CollationCaseInsensitive:
+				return "FALSE"
+			default:
+				return "DEFAULT"
+			}
+		},
+		set: func(engine *Engine, value string) error {
+			if strings.EqualFold(value, "DEFAULT") {
+				engine.Collation = CollationDefault
+				return nil
+			}
+			caseSensitive, err := parseBoolSetting("case_sensitive", value)
+			if err != nil {
+				return err
+			}
+			engine.Collation = CollationCaseInsensitive
+			if caseSensitive {
+				engine.Collation = CollationCaseSensitive
+			}
+			return nil
+		},
+	},
+	// max_in_values sets MaxInValues; 0 removes the limit
+	"max_in_values": {
+		get: func(engine *Engine) string {
+			switch {
+			case engine.MaxInValues == 0:
+				return strconv.Itoa(sql.DefaultMaxInValues)
+			case engine.MaxInValues < 0:
+				return "0"
+			}
+			return strconv.Itoa(engine.MaxInValues)
+		},
+		set: func(engine *Engine, value string) error {
+			if strings.EqualFold(value, "DEFAULT") {
+				engine.MaxInValues = 0
+				return nil
+			}
+			limit, err := parseLimitSetting("max_in_values", value)
+			if err != nil {
+				return err
+			}
+			engine.MaxInValues = limit
+			if limit == 0 {
+				engine.MaxInValues = -1
+			}
+			return nil
+		},
+	},
+	// max_result_rows sets MaxResultRows, at most MaxResultRowsCap; 0 falls
+	// back to the cap, or removes the limit when there is none
+	"max_result_rows": {
+		get: func(engine *Engine) string { return strconv.Itoa(engine.maxResultRows()) },
+		set: func(engine *Engine, value string) error {
+			limit := 0
+			if !strings.EqualFold(value, "DEFAULT") {
+				var err error
+				if limit, err = parseLimitSetting("max_result_rows", value); err != nil {
+					return err
+				}
+			}
+			if limit == 0 {
+				limit = engine.MaxResultRowsCap
+			}
+			if engine.MaxResultRowsCap > 0 && limit > engine.MaxResultRowsCap {
+				return fmt.Errorf("max_result_rows cannot be raised above the limit of %d", engine.MaxResultRowsCap)
+			}
+			engine.MaxResultRows = limit
+			return nil
+		},
+	},
+	// report_keys sets ReportKeys
+	"report_keys": {
+		get: func(engine *Engine) string { return formatBoolSetting(engine.ReportKeys) },
+		set: func(engine *Engine, value string) error {
+			if strings.EqualFold(value, "DEFAULT") {
+				engine.ReportKeys = false
+				return nil
+			}
+			report, err := parseBoolSetting("report_keys", value)
+			if err != nil {
+				return err
+			}
+			engine.ReportKeys = report
+			return nil
+		},
+	},
+	// strict_reads sets StrictReads
+	"strict_reads": {
+		get: func(engine *Engine) string { return formatBoolSetting(engine.StrictReads) },
+		set: func(engine *Engine, value string) error {
+			if strings.EqualFold(value, "DEFAULT") {
+				engine.StrictReads = false
+				return nil
+			}
+			strict, err := parseBoolSetting("strict_reads", value)
+			if err != nil {
+				return err
+			}
+			engine.StrictReads = strict
+			return nil
+		},
+	},
+}
+
+// parseLimitSetting parses a non-negative row or value count for a setting
+func parseLimitSetting(name, value string) (int, error) {
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		return 0, fmt.Errorf("invalid value '%s' for %s: expected a non-negative integer", value, name)
+	}
+	return limit, nil
+}
+
+// parseBoolSetting parses TRUE/FALSE, ON/OFF or 1/0 for a boolean setting
+func parseBoolSetting(name, value string) (bool, error) {
+	switch strings.ToUpper(value) {
+	case "TRUE", "ON", "1":
+		return true, nil
+	case "FALSE", "OFF", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid value '%s' for %s: expected TRUE or FALSE", value, name)
+}
+
+func formatBoolSetting(value bool) string {
+	if value {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// executeSetVariableStatement changes a session setting
+func (engine *Engine) executeSetVariableStatement(statement sql.SetVariableStatement) (CommitResult, error) {
+	startTime := time.Now()
+
+	variable, ok := sessionVariables[statement.Name]
+	if !ok {
+		return CommitResult{}, fmt.Errorf("unknown setting %s", statement.Name)
+	}
+	if err := variable.set(engine, statement.Value); err != nil {
+		return CommitResult{}, err
+	}
+
+	return CommitResult{
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    1,
+	}, nil
+}
+
+// executeShowVariablesStatement lists the session settings and their values
+func (engine *Engine) executeShowVariablesStatement() (QueryResult, error) {
+	startTime := time.Now()
+
+	var data [][]string
+	for _, name := range slices.Sorted(maps.Keys(sessionVariables)) {
+		data = append(data, []string{name, sessionVariables[name].get(engine)})
+	}
+
+	return QueryResult{
+		Columns:         []string{"name", "value"},
+		Data:            data,
+		RecordsRead:     len(data),
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    len(data),
+	}, nil
+}
+
+// maxResultRows is the row cap a SELECT runs with: the lower of MaxResultRows
+// and MaxResultRowsCap, where 0 means no limit.
+func (engine *Engine) maxResultRows() int {
+	if engine.MaxResultRowsCap > 0 && (engine.MaxResultRows == 0 || engine.MaxResultRows > engine.MaxResultRowsCap) {
+		return engine.MaxResultRowsCap
+	}
+	return engine.MaxResultRows
+}
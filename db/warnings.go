@@ -0,0 +1,61 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nickyhof/CommitDB/sql"
+)
+
+// warn records a non-fatal issue with the statement being executed
+func (engine *Engine) warn(format string, args ...any) {
+	engine.warnings = append(engine.warnings, fmt.Sprintf(format, args...))
+}
+
+// Warnings returns the non-fatal issues raised by the last statement, such
+// as skipped corrupt rows or COPY rows replaced by a later row.
+func (engine *Engine) Warnings() []string {
+	return engine.warnings
+}
+
+// warnNonNumeric warns about values SUM, AVG, MIN and MAX ignore because
+// they are not numbers. NULLs are ignored without a warning.
+func (engine *Engine) warnNonNumeric(rows []map[string]string, aggregates []sql.AggregateExpr) {
+	for _, agg := range aggregates {
+		if agg.Function == "COUNT" {
+			continue
+		}
+		ignored := 0
+		for _, row := range rows {
+			value, ok := row[agg.Column]
+			if !ok || value == "" {
+				continue
+			}
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				ignored++
+			}
+		}
+		if ignored > 0 {
+			engine.warn("%s ignored %d non-numeric values", agg.Name(), ignored)
+		}
+	}
+}
+
+// executeShowWarningsStatement lists the warnings of the previous statement
+func (engine *Engine) executeShowWarningsStatement() (QueryResult, error) {
+	startTime := time.Now()
+
+	data := make([][]string, len(engine.warnings))
+	for i, message := range engine.warnings {
+		data[i] = []string{"Warning", message}
+	}
+
+	return QueryResult{
+		Columns:         []string{"level", "message"},
+		Data:            data,
+		RecordsRead:     len(data),
+		ExecutionTimeMs: elapsedMs(startTime),
+		ExecutionOps:    len(data),
+	}, nil
+}
@@ -26,6 +26,25 @@ func CreateTable(table core.Table, persistence *ps.Persistence, identity core.Id
 	}, nil
 }
 
+// CreateTableWithRecords creates a table together with its initial records
+// in a single commit.
+func CreateTableWithRecords(table core.Table, records map[string][]byte, persistence *ps.Persistence, identity core.Identity) (*ps.Transaction, *TableOp, error) {
+	for key := range records {
+		if key == "" {
+			return nil, nil, errors.New("record key cannot be empty")
+		}
+	}
+	txn, err := persistence.CreateTableWithRecords(table, records, identity)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &txn, &TableOp{
+		Table:       table,
+		Persistence: persistence,
+	}, nil
+}
+
 func GetTable(database string, tableName string, persistence *ps.Persistence) (*TableOp, error) {
 	table, err := persistence.GetTable(database, tableName)
 
@@ -84,6 +103,11 @@ func (op *TableOp) Put(key string, value []byte, identity core.Identity) (txn ps
 }
 
 func (op *TableOp) PutAll(records map[string][]byte, identity core.Identity) (txn ps.Transaction, err error) {
+	for key := range records {
+		if key == "" {
+			return ps.Transaction{}, errors.New("record key cannot be empty")
+		}
+	}
 	return op.Persistence.SaveRecord(op.Table.Database, op.Table.Name, records, identity)
 }
 
@@ -91,6 +115,10 @@ func (op *TableOp) Delete(key string, identity core.Identity) (txn ps.Transactio
 	return op.Persistence.DeleteRecord(op.Table.Database, op.Table.Name, key, identity)
 }
 
+func (op *TableOp) DeleteAll(keys []string, identity core.Identity) (txn ps.Transaction, err error) {
+	return op.Persistence.DeleteRecords(op.Table.Database, op.Table.Name, keys, identity)
+}
+
 func (op *TableOp) Count() int {
 	return len(op.Keys())
 }
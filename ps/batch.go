@@ -2,6 +2,8 @@ package ps
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 
 	"github.com/nickyhof/CommitDB/core"
 )
@@ -13,6 +15,7 @@ type Operation struct {
 	Table    string
 	Key      string
 	Data     []byte
+	Path     string // Repository path of the file; overrides the record path of Database, Table and Key
 }
 
 type OperationType int
@@ -26,9 +29,16 @@ const (
 type TransactionBuilder struct {
 	persistence *Persistence
 	operations  []Operation
+	savepoints  []savepoint
 	started     bool
 }
 
+// savepoint marks how many operations were batched when it was set
+type savepoint struct {
+	name       string
+	operations int
+}
+
 // BeginTransaction creates a new transaction builder for batching operations
 func (persistence *Persistence) BeginTransaction() (*TransactionBuilder, error) {
 	if err := persistence.ensureInitialized(); err != nil {
@@ -86,24 +96,69 @@ func (tb *TransactionBuilder) Commit(identity core.Identity) (Transaction, error
 		return Transaction{}, fmt.Errorf("no operations to commit")
 	}
 
+	defer tb.persistence.dispatchCommitEvents()
+	if _, err := tb.persistence.Flush(); err != nil {
+		return Transaction{}, err
+	}
+
+	message := fmt.Sprintf("Batch transaction: %d operation(s)", len(tb.operations))
+	txn, err := tb.persistence.commitOperations(tb.operations, identity, message)
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	// Mark transaction as completed
+	tb.started = false
+	tb.operations = nil
+	tb.savepoints = nil
+
+	return txn, nil
+}
+
+// commitOperations applies operations to HEAD in a single commit
+func (persistence *Persistence) commitOperations(operations []Operation, identity core.Identity, message string) (Transaction, error) {
+	return persistence.commitMerged(operations, nil, identity, message)
+}
+
+// commitMerged is commitOperations that also applies buffered index changes
+// to the indexes as they are at HEAD, in the same commit
+func (persistence *Persistence) commitMerged(operations []Operation, indexes map[string]*indexDelta, identity core.Identity, message string) (Transaction, error) {
 	// Acquire write lock for the entire commit operation
-	tb.persistence.mu.Lock()
-	defer tb.persistence.mu.Unlock()
+	persistence.mu.Lock()
+	defer persistence.mu.Unlock()
 
 	// Get current tree
-	currentTree, err := tb.persistence.getCurrentTree()
+	currentTree, err := persistence.getCurrentTree()
 	if err != nil {
 		return Transaction{}, err
 	}
 
-	// Build list of changes
-	changes := make([]TreeChange, 0, len(tb.operations))
-	for _, op := range tb.operations {
-		opPath := fmt.Sprintf("%s/%s/%s", op.Database, op.Table, op.Key)
+	for _, indexPath := range slices.Sorted(maps.Keys(indexes)) {
+		data, err := persistence.mergeIndex(currentTree, indexPath, indexes[indexPath])
+		if err != nil {
+			return Transaction{}, err
+		}
+		operations = append(operations, Operation{Type: WriteOp, Path: indexPath, Data: data})
+	}
+
+	// Build list of changes, resolving each table's record layout once
+	fanouts := make(map[string]int)
+	changes := make([]TreeChange, 0, len(operations))
+	for _, op := range operations {
+		opPath := op.Path
+		if opPath == "" {
+			tableKey := op.Database + "/" + op.Table
+			fanout, ok := fanouts[tableKey]
+			if !ok {
+				fanout = persistence.tableFanoutAt(currentTree, op.Database, op.Table)
+				fanouts[tableKey] = fanout
+			}
+			opPath = recordPath(op.Database, op.Table, op.Key, fanout)
+		}
 
 		switch op.Type {
 		case WriteOp:
-			blobHash, err := tb.persistence.createBlob(op.Data)
+			blobHash, err := persistence.createBlob(op.Data)
 			if err != nil {
 				return Transaction{}, fmt.Errorf("failed to create blob for %s: %w", opPath, err)
 			}
@@ -121,27 +176,23 @@ func (tb *TransactionBuilder) Commit(identity core.Identity) (Transaction, error
 	}
 
 	// Apply all changes in single tree operation
-	newTree, err := tb.persistence.batchUpdateTree(currentTree, changes)
+	newTree, err := persistence.batchUpdateTree(currentTree, changes)
 	if err != nil {
 		return Transaction{}, fmt.Errorf("failed to update tree: %w", err)
 	}
 
 	// Create single commit for all operations
-	message := fmt.Sprintf("Batch transaction: %d operation(s)", len(tb.operations))
-	txn, err := tb.persistence.createCommitDirect(newTree, identity, message)
+	txn, err := persistence.createCommitDirect(newTree, identity, message)
 	if err != nil {
 		return Transaction{}, fmt.Errorf("failed to commit: %w", err)
 	}
+	persistence.queueCommitEvent(txn, operations)
 
 	// Sync worktree
-	if err := tb.persistence.syncWorktree(); err != nil {
+	if err := persistence.syncWorktree(); err != nil {
 		return Transaction{}, fmt.Errorf("failed to sync worktree: %w", err)
 	}
 
-	// Mark transaction as completed
-	tb.started = false
-	tb.operations = nil
-
 	return txn, nil
 }
 
@@ -149,6 +200,57 @@ func (tb *TransactionBuilder) Commit(identity core.Identity) (Transaction, error
 func (tb *TransactionBuilder) Rollback() {
 	tb.started = false
 	tb.operations = nil
+	tb.savepoints = nil
+}
+
+// Savepoint marks the current point in the batch so later operations can be
+// undone with RollbackToSavepoint. Reusing a name moves the savepoint.
+func (tb *TransactionBuilder) Savepoint(name string) error {
+	if !tb.started {
+		return fmt.Errorf("transaction not started")
+	}
+
+	tb.savepoints = slices.DeleteFunc(tb.savepoints, func(sp savepoint) bool { return sp.name == name })
+	tb.savepoints = append(tb.savepoints, savepoint{name: name, operations: len(tb.operations)})
+	return nil
+}
+
+// RollbackToSavepoint discards the operations added after the named
+// savepoint, along with savepoints set after it. The savepoint itself stays.
+func (tb *TransactionBuilder) RollbackToSavepoint(name string) error {
+	i, err := tb.findSavepoint(name)
+	if err != nil {
+		return err
+	}
+
+	tb.operations = tb.operations[:tb.savepoints[i].operations]
+	tb.savepoints = tb.savepoints[:i+1]
+	return nil
+}
+
+// ReleaseSavepoint removes the named savepoint and those set after it,
+// keeping their operations in the batch.
+func (tb *TransactionBuilder) ReleaseSavepoint(name string) error {
+	i, err := tb.findSavepoint(name)
+	if err != nil {
+		return err
+	}
+
+	tb.savepoints = tb.savepoints[:i]
+	return nil
+}
+
+func (tb *TransactionBuilder) findSavepoint(name string) (int, error) {
+	if !tb.started {
+		return 0, fmt.Errorf("transaction not started")
+	}
+
+	for i := len(tb.savepoints) - 1; i >= 0; i-- {
+		if tb.savepoints[i].name == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("savepoint %s does not exist", name)
 }
 
 // OperationCount returns the number of pending operations
@@ -162,3 +162,66 @@ func TestTransactionBuilderNotStarted(t *testing.T) {
 		t.Error("Expected error when deleting from unstarted transaction")
 	}
 }
+
+func TestTransactionBuilderSavepoint(t *testing.T) {
+	persistence, err := NewMemoryPersistence()
+	if err != nil {
+		t.Fatalf("Failed to create persistence: %v", err)
+	}
+
+	identity := core.Identity{Name: "test", Email: "test@test.com"}
+
+	persistence.CreateDatabase(core.Database{Name: "testdb"}, identity)
+	persistence.CreateTable(core.Table{
+		Database: "testdb",
+		Name:     "users",
+		Columns:  []core.Column{{Name: "id", Type: core.IntType}},
+	}, identity)
+
+	txn, err := persistence.BeginTransaction()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+
+	txn.AddWrite("testdb", "users", "1", []byte(`{"id":"1"}`))
+	if err := txn.Savepoint("first"); err != nil {
+		t.Fatalf("Failed to set savepoint: %v", err)
+	}
+	txn.AddWrite("testdb", "users", "2", []byte(`{"id":"2"}`))
+	txn.Savepoint("second")
+	txn.AddWrite("testdb", "users", "3", []byte(`{"id":"3"}`))
+
+	// Rolling back to "first" drops writes 2 and 3 and the later savepoint
+	if err := txn.RollbackToSavepoint("first"); err != nil {
+		t.Fatalf("Failed to roll back to savepoint: %v", err)
+	}
+	if txn.OperationCount() != 1 {
+		t.Errorf("Expected 1 operation after rollback to savepoint, got %d", txn.OperationCount())
+	}
+	if err := txn.RollbackToSavepoint("second"); err == nil {
+		t.Error("Expected savepoints after the rollback target to be removed")
+	}
+
+	// The savepoint survives the rollback and can be used again
+	txn.AddWrite("testdb", "users", "4", []byte(`{"id":"4"}`))
+	if err := txn.RollbackToSavepoint("first"); err != nil {
+		t.Fatalf("Failed to roll back to savepoint again: %v", err)
+	}
+	txn.AddWrite("testdb", "users", "5", []byte(`{"id":"5"}`))
+
+	if err := txn.ReleaseSavepoint("first"); err != nil {
+		t.Fatalf("Failed to release savepoint: %v", err)
+	}
+	if err := txn.RollbackToSavepoint("first"); err == nil {
+		t.Error("Expected released savepoint to be gone")
+	}
+
+	if _, err := txn.Commit(identity); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	for key, want := range map[string]bool{"1": true, "2": false, "3": false, "4": false, "5": true} {
+		if _, exists := persistence.GetRecord("testdb", "users", key); exists != want {
+			t.Errorf("Record %s exists=%v, expected %v", key, exists, want)
+		}
+	}
+}
@@ -13,6 +13,9 @@ func (p *Persistence) Branch(name string, from *Transaction) error {
 	if err := p.ensureInitialized(); err != nil {
 		return err
 	}
+	if _, err := p.Flush(); err != nil {
+		return err
+	}
 
 	var hash plumbing.Hash
 
@@ -37,6 +40,9 @@ func (p *Persistence) Checkout(name string) error {
 	if err := p.ensureInitialized(); err != nil {
 		return err
 	}
+	if _, err := p.Flush(); err != nil {
+		return err
+	}
 
 	wt, err := p.repo.Worktree()
 	if err != nil {
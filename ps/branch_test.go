@@ -1,6 +1,7 @@
 package ps
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -292,6 +293,46 @@ func TestMergeRowLevel(t *testing.T) {
 	}
 }
 
+// TestMergeBase tests that the common ancestor of diverged branches is the branch point
+func TestMergeBase(t *testing.T) {
+	persistence, _ := NewMemoryPersistence()
+	identity := core.Identity{Name: "Test", Email: "test@test.com"}
+
+	persistence.CreateDatabase(core.Database{Name: "testdb"}, identity)
+	persistence.CreateTable(core.Table{
+		Database: "testdb",
+		Name:     "users",
+		Columns:  []core.Column{{Name: "id", Type: core.IntType}},
+	}, identity)
+	branchPoint := persistence.LatestTransaction()
+
+	persistence.Branch("feature", nil)
+	persistence.Checkout("feature")
+	persistence.SaveRecord("testdb", "users", map[string][]byte{
+		"1.json": []byte(`{"id":"1"}`),
+	}, identity)
+
+	persistence.Checkout("master")
+	persistence.SaveRecord("testdb", "users", map[string][]byte{
+		"2.json": []byte(`{"id":"2"}`),
+	}, identity)
+	persistence.SaveRecord("testdb", "users", map[string][]byte{
+		"3.json": []byte(`{"id":"3"}`),
+	}, identity)
+
+	base, err := persistence.MergeBase("master", "feature")
+	if err != nil {
+		t.Fatalf("MergeBase failed: %v", err)
+	}
+	if base.Id != branchPoint.Id {
+		t.Errorf("Expected merge base %s, got %s", branchPoint.Id, base.Id)
+	}
+
+	if _, err := persistence.MergeBase("master", "missing"); err == nil {
+		t.Error("Expected error for unknown branch")
+	}
+}
+
 // TestMergeRowLevelWithConflict tests LWW resolution when both branches modify same record
 func TestMergeRowLevelWithConflict(t *testing.T) {
 	persistence, _ := NewMemoryPersistence()
@@ -506,6 +547,79 @@ func TestMergeManualResolveAndComplete(t *testing.T) {
 	}
 }
 
+// TestMergeExportConflicts tests the structured export of pending conflicts
+func TestMergeExportConflicts(t *testing.T) {
+	persistence, _ := NewMemoryPersistence()
+	identity := core.Identity{Name: "Test", Email: "test@test.com"}
+
+	persistence.CreateDatabase(core.Database{Name: "testdb"}, identity)
+	persistence.CreateTable(core.Table{
+		Database: "testdb",
+		Name:     "users",
+		Columns:  []core.Column{{Name: "id", Type: core.IntType}},
+	}, identity)
+	persistence.SaveRecord("testdb", "users", map[string][]byte{
+		"1.json": []byte(`{"id":"1","name":"Original"}`),
+	}, identity)
+
+	persistence.Branch("feature", nil)
+	persistence.Checkout("feature")
+	persistence.SaveRecord("testdb", "users", map[string][]byte{
+		"1.json": []byte(`{"id":"1","name":"FeatureVersion"}`),
+	}, identity)
+
+	persistence.Checkout("master")
+	persistence.SaveRecord("testdb", "users", map[string][]byte{
+		"1.json": []byte(`{"id":"1","name":"MasterVersion"}`),
+	}, identity)
+
+	persistence.MergeWithOptions("feature", identity, MergeOptions{
+		Strategy: MergeStrategyManual,
+	})
+
+	pending := persistence.GetPendingMerge()
+	if pending == nil {
+		t.Fatal("Expected pending merge to exist")
+	}
+	export := pending.ExportConflicts()
+	if export.MergeID != pending.MergeID || export.SourceBranch != "feature" {
+		t.Errorf("Unexpected merge metadata: %+v", export)
+	}
+	if len(export.Conflicts) != 1 {
+		t.Fatalf("Expected 1 exported conflict, got %d", len(export.Conflicts))
+	}
+
+	data, err := json.Marshal(export)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var decoded struct {
+		Conflicts []struct {
+			Table  string            `json:"table"`
+			Key    string            `json:"key"`
+			Base   map[string]string `json:"base"`
+			Head   map[string]string `json:"head"`
+			Source map[string]string `json:"source"`
+		} `json:"conflicts"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v\n%s", err, data)
+	}
+	conflict := decoded.Conflicts[0]
+	if conflict.Table != "users" || conflict.Key != "1" {
+		t.Errorf("Unexpected conflict location: %s/%s", conflict.Table, conflict.Key)
+	}
+	if conflict.Base["name"] != "Original" {
+		t.Errorf("Expected base value Original, got %v", conflict.Base)
+	}
+	if conflict.Head["name"] != "MasterVersion" {
+		t.Errorf("Expected head value MasterVersion, got %v", conflict.Head)
+	}
+	if conflict.Source["name"] != "FeatureVersion" {
+		t.Errorf("Expected source value FeatureVersion, got %v", conflict.Source)
+	}
+}
+
 // TestMergeAbort tests aborting a pending merge
 func TestMergeAbort(t *testing.T) {
 	persistence, _ := NewMemoryPersistence()
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"iter"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/nickyhof/CommitDB/core"
@@ -25,8 +27,10 @@ func (persistence *Persistence) GetDatabase(name string) (d *core.Database, err
 	path := fmt.Sprintf("%s.database", name)
 
 	data, err := persistence.ReadFileDirect(path)
-	if err != nil {
-		return nil, fmt.Errorf("database %s does not exist: %w", name, err)
+	if isNotFound(err) {
+		return nil, fmt.Errorf("%w: %s", ErrDatabaseNotFound, name)
+	} else if err != nil {
+		return nil, err
 	}
 
 	if err := json.Unmarshal(data, &d); err != nil {
@@ -40,6 +44,7 @@ func (persistence *Persistence) DropDatabase(name string, identity core.Identity
 	paths := []string{
 		fmt.Sprintf("%s.database", name),
 		name, // Database directory
+		fmt.Sprintf(".commitdb/triggers/%s", name), // Trigger definitions
 	}
 
 	// Use low-level plumbing API
@@ -47,6 +52,10 @@ func (persistence *Persistence) DropDatabase(name string, identity core.Identity
 }
 
 func (persistence *Persistence) CreateTable(table core.Table, identity core.Identity) (txn Transaction, err error) {
+	if table.Fanout < 0 || table.Fanout > MaxFanout {
+		return Transaction{}, fmt.Errorf("fanout must be between 0 and %d", MaxFanout)
+	}
+
 	path := fmt.Sprintf("%s/%s.table", table.Database, table.Name)
 
 	dataBytes, err := json.Marshal(table)
@@ -58,12 +67,54 @@ func (persistence *Persistence) CreateTable(table core.Table, identity core.Iden
 	return persistence.WriteFileDirect(path, dataBytes, identity, "Creating table")
 }
 
+// CreateTableWithRecords creates a table and writes its initial records in a
+// single commit.
+func (persistence *Persistence) CreateTableWithRecords(table core.Table, records map[string][]byte, identity core.Identity) (txn Transaction, err error) {
+	if table.Fanout < 0 || table.Fanout > MaxFanout {
+		return Transaction{}, fmt.Errorf("fanout must be between 0 and %d", MaxFanout)
+	}
+	if err := persistence.ensureInitialized(); err != nil {
+		return Transaction{}, err
+	}
+
+	dataBytes, err := json.Marshal(table)
+	if err != nil {
+		return Transaction{}, fmt.Errorf("failed to marshal table: %w", err)
+	}
+
+	// The table is not in HEAD yet, so record paths use its fanout directly
+	operations := []Operation{{
+		Type: WriteOp,
+		Path: fmt.Sprintf("%s/%s.table", table.Database, table.Name),
+		Data: dataBytes,
+	}}
+	for _, key := range slices.Sorted(maps.Keys(records)) {
+		operations = append(operations, Operation{
+			Type:     WriteOp,
+			Database: table.Database,
+			Table:    table.Name,
+			Key:      key,
+			Data:     records[key],
+			Path:     recordPath(table.Database, table.Name, key, table.Fanout),
+		})
+	}
+
+	// Buffered writes go first so they are not lost under this commit
+	defer persistence.dispatchCommitEvents()
+	if _, err := persistence.Flush(); err != nil {
+		return Transaction{}, err
+	}
+	return persistence.commitOperations(operations, identity, "Creating table")
+}
+
 func (persistence *Persistence) GetTable(database string, table string) (t *core.Table, err error) {
 	path := fmt.Sprintf("%s/%s.table", database, table)
 
 	data, err := persistence.ReadFileDirect(path)
-	if err != nil {
-		return nil, fmt.Errorf("table %s.%s does not exist: %w", database, table, err)
+	if isNotFound(err) {
+		return nil, fmt.Errorf("%w: %s.%s", ErrTableNotFound, database, table)
+	} else if err != nil {
+		return nil, err
 	}
 
 	if err := json.Unmarshal(data, &t); err != nil {
@@ -91,22 +142,64 @@ func (persistence *Persistence) DropTable(database string, table string, identit
 		fmt.Sprintf("%s/%s.table", database, table),
 		fmt.Sprintf("%s/%s", database, table), // Table data directory
 	}
+	// Triggers on the table go with it, so a table recreated under the same
+	// name does not inherit them
+	triggers, _ := persistence.ListTriggers(database)
+	for _, trigger := range triggers {
+		if trigger.Table == table {
+			paths = append(paths, triggerPath(database, trigger.Name))
+		}
+	}
+	// So do its indexes, which a seeded table of the same name would
+	// otherwise find stale
+	if schema, err := persistence.GetTable(database, table); err == nil {
+		for _, column := range schema.Columns {
+			path := fmt.Sprintf("%s/%s.index.%s", database, table, column.Name)
+			if _, err := persistence.ReadFileDirect(path); err == nil {
+				paths = append(paths, path)
+			}
+		}
+	}
 
 	// Use low-level plumbing API
 	return persistence.DeletePathDirect(paths, identity, "Dropping table")
 }
 
+// SaveRecord writes records in one commit, or buffers them while
+// write-behind is enabled (the returned transaction then has no Id).
 func (persistence *Persistence) SaveRecord(database string, table string, records map[string][]byte, identity core.Identity) (txn Transaction, err error) {
+	if buffered, txn, err := persistence.bufferWrite(database, table, records, identity); buffered {
+		return txn, err
+	}
 	// Use low-level plumbing API for better performance
 	return persistence.SaveRecordDirect(database, table, records, identity)
 }
 
 func (persistence *Persistence) DeleteRecord(database string, table string, key string, identity core.Identity) (txn Transaction, err error) {
+	if buffered, txn, err := persistence.bufferWrite(database, table, map[string][]byte{key: nil}, identity); buffered {
+		return txn, err
+	}
 	// Use low-level plumbing API for better performance
 	return persistence.DeleteRecordDirect(database, table, key, identity)
 }
 
+// DeleteRecords deletes the records with the given keys in one commit, or
+// buffers the deletes while write-behind is enabled. Missing keys are ignored.
+func (persistence *Persistence) DeleteRecords(database string, table string, keys []string, identity core.Identity) (txn Transaction, err error) {
+	deletes := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		deletes[key] = nil
+	}
+	if buffered, txn, err := persistence.bufferWrite(database, table, deletes, identity); buffered {
+		return txn, err
+	}
+	return persistence.DeleteRecordsDirect(database, table, keys, identity)
+}
+
 func (persistence *Persistence) GetRecord(database string, table string, key string) (data []byte, exists bool) {
+	if data, ok := persistence.bufferedRecords(database, table)[key]; ok {
+		return data, data != nil
+	}
 	// Use low-level plumbing API
 	return persistence.GetRecordDirect(database, table, key)
 }
@@ -154,39 +247,70 @@ func (persistence *Persistence) ListTables(database string) []string {
 }
 
 func (persistence *Persistence) ListRecordKeys(database string, table string) []string {
-	path := fmt.Sprintf("%s/%s", database, table)
-
-	entries, err := persistence.ListEntriesDirect(path)
-	if err != nil {
-		return nil
-	}
+	buffered := persistence.bufferedRecords(database, table)
 
 	var keys []string
-	for _, entry := range entries {
-		if !entry.IsDir {
-			keys = append(keys, entry.Name)
+	for _, record := range persistence.listRecordsDirect(database, table) {
+		if data, ok := buffered[record.Key]; ok {
+			delete(buffered, record.Key)
+			if data == nil {
+				continue
+			}
+		}
+		keys = append(keys, record.Key)
+	}
+	// Keys only written to the write-behind buffer
+	for _, key := range slices.Sorted(maps.Keys(buffered)) {
+		if buffered[key] != nil {
+			keys = append(keys, key)
 		}
 	}
 
 	return keys
 }
 
+// Scan iterates over the records of a table at HEAD in tree order: by key
+// bytes for flat tables and by key hash for tables with a fan-out. Records
+// only in the write-behind buffer follow in key order.
 func (persistence *Persistence) Scan(database string, table string, filterExpr *func(key string, value []byte) bool) iter.Seq2[string, []byte] {
-	keys := persistence.ListRecordKeys(database, table)
+	records := persistence.listRecordsDirect(database, table)
+	buffered := persistence.bufferedRecords(database, table)
 
 	currentIndex := 0
 
 	return func(yield func(key string, value []byte) bool) {
-		for currentIndex < len(keys) {
-			key := keys[currentIndex]
-			value, _ := persistence.GetRecord(database, table, key)
-
+		for currentIndex < len(records) {
+			record := records[currentIndex]
 			currentIndex++
 
-			if filterExpr != nil && !(*filterExpr)(key, value) {
+			value, ok := buffered[record.Key]
+			if ok {
+				delete(buffered, record.Key)
+				if value == nil {
+					continue // deleted in the buffer
+				}
+			} else {
+				value, _ = persistence.readBlobDirect(record.Hash)
+			}
+
+			if filterExpr != nil && !(*filterExpr)(record.Key, value) {
 				continue
 			}
 
+			if !yield(record.Key, value) {
+				return
+			}
+		}
+
+		for _, key := range slices.Sorted(maps.Keys(buffered)) {
+			value := buffered[key]
+			delete(buffered, key)
+			if value == nil {
+				continue
+			}
+			if filterExpr != nil && !(*filterExpr)(key, value) {
+				continue
+			}
 			if !yield(key, value) {
 				return
 			}
@@ -0,0 +1,127 @@
+package ps
+
+import (
+	"maps"
+	"slices"
+)
+
+// CommitEvent describes the records a committed transaction changed
+type CommitEvent struct {
+	Transaction Transaction
+	Changes     []TableChange // One entry per table, in the order the tables were first changed
+}
+
+// TableChange lists the primary keys of one table's records that a
+// transaction wrote or deleted. A key changed more than once is listed by its
+// final operation.
+type TableChange struct {
+	Database string
+	Table    string
+	Written  []string
+	Deleted  []string
+}
+
+// CommitHook is called after a transaction that wrote or deleted records is
+// committed.
+type CommitHook func(event CommitEvent)
+
+// AddCommitHook registers hook to be called after every commit that changes
+// records, whether made directly, by a TransactionBuilder or by a
+// write-behind flush. Schema, index and merge commits are not reported.
+//
+// Hooks are synchronous and best-effort: they run on a committing goroutine
+// once the commit's locks are released, so they may read and write through
+// the Persistence, but they cannot fail or undo the commit. Events are
+// delivered in commit order; a commit made while hooks are running is
+// delivered after them, possibly on another committing goroutine.
+func (p *Persistence) AddCommitHook(hook CommitHook) {
+	p.hookMu.Lock()
+	defer p.hookMu.Unlock()
+
+	p.commitHooks = append(p.commitHooks, hook)
+}
+
+// queueCommitEvent records the record changes of a commit for the next
+// dispatchCommitEvents. It does nothing when no hooks are registered or
+// the write made no commit.
+func (p *Persistence) queueCommitEvent(txn Transaction, operations []Operation) {
+	p.hookMu.Lock()
+	defer p.hookMu.Unlock()
+
+	if len(p.commitHooks) == 0 || txn.Unchanged {
+		return
+	}
+	if event := commitEvent(txn, operations); len(event.Changes) > 0 {
+		p.pendingEvents = append(p.pendingEvents, event)
+	}
+}
+
+// dispatchCommitEvents calls the registered hooks with the queued events.
+// Commit paths defer it before taking their locks so hooks run after the
+// locks are released. If another call is already dispatching, that call
+// delivers the queued events once its current hooks return.
+func (p *Persistence) dispatchCommitEvents() {
+	p.hookMu.Lock()
+	if p.dispatching {
+		p.hookMu.Unlock()
+		return
+	}
+	p.dispatching = true
+	for len(p.pendingEvents) > 0 {
+		events, hooks := p.pendingEvents, slices.Clone(p.commitHooks)
+		p.pendingEvents = nil
+		p.hookMu.Unlock()
+		for _, event := range events {
+			for _, hook := range hooks {
+				hook(event)
+			}
+		}
+		p.hookMu.Lock()
+	}
+	p.dispatching = false
+	p.hookMu.Unlock()
+}
+
+// commitEvent groups the record operations of a commit by table. Operations
+// on non-record files, which have a Path but no Key, are left out.
+func commitEvent(txn Transaction, operations []Operation) CommitEvent {
+	var tables []string
+	finalOps := make(map[string]map[string]OperationType) // "database/table" -> key -> last operation
+	names := make(map[string]Operation)
+	for _, op := range operations {
+		if op.Path != "" && op.Key == "" {
+			continue
+		}
+		tableKey := op.Database + "/" + op.Table
+		if finalOps[tableKey] == nil {
+			finalOps[tableKey] = make(map[string]OperationType)
+			names[tableKey] = op
+			tables = append(tables, tableKey)
+		}
+		finalOps[tableKey][op.Key] = op.Type
+	}
+
+	event := CommitEvent{Transaction: txn}
+	for _, tableKey := range tables {
+		change := TableChange{Database: names[tableKey].Database, Table: names[tableKey].Table}
+		for _, key := range slices.Sorted(maps.Keys(finalOps[tableKey])) {
+			if finalOps[tableKey][key] == DeleteOp {
+				change.Deleted = append(change.Deleted, key)
+			} else {
+				change.Written = append(change.Written, key)
+			}
+		}
+		event.Changes = append(event.Changes, change)
+	}
+	return event
+}
+
+// recordOperations returns operations of one type on the given keys of a
+// table, for describing direct commits to commitEvent
+func recordOperations(opType OperationType, database, table string, keys []string) []Operation {
+	operations := make([]Operation, len(keys))
+	for i, key := range keys {
+		operations[i] = Operation{Type: opType, Database: database, Table: table, Key: key}
+	}
+	return operations
+}
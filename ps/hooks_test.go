@@ -0,0 +1,103 @@
+package ps
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCommitHookDirectWrites(t *testing.T) {
+	persistence, identity := setupWriteBehind(t)
+	var events []CommitEvent
+	persistence.AddCommitHook(func(event CommitEvent) {
+		// Hooks run after the commit's locks are released, so reads work
+		if _, exists := persistence.GetRecord("testdb", "users", "1"); !exists && len(events) == 0 {
+			t.Error("Expected the committed record to be readable from the hook")
+		}
+		events = append(events, event)
+	})
+
+	txn, err := persistence.SaveRecord("testdb", "users", map[string][]byte{"2": []byte(`{"id":"2"}`), "1": []byte(`{"id":"1"}`)}, identity)
+	if err != nil {
+		t.Fatalf("SaveRecord failed: %v", err)
+	}
+	if _, err := persistence.DeleteRecords("testdb", "users", []string{"2"}, identity); err != nil {
+		t.Fatalf("DeleteRecords failed: %v", err)
+	}
+
+	// Rewriting a record with the same data makes no commit and no event
+	unchanged, err := persistence.SaveRecord("testdb", "users", map[string][]byte{"1": []byte(`{"id":"1"}`)}, identity)
+	if err != nil || !unchanged.Unchanged {
+		t.Fatalf("Expected an unchanged rewrite, got %+v (%v)", unchanged, err)
+	}
+
+	expected := []TableChange{{Database: "testdb", Table: "users", Written: []string{"1", "2"}}}
+	if len(events) != 2 || events[0].Transaction.Id != txn.Id || !reflect.DeepEqual(events[0].Changes, expected) {
+		t.Fatalf("Expected write event %+v in %s, got %+v", expected, txn.Id, events)
+	}
+	expected = []TableChange{{Database: "testdb", Table: "users", Deleted: []string{"2"}}}
+	if !reflect.DeepEqual(events[1].Changes, expected) {
+		t.Errorf("Expected delete event %+v, got %+v", expected, events[1].Changes)
+	}
+}
+
+func TestCommitHookTransactionAndWriteBehind(t *testing.T) {
+	persistence, identity := setupWriteBehind(t)
+	var events []CommitEvent
+	persistence.AddCommitHook(func(event CommitEvent) { events = append(events, event) })
+
+	tb, _ := persistence.BeginTransaction()
+	tb.AddWrite("testdb", "users", "1", []byte(`{"id":"1"}`))
+	tb.AddWrite("testdb", "orders", "9", []byte(`{"id":"9"}`))
+	tb.AddWrite("testdb", "users", "2", []byte(`{"id":"2"}`))
+	tb.AddDelete("testdb", "users", "2")
+	txn, err := tb.Commit(identity)
+	if err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	expected := CommitEvent{Transaction: txn, Changes: []TableChange{
+		{Database: "testdb", Table: "users", Written: []string{"1"}, Deleted: []string{"2"}},
+		{Database: "testdb", Table: "orders", Written: []string{"9"}},
+	}}
+	if len(events) != 1 || !reflect.DeepEqual(events[0], expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, events)
+	}
+
+	// Buffered writes are reported once, when they are flushed
+	persistence.EnableWriteBehind(WriteBehind{})
+	persistence.SaveRecord("testdb", "users", map[string][]byte{"3": []byte(`{"id":"3"}`)}, identity)
+	if len(events) != 1 {
+		t.Fatalf("Expected no event before flush, got %+v", events[1:])
+	}
+	txn, err = persistence.Flush()
+	if err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if len(events) != 2 || events[1].Transaction.Id != txn.Id || !reflect.DeepEqual(events[1].Changes[0].Written, []string{"3"}) {
+		t.Errorf("Expected flush event for key 3 in %s, got %+v", txn.Id, events[1:])
+	}
+}
+
+func TestCommitHookWritesFromHook(t *testing.T) {
+	persistence, identity := setupWriteBehind(t)
+	var tables []string
+	persistence.AddCommitHook(func(event CommitEvent) {
+		change := event.Changes[0]
+		tables = append(tables, change.Table)
+		// A hook may commit again; its event is delivered after this one
+		if change.Table == "users" {
+			if _, err := persistence.SaveRecord("testdb", "audit", map[string][]byte{change.Written[0]: []byte(`{}`)}, identity); err != nil {
+				t.Errorf("SaveRecord from hook failed: %v", err)
+			}
+		}
+	})
+
+	if _, err := persistence.SaveRecord("testdb", "users", map[string][]byte{"1": []byte(`{"id":"1"}`)}, identity); err != nil {
+		t.Fatalf("SaveRecord failed: %v", err)
+	}
+	if !reflect.DeepEqual(tables, []string{"users", "audit"}) {
+		t.Errorf("Expected events for users then audit, got %v", tables)
+	}
+	if _, exists := persistence.GetRecord("testdb", "audit", "1"); !exists {
+		t.Error("Expected the hook's write to be committed")
+	}
+}
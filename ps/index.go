@@ -3,9 +3,12 @@ package ps
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
 	"sort"
 	"sync"
 
+	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/nickyhof/CommitDB/core"
 )
 
@@ -17,6 +20,8 @@ type Index struct {
 	Column   string              `json:"column"`
 	Unique   bool                `json:"unique"`
 	Entries  map[string][]string `json:"entries"` // column value -> list of primary keys
+
+	changes map[string]map[string]bool // since the last save: value -> primary key -> added, or removed if false
 }
 
 // IndexManager manages indexes for a persistence layer
@@ -105,6 +110,31 @@ func (im *IndexManager) DropIndex(database, table, column string) error {
 
 // Insert adds an entry to the index
 func (idx *Index) Insert(columnValue, primaryKey string) error {
+	if err := idx.insert(columnValue, primaryKey); err != nil {
+		return err
+	}
+	idx.recordChange(columnValue, primaryKey, true)
+	return nil
+}
+
+// Delete removes an entry from the index
+func (idx *Index) Delete(columnValue, primaryKey string) {
+	idx.delete(columnValue, primaryKey)
+	idx.recordChange(columnValue, primaryKey, false)
+}
+
+// recordChange notes an added or removed entry for the next save
+func (idx *Index) recordChange(columnValue, primaryKey string, added bool) {
+	if idx.changes == nil {
+		idx.changes = make(map[string]map[string]bool)
+	}
+	if idx.changes[columnValue] == nil {
+		idx.changes[columnValue] = make(map[string]bool)
+	}
+	idx.changes[columnValue][primaryKey] = added
+}
+
+func (idx *Index) insert(columnValue, primaryKey string) error {
 	if idx.Unique {
 		if existing, ok := idx.Entries[columnValue]; ok && len(existing) > 0 {
 			return fmt.Errorf("duplicate value %s violates unique constraint on index %s", columnValue, idx.Name)
@@ -123,8 +153,7 @@ func (idx *Index) Insert(columnValue, primaryKey string) error {
 	return nil
 }
 
-// Delete removes an entry from the index
-func (idx *Index) Delete(columnValue, primaryKey string) {
+func (idx *Index) delete(columnValue, primaryKey string) {
 	keys := idx.Entries[columnValue]
 	for i, k := range keys {
 		if k == primaryKey {
@@ -137,6 +166,41 @@ func (idx *Index) Delete(columnValue, primaryKey string) {
 	}
 }
 
+// apply makes changes recorded by another copy of the index, removals
+// first so a unique value can move between keys. It applies every change
+// and returns the first that violates the unique constraint.
+func (idx *Index) apply(changes map[string]map[string]bool) error {
+	values := slices.Sorted(maps.Keys(changes))
+	for _, value := range values {
+		for _, key := range slices.Sorted(maps.Keys(changes[value])) {
+			if !changes[value][key] {
+				idx.delete(value, key)
+			}
+		}
+	}
+	var conflict error
+	for _, value := range values {
+		for _, key := range slices.Sorted(maps.Keys(changes[value])) {
+			if !changes[value][key] {
+				continue
+			}
+			if len(idx.Entries[value]) > 0 && !slices.Contains(idx.Entries[value], key) && idx.Unique && conflict == nil {
+				conflict = fmt.Errorf("duplicate value %s violates unique constraint on index %s", value, idx.Name)
+			}
+			idx.Entries[value] = appendMissing(idx.Entries[value], key)
+		}
+	}
+	return conflict
+}
+
+// appendMissing appends key to keys unless it is already there
+func appendMissing(keys []string, key string) []string {
+	if slices.Contains(keys, key) {
+		return keys
+	}
+	return append(keys, key)
+}
+
 // Lookup finds primary keys for a given column value
 func (idx *Index) Lookup(columnValue string) []string {
 	return idx.Entries[columnValue]
@@ -166,6 +230,14 @@ func (idx *Index) LookupRange(minValue, maxValue string) []string {
 func (im *IndexManager) saveIndex(idx *Index) error {
 	path := fmt.Sprintf("%s/%s.index.%s", idx.Database, idx.Table, idx.Column)
 
+	// Under write-behind only the index's changes are buffered. They commit
+	// with the buffered records, applied to the index as it is then.
+	changes := idx.changes
+	idx.changes = nil
+	if im.persistence.bufferIndex(path, idx, changes, im.identity) {
+		return nil
+	}
+
 	data, err := json.Marshal(idx)
 	if err != nil {
 		return fmt.Errorf("failed to marshal index: %w", err)
@@ -230,15 +302,27 @@ func (im *IndexManager) LoadIndexes(database, table string, columns []core.Colum
 
 	for _, col := range columns {
 		path := fmt.Sprintf("%s/%s.index.%s", database, table, col.Name)
+		buffered := im.persistence.bufferedIndex(path)
 		data, err := im.persistence.ReadFileDirect(path)
-		if err != nil {
+		if err != nil && buffered == nil {
 			continue // Index doesn't exist
 		}
 
 		var idx Index
-		if err := json.Unmarshal(data, &idx); err != nil {
+		if err != nil {
+			// Created under write-behind and not yet flushed
+			idx = buffered.header
+		} else if err := json.Unmarshal(data, &idx); err != nil {
 			continue
 		}
+		if idx.Entries == nil {
+			idx.Entries = make(map[string][]string)
+		}
+		if buffered != nil {
+			// Buffered changes apply on top of the committed index; a unique
+			// conflict with rows committed since is reported at flush
+			_ = idx.apply(buffered.changes)
+		}
 
 		key := indexKey(database, table, col.Name)
 		im.indexes[key] = &idx
@@ -249,10 +333,39 @@ func (im *IndexManager) LoadIndexes(database, table string, columns []core.Colum
 
 // RebuildIndex rebuilds an index by scanning all records
 func (im *IndexManager) RebuildIndex(idx *Index, getRecordValue func(pk string) (string, bool)) error {
-	idx.Entries = make(map[string][]string)
+	for value, keys := range idx.Entries {
+		for _, key := range slices.Clone(keys) {
+			idx.Delete(value, key)
+		}
+	}
 
 	// This would be called with a function that retrieves the column value for each primary key
 	// The actual implementation depends on how records are stored
 
 	return im.saveIndex(idx)
 }
+
+// mergeIndex applies buffered changes to the index at path in the tree and
+// returns the merged index file. The index is created if the tree has none.
+func (p *Persistence) mergeIndex(treeHash plumbing.Hash, path string, delta *indexDelta) ([]byte, error) {
+	idx := delta.header
+	if data, err := p.readFileAt(treeHash, path); err == nil {
+		if err := json.Unmarshal(data, &idx); err != nil {
+			return nil, fmt.Errorf("failed to parse index %s: %w", path, err)
+		}
+	} else if !isNotFound(err) {
+		return nil, err
+	}
+	if idx.Entries == nil {
+		idx.Entries = make(map[string][]string)
+	}
+	if err := idx.apply(delta.changes); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal index: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,155 @@
+package ps
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"path"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/nickyhof/CommitDB/core"
+)
+
+// Record layout
+//
+// By default every row is a blob directly under database/table/. A table
+// created with a fan-out instead stores each row under directories named
+// after its key hash (database/table/ab/cd/key for a fan-out of 2), so no
+// single Git tree grows with the table and updates rewrite smaller trees.
+
+// MaxFanout is the deepest supported record directory fan-out.
+const MaxFanout = 4
+
+// recordEntry is a record located in a table tree.
+type recordEntry struct {
+	Key  string
+	Hash plumbing.Hash
+}
+
+// recordPath returns the tree path of a record in a table with the given fan-out.
+func recordPath(database, table, key string, fanout int) string {
+	parts := []string{database, table}
+	if fanout > 0 {
+		sum := sha1.Sum([]byte(key))
+		digest := hex.EncodeToString(sum[:])
+		for i := 0; i < fanout; i++ {
+			parts = append(parts, digest[i*2:i*2+2])
+		}
+	}
+	parts = append(parts, key)
+	return path.Join(parts...)
+}
+
+// tableFanout reads a table's record fan-out from its metadata in tree.
+// Missing or unreadable metadata means the flat layout. Fan-outs are cached
+// by metadata blob hash, so each schema version is parsed once however many
+// records are located with it.
+func (p *Persistence) tableFanout(tree *object.Tree, database, table string) int {
+	entry, err := tree.FindEntry(path.Join(database, table+".table"))
+	if err != nil {
+		return 0
+	}
+
+	p.fanoutMu.Lock()
+	fanout, ok := p.fanouts[entry.Hash]
+	p.fanoutMu.Unlock()
+	if ok {
+		return fanout
+	}
+
+	content, err := p.readBlob(entry.Hash)
+	if err != nil {
+		return 0
+	}
+	var t core.Table
+	if err := json.Unmarshal(content, &t); err != nil {
+		return 0
+	}
+
+	p.fanoutMu.Lock()
+	if p.fanouts == nil {
+		p.fanouts = make(map[plumbing.Hash]int)
+	}
+	p.fanouts[entry.Hash] = t.Fanout
+	p.fanoutMu.Unlock()
+	return t.Fanout
+}
+
+// tableFanoutAt is tableFanout for a tree hash; ZeroHash (empty repo) is flat.
+func (p *Persistence) tableFanoutAt(treeHash plumbing.Hash, database, table string) int {
+	if treeHash == plumbing.ZeroHash {
+		return 0
+	}
+	tree, err := p.repo.TreeObject(treeHash)
+	if err != nil {
+		return 0
+	}
+	return p.tableFanout(tree, database, table)
+}
+
+// headTree returns the tree of the HEAD commit, or nil if there are no commits.
+func (p *Persistence) headTree() (*object.Tree, error) {
+	headRef, err := p.repo.Head()
+	if err != nil {
+		return nil, nil
+	}
+	commit, err := p.repo.CommitObject(headRef.Hash())
+	if err != nil {
+		return nil, err
+	}
+	return commit.Tree()
+}
+
+// tableRecords lists every record of a table in tree, descending into
+// fan-out directories. Records are returned in tree order.
+func tableRecords(tree *object.Tree, database, table string) []recordEntry {
+	tableTree, err := tree.Tree(path.Join(database, table))
+	if err != nil {
+		return nil
+	}
+
+	var records []recordEntry
+	tableTree.Files().ForEach(func(f *object.File) error {
+		records = append(records, recordEntry{Key: path.Base(f.Name), Hash: f.Hash})
+		return nil
+	})
+	return records
+}
+
+// readBlob returns the contents of a blob.
+func (p *Persistence) readBlob(hash plumbing.Hash) ([]byte, error) {
+	blob, err := p.repo.BlobObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	file := object.NewFile("", 0, blob)
+	content, err := file.Contents()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(content), nil
+}
+
+// listRecordsDirect lists the records of a table at HEAD.
+func (p *Persistence) listRecordsDirect(database, table string) []recordEntry {
+	if !p.IsInitialized() {
+		return nil
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	tree, err := p.headTree()
+	if err != nil || tree == nil {
+		return nil
+	}
+	return tableRecords(tree, database, table)
+}
+
+// readBlobDirect returns the contents of a blob, taking the read lock.
+func (p *Persistence) readBlobDirect(hash plumbing.Hash) ([]byte, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.readBlob(hash)
+}
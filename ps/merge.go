@@ -1,6 +1,7 @@
 package ps
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -71,7 +72,8 @@ type PendingMerge struct {
 	CreatedAt     time.Time         `json:"created_at"`
 }
 
-// findMergeBase finds the common ancestor commit of two commits
+// findMergeBase finds the best common ancestor of two commits, as
+// `git merge-base` would. When several candidates exist the first is used.
 func (p *Persistence) findMergeBase(headHash, sourceHash plumbing.Hash) (*object.Commit, error) {
 	headCommit, err := p.repo.CommitObject(headHash)
 	if err != nil {
@@ -83,34 +85,39 @@ func (p *Persistence) findMergeBase(headHash, sourceHash plumbing.Hash) (*object
 		return nil, fmt.Errorf("failed to get source commit: %w", err)
 	}
 
-	// Walk both commit histories to find common ancestor
-	// Build ancestor set from HEAD
-	headAncestors := make(map[plumbing.Hash]bool)
-	headIter := object.NewCommitIterCTime(headCommit, nil, nil)
-	err = headIter.ForEach(func(c *object.Commit) error {
-		headAncestors[c.Hash] = true
-		return nil
-	})
+	bases, err := headCommit.MergeBase(sourceCommit)
 	if err != nil {
-		return nil, fmt.Errorf("failed to iterate HEAD history: %w", err)
+		return nil, fmt.Errorf("failed to compute merge base: %w", err)
+	}
+	if len(bases) == 0 {
+		return nil, fmt.Errorf("no common ancestor found")
 	}
 
-	// Find first source ancestor that's also in HEAD ancestors
-	var mergeBase *object.Commit
-	sourceIter := object.NewCommitIterCTime(sourceCommit, nil, nil)
-	err = sourceIter.ForEach(func(c *object.Commit) error {
-		if headAncestors[c.Hash] {
-			mergeBase = c
-			return fmt.Errorf("found") // break iteration
-		}
-		return nil
-	})
+	return bases[0], nil
+}
 
-	if mergeBase == nil {
-		return nil, fmt.Errorf("no common ancestor found")
+// MergeBase returns the commit a merge between two branches would use as
+// their common ancestor.
+func (p *Persistence) MergeBase(branchA, branchB string) (Transaction, error) {
+	if err := p.ensureInitialized(); err != nil {
+		return Transaction{}, err
 	}
 
-	return mergeBase, nil
+	refA, err := p.repo.Reference(plumbing.NewBranchReferenceName(branchA), true)
+	if err != nil {
+		return Transaction{}, fmt.Errorf("branch '%s' not found", branchA)
+	}
+	refB, err := p.repo.Reference(plumbing.NewBranchReferenceName(branchB), true)
+	if err != nil {
+		return Transaction{}, fmt.Errorf("branch '%s' not found", branchB)
+	}
+
+	base, err := p.findMergeBase(refA.Hash(), refB.Hash())
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	return commitTransaction(base), nil
 }
 
 // getRecordsAtCommit reads all records from a table at a specific commit
@@ -121,38 +128,25 @@ func (p *Persistence) getRecordsAtCommit(commit *object.Commit, database, table
 	}
 
 	records := make(map[string][]byte)
-	tablePath := fmt.Sprintf("%s/%s", database, table)
 
-	// Try to find the table directory
-	tableTree, err := tree.Tree(tablePath)
-	if err != nil {
-		// Table doesn't exist at this commit
-		return records, nil
-	}
-
-	// Read all record files
-	for _, entry := range tableTree.Entries {
-		if entry.Name == "_meta.json" {
+	// Read all record files, including those under fan-out directories
+	for _, record := range tableRecords(tree, database, table) {
+		if record.Key == "_meta.json" {
 			continue
 		}
 
-		file, err := tableTree.File(entry.Name)
-		if err != nil {
-			continue
-		}
-
-		content, err := file.Contents()
+		content, err := p.readBlob(record.Hash)
 		if err != nil {
 			continue
 		}
 
 		// Remove .json extension for key
-		key := entry.Name
+		key := record.Key
 		if len(key) > 5 && key[len(key)-5:] == ".json" {
 			key = key[:len(key)-5]
 		}
 
-		records[key] = []byte(content)
+		records[key] = content
 	}
 
 	return records, nil
@@ -312,9 +306,15 @@ func (p *Persistence) performRowLevelMerge(
 			}
 			result.Conflicts = append(result.Conflicts, conflicts...)
 
+			headTree, _ := headCommit.Tree()
+			fanout := 0
+			if headTree != nil {
+				fanout = p.tableFanout(headTree, dbName, tableName)
+			}
+
 			// Write merged records to worktree
 			for key, data := range merged {
-				path := fmt.Sprintf("%s/%s/%s.json", dbName, tableName, key)
+				path := recordPath(dbName, tableName, key+".json", fanout)
 				if err := util.WriteFile(wt.Filesystem, path, data, 0644); err != nil {
 					return result, fmt.Errorf("failed to write merged record: %w", err)
 				}
@@ -324,7 +324,7 @@ func (p *Persistence) performRowLevelMerge(
 			// Remove records that should be deleted (in head/source but not in merged)
 			for key := range headRecords {
 				if _, inMerged := merged[key]; !inMerged {
-					path := fmt.Sprintf("%s/%s/%s.json", dbName, tableName, key)
+					path := recordPath(dbName, tableName, key+".json", fanout)
 					wt.Filesystem.Remove(path)
 				}
 			}
@@ -456,6 +456,9 @@ func (p *Persistence) MergeWithOptions(source string, identity core.Identity, op
 	if err := p.ensureInitialized(); err != nil {
 		return MergeResult{}, err
 	}
+	if _, err := p.Flush(); err != nil {
+		return MergeResult{}, err
+	}
 
 	headRef, err := p.repo.Head()
 	if err != nil {
@@ -678,6 +681,67 @@ func (p *Persistence) GetPendingMerge() *PendingMerge {
 	return p.pendingMerge
 }
 
+// ConflictExport describes a pending merge and its unresolved conflicts for
+// external merge tools. It marshals to JSON with stored rows embedded as
+// JSON objects.
+type ConflictExport struct {
+	MergeID      string             `json:"merge_id"`
+	SourceBranch string             `json:"source_branch"`
+	BaseCommit   string             `json:"base_commit"`
+	HeadCommit   string             `json:"head_commit"`
+	SourceCommit string             `json:"source_commit"`
+	Conflicts    []ExportedConflict `json:"conflicts"`
+}
+
+// ExportedConflict is one conflicting record with its value at the common
+// ancestor and on each side. A null value means the record did not exist
+// at the merge base, or was deleted on that side.
+type ExportedConflict struct {
+	Database string          `json:"database"`
+	Table    string          `json:"table"`
+	Key      string          `json:"key"`
+	Base     json.RawMessage `json:"base"`
+	Head     json.RawMessage `json:"head"`
+	Source   json.RawMessage `json:"source"`
+}
+
+// ExportConflicts returns the merge's unresolved conflicts with their base,
+// HEAD and SOURCE values.
+func (pm *PendingMerge) ExportConflicts() ConflictExport {
+	export := ConflictExport{
+		MergeID:      pm.MergeID,
+		SourceBranch: pm.SourceBranch,
+		BaseCommit:   pm.BaseCommit,
+		HeadCommit:   pm.HeadCommit,
+		SourceCommit: pm.SourceCommit,
+		Conflicts:    make([]ExportedConflict, len(pm.Unresolved)),
+	}
+	for i, conflict := range pm.Unresolved {
+		export.Conflicts[i] = ExportedConflict{
+			Database: conflict.Database,
+			Table:    conflict.Table,
+			Key:      conflict.Key,
+			Base:     exportedValue(conflict.BaseVal),
+			Head:     exportedValue(conflict.HeadVal),
+			Source:   exportedValue(conflict.SourceVal),
+		}
+	}
+	return export
+}
+
+// exportedValue embeds a stored record as JSON: null when it is missing and
+// a JSON string when it is not valid JSON
+func exportedValue(value []byte) json.RawMessage {
+	if value == nil {
+		return json.RawMessage("null")
+	}
+	if json.Valid(value) {
+		return json.RawMessage(value)
+	}
+	quoted, _ := json.Marshal(string(value))
+	return quoted
+}
+
 // ResolveConflict resolves a single conflict in a pending merge
 func (p *Persistence) ResolveConflict(database, table, key string, resolution []byte) error {
 	if p.pendingMerge == nil {
@@ -723,13 +787,24 @@ func (p *Persistence) CompleteMerge(identity core.Identity) (Transaction, error)
 		return Transaction{}, err
 	}
 
+	headTree, err := p.headTree()
+	if err != nil {
+		return Transaction{}, err
+	}
+	fanoutOf := func(database, table string) int {
+		if headTree == nil {
+			return 0
+		}
+		return p.tableFanout(headTree, database, table)
+	}
+
 	// Write all merged records
 	for fullKey, data := range p.pendingMerge.MergedRecords {
 		parts := splitKey(fullKey)
 		if len(parts) != 3 {
 			continue
 		}
-		path := fmt.Sprintf("%s/%s/%s.json", parts[0], parts[1], parts[2])
+		path := recordPath(parts[0], parts[1], parts[2]+".json", fanoutOf(parts[0], parts[1]))
 		if err := util.WriteFile(wt.Filesystem, path, data, 0644); err != nil {
 			return Transaction{}, err
 		}
@@ -741,7 +816,7 @@ func (p *Persistence) CompleteMerge(identity core.Identity) (Transaction, error)
 		if len(parts) != 3 {
 			continue
 		}
-		path := fmt.Sprintf("%s/%s/%s.json", parts[0], parts[1], parts[2])
+		path := recordPath(parts[0], parts[1], parts[2]+".json", fanoutOf(parts[0], parts[1]))
 		if err := util.WriteFile(wt.Filesystem, path, data, 0644); err != nil {
 			return Transaction{}, err
 		}
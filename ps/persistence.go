@@ -8,7 +8,9 @@ import (
 	"github.com/go-git/go-billy/v6/memfs"
 	"github.com/go-git/go-billy/v6/osfs"
 	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/cache"
+	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/go-git/go-git/v6/storage/filesystem"
 	"github.com/go-git/go-git/v6/storage/memory"
 )
@@ -16,13 +18,61 @@ import (
 var (
 	ErrNotInitialized = errors.New("persistence layer not initialized")
 	ErrRepoNotFound   = errors.New("repository not found")
+
+	// Lookups of missing objects wrap these, so callers can test with errors.Is
+	ErrDatabaseNotFound = errors.New("database not found")
+	ErrTableNotFound    = errors.New("table not found")
+	ErrViewNotFound     = errors.New("view not found")
+	ErrTriggerNotFound  = errors.New("trigger not found")
 )
 
+// isNotFound reports whether err from reading the repository means the path
+// looked up does not exist, rather than that the repository could not be read.
+func isNotFound(err error) bool {
+	return errors.Is(err, object.ErrFileNotFound) || errors.Is(err, object.ErrDirectoryNotFound) ||
+		errors.Is(err, object.ErrEntryNotFound)
+}
+
 type Persistence struct {
+	*shared
 	repo         *git.Repository
+	isMemoryMode bool         // True for memory-only persistence (skip worktree sync)
+	wbMu         sync.Mutex   // Guards writeBehind; taken before mu
+	writeBehind  *writeBuffer // Buffered record writes, nil unless write-behind is enabled
+	release      func()       // Releases the share read lock of an opened share
+}
+
+// shared is the state a Persistence has in common with its sessions
+type shared struct {
 	mu           sync.RWMutex
 	pendingMerge *PendingMerge // For manual conflict resolution
-	isMemoryMode bool          // True for memory-only persistence (skip worktree sync)
+
+	shareMu    sync.Mutex               // Guards shareLocks
+	shareLocks map[string]*sync.RWMutex // Per-share locks: reads shared, syncs exclusive
+
+	hookMu        sync.Mutex    // Guards the fields below; never held while hooks run
+	commitHooks   []CommitHook  // Called after commits that change records
+	pendingEvents []CommitEvent // Commits waiting for dispatchCommitEvents
+	dispatching   bool          // A dispatchCommitEvents call is delivering events
+
+	statsMu    sync.Mutex                 // Guards tableStats
+	tableStats map[string]tableStatsCache // Last TableStats result by database.table
+
+	fanoutMu sync.Mutex            // Guards fanouts
+	fanouts  map[plumbing.Hash]int // Record fan-out by table metadata blob hash
+}
+
+// Session returns a Persistence for the same repository with its own
+// write-behind buffer. Commits, locks, merges and commit hooks are shared
+// with p, but writes buffered by the session are only visible through it
+// until they are flushed, and flushing or discarding them leaves p's buffer
+// alone. Write-behind starts disabled in the session.
+func (p *Persistence) Session() *Persistence {
+	return &Persistence{
+		shared:       p.shared,
+		repo:         p.repo,
+		isMemoryMode: p.isMemoryMode,
+	}
 }
 
 // IsInitialized returns true if the persistence layer has a valid repository
@@ -30,6 +80,22 @@ func (p *Persistence) IsInitialized() bool {
 	return p != nil && p.repo != nil
 }
 
+// Repository returns the underlying go-git repository for Git operations the
+// SQL surface does not cover, such as custom refs or garbage collection. It
+// is nil if the persistence is not initialized. For memory persistence the
+// repository lives in memory and has no files on disk to gc or hook into.
+//
+// This is an advanced and unsafe escape hatch: CommitDB does not see changes
+// made through the repository until it next reads HEAD, and moving HEAD or
+// rewriting branches it manages can lose data. Call Flush first so buffered
+// writes are committed, and hold Lock while changing the repository.
+func (p *Persistence) Repository() *git.Repository {
+	if p == nil {
+		return nil
+	}
+	return p.repo
+}
+
 // ensureInitialized checks if the persistence layer is initialized and returns an error if not
 func (p *Persistence) ensureInitialized() error {
 	if !p.IsInitialized() {
@@ -68,6 +134,7 @@ func NewMemoryPersistence() (Persistence, error) {
 	}
 
 	return Persistence{
+		shared:       &shared{},
 		repo:         repo,
 		isMemoryMode: true, // Skip worktree sync for better performance
 	}, nil
@@ -117,6 +184,7 @@ func NewFilePersistence(baseDir string, gitUrl *string) (Persistence, error) {
 	}
 
 	return Persistence{
-		repo: repo,
+		shared: &shared{},
+		repo:   repo,
 	}, nil
 }
@@ -1,8 +1,12 @@
 package ps
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/nickyhof/CommitDB/core"
 )
 
@@ -30,6 +34,53 @@ func TestPersistenceNotInitialized(t *testing.T) {
 	}
 }
 
+func TestRepository(t *testing.T) {
+	identity := core.Identity{Name: "test", Email: "test@test.com"}
+
+	persistence, err := NewFilePersistence(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("Failed to create file persistence: %v", err)
+	}
+	txn, err := persistence.CreateDatabase(core.Database{Name: "testdb"}, identity)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+
+	repo := persistence.Repository()
+	if repo == nil {
+		t.Fatal("Expected a repository for file persistence")
+	}
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatalf("Failed to read HEAD: %v", err)
+	}
+	if head.Hash().String() != txn.Id {
+		t.Errorf("Expected HEAD %s, got %s", txn.Id, head.Hash())
+	}
+
+	// Refs created through the repository are stored alongside CommitDB's
+	ref := plumbing.NewHashReference("refs/custom/checkpoint", head.Hash())
+	if err := repo.Storer.SetReference(ref); err != nil {
+		t.Fatalf("Failed to set custom ref: %v", err)
+	}
+	if got, err := repo.Reference(ref.Name(), true); err != nil || got.Hash() != head.Hash() {
+		t.Errorf("Expected custom ref at %s, got %v (%v)", head.Hash(), got, err)
+	}
+
+	memoryPersistence, err := NewMemoryPersistence()
+	if err != nil {
+		t.Fatalf("Failed to create memory persistence: %v", err)
+	}
+	if memoryPersistence.Repository() == nil {
+		t.Error("Expected an in-memory repository for memory persistence")
+	}
+
+	var uninitialized Persistence
+	if uninitialized.Repository() != nil {
+		t.Error("Expected no repository for uninitialized persistence")
+	}
+}
+
 func TestCreateAndGetDatabase(t *testing.T) {
 	persistence, err := NewMemoryPersistence()
 	if err != nil {
@@ -101,6 +152,43 @@ func TestCreateAndGetTable(t *testing.T) {
 	}
 }
 
+func TestGetTableReadError(t *testing.T) {
+	identity := core.Identity{Name: "test", Email: "test@test.com"}
+	dir := t.TempDir()
+
+	persistence, err := NewFilePersistence(dir, nil)
+	if err != nil {
+		t.Fatalf("Failed to create file persistence: %v", err)
+	}
+	persistence.CreateDatabase(core.Database{Name: "testdb"}, identity)
+	txn, err := persistence.CreateTable(core.Table{
+		Database: "testdb",
+		Name:     "users",
+		Columns:  []core.Column{{Name: "id", Type: core.IntType, PrimaryKey: true}},
+	}, identity)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	if _, err := persistence.GetTable("testdb", "missing"); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("Expected ErrTableNotFound for a missing table, got %v", err)
+	}
+
+	// Losing the HEAD commit is a storage failure, not a missing table
+	object := filepath.Join(dir, ".git", "objects", txn.Id[:2], txn.Id[2:])
+	if err := os.Remove(object); err != nil {
+		t.Fatalf("Failed to remove commit object: %v", err)
+	}
+	reopened, err := NewFilePersistence(dir, nil)
+	if err != nil {
+		t.Fatalf("Failed to reopen persistence: %v", err)
+	}
+	_, err = reopened.GetTable("testdb", "users")
+	if err == nil || errors.Is(err, ErrTableNotFound) {
+		t.Errorf("Expected a read error other than ErrTableNotFound, got %v", err)
+	}
+}
+
 func TestSaveAndGetRecord(t *testing.T) {
 	persistence, err := NewMemoryPersistence()
 	if err != nil {
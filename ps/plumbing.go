@@ -2,8 +2,11 @@ package ps
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"path"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -323,7 +326,7 @@ func (p *Persistence) batchUpdateTree(rootTreeHash plumbing.Hash, changes []Tree
 
 // createCommitDirect creates a commit object directly without using worktree.
 // If the new tree hash is identical to the current HEAD's tree hash, no commit
-// is created and an empty Transaction is returned (avoiding empty commits).
+// is created and an Unchanged Transaction is returned (avoiding empty commits).
 func (p *Persistence) createCommitDirect(treeHash plumbing.Hash, identity core.Identity, message string) (Transaction, error) {
 	// Handle empty tree case - create an actual empty tree object
 	actualTreeHash := treeHash
@@ -349,8 +352,8 @@ func (p *Persistence) createCommitDirect(treeHash plumbing.Hash, identity core.I
 		// Compare with current tree - skip commit if no changes
 		currentTreeHash, err := p.getCurrentTree()
 		if err == nil && currentTreeHash == actualTreeHash {
-			// No changes - return empty transaction without creating commit
-			return Transaction{}, nil
+			// No changes - report it without creating a commit
+			return Transaction{Unchanged: true}, nil
 		}
 	}
 
@@ -410,6 +413,12 @@ func (p *Persistence) SaveRecordDirect(database, table string, records map[strin
 		return Transaction{}, err
 	}
 
+	// Buffered writes go first so they are not lost under this commit
+	if _, err := p.Flush(); err != nil {
+		return Transaction{}, err
+	}
+
+	defer p.dispatchCommitEvents()
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -419,6 +428,8 @@ func (p *Persistence) SaveRecordDirect(database, table string, records map[strin
 		return Transaction{}, err
 	}
 
+	fanout := p.tableFanoutAt(currentTree, database, table)
+
 	// Build list of changes
 	changes := make([]TreeChange, 0, len(records))
 	for key, data := range records {
@@ -429,7 +440,7 @@ func (p *Persistence) SaveRecordDirect(database, table string, records map[strin
 		}
 
 		changes = append(changes, TreeChange{
-			Path:     path.Join(database, table, key),
+			Path:     recordPath(database, table, key, fanout),
 			BlobHash: blobHash,
 			IsDelete: false,
 		})
@@ -446,6 +457,7 @@ func (p *Persistence) SaveRecordDirect(database, table string, records map[strin
 	if err != nil {
 		return Transaction{}, err
 	}
+	p.queueCommitEvent(txn, recordOperations(WriteOp, database, table, slices.Collect(maps.Keys(records))))
 
 	// Sync worktree to match the new commit (for read compatibility)
 	if err := p.syncWorktree(); err != nil {
@@ -526,7 +538,7 @@ func (p *Persistence) getRecordDirectUnlocked(database, table, key string) ([]by
 		return nil, false
 	}
 
-	filePath := path.Join(database, table, key)
+	filePath := recordPath(database, table, key, p.tableFanout(tree, database, table))
 	file, err := tree.File(filePath)
 	if err != nil {
 		return nil, false
@@ -565,7 +577,7 @@ func (p *Persistence) GetRecordDirect(database, table, key string) ([]byte, bool
 	}
 
 	// Navigate to database/table/key
-	filePath := path.Join(database, table, key)
+	filePath := recordPath(database, table, key, p.tableFanout(tree, database, table))
 	file, err := tree.File(filePath)
 	if err != nil {
 		return nil, false
@@ -585,6 +597,12 @@ func (p *Persistence) DeleteRecordDirect(database, table, key string, identity c
 		return Transaction{}, err
 	}
 
+	// Buffered writes go first so they are not lost under this commit
+	if _, err := p.Flush(); err != nil {
+		return Transaction{}, err
+	}
+
+	defer p.dispatchCommitEvents()
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -599,8 +617,8 @@ func (p *Persistence) DeleteRecordDirect(database, table, key string, identity c
 	}
 
 	// Delete from tree
-	recordPath := path.Join(database, table, key)
-	newTree, err := p.deleteTreePath(currentTree, recordPath)
+	filePath := recordPath(database, table, key, p.tableFanoutAt(currentTree, database, table))
+	newTree, err := p.deleteTreePath(currentTree, filePath)
 	if err != nil {
 		return Transaction{}, fmt.Errorf("failed to delete from tree: %w", err)
 	}
@@ -610,6 +628,65 @@ func (p *Persistence) DeleteRecordDirect(database, table, key string, identity c
 	if err != nil {
 		return Transaction{}, err
 	}
+	p.queueCommitEvent(txn, recordOperations(DeleteOp, database, table, []string{key}))
+
+	// Sync worktree
+	if err := p.syncWorktree(); err != nil {
+		return Transaction{}, fmt.Errorf("failed to sync worktree: %w", err)
+	}
+
+	return txn, nil
+}
+
+// DeleteRecordsDirect deletes records using low-level plumbing API
+// Uses batch tree update to remove every key in a single commit
+func (p *Persistence) DeleteRecordsDirect(database, table string, keys []string, identity core.Identity) (Transaction, error) {
+	if err := p.ensureInitialized(); err != nil {
+		return Transaction{}, err
+	}
+
+	// Buffered writes go first so they are not lost under this commit
+	if _, err := p.Flush(); err != nil {
+		return Transaction{}, err
+	}
+
+	defer p.dispatchCommitEvents()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Get current tree
+	currentTree, err := p.getCurrentTree()
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	if currentTree == plumbing.ZeroHash {
+		return Transaction{}, fmt.Errorf("no records exist")
+	}
+
+	fanout := p.tableFanoutAt(currentTree, database, table)
+
+	// Build list of deletions
+	changes := make([]TreeChange, 0, len(keys))
+	for _, key := range keys {
+		changes = append(changes, TreeChange{
+			Path:     recordPath(database, table, key, fanout),
+			IsDelete: true,
+		})
+	}
+
+	// Apply all changes in single tree operation
+	newTree, err := p.batchUpdateTree(currentTree, changes)
+	if err != nil {
+		return Transaction{}, fmt.Errorf("failed to update tree: %w", err)
+	}
+
+	// Create commit
+	txn, err := p.createCommitDirect(newTree, identity, "Deleting record(s)")
+	if err != nil {
+		return Transaction{}, err
+	}
+	p.queueCommitEvent(txn, recordOperations(DeleteOp, database, table, keys))
 
 	// Sync worktree
 	if err := p.syncWorktree(); err != nil {
@@ -626,25 +703,26 @@ func (p *Persistence) CopyRecordsDirect(srcDatabase, srcTable, dstDatabase, dstT
 		return Transaction{}, err
 	}
 
+	// Buffered writes go first so they are not lost under this commit
+	if _, err := p.Flush(); err != nil {
+		return Transaction{}, err
+	}
+
+	defer p.dispatchCommitEvents()
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	// Get source keys using internal unlocked method
-	srcPath := fmt.Sprintf("%s/%s", srcDatabase, srcTable)
-	entries, err := p.listEntriesDirectUnlocked(srcPath)
+	headTree, err := p.headTree()
 	if err != nil {
-		return Transaction{}, nil // Nothing to copy
+		return Transaction{}, err
 	}
-
-	var keys []string
-	for _, entry := range entries {
-		if !entry.IsDir {
-			keys = append(keys, entry.Name)
-		}
+	if headTree == nil {
+		return Transaction{Unchanged: true}, nil // Nothing to copy
 	}
 
-	if len(keys) == 0 {
-		return Transaction{}, nil // Nothing to copy
+	records := tableRecords(headTree, srcDatabase, srcTable)
+	if len(records) == 0 {
+		return Transaction{Unchanged: true}, nil // Nothing to copy
 	}
 
 	// Get current tree
@@ -653,24 +731,13 @@ func (p *Persistence) CopyRecordsDirect(srcDatabase, srcTable, dstDatabase, dstT
 		return Transaction{}, err
 	}
 
-	// Build list of changes
-	changes := make([]TreeChange, 0, len(keys))
-	for _, key := range keys {
-		// Use internal unlocked method
-		data, exists := p.getRecordDirectUnlocked(srcDatabase, srcTable, key)
-		if !exists {
-			continue
-		}
-
-		// Create blob
-		blobHash, err := p.createBlob(data)
-		if err != nil {
-			return Transaction{}, fmt.Errorf("failed to create blob for %s: %w", key, err)
-		}
-
+	// Blobs are content-addressed, so copies reuse the source blob hashes
+	dstFanout := p.tableFanout(headTree, dstDatabase, dstTable)
+	changes := make([]TreeChange, 0, len(records))
+	for _, record := range records {
 		changes = append(changes, TreeChange{
-			Path:     path.Join(dstDatabase, dstTable, key),
-			BlobHash: blobHash,
+			Path:     recordPath(dstDatabase, dstTable, record.Key, dstFanout),
+			BlobHash: record.Hash,
 			IsDelete: false,
 		})
 	}
@@ -686,6 +753,11 @@ func (p *Persistence) CopyRecordsDirect(srcDatabase, srcTable, dstDatabase, dstT
 	if err != nil {
 		return Transaction{}, err
 	}
+	copied := make([]string, len(records))
+	for i, record := range records {
+		copied[i] = record.Key
+	}
+	p.queueCommitEvent(txn, recordOperations(WriteOp, dstDatabase, dstTable, copied))
 
 	// Sync worktree
 	if err := p.syncWorktree(); err != nil {
@@ -701,6 +773,11 @@ func (p *Persistence) WriteFileDirect(filePath string, data []byte, identity cor
 		return Transaction{}, err
 	}
 
+	// Buffered writes go first so they are not lost under this commit
+	if _, err := p.Flush(); err != nil {
+		return Transaction{}, err
+	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -742,6 +819,11 @@ func (p *Persistence) DeletePathDirect(paths []string, identity core.Identity, m
 		return Transaction{}, err
 	}
 
+	// Buffered writes go first so they are not lost under this commit
+	if _, err := p.Flush(); err != nil {
+		return Transaction{}, err
+	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -778,6 +860,57 @@ func (p *Persistence) DeletePathDirect(paths []string, identity core.Identity, m
 	return txn, nil
 }
 
+// replacePathsDirect deletes paths and then writes files, by path, in a
+// single commit. Deleting a path that does not exist is not an error.
+func (p *Persistence) replacePathsDirect(deletes []string, files map[string][]byte, identity core.Identity, message string) (Transaction, error) {
+	if err := p.ensureInitialized(); err != nil {
+		return Transaction{}, err
+	}
+
+	// Buffered writes go first so they are not lost under this commit
+	if _, err := p.Flush(); err != nil {
+		return Transaction{}, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	newTree, err := p.getCurrentTree()
+	if err != nil {
+		return Transaction{}, err
+	}
+	for _, filePath := range deletes {
+		if newTree == plumbing.ZeroHash {
+			break
+		}
+		newTree, err = p.deleteTreePath(newTree, filePath)
+		if err != nil {
+			return Transaction{}, fmt.Errorf("failed to delete %s: %w", filePath, err)
+		}
+	}
+	for filePath, data := range files {
+		blobHash, err := p.createBlob(data)
+		if err != nil {
+			return Transaction{}, fmt.Errorf("failed to create blob: %w", err)
+		}
+		newTree, err = p.updateTreePath(newTree, filePath, blobHash)
+		if err != nil {
+			return Transaction{}, fmt.Errorf("failed to update tree: %w", err)
+		}
+	}
+
+	txn, err := p.createCommitDirect(newTree, identity, message)
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	if err := p.syncWorktree(); err != nil {
+		return Transaction{}, fmt.Errorf("failed to sync worktree: %w", err)
+	}
+
+	return txn, nil
+}
+
 // ReadFileDirect reads a file directly from the Git tree (bypasses worktree filesystem)
 func (p *Persistence) ReadFileDirect(filePath string) ([]byte, error) {
 	if !p.IsInitialized() {
@@ -787,9 +920,12 @@ func (p *Persistence) ReadFileDirect(filePath string) ([]byte, error) {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
+	// Without commits no file exists yet
 	headRef, err := p.repo.Head()
-	if err != nil {
-		return nil, fmt.Errorf("no commits yet")
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, fmt.Errorf("no commits yet: %w", object.ErrFileNotFound)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read HEAD: %w", err)
 	}
 
 	commit, err := p.repo.CommitObject(headRef.Hash())
@@ -815,6 +951,27 @@ func (p *Persistence) ReadFileDirect(filePath string) ([]byte, error) {
 	return []byte(content), nil
 }
 
+// readFileAt reads a file from the tree with the given hash. Callers hold
+// the lock.
+func (p *Persistence) readFileAt(treeHash plumbing.Hash, filePath string) ([]byte, error) {
+	if treeHash == plumbing.ZeroHash {
+		return nil, object.ErrFileNotFound
+	}
+	tree, err := p.repo.TreeObject(treeHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree: %w", err)
+	}
+	file, err := tree.File(filePath)
+	if err != nil {
+		return nil, err
+	}
+	content, err := file.Contents()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read contents: %w", err)
+	}
+	return []byte(content), nil
+}
+
 // TreeEntry represents a directory entry from the Git tree
 type TreeEntry struct {
 	Name  string
@@ -962,7 +1119,7 @@ func (p *Persistence) GetRecordAtTransaction(database, table, key, transactionID
 	}
 
 	// Navigate to database/table/key
-	filePath := path.Join(database, table, key)
+	filePath := recordPath(database, table, key, p.tableFanout(tree, database, table))
 	file, err := tree.File(filePath)
 	if err != nil {
 		return nil, false, nil // Record doesn't exist at this transaction
@@ -995,18 +1152,9 @@ func (p *Persistence) ListRecordsAtTransaction(database, table, transactionID st
 		return nil, fmt.Errorf("failed to get tree: %w", err)
 	}
 
-	// Navigate to database/table directory
-	dirPath := path.Join(database, table)
-	tableTree, err := tree.Tree(dirPath)
-	if err != nil {
-		return nil, nil // Table doesn't exist at this transaction
-	}
-
 	var keys []string
-	for _, entry := range tableTree.Entries {
-		if entry.Mode.IsFile() {
-			keys = append(keys, entry.Name)
-		}
+	for _, record := range tableRecords(tree, database, table) {
+		keys = append(keys, record.Key)
 	}
 
 	return keys, nil
@@ -1034,8 +1182,10 @@ func (p *Persistence) GetTableAtTransaction(database, table, transactionID strin
 	// Read table metadata from database/table.table (matches crud.go storage pattern)
 	metaPath := path.Join(database, table+".table")
 	file, err := tree.File(metaPath)
-	if err != nil {
-		return nil, fmt.Errorf("table not found at transaction %s", transactionID)
+	if isNotFound(err) {
+		return nil, fmt.Errorf("%w at transaction %s", ErrTableNotFound, transactionID)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read table metadata: %w", err)
 	}
 
 	content, err := file.Contents()
@@ -1,6 +1,7 @@
 package ps
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/nickyhof/CommitDB/core"
@@ -142,3 +143,127 @@ func TestPlumbingMultipleTables(t *testing.T) {
 		t.Error("table2 record missing or incorrect")
 	}
 }
+
+func TestPlumbingFanoutLayout(t *testing.T) {
+	p, err := NewMemoryPersistence()
+	if err != nil {
+		t.Fatalf("Failed to create persistence: %v", err)
+	}
+
+	identity := core.Identity{Name: "Test User", Email: "test@example.com"}
+
+	table := core.Table{
+		Database: "testdb",
+		Name:     "wide",
+		Columns:  []core.Column{{Name: "id", Type: core.IntType, PrimaryKey: true}},
+		Fanout:   2,
+	}
+	if _, err := p.CreateTable(table, identity); err != nil {
+		t.Fatalf("CreateTable failed: %v", err)
+	}
+
+	records := map[string][]byte{}
+	for i := 0; i < 20; i++ {
+		records[fmt.Sprint(i)] = []byte(fmt.Sprintf(`{"id":"%d"}`, i))
+	}
+	if _, err := p.SaveRecordDirect("testdb", "wide", records, identity); err != nil {
+		t.Fatalf("SaveRecordDirect failed: %v", err)
+	}
+
+	// Rows live under two levels of hash-prefix directories
+	entries, _ := p.ListEntriesDirect("testdb/wide")
+	for _, entry := range entries {
+		if !entry.IsDir || len(entry.Name) != 2 {
+			t.Errorf("Expected only fan-out directories in table tree, got %q", entry.Name)
+		}
+	}
+	if _, err := p.ReadFileDirect(recordPath("testdb", "wide", "7", 2)); err != nil {
+		t.Errorf("Expected record at fan-out path: %v", err)
+	}
+
+	// Reads, scans and deletes resolve the layout transparently
+	data, exists := p.GetRecord("testdb", "wide", "7")
+	if !exists || string(data) != `{"id":"7"}` {
+		t.Errorf("GetRecord mismatch: %s", data)
+	}
+	if keys := p.ListRecordKeys("testdb", "wide"); len(keys) != 20 {
+		t.Errorf("Expected 20 keys, got %d", len(keys))
+	}
+	scanned := 0
+	for key, value := range p.Scan("testdb", "wide", nil) {
+		if string(value) != string(records[key]) {
+			t.Errorf("Scan mismatch for %s: %s", key, value)
+		}
+		scanned++
+	}
+	if scanned != 20 {
+		t.Errorf("Expected to scan 20 records, got %d", scanned)
+	}
+
+	// The schema is parsed once for all of those lookups
+	for i := 0; i < 20; i++ {
+		p.GetRecord("testdb", "wide", fmt.Sprint(i))
+	}
+	if len(p.fanouts) != 1 {
+		t.Errorf("Expected one cached fan-out, got %d", len(p.fanouts))
+	}
+
+	if _, err := p.DeleteRecord("testdb", "wide", "7", identity); err != nil {
+		t.Fatalf("DeleteRecord failed: %v", err)
+	}
+	if _, exists := p.GetRecord("testdb", "wide", "7"); exists {
+		t.Error("Record should be deleted")
+	}
+
+	table.Name = "toodeep"
+	table.Fanout = MaxFanout + 1
+	if _, err := p.CreateTable(table, identity); err == nil {
+		t.Error("Expected error for fanout above MaxFanout")
+	}
+}
+
+// BenchmarkBatchUpdateTree measures a single-row update in a large table with
+// the flat layout and with fan-out directories.
+func BenchmarkBatchUpdateTree(b *testing.B) {
+	const rows = 20000
+
+	for _, fanout := range []int{0, 1, 2} {
+		b.Run(fmt.Sprintf("fanout=%d", fanout), func(b *testing.B) {
+			p, err := NewMemoryPersistence()
+			if err != nil {
+				b.Fatalf("Failed to create persistence: %v", err)
+			}
+			identity := core.Identity{Name: "Bench", Email: "bench@example.com"}
+
+			table := core.Table{Database: "benchdb", Name: "rows", Fanout: fanout}
+			if _, err := p.CreateTable(table, identity); err != nil {
+				b.Fatalf("CreateTable failed: %v", err)
+			}
+			records := make(map[string][]byte, rows)
+			for i := 0; i < rows; i++ {
+				records[fmt.Sprint(i)] = []byte(fmt.Sprintf(`{"id":"%d"}`, i))
+			}
+			if _, err := p.SaveRecordDirect("benchdb", "rows", records, identity); err != nil {
+				b.Fatalf("SaveRecordDirect failed: %v", err)
+			}
+
+			rootTree, err := p.getCurrentTree()
+			if err != nil {
+				b.Fatalf("getCurrentTree failed: %v", err)
+			}
+			blobHash, err := p.createBlob([]byte(`{"id":"updated"}`))
+			if err != nil {
+				b.Fatalf("createBlob failed: %v", err)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				key := fmt.Sprint(i % rows)
+				changes := []TreeChange{{Path: recordPath("benchdb", "rows", key, fanout), BlobHash: blobHash}}
+				if _, err := p.batchUpdateTree(rootTree, changes); err != nil {
+					b.Fatalf("batchUpdateTree failed: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -135,6 +135,9 @@ func (p *Persistence) Push(remoteName, branch string, auth *RemoteAuth) error {
 	if err := p.ensureInitialized(); err != nil {
 		return err
 	}
+	if _, err := p.Flush(); err != nil {
+		return err
+	}
 
 	// Default to origin if not specified
 	if remoteName == "" {
@@ -177,6 +180,9 @@ func (p *Persistence) Pull(remoteName, branch string, auth *RemoteAuth) error {
 	if err := p.ensureInitialized(); err != nil {
 		return err
 	}
+	if _, err := p.Flush(); err != nil {
+		return err
+	}
 
 	// Default to origin if not specified
 	if remoteName == "" {
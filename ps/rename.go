@@ -0,0 +1,224 @@
+package ps
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/nickyhof/CommitDB/core"
+)
+
+// RenameDatabase moves database oldName, with its tables, rows, indexes,
+// views, materialized view data and triggers, to newName in a single commit.
+// Row blobs are reused as they are; the database, table, index, view and
+// trigger metadata is rewritten to name newName. rewrite, when not nil, is
+// applied to every view query and trigger body in the repository, so
+// definitions that refer to the database by name follow it.
+func (p *Persistence) RenameDatabase(oldName, newName string, rewrite func(definition string) string, identity core.Identity) (Transaction, error) {
+	if err := p.ensureInitialized(); err != nil {
+		return Transaction{}, err
+	}
+	if oldName == newName {
+		return Transaction{}, fmt.Errorf("database %s is already named %s", oldName, newName)
+	}
+	if rewrite == nil {
+		rewrite = func(definition string) string { return definition }
+	}
+
+	// Buffered writes go first so they are not lost under this commit
+	if _, err := p.Flush(); err != nil {
+		return Transaction{}, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	headTree, err := p.headTree()
+	if err != nil {
+		return Transaction{}, err
+	}
+	if headTree == nil || !databaseExists(headTree, oldName) {
+		return Transaction{}, fmt.Errorf("%w: %s", ErrDatabaseNotFound, oldName)
+	}
+	if databaseExists(headTree, newName) {
+		return Transaction{}, fmt.Errorf("database %s already exists", newName)
+	}
+
+	currentTree, err := p.getCurrentTree()
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	var changes []TreeChange
+	write := func(filePath string, data []byte) error {
+		blobHash, err := p.createBlob(data)
+		if err != nil {
+			return fmt.Errorf("failed to create blob: %w", err)
+		}
+		changes = append(changes, TreeChange{Path: filePath, BlobHash: blobHash})
+		return nil
+	}
+	remove := func(filePath string) {
+		changes = append(changes, TreeChange{Path: filePath, IsDelete: true})
+	}
+
+	// The database marker file
+	databaseData, err := json.Marshal(core.Database{Name: newName})
+	if err != nil {
+		return Transaction{}, fmt.Errorf("failed to marshal database: %w", err)
+	}
+	if err := write(newName+".database", databaseData); err != nil {
+		return Transaction{}, err
+	}
+	remove(oldName + ".database")
+
+	// Tables, indexes and rows. Metadata names its database; rows do not.
+	err = forEachFile(headTree, oldName, func(name string, hash plumbing.Hash) error {
+		target := path.Join(newName, name)
+		switch {
+		case strings.HasSuffix(name, ".table"):
+			table, err := decodeBlob[core.Table](p, hash)
+			if err != nil {
+				return fmt.Errorf("failed to read table %s: %w", name, err)
+			}
+			table.Database = newName
+			data, err := json.Marshal(table)
+			if err != nil {
+				return err
+			}
+			return write(target, data)
+		case !strings.Contains(name, "/") && strings.Contains(name, ".index."):
+			idx, err := decodeBlob[Index](p, hash)
+			if err != nil {
+				return fmt.Errorf("failed to read index %s: %w", name, err)
+			}
+			idx.Database = newName
+			data, err := json.Marshal(idx)
+			if err != nil {
+				return err
+			}
+			return write(target, data)
+		default:
+			changes = append(changes, TreeChange{Path: target, BlobHash: hash})
+			return nil
+		}
+	})
+	if err != nil {
+		return Transaction{}, err
+	}
+	remove(oldName)
+
+	// View definitions of the database move; a view anywhere whose query
+	// names the database is rewritten in place
+	err = forEachFile(headTree, ".commitdb/views", func(name string, hash plumbing.Hash) error {
+		view, err := decodeBlob[core.View](p, hash)
+		if err != nil {
+			return fmt.Errorf("failed to read view %s: %w", name, err)
+		}
+		query := rewrite(view.Query)
+		if view.Database != oldName && query == view.Query {
+			return nil
+		}
+		view.Query = query
+		if view.Database == oldName {
+			view.Database = newName
+		}
+		data, err := json.MarshalIndent(view, "", "  ")
+		if err != nil {
+			return err
+		}
+		return write(path.Join(".commitdb/views", view.Database, view.Name+".json"), data)
+	})
+	if err != nil {
+		return Transaction{}, err
+	}
+	remove(path.Join(".commitdb/views", oldName))
+
+	// Materialized view data is moved unchanged
+	err = forEachFile(headTree, path.Join(".commitdb/materialized", oldName), func(name string, hash plumbing.Hash) error {
+		changes = append(changes, TreeChange{Path: path.Join(".commitdb/materialized", newName, name), BlobHash: hash})
+		return nil
+	})
+	if err != nil {
+		return Transaction{}, err
+	}
+	remove(path.Join(".commitdb/materialized", oldName))
+
+	// Triggers are handled like views
+	err = forEachFile(headTree, ".commitdb/triggers", func(name string, hash plumbing.Hash) error {
+		trigger, err := decodeBlob[core.Trigger](p, hash)
+		if err != nil {
+			return fmt.Errorf("failed to read trigger %s: %w", name, err)
+		}
+		body := rewrite(trigger.Body)
+		if trigger.Database != oldName && body == trigger.Body {
+			return nil
+		}
+		trigger.Body = body
+		if trigger.Database == oldName {
+			trigger.Database = newName
+		}
+		data, err := json.MarshalIndent(trigger, "", "  ")
+		if err != nil {
+			return err
+		}
+		return write(triggerPath(trigger.Database, trigger.Name), data)
+	})
+	if err != nil {
+		return Transaction{}, err
+	}
+	remove(path.Join(".commitdb/triggers", oldName))
+
+	// Apply all changes in single tree operation
+	newTree, err := p.batchUpdateTree(currentTree, changes)
+	if err != nil {
+		return Transaction{}, fmt.Errorf("failed to update tree: %w", err)
+	}
+
+	txn, err := p.createCommitDirect(newTree, identity, fmt.Sprintf("Renaming database %s to %s", oldName, newName))
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	// Sync worktree
+	if err := p.syncWorktree(); err != nil {
+		return Transaction{}, fmt.Errorf("failed to sync worktree: %w", err)
+	}
+
+	return txn, nil
+}
+
+// databaseExists reports whether tree has a database's marker file or directory.
+func databaseExists(tree *object.Tree, name string) bool {
+	if _, err := tree.File(name + ".database"); err == nil {
+		return true
+	}
+	_, err := tree.Tree(name)
+	return err == nil
+}
+
+// forEachFile calls fn with the path, relative to dir, and blob hash of every
+// file under dir in tree. A missing dir has no files.
+func forEachFile(tree *object.Tree, dir string, fn func(name string, hash plumbing.Hash) error) error {
+	dirTree, err := tree.Tree(dir)
+	if err != nil {
+		return nil
+	}
+	return dirTree.Files().ForEach(func(f *object.File) error {
+		return fn(f.Name, f.Hash)
+	})
+}
+
+// decodeBlob reads the JSON document stored in a blob.
+func decodeBlob[T any](p *Persistence, hash plumbing.Hash) (T, error) {
+	var value T
+	data, err := p.readBlob(hash)
+	if err != nil {
+		return value, err
+	}
+	err = json.Unmarshal(data, &value)
+	return value, err
+}
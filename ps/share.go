@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/go-git/go-billy/v6/osfs"
 	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/cache"
 	"github.com/go-git/go-git/v6/storage/filesystem"
 	"github.com/nickyhof/CommitDB/core"
@@ -82,7 +84,8 @@ func (p *Persistence) CreateShare(name, url string, auth *RemoteAuth, identity c
 	return p.saveShare(share, identity)
 }
 
-// SyncShare pulls latest changes from the share's remote
+// SyncShare pulls latest changes from the share's remote. It waits for open
+// readers of the share to close, and blocks new ones until it is done.
 func (p *Persistence) SyncShare(name string, auth *RemoteAuth) error {
 	if err := p.ensureInitialized(); err != nil {
 		return err
@@ -92,6 +95,10 @@ func (p *Persistence) SyncShare(name string, auth *RemoteAuth) error {
 		return fmt.Errorf("shares are not supported in memory mode")
 	}
 
+	lock := p.shareLock(name)
+	lock.Lock()
+	defer lock.Unlock()
+
 	// Get share info
 	shares, err := p.ListShares()
 	if err != nil {
@@ -140,15 +147,31 @@ func (p *Persistence) SyncShare(name string, auth *RemoteAuth) error {
 		return fmt.Errorf("failed to configure auth: %w", err)
 	}
 
-	err = wt.Pull(&git.PullOptions{
+	// Shares are read-only mirrors, so move the share to the remote branch
+	// rather than pulling: a pull refuses to run when the clone's worktree
+	// was not fully checked out.
+	err = shareRepo.Fetch(&git.FetchOptions{
 		Auth: authMethod,
 	})
-	if err == git.NoErrAlreadyUpToDate {
-		return nil
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return fmt.Errorf("failed to sync share '%s': %w", name, err)
+	}
+
+	head, err := shareRepo.Head()
+	if err != nil {
+		return fmt.Errorf("failed to sync share '%s': %w", name, err)
 	}
+	remoteRef, err := shareRepo.Reference(plumbing.NewRemoteReferenceName("origin", head.Name().Short()), true)
 	if err != nil {
 		return fmt.Errorf("failed to sync share '%s': %w", name, err)
 	}
+	if remoteRef.Hash() == head.Hash() {
+		return nil
+	}
+
+	if err := wt.Reset(&git.ResetOptions{Mode: git.HardReset, Commit: remoteRef.Hash()}); err != nil {
+		return fmt.Errorf("failed to sync share '%s': %w", name, err)
+	}
 
 	return nil
 }
@@ -163,6 +186,10 @@ func (p *Persistence) DropShare(name string, identity core.Identity) error {
 		return fmt.Errorf("shares are not supported in memory mode")
 	}
 
+	lock := p.shareLock(name)
+	lock.Lock()
+	defer lock.Unlock()
+
 	// Get share path and remove the directory
 	sharePath, err := p.GetSharePath(name)
 	if err != nil {
@@ -296,8 +323,35 @@ func (p *Persistence) removeShare(name string, identity core.Identity) error {
 	return p.saveSharesConfig(config, identity)
 }
 
-// OpenSharePersistence opens a read-only persistence for a share's repository
-func (p *Persistence) OpenSharePersistence(shareName string) (*Persistence, error) {
+// shareLock returns the lock guarding a share's repository files
+func (p *Persistence) shareLock(name string) *sync.RWMutex {
+	p.shareMu.Lock()
+	defer p.shareMu.Unlock()
+
+	if p.shareLocks == nil {
+		p.shareLocks = make(map[string]*sync.RWMutex)
+	}
+	lock, ok := p.shareLocks[name]
+	if !ok {
+		lock = &sync.RWMutex{}
+		p.shareLocks[name] = lock
+	}
+	return lock
+}
+
+// OpenSharePersistence opens a read-only persistence for a share's repository.
+// It holds a read lock on the share, which keeps SyncShare and DropShare out
+// until Close is called. Open a share at most once per reader: a second open
+// while a sync is waiting blocks forever.
+func (p *Persistence) OpenSharePersistence(shareName string) (_ *Persistence, err error) {
+	lock := p.shareLock(shareName)
+	lock.RLock()
+	defer func() {
+		if err != nil {
+			lock.RUnlock()
+		}
+	}()
+
 	sharePath, err := p.GetSharePath(shareName)
 	if err != nil {
 		return nil, err
@@ -320,7 +374,18 @@ func (p *Persistence) OpenSharePersistence(shareName string) (*Persistence, erro
 	}
 
 	return &Persistence{
+		shared:       &shared{},
 		repo:         shareRepo,
 		isMemoryMode: false,
+		release:      lock.RUnlock,
 	}, nil
 }
+
+// Close releases a share opened with OpenSharePersistence. It does nothing
+// for other persistence and when called again.
+func (p *Persistence) Close() {
+	if p.release != nil {
+		p.release()
+		p.release = nil
+	}
+}
@@ -1,7 +1,10 @@
 package ps
 
 import (
+	"fmt"
 	"os"
+	"strconv"
+	"sync"
 	"testing"
 
 	"github.com/go-git/go-billy/v6/osfs"
@@ -399,3 +402,92 @@ func TestOpenSharePersistence(t *testing.T) {
 		t.Errorf("Expected to find 'testdb' in share, got: %v", databases)
 	}
 }
+
+func TestShareReadsDuringSync(t *testing.T) {
+	sourceDir, bareDir, cleanup := setupShareTestEnv(t)
+	defer cleanup()
+
+	targetDir, err := os.MkdirTemp("", "share-test-target-*")
+	if err != nil {
+		t.Fatalf("Failed to create target dir: %v", err)
+	}
+	defer os.RemoveAll(targetDir)
+
+	persistence, err := NewFilePersistence(targetDir, nil)
+	if err != nil {
+		t.Fatalf("Failed to create persistence: %v", err)
+	}
+	source, err := NewFilePersistence(sourceDir, nil)
+	if err != nil {
+		t.Fatalf("Failed to open source persistence: %v", err)
+	}
+
+	testIdentity := core.Identity{Name: "test", Email: "test@test.com"}
+	if err := persistence.CreateShare("myshare", bareDir, nil, testIdentity); err != nil {
+		t.Fatalf("CreateShare failed: %v", err)
+	}
+
+	const batches, batchSize = 5, 10
+	done := make(chan struct{})
+	errs := make(chan error, 100)
+
+	var readers sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				share, err := persistence.OpenSharePersistence("myshare")
+				if err != nil {
+					errs <- err
+					return
+				}
+				// Each sync adds whole batches; a partial count means a torn read
+				keys := share.ListRecordKeys("testdb", "users")
+				share.Close()
+				if len(keys)%batchSize != 0 {
+					errs <- fmt.Errorf("read %d rows, expected a multiple of %d", len(keys), batchSize)
+					return
+				}
+			}
+		}()
+	}
+
+	for b := 0; b < batches; b++ {
+		records := make(map[string][]byte)
+		for i := 0; i < batchSize; i++ {
+			key := strconv.Itoa(b*batchSize + i)
+			records[key] = []byte(`{"id":"` + key + `"}`)
+		}
+		if _, err := source.SaveRecord("testdb", "users", records, testIdentity); err != nil {
+			t.Fatalf("SaveRecord failed: %v", err)
+		}
+		if err := source.Push("origin", "", nil); err != nil {
+			t.Fatalf("Push failed: %v", err)
+		}
+		if err := persistence.SyncShare("myshare", nil); err != nil {
+			t.Fatalf("SyncShare failed: %v", err)
+		}
+	}
+	close(done)
+	readers.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	share, err := persistence.OpenSharePersistence("myshare")
+	if err != nil {
+		t.Fatalf("OpenSharePersistence failed: %v", err)
+	}
+	defer share.Close()
+	if keys := share.ListRecordKeys("testdb", "users"); len(keys) != batches*batchSize {
+		t.Errorf("Expected %d rows after the last sync, got %d", batches*batchSize, len(keys))
+	}
+}
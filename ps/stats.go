@@ -0,0 +1,210 @@
+package ps
+
+import (
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+	"github.com/go-git/go-git/v6/storage/filesystem"
+)
+
+// TableStats summarizes the storage of a table at HEAD.
+type TableStats struct {
+	Rows         int
+	Size         int64       // Sum of the record blob sizes in bytes
+	LastModified Transaction // Most recent commit that changed the table's rows or schema
+}
+
+// tableStatsCache is the result of the last TableStats call for a table,
+// with the HEAD it was computed at and the table's version there.
+type tableStatsCache struct {
+	head    plumbing.Hash
+	version [2]plumbing.Hash
+	stats   TableStats
+}
+
+// TableStats counts a table's rows and sums their blob sizes by walking the
+// table's tree, then follows first parents back from HEAD to find the last
+// commit that changed the table. The result is cached per table: an unchanged
+// table reuses its counts, and the walk stops at the HEAD of the previous
+// call when the table has not changed since, so repeated calls only visit
+// new commits.
+func (p *Persistence) TableStats(database, table string) (TableStats, error) {
+	if err := p.ensureInitialized(); err != nil {
+		return TableStats{}, err
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	headRef, err := p.repo.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return TableStats{}, fmt.Errorf("%w: %s.%s", ErrTableNotFound, database, table)
+	} else if err != nil {
+		return TableStats{}, err
+	}
+	commit, err := p.repo.CommitObject(headRef.Hash())
+	if err != nil {
+		return TableStats{}, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return TableStats{}, err
+	}
+	if _, err := tree.FindEntry(path.Join(database, table+".table")); isNotFound(err) {
+		return TableStats{}, fmt.Errorf("%w: %s.%s", ErrTableNotFound, database, table)
+	} else if err != nil {
+		return TableStats{}, err
+	}
+
+	key := database + "." + table
+	version := tableVersion(tree, database, table)
+	p.statsMu.Lock()
+	cached, hit := p.tableStats[key]
+	p.statsMu.Unlock()
+	hit = hit && cached.version == version
+
+	var stats TableStats
+	if hit {
+		stats.Rows, stats.Size = cached.stats.Rows, cached.stats.Size
+	} else if tableTree, err := tree.Tree(path.Join(database, table)); err == nil {
+		err := tableTree.Files().ForEach(func(f *object.File) error {
+			stats.Rows++
+			stats.Size += f.Size
+			return nil
+		})
+		if err != nil {
+			return TableStats{}, err
+		}
+	}
+
+	// Walk back while the parent still has the same table contents, up to
+	// the previous call's HEAD if the table is unchanged since
+	reached := func() bool { return hit && commit.Hash == cached.head }
+	for commit.NumParents() > 0 && !reached() {
+		parent, err := commit.Parent(0)
+		if err != nil {
+			return TableStats{}, err
+		}
+		parentTree, err := parent.Tree()
+		if err != nil {
+			return TableStats{}, err
+		}
+		if tableVersion(parentTree, database, table) != version {
+			break
+		}
+		commit = parent
+	}
+	if reached() {
+		stats.LastModified = cached.stats.LastModified
+	} else {
+		stats.LastModified = commitTransaction(commit)
+	}
+
+	p.statsMu.Lock()
+	if p.tableStats == nil {
+		p.tableStats = make(map[string]tableStatsCache)
+	}
+	p.tableStats[key] = tableStatsCache{head: headRef.Hash(), version: version, stats: stats}
+	p.statsMu.Unlock()
+
+	return stats, nil
+}
+
+// tableVersion identifies the contents of a table in tree: the hashes of its
+// record tree and its metadata file.
+func tableVersion(tree *object.Tree, database, table string) [2]plumbing.Hash {
+	var version [2]plumbing.Hash
+	if entry, err := tree.FindEntry(path.Join(database, table)); err == nil {
+		version[0] = entry.Hash
+	}
+	if entry, err := tree.FindEntry(path.Join(database, table+".table")); err == nil {
+		version[1] = entry.Hash
+	}
+	return version
+}
+
+// RepositoryStats summarizes the storage of the whole repository.
+type RepositoryStats struct {
+	Objects      int   // Loose and packed objects
+	LooseObjects int   // Objects stored on their own; every object in memory persistence
+	Packs        int   // Pack files
+	PackSize     int64 // Sum of the pack file sizes in bytes
+	Commits      int   // Commits reachable from HEAD
+}
+
+// RepositoryStats counts the repository's objects, loose and packed, sums
+// the pack file sizes and counts the commits in HEAD's history, so growth
+// can be tracked and gc scheduled.
+func (p *Persistence) RepositoryStats() (RepositoryStats, error) {
+	if err := p.ensureInitialized(); err != nil {
+		return RepositoryStats{}, err
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var stats RepositoryStats
+	objects, err := p.repo.Storer.IterEncodedObjects(plumbing.AnyObject)
+	if err != nil {
+		return RepositoryStats{}, err
+	}
+	err = objects.ForEach(func(plumbing.EncodedObject) error {
+		stats.Objects++
+		return nil
+	})
+	if err != nil {
+		return RepositoryStats{}, err
+	}
+
+	if loose, ok := p.repo.Storer.(storer.LooseObjectStorer); ok {
+		err := loose.ForEachObjectHash(func(plumbing.Hash) error {
+			stats.LooseObjects++
+			return nil
+		})
+		if err != nil {
+			return RepositoryStats{}, err
+		}
+	}
+
+	if packed, ok := p.repo.Storer.(storer.PackedObjectStorer); ok {
+		packs, err := packed.ObjectPacks()
+		if err != nil {
+			return RepositoryStats{}, err
+		}
+		stats.Packs = len(packs)
+		if fs, ok := p.repo.Storer.(*filesystem.Storage); ok {
+			for _, pack := range packs {
+				info, err := fs.Filesystem().Stat(path.Join("objects", "pack", "pack-"+pack.String()+".pack"))
+				if err != nil {
+					return RepositoryStats{}, err
+				}
+				stats.PackSize += info.Size()
+			}
+		}
+	}
+
+	headRef, err := p.repo.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return stats, nil
+	} else if err != nil {
+		return RepositoryStats{}, err
+	}
+	commits, err := p.repo.Log(&git.LogOptions{From: headRef.Hash()})
+	if err != nil {
+		return RepositoryStats{}, err
+	}
+	err = commits.ForEach(func(*object.Commit) error {
+		stats.Commits++
+		return nil
+	})
+	if err != nil {
+		return RepositoryStats{}, err
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,118 @@
+package ps
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/go-git/go-git/v6"
+	"github.com/nickyhof/CommitDB/core"
+)
+
+func TestTableStats(t *testing.T) {
+	persistence, _ := NewMemoryPersistence()
+	identity := core.Identity{Name: "Test", Email: "test@test.com"}
+
+	persistence.CreateDatabase(core.Database{Name: "testdb"}, identity)
+	for _, name := range []string{"users", "orders"} {
+		persistence.CreateTable(core.Table{
+			Database: "testdb",
+			Name:     name,
+			Columns:  []core.Column{{Name: "id", Type: core.IntType}},
+		}, identity)
+	}
+
+	stats, err := persistence.TableStats("testdb", "users")
+	if err != nil {
+		t.Fatalf("TableStats failed: %v", err)
+	}
+	if stats.Rows != 0 || stats.Size != 0 {
+		t.Errorf("Expected an empty table, got %d rows of %d bytes", stats.Rows, stats.Size)
+	}
+
+	var lastWrite Transaction
+	var expectedSize int64
+	for i := 1; i <= 5; i++ {
+		data := []byte(`{"id":"` + strconv.Itoa(i) + `"}`)
+		expectedSize += int64(len(data))
+		lastWrite, err = persistence.SaveRecord("testdb", "users", map[string][]byte{strconv.Itoa(i): data}, identity)
+		if err != nil {
+			t.Fatalf("SaveRecord failed: %v", err)
+		}
+	}
+	// A later write to another table does not touch users
+	persistence.SaveRecord("testdb", "orders", map[string][]byte{"1": []byte(`{"id":"1"}`)}, identity)
+
+	stats, err = persistence.TableStats("testdb", "users")
+	if err != nil {
+		t.Fatalf("TableStats failed: %v", err)
+	}
+	if stats.Rows != len(persistence.ListRecordKeys("testdb", "users")) || stats.Rows != 5 {
+		t.Errorf("Expected 5 rows, got %d", stats.Rows)
+	}
+	if stats.Size != expectedSize {
+		t.Errorf("Expected %d bytes, got %d", expectedSize, stats.Size)
+	}
+	if stats.LastModified.Id != lastWrite.Id {
+		t.Errorf("Expected last modified at %s, got %s", lastWrite.Id, stats.LastModified.Id)
+	}
+
+	// Later calls reuse the cached result up to the previous HEAD
+	persistence.SaveRecord("testdb", "orders", map[string][]byte{"2": []byte(`{"id":"2"}`)}, identity)
+	if again, err := persistence.TableStats("testdb", "users"); err != nil || again != stats {
+		t.Errorf("Expected %+v after an unrelated write, got %+v (%v)", stats, again, err)
+	}
+	lastWrite, _ = persistence.SaveRecord("testdb", "users", map[string][]byte{"6": []byte(`{"id":"6"}`)}, identity)
+	stats, err = persistence.TableStats("testdb", "users")
+	if err != nil || stats.Rows != 6 || stats.LastModified.Id != lastWrite.Id {
+		t.Errorf("Expected 6 rows last modified at %s, got %+v (%v)", lastWrite.Id, stats, err)
+	}
+	persistence.DeleteRecord("testdb", "users", "6", identity)
+	if stats, err = persistence.TableStats("testdb", "users"); err != nil || stats.Rows != 5 {
+		t.Errorf("Expected 5 rows after a delete, got %+v (%v)", stats, err)
+	}
+
+	if _, err := persistence.TableStats("testdb", "missing"); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("Expected ErrTableNotFound, got %v", err)
+	}
+}
+
+func TestRepositoryStats(t *testing.T) {
+	persistence, err := NewFilePersistence(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("NewFilePersistence failed: %v", err)
+	}
+	identity := core.Identity{Name: "Test", Email: "test@test.com"}
+
+	persistence.CreateDatabase(core.Database{Name: "testdb"}, identity)
+	persistence.CreateTable(core.Table{
+		Database: "testdb",
+		Name:     "users",
+		Columns:  []core.Column{{Name: "id", Type: core.IntType}},
+	}, identity)
+	for i := 1; i <= 3; i++ {
+		persistence.SaveRecord("testdb", "users", map[string][]byte{strconv.Itoa(i): []byte(`{"id":"` + strconv.Itoa(i) + `"}`)}, identity)
+	}
+
+	stats, err := persistence.RepositoryStats()
+	if err != nil {
+		t.Fatalf("RepositoryStats failed: %v", err)
+	}
+	if stats.Commits != 5 {
+		t.Errorf("Expected 5 commits, got %d", stats.Commits)
+	}
+	if stats.Objects == 0 || stats.LooseObjects != stats.Objects || stats.Packs != 0 || stats.PackSize != 0 {
+		t.Errorf("Expected only loose objects, got %+v", stats)
+	}
+
+	if err := persistence.Repository().RepackObjects(&git.RepackConfig{}); err != nil {
+		t.Fatalf("RepackObjects failed: %v", err)
+	}
+	packed, err := persistence.RepositoryStats()
+	if err != nil {
+		t.Fatalf("RepositoryStats failed: %v", err)
+	}
+	if packed.Packs != 1 || packed.PackSize == 0 || packed.Commits != stats.Commits {
+		t.Errorf("Expected one non-empty pack and the same history, got %+v", packed)
+	}
+}
@@ -13,12 +13,29 @@ type Transaction struct {
 	Id     string
 	When   time.Time
 	Author string // "Name <email>" format
+	// Unchanged is set when a write left the tree as it was, so no commit
+	// was made and Id is empty
+	Unchanged bool
 }
 
 func (transaction Transaction) String() string {
 	return fmt.Sprintf("Transaction{Id: %s, When: %s, Author: %s}", transaction.Id, transaction.When, transaction.Author)
 }
 
+// commitTransaction describes a commit as a Transaction
+func commitTransaction(commit *object.Commit) Transaction {
+	author := ""
+	if commit.Author.Name != "" || commit.Author.Email != "" {
+		author = fmt.Sprintf("%s <%s>", commit.Author.Name, commit.Author.Email)
+	}
+
+	return Transaction{
+		Id:     commit.Hash.String(),
+		When:   commit.Committer.When,
+		Author: author,
+	}
+}
+
 func (persistence *Persistence) LatestTransaction() Transaction {
 	headRef, err := persistence.repo.Head()
 	if err != nil || headRef == nil {
@@ -0,0 +1,71 @@
+package ps
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/nickyhof/CommitDB/core"
+)
+
+// triggerPath returns the repository path of a trigger definition
+func triggerPath(database, name string) string {
+	return fmt.Sprintf(".commitdb/triggers/%s/%s.json", database, name)
+}
+
+// CreateTrigger stores a trigger definition, replacing any trigger of the
+// same name
+func (persistence *Persistence) CreateTrigger(trigger core.Trigger, identity core.Identity) (txn Transaction, err error) {
+	dataBytes, err := json.MarshalIndent(trigger, "", "  ")
+	if err != nil {
+		return Transaction{}, fmt.Errorf("failed to marshal trigger: %w", err)
+	}
+
+	return persistence.WriteFileDirect(triggerPath(trigger.Database, trigger.Name), dataBytes, identity, fmt.Sprintf("Creating trigger %s.%s", trigger.Database, trigger.Name))
+}
+
+// GetTrigger retrieves a trigger definition
+func (persistence *Persistence) GetTrigger(database, name string) (*core.Trigger, error) {
+	data, err := persistence.ReadFileDirect(triggerPath(database, name))
+	if isNotFound(err) {
+		return nil, fmt.Errorf("%w: %s.%s", ErrTriggerNotFound, database, name)
+	} else if err != nil {
+		return nil, err
+	}
+
+	var trigger core.Trigger
+	if err := json.Unmarshal(data, &trigger); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal trigger: %w", err)
+	}
+
+	return &trigger, nil
+}
+
+// ListTriggers returns all triggers in a database, ordered by name
+func (persistence *Persistence) ListTriggers(database string) ([]core.Trigger, error) {
+	entries, err := persistence.ListEntriesDirect(fmt.Sprintf(".commitdb/triggers/%s", database))
+	if err != nil {
+		// No triggers directory yet - return empty list
+		return []core.Trigger{}, nil
+	}
+
+	var triggers []core.Trigger
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name, ".json") {
+			continue
+		}
+
+		trigger, err := persistence.GetTrigger(database, strings.TrimSuffix(entry.Name, ".json"))
+		if err != nil {
+			continue // Skip invalid triggers
+		}
+		triggers = append(triggers, *trigger)
+	}
+
+	return triggers, nil
+}
+
+// DropTrigger removes a trigger definition
+func (persistence *Persistence) DropTrigger(database, name string, identity core.Identity) (txn Transaction, err error) {
+	return persistence.DeletePathDirect([]string{triggerPath(database, name)}, identity, fmt.Sprintf("Dropping trigger %s.%s", database, name))
+}
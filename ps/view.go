@@ -20,13 +20,51 @@ func (persistence *Persistence) CreateView(view core.View, identity core.Identit
 	return persistence.WriteFileDirect(path, dataBytes, identity, fmt.Sprintf("Creating view %s.%s", view.Database, view.Name))
 }
 
+// ReplaceView stores a view definition in a single commit, replacing any view
+// of the same name along with its cached data. For a materialized view, rows
+// become the new cache; a regular view is left without one.
+func (persistence *Persistence) ReplaceView(view core.View, rows []map[string]string, identity core.Identity) (txn Transaction, err error) {
+	return persistence.storeView(view, rows, identity, fmt.Sprintf("Creating view %s.%s", view.Database, view.Name))
+}
+
+// RefreshView stores a materialized view's refreshed definition and rows as
+// its cached data in a single commit.
+func (persistence *Persistence) RefreshView(view core.View, rows []map[string]string, identity core.Identity) (txn Transaction, err error) {
+	return persistence.storeView(view, rows, identity, fmt.Sprintf("Refreshing materialized view %s.%s", view.Database, view.Name))
+}
+
+// storeView writes a view definition and, for a materialized view, its
+// cached rows, replacing the previous cache in one commit.
+func (persistence *Persistence) storeView(view core.View, rows []map[string]string, identity core.Identity, message string) (txn Transaction, err error) {
+	basePath := fmt.Sprintf(".commitdb/materialized/%s/%s", view.Database, view.Name)
+
+	viewBytes, err := json.MarshalIndent(view, "", "  ")
+	if err != nil {
+		return Transaction{}, fmt.Errorf("failed to marshal view: %w", err)
+	}
+	files := map[string][]byte{
+		fmt.Sprintf(".commitdb/views/%s/%s.json", view.Database, view.Name): viewBytes,
+	}
+	if view.Materialized {
+		dataBytes, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return Transaction{}, fmt.Errorf("failed to marshal rows: %w", err)
+		}
+		files[basePath+"/data.json"] = dataBytes
+	}
+
+	return persistence.replacePathsDirect([]string{basePath}, files, identity, message)
+}
+
 // GetView retrieves a view definition
 func (persistence *Persistence) GetView(database, name string) (*core.View, error) {
 	path := fmt.Sprintf(".commitdb/views/%s/%s.json", database, name)
 
 	data, err := persistence.ReadFileDirect(path)
-	if err != nil {
-		return nil, fmt.Errorf("view %s.%s does not exist: %w", database, name, err)
+	if isNotFound(err) {
+		return nil, fmt.Errorf("%w: %s.%s", ErrViewNotFound, database, name)
+	} else if err != nil {
+		return nil, err
 	}
 
 	var view core.View
@@ -64,10 +102,12 @@ func (persistence *Persistence) ListViews(database string) ([]core.View, error)
 	return views, nil
 }
 
-// DropView removes a view definition
+// DropView removes a view definition along with any cached materialized data,
+// so a view recreated under the same name never reads a stale cache
 func (persistence *Persistence) DropView(database, name string, identity core.Identity) (txn Transaction, err error) {
 	paths := []string{
 		fmt.Sprintf(".commitdb/views/%s/%s.json", database, name),
+		fmt.Sprintf(".commitdb/materialized/%s/%s", database, name), // Materialized data directory
 	}
 
 	return persistence.DeletePathDirect(paths, identity, fmt.Sprintf("Dropping view %s.%s", database, name))
@@ -0,0 +1,69 @@
+package ps
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nickyhof/CommitDB/core"
+)
+
+func TestGetAndListViews(t *testing.T) {
+	persistence, _ := NewMemoryPersistence()
+	identity := core.Identity{Name: "Test", Email: "test@test.com"}
+
+	persistence.CreateDatabase(core.Database{Name: "testdb"}, identity)
+
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	views := []core.View{
+		{
+			Database:  "testdb",
+			Name:      "adults",
+			Query:     "SELECT * FROM testdb.users WHERE age >= 18",
+			Columns:   []core.Column{},
+			CreatedAt: created,
+			UpdatedAt: created,
+		},
+		{
+			Database:     "testdb",
+			Name:         "totals",
+			Query:        "SELECT region, SUM(total) FROM testdb.orders GROUP BY region",
+			Materialized: true,
+			Columns:      []core.Column{{Name: "region"}, {Name: "SUM(total)"}},
+			CreatedAt:    created,
+			UpdatedAt:    created.Add(time.Hour),
+			Snapshot:     persistence.LatestTransaction().Id,
+		},
+	}
+	for _, view := range views {
+		if _, err := persistence.CreateView(view, identity); err != nil {
+			t.Fatalf("CreateView failed: %v", err)
+		}
+	}
+
+	view, err := persistence.GetView("testdb", "totals")
+	if err != nil {
+		t.Fatalf("GetView failed: %v", err)
+	}
+	if !reflect.DeepEqual(*view, views[1]) {
+		t.Errorf("Expected %+v, got %+v", views[1], *view)
+	}
+
+	listed, err := persistence.ListViews("testdb")
+	if err != nil {
+		t.Fatalf("ListViews failed: %v", err)
+	}
+	if !reflect.DeepEqual(listed, views) {
+		t.Errorf("Expected %+v, got %+v", views, listed)
+	}
+
+	if _, err := persistence.GetView("testdb", "missing"); !errors.Is(err, ErrViewNotFound) {
+		t.Errorf("Expected ErrViewNotFound, got %v", err)
+	}
+
+	listed, err = persistence.ListViews("otherdb")
+	if err != nil || len(listed) != 0 {
+		t.Errorf("Expected no views in otherdb, got %v (%v)", listed, err)
+	}
+}
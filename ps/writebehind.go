@@ -0,0 +1,345 @@
+package ps
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/nickyhof/CommitDB/core"
+)
+
+// WriteBehind configures buffered record writes for bulk ingestion.
+//
+// While enabled, SaveRecord and DeleteRecord only buffer their changes, and
+// the index updates they cause, in memory. Reads through the same
+// Persistence see buffered changes, and all of them are committed together
+// as a single commit on Flush, once MaxWrites records are buffered, or
+// Interval after the first buffered write. Index updates are buffered as the
+// entries added and removed, and apply to each index as it is at the flush,
+// so entries others committed in the meantime are kept.
+//
+// Durability caveat: buffered writes are not in the repository until they are
+// flushed. They are lost if the process exits first, and other processes or
+// clones of the repository do not see them.
+type WriteBehind struct {
+	MaxWrites int           // Flush once this many record writes are buffered; 0 for no limit
+	Interval  time.Duration // Flush this long after the first buffered write; 0 for no timer
+}
+
+// writeBuffer holds record writes waiting for a write-behind flush
+type writeBuffer struct {
+	options    WriteBehind
+	operations []Operation
+	records    map[string]map[string][]byte // "database/table" -> key -> data, nil for a delete
+	indexes    map[string]*indexDelta       // index file path -> buffered changes to the index
+	identity   core.Identity                // author of the most recent buffered write
+	timer      *time.Timer
+	timerErr   error // error of the last timed flush, reported by the next Flush
+	emptied    int   // times the buffer was flushed or discarded, for WriteMark
+}
+
+// indexDelta is the buffered change to one index
+type indexDelta struct {
+	header  Index                      // the index's name, table, column and uniqueness, without entries
+	changes map[string]map[string]bool // column value -> primary key -> added, or removed if false
+}
+
+// clone returns a copy of the delta that shares nothing with it
+func (delta *indexDelta) clone() *indexDelta {
+	copied := &indexDelta{header: delta.header, changes: make(map[string]map[string]bool, len(delta.changes))}
+	for value, keys := range delta.changes {
+		copied.changes[value] = maps.Clone(keys)
+	}
+	return copied
+}
+
+// cloneIndexDeltas copies buffered index changes
+func cloneIndexDeltas(indexes map[string]*indexDelta) map[string]*indexDelta {
+	copied := make(map[string]*indexDelta, len(indexes))
+	for path, delta := range indexes {
+		copied[path] = delta.clone()
+	}
+	return copied
+}
+
+// EnableWriteBehind starts buffering record writes with the given options.
+// Calling it again changes the options of the active buffer.
+func (p *Persistence) EnableWriteBehind(options WriteBehind) {
+	p.wbMu.Lock()
+	defer p.wbMu.Unlock()
+
+	if p.writeBehind == nil {
+		p.writeBehind = &writeBuffer{records: make(map[string]map[string][]byte), indexes: make(map[string]*indexDelta)}
+	}
+	p.writeBehind.options = options
+}
+
+// WriteBehindOptions returns the options of the active write-behind buffer
+// and whether write-behind is enabled.
+func (p *Persistence) WriteBehindOptions() (WriteBehind, bool) {
+	p.wbMu.Lock()
+	defer p.wbMu.Unlock()
+
+	if p.writeBehind == nil {
+		return WriteBehind{}, false
+	}
+	return p.writeBehind.options, true
+}
+
+// DisableWriteBehind flushes any buffered writes and returns to committing
+// every write immediately.
+func (p *Persistence) DisableWriteBehind() (Transaction, error) {
+	defer p.dispatchCommitEvents()
+	p.wbMu.Lock()
+	defer p.wbMu.Unlock()
+
+	txn, err := p.flushLocked()
+	if err != nil {
+		return Transaction{}, err
+	}
+	p.writeBehind = nil
+	return txn, nil
+}
+
+// Flush commits all buffered writes as one commit. It returns an empty
+// transaction when write-behind is disabled or nothing is buffered.
+func (p *Persistence) Flush() (Transaction, error) {
+	defer p.dispatchCommitEvents()
+	p.wbMu.Lock()
+	defer p.wbMu.Unlock()
+
+	return p.flushLocked()
+}
+
+// DiscardWrites drops all buffered writes without committing them and
+// returns the number of record writes dropped. Write-behind stays enabled.
+func (p *Persistence) DiscardWrites() int {
+	p.wbMu.Lock()
+	defer p.wbMu.Unlock()
+
+	wb := p.writeBehind
+	if wb == nil {
+		return 0
+	}
+	if wb.timer != nil {
+		wb.timer.Stop()
+		wb.timer = nil
+	}
+	discarded := len(wb.operations)
+	wb.emptied++
+	wb.operations = nil
+	wb.records = make(map[string]map[string][]byte)
+	wb.indexes = make(map[string]*indexDelta)
+	return discarded
+}
+
+// WriteMark is the state of the write-behind buffer at one point, which
+// RollbackWrites returns it to.
+type WriteMark struct {
+	emptied    int
+	operations int
+	records    map[string]map[string][]byte
+	indexes    map[string]*indexDelta
+}
+
+// MarkWrites returns the current state of the write-behind buffer
+func (p *Persistence) MarkWrites() WriteMark {
+	p.wbMu.Lock()
+	defer p.wbMu.Unlock()
+
+	wb := p.writeBehind
+	if wb == nil {
+		return WriteMark{}
+	}
+	mark := WriteMark{
+		emptied:    wb.emptied,
+		operations: len(wb.operations),
+		records:    make(map[string]map[string][]byte, len(wb.records)),
+		indexes:    cloneIndexDeltas(wb.indexes),
+	}
+	for table, records := range wb.records {
+		mark.records[table] = maps.Clone(records)
+	}
+	return mark
+}
+
+// RollbackWrites drops the writes buffered since mark was taken and returns
+// the number of record writes dropped. A flush since then leaves nothing to
+// drop, as do marks taken while write-behind was disabled.
+func (p *Persistence) RollbackWrites(mark WriteMark) int {
+	p.wbMu.Lock()
+	defer p.wbMu.Unlock()
+
+	wb := p.writeBehind
+	if wb == nil || mark.records == nil || wb.emptied != mark.emptied {
+		return 0
+	}
+	dropped := len(wb.operations) - mark.operations
+	wb.operations = wb.operations[:mark.operations]
+	wb.records = make(map[string]map[string][]byte, len(mark.records))
+	for table, records := range mark.records {
+		wb.records[table] = maps.Clone(records)
+	}
+	wb.indexes = cloneIndexDeltas(mark.indexes)
+	return dropped
+}
+
+// PendingWrites returns the number of buffered record writes, counting
+// deletes
+func (p *Persistence) PendingWrites() int {
+	p.wbMu.Lock()
+	defer p.wbMu.Unlock()
+
+	if p.writeBehind == nil {
+		return 0
+	}
+	return len(p.writeBehind.operations)
+}
+
+// PendingDeletes returns how many of the buffered record writes are deletes
+func (p *Persistence) PendingDeletes() int {
+	p.wbMu.Lock()
+	defer p.wbMu.Unlock()
+
+	if p.writeBehind == nil {
+		return 0
+	}
+	deletes := 0
+	for _, operation := range p.writeBehind.operations {
+		if operation.Type == DeleteOp {
+			deletes++
+		}
+	}
+	return deletes
+}
+
+func (p *Persistence) flushLocked() (Transaction, error) {
+	wb := p.writeBehind
+	if wb == nil {
+		return Transaction{}, nil
+	}
+	if wb.timer != nil {
+		wb.timer.Stop()
+		wb.timer = nil
+	}
+	if err := wb.timerErr; err != nil {
+		wb.timerErr = nil
+		return Transaction{}, err
+	}
+	if len(wb.operations) == 0 && len(wb.indexes) == 0 {
+		return Transaction{Unchanged: true}, nil
+	}
+
+	operations := len(wb.operations) + len(wb.indexes)
+	txn, err := p.commitMerged(slices.Clone(wb.operations), wb.indexes, wb.identity, fmt.Sprintf("Batch transaction: %d operation(s)", operations))
+	if err != nil {
+		return Transaction{}, err
+	}
+	wb.emptied++
+	wb.operations = nil
+	wb.records = make(map[string]map[string][]byte)
+	wb.indexes = make(map[string]*indexDelta)
+	return txn, nil
+}
+
+// bufferWrite buffers record writes (nil data deletes) if write-behind is
+// enabled, flushing when the buffer is full. It reports whether the writes
+// were handled.
+func (p *Persistence) bufferWrite(database, table string, records map[string][]byte, identity core.Identity) (bool, Transaction, error) {
+	defer p.dispatchCommitEvents()
+	p.wbMu.Lock()
+	defer p.wbMu.Unlock()
+
+	wb := p.writeBehind
+	if wb == nil {
+		return false, Transaction{}, nil
+	}
+
+	tableKey := database + "/" + table
+	if wb.records[tableKey] == nil {
+		wb.records[tableKey] = make(map[string][]byte)
+	}
+	for _, key := range slices.Sorted(maps.Keys(records)) {
+		data := records[key]
+		op := Operation{Type: WriteOp, Database: database, Table: table, Key: key, Data: data}
+		if data == nil {
+			op.Type = DeleteOp
+		}
+		wb.operations = append(wb.operations, op)
+		wb.records[tableKey][key] = data
+	}
+	wb.identity = identity
+
+	if wb.options.MaxWrites > 0 && len(wb.operations) >= wb.options.MaxWrites {
+		txn, err := p.flushLocked()
+		return true, txn, err
+	}
+	if wb.options.Interval > 0 && wb.timer == nil {
+		wb.timer = time.AfterFunc(wb.options.Interval, func() {
+			defer p.dispatchCommitEvents()
+			p.wbMu.Lock()
+			defer p.wbMu.Unlock()
+			if p.writeBehind != wb {
+				return
+			}
+			wb.timer = nil
+			if _, err := p.flushLocked(); err != nil {
+				wb.timerErr = err
+			}
+		})
+	}
+	return true, Transaction{When: time.Now()}, nil
+}
+
+// bufferedRecords returns a copy of the buffered writes to a table, nil data
+// marking deletes. It returns nil when nothing is buffered for the table.
+func (p *Persistence) bufferedRecords(database, table string) map[string][]byte {
+	p.wbMu.Lock()
+	defer p.wbMu.Unlock()
+
+	if p.writeBehind == nil || len(p.writeBehind.records[database+"/"+table]) == 0 {
+		return nil
+	}
+	return maps.Clone(p.writeBehind.records[database+"/"+table])
+}
+
+// bufferIndex buffers the changes made to an index since it was last
+// saved, if write-behind is enabled, so they land in the same commit as the
+// buffered records. It reports whether the changes were buffered. Buffered
+// indexes do not count towards MaxWrites.
+func (p *Persistence) bufferIndex(path string, idx *Index, changes map[string]map[string]bool, identity core.Identity) bool {
+	p.wbMu.Lock()
+	defer p.wbMu.Unlock()
+
+	wb := p.writeBehind
+	if wb == nil {
+		return false
+	}
+	delta := wb.indexes[path]
+	if delta == nil {
+		delta = &indexDelta{changes: make(map[string]map[string]bool)}
+		wb.indexes[path] = delta
+	}
+	delta.header = Index{Name: idx.Name, Database: idx.Database, Table: idx.Table, Column: idx.Column, Unique: idx.Unique}
+	for value, keys := range changes {
+		if delta.changes[value] == nil {
+			delta.changes[value] = make(map[string]bool)
+		}
+		maps.Copy(delta.changes[value], keys)
+	}
+	wb.identity = identity
+	return true
+}
+
+// bufferedIndex returns a copy of the buffered changes to an index, or nil
+// if none are buffered
+func (p *Persistence) bufferedIndex(path string) *indexDelta {
+	p.wbMu.Lock()
+	defer p.wbMu.Unlock()
+
+	if p.writeBehind == nil || p.writeBehind.indexes[path] == nil {
+		return nil
+	}
+	return p.writeBehind.indexes[path].clone()
+}
@@ -0,0 +1,206 @@
+package ps
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/nickyhof/CommitDB/core"
+)
+
+func setupWriteBehind(t *testing.T) (*Persistence, core.Identity) {
+	t.Helper()
+	persistence, err := NewMemoryPersistence()
+	if err != nil {
+		t.Fatalf("Failed to create persistence: %v", err)
+	}
+
+	identity := core.Identity{Name: "test", Email: "test@test.com"}
+	persistence.CreateDatabase(core.Database{Name: "testdb"}, identity)
+	persistence.CreateTable(core.Table{
+		Database: "testdb",
+		Name:     "users",
+		Columns:  []core.Column{{Name: "id", Type: core.IntType}},
+	}, identity)
+	return &persistence, identity
+}
+
+func TestWriteBehindFlush(t *testing.T) {
+	persistence, identity := setupWriteBehind(t)
+	if _, err := persistence.SaveRecord("testdb", "users", map[string][]byte{"0": []byte(`{"id":"0"}`)}, identity); err != nil {
+		t.Fatalf("SaveRecord failed: %v", err)
+	}
+	head := persistence.LatestTransaction().Id
+
+	persistence.EnableWriteBehind(WriteBehind{})
+	for i := 1; i <= 10; i++ {
+		key := strconv.Itoa(i)
+		txn, err := persistence.SaveRecord("testdb", "users", map[string][]byte{key: []byte(`{"id":"` + key + `"}`)}, identity)
+		if err != nil {
+			t.Fatalf("SaveRecord failed: %v", err)
+		}
+		if txn.Id != "" {
+			t.Fatalf("Expected buffered write to have no commit, got %s", txn.Id)
+		}
+	}
+	if _, err := persistence.DeleteRecord("testdb", "users", "0", identity); err != nil {
+		t.Fatalf("DeleteRecord failed: %v", err)
+	}
+
+	// Buffered writes are visible before the flush, without a commit
+	if got := persistence.LatestTransaction().Id; got != head {
+		t.Fatalf("Expected HEAD to stay at %s before flush, got %s", head, got)
+	}
+	if data, exists := persistence.GetRecord("testdb", "users", "5"); !exists || string(data) != `{"id":"5"}` {
+		t.Errorf("Expected buffered record 5, got %q (exists=%v)", data, exists)
+	}
+	if _, exists := persistence.GetRecord("testdb", "users", "0"); exists {
+		t.Error("Expected buffered delete to hide record 0")
+	}
+	if keys := persistence.ListRecordKeys("testdb", "users"); len(keys) != 10 {
+		t.Errorf("Expected 10 keys before flush, got %v", keys)
+	}
+	scanned := 0
+	for range persistence.Scan("testdb", "users", nil) {
+		scanned++
+	}
+	if scanned != 10 {
+		t.Errorf("Expected Scan to return 10 records before flush, got %d", scanned)
+	}
+	if pending := persistence.PendingWrites(); pending != 11 {
+		t.Errorf("Expected 11 pending writes, got %d", pending)
+	}
+	if deletes := persistence.PendingDeletes(); deletes != 1 {
+		t.Errorf("Expected 1 pending delete, got %d", deletes)
+	}
+
+	txn, err := persistence.Flush()
+	if err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if txn.Id == "" || persistence.PendingWrites() != 0 {
+		t.Fatalf("Expected flush to commit all writes, got txn %q with %d pending", txn.Id, persistence.PendingWrites())
+	}
+
+	// All buffered writes land in exactly one commit on top of the old HEAD
+	commit, err := persistence.repo.CommitObject(plumbing.NewHash(txn.Id))
+	if err != nil {
+		t.Fatalf("Failed to read flush commit: %v", err)
+	}
+	if commit.NumParents() != 1 || commit.ParentHashes[0].String() != head {
+		t.Errorf("Expected flush commit to follow %s directly, got parents %v", head, commit.ParentHashes)
+	}
+	if keys := persistence.ListRecordKeys("testdb", "users"); len(keys) != 10 {
+		t.Errorf("Expected 10 keys after flush, got %v", keys)
+	}
+	if _, exists := persistence.GetRecord("testdb", "users", "0"); exists {
+		t.Error("Expected record 0 to be deleted after flush")
+	}
+}
+
+func TestWriteBehindMaxWrites(t *testing.T) {
+	persistence, identity := setupWriteBehind(t)
+	head := persistence.LatestTransaction().Id
+
+	persistence.EnableWriteBehind(WriteBehind{MaxWrites: 3})
+	for i := 1; i <= 2; i++ {
+		persistence.SaveRecord("testdb", "users", map[string][]byte{strconv.Itoa(i): []byte(`{}`)}, identity)
+	}
+	if got := persistence.LatestTransaction().Id; got != head {
+		t.Fatalf("Expected no commit below MaxWrites, got %s", got)
+	}
+
+	txn, err := persistence.SaveRecord("testdb", "users", map[string][]byte{"3": []byte(`{}`)}, identity)
+	if err != nil {
+		t.Fatalf("SaveRecord failed: %v", err)
+	}
+	if txn.Id == "" || persistence.LatestTransaction().Id != txn.Id {
+		t.Errorf("Expected reaching MaxWrites to commit, got %q", txn.Id)
+	}
+	if persistence.PendingWrites() != 0 {
+		t.Errorf("Expected an empty buffer after the flush, got %d", persistence.PendingWrites())
+	}
+}
+
+func TestWriteBehindInterval(t *testing.T) {
+	persistence, identity := setupWriteBehind(t)
+	head := persistence.LatestTransaction().Id
+
+	persistence.EnableWriteBehind(WriteBehind{Interval: 10 * time.Millisecond})
+	persistence.SaveRecord("testdb", "users", map[string][]byte{"1": []byte(`{}`)}, identity)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for persistence.PendingWrites() > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if persistence.PendingWrites() != 0 || persistence.LatestTransaction().Id == head {
+		t.Fatal("Expected the interval to flush the buffered write")
+	}
+}
+
+func TestDisableWriteBehindFlushes(t *testing.T) {
+	persistence, identity := setupWriteBehind(t)
+
+	persistence.EnableWriteBehind(WriteBehind{})
+	persistence.SaveRecord("testdb", "users", map[string][]byte{"1": []byte(`{}`)}, identity)
+
+	txn, err := persistence.DisableWriteBehind()
+	if err != nil {
+		t.Fatalf("DisableWriteBehind failed: %v", err)
+	}
+	if txn.Id == "" {
+		t.Error("Expected DisableWriteBehind to commit the buffered write")
+	}
+
+	// Writes commit immediately again
+	txn, _ = persistence.SaveRecord("testdb", "users", map[string][]byte{"2": []byte(`{}`)}, identity)
+	if txn.Id == "" {
+		t.Error("Expected SaveRecord to commit after DisableWriteBehind")
+	}
+}
+
+func TestWriteBehindIndexAndDiscard(t *testing.T) {
+	persistence, identity := setupWriteBehind(t)
+	head := persistence.LatestTransaction().Id
+	columns := []core.Column{{Name: "id", Type: core.IntType}}
+
+	persistence.EnableWriteBehind(WriteBehind{})
+	if _, err := persistence.SaveRecord("testdb", "users", map[string][]byte{"1": []byte(`{"id":"1"}`)}, identity); err != nil {
+		t.Fatalf("SaveRecord failed: %v", err)
+	}
+	im := NewIndexManager(persistence, identity)
+	idx, err := im.CreateIndex("idx_id", "testdb", "users", "id", false)
+	if err != nil {
+		t.Fatalf("CreateIndex failed: %v", err)
+	}
+	idx.Insert("1", "1")
+	if err := im.SaveIndex(idx); err != nil {
+		t.Fatalf("SaveIndex failed: %v", err)
+	}
+
+	// The buffered index is loadable but not committed
+	if got := persistence.LatestTransaction().Id; got != head {
+		t.Fatalf("Expected HEAD to stay at %s, got %s", head, got)
+	}
+	loaded := NewIndexManager(persistence, identity)
+	loaded.LoadIndexes("testdb", "users", columns)
+	if idx, found := loaded.GetIndex("testdb", "users", "id"); !found || len(idx.Lookup("1")) != 1 {
+		t.Fatal("Expected buffered index to be loadable")
+	}
+
+	if discarded := persistence.DiscardWrites(); discarded != 1 {
+		t.Errorf("Expected 1 discarded write, got %d", discarded)
+	}
+	if _, exists := persistence.GetRecord("testdb", "users", "1"); exists {
+		t.Error("Expected discarded record to be gone")
+	}
+	loaded = NewIndexManager(persistence, identity)
+	loaded.LoadIndexes("testdb", "users", columns)
+	if _, found := loaded.GetIndex("testdb", "users", "id"); found {
+		t.Error("Expected discarded index to be gone")
+	}
+	if txn, err := persistence.Flush(); err != nil || txn.Id != "" {
+		t.Errorf("Expected nothing to flush after discard, got %v (%v)", txn, err)
+	}
+}
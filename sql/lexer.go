@@ -1,5 +1,11 @@
 package sql
 
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
 type Token struct {
 	Type  TokenType
 	Value string
@@ -113,6 +119,8 @@ const (
 	Trim
 	Length
 	Replace
+	ToBase64
+	FromBase64
 	LeftFunc
 	RightFunc
 	Now
@@ -134,6 +142,8 @@ const (
 	JsonKeys
 	JsonLength
 	JsonType
+	JsonPretty
+	JsonCompact
 	Copy
 	Header
 	Delimiter
@@ -150,6 +160,8 @@ const (
 	If
 	Exists
 	Of
+	Returning
+	Repair
 	EOF
 	Unknown
 )
@@ -271,7 +283,9 @@ type Lexer struct {
 	sql          string
 	position     int
 	readPosition int
+	tokenStart   int // position of the last token returned by NextToken
 	ch           byte
+	hints        map[string]bool // words from /*+ ... */ optimizer hints
 }
 
 func NewLexer(sql string) *Lexer {
@@ -294,6 +308,7 @@ func (lexer *Lexer) NextToken() Token {
 	var token Token
 
 	lexer.skipWhitespace()
+	lexer.tokenStart = lexer.position
 
 	switch lexer.ch {
 	case ',':
@@ -338,17 +353,22 @@ func (lexer *Lexer) NextToken() Token {
 			return Token{Type: Int, Value: num}
 		} else if isAlphaNumeric(lexer.ch) {
 			literal := lexer.readIdentifier()
-			if literal == "PRIMARY" {
+			if toUpper(literal) == "PRIMARY" {
 				// Check for KEY
 				lexer.skipWhitespace()
 				nextLiteral := lexer.readIdentifier()
-				if nextLiteral == "KEY" {
+				if toUpper(nextLiteral) == "KEY" {
 					return Token{Type: PrimaryKey, Value: "PRIMARY KEY"}
 				} else {
 					return Token{Type: Unknown, Value: literal + " " + nextLiteral}
 				}
 			} else {
 				tokenType := lookupIdentifier(literal)
+				// Function names are only keywords when called, so columns
+				// such as date or count stay usable
+				if isFunctionKeyword(tokenType) && lexer.nextNonSpace() != '(' {
+					tokenType = Identifier
+				}
 				return Token{Type: tokenType, Value: literal}
 			}
 		} else {
@@ -365,6 +385,7 @@ func (lexer *Lexer) PeekToken() Token {
 	savedPosition := lexer.position
 	savedReadPosition := lexer.readPosition
 	savedCh := lexer.ch
+	savedTokenStart := lexer.tokenStart
 
 	// Get next token
 	token := lexer.NextToken()
@@ -373,14 +394,70 @@ func (lexer *Lexer) PeekToken() Token {
 	lexer.position = savedPosition
 	lexer.readPosition = savedReadPosition
 	lexer.ch = savedCh
+	lexer.tokenStart = savedTokenStart
 
 	return token
 }
 
+// lastTokenWas reports whether the last token NextToken returned starts
+// with ch
+func (lexer *Lexer) lastTokenWas(ch byte) bool {
+	return lexer.tokenStart < len(lexer.sql) && lexer.sql[lexer.tokenStart] == ch
+}
+
 func (lexer *Lexer) skipWhitespace() {
-	for lexer.ch == ' ' || lexer.ch == '\t' || lexer.ch == '\n' || lexer.ch == '\r' {
+	for {
+		switch {
+		case lexer.ch == ' ' || lexer.ch == '\t' || lexer.ch == '\n' || lexer.ch == '\r':
+			lexer.readChar()
+		case lexer.ch == '-' && lexer.peekChar() == '-':
+			// Line comment: skip to end of line
+			for lexer.ch != '\n' && lexer.ch != 0 {
+				lexer.readChar()
+			}
+		case lexer.ch == '/' && lexer.peekChar() == '*':
+			lexer.skipBlockComment()
+		default:
+			return
+		}
+	}
+}
+
+// skipBlockComment skips a /* ... */ comment, recording the words of
+// /*+ ... */ hints.
+func (lexer *Lexer) skipBlockComment() {
+	lexer.readChar() // skip '/'
+	lexer.readChar() // skip '*'
+	position := lexer.position
+	for lexer.ch != 0 && !(lexer.ch == '*' && lexer.peekChar() == '/') {
 		lexer.readChar()
 	}
+	comment := lexer.sql[position:lexer.position]
+	if lexer.ch != 0 {
+		lexer.readChar() // skip '*'
+		lexer.readChar() // skip '/'
+	}
+
+	if hint, ok := strings.CutPrefix(comment, "+"); ok {
+		if lexer.hints == nil {
+			lexer.hints = make(map[string]bool)
+		}
+		for _, word := range strings.Fields(hint) {
+			lexer.hints[toUpper(word)] = true
+		}
+	}
+}
+
+// HasHint reports whether a /*+ ... */ hint read so far contains name.
+func (lexer *Lexer) HasHint(name string) bool {
+	return lexer.hints[toUpper(name)]
+}
+
+func (lexer *Lexer) peekChar() byte {
+	if lexer.readPosition >= len(lexer.sql) {
+		return 0
+	}
+	return lexer.sql[lexer.readPosition]
 }
 
 func (lexer *Lexer) readIdentifier() string {
@@ -391,14 +468,28 @@ func (lexer *Lexer) readIdentifier() string {
 	return lexer.sql[position:lexer.position]
 }
 
+// readString reads a string literal. Two quotes in a row stand for one
+// quote; every other character, backslashes and newlines included, is taken
+// as written.
 func (lexer *Lexer) readString() string {
 	lexer.readChar() // skip opening quote
+	var str strings.Builder
 	position := lexer.position
-	for lexer.ch != '\'' && lexer.ch != 0 {
+	for lexer.ch != 0 {
+		if lexer.ch == '\'' {
+			if lexer.peekChar() != '\'' {
+				break
+			}
+			str.WriteString(lexer.sql[position : lexer.position+1])
+			lexer.readChar()
+			lexer.readChar()
+			position = lexer.position
+			continue
+		}
 		lexer.readChar()
 	}
-	str := lexer.sql[position:lexer.position]
-	return str
+	str.WriteString(lexer.sql[position:lexer.position])
+	return str.String()
 }
 
 func (lexer *Lexer) readNumber() string {
@@ -417,6 +508,37 @@ func (lexer *Lexer) readOperator() string {
 	return lexer.sql[position:lexer.position]
 }
 
+// nextNonSpace returns the next character that is not whitespace, without consuming it.
+func (lexer *Lexer) nextNonSpace() byte {
+	for i := lexer.position; i < len(lexer.sql); i++ {
+		switch lexer.sql[i] {
+		case ' ', '\t', '\n', '\r':
+			continue
+		default:
+			return lexer.sql[i]
+		}
+	}
+	return 0
+}
+
+// isFunctionKeyword reports whether tokenType names a SQL function.
+func isFunctionKeyword(tokenType TokenType) bool {
+	switch tokenType {
+	case Count, Sum, Avg, Min, Max,
+		Upper, Lower, Concat, Substring, Trim, Length, ToBase64, FromBase64, Now,
+		DateAdd, DateSub, DateDiff, DateFunc, Year, Month, Day, Hour, Minute, Second, DateFormat,
+		JsonExtract, JsonSet, JsonRemove, JsonContains, JsonKeys, JsonLength, JsonType,
+		JsonPretty, JsonCompact:
+		return true
+	}
+	return false
+}
+
+// isKeyword reports whether token is a word the lexer recognized as a keyword.
+func isKeyword(token Token) bool {
+	return token.Type != Identifier && token.Value != "" && lookupIdentifier(token.Value) == token.Type
+}
+
 func isAlphaNumeric(ch byte) bool {
 	return ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ch == '_' || ch == '.' || isDigit(ch)
 }
@@ -603,6 +725,10 @@ func lookupIdentifier(id string) TokenType {
 		return Refresh
 	case "OF":
 		return Of
+	case "RETURNING":
+		return Returning
+	case "REPAIR":
+		return Repair
 	case "TOKEN":
 		return TokenKeyword
 	case "KEY":
@@ -629,6 +755,10 @@ func lookupIdentifier(id string) TokenType {
 		return Length
 	case "REPLACE":
 		return Replace
+	case "TO_BASE64":
+		return ToBase64
+	case "FROM_BASE64":
+		return FromBase64
 	case "NOW":
 		return Now
 	case "DATE_ADD", "DATEADD":
@@ -667,6 +797,10 @@ func lookupIdentifier(id string) TokenType {
 		return JsonLength
 	case "JSON_TYPE":
 		return JsonType
+	case "JSON_PRETTY":
+		return JsonPretty
+	case "JSON_COMPACT":
+		return JsonCompact
 	case "COPY":
 		return Copy
 	case "HEADER":
@@ -716,3 +850,132 @@ func tokenize(sql string) []Token {
 		tokens = append(tokens, token)
 	}
 }
+
+// SplitStatements splits a script into individual statements on semicolons,
+// ignoring semicolons inside string literals and dropping -- line comments
+// and empty statements. As in the lexer, a literal ends at the next quote
+// that is not doubled; backslashes do not escape.
+func SplitStatements(content string) []string {
+	var statements []string
+	var current strings.Builder
+	inString := false
+	stringChar := byte(0)
+
+	for i := 0; i < len(content); i++ {
+		ch := content[i]
+
+		// Handle string literals. A doubled quote closes the literal and
+		// reopens it at once, so it stays inside.
+		if ch == '\'' || ch == '"' {
+			if !inString {
+				inString = true
+				stringChar = ch
+			} else if ch == stringChar {
+				inString = false
+			}
+		}
+
+		// Handle comments
+		if !inString && ch == '-' && i+1 < len(content) && content[i+1] == '-' {
+			// Skip to end of line
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+			continue
+		}
+
+		// Skip block comments, keeping /*+ ... */ optimizer hints intact
+		if !inString && ch == '/' && i+1 < len(content) && content[i+1] == '*' {
+			end := strings.Index(content[i+2:], "*/")
+			if end < 0 {
+				end = len(content)
+			} else {
+				end += i + 4
+			}
+			if strings.HasPrefix(content[i:], "/*+") {
+				current.WriteString(content[i:end])
+			} else {
+				current.WriteByte(' ')
+			}
+			i = end - 1
+			continue
+		}
+
+		// Statement separator; a CREATE TRIGGER body keeps its own
+		if !inString && ch == ';' && !InTriggerBody(current.String()) {
+			stmt := strings.TrimSpace(current.String())
+			if stmt != "" {
+				statements = append(statements, stmt)
+			}
+			current.Reset()
+			continue
+		}
+
+		current.WriteByte(ch)
+	}
+
+	// Handle last statement without semicolon
+	stmt := strings.TrimSpace(current.String())
+	if stmt != "" {
+		statements = append(statements, stmt)
+	}
+
+	return statements
+}
+
+// InTriggerBody reports whether statement is a CREATE TRIGGER whose
+// BEGIN ... END body is still open, so a ';' inside it does not end it
+func InTriggerBody(statement string) bool {
+	words := strings.Fields(strings.ToUpper(statement))
+	return len(words) > 2 && words[0] == "CREATE" && words[1] == "TRIGGER" &&
+		slices.Contains(words, "BEGIN") && words[len(words)-1] != "END"
+}
+
+// BindRowReferences replaces each name.column reference in query, such as
+// NEW.id, with the column's value in row as a string literal. A row value of
+// NullValue binds NULL. Quotes in a value are doubled, as the lexer reads
+// them. Referencing a column that is not in row is an error.
+func BindRowReferences(query, name string, row map[string]string) (string, error) {
+	prefix := strings.ToUpper(name) + "."
+	lexer := NewLexer(query)
+	var bound strings.Builder
+	last := 0
+	for token := lexer.NextToken(); token.Type != EOF; token = lexer.NextToken() {
+		if token.Type != Identifier || !strings.HasPrefix(strings.ToUpper(token.Value), prefix) {
+			continue
+		}
+		value, ok := row[token.Value[len(prefix):]]
+		if !ok {
+			return "", fmt.Errorf("unknown column %s", token.Value)
+		}
+		literal := "NULL"
+		if value != NullValue {
+			literal = "'" + strings.ReplaceAll(value, "'", "''") + "'"
+		}
+		bound.WriteString(query[last:lexer.tokenStart])
+		bound.WriteString(literal)
+		last = lexer.position
+	}
+	bound.WriteString(query[last:])
+	return bound.String(), nil
+}
+
+// RenameDatabaseReferences returns query with every name qualified by
+// database oldName, such as oldName.users or oldName.users.id, qualified by
+// newName instead. String literals and other names are left as written.
+func RenameDatabaseReferences(query, oldName, newName string) string {
+	prefix := oldName + "."
+	lexer := NewLexer(query)
+	var renamed strings.Builder
+	last := 0
+	for token := lexer.NextToken(); token.Type != EOF; token = lexer.NextToken() {
+		if token.Type != Identifier || !strings.HasPrefix(token.Value, prefix) {
+			continue
+		}
+		renamed.WriteString(query[last:lexer.tokenStart])
+		renamed.WriteString(newName + "." + token.Value[len(prefix):])
+		last = lexer.position
+	}
+	renamed.WriteString(query[last:])
+	return renamed.String()
+}
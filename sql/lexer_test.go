@@ -22,6 +22,64 @@ func TestLexer(t *testing.T) {
 				{EOF, ""},
 			},
 		},
+		{
+			"line comments",
+			"-- leading comment\nSELECT * -- trailing\nFROM test -- end",
+			[]Token{
+				{Select, "SELECT"},
+				{Wildcard, "*"},
+				{From, "FROM"},
+				{Identifier, "test"},
+				{EOF, ""},
+			},
+		},
+		{
+			"lowercase keywords",
+			"select * from test where x = 1 order by x desc",
+			[]Token{
+				{Select, "select"},
+				{Wildcard, "*"},
+				{From, "from"},
+				{Identifier, "test"},
+				{Where, "where"},
+				{Identifier, "x"},
+				{Equals, "="},
+				{Int, "1"},
+				{Order, "order"},
+				{By, "by"},
+				{Identifier, "x"},
+				{Desc, "desc"},
+				{EOF, ""},
+			},
+		},
+		{
+			"function names as columns",
+			"SELECT count (*), date, year FROM test",
+			[]Token{
+				{Select, "SELECT"},
+				{Count, "count"},
+				{ParenOpen, "("},
+				{Wildcard, "*"},
+				{ParenClose, ")"},
+				{Comma, ","},
+				{Identifier, "date"},
+				{Comma, ","},
+				{Identifier, "year"},
+				{From, "FROM"},
+				{Identifier, "test"},
+				{EOF, ""},
+			},
+		},
+		{
+			"lowercase primary key",
+			"id INT primary key",
+			[]Token{
+				{Identifier, "id"},
+				{Identifier, "INT"},
+				{PrimaryKey, "PRIMARY KEY"},
+				{EOF, ""},
+			},
+		},
 		{
 			"select columns",
 			"SELECT col_1, col_2 FROM test",
@@ -210,6 +268,25 @@ func TestLexer(t *testing.T) {
 				{EOF, ""},
 			},
 		},
+		{
+			"doubled quotes",
+			"SELECT * FROM test WHERE name = 'O''Brien' OR path = 'C:\\tmp\\'",
+			[]Token{
+				{Select, "SELECT"},
+				{Wildcard, "*"},
+				{From, "FROM"},
+				{Identifier, "test"},
+				{Where, "WHERE"},
+				{Identifier, "name"},
+				{Equals, "="},
+				{String, "O'Brien"},
+				{Or, "OR"},
+				{Identifier, "path"},
+				{Equals, "="},
+				{String, "C:\\tmp\\"},
+				{EOF, ""},
+			},
+		},
 		{
 			"create database",
 			"CREATE DATABASE test",
@@ -518,3 +595,66 @@ func TestLexer(t *testing.T) {
 		})
 	}
 }
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected int
+	}{
+		{"single statement", "SELECT * FROM test", 1},
+		{"two statements", "SELECT * FROM a; SELECT * FROM b", 2},
+		{"with semicolons", "INSERT INTO t VALUES (1); INSERT INTO t VALUES (2);", 2},
+		{"with comments", "-- comment\nSELECT * FROM test", 1},
+		{"multiline", "CREATE TABLE t (\n  id INT,\n  name STRING\n);", 1},
+		{"empty", "", 0},
+		{"only semicolons", ";;;", 0},
+		{"string with semicolon", "INSERT INTO t (s) VALUES ('a;b')", 1},
+		{"doubled quote", "INSERT INTO t (s) VALUES ('it''s; fine'); SELECT * FROM t", 2},
+		{"trailing backslash", "INSERT INTO t (s) VALUES ('C:\\'); SELECT * FROM t", 2},
+		{"block comment", "/* a; b */ SELECT * FROM test", 1},
+		{"only block comment", "/* nothing here */;", 0},
+		{"hint with semicolon", "SELECT /*+ NO_INDEX; */ * FROM a; SELECT * FROM b", 2},
+		{"trigger body", "CREATE TRIGGER t AFTER INSERT ON db.a BEGIN INSERT INTO db.b (id) VALUES (NEW.id); DELETE FROM db.c WHERE id = NEW.id; END; SELECT * FROM db.b", 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := SplitStatements(test.input)
+			if len(result) != test.expected {
+				t.Errorf("SplitStatements(%q) = %d statements, expected %d", test.input, len(result), test.expected)
+			}
+		})
+	}
+}
+
+func TestBindRowReferences(t *testing.T) {
+	row := map[string]string{"id": "7", "name": "Ann", "note": NullValue}
+	bound, err := BindRowReferences("INSERT INTO db.audit (id, name, note, src) VALUES (new.id, NEW.name, NEW.note, 'NEW.id')", "NEW", row)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "INSERT INTO db.audit (id, name, note, src) VALUES ('7', 'Ann', NULL, 'NEW.id')"
+	if bound != expected {
+		t.Errorf("Expected %q, got %q", expected, bound)
+	}
+
+	if _, err := BindRowReferences("DELETE FROM db.a WHERE id = NEW.missing", "NEW", row); err == nil {
+		t.Error("Expected error for unknown column")
+	}
+	bound, err = BindRowReferences("DELETE FROM db.a WHERE name = NEW.name", "NEW", map[string]string{"name": "it's"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := "DELETE FROM db.a WHERE name = 'it''s'"; bound != want {
+		t.Errorf("Expected %q, got %q", want, bound)
+	}
+}
+
+func TestRenameDatabaseReferences(t *testing.T) {
+	query := "SELECT u.name, old.orders.id FROM old.users u JOIN old.orders ON u.id = old.orders.user_id JOIN share.old.items i ON i.id = u.id WHERE u.note = 'old.users'"
+	expected := "SELECT u.name, new.orders.id FROM new.users u JOIN new.orders ON u.id = new.orders.user_id JOIN share.old.items i ON i.id = u.id WHERE u.note = 'old.users'"
+	if renamed := RenameDatabaseReferences(query, "old", "new"); renamed != expected {
+		t.Errorf("Expected %q, got %q", expected, renamed)
+	}
+}
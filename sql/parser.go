@@ -1,13 +1,36 @@
 package sql
 
 import (
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/nickyhof/CommitDB/core"
 )
 
+// ErrEmptyStatement is returned when the input contains no statement,
+// only whitespace, comments or semicolons.
+var ErrEmptyStatement = errors.New("empty statement")
+
+// SyntaxError is returned by Parse for input that is not a valid statement.
+// Position is the 1-based byte offset of the token where parsing failed.
+type SyntaxError struct {
+	Position int
+	Err      error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%v (at position %d)", e.Err, e.Position)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
 type StatementType int
 
 const (
@@ -52,6 +75,23 @@ const (
 	DropViewStatementType
 	ShowViewsStatementType
 	RefreshViewStatementType
+	RepairTableStatementType
+	ShowMergeBaseStatementType
+	FlushStatementType
+	ShowTableStatusStatementType
+	CreateTriggerStatementType
+	DropTriggerStatementType
+	ShowTriggersStatementType
+	ShowWarningsStatementType
+	SetVariableStatementType
+	ShowVariablesStatementType
+	ShowStatsStatementType
+	AlterDatabaseStatementType
+	ShowCreateViewStatementType
+	CheckDatabaseStatementType
+	SavepointStatementType
+	ReleaseSavepointStatementType
+	ShowCreateTableStatementType
 )
 
 type Statement interface {
@@ -59,23 +99,33 @@ type Statement interface {
 }
 
 type SelectStatement struct {
-	Share      string // For 3-level naming: share.database.table
-	Database   string
-	Table      string
-	TableAlias string
-	Columns    []string
-	Aggregates []AggregateExpr
-	Functions  []FunctionExpr // String functions like UPPER, LOWER, etc.
-	Joins      []JoinClause
-	Distinct   bool
-	CountAll   bool
-	Where      WhereClause
-	GroupBy    []string
-	Having     WhereClause
-	OrderBy    []OrderByClause
-	Limit      int
-	Offset     int
-	AsOf       string // Transaction ID for time-travel queries
+	Share            string // For 3-level naming: share.database.table
+	Database         string
+	Table            string
+	TableAlias       string
+	Columns          []string
+	Literals         map[string]string // Constant select-list columns: output name to value; the names also appear in Columns
+	Aggregates       []AggregateExpr
+	Functions        []FunctionExpr // String functions like UPPER, LOWER, etc.
+	FunctionsAfter   []int          // For each of Functions, the number of Columns written before it in the select list
+	Joins            []JoinClause
+	Distinct         bool
+	DistinctOn       []string // DISTINCT ON (columns): the first row, after ORDER BY, per distinct key
+	Where            WhereClause
+	GroupBy          []string
+	Computed         []FunctionExpr // Function calls used by GROUP BY or aggregate arguments, referenced by Name()
+	Having           WhereClause
+	HavingAggregates []AggregateExpr // Aggregate calls the HAVING clause compares, referenced by Name()
+	OrderBy          []OrderByClause
+	Limit            int  // LIMIT n; 0 returns every row unless LimitSet
+	LimitSet         bool // LIMIT or FETCH FIRST was given, so a Limit of 0 returns no rows
+	Offset           int
+	AsOf             string // Transaction ID for time-travel queries
+	NoIndex          bool   // /*+ NO_INDEX */ hint: always scan instead of probing indexes
+
+	// Deprecated: COUNT(*) is parsed into Aggregates. A statement built with
+	// CountAll set and no Aggregates still runs as SELECT COUNT(*).
+	CountAll bool
 }
 
 type JoinClause struct {
@@ -91,7 +141,19 @@ type JoinClause struct {
 type AggregateExpr struct {
 	Function string // COUNT, SUM, AVG, MIN, MAX
 	Column   string
+	Distinct bool // COUNT(DISTINCT col): each distinct non-NULL value counts once
 	Alias    string
+	Filter   WhereClause // FILTER (WHERE ...): only matching rows are aggregated
+}
+
+// Name returns the call as written without its alias, e.g.
+// "COUNT(DISTINCT project)". It names the aggregate's output column when
+// there is no alias.
+func (agg AggregateExpr) Name() string {
+	if agg.Distinct {
+		return agg.Function + "(DISTINCT " + agg.Column + ")"
+	}
+	return agg.Function + "(" + agg.Column + ")"
 }
 
 // FunctionExpr represents a function call like UPPER(column), CONCAT(a, b)
@@ -99,20 +161,61 @@ type FunctionExpr struct {
 	Function string   // UPPER, LOWER, CONCAT, SUBSTRING, TRIM, LENGTH, REPLACE
 	Args     []string // Arguments (column names or literals)
 	Alias    string   // Optional AS alias
+	Literal  []bool   // Which Args were written as literals; nil when none was
+}
+
+// IsLiteral reports whether argument i was written as a literal rather than
+// a column name.
+func (fn FunctionExpr) IsLiteral(i int) bool {
+	return i < len(fn.Literal) && fn.Literal[i]
+}
+
+// addArg appends an argument, recording in Literal whether it is a literal.
+func (fn *FunctionExpr) addArg(value string, literal bool) {
+	if literal && fn.Literal == nil {
+		fn.Literal = make([]bool, len(fn.Args), len(fn.Args)+1)
+	}
+	fn.Args = append(fn.Args, value)
+	if fn.Literal != nil {
+		fn.Literal = append(fn.Literal, literal)
+	}
+}
+
+// Name returns the call as written without its alias, e.g. "YEAR(created)".
+// It names the function's output column when there is no alias.
+func (fn FunctionExpr) Name() string {
+	return fn.Function + "(" + strings.Join(fn.Args, ", ") + ")"
 }
 
+// NullValue is the value of a NULL literal in INSERT values and UPDATE SET
+// clauses. Stored rows omit NULL columns, which keeps them distinct from the
+// empty string.
+const NullValue = "\x00NULL"
+
+// DefaultValue is the value of the DEFAULT keyword in INSERT values and
+// UPDATE SET clauses: the column's DEFAULT, or NULL when it has none.
+const DefaultValue = "\x00DEFAULT"
+
+// NowValue is the value of NOW() in INSERT values: the current date or
+// timestamp, as suits the column. A quoted 'NOW()' is an ordinary string.
+const NowValue = "\x00NOW"
+
 type InsertStatement struct {
 	Database  string
 	Table     string
 	Columns   []string
 	ValueRows [][]string // Multiple rows for bulk insert: VALUES (v1), (v2), ...
+	Returning []string   // RETURNING columns; "*" returns every column
 }
 
 type UpdateStatement struct {
-	Database string
-	Table    string
-	Updates  []SetClause
-	Where    WhereClause
+	Database  string
+	Table     string
+	Updates   []SetClause
+	Where     WhereClause
+	Limit     int      // LIMIT n: update at most n matching rows, in primary key order; 0 updates all
+	Returning []string // RETURNING columns (post-update values); "*" returns every column
+	DryRun    bool     // DRY RUN: report the rows that would change without committing
 }
 
 type SetClause struct {
@@ -121,21 +224,32 @@ type SetClause struct {
 }
 
 type DeleteStatement struct {
-	Database string
-	Table    string
-	Where    WhereClause
+	Database  string
+	Table     string
+	Where     WhereClause
+	Limit     int      // LIMIT n: delete at most n matching rows, in primary key order; 0 deletes all
+	Returning []string // RETURNING columns of the deleted rows; "*" returns every column
+	DryRun    bool     // DRY RUN: report the rows that would be deleted without committing
 }
 
 type CreateTableStatement struct {
 	Database string
 	Table    string
 	Columns  []core.Column
+	Fanout   int    // WITH FANOUT n: hash-prefix directory levels for row storage
+	Comment  string // COMMENT 'text' after the column list
+	// CHECK conditions, as written, from column and table constraints
+	Checks []string
+	Author *core.Identity // AUTHOR 'Name <email>': commit author for row writes
+	// VALUES rows, in column order, written in the same commit as the table
+	ValueRows [][]string
 }
 
 type DropTableStatement struct {
 	Database string
 	Table    string
 	IfExists bool
+	DryRun   bool // DRY RUN: report what would be dropped without committing
 }
 
 type ShowDatabasesStatement struct {
@@ -145,6 +259,11 @@ type ShowTablesStatement struct {
 	Database string
 }
 
+// ShowTableStatusStatement reports row counts and storage size per table
+type ShowTableStatusStatement struct {
+	Database string
+}
+
 type WhereClause struct {
 	Conditions []WhereCondition
 	LogicalOps []LogicalOperator // AND/OR between conditions
@@ -161,10 +280,32 @@ type WhereCondition struct {
 	Left     string
 	Operator WhereOperator
 	Right    string
-	InValues []string // for IN operator
+	InValues []string // for IN, where NullValue stands for NULL, and the patterns of LIKE ANY and LIKE ALL
 	Negated  bool     // for NOT
+	// InSet holds InValues for constant-time membership tests, as built by
+	// InValueSet; when nil, InValues are searched in order.
+	InSet map[string]struct{}
+}
+
+// InSetThreshold is the IN list length above which InValueSet builds a set.
+const InSetThreshold = 16
+
+// InValueSet returns the set of an IN list's values, or nil for lists of
+// InSetThreshold values or fewer, which are as quick to search in order.
+func InValueSet(values []string) map[string]struct{} {
+	if len(values) <= InSetThreshold {
+		return nil
+	}
+	set := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		set[value] = struct{}{}
+	}
+	return set
 }
 
+// DefaultMaxInValues is the longest IN list a new Parser accepts.
+const DefaultMaxInValues = 10000
+
 type WhereOperator int
 
 const (
@@ -178,6 +319,8 @@ const (
 	IsNullOperator
 	IsNotNullOperator
 	InOperator
+	LikeAnyOperator // LIKE ANY (patterns): matches when any pattern does
+	LikeAllOperator // LIKE ALL (patterns): matches when every pattern does
 )
 
 type OrderByClause struct {
@@ -192,6 +335,7 @@ type CreateDatabaseStatement struct {
 type DropDatabaseStatement struct {
 	Database string
 	IfExists bool
+	DryRun   bool // DRY RUN: report what would be dropped without committing
 }
 
 type CreateIndexStatement struct {
@@ -211,21 +355,51 @@ type DropIndexStatement struct {
 type AlterTableStatement struct {
 	Database      string
 	Table         string
-	Action        string // ADD, DROP, MODIFY, RENAME
+	Action        string // ADD, DROP, MODIFY, RENAME, AUTHOR
 	ColumnName    string
 	NewColumnName string // for RENAME
 	ColumnType    string
+	NotNull       bool           // for ADD ... NOT NULL
+	Default       *string        // for ADD ... DEFAULT value; nil for no DEFAULT
+	Author        *core.Identity // for SET AUTHOR; nil for SET AUTHOR NULL
+}
+
+// AlterDatabaseStatement renames a database: ALTER DATABASE name RENAME TO newname
+type AlterDatabaseStatement struct {
+	Database string
+	NewName  string
 }
 
-type BeginStatement struct{}
+type BeginStatement struct {
+	ReadOnly bool // BEGIN READ ONLY: reads see one snapshot and writes are rejected
+}
 type CommitStatement struct{}
-type RollbackStatement struct{}
+
+// RollbackStatement ends the open transaction, discarding its writes, or with
+// a Savepoint only discards the writes made since SAVEPOINT of that name.
+type RollbackStatement struct {
+	Savepoint string
+}
+
+// SavepointStatement marks a point in the open transaction that ROLLBACK TO
+// can return to: SAVEPOINT name
+type SavepointStatement struct {
+	Name string
+}
+
+// ReleaseSavepointStatement forgets a savepoint and every later one, keeping
+// their writes: RELEASE [SAVEPOINT] name
+type ReleaseSavepointStatement struct {
+	Name string
+}
 
 type DescribeStatement struct {
 	Database string
 	Table    string
 }
 
+// ShowIndexesStatement lists the indexes on a table, or on every table of
+// Database when Table is empty (SHOW INDEXES IN database)
 type ShowIndexesStatement struct {
 	Database string
 	Table    string
@@ -234,11 +408,17 @@ type ShowIndexesStatement struct {
 // CopyStatement for bulk data import/export
 type CopyStatement struct {
 	Direction string // "INTO_TABLE" or "INTO_FILE"
+	Share     string // Share an export reads from (share.database.table); empty reads locally
 	Database  string
 	Table     string
 	FilePath  string
-	Header    bool   // Include/expect header row
-	Delimiter string // Column delimiter (default ",")
+	Header    bool             // Include/expect header row
+	Delimiter string           // Column delimiter (default ",")
+	Format    string           // File format: "CSV" (default), "PARQUET" (export only) or "JSON" (import only)
+	Query     *SelectStatement // Query to export for COPY INTO 'file' FROM (SELECT ...); nil exports Table
+	// ON_CONFLICT = 'UPDATE' or 'IGNORE' for imports: what a row whose primary
+	// key already exists does. Empty replaces the stored row.
+	OnConflict string
 	// S3 configuration (optional)
 	S3AccessKey string
 	S3SecretKey string
@@ -262,19 +442,31 @@ type MergeStatement struct {
 
 type ShowBranchesStatement struct{}
 
-type ShowMergeConflictsStatement struct{}
+type ShowMergeConflictsStatement struct {
+	Limit  int // 0 lists every conflict
+	Offset int
+}
+
+type ShowMergeBaseStatement struct {
+	BranchA string
+	BranchB string
+}
 
 type ResolveConflictStatement struct {
 	Database   string
 	Table      string
 	Key        string
 	Resolution string // "HEAD", "SOURCE", or a literal value
+	All        bool   // RESOLVE ALL CONFLICTS; Database, Table and Key are empty
 }
 
 type CommitMergeStatement struct{}
 
 type AbortMergeStatement struct{}
 
+// FlushStatement commits writes buffered by persistence write-behind
+type FlushStatement struct{}
+
 // AuthConfig represents authentication configuration for remote operations
 type AuthConfig struct {
 	Token      string // Token-based authentication
@@ -356,6 +548,10 @@ func (s AlterTableStatement) Type() StatementType {
 	return AlterTableStatementType
 }
 
+func (s AlterDatabaseStatement) Type() StatementType {
+	return AlterDatabaseStatementType
+}
+
 func (s BeginStatement) Type() StatementType {
 	return BeginStatementType
 }
@@ -368,6 +564,14 @@ func (s RollbackStatement) Type() StatementType {
 	return RollbackStatementType
 }
 
+func (s SavepointStatement) Type() StatementType {
+	return SavepointStatementType
+}
+
+func (s ReleaseSavepointStatement) Type() StatementType {
+	return ReleaseSavepointStatementType
+}
+
 func (s DescribeStatement) Type() StatementType {
 	return DescribeStatementType
 }
@@ -384,6 +588,10 @@ func (s ShowTablesStatement) Type() StatementType {
 	return ShowTablesStatementType
 }
 
+func (s ShowTableStatusStatement) Type() StatementType {
+	return ShowTableStatusStatementType
+}
+
 func (s CreateBranchStatement) Type() StatementType {
 	return CreateBranchStatementType
 }
@@ -404,6 +612,10 @@ func (s ShowMergeConflictsStatement) Type() StatementType {
 	return ShowMergeConflictsStatementType
 }
 
+func (s ShowMergeBaseStatement) Type() StatementType {
+	return ShowMergeBaseStatementType
+}
+
 func (s ResolveConflictStatement) Type() StatementType {
 	return ResolveConflictStatementType
 }
@@ -484,6 +696,12 @@ type CreateViewStatement struct {
 	ViewName     string
 	SelectQuery  string // Raw SQL for the view definition
 	Materialized bool
+	OrReplace    bool // CREATE OR REPLACE: overwrite an existing view definition
+
+	// WITH AUTO REFRESH: refresh a materialized view after every write to its
+	// source tables, or with EVERY '<duration>' when read after that long
+	AutoRefresh     bool
+	RefreshInterval time.Duration
 }
 
 type DropViewStatement struct {
@@ -492,10 +710,26 @@ type DropViewStatement struct {
 	IfExists bool
 }
 
+// ShowViewsStatement lists the views of Database, or of every database when
+// Database is empty (SHOW VIEWS without IN)
 type ShowViewsStatement struct {
 	Database string
 }
 
+// ShowCreateViewStatement shows the stored definition of a view:
+// SHOW CREATE VIEW database.name
+type ShowCreateViewStatement struct {
+	Database string
+	ViewName string
+}
+
+// ShowCreateTableStatement shows a CREATE TABLE statement that recreates a
+// table's schema: SHOW CREATE TABLE database.name
+type ShowCreateTableStatement struct {
+	Database string
+	Table    string
+}
+
 type RefreshViewStatement struct {
 	Database string
 	ViewName string
@@ -513,22 +747,134 @@ func (s ShowViewsStatement) Type() StatementType {
 	return ShowViewsStatementType
 }
 
+func (s ShowCreateViewStatement) Type() StatementType {
+	return ShowCreateViewStatementType
+}
+
+func (s ShowCreateTableStatement) Type() StatementType {
+	return ShowCreateTableStatementType
+}
+
 func (s RefreshViewStatement) Type() StatementType {
 	return RefreshViewStatementType
 }
 
+// Trigger statements
+type CreateTriggerStatement struct {
+	Database string
+	Name     string
+	Table    string // Table in Database whose writes fire the trigger
+	Event    string // "INSERT", "UPDATE" or "DELETE"
+	Body     string // Raw SQL between BEGIN and END; statements are separated by ';'
+}
+
+type DropTriggerStatement struct {
+	Database string
+	Name     string
+	IfExists bool
+}
+
+type ShowTriggersStatement struct {
+	Database string
+}
+
+func (s CreateTriggerStatement) Type() StatementType {
+	return CreateTriggerStatementType
+}
+
+func (s DropTriggerStatement) Type() StatementType {
+	return DropTriggerStatementType
+}
+
+func (s ShowTriggersStatement) Type() StatementType {
+	return ShowTriggersStatementType
+}
+
+// ShowWarningsStatement lists the warnings raised by the previous statement
+type ShowWarningsStatement struct{}
+
+func (s ShowWarningsStatement) Type() StatementType {
+	return ShowWarningsStatementType
+}
+
+// SetVariableStatement changes a session setting: SET name = value
+type SetVariableStatement struct {
+	Name  string // Lower-cased setting name
+	Value string // Value as written, without quotes
+}
+
+// ShowVariablesStatement lists the session settings
+type ShowVariablesStatement struct{}
+
+func (s SetVariableStatement) Type() StatementType {
+	return SetVariableStatementType
+}
+
+func (s ShowVariablesStatement) Type() StatementType {
+	return ShowVariablesStatementType
+}
+
+// ShowStatsStatement reports object, pack and commit counts for the repository
+type ShowStatsStatement struct{}
+
+func (s ShowStatsStatement) Type() StatementType {
+	return ShowStatsStatementType
+}
+
+// RepairTableStatement rewrites stored rows to match the current table schema
+type RepairTableStatement struct {
+	Database string
+	Table    string
+}
+
+func (s RepairTableStatement) Type() StatementType {
+	return RepairTableStatementType
+}
+
+// CheckDatabaseStatement verifies stored schemas, rows and indexes without
+// changing them. An empty Database checks every database.
+type CheckDatabaseStatement struct {
+	Database string
+}
+
+func (s CheckDatabaseStatement) Type() StatementType {
+	return CheckDatabaseStatementType
+}
+
+func (s FlushStatement) Type() StatementType {
+	return FlushStatementType
+}
+
 type Parser struct {
 	lexer *Lexer
+	// MaxInValues is the longest IN list accepted; longer lists fail to
+	// parse. Zero or less removes the limit.
+	MaxInValues int
 }
 
 func NewParser(sql string) *Parser {
 	lexer := NewLexer(sql)
-	return &Parser{lexer: lexer}
+	return &Parser{lexer: lexer, MaxInValues: DefaultMaxInValues}
 }
 
+// Parse parses the next statement. Invalid input fails with a *SyntaxError.
 func (parser *Parser) Parse() (Statement, error) {
+	statement, err := parser.parseStatement()
+	if err != nil && err != ErrEmptyStatement {
+		return nil, &SyntaxError{Position: parser.lexer.tokenStart + 1, Err: err}
+	}
+	return statement, err
+}
+
+func (parser *Parser) parseStatement() (Statement, error) {
 	token := parser.lexer.NextToken()
+	// Skip stray semicolons left by scripts and clients
+	for token.Type == Unknown && token.Value == ";" {
+		token = parser.lexer.NextToken()
+	}
 	switch token.Type {
+	case EOF:
+		return nil, ErrEmptyStatement
 	case Select:
 		return ParseSelect(parser)
 	case Insert:
@@ -544,7 +890,7 @@ func (parser *Parser) Parse() (Statement, error) {
 	case Alter:
 		return ParseAlter(parser)
 	case Begin:
-		return BeginStatement{}, nil
+		return ParseBegin(parser)
 	case Commit:
 		// Could be regular COMMIT or COMMIT MERGE
 		nextToken := parser.lexer.PeekToken()
@@ -554,7 +900,9 @@ func (parser *Parser) Parse() (Statement, error) {
 		}
 		return CommitStatement{}, nil
 	case Rollback:
-		return RollbackStatement{}, nil
+		return ParseRollback(parser)
+	case Set:
+		return ParseSetVariable(parser)
 	case Describe:
 		return ParseDescribe(parser)
 	case Show:
@@ -579,458 +927,177 @@ func (parser *Parser) Parse() (Statement, error) {
 		return ParseSyncShare(parser)
 	case Refresh:
 		return ParseRefreshView(parser)
+	case Repair:
+		return ParseRepairTable(parser)
+	case Identifier:
+		// FLUSH, DRY, CHECK, SAVEPOINT and RELEASE are not reserved, so they can
+		// still name columns and tables
+		if strings.ToUpper(token.Value) == "FLUSH" {
+			return FlushStatement{}, nil
+		}
+		if strings.ToUpper(token.Value) == "SAVEPOINT" {
+			name, err := parseSavepointName(parser, "SAVEPOINT")
+			if err != nil {
+				return nil, err
+			}
+			return SavepointStatement{Name: name}, nil
+		}
+		if strings.ToUpper(token.Value) == "RELEASE" {
+			if isWord(parser.lexer.PeekToken(), "SAVEPOINT") {
+				parser.lexer.NextToken()
+			}
+			name, err := parseSavepointName(parser, "RELEASE")
+			if err != nil {
+				return nil, err
+			}
+			return ReleaseSavepointStatement{Name: name}, nil
+		}
+		if strings.ToUpper(token.Value) == "DRY" {
+			return parseDryRun(parser)
+		}
+		if strings.ToUpper(token.Value) == "CHECK" {
+			return parseCheckDatabase(parser)
+		}
+		return nil, errors.New("unknown statement type")
 	default:
 		return nil, errors.New("unknown statement type")
 	}
 }
 
+// parseDryRun parses DRY RUN {UPDATE | DELETE | DROP TABLE | DROP DATABASE} ...
+// after DRY
+func parseDryRun(parser *Parser) (Statement, error) {
+	if !isWord(parser.lexer.NextToken(), "RUN") {
+		return nil, errors.New("expected RUN after DRY")
+	}
+	statement, err := parser.parseStatement()
+	if err == ErrEmptyStatement {
+		return nil, errors.New("expected statement after DRY RUN")
+	} else if err != nil {
+		return nil, err
+	}
+	switch stmt := statement.(type) {
+	case UpdateStatement:
+		stmt.DryRun = true
+		return stmt, nil
+	case DeleteStatement:
+		stmt.DryRun = true
+		return stmt, nil
+	case DropTableStatement:
+		stmt.DryRun = true
+		return stmt, nil
+	case DropDatabaseStatement:
+		stmt.DryRun = true
+		return stmt, nil
+	}
+	return nil, errors.New("DRY RUN applies to UPDATE, DELETE, DROP TABLE and DROP DATABASE")
+}
+
 func ParseSelect(parser *Parser) (Statement, error) {
 	var selectStatement SelectStatement
 
 	token := parser.lexer.NextToken()
 
-	// Check for DISTINCT
+	// Check for DISTINCT or DISTINCT ON (columns)
 	if token.Type == Distinct {
-		selectStatement.Distinct = true
+		if parser.lexer.PeekToken().Type == On {
+			parser.lexer.NextToken() // consume ON
+			columns, err := parseDistinctOn(parser)
+			if err != nil {
+				return nil, err
+			}
+			selectStatement.DistinctOn = columns
+		} else {
+			selectStatement.Distinct = true
+		}
 		token = parser.lexer.NextToken()
 	}
 
-	// Check for COUNT(*)
-	if token.Type == Count {
-		token = parser.lexer.NextToken()
-		if token.Type != ParenOpen {
-			return nil, errors.New("expected '(' after COUNT")
-		}
-		token = parser.lexer.NextToken()
-		switch token.Type {
-		case Wildcard:
-			token = parser.lexer.NextToken()
-			if token.Type != ParenClose {
-				return nil, errors.New("expected ')' after COUNT(*")
-			}
-			selectStatement.CountAll = true
-			selectStatement.Columns = []string{}
-			token = parser.lexer.NextToken()
-		case Identifier:
-			// COUNT(column)
-			col := token.Value
-			token = parser.lexer.NextToken()
-			if token.Type != ParenClose {
-				return nil, errors.New("expected ')' after column name")
+	// Parse the select list: columns, *, aggregates and function calls
+	wildcard := false
+	for {
+		if name, ok := aggregateNames[token.Type]; ok {
+			agg, err := parseAggregate(parser, name, &selectStatement)
+			if err != nil {
+				return nil, err
 			}
-			selectStatement.Aggregates = append(selectStatement.Aggregates, AggregateExpr{
-				Function: "COUNT",
-				Column:   col,
-			})
-			token = parser.lexer.NextToken()
-		default:
-			return nil, errors.New("expected '*' or column name in COUNT()")
-		}
-	} else if token.Type == Sum || token.Type == Avg || token.Type == Min || token.Type == Max {
-		// Parse aggregate function: SUM(col), AVG(col), MIN(col), MAX(col)
-		for {
-			funcName := ""
-			switch token.Type {
-			case Sum:
-				funcName = "SUM"
-			case Avg:
-				funcName = "AVG"
-			case Min:
-				funcName = "MIN"
-			case Max:
-				funcName = "MAX"
-			case Count:
-				funcName = "COUNT"
-			}
-
-			if funcName == "" {
-				break
+			selectStatement.Aggregates = append(selectStatement.Aggregates, agg)
+		} else if name, ok := functionCallName(parser, token); ok {
+			fn, err := parseFunctionCall(parser, name)
+			if err != nil {
+				return nil, err
 			}
-
-			token = parser.lexer.NextToken()
-			if token.Type != ParenOpen {
-				return nil, errors.New("expected '(' after " + funcName)
+			fn.Alias, err = parseOptionalAlias(parser)
+			if err != nil {
+				return nil, err
 			}
-			token = parser.lexer.NextToken()
-			if token.Type != Identifier {
-				return nil, errors.New("expected column name in " + funcName + "()")
+			selectStatement.Functions = append(selectStatement.Functions, fn)
+			selectStatement.FunctionsAfter = append(selectStatement.FunctionsAfter, len(selectStatement.Columns))
+		} else if token.Type == Wildcard {
+			wildcard = true
+			if selectStatement.Columns == nil {
+				selectStatement.Columns = []string{}
 			}
-			col := token.Value
-			token = parser.lexer.NextToken()
-			if token.Type != ParenClose {
-				return nil, errors.New("expected ')' after column name")
+		} else if isColumnName(token) && isIntervalOperator(parser.lexer.PeekToken()) {
+			fn, err := parseIntervalArithmetic(parser, token.Value)
+			if err != nil {
+				return nil, err
 			}
-
-			agg := AggregateExpr{
-				Function: funcName,
-				Column:   col,
+			if alias, err := parseOptionalAlias(parser); err != nil {
+				return nil, err
+			} else if alias != "" {
+				fn.Alias = alias
 			}
-
-			// Check for AS alias
-			token = parser.lexer.NextToken()
-			if token.Type == As {
-				token = parser.lexer.NextToken()
-				if token.Type != Identifier {
-					return nil, errors.New("expected alias after AS")
-				}
-				agg.Alias = token.Value
-				token = parser.lexer.NextToken()
+			selectStatement.Functions = append(selectStatement.Functions, fn)
+			selectStatement.FunctionsAfter = append(selectStatement.FunctionsAfter, len(selectStatement.Columns))
+		} else if isColumnName(token) {
+			selectStatement.Columns = append(selectStatement.Columns, parseSelectColumn(parser, token))
+		} else if token.Type == String || token.Type == Int || token.Type == Float {
+			name, err := parseSelectLiteral(parser, token, &selectStatement)
+			if err != nil {
+				return nil, err
 			}
+			selectStatement.Columns = append(selectStatement.Columns, name)
+		} else {
+			return nil, errors.New("expected column name, literal, *, DISTINCT, COUNT, SUM, AVG, MIN, MAX, or function")
+		}
+		if isWord(parser.lexer.PeekToken(), "OVER") {
+			return nil, errors.New("window functions (OVER) are not supported")
+		}
 
-			selectStatement.Aggregates = append(selectStatement.Aggregates, agg)
-
-			// Check for comma (more aggregates) or break
-			if token.Type == Comma {
-				token = parser.lexer.NextToken()
-				continue
-			}
+		token = parser.lexer.NextToken()
+		if token.Type != Comma {
 			break
 		}
-	} else if token.Type == Upper || token.Type == Lower || token.Type == Concat ||
-		token.Type == Substring || token.Type == Trim || token.Type == Length || token.Type == Replace {
-		// Parse string functions: UPPER(col), LOWER(col), CONCAT(a, b), SUBSTRING(col, start, len), etc.
-		for {
-			funcName := ""
-			switch token.Type {
-			case Upper:
-				funcName = "UPPER"
-			case Lower:
-				funcName = "LOWER"
-			case Concat:
-				funcName = "CONCAT"
-			case Substring:
-				funcName = "SUBSTRING"
-			case Trim:
-				funcName = "TRIM"
-			case Length:
-				funcName = "LENGTH"
-			case Replace:
-				funcName = "REPLACE"
-			}
-
-			if funcName == "" {
-				break
-			}
+		token = parser.lexer.NextToken()
+	}
+	if wildcard && (len(selectStatement.Columns) > 0 || len(selectStatement.Aggregates) > 0 || len(selectStatement.Functions) > 0) {
+		return nil, errors.New("* cannot be combined with other select expressions")
+	}
 
-			token = parser.lexer.NextToken()
-			if token.Type != ParenOpen {
-				return nil, errors.New("expected '(' after " + funcName)
-			}
+	if token.Type != From {
+		return nil, errors.New("expected FROM")
+	}
 
-			// Parse function arguments
-			var args []string
-			for {
-				token = parser.lexer.NextToken()
-				if token.Type == Identifier || token.Type == String || token.Type == Int {
-					args = append(args, token.Value)
-				} else {
-					return nil, errors.New("expected argument in " + funcName + "()")
-				}
+	token = parser.lexer.NextToken()
+	if token.Type != Identifier {
+		return nil, errors.New("expected table name")
+	}
 
-				token = parser.lexer.NextToken()
-				if token.Type == ParenClose {
-					break
-				}
-				if token.Type != Comma {
-					return nil, errors.New("expected ',' or ')' in " + funcName + "()")
-				}
-			}
+	parts := strings.Split(token.Value, ".")
+	if len(parts) == 3 {
+		// share.database.table format
+		selectStatement.Share = parts[0]
+		selectStatement.Database = parts[1]
+		selectStatement.Table = parts[2]
+	} else if len(parts) == 2 {
+		selectStatement.Database = parts[0]
+		selectStatement.Table = parts[1]
+	} else {
+		return nil, errors.New("expected database.table or share.database.table format")
+	}
 
-			fn := FunctionExpr{
-				Function: funcName,
-				Args:     args,
-			}
-
-			// Check for AS alias
-			token = parser.lexer.NextToken()
-			if token.Type == As {
-				token = parser.lexer.NextToken()
-				if token.Type != Identifier {
-					return nil, errors.New("expected alias after AS")
-				}
-				fn.Alias = token.Value
-				token = parser.lexer.NextToken()
-			}
-
-			selectStatement.Functions = append(selectStatement.Functions, fn)
-
-			// Check for comma (more functions/columns) or break
-			if token.Type == Comma {
-				token = parser.lexer.NextToken()
-				// Check if next is another function
-				if token.Type == Upper || token.Type == Lower || token.Type == Concat ||
-					token.Type == Substring || token.Type == Trim || token.Type == Length || token.Type == Replace {
-					continue
-				}
-				// Otherwise it might be a column
-				if token.Type == Identifier {
-					selectStatement.Columns = append(selectStatement.Columns, token.Value)
-					for {
-						token = parser.lexer.NextToken()
-						if token.Type == Comma {
-							token = parser.lexer.NextToken()
-							if token.Type == Identifier {
-								selectStatement.Columns = append(selectStatement.Columns, token.Value)
-							} else {
-								break // might be an aggregate or function
-							}
-						} else {
-							break
-						}
-					}
-				}
-			}
-			break
-		}
-	} else if token.Type == Now || token.Type == DateAdd || token.Type == DateSub ||
-		token.Type == DateDiff || token.Type == DateFunc || token.Type == Year ||
-		token.Type == Month || token.Type == Day || token.Type == Hour ||
-		token.Type == Minute || token.Type == Second || token.Type == DateFormat {
-		// Parse date functions
-		for {
-			funcName := ""
-			switch token.Type {
-			case Now:
-				funcName = "NOW"
-			case DateAdd:
-				funcName = "DATE_ADD"
-			case DateSub:
-				funcName = "DATE_SUB"
-			case DateDiff:
-				funcName = "DATEDIFF"
-			case DateFunc:
-				funcName = "DATE"
-			case Year:
-				funcName = "YEAR"
-			case Month:
-				funcName = "MONTH"
-			case Day:
-				funcName = "DAY"
-			case Hour:
-				funcName = "HOUR"
-			case Minute:
-				funcName = "MINUTE"
-			case Second:
-				funcName = "SECOND"
-			case DateFormat:
-				funcName = "DATE_FORMAT"
-			}
-
-			if funcName == "" {
-				break
-			}
-
-			token = parser.lexer.NextToken()
-			if token.Type != ParenOpen {
-				return nil, errors.New("expected '(' after " + funcName)
-			}
-
-			// Parse function arguments (NOW() can have no args)
-			var args []string
-			token = parser.lexer.NextToken()
-			if token.Type != ParenClose {
-				for {
-					if token.Type == Identifier || token.Type == String || token.Type == Int {
-						args = append(args, token.Value)
-					} else {
-						return nil, errors.New("expected argument in " + funcName + "()")
-					}
-
-					token = parser.lexer.NextToken()
-					if token.Type == ParenClose {
-						break
-					}
-					if token.Type != Comma {
-						return nil, errors.New("expected ',' or ')' in " + funcName + "()")
-					}
-					token = parser.lexer.NextToken()
-				}
-			}
-
-			fn := FunctionExpr{
-				Function: funcName,
-				Args:     args,
-			}
-
-			// Check for AS alias
-			token = parser.lexer.NextToken()
-			if token.Type == As {
-				token = parser.lexer.NextToken()
-				if token.Type != Identifier {
-					return nil, errors.New("expected alias after AS")
-				}
-				fn.Alias = token.Value
-				token = parser.lexer.NextToken()
-			}
-
-			selectStatement.Functions = append(selectStatement.Functions, fn)
-
-			// Check for comma (more functions/columns) or break
-			if token.Type == Comma {
-				token = parser.lexer.NextToken()
-				continue
-			}
-			break
-		}
-	} else if token.Type == JsonExtract || token.Type == JsonSet || token.Type == JsonRemove ||
-		token.Type == JsonContains || token.Type == JsonKeys || token.Type == JsonLength || token.Type == JsonType {
-		// Parse JSON functions
-		for {
-			funcName := ""
-			switch token.Type {
-			case JsonExtract:
-				funcName = "JSON_EXTRACT"
-			case JsonSet:
-				funcName = "JSON_SET"
-			case JsonRemove:
-				funcName = "JSON_REMOVE"
-			case JsonContains:
-				funcName = "JSON_CONTAINS"
-			case JsonKeys:
-				funcName = "JSON_KEYS"
-			case JsonLength:
-				funcName = "JSON_LENGTH"
-			case JsonType:
-				funcName = "JSON_TYPE"
-			}
-			if funcName == "" {
-				break
-			}
-			token = parser.lexer.NextToken()
-			if token.Type != ParenOpen {
-				return nil, errors.New("expected '(' after " + funcName)
-			}
-			var args []string
-			token = parser.lexer.NextToken()
-			if token.Type != ParenClose {
-				for {
-					if token.Type == Identifier || token.Type == String || token.Type == Int {
-						args = append(args, token.Value)
-					} else {
-						return nil, errors.New("expected argument in " + funcName + "()")
-					}
-					token = parser.lexer.NextToken()
-					if token.Type == ParenClose {
-						break
-					}
-					if token.Type != Comma {
-						return nil, errors.New("expected ',' or ')' in " + funcName + "()")
-					}
-					token = parser.lexer.NextToken()
-				}
-			}
-			fn := FunctionExpr{Function: funcName, Args: args}
-			token = parser.lexer.NextToken()
-			if token.Type == As {
-				token = parser.lexer.NextToken()
-				if token.Type != Identifier {
-					return nil, errors.New("expected alias after AS")
-				}
-				fn.Alias = token.Value
-				token = parser.lexer.NextToken()
-			}
-			selectStatement.Functions = append(selectStatement.Functions, fn)
-			if token.Type == Comma {
-				token = parser.lexer.NextToken()
-				continue
-			}
-			break
-		}
-	} else if token.Type == Wildcard {
-		// Parse wildcard
-		selectStatement.Columns = []string{}
-		token = parser.lexer.NextToken()
-	} else if token.Type == Identifier {
-		// Parse columns (may be mixed with aggregates like: SELECT city, COUNT(*) ...)
-		selectStatement.Columns = append(selectStatement.Columns, token.Value)
-		for {
-			token = parser.lexer.NextToken()
-			if token.Type == Comma {
-				token = parser.lexer.NextToken()
-				if token.Type == Identifier {
-					selectStatement.Columns = append(selectStatement.Columns, token.Value)
-				} else if token.Type == Count {
-					// Parse COUNT(*) or COUNT(col)
-					token = parser.lexer.NextToken()
-					if token.Type != ParenOpen {
-						return nil, errors.New("expected '(' after COUNT")
-					}
-					token = parser.lexer.NextToken()
-					if token.Type == Wildcard {
-						selectStatement.Aggregates = append(selectStatement.Aggregates, AggregateExpr{Function: "COUNT", Column: "*"})
-						token = parser.lexer.NextToken()
-					} else if token.Type == Identifier {
-						selectStatement.Aggregates = append(selectStatement.Aggregates, AggregateExpr{Function: "COUNT", Column: token.Value})
-						token = parser.lexer.NextToken()
-					} else {
-						return nil, errors.New("expected '*' or column name in COUNT()")
-					}
-					if token.Type != ParenClose {
-						return nil, errors.New("expected ')' after COUNT argument")
-					}
-				} else if token.Type == Sum || token.Type == Avg || token.Type == Min || token.Type == Max {
-					// Parse SUM/AVG/MIN/MAX(col)
-					funcName := ""
-					switch token.Type {
-					case Sum:
-						funcName = "SUM"
-					case Avg:
-						funcName = "AVG"
-					case Min:
-						funcName = "MIN"
-					case Max:
-						funcName = "MAX"
-					}
-					token = parser.lexer.NextToken()
-					if token.Type != ParenOpen {
-						return nil, errors.New("expected '(' after " + funcName)
-					}
-					token = parser.lexer.NextToken()
-					if token.Type != Identifier {
-						return nil, errors.New("expected column name in " + funcName + "()")
-					}
-					col := token.Value
-					token = parser.lexer.NextToken()
-					if token.Type != ParenClose {
-						return nil, errors.New("expected ')' after column name")
-					}
-					selectStatement.Aggregates = append(selectStatement.Aggregates, AggregateExpr{Function: funcName, Column: col})
-				} else {
-					return nil, errors.New("expected identifier or aggregate function after comma")
-				}
-			} else if token.Type == From {
-				break
-			} else {
-				return nil, errors.New("expected FROM or comma")
-			}
-		}
-	} else {
-		return nil, errors.New("expected column name, *, DISTINCT, COUNT, SUM, AVG, MIN, MAX, or string function")
-	}
-
-	if token.Type != From {
-		return nil, errors.New("expected FROM")
-	}
-
-	token = parser.lexer.NextToken()
-	if token.Type != Identifier {
-		return nil, errors.New("expected table name")
-	}
-
-	parts := strings.Split(token.Value, ".")
-	if len(parts) == 3 {
-		// share.database.table format
-		selectStatement.Share = parts[0]
-		selectStatement.Database = parts[1]
-		selectStatement.Table = parts[2]
-	} else if len(parts) == 2 {
-		selectStatement.Database = parts[0]
-		selectStatement.Table = parts[1]
-	} else {
-		return nil, errors.New("expected database.table or share.database.table format")
-	}
-
-	token = parser.lexer.NextToken()
+	token = parser.lexer.NextToken()
 
 	// Check for table alias or AS OF clause for time-travel
 	if token.Type == As {
@@ -1130,7 +1197,7 @@ func ParseSelect(parser *Parser) (Statement, error) {
 		}
 
 		token = parser.lexer.NextToken()
-		if token.Type != Identifier {
+		if !isColumnName(token) {
 			return nil, errors.New("expected column after ON")
 		}
 		joinClause.LeftCol = token.Value
@@ -1141,7 +1208,7 @@ func ParseSelect(parser *Parser) (Statement, error) {
 		}
 
 		token = parser.lexer.NextToken()
-		if token.Type != Identifier {
+		if !isColumnName(token) {
 			return nil, errors.New("expected column after = in JOIN ON")
 		}
 		joinClause.RightCol = token.Value
@@ -1168,10 +1235,18 @@ func ParseSelect(parser *Parser) (Statement, error) {
 		}
 		for {
 			token = parser.lexer.NextToken()
-			if token.Type != Identifier {
+			if name, ok := functionCallName(parser, token); ok {
+				fn, err := parseFunctionCall(parser, name)
+				if err != nil {
+					return nil, err
+				}
+				selectStatement.addComputed(fn)
+				selectStatement.GroupBy = append(selectStatement.GroupBy, fn.Name())
+			} else if isColumnName(token) {
+				selectStatement.GroupBy = append(selectStatement.GroupBy, token.Value)
+			} else {
 				return nil, errors.New("expected column name in GROUP BY")
 			}
-			selectStatement.GroupBy = append(selectStatement.GroupBy, token.Value)
 
 			peek := parser.lexer.PeekToken()
 			if peek.Type == Comma {
@@ -1185,7 +1260,7 @@ func ParseSelect(parser *Parser) (Statement, error) {
 
 	// Parse HAVING clause (only valid after GROUP BY)
 	if token.Type == Having {
-		havingClause, err := ParseWhere(parser)
+		havingClause, err := parseConditions(parser, &selectStatement, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -1201,7 +1276,7 @@ func ParseSelect(parser *Parser) (Statement, error) {
 		}
 		for {
 			token = parser.lexer.NextToken()
-			if token.Type != Identifier {
+			if !isColumnName(token) {
 				return nil, errors.New("expected column name in ORDER BY")
 			}
 			orderByClause := OrderByClause{Column: token.Value}
@@ -1239,10 +1314,11 @@ func ParseSelect(parser *Parser) (Statement, error) {
 			return nil, err
 		}
 		selectStatement.Limit = limit
+		selectStatement.LimitSet = true
 		token = parser.lexer.NextToken()
 	}
 
-	// Parse OFFSET clause
+	// Parse OFFSET clause, with or without LIMIT and optionally followed by ROW/ROWS
 	if token.Type == Offset {
 		token = parser.lexer.NextToken()
 		if token.Type != Int {
@@ -1253,12 +1329,445 @@ func ParseSelect(parser *Parser) (Statement, error) {
 			return nil, err
 		}
 		selectStatement.Offset = offset
+		token = parser.lexer.NextToken()
+		if isWord(token, "ROW", "ROWS") {
+			token = parser.lexer.NextToken()
+		}
+	}
+
+	// FETCH FIRST n ROWS ONLY is the standard spelling of LIMIT
+	if token.Type == Fetch {
+		if selectStatement.LimitSet {
+			return nil, errors.New("FETCH FIRST cannot be combined with LIMIT")
+		}
+		limit, err := parseFetchFirst(parser)
+		if err != nil {
+			return nil, err
+		}
+		selectStatement.Limit = limit
+		selectStatement.LimitSet = true
 	}
 
+	selectStatement.NoIndex = parser.lexer.HasHint("NO_INDEX")
+
 	return selectStatement, nil
 }
 
+// parseFetchFirst parses the rest of "FETCH {FIRST | NEXT} [n] {ROW | ROWS} ONLY"
+// and returns n, which defaults to 1.
+func parseFetchFirst(parser *Parser) (int, error) {
+	if !isWord(parser.lexer.NextToken(), "FIRST", "NEXT") {
+		return 0, errors.New("expected FIRST or NEXT after FETCH")
+	}
+	limit := 1
+	token := parser.lexer.NextToken()
+	if token.Type == Int {
+		var err error
+		if limit, err = strconv.Atoi(token.Value); err != nil {
+			return 0, err
+		}
+		token = parser.lexer.NextToken()
+	}
+	if !isWord(token, "ROW", "ROWS") {
+		return 0, errors.New("expected ROW or ROWS in FETCH clause")
+	}
+	if !isWord(parser.lexer.NextToken(), "ONLY") {
+		return 0, errors.New("expected ONLY after FETCH FIRST ... ROWS")
+	}
+	return limit, nil
+}
+
+// isWord reports whether token is an identifier spelling one of words,
+// for keywords that are only meaningful in one clause.
+func isWord(token Token, words ...string) bool {
+	return token.Type == Identifier && slices.Contains(words, strings.ToUpper(token.Value))
+}
+
+// parseDistinctOn parses the parenthesized column list that follows DISTINCT ON
+func parseDistinctOn(parser *Parser) ([]string, error) {
+	if parser.lexer.NextToken().Type != ParenOpen {
+		return nil, errors.New("expected '(' after DISTINCT ON")
+	}
+	var columns []string
+	for {
+		token := parser.lexer.NextToken()
+		if !isColumnName(token) {
+			return nil, errors.New("expected column name in DISTINCT ON")
+		}
+		columns = append(columns, token.Value)
+
+		token = parser.lexer.NextToken()
+		if token.Type == ParenClose {
+			return columns, nil
+		}
+		if token.Type != Comma {
+			return nil, errors.New("expected ',' or ')' in DISTINCT ON")
+		}
+	}
+}
+
+// isColumnName reports whether token can name a column: an identifier or a
+// keyword that is not reserved. Reserved words start or join clauses, so they
+// cannot be column names; function names are only keywords before '('.
+func isColumnName(token Token) bool {
+	if token.Type == Identifier {
+		return true
+	}
+	return token.Type != String && isKeyword(token) && !reservedWords[token.Type] && !isFunctionKeyword(token.Type)
+}
+
+// reservedWords are the keywords that cannot be used as column names.
+var reservedWords = map[TokenType]bool{
+	Select: true, From: true, Where: true, And: true, Or: true, Not: true,
+	Is: true, Null: true, Like: true, In: true, On: true, True: true, False: true,
+	Limit: true, Offset: true, Order: true, By: true, Asc: true, Desc: true,
+	Group: true, Having: true, Distinct: true, As: true, Join: true, Inner: true,
+	Left: true, Right: true, Outer: true, Insert: true, Into: true, Values: true,
+	Update: true, Set: true, Delete: true, Create: true, Drop: true, Alter: true,
+	With: true, Returning: true, Replace: true, PrimaryKey: true,
+}
+
+// parseOptionalAlias consumes an optional "AS alias" following a select expression
+func parseOptionalAlias(parser *Parser) (string, error) {
+	if parser.lexer.PeekToken().Type != As {
+		return "", nil
+	}
+	parser.lexer.NextToken() // consume AS
+	token := parser.lexer.NextToken()
+	if token.Type != Identifier {
+		return "", errors.New("expected alias after AS")
+	}
+	return token.Value, nil
+}
+
+// parseSelectLiteral records a constant select-list entry and returns its
+// output name: the alias, or the literal as written.
+func parseSelectLiteral(parser *Parser, token Token, statement *SelectStatement) (string, error) {
+	name, err := parseOptionalAlias(parser)
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		name = token.Value
+		if token.Type == String {
+			name = "'" + strings.ReplaceAll(token.Value, "'", "''") + "'"
+		}
+	}
+	if _, exists := statement.Literals[name]; exists {
+		return "", fmt.Errorf("duplicate literal column %s", name)
+	}
+	if statement.Literals == nil {
+		statement.Literals = make(map[string]string)
+	}
+	statement.Literals[name] = token.Value
+	return name, nil
+}
+
+// parseCount parses the non-negative integer following keyword.
+func parseCount(parser *Parser, keyword string) (int, error) {
+	token := parser.lexer.NextToken()
+	if token.Type != Int {
+		return 0, errors.New("expected integer after " + keyword)
+	}
+	return strconv.Atoi(token.Value)
+}
+
+// parseSelectColumn returns the select list entry for a column token. The
+// lexer splits a qualified wildcard into "alias." and "*", which are joined
+// back into "alias.*".
+func parseSelectColumn(parser *Parser, token Token) string {
+	if strings.HasSuffix(token.Value, ".") && parser.lexer.PeekToken().Type == Wildcard {
+		parser.lexer.NextToken() // consume *
+		return token.Value + "*"
+	}
+	return token.Value
+}
+
+// isIntervalOperator reports whether token is the + or - of date arithmetic
+// such as "created + INTERVAL 7 DAY"
+func isIntervalOperator(token Token) bool {
+	return token.Type == Unknown && (token.Value == "+" || token.Value == "-")
+}
+
+// intervalUnits are the units an INTERVAL literal may use
+var intervalUnits = []string{"YEAR", "MONTH", "DAY", "HOUR", "MINUTE", "SECOND"}
+
+// parseInterval parses "+ INTERVAL n unit" or "- INTERVAL n unit" and returns
+// the operator, the amount and the unit. The amount may be quoted, as in
+// INTERVAL '7' DAY.
+func parseInterval(parser *Parser) (operator, amount, unit string, err error) {
+	operator = parser.lexer.NextToken().Value
+	if !isWord(parser.lexer.NextToken(), "INTERVAL") {
+		return "", "", "", errors.New("expected INTERVAL after " + operator)
+	}
+	token := parser.lexer.NextToken()
+	if _, err := strconv.Atoi(token.Value); err != nil || (token.Type != Int && token.Type != String) {
+		return "", "", "", errors.New("expected integer after INTERVAL")
+	}
+	amount = token.Value
+	unit = strings.TrimSuffix(toUpper(parser.lexer.NextToken().Value), "S")
+	if !slices.Contains(intervalUnits, unit) {
+		return "", "", "", errors.New("expected YEAR, MONTH, DAY, HOUR, MINUTE or SECOND after INTERVAL " + amount)
+	}
+	return operator, amount, unit, nil
+}
+
+// parseIntervalArithmetic parses "+ INTERVAL n unit" or "- INTERVAL n unit"
+// after column as a call to DATE_ADD or DATE_SUB. The call is named as
+// written, e.g. "created + INTERVAL 7 DAY", unless it is given an alias.
+func parseIntervalArithmetic(parser *Parser, column string) (FunctionExpr, error) {
+	operator, amount, unit, err := parseInterval(parser)
+	if err != nil {
+		return FunctionExpr{}, err
+	}
+
+	fn := FunctionExpr{Function: "DATE_ADD", Args: []string{column, amount, unit}, Literal: []bool{false, true, true}}
+	if operator == "-" {
+		fn.Function = "DATE_SUB"
+	}
+	fn.Alias = column + " " + operator + " INTERVAL " + amount + " " + unit
+	return fn, nil
+}
+
+// intervalDateLayouts are the layouts a date literal may use in WHERE
+// arithmetic such as "created > '2024-01-01' + INTERVAL 7 DAY".
+var intervalDateLayouts = []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", time.RFC3339, "2006-01-02"}
+
+// parseIntervalLiteral parses "+ INTERVAL n unit" or "- INTERVAL n unit"
+// after the date literal value and returns the resulting date. A date stays
+// a date unless a time unit is added; otherwise the result is a timestamp
+// in UTC.
+func parseIntervalLiteral(parser *Parser, value string) (string, error) {
+	operator, amount, unit, err := parseInterval(parser)
+	if err != nil {
+		return "", err
+	}
+	n, _ := strconv.Atoi(amount)
+	if operator == "-" {
+		n = -n
+	}
+
+	for _, layout := range intervalDateLayouts {
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			continue
+		}
+		switch unit {
+		case "YEAR":
+			t = t.AddDate(n, 0, 0)
+		case "MONTH":
+			t = t.AddDate(0, n, 0)
+		case "DAY":
+			t = t.AddDate(0, 0, n)
+		case "HOUR":
+			t = t.Add(time.Duration(n) * time.Hour)
+		case "MINUTE":
+			t = t.Add(time.Duration(n) * time.Minute)
+		case "SECOND":
+			t = t.Add(time.Duration(n) * time.Second)
+		}
+		if layout == "2006-01-02" && (unit == "YEAR" || unit == "MONTH" || unit == "DAY") {
+			return t.Format(layout), nil
+		}
+		return t.UTC().Format("2006-01-02 15:04:05"), nil
+	}
+	return "", fmt.Errorf("cannot add INTERVAL to '%s': expected a date such as YYYY-MM-DD or YYYY-MM-DD HH:MM:SS", value)
+}
+
+// aggregateNames maps aggregate function tokens to their names.
+var aggregateNames = map[TokenType]string{
+	Count: "COUNT", Sum: "SUM", Avg: "AVG", Min: "MIN", Max: "MAX",
+}
+
+// functionNames maps built-in scalar function tokens to their names.
+var functionNames = map[TokenType]string{
+	Upper: "UPPER", Lower: "LOWER", Concat: "CONCAT", Substring: "SUBSTRING", Trim: "TRIM",
+	Length: "LENGTH", Replace: "REPLACE", ToBase64: "TO_BASE64", FromBase64: "FROM_BASE64",
+	Now: "NOW", DateAdd: "DATE_ADD", DateSub: "DATE_SUB", DateDiff: "DATEDIFF", DateFunc: "DATE",
+	Year: "YEAR", Month: "MONTH", Day: "DAY", Hour: "HOUR", Minute: "MINUTE", Second: "SECOND",
+	DateFormat: "DATE_FORMAT", JsonExtract: "JSON_EXTRACT", JsonSet: "JSON_SET",
+	JsonRemove: "JSON_REMOVE", JsonContains: "JSON_CONTAINS", JsonKeys: "JSON_KEYS",
+	JsonLength: "JSON_LENGTH", JsonType: "JSON_TYPE", JsonPretty: "JSON_PRETTY", JsonCompact: "JSON_COMPACT",
+}
+
+// functionCallName reports whether token starts a scalar function call and
+// returns the function's name. Identifiers followed by '(' name functions
+// registered with the engine.
+func functionCallName(parser *Parser, token Token) (string, bool) {
+	if parser.lexer.PeekToken().Type != ParenOpen {
+		return "", false
+	}
+	if name, ok := functionNames[token.Type]; ok {
+		return name, true
+	}
+	if token.Type == Identifier {
+		return strings.ToUpper(token.Value), true
+	}
+	return "", false
+}
+
+// parseFunctionCall parses the parenthesized arguments of a call to name.
+// Arguments are column names or literals.
+func parseFunctionCall(parser *Parser, name string) (FunctionExpr, error) {
+	parser.lexer.NextToken() // consume '('
+	fn := FunctionExpr{Function: name}
+	token := parser.lexer.NextToken()
+	if token.Type == ParenClose {
+		return fn, nil
+	}
+	for {
+		if isColumnName(token) {
+			fn.addArg(token.Value, false)
+		} else if token.Type == String || token.Type == Int || token.Type == Float {
+			fn.addArg(token.Value, true)
+		} else {
+			return FunctionExpr{}, errors.New("expected argument in " + name + "()")
+		}
+		token = parser.lexer.NextToken()
+		if token.Type == ParenClose {
+			return fn, nil
+		}
+		if token.Type != Comma {
+			return FunctionExpr{}, errors.New("expected ',' or ')' in " + name + "()")
+		}
+		token = parser.lexer.NextToken()
+	}
+}
+
+// parseAggregate parses an aggregate call after its name, including any
+// FILTER clause and alias.
+func parseAggregate(parser *Parser, name string, statement *SelectStatement) (AggregateExpr, error) {
+	agg, err := parseAggregateCall(parser, name, statement)
+	if err != nil {
+		return AggregateExpr{}, err
+	}
+	filter, err := parseAggregateFilter(parser)
+	if err != nil {
+		return AggregateExpr{}, err
+	}
+	agg.Filter = filter
+	agg.Alias, err = parseOptionalAlias(parser)
+	if err != nil {
+		return AggregateExpr{}, err
+	}
+	return agg, nil
+}
+
+// parseAggregateCall parses the parenthesized argument of an aggregate call.
+// The argument may be *, a column or a function call, which is recorded in
+// the statement's Computed functions, optionally preceded by DISTINCT.
+func parseAggregateCall(parser *Parser, name string, statement *SelectStatement) (AggregateExpr, error) {
+	if parser.lexer.NextToken().Type != ParenOpen {
+		return AggregateExpr{}, errors.New("expected '(' after " + name)
+	}
+	agg := AggregateExpr{Function: name}
+	token := parser.lexer.NextToken()
+	if token.Type == Distinct {
+		agg.Distinct = true
+		token = parser.lexer.NextToken()
+	}
+	if fnName, ok := functionCallName(parser, token); ok {
+		fn, err := parseFunctionCall(parser, fnName)
+		if err != nil {
+			return AggregateExpr{}, err
+		}
+		statement.addComputed(fn)
+		agg.Column = fn.Name()
+	} else if token.Type == Wildcard && name == "COUNT" && !agg.Distinct {
+		agg.Column = "*"
+	} else if isColumnName(token) {
+		agg.Column = token.Value
+	} else if name == "COUNT" && !agg.Distinct {
+		return AggregateExpr{}, errors.New("expected '*' or column name in COUNT()")
+	} else {
+		return AggregateExpr{}, errors.New("expected column name in " + name + "()")
+	}
+	if parser.lexer.NextToken().Type != ParenClose {
+		return AggregateExpr{}, errors.New("expected ')' after " + name + " argument")
+	}
+	return agg, nil
+}
+
+// addComputed records a function call the statement groups or aggregates by,
+// once per distinct call.
+func (statement *SelectStatement) addComputed(fn FunctionExpr) {
+	for _, existing := range statement.Computed {
+		if existing.Name() == fn.Name() {
+			return
+		}
+	}
+	statement.Computed = append(statement.Computed, fn)
+}
+
+// parseAggregateFilter consumes an optional "FILTER (WHERE ...)" following an aggregate
+func parseAggregateFilter(parser *Parser) (WhereClause, error) {
+	peek := parser.lexer.PeekToken()
+	if peek.Type != Identifier || strings.ToUpper(peek.Value) != "FILTER" {
+		return WhereClause{}, nil
+	}
+	parser.lexer.NextToken() // consume FILTER
+	if parser.lexer.NextToken().Type != ParenOpen {
+		return WhereClause{}, errors.New("expected '(' after FILTER")
+	}
+	if parser.lexer.NextToken().Type != Where {
+		return WhereClause{}, errors.New("expected WHERE in FILTER clause")
+	}
+	filter, err := ParseWhere(parser)
+	if err != nil {
+		return WhereClause{}, err
+	}
+	if parser.lexer.NextToken().Type != ParenClose {
+		return WhereClause{}, errors.New("expected ')' after FILTER condition")
+	}
+	return filter, nil
+}
+
 func ParseWhere(parser *Parser) (WhereClause, error) {
+	return parseConditions(parser, nil, nil)
+}
+
+// ParseCheck parses the condition of a CHECK constraint, such as
+// "LENGTH(name) > 0". Function calls in the condition are returned in order
+// and named in its conditions by their Name().
+func ParseCheck(check string) (WhereClause, []FunctionExpr, error) {
+	parser := NewParser(check)
+	var functions []FunctionExpr
+	where, err := parseConditions(parser, nil, &functions)
+	if err != nil {
+		return WhereClause{}, nil, err
+	}
+	if token := parser.lexer.NextToken(); token.Type != EOF {
+		return WhereClause{}, nil, errors.New("unexpected " + token.Value + " after CHECK condition")
+	}
+	return where, functions, nil
+}
+
+// ParseFunction parses a function call written on its own, such as the
+// NOW() of a column's DEFAULT.
+func ParseFunction(call string) (FunctionExpr, error) {
+	parser := NewParser(call)
+	token := parser.lexer.NextToken()
+	name, ok := functionCallName(parser, token)
+	if !ok {
+		return FunctionExpr{}, errors.New("expected function call, got " + call)
+	}
+	fn, err := parseFunctionCall(parser, name)
+	if err != nil {
+		return FunctionExpr{}, err
+	}
+	if token := parser.lexer.NextToken(); token.Type != EOF {
+		return FunctionExpr{}, errors.New("unexpected " + token.Value + " after " + fn.Name())
+	}
+	return fn, nil
+}
+
+// parseConditions parses a list of conditions joined by AND/OR. For a HAVING
+// clause, having is the statement being parsed: a condition may then compare
+// an aggregate call, which is recorded in its HavingAggregates and named in
+// the condition by its Name(). When functions is non-nil, a condition may
+// compare a scalar function call, which is appended to it and likewise named
+// by its Name().
+func parseConditions(parser *Parser, having *SelectStatement, functions *[]FunctionExpr) (WhereClause, error) {
 	var whereClause WhereClause
 
 	for {
@@ -1271,15 +1780,38 @@ func ParseWhere(parser *Parser) (WhereClause, error) {
 			token = parser.lexer.NextToken()
 		}
 
-		if token.Type != Identifier {
+		var left string
+		if name, ok := aggregateNames[token.Type]; ok && having != nil {
+			agg, err := parseAggregateCall(parser, name, having)
+			if err != nil {
+				return whereClause, err
+			}
+			having.HavingAggregates = append(having.HavingAggregates, agg)
+			left = agg.Name()
+		} else if name, ok := functionCallName(parser, token); ok && functions != nil {
+			fn, err := parseFunctionCall(parser, name)
+			if err != nil {
+				return whereClause, err
+			}
+			*functions = append(*functions, fn)
+			left = fn.Name()
+		} else if isColumnName(token) {
+			left = token.Value
+		} else {
 			return whereClause, errors.New("expected identifier in WHERE clause")
 		}
-		left := token.Value
 
 		token = parser.lexer.NextToken()
 
+		// column NOT IN (...) is NOT column IN (...)
+		if token.Type == Not && parser.lexer.PeekToken().Type == In {
+			negated = !negated
+			token = parser.lexer.NextToken()
+		}
+
 		var operator WhereOperator
 		var right string
+		var patterns []string
 
 		// Handle IS NULL / IS NOT NULL
 		if token.Type == Is {
@@ -1307,10 +1839,17 @@ func ParseWhere(parser *Parser) (WhereClause, error) {
 			var inValues []string
 			for {
 				token = parser.lexer.NextToken()
-				if token.Type != String && token.Type != Int {
+				if token.Type != String && token.Type != Int && token.Type != Null {
 					return whereClause, errors.New("expected value in IN list")
 				}
-				inValues = append(inValues, token.Value)
+				if parser.MaxInValues > 0 && len(inValues) == parser.MaxInValues {
+					return whereClause, fmt.Errorf("IN list has more than %d values; JOIN a table holding the values instead", parser.MaxInValues)
+				}
+				if token.Type == Null {
+					inValues = append(inValues, NullValue)
+				} else {
+					inValues = append(inValues, token.Value)
+				}
 
 				token = parser.lexer.NextToken()
 				if token.Type == ParenClose {
@@ -1326,6 +1865,7 @@ func ParseWhere(parser *Parser) (WhereClause, error) {
 				Operator: operator,
 				InValues: inValues,
 				Negated:  negated,
+				InSet:    InValueSet(inValues),
 			})
 
 			token = parser.lexer.PeekToken()
@@ -1357,21 +1897,41 @@ func ParseWhere(parser *Parser) (WhereClause, error) {
 				operator = GreaterThanOrEqualOperator
 			case Like:
 				operator = LikeOperator
+				if next := parser.lexer.PeekToken(); isWord(next, "ANY") {
+					operator = LikeAnyOperator
+				} else if isWord(next, "ALL") {
+					operator = LikeAllOperator
+				}
 			default:
 				return whereClause, errors.New("expected operator in WHERE clause")
 			}
 
-			token = parser.lexer.NextToken()
-			if token.Type != String && token.Type != Int {
-				return whereClause, errors.New("expected value in WHERE clause")
+			if operator == LikeAnyOperator || operator == LikeAllOperator {
+				parser.lexer.NextToken() // consume ANY or ALL
+				var err error
+				if patterns, err = parseLikePatterns(parser); err != nil {
+					return whereClause, err
+				}
+			} else {
+				token = parser.lexer.NextToken()
+				if token.Type != String && token.Type != Int {
+					return whereClause, errors.New("expected value in WHERE clause")
+				}
+				right = token.Value
+				if isIntervalOperator(parser.lexer.PeekToken()) {
+					var err error
+					if right, err = parseIntervalLiteral(parser, right); err != nil {
+						return whereClause, err
+					}
+				}
 			}
-			right = token.Value
 		}
 
 		whereClause.Conditions = append(whereClause.Conditions, WhereCondition{
 			Left:     left,
 			Operator: operator,
 			Right:    right,
+			InValues: patterns,
 			Negated:  negated,
 		})
 
@@ -1392,6 +1952,33 @@ func ParseWhere(parser *Parser) (WhereClause, error) {
 	return whereClause, nil
 }
 
+// parseLikePatterns parses the ('pattern', ...) list of LIKE ANY and LIKE ALL
+func parseLikePatterns(parser *Parser) ([]string, error) {
+	if parser.lexer.NextToken().Type != ParenOpen {
+		return nil, errors.New("expected '(' after LIKE ANY or LIKE ALL")
+	}
+
+	var patterns []string
+	for {
+		token := parser.lexer.NextToken()
+		if token.Type != String {
+			return nil, errors.New("expected pattern in LIKE list")
+		}
+		if parser.MaxInValues > 0 && len(patterns) == parser.MaxInValues {
+			return nil, fmt.Errorf("LIKE list has more than %d patterns", parser.MaxInValues)
+		}
+		patterns = append(patterns, token.Value)
+
+		token = parser.lexer.NextToken()
+		if token.Type == ParenClose {
+			return patterns, nil
+		}
+		if token.Type != Comma {
+			return nil, errors.New("expected ',' or ')' in LIKE list")
+		}
+	}
+}
+
 func ParseInsert(parser *Parser) (Statement, error) {
 	var insertStatement InsertStatement
 
@@ -1423,7 +2010,7 @@ func ParseInsert(parser *Parser) (Statement, error) {
 
 	for {
 		token = parser.lexer.NextToken()
-		if token.Type != Identifier {
+		if !isColumnName(token) {
 			return nil, errors.New("expected column name")
 		}
 		insertStatement.Columns = append(insertStatement.Columns, token.Value)
@@ -1444,9 +2031,26 @@ func ParseInsert(parser *Parser) (Statement, error) {
 		return nil, errors.New("expected VALUES")
 	}
 
-	// Parse value rows (one or more): VALUES (v1, v2), (v3, v4), ...
+	valueRows, err := parseValueRows(parser)
+	if err != nil {
+		return nil, err
+	}
+	insertStatement.ValueRows = valueRows
+
+	returning, err := parseReturning(parser)
+	if err != nil {
+		return nil, err
+	}
+	insertStatement.Returning = returning
+
+	return insertStatement, nil
+}
+
+// parseValueRows parses the rows after VALUES: (v1, v2), (v3, v4), ...
+func parseValueRows(parser *Parser) ([][]string, error) {
+	var rows [][]string
 	for {
-		token = parser.lexer.NextToken()
+		token := parser.lexer.NextToken()
 		if token.Type != ParenOpen {
 			return nil, errors.New("expected '(' after VALUES or ','")
 		}
@@ -1469,11 +2073,33 @@ func ParseInsert(parser *Parser) (Statement, error) {
 				if nextToken.Type != ParenClose {
 					return nil, errors.New("expected ')' after NOW(")
 				}
-				value = "NOW()"
+				value = NowValue
+			case FromBase64:
+				// FROM_BASE64('...') inserts the decoded bytes, e.g. into a BLOB column
+				if parser.lexer.NextToken().Type != ParenOpen {
+					return nil, errors.New("expected '(' after FROM_BASE64")
+				}
+				arg := parser.lexer.NextToken()
+				if arg.Type != String {
+					return nil, errors.New("expected string in FROM_BASE64()")
+				}
+				if parser.lexer.NextToken().Type != ParenClose {
+					return nil, errors.New("expected ')' after FROM_BASE64 argument")
+				}
+				raw, err := base64.StdEncoding.DecodeString(arg.Value)
+				if err != nil {
+					return nil, errors.New("invalid base64 in FROM_BASE64()")
+				}
+				value = string(raw)
 			case Null:
-				value = ""
+				value = NullValue
+			case Identifier:
+				if !isWord(token, "DEFAULT") {
+					return nil, errors.New("expected value (string, number, NOW(), FROM_BASE64(), NULL, or DEFAULT)")
+				}
+				value = DefaultValue
 			default:
-				return nil, errors.New("expected value (string, number, NOW(), or NULL)")
+				return nil, errors.New("expected value (string, number, NOW(), FROM_BASE64(), NULL, or DEFAULT)")
 			}
 			currentRow = append(currentRow, value)
 
@@ -1487,7 +2113,7 @@ func ParseInsert(parser *Parser) (Statement, error) {
 			}
 		}
 
-		insertStatement.ValueRows = append(insertStatement.ValueRows, currentRow)
+		rows = append(rows, currentRow)
 
 		// Check for more value rows
 		token = parser.lexer.PeekToken()
@@ -1497,8 +2123,34 @@ func ParseInsert(parser *Parser) (Statement, error) {
 		}
 		break
 	}
+	return rows, nil
+}
 
-	return insertStatement, nil
+// parseReturning parses an optional RETURNING * or RETURNING col1, col2 clause.
+func parseReturning(parser *Parser) ([]string, error) {
+	if parser.lexer.PeekToken().Type != Returning {
+		return nil, nil
+	}
+	parser.lexer.NextToken() // consume RETURNING
+
+	if parser.lexer.PeekToken().Type == Wildcard {
+		parser.lexer.NextToken()
+		return []string{"*"}, nil
+	}
+
+	var columns []string
+	for {
+		token := parser.lexer.NextToken()
+		if !isColumnName(token) {
+			return nil, errors.New("expected column name or '*' after RETURNING")
+		}
+		columns = append(columns, token.Value)
+
+		if parser.lexer.PeekToken().Type != Comma {
+			return columns, nil
+		}
+		parser.lexer.NextToken() // consume comma
+	}
 }
 
 func ParseUpdate(parser *Parser) (Statement, error) {
@@ -1526,7 +2178,7 @@ func ParseUpdate(parser *Parser) (Statement, error) {
 
 	for {
 		token = parser.lexer.NextToken()
-		if token.Type != Identifier {
+		if !isColumnName(token) {
 			return nil, errors.New("expected column name in SET clause")
 		}
 		column := token.Value
@@ -1537,10 +2189,20 @@ func ParseUpdate(parser *Parser) (Statement, error) {
 		}
 
 		token = parser.lexer.NextToken()
-		if token.Type != String && token.Type != Int {
+		var value string
+		switch token.Type {
+		case String, Int:
+			value = token.Value
+		case Null:
+			value = NullValue
+		case Identifier:
+			if !isWord(token, "DEFAULT") {
+				return nil, errors.New("expected value in SET clause")
+			}
+			value = DefaultValue
+		default:
 			return nil, errors.New("expected value in SET clause")
 		}
-		value := token.Value
 
 		updateStatement.Updates = append(updateStatement.Updates, SetClause{
 			Column: column,
@@ -1556,8 +2218,8 @@ func ParseUpdate(parser *Parser) (Statement, error) {
 		}
 	}
 
-	token = parser.lexer.NextToken()
-	if token.Type == Where {
+	if parser.lexer.PeekToken().Type == Where {
+		parser.lexer.NextToken() // consume WHERE
 		whereClause, err := ParseWhere(parser)
 		if err != nil {
 			return nil, err
@@ -1565,6 +2227,18 @@ func ParseUpdate(parser *Parser) (Statement, error) {
 		updateStatement.Where = whereClause
 	}
 
+	limit, err := parseWriteLimit(parser)
+	if err != nil {
+		return nil, err
+	}
+	updateStatement.Limit = limit
+
+	returning, err := parseReturning(parser)
+	if err != nil {
+		return nil, err
+	}
+	updateStatement.Returning = returning
+
 	return updateStatement, nil
 }
 
@@ -1592,8 +2266,8 @@ func ParseDelete(parser *Parser) (Statement, error) {
 	}
 
 	// Parse WHERE clause
-	token = parser.lexer.NextToken()
-	if token.Type == Where {
+	if parser.lexer.PeekToken().Type == Where {
+		parser.lexer.NextToken() // consume WHERE
 		whereClause, err := ParseWhere(parser)
 		if err != nil {
 			return nil, err
@@ -1601,9 +2275,38 @@ func ParseDelete(parser *Parser) (Statement, error) {
 		deleteStatement.Where = whereClause
 	}
 
+	limit, err := parseWriteLimit(parser)
+	if err != nil {
+		return nil, err
+	}
+	deleteStatement.Limit = limit
+
+	returning, err := parseReturning(parser)
+	if err != nil {
+		return nil, err
+	}
+	deleteStatement.Returning = returning
+
 	return deleteStatement, nil
 }
 
+// parseWriteLimit parses the optional LIMIT n of an UPDATE or DELETE,
+// returning 0 when there is none
+func parseWriteLimit(parser *Parser) (int, error) {
+	if parser.lexer.PeekToken().Type != Limit {
+		return 0, nil
+	}
+	parser.lexer.NextToken() // consume LIMIT
+	limit, err := parseCount(parser, "LIMIT")
+	if err != nil {
+		return 0, err
+	}
+	if limit == 0 {
+		return 0, errors.New("LIMIT must be greater than 0")
+	}
+	return limit, nil
+}
+
 func ParseCreate(parser *Parser) (Statement, error) {
 	token := parser.lexer.NextToken()
 	switch token.Type {
@@ -1635,8 +2338,36 @@ func ParseCreate(parser *Parser) (Statement, error) {
 			return nil, errors.New("expected VIEW after MATERIALIZED")
 		}
 		return ParseCreateView(parser, true)
+	case Or:
+		// OR REPLACE [MATERIALIZED] VIEW
+		token = parser.lexer.NextToken()
+		if token.Type != Replace {
+			return nil, errors.New("expected REPLACE after OR")
+		}
+		materialized := false
+		token = parser.lexer.NextToken()
+		if token.Type == Materialized {
+			materialized = true
+			token = parser.lexer.NextToken()
+		}
+		if token.Type != View {
+			return nil, errors.New("expected VIEW or MATERIALIZED VIEW after OR REPLACE")
+		}
+		stmt, err := ParseCreateView(parser, materialized)
+		if err != nil {
+			return nil, err
+		}
+		createView := stmt.(CreateViewStatement)
+		createView.OrReplace = true
+		return createView, nil
+	case Identifier:
+		// TRIGGER is not reserved, so it can still name columns and tables
+		if isWord(token, "TRIGGER") {
+			return ParseCreateTrigger(parser)
+		}
+		return nil, errors.New("expected TABLE, DATABASE, INDEX, BRANCH, REMOTE, SHARE, VIEW, or TRIGGER after CREATE")
 	default:
-		return nil, errors.New("expected TABLE, DATABASE, INDEX, BRANCH, REMOTE, SHARE, or VIEW after CREATE")
+		return nil, errors.New("expected TABLE, DATABASE, INDEX, BRANCH, REMOTE, SHARE, VIEW, or TRIGGER after CREATE")
 	}
 }
 
@@ -1673,6 +2404,21 @@ func ParseCreateTable(parser *Parser) (Statement, error) {
 		if token.Value == "" || token.Type == EOF {
 			return nil, errors.New("expected column name")
 		}
+		// A table constraint: CHECK (condition)
+		if isWord(token, "CHECK") && parser.lexer.PeekToken().Type == ParenOpen {
+			check, err := parseCheck(parser)
+			if err != nil {
+				return nil, err
+			}
+			createTableStatement.Checks = append(createTableStatement.Checks, check)
+			token = parser.lexer.NextToken()
+			if token.Type == Comma {
+				continue
+			} else if token.Type == ParenClose {
+				break
+			}
+			return nil, errors.New("expected ',' or ')' in column list")
+		}
 		columnName := token.Value
 
 		token = parser.lexer.NextToken()
@@ -1694,23 +2440,73 @@ func ParseCreateTable(parser *Parser) (Statement, error) {
 			columnType = core.TimestampType
 		case "JSON":
 			columnType = core.JsonType
+		case "BLOB", "BINARY":
+			columnType = core.BlobType
 		default:
-			return nil, errors.New("expected column type (STRING, INT, FLOAT, BOOL, TEXT, DATE, TIMESTAMP, JSON)")
+			return nil, errors.New("expected column type (STRING, INT, FLOAT, BOOL, TEXT, DATE, TIMESTAMP, JSON, BLOB, BINARY)")
 		}
 
-		// Check for PRIMARY KEY
-		token = parser.lexer.PeekToken()
-		isPrimaryKey := false
-		if token.Type == PrimaryKey {
-			parser.lexer.NextToken() // consume PRIMARY KEY
-			isPrimaryKey = true
+		column := core.Column{
+			Name: columnName,
+			Type: columnType,
 		}
 
-		createTableStatement.Columns = append(createTableStatement.Columns, core.Column{
-			Name:       columnName,
-			Type:       columnType,
-			PrimaryKey: isPrimaryKey,
-		})
+		// Optional PRIMARY KEY, NOT NULL / NULL, DEFAULT value, CHECK and COMMENT, in any order
+		for {
+			token = parser.lexer.PeekToken()
+			if token.Type == PrimaryKey {
+				parser.lexer.NextToken() // consume PRIMARY KEY
+				column.PrimaryKey = true
+			} else if token.Type == Not {
+				parser.lexer.NextToken() // consume NOT
+				if parser.lexer.NextToken().Type != Null {
+					return nil, errors.New("expected NULL after NOT")
+				}
+				column.NotNull = true
+			} else if token.Type == Null {
+				parser.lexer.NextToken() // consume NULL: nullable, the default
+				column.NotNull = false
+			} else if token.Type == Identifier && strings.ToUpper(token.Value) == "DEFAULT" {
+				parser.lexer.NextToken() // consume DEFAULT
+				token = parser.lexer.NextToken()
+				if name, ok := functionCallName(parser, token); ok {
+					start := parser.lexer.tokenStart
+					if _, err := parseFunctionCall(parser, name); err != nil {
+						return nil, err
+					}
+					column.DefaultExpr = parser.lexer.sql[start:parser.lexer.position]
+					column.Default = nil
+					continue
+				}
+				switch token.Type {
+				case String, Int, Float:
+					value := token.Value
+					column.Default = &value
+				case Null:
+					column.Default = nil
+				default:
+					return nil, errors.New("expected value after DEFAULT")
+				}
+			} else if isWord(token, "CHECK") {
+				parser.lexer.NextToken() // consume CHECK
+				check, err := parseCheck(parser)
+				if err != nil {
+					return nil, err
+				}
+				createTableStatement.Checks = append(createTableStatement.Checks, check)
+			} else if token.Type == Identifier && strings.ToUpper(token.Value) == "COMMENT" {
+				parser.lexer.NextToken() // consume COMMENT
+				comment, err := parseComment(parser)
+				if err != nil {
+					return nil, err
+				}
+				column.Comment = comment
+			} else {
+				break
+			}
+		}
+
+		createTableStatement.Columns = append(createTableStatement.Columns, column)
 
 		token = parser.lexer.NextToken()
 		if token.Type == Comma {
@@ -1722,9 +2518,96 @@ func ParseCreateTable(parser *Parser) (Statement, error) {
 		}
 	}
 
+	// Optional table COMMENT 'text', AUTHOR 'Name <email>' and storage layout
+	// WITH FANOUT n, in any order
+	for {
+		token = parser.lexer.PeekToken()
+		if token.Type == With {
+			parser.lexer.NextToken() // consume WITH
+			token = parser.lexer.NextToken()
+			if token.Type != Identifier || strings.ToUpper(token.Value) != "FANOUT" {
+				return nil, errors.New("expected FANOUT after WITH")
+			}
+			token = parser.lexer.NextToken()
+			if token.Type != Int {
+				return nil, errors.New("expected number after FANOUT")
+			}
+			fanout, err := strconv.Atoi(token.Value)
+			if err != nil {
+				return nil, err
+			}
+			createTableStatement.Fanout = fanout
+		} else if token.Type == Identifier && strings.ToUpper(token.Value) == "COMMENT" {
+			parser.lexer.NextToken() // consume COMMENT
+			comment, err := parseComment(parser)
+			if err != nil {
+				return nil, err
+			}
+			createTableStatement.Comment = comment
+		} else if isWord(token, "AUTHOR") {
+			parser.lexer.NextToken() // consume AUTHOR
+			author, err := parseAuthor(parser)
+			if err != nil {
+				return nil, err
+			}
+			createTableStatement.Author = author
+		} else {
+			break
+		}
+	}
+
+	// Optional seed rows: VALUES (v1, v2), (v3, v4), ... in column order
+	if parser.lexer.PeekToken().Type == Values {
+		parser.lexer.NextToken() // consume VALUES
+		valueRows, err := parseValueRows(parser)
+		if err != nil {
+			return nil, err
+		}
+		createTableStatement.ValueRows = valueRows
+	}
+
 	return createTableStatement, nil
 }
 
+// parseCheck parses the parenthesized condition that follows CHECK and
+// returns it as written
+func parseCheck(parser *Parser) (string, error) {
+	if parser.lexer.NextToken().Type != ParenOpen {
+		return "", errors.New("expected '(' after CHECK")
+	}
+	start := parser.lexer.position
+	var functions []FunctionExpr
+	if _, err := parseConditions(parser, nil, &functions); err != nil {
+		return "", err
+	}
+	if parser.lexer.NextToken().Type != ParenClose {
+		return "", errors.New("expected ')' after CHECK condition")
+	}
+	return strings.TrimSpace(parser.lexer.sql[start:parser.lexer.tokenStart]), nil
+}
+
+// parseAuthor parses the quoted 'Name <email>' that follows AUTHOR
+func parseAuthor(parser *Parser) (*core.Identity, error) {
+	token := parser.lexer.NextToken()
+	if token.Type != String {
+		return nil, errors.New("expected quoted 'Name <email>' after AUTHOR")
+	}
+	name, email, ok := strings.Cut(token.Value, "<")
+	if !ok || !strings.HasSuffix(email, ">") || strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("expected AUTHOR as 'Name <email>', got '%s'", token.Value)
+	}
+	return &core.Identity{Name: strings.TrimSpace(name), Email: strings.TrimSuffix(email, ">")}, nil
+}
+
+// parseComment parses the quoted text that follows COMMENT
+func parseComment(parser *Parser) (string, error) {
+	token := parser.lexer.NextToken()
+	if token.Type != String {
+		return "", errors.New("expected quoted text after COMMENT")
+	}
+	return token.Value, nil
+}
+
 // ParseCreateIndex parses: CREATE [UNIQUE] INDEX name ON database.table(column)
 func ParseCreateIndex(parser *Parser, unique bool) (Statement, error) {
 	var statement CreateIndexStatement
@@ -1764,7 +2647,7 @@ func ParseCreateIndex(parser *Parser, unique bool) (Statement, error) {
 	}
 
 	token = parser.lexer.NextToken()
-	if token.Type != Identifier {
+	if !isColumnName(token) {
 		return nil, errors.New("expected column name inside parentheses")
 	}
 	statement.Column = token.Value
@@ -1792,8 +2675,13 @@ func ParseDrop(parser *Parser) (Statement, error) {
 		return ParseDropShare(parser)
 	case View:
 		return ParseDropView(parser)
+	case Identifier:
+		if isWord(token, "TRIGGER") {
+			return ParseDropTrigger(parser)
+		}
+		return nil, errors.New("expected TABLE, DATABASE, INDEX, REMOTE, SHARE, VIEW, or TRIGGER after DROP")
 	default:
-		return nil, errors.New("expected TABLE, DATABASE, INDEX, REMOTE, SHARE, or VIEW after DROP")
+		return nil, errors.New("expected TABLE, DATABASE, INDEX, REMOTE, SHARE, VIEW, or TRIGGER after DROP")
 	}
 }
 
@@ -1915,11 +2803,33 @@ func ParseShow(parser *Parser) (Statement, error) {
 			return nil, errors.New("expected database name after IN")
 		}
 		return ShowTablesStatement{Database: token.Value}, nil
+	case TableIdentifier:
+		// SHOW TABLE STATUS IN database
+		token = parser.lexer.NextToken()
+		if token.Type != Identifier || strings.ToUpper(token.Value) != "STATUS" {
+			return nil, errors.New("expected STATUS after TABLE")
+		}
+		token = parser.lexer.NextToken()
+		if token.Type != In {
+			return nil, errors.New("expected IN after TABLE STATUS")
+		}
+		token = parser.lexer.NextToken()
+		if token.Type != Identifier {
+			return nil, errors.New("expected database name after IN")
+		}
+		return ShowTableStatusStatement{Database: token.Value}, nil
 	case IndexIdentifier:
-		// SHOW INDEXES ON database.table
+		// SHOW INDEXES ON database.table or SHOW INDEXES IN database
 		token = parser.lexer.NextToken()
+		if token.Type == In {
+			token = parser.lexer.NextToken()
+			if token.Type != Identifier {
+				return nil, errors.New("expected database name after IN")
+			}
+			return ShowIndexesStatement{Database: token.Value}, nil
+		}
 		if token.Type != On {
-			return nil, errors.New("expected ON after INDEXES")
+			return nil, errors.New("expected ON or IN after INDEXES")
 		}
 		token = parser.lexer.NextToken()
 		if token.Type != Identifier {
@@ -1933,37 +2843,105 @@ func ParseShow(parser *Parser) (Statement, error) {
 	case Branches:
 		return ShowBranchesStatement{}, nil
 	case Merge:
-		// SHOW MERGE CONFLICTS
+		// SHOW MERGE CONFLICTS | SHOW MERGE BASE branchA branchB
 		token = parser.lexer.NextToken()
+		if token.Type == Identifier && strings.ToUpper(token.Value) == "BASE" {
+			branchA := parser.lexer.NextToken()
+			branchB := parser.lexer.NextToken()
+			if branchA.Type != Identifier || branchB.Type != Identifier {
+				return nil, errors.New("expected two branch names after MERGE BASE")
+			}
+			return ShowMergeBaseStatement{BranchA: branchA.Value, BranchB: branchB.Value}, nil
+		}
 		if token.Type != Conflicts {
-			return nil, errors.New("expected CONFLICTS after MERGE")
+			return nil, errors.New("expected CONFLICTS or BASE after MERGE")
+		}
+		var stmt ShowMergeConflictsStatement
+		if parser.lexer.PeekToken().Type == Limit {
+			parser.lexer.NextToken() // consume LIMIT
+			limit, err := parseCount(parser, "LIMIT")
+			if err != nil {
+				return nil, err
+			}
+			stmt.Limit = limit
+		}
+		if parser.lexer.PeekToken().Type == Offset {
+			parser.lexer.NextToken() // consume OFFSET
+			offset, err := parseCount(parser, "OFFSET")
+			if err != nil {
+				return nil, err
+			}
+			stmt.Offset = offset
 		}
-		return ShowMergeConflictsStatement{}, nil
+		return stmt, nil
 	case Remotes:
 		return ShowRemotesStatement{}, nil
 	case Shares:
 		return ShowSharesStatement{}, nil
 	case Views:
-		// SHOW VIEWS IN database
-		token = parser.lexer.NextToken()
-		if token.Type != In {
-			return nil, errors.New("expected IN after VIEWS")
+		// SHOW VIEWS [IN database]
+		if parser.lexer.PeekToken().Type != In {
+			return ShowViewsStatement{}, nil
 		}
+		parser.lexer.NextToken() // consume IN
 		token = parser.lexer.NextToken()
 		if token.Type != Identifier {
 			return nil, errors.New("expected database name after IN")
 		}
 		return ShowViewsStatement{Database: token.Value}, nil
+	case Create:
+		// SHOW CREATE TABLE database.name or SHOW CREATE VIEW database.name
+		token = parser.lexer.NextToken()
+		if token.Type == TableIdentifier {
+			token = parser.lexer.NextToken()
+			tableParts := strings.Split(token.Value, ".")
+			if token.Type != Identifier || len(tableParts) != 2 {
+				return nil, errors.New("table name must be in format database.tablename")
+			}
+			return ShowCreateTableStatement{Database: tableParts[0], Table: tableParts[1]}, nil
+		}
+		if token.Type != View {
+			return nil, errors.New("expected TABLE or VIEW after SHOW CREATE")
+		}
+		token = parser.lexer.NextToken()
+		viewParts := strings.Split(token.Value, ".")
+		if token.Type != Identifier || len(viewParts) != 2 {
+			return nil, errors.New("view name must be in format database.viewname")
+		}
+		return ShowCreateViewStatement{Database: viewParts[0], ViewName: viewParts[1]}, nil
 	default:
-		return nil, errors.New("expected DATABASES, TABLES, INDEXES, VIEWS, BRANCHES, REMOTES, SHARES, or MERGE CONFLICTS after SHOW")
+		if isWord(token, "WARNINGS") {
+			return ShowWarningsStatement{}, nil
+		}
+		if isWord(token, "VARIABLES") {
+			return ShowVariablesStatement{}, nil
+		}
+		if isWord(token, "STATS") {
+			return ShowStatsStatement{}, nil
+		}
+		// SHOW TRIGGERS IN database
+		if isWord(token, "TRIGGERS") {
+			if parser.lexer.NextToken().Type != In {
+				return nil, errors.New("expected IN after TRIGGERS")
+			}
+			token = parser.lexer.NextToken()
+			if token.Type != Identifier {
+				return nil, errors.New("expected database name after IN")
+			}
+			return ShowTriggersStatement{Database: token.Value}, nil
+		}
+		return nil, errors.New("expected DATABASES, TABLES, INDEXES, VIEWS, CREATE VIEW, TRIGGERS, WARNINGS, VARIABLES, STATS, BRANCHES, REMOTES, SHARES, or MERGE CONFLICTS after SHOW")
 	}
 }
 
-// ParseAlter parses ALTER TABLE statements
+// ParseAlter parses ALTER TABLE and ALTER DATABASE statements
 func ParseAlter(parser *Parser) (Statement, error) {
 	token := parser.lexer.NextToken()
+	if token.Type == DatabaseIdentifier {
+		return parseAlterDatabase(parser)
+	}
 	if token.Type != TableIdentifier {
-		return nil, errors.New("expected TABLE after ALTER")
+		return nil, errors.New("expected TABLE or DATABASE after ALTER")
 	}
 
 	var statement AlterTableStatement
@@ -1981,8 +2959,24 @@ func ParseAlter(parser *Parser) (Statement, error) {
 		statement.Table = token.Value
 	}
 
-	// Parse action: ADD, DROP, MODIFY, RENAME
+	// Parse action: ADD, DROP, MODIFY, RENAME, or SET AUTHOR
 	token = parser.lexer.NextToken()
+	if token.Type == Set {
+		if !isWord(parser.lexer.NextToken(), "AUTHOR") {
+			return nil, errors.New("expected AUTHOR after SET")
+		}
+		statement.Action = "AUTHOR"
+		if parser.lexer.PeekToken().Type == Null {
+			parser.lexer.NextToken() // consume NULL: commit as the engine's identity
+			return statement, nil
+		}
+		author, err := parseAuthor(parser)
+		if err != nil {
+			return nil, err
+		}
+		statement.Author = author
+		return statement, nil
+	}
 	switch token.Type {
 	case Add:
 		statement.Action = "ADD"
@@ -1993,7 +2987,7 @@ func ParseAlter(parser *Parser) (Statement, error) {
 	case Rename:
 		statement.Action = "RENAME"
 	default:
-		return nil, errors.New("expected ADD, DROP, MODIFY, or RENAME")
+		return nil, errors.New("expected ADD, DROP, MODIFY, RENAME, or SET AUTHOR")
 	}
 
 	// Parse COLUMN (optional)
@@ -2003,7 +2997,7 @@ func ParseAlter(parser *Parser) (Statement, error) {
 	}
 
 	// Parse column name
-	if token.Type != Identifier {
+	if !isColumnName(token) {
 		return nil, errors.New("expected column name")
 	}
 	statement.ColumnName = token.Value
@@ -2017,6 +3011,35 @@ func ParseAlter(parser *Parser) (Statement, error) {
 		statement.ColumnType = token.Value
 	}
 
+	// ADD takes an optional NOT NULL / NULL and DEFAULT value, in any order
+	for statement.Action == "ADD" {
+		token = parser.lexer.PeekToken()
+		if token.Type == Not {
+			parser.lexer.NextToken() // consume NOT
+			if parser.lexer.NextToken().Type != Null {
+				return nil, errors.New("expected NULL after NOT")
+			}
+			statement.NotNull = true
+		} else if token.Type == Null {
+			parser.lexer.NextToken() // consume NULL: nullable, the default
+			statement.NotNull = false
+		} else if isWord(token, "DEFAULT") {
+			parser.lexer.NextToken() // consume DEFAULT
+			token = parser.lexer.NextToken()
+			switch token.Type {
+			case String, Int, Float:
+				value := token.Value
+				statement.Default = &value
+			case Null:
+				statement.Default = nil
+			default:
+				return nil, errors.New("expected value after DEFAULT")
+			}
+		} else {
+			break
+		}
+	}
+
 	// Parse TO newname for RENAME
 	if statement.Action == "RENAME" {
 		token = parser.lexer.NextToken()
@@ -2024,7 +3047,7 @@ func ParseAlter(parser *Parser) (Statement, error) {
 			return nil, errors.New("expected TO after RENAME COLUMN oldname")
 		}
 		token = parser.lexer.NextToken()
-		if token.Type != Identifier {
+		if !isColumnName(token) {
 			return nil, errors.New("expected new column name after TO")
 		}
 		statement.NewColumnName = token.Value
@@ -2033,6 +3056,31 @@ func ParseAlter(parser *Parser) (Statement, error) {
 	return statement, nil
 }
 
+// parseAlterDatabase parses the rest of ALTER DATABASE name RENAME TO newname
+func parseAlterDatabase(parser *Parser) (Statement, error) {
+	var statement AlterDatabaseStatement
+
+	token := parser.lexer.NextToken()
+	if token.Type != Identifier || strings.Contains(token.Value, ".") {
+		return nil, errors.New("expected database name")
+	}
+	statement.Database = token.Value
+
+	if parser.lexer.NextToken().Type != Rename {
+		return nil, errors.New("expected RENAME after ALTER DATABASE name")
+	}
+	if parser.lexer.NextToken().Type != To {
+		return nil, errors.New("expected TO after RENAME")
+	}
+	token = parser.lexer.NextToken()
+	if token.Type != Identifier || strings.Contains(token.Value, ".") {
+		return nil, errors.New("expected new database name after TO")
+	}
+	statement.NewName = token.Value
+
+	return statement, nil
+}
+
 // ParseDescribe parses DESCRIBE table statements
 func ParseDescribe(parser *Parser) (Statement, error) {
 	token := parser.lexer.NextToken()
@@ -2118,9 +3166,15 @@ func ParseMerge(parser *Parser) (Statement, error) {
 
 // ParseResolveConflict parses RESOLVE CONFLICT statements
 // Syntax: RESOLVE CONFLICT db.table.key USING HEAD|SOURCE|'value'
+//
+//	RESOLVE ALL CONFLICTS USING HEAD|SOURCE
 func ParseResolveConflict(parser *Parser) (Statement, error) {
-	// Expect CONFLICT
 	token := parser.lexer.NextToken()
+	if token.Type == Identifier && strings.ToUpper(token.Value) == "ALL" {
+		return parseResolveAllConflicts(parser)
+	}
+
+	// Expect CONFLICT
 	if token.Type != Conflict {
 		return nil, errors.New("expected CONFLICT after RESOLVE")
 	}
@@ -2166,6 +3220,29 @@ func ParseResolveConflict(parser *Parser) (Statement, error) {
 	}, nil
 }
 
+// parseResolveAllConflicts parses the rest of RESOLVE ALL CONFLICTS USING HEAD|SOURCE
+func parseResolveAllConflicts(parser *Parser) (Statement, error) {
+	token := parser.lexer.NextToken()
+	if token.Type != Conflicts {
+		return nil, errors.New("expected CONFLICTS after RESOLVE ALL")
+	}
+
+	token = parser.lexer.NextToken()
+	if token.Type != Using {
+		return nil, errors.New("expected USING after RESOLVE ALL CONFLICTS")
+	}
+
+	token = parser.lexer.NextToken()
+	switch token.Type {
+	case Head:
+		return ResolveConflictStatement{Resolution: "HEAD", All: true}, nil
+	case Source:
+		return ResolveConflictStatement{Resolution: "SOURCE", All: true}, nil
+	default:
+		return nil, errors.New("expected HEAD or SOURCE after USING")
+	}
+}
+
 // ParseAbortMerge parses ABORT MERGE statements
 func ParseAbortMerge(parser *Parser) (Statement, error) {
 	token := parser.lexer.NextToken()
@@ -2400,12 +3477,14 @@ func parseAuth(parser *Parser) (*AuthConfig, error) {
 }
 
 // ParseCopy parses COPY INTO commands for bulk data import/export
-// COPY INTO table FROM 'file.csv' [WITH (HEADER = TRUE, DELIMITER = ',')]
+// COPY INTO table FROM 'file.csv' [WITH (HEADER = TRUE, DELIMITER = ',', ON_CONFLICT = 'UPDATE')]
 // COPY INTO 'file.csv' FROM table [WITH (HEADER = TRUE)]
+// COPY INTO 'file.csv' FROM (SELECT ...) [WITH (HEADER = TRUE)]
 func ParseCopy(parser *Parser) (Statement, error) {
 	stmt := CopyStatement{
 		Delimiter: ",",  // default delimiter
 		Header:    true, // default to having headers
+		Format:    "CSV",
 	}
 
 	// Expect INTO
@@ -2427,18 +3506,39 @@ func ParseCopy(parser *Parser) (Statement, error) {
 			return nil, errors.New("expected FROM after file path")
 		}
 
-		// Expect table name (database.table)
+		// Expect a parenthesized query or a table name (database.table)
 		token = parser.lexer.NextToken()
-		if token.Type == DatabaseIdentifier {
+		if token.Type == ParenOpen {
+			if parser.lexer.NextToken().Type != Select {
+				return nil, errors.New("expected SELECT after '(' in COPY")
+			}
+			query, err := ParseSelect(parser)
+			if err != nil {
+				return nil, err
+			}
+			// ParseSelect consumes the token that ends the query, which
+			// must be the closing paren unless the query ended in FETCH
+			if !parser.lexer.lastTokenWas(')') && parser.lexer.NextToken().Type != ParenClose {
+				return nil, errors.New("expected ')' after COPY query")
+			}
+			selectStatement := query.(SelectStatement)
+			stmt.Query = &selectStatement
+		} else if token.Type == DatabaseIdentifier {
 			parts := strings.Split(token.Value, ".")
 			stmt.Database = parts[0]
 			stmt.Table = parts[1]
+		} else if token.Type == Identifier && strings.Count(token.Value, ".") == 2 {
+			// share.database.table: export from a share
+			parts := strings.Split(token.Value, ".")
+			stmt.Share = parts[0]
+			stmt.Database = parts[1]
+			stmt.Table = parts[2]
 		} else if token.Type == Identifier && strings.Contains(token.Value, ".") {
 			parts := strings.Split(token.Value, ".")
 			stmt.Database = parts[0]
 			stmt.Table = parts[1]
 		} else {
-			return nil, errors.New("expected database.table after FROM")
+			return nil, errors.New("expected database.table, share.database.table or (SELECT ...) after FROM")
 		}
 
 	} else if token.Type == Identifier || token.Type == DatabaseIdentifier {
@@ -2448,6 +3548,8 @@ func ParseCopy(parser *Parser) (Statement, error) {
 			parts := strings.Split(token.Value, ".")
 			stmt.Database = parts[0]
 			stmt.Table = parts[1]
+		} else if token.Type == Identifier && strings.Count(token.Value, ".") == 2 {
+			return nil, errors.New("COPY cannot import into a share; shares are read-only")
 		} else if token.Type == Identifier && strings.Contains(token.Value, ".") {
 			parts := strings.Split(token.Value, ".")
 			stmt.Database = parts[0]
@@ -2494,7 +3596,7 @@ func ParseCopy(parser *Parser) (Statement, error) {
 					return nil, errors.New("expected '=' after HEADER")
 				}
 				token = parser.lexer.NextToken()
-				if token.Type == Identifier {
+				if token.Type == True || token.Type == False || token.Type == Identifier {
 					stmt.Header = (toUpper(token.Value) == "TRUE")
 				} else {
 					return nil, errors.New("expected TRUE or FALSE after HEADER =")
@@ -2543,8 +3645,40 @@ func ParseCopy(parser *Parser) (Statement, error) {
 					return nil, errors.New("expected string after AWS_REGION =")
 				}
 				stmt.S3Region = token.Value
+			case Identifier:
+				if toUpper(token.Value) == "ON_CONFLICT" {
+					// ON_CONFLICT = 'UPDATE' | 'IGNORE'
+					if parser.lexer.NextToken().Type != Equals {
+						return nil, errors.New("expected '=' after ON_CONFLICT")
+					}
+					token = parser.lexer.NextToken()
+					if token.Type != String {
+						return nil, errors.New("expected string after ON_CONFLICT =")
+					}
+					stmt.OnConflict = toUpper(token.Value)
+					if stmt.OnConflict != "UPDATE" && stmt.OnConflict != "IGNORE" {
+						return nil, fmt.Errorf("unsupported ON_CONFLICT '%s'; expected UPDATE or IGNORE", token.Value)
+					}
+					break
+				}
+				// FORMAT = 'CSV' | 'PARQUET' | 'JSON'
+				if toUpper(token.Value) != "FORMAT" {
+					return nil, errors.New("expected HEADER, DELIMITER, FORMAT, ON_CONFLICT, AWS_KEY, AWS_SECRET, or AWS_REGION in WITH clause")
+				}
+				token = parser.lexer.NextToken()
+				if token.Type != Equals {
+					return nil, errors.New("expected '=' after FORMAT")
+				}
+				token = parser.lexer.NextToken()
+				if token.Type != String {
+					return nil, errors.New("expected string after FORMAT =")
+				}
+				stmt.Format = toUpper(token.Value)
+				if stmt.Format != "CSV" && stmt.Format != "PARQUET" && stmt.Format != "JSON" {
+					return nil, fmt.Errorf("unsupported COPY format '%s'; expected CSV, PARQUET or JSON", token.Value)
+				}
 			default:
-				return nil, errors.New("expected HEADER, DELIMITER, AWS_KEY, AWS_SECRET, or AWS_REGION in WITH clause")
+				return nil, errors.New("expected HEADER, DELIMITER, FORMAT, ON_CONFLICT, AWS_KEY, AWS_SECRET, or AWS_REGION in WITH clause")
 			}
 
 			// Check for comma or closing paren
@@ -2636,7 +3770,8 @@ func ParseDropShare(parser *Parser) (Statement, error) {
 	return DropShareStatement{Name: token.Value}, nil
 }
 
-// ParseCreateView parses CREATE [MATERIALIZED] VIEW database.name AS SELECT ...
+// ParseCreateView parses CREATE [OR REPLACE] [MATERIALIZED] VIEW database.name
+// [WITH AUTO REFRESH [EVERY 'duration']] AS SELECT ...
 func ParseCreateView(parser *Parser, materialized bool) (Statement, error) {
 	var stmt CreateViewStatement
 	stmt.Materialized = materialized
@@ -2655,35 +3790,68 @@ func ParseCreateView(parser *Parser, materialized bool) (Statement, error) {
 		return nil, errors.New("view name must be in format database.viewname")
 	}
 
-	// Expect AS
 	token = parser.lexer.NextToken()
+	if token.Type == With {
+		if err := parseAutoRefresh(parser, &stmt); err != nil {
+			return nil, err
+		}
+		token = parser.lexer.NextToken()
+	}
+
+	// Expect AS
 	if token.Type != As {
 		return nil, errors.New("expected AS after view name")
 	}
 
-	// Capture the rest as raw SELECT query
-	// We need to capture everything after AS until end of statement
-	var queryParts []string
+	// Keep everything after AS until the end of the statement as written
+	start := parser.lexer.position
 	for {
 		token = parser.lexer.NextToken()
 		if token.Type == EOF || token.Type == Unknown {
 			break
 		}
-		if token.Type == String {
-			queryParts = append(queryParts, "'"+token.Value+"'")
-		} else {
-			queryParts = append(queryParts, token.Value)
-		}
 	}
+	stmt.SelectQuery = strings.TrimSpace(parser.lexer.sql[start:parser.lexer.tokenStart])
 
-	if len(queryParts) == 0 {
+	if stmt.SelectQuery == "" {
 		return nil, errors.New("expected SELECT query after AS")
 	}
-
-	stmt.SelectQuery = strings.Join(queryParts, " ")
 	return stmt, nil
 }
 
+// parseAutoRefresh parses the AUTO REFRESH [EVERY 'duration'] option that
+// follows WITH in CREATE MATERIALIZED VIEW
+func parseAutoRefresh(parser *Parser, stmt *CreateViewStatement) error {
+	token := parser.lexer.NextToken()
+	if token.Type != Identifier || strings.ToUpper(token.Value) != "AUTO" {
+		return errors.New("expected AUTO REFRESH after WITH")
+	}
+	if token = parser.lexer.NextToken(); token.Type != Refresh {
+		return errors.New("expected REFRESH after AUTO")
+	}
+	if !stmt.Materialized {
+		return errors.New("AUTO REFRESH is only supported for materialized views")
+	}
+	stmt.AutoRefresh = true
+
+	token = parser.lexer.PeekToken()
+	if token.Type != Identifier || strings.ToUpper(token.Value) != "EVERY" {
+		return nil
+	}
+	parser.lexer.NextToken()
+
+	token = parser.lexer.NextToken()
+	if token.Type != String {
+		return errors.New("expected interval such as '5m' after EVERY")
+	}
+	interval, err := time.ParseDuration(token.Value)
+	if err != nil || interval <= 0 {
+		return fmt.Errorf("invalid refresh interval '%s'", token.Value)
+	}
+	stmt.RefreshInterval = interval
+	return nil
+}
+
 // ParseDropView parses DROP [MATERIALIZED] VIEW [IF EXISTS] database.name
 func ParseDropView(parser *Parser) (Statement, error) {
 	var stmt DropViewStatement
@@ -2715,6 +3883,177 @@ func ParseDropView(parser *Parser) (Statement, error) {
 	return stmt, nil
 }
 
+// ParseSetVariable parses SET name = value, where value is a string, a
+// number, TRUE, FALSE or a bare word such as DEFAULT
+func ParseSetVariable(parser *Parser) (Statement, error) {
+	token := parser.lexer.NextToken()
+	if !isColumnName(token) {
+		return nil, errors.New("expected setting name after SET")
+	}
+	stmt := SetVariableStatement{Name: strings.ToLower(token.Value)}
+
+	if parser.lexer.NextToken().Type != Equals {
+		return nil, fmt.Errorf("expected '=' after %s", token.Value)
+	}
+	token = parser.lexer.NextToken()
+	switch token.Type {
+	case String, Int, Float, True, False, Identifier:
+		stmt.Value = token.Value
+	default:
+		return nil, fmt.Errorf("expected value for %s", stmt.Name)
+	}
+	return stmt, nil
+}
+
+// ParseBegin parses BEGIN [READ ONLY]
+func ParseBegin(parser *Parser) (Statement, error) {
+	if !isWord(parser.lexer.PeekToken(), "READ") {
+		return BeginStatement{}, nil
+	}
+	parser.lexer.NextToken()
+	if !isWord(parser.lexer.NextToken(), "ONLY") {
+		return nil, errors.New("expected ONLY after BEGIN READ")
+	}
+	return BeginStatement{ReadOnly: true}, nil
+}
+
+// ParseRollback parses ROLLBACK [TO [SAVEPOINT] name] after ROLLBACK
+func ParseRollback(parser *Parser) (Statement, error) {
+	if parser.lexer.PeekToken().Type != To {
+		if token := parser.lexer.NextToken(); token.Type != EOF && token.Value != ";" {
+			return nil, errors.New("unexpected " + token.Value + " after ROLLBACK")
+		}
+		return RollbackStatement{}, nil
+	}
+	parser.lexer.NextToken()
+	if isWord(parser.lexer.PeekToken(), "SAVEPOINT") {
+		parser.lexer.NextToken()
+	}
+	name, err := parseSavepointName(parser, "ROLLBACK TO")
+	if err != nil {
+		return nil, err
+	}
+	return RollbackStatement{Savepoint: name}, nil
+}
+
+// parseSavepointName parses the savepoint name that ends a statement
+func parseSavepointName(parser *Parser, after string) (string, error) {
+	token := parser.lexer.NextToken()
+	if token.Type != Identifier || strings.Contains(token.Value, ".") {
+		return "", errors.New("expected savepoint name after " + after)
+	}
+	if next := parser.lexer.NextToken(); next.Type != EOF && next.Value != ";" {
+		return "", errors.New("unexpected " + next.Value + " after savepoint name")
+	}
+	return token.Value, nil
+}
+
+// ParseCreateTrigger parses
+// CREATE TRIGGER [database.]name AFTER {INSERT | UPDATE | DELETE} ON database.table
+// BEGIN statement; ... END
+func ParseCreateTrigger(parser *Parser) (Statement, error) {
+	var stmt CreateTriggerStatement
+
+	token := parser.lexer.NextToken()
+	if token.Type != Identifier {
+		return nil, errors.New("expected trigger name after TRIGGER")
+	}
+	name := token.Value
+
+	if !isWord(parser.lexer.NextToken(), "AFTER") {
+		return nil, errors.New("expected AFTER after trigger name")
+	}
+	switch parser.lexer.NextToken().Type {
+	case Insert:
+		stmt.Event = "INSERT"
+	case Update:
+		stmt.Event = "UPDATE"
+	case Delete:
+		stmt.Event = "DELETE"
+	default:
+		return nil, errors.New("expected INSERT, UPDATE, or DELETE after AFTER")
+	}
+
+	if parser.lexer.NextToken().Type != On {
+		return nil, fmt.Errorf("expected ON after AFTER %s", stmt.Event)
+	}
+	token = parser.lexer.NextToken()
+	tableParts := strings.Split(token.Value, ".")
+	if token.Type != Identifier || len(tableParts) != 2 {
+		return nil, errors.New("expected database.table after ON")
+	}
+	stmt.Database = tableParts[0]
+	stmt.Table = tableParts[1]
+
+	// The trigger lives in its table's database
+	nameParts := strings.Split(name, ".")
+	switch {
+	case len(nameParts) == 1:
+		stmt.Name = name
+	case len(nameParts) == 2 && nameParts[0] == stmt.Database:
+		stmt.Name = nameParts[1]
+	default:
+		return nil, fmt.Errorf("trigger name must be name or %s.name", stmt.Database)
+	}
+
+	if parser.lexer.NextToken().Type != Begin {
+		return nil, errors.New("expected BEGIN after trigger table")
+	}
+	body, err := parseTriggerBody(parser)
+	if err != nil {
+		return nil, err
+	}
+	stmt.Body = body
+	return stmt, nil
+}
+
+// parseTriggerBody returns the source text between BEGIN and the END that
+// closes the statement. The body's own statements may use END as a name, so
+// only the last word before the end of input counts.
+func parseTriggerBody(parser *Parser) (string, error) {
+	lexer := parser.lexer
+	start := lexer.position
+	end := -1
+	for token := lexer.NextToken(); token.Type != EOF; token = lexer.NextToken() {
+		if isWord(token, "END") {
+			end = lexer.tokenStart
+		} else if token.Type != Unknown || token.Value != ";" {
+			end = -1
+		}
+	}
+	if end < 0 {
+		return "", errors.New("expected END after trigger body")
+	}
+
+	body := strings.TrimSuffix(strings.TrimSpace(lexer.sql[start:end]), ";")
+	if strings.TrimSpace(body) == "" {
+		return "", errors.New("expected statements between BEGIN and END")
+	}
+	return strings.TrimSpace(body), nil
+}
+
+// ParseDropTrigger parses DROP TRIGGER [IF EXISTS] database.name
+func ParseDropTrigger(parser *Parser) (Statement, error) {
+	var stmt DropTriggerStatement
+
+	token := parser.lexer.NextToken()
+	if token.Type == If {
+		if parser.lexer.NextToken().Type != Exists {
+			return nil, errors.New("expected EXISTS after IF")
+		}
+		stmt.IfExists = true
+		token = parser.lexer.NextToken()
+	}
+
+	parts := strings.Split(token.Value, ".")
+	if token.Type != Identifier || len(parts) != 2 {
+		return nil, errors.New("trigger name must be in format database.name")
+	}
+	stmt.Database = parts[0]
+	stmt.Name = parts[1]
+	return stmt, nil
+}
+
 // ParseRefreshView parses REFRESH VIEW database.name
 func ParseRefreshView(parser *Parser) (Statement, error) {
 	// Expect VIEW
@@ -2739,3 +4078,42 @@ func ParseRefreshView(parser *Parser) (Statement, error) {
 		ViewName: viewParts[1],
 	}, nil
 }
+
+// parseCheckDatabase parses CHECK DATABASE [database] after CHECK
+func parseCheckDatabase(parser *Parser) (Statement, error) {
+	if parser.lexer.NextToken().Type != DatabaseIdentifier {
+		return nil, errors.New("expected DATABASE after CHECK")
+	}
+
+	if parser.lexer.PeekToken().Type != Identifier {
+		return CheckDatabaseStatement{}, nil
+	}
+	token := parser.lexer.NextToken()
+	if strings.Contains(token.Value, ".") {
+		return nil, errors.New("expected database name after CHECK DATABASE")
+	}
+	return CheckDatabaseStatement{Database: token.Value}, nil
+}
+
+// ParseRepairTable parses: REPAIR TABLE database.table
+func ParseRepairTable(parser *Parser) (Statement, error) {
+	token := parser.lexer.NextToken()
+	if token.Type != TableIdentifier {
+		return nil, errors.New("expected TABLE after REPAIR")
+	}
+
+	token = parser.lexer.NextToken()
+	if token.Type != Identifier {
+		return nil, errors.New("expected table name after REPAIR TABLE")
+	}
+
+	parts := strings.Split(token.Value, ".")
+	if len(parts) != 2 {
+		return nil, errors.New("expected database.table format")
+	}
+
+	return RepairTableStatement{
+		Database: parts[0],
+		Table:    parts[1],
+	}, nil
+}
@@ -2,12 +2,17 @@ package sql
 
 import (
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/nickyhof/CommitDB/core"
 )
 
 func TestParser(t *testing.T) {
+	emptyObject, zero := "{}", "0"
+
 	tests := []struct {
 		name     string
 		sql      string
@@ -70,6 +75,7 @@ func TestParser(t *testing.T) {
 				Columns:  []string{"col_1", "col_2"},
 				Where:    WhereClause{Conditions: []WhereCondition{{Left: "col_1", Operator: EqualsOperator, Right: "green"}, {Left: "col_2", Operator: EqualsOperator, Right: "5"}}, LogicalOps: []LogicalOperator{LogicalAnd}},
 				Limit:    10,
+				LimitSet: true,
 			},
 		},
 		{
@@ -96,6 +102,139 @@ func TestParser(t *testing.T) {
 				},
 			},
 		},
+		{
+			"create table with default",
+			"CREATE TABLE db.test (id INT DEFAULT 0 PRIMARY KEY, data JSON DEFAULT '{}', note STRING DEFAULT NULL)",
+			CreateTableStatement{
+				Database: "db",
+				Table:    "test",
+				Columns: []core.Column{
+					{Name: "id", Type: core.IntType, PrimaryKey: true, Default: &zero},
+					{Name: "data", Type: core.JsonType, Default: &emptyObject},
+					{Name: "note", Type: core.StringType},
+				},
+			},
+		},
+		{
+			"create table with not null",
+			"CREATE TABLE db.test (id INT PRIMARY KEY, name STRING NOT NULL, data JSON NOT NULL DEFAULT '{}', note STRING NULL)",
+			CreateTableStatement{
+				Database: "db",
+				Table:    "test",
+				Columns: []core.Column{
+					{Name: "id", Type: core.IntType, PrimaryKey: true},
+					{Name: "name", Type: core.StringType, NotNull: true},
+					{Name: "data", Type: core.JsonType, NotNull: true, Default: &emptyObject},
+					{Name: "note", Type: core.StringType},
+				},
+			},
+		},
+		{
+			"create table with fanout",
+			"CREATE TABLE db.test (id INT PRIMARY KEY, name STRING) WITH FANOUT 2",
+			CreateTableStatement{
+				Database: "db",
+				Table:    "test",
+				Columns: []core.Column{
+					{Name: "id", Type: core.IntType, PrimaryKey: true},
+					{Name: "name", Type: core.StringType},
+				},
+				Fanout: 2,
+			},
+		},
+		{
+			"create table with comments",
+			"CREATE TABLE db.test (id INT PRIMARY KEY COMMENT 'Order number', total FLOAT COMMENT 'In cents') COMMENT 'Customer orders' WITH FANOUT 1",
+			CreateTableStatement{
+				Database: "db",
+				Table:    "test",
+				Columns: []core.Column{
+					{Name: "id", Type: core.IntType, PrimaryKey: true, Comment: "Order number"},
+					{Name: "total", Type: core.FloatType, Comment: "In cents"},
+				},
+				Fanout:  1,
+				Comment: "Customer orders",
+			},
+		},
+		{
+			"create table with seed rows",
+			"CREATE TABLE db.test (id INT PRIMARY KEY, name STRING) VALUES (1, 'Alice'), (2, NULL)",
+			CreateTableStatement{
+				Database: "db",
+				Table:    "test",
+				Columns: []core.Column{
+					{Name: "id", Type: core.IntType, PrimaryKey: true},
+					{Name: "name", Type: core.StringType},
+				},
+				ValueRows: [][]string{{"1", "Alice"}, {"2", NullValue}},
+			},
+		},
+		{
+			"create table with author",
+			"CREATE TABLE db.test (id INT PRIMARY KEY) AUTHOR 'Loader Bot <bot@example.com>'",
+			CreateTableStatement{
+				Database: "db",
+				Table:    "test",
+				Columns: []core.Column{
+					{Name: "id", Type: core.IntType, PrimaryKey: true},
+				},
+				Author: &core.Identity{Name: "Loader Bot", Email: "bot@example.com"},
+			},
+		},
+		{
+			"alter database rename",
+			"ALTER DATABASE db RENAME TO archive",
+			AlterDatabaseStatement{
+				Database: "db",
+				NewName:  "archive",
+			},
+		},
+		{
+			"alter table set author",
+			"ALTER TABLE db.test SET AUTHOR 'Loader Bot <bot@example.com>'",
+			AlterTableStatement{
+				Database: "db",
+				Table:    "test",
+				Action:   "AUTHOR",
+				Author:   &core.Identity{Name: "Loader Bot", Email: "bot@example.com"},
+			},
+		},
+		{
+			"alter table add not null column with default",
+			"ALTER TABLE db.test ADD COLUMN status STRING NOT NULL DEFAULT 'new'",
+			AlterTableStatement{
+				Database:   "db",
+				Table:      "test",
+				Action:     "ADD",
+				ColumnName: "status",
+				ColumnType: "STRING",
+				NotNull:    true,
+				Default:    func() *string { value := "new"; return &value }(),
+			},
+		},
+		{
+			"create table with function default and checks",
+			"CREATE TABLE db.test (id INT PRIMARY KEY, name STRING, price FLOAT CHECK (price >= 0), created TIMESTAMP DEFAULT NOW(), CHECK (LENGTH(name) > 0))",
+			CreateTableStatement{
+				Database: "db",
+				Table:    "test",
+				Columns: []core.Column{
+					{Name: "id", Type: core.IntType, PrimaryKey: true},
+					{Name: "name", Type: core.StringType},
+					{Name: "price", Type: core.FloatType},
+					{Name: "created", Type: core.TimestampType, DefaultExpr: "NOW()"},
+				},
+				Checks: []string{"price >= 0", "LENGTH(name) > 0"},
+			},
+		},
+		{
+			"repair table",
+			"REPAIR TABLE db.test",
+			RepairTableStatement{
+				Database: "db",
+				Table:    "test",
+			},
+		},
 		{
 			"drop table",
 			"DROP TABLE db.test",
@@ -114,6 +253,67 @@ func TestParser(t *testing.T) {
 				ValueRows: [][]string{{"value", "1"}},
 			},
 		},
+		{
+			"insert null and empty string",
+			"INSERT INTO db.test (col_1, col_2) VALUES (NULL, '')",
+			InsertStatement{
+				Database:  "db",
+				Table:     "test",
+				Columns:   []string{"col_1", "col_2"},
+				ValueRows: [][]string{{NullValue, ""}},
+			},
+		},
+		{
+			"insert now and a quoted now",
+			"INSERT INTO db.test (col_1, col_2) VALUES (NOW(), 'NOW()')",
+			InsertStatement{
+				Database:  "db",
+				Table:     "test",
+				Columns:   []string{"col_1", "col_2"},
+				ValueRows: [][]string{{NowValue, "NOW()"}},
+			},
+		},
+		{
+			"insert default",
+			"INSERT INTO db.test (id, status) VALUES (1, DEFAULT)",
+			InsertStatement{
+				Database:  "db",
+				Table:     "test",
+				Columns:   []string{"id", "status"},
+				ValueRows: [][]string{{"1", DefaultValue}},
+			},
+		},
+		{
+			"insert from base64",
+			"INSERT INTO db.test (id, data) VALUES (1, FROM_BASE64('AP8n'))",
+			InsertStatement{
+				Database:  "db",
+				Table:     "test",
+				Columns:   []string{"id", "data"},
+				ValueRows: [][]string{{"1", "\x00\xff'"}},
+			},
+		},
+		{
+			"insert non-reserved column names",
+			"insert into db.test (key, source, date) values (1, 'a', '2024-01-01')",
+			InsertStatement{
+				Database:  "db",
+				Table:     "test",
+				Columns:   []string{"key", "source", "date"},
+				ValueRows: [][]string{{"1", "a", "2024-01-01"}},
+			},
+		},
+		{
+			"insert returning",
+			"INSERT INTO db.test (id, name) VALUES (1, 'a'), (2, 'b') RETURNING id, name",
+			InsertStatement{
+				Database:  "db",
+				Table:     "test",
+				Columns:   []string{"id", "name"},
+				ValueRows: [][]string{{"1", "a"}, {"2", "b"}},
+				Returning: []string{"id", "name"},
+			},
+		},
 		{
 			"update table",
 			"UPDATE db.test SET col_1 = 'value' WHERE col_2 = 5",
@@ -126,6 +326,63 @@ func TestParser(t *testing.T) {
 				Where: WhereClause{Conditions: []WhereCondition{{Left: "col_2", Operator: EqualsOperator, Right: "5"}}},
 			},
 		},
+		{
+			"update set default",
+			"UPDATE db.test SET status = DEFAULT WHERE id = 1",
+			UpdateStatement{
+				Database: "db",
+				Table:    "test",
+				Updates: []SetClause{
+					{Column: "status", Value: DefaultValue},
+				},
+				Where: WhereClause{Conditions: []WhereCondition{{Left: "id", Operator: EqualsOperator, Right: "1"}}},
+			},
+		},
+		{
+			"update returning",
+			"UPDATE db.test SET col_1 = 'value' WHERE id = 5 RETURNING col_1",
+			UpdateStatement{
+				Database: "db",
+				Table:    "test",
+				Updates: []SetClause{
+					{Column: "col_1", Value: "value"},
+				},
+				Where:     WhereClause{Conditions: []WhereCondition{{Left: "id", Operator: EqualsOperator, Right: "5"}}},
+				Returning: []string{"col_1"},
+			},
+		},
+		{
+			"delete returning",
+			"DELETE FROM db.test WHERE id = 5 RETURNING *",
+			DeleteStatement{
+				Database:  "db",
+				Table:     "test",
+				Where:     WhereClause{Conditions: []WhereCondition{{Left: "id", Operator: EqualsOperator, Right: "5"}}},
+				Returning: []string{"*"},
+			},
+		},
+		{
+			"delete with limit",
+			"DELETE FROM db.logs WHERE level = 'debug' LIMIT 1000 RETURNING id",
+			DeleteStatement{
+				Database:  "db",
+				Table:     "logs",
+				Where:     WhereClause{Conditions: []WhereCondition{{Left: "level", Operator: EqualsOperator, Right: "debug"}}},
+				Limit:     1000,
+				Returning: []string{"id"},
+			},
+		},
+		{
+			"update with limit",
+			"UPDATE db.logs SET level = 'info' WHERE level = 'debug' LIMIT 10",
+			UpdateStatement{
+				Database: "db",
+				Table:    "logs",
+				Updates:  []SetClause{{Column: "level", Value: "info"}},
+				Where:    WhereClause{Conditions: []WhereCondition{{Left: "level", Operator: EqualsOperator, Right: "debug"}}},
+				Limit:    10,
+			},
+		},
 		{
 			"delete table",
 			"DELETE FROM db.test WHERE col_1 = 'value 123'",
@@ -135,6 +392,21 @@ func TestParser(t *testing.T) {
 				Where:    WhereClause{Conditions: []WhereCondition{{Left: "col_1", Operator: EqualsOperator, Right: "value 123"}}},
 			},
 		},
+		{
+			"dry run delete",
+			"DRY RUN DELETE FROM db.logs WHERE level = 'debug'",
+			DeleteStatement{
+				Database: "db",
+				Table:    "logs",
+				Where:    WhereClause{Conditions: []WhereCondition{{Left: "level", Operator: EqualsOperator, Right: "debug"}}},
+				DryRun:   true,
+			},
+		},
+		{
+			"dry run drop table",
+			"dry run DROP TABLE db.logs",
+			DropTableStatement{Database: "db", Table: "logs", DryRun: true},
+		},
 		{
 			"create database",
 			"CREATE DATABASE test",
@@ -250,6 +522,36 @@ func TestParser(t *testing.T) {
 				Where:    WhereClause{Conditions: []WhereCondition{{Left: "name", Operator: LikeOperator, Right: "%john%"}}},
 			},
 		},
+		{
+			"select with not in null",
+			"SELECT * FROM db.test WHERE name NOT IN ('a', NULL)",
+			SelectStatement{
+				Database: "db",
+				Table:    "test",
+				Columns:  []string{},
+				Where:    WhereClause{Conditions: []WhereCondition{{Left: "name", Operator: InOperator, InValues: []string{"a", NullValue}, Negated: true}}},
+			},
+		},
+		{
+			"select with like any",
+			"SELECT * FROM db.test WHERE name LIKE ANY ('a%', 'b%')",
+			SelectStatement{
+				Database: "db",
+				Table:    "test",
+				Columns:  []string{},
+				Where:    WhereClause{Conditions: []WhereCondition{{Left: "name", Operator: LikeAnyOperator, InValues: []string{"a%", "b%"}}}},
+			},
+		},
+		{
+			"select with like all",
+			"SELECT * FROM db.test WHERE name LIKE ALL ('%a%', '%b')",
+			SelectStatement{
+				Database: "db",
+				Table:    "test",
+				Columns:  []string{},
+				Where:    WhereClause{Conditions: []WhereCondition{{Left: "name", Operator: LikeAllOperator, InValues: []string{"%a%", "%b"}}}},
+			},
+		},
 		{
 			"select with order by",
 			"SELECT * FROM db.test ORDER BY col",
@@ -288,9 +590,54 @@ func TestParser(t *testing.T) {
 				Table:    "test",
 				Columns:  []string{},
 				Limit:    10,
+				LimitSet: true,
 				Offset:   5,
 			},
 		},
+		{
+			"select with offset only",
+			"SELECT * FROM db.test OFFSET 5",
+			SelectStatement{
+				Database: "db",
+				Table:    "test",
+				Columns:  []string{},
+				Offset:   5,
+			},
+		},
+		{
+			"select with offset rows and fetch next",
+			"SELECT * FROM db.test ORDER BY col1 OFFSET 5 ROWS FETCH NEXT 10 ROWS ONLY",
+			SelectStatement{
+				Database: "db",
+				Table:    "test",
+				Columns:  []string{},
+				OrderBy:  []OrderByClause{{Column: "col1"}},
+				Limit:    10,
+				LimitSet: true,
+				Offset:   5,
+			},
+		},
+		{
+			"select with fetch first row",
+			"SELECT * FROM db.test fetch first row only",
+			SelectStatement{
+				Database: "db",
+				Table:    "test",
+				Columns:  []string{},
+				Limit:    1,
+				LimitSet: true,
+			},
+		},
+		{
+			"select with limit 0",
+			"SELECT * FROM db.test LIMIT 0",
+			SelectStatement{
+				Database: "db",
+				Table:    "test",
+				Columns:  []string{},
+				LimitSet: true,
+			},
+		},
 		{
 			"select distinct",
 			"SELECT DISTINCT col FROM db.test",
@@ -301,14 +648,148 @@ func TestParser(t *testing.T) {
 				Distinct: true,
 			},
 		},
+		{
+			"select distinct on",
+			"SELECT DISTINCT ON (customer, region) * FROM db.orders ORDER BY customer, created DESC",
+			SelectStatement{
+				Database:   "db",
+				Table:      "orders",
+				Columns:    []string{},
+				DistinctOn: []string{"customer", "region"},
+				OrderBy: []OrderByClause{
+					{Column: "customer"},
+					{Column: "created", Descending: true},
+				},
+			},
+		},
+		{
+			"select literal columns",
+			"SELECT id, 'active' AS status, 1 AS version, 2.5 FROM db.users",
+			SelectStatement{
+				Database: "db",
+				Table:    "users",
+				Columns:  []string{"id", "status", "version", "2.5"},
+				Literals: map[string]string{"status": "active", "version": "1", "2.5": "2.5"},
+			},
+		},
 		{
 			"select count star",
 			"SELECT COUNT(*) FROM db.test",
+			SelectStatement{
+				Database:   "db",
+				Table:      "test",
+				Aggregates: []AggregateExpr{{Function: "COUNT", Column: "*"}},
+			},
+		},
+		{
+			"select qualified wildcard",
+			"SELECT u.*, o.total FROM db.users u",
+			SelectStatement{
+				Database:   "db",
+				Table:      "users",
+				TableAlias: "u",
+				Columns:    []string{"u.*", "o.total"},
+			},
+		},
+		{
+			"show merge conflicts page",
+			"SHOW MERGE CONFLICTS LIMIT 10 OFFSET 20",
+			ShowMergeConflictsStatement{Limit: 10, Offset: 20},
+		},
+		{
+			"group by function",
+			"SELECT YEAR(created) AS yr, COUNT(*), SUM(LENGTH(name)) FROM db.events GROUP BY YEAR(created)",
+			SelectStatement{
+				Database:       "db",
+				Table:          "events",
+				Functions:      []FunctionExpr{{Function: "YEAR", Args: []string{"created"}, Alias: "yr"}},
+				FunctionsAfter: []int{0},
+				Aggregates:     []AggregateExpr{{Function: "COUNT", Column: "*"}, {Function: "SUM", Column: "LENGTH(name)"}},
+				GroupBy:        []string{"YEAR(created)"},
+				Computed: []FunctionExpr{
+					{Function: "LENGTH", Args: []string{"name"}},
+					{Function: "YEAR", Args: []string{"created"}},
+				},
+			},
+		},
+		{
+			"select user-defined function",
+			"SELECT name, geohash(lat, lon, 7) AS hash, id FROM db.places",
+			SelectStatement{
+				Database:       "db",
+				Table:          "places",
+				Functions:      []FunctionExpr{{Function: "GEOHASH", Args: []string{"lat", "lon", "7"}, Alias: "hash", Literal: []bool{false, false, true}}},
+				FunctionsAfter: []int{1},
+				Columns:        []string{"name", "id"},
+			},
+		},
+		{
+			"select interval arithmetic",
+			"SELECT created + INTERVAL 7 DAY, created - INTERVAL '1' months AS earlier FROM db.events",
+			SelectStatement{
+				Database: "db",
+				Table:    "events",
+				Functions: []FunctionExpr{
+					{Function: "DATE_ADD", Args: []string{"created", "7", "DAY"}, Alias: "created + INTERVAL 7 DAY", Literal: []bool{false, true, true}},
+					{Function: "DATE_SUB", Args: []string{"created", "1", "MONTH"}, Alias: "earlier", Literal: []bool{false, true, true}},
+				},
+				FunctionsAfter: []int{0, 0},
+			},
+		},
+		{
+			"where interval arithmetic",
+			"SELECT * FROM db.events WHERE created > '2024-01-01' + INTERVAL 100 DAY AND created < '2024-06-01 12:00:00' - INTERVAL '2' HOURS",
+			SelectStatement{
+				Database: "db",
+				Table:    "events",
+				Columns:  []string{},
+				Where: WhereClause{
+					Conditions: []WhereCondition{
+						{Left: "created", Operator: GreaterThanOperator, Right: "2024-04-10"},
+						{Left: "created", Operator: LessThanOperator, Right: "2024-06-01 10:00:00"},
+					},
+					LogicalOps: []LogicalOperator{LogicalAnd},
+				},
+			},
+		},
+		{
+			"show merge base",
+			"SHOW MERGE BASE master feature",
+			ShowMergeBaseStatement{BranchA: "master", BranchB: "feature"},
+		},
+		{
+			"show table status",
+			"SHOW TABLE STATUS IN mydb",
+			ShowTableStatusStatement{Database: "mydb"},
+		},
+		{
+			"flush",
+			"FLUSH",
+			FlushStatement{},
+		},
+		{
+			"resolve all conflicts",
+			"RESOLVE ALL CONFLICTS USING SOURCE",
+			ResolveConflictStatement{Resolution: "SOURCE", All: true},
+		},
+		{
+			"select with no index hint",
+			"SELECT /*+ NO_INDEX */ * FROM db.test WHERE col = 5",
+			SelectStatement{
+				Database: "db",
+				Table:    "test",
+				Columns:  []string{},
+				Where:    WhereClause{Conditions: []WhereCondition{{Left: "col", Operator: EqualsOperator, Right: "5"}}},
+				NoIndex:  true,
+			},
+		},
+		{
+			"select with block comment",
+			"SELECT /* NO_INDEX */ * FROM db.test",
 			SelectStatement{
 				Database: "db",
 				Table:    "test",
 				Columns:  []string{},
-				CountAll: true,
 			},
 		},
 		{
@@ -332,9 +813,24 @@ func TestParser(t *testing.T) {
 				Where:    WhereClause{Conditions: []WhereCondition{{Left: "col1", Operator: GreaterThanOperator, Right: "5"}, {Left: "col2", Operator: IsNotNullOperator, Right: ""}}, LogicalOps: []LogicalOperator{LogicalAnd}},
 				OrderBy:  []OrderByClause{{Column: "col1", Descending: true}},
 				Limit:    10,
+				LimitSet: true,
 				Offset:   20,
 			},
 		},
+		{
+			"select mixed-case keywords with non-reserved column names",
+			"Select key, View From db.test wHeRe Source = 'x' aNd key > 1 Order By view Desc",
+			SelectStatement{
+				Database: "db",
+				Table:    "test",
+				Columns:  []string{"key", "View"},
+				Where: WhereClause{
+					Conditions: []WhereCondition{{Left: "Source", Operator: EqualsOperator, Right: "x"}, {Left: "key", Operator: GreaterThanOperator, Right: "1"}},
+					LogicalOps: []LogicalOperator{LogicalAnd},
+				},
+				OrderBy: []OrderByClause{{Column: "view", Descending: true}},
+			},
+		},
 		{
 			"select column and count star",
 			"SELECT city, COUNT(*) FROM db.test",
@@ -366,6 +862,56 @@ func TestParser(t *testing.T) {
 				GroupBy:    []string{"city"},
 			},
 		},
+		{
+			"select columns and aliased aggregates with group by",
+			"SELECT region, SUM(amount) AS total, COUNT(*) AS orders FROM db.test GROUP BY region",
+			SelectStatement{
+				Database: "db",
+				Table:    "test",
+				Columns:  []string{"region"},
+				Aggregates: []AggregateExpr{
+					{Function: "SUM", Column: "amount", Alias: "total"},
+					{Function: "COUNT", Column: "*", Alias: "orders"},
+				},
+				GroupBy: []string{"region"},
+			},
+		},
+		{
+			"select filtered aggregates",
+			"SELECT COUNT(*) FILTER (WHERE status = 'active') AS active, SUM(amount) FILTER (WHERE status != 'active') FROM db.test",
+			SelectStatement{
+				Database: "db",
+				Table:    "test",
+				Aggregates: []AggregateExpr{
+					{Function: "COUNT", Column: "*", Alias: "active", Filter: WhereClause{Conditions: []WhereCondition{{Left: "status", Operator: EqualsOperator, Right: "active"}}}},
+					{Function: "SUM", Column: "amount", Filter: WhereClause{Conditions: []WhereCondition{{Left: "status", Operator: NotEqualsOperator, Right: "active"}}}},
+				},
+			},
+		},
+		{
+			"select column and filtered count",
+			"SELECT city, COUNT(*) FILTER (WHERE age > 30) FROM db.test GROUP BY city",
+			SelectStatement{
+				Database:   "db",
+				Table:      "test",
+				Columns:    []string{"city"},
+				Aggregates: []AggregateExpr{{Function: "COUNT", Column: "*", Filter: WhereClause{Conditions: []WhereCondition{{Left: "age", Operator: GreaterThanOperator, Right: "30"}}}}},
+				GroupBy:    []string{"city"},
+			},
+		},
+		{
+			"select count distinct with having",
+			"SELECT dept, COUNT(DISTINCT project) FROM db.test GROUP BY dept HAVING COUNT(DISTINCT project) > 2",
+			SelectStatement{
+				Database:         "db",
+				Table:            "test",
+				Columns:          []string{"dept"},
+				Aggregates:       []AggregateExpr{{Function: "COUNT", Column: "project", Distinct: true}},
+				GroupBy:          []string{"dept"},
+				Having:           WhereClause{Conditions: []WhereCondition{{Left: "COUNT(DISTINCT project)", Operator: GreaterThanOperator, Right: "2"}}},
+				HavingAggregates: []AggregateExpr{{Function: "COUNT", Column: "project", Distinct: true}},
+			},
+		},
 		// View tests
 		{
 			"create view",
@@ -383,8 +929,77 @@ func TestParser(t *testing.T) {
 			CreateViewStatement{
 				Database:     "db",
 				ViewName:     "user_stats",
-				SelectQuery:  "SELECT city , COUNT ( * ) FROM db.users GROUP BY city",
+				SelectQuery:  "SELECT city, COUNT(*) FROM db.users GROUP BY city",
+				Materialized: true,
+			},
+		},
+		{
+			"show views in all databases",
+			"SHOW VIEWS",
+			ShowViewsStatement{},
+		},
+		{
+			"show create table",
+			"SHOW CREATE TABLE db.users",
+			ShowCreateTableStatement{Database: "db", Table: "users"},
+		},
+		{
+			"show create view",
+			"SHOW CREATE VIEW db.active_users",
+			ShowCreateViewStatement{Database: "db", ViewName: "active_users"},
+		},
+		{
+			"check database",
+			"CHECK DATABASE db",
+			CheckDatabaseStatement{Database: "db"},
+		},
+		{
+			"check all databases",
+			"CHECK DATABASE",
+			CheckDatabaseStatement{},
+		},
+		{
+			"create or replace view",
+			"CREATE OR REPLACE VIEW db.active_users AS SELECT * FROM db.users",
+			CreateViewStatement{
+				Database:    "db",
+				ViewName:    "active_users",
+				SelectQuery: "SELECT * FROM db.users",
+				OrReplace:   true,
+			},
+		},
+		{
+			"create or replace materialized view",
+			"CREATE OR REPLACE MATERIALIZED VIEW db.user_stats AS SELECT * FROM db.users",
+			CreateViewStatement{
+				Database:     "db",
+				ViewName:     "user_stats",
+				SelectQuery:  "SELECT * FROM db.users",
+				Materialized: true,
+				OrReplace:    true,
+			},
+		},
+		{
+			"create auto refresh materialized view",
+			"CREATE MATERIALIZED VIEW db.user_stats WITH AUTO REFRESH AS SELECT * FROM db.users",
+			CreateViewStatement{
+				Database:     "db",
+				ViewName:     "user_stats",
+				SelectQuery:  "SELECT * FROM db.users",
 				Materialized: true,
+				AutoRefresh:  true,
+			},
+		},
+		{
+			"create materialized view refreshed every interval",
+			"CREATE MATERIALIZED VIEW db.user_stats WITH AUTO REFRESH EVERY '5m' AS SELECT * FROM db.users",
+			CreateViewStatement{
+				Database:        "db",
+				ViewName:        "user_stats",
+				SelectQuery:     "SELECT * FROM db.users",
+				Materialized:    true,
+				AutoRefresh:     true,
+				RefreshInterval: 5 * time.Minute,
 			},
 		},
 		{
@@ -412,6 +1027,120 @@ func TestParser(t *testing.T) {
 				Database: "mydb",
 			},
 		},
+		{
+			"begin",
+			"BEGIN",
+			BeginStatement{},
+		},
+		{
+			"begin read only",
+			"BEGIN READ ONLY",
+			BeginStatement{ReadOnly: true},
+		},
+		{
+			"rollback",
+			"ROLLBACK",
+			RollbackStatement{},
+		},
+		{
+			"rollback to savepoint",
+			"ROLLBACK TO SAVEPOINT before_orders",
+			RollbackStatement{Savepoint: "before_orders"},
+		},
+		{
+			"rollback to",
+			"ROLLBACK TO before_orders",
+			RollbackStatement{Savepoint: "before_orders"},
+		},
+		{
+			"savepoint",
+			"SAVEPOINT before_orders",
+			SavepointStatement{Name: "before_orders"},
+		},
+		{
+			"release savepoint",
+			"RELEASE SAVEPOINT before_orders",
+			ReleaseSavepointStatement{Name: "before_orders"},
+		},
+		{
+			"release",
+			"RELEASE before_orders",
+			ReleaseSavepointStatement{Name: "before_orders"},
+		},
+		{
+			"set variable",
+			"SET Case_Sensitive = TRUE",
+			SetVariableStatement{Name: "case_sensitive", Value: "TRUE"},
+		},
+		{
+			"set variable to string",
+			"SET strict_reads = 'off'",
+			SetVariableStatement{Name: "strict_reads", Value: "off"},
+		},
+		{
+			"show variables",
+			"SHOW VARIABLES",
+			ShowVariablesStatement{},
+		},
+		{
+			"show stats",
+			"SHOW STATS",
+			ShowStatsStatement{},
+		},
+		// Trigger tests
+		{
+			"create trigger",
+			"CREATE TRIGGER log_orders AFTER INSERT ON db.orders BEGIN INSERT INTO db.audit (id, item) VALUES (NEW.id, NEW.item); UPDATE db.stats SET total = '1' WHERE id = 'orders'; END",
+			CreateTriggerStatement{
+				Database: "db",
+				Name:     "log_orders",
+				Table:    "orders",
+				Event:    "INSERT",
+				Body:     "INSERT INTO db.audit (id, item) VALUES (NEW.id, NEW.item); UPDATE db.stats SET total = '1' WHERE id = 'orders'",
+			},
+		},
+		{
+			"create qualified delete trigger",
+			"CREATE TRIGGER db.log_deletes AFTER DELETE ON db.orders BEGIN DELETE FROM db.audit WHERE id = OLD.id END;",
+			CreateTriggerStatement{
+				Database: "db",
+				Name:     "log_deletes",
+				Table:    "orders",
+				Event:    "DELETE",
+				Body:     "DELETE FROM db.audit WHERE id = OLD.id",
+			},
+		},
+		{
+			"drop trigger if exists",
+			"DROP TRIGGER IF EXISTS db.log_orders",
+			DropTriggerStatement{
+				Database: "db",
+				Name:     "log_orders",
+				IfExists: true,
+			},
+		},
+		{
+			"show indexes on table",
+			"SHOW INDEXES ON mydb.users",
+			ShowIndexesStatement{Database: "mydb", Table: "users"},
+		},
+		{
+			"show indexes in database",
+			"SHOW INDEXES IN mydb",
+			ShowIndexesStatement{Database: "mydb"},
+		},
+		{
+			"show triggers",
+			"SHOW TRIGGERS IN mydb",
+			ShowTriggersStatement{
+				Database: "mydb",
+			},
+		},
+		{
+			"show warnings",
+			"SHOW WARNINGS",
+			ShowWarningsStatement{},
+		},
 		{
 			"refresh view",
 			"REFRESH VIEW db.cached_data",
@@ -462,3 +1191,134 @@ func TestParser(t *testing.T) {
 		})
 	}
 }
+
+func TestParseLargeInList(t *testing.T) {
+	values := make([]string, 100)
+	for i := range values {
+		values[i] = strconv.Itoa(i)
+	}
+	query := "SELECT * FROM db.users WHERE id IN (" + strings.Join(values, ", ") + ")"
+
+	parser := NewParser(query)
+	statement, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cond := statement.(SelectStatement).Where.Conditions[0]
+	if _, ok := cond.InSet["99"]; len(cond.InSet) != 100 || !ok {
+		t.Errorf("Expected a set of the 100 IN values, got %d", len(cond.InSet))
+	}
+
+	parser = NewParser(query)
+	parser.MaxInValues = 99
+	if _, err := parser.Parse(); err == nil || !strings.Contains(err.Error(), "more than 99 values") {
+		t.Errorf("Expected the IN list limit error, got %v", err)
+	}
+}
+
+func TestParseCopyOnConflict(t *testing.T) {
+	actual, err := parse("COPY INTO db.users FROM '/tmp/users.csv' WITH (HEADER = TRUE, ON_CONFLICT = 'update')")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := CopyStatement{Direction: "INTO_TABLE", Database: "db", Table: "users", FilePath: "/tmp/users.csv", Header: true, Delimiter: ",", Format: "CSV", OnConflict: "UPDATE"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, actual)
+	}
+
+	if _, err := parse("COPY INTO db.users FROM '/tmp/users.csv' WITH (ON_CONFLICT = 'merge')"); err == nil {
+		t.Error("Expected error for an unknown ON_CONFLICT policy")
+	}
+}
+
+func TestParseCopyFormat(t *testing.T) {
+	actual, err := parse("COPY INTO '/tmp/users.parquet' FROM db.users WITH (FORMAT = 'parquet', DELIMITER = ';')")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := CopyStatement{Direction: "INTO_FILE", Database: "db", Table: "users", FilePath: "/tmp/users.parquet", Header: true, Delimiter: ";", Format: "PARQUET"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, actual)
+	}
+
+	if actual, err := parse("COPY INTO db.users FROM '/tmp/users.csv'"); err != nil || actual.(CopyStatement).Format != "CSV" {
+		t.Errorf("Expected CSV by default, got %+v (%v)", actual, err)
+	}
+	if actual, err := parse("COPY INTO db.users FROM '/tmp/users.json' WITH (FORMAT = 'json')"); err != nil || actual.(CopyStatement).Format != "JSON" {
+		t.Errorf("Expected JSON format, got %+v (%v)", actual, err)
+	}
+	if _, err := parse("COPY INTO '/tmp/users.orc' FROM db.users WITH (FORMAT = 'ORC')"); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+}
+
+func TestParseCopyFromShare(t *testing.T) {
+	actual, err := parse("COPY INTO '/tmp/users.csv' FROM external.db.users")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := CopyStatement{Direction: "INTO_FILE", Share: "external", Database: "db", Table: "users", FilePath: "/tmp/users.csv", Header: true, Delimiter: ",", Format: "CSV"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, actual)
+	}
+
+	if _, err := parse("COPY INTO external.db.users FROM '/tmp/users.csv'"); err == nil {
+		t.Error("Expected error for an import into a share")
+	}
+}
+
+func TestParseCopyFromQuery(t *testing.T) {
+	actual, err := parse("COPY INTO '/tmp/active.csv' FROM (SELECT name, email FROM db.users WHERE active = 'true' ORDER BY name) WITH (DELIMITER = ';')")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	stmt := actual.(CopyStatement)
+	if stmt.Direction != "INTO_FILE" || stmt.FilePath != "/tmp/active.csv" || stmt.Delimiter != ";" || stmt.Table != "" {
+		t.Errorf("Unexpected statement %+v", stmt)
+	}
+	if stmt.Query == nil || stmt.Query.Database != "db" || stmt.Query.Table != "users" ||
+		!reflect.DeepEqual(stmt.Query.Columns, []string{"name", "email"}) || len(stmt.Query.OrderBy) != 1 {
+		t.Errorf("Unexpected query %+v", stmt.Query)
+	}
+
+	if actual, err := parse("COPY INTO '/tmp/top.csv' FROM (SELECT name FROM db.users FETCH FIRST 2 ROWS ONLY)"); err != nil || actual.(CopyStatement).Query.Limit != 2 {
+		t.Errorf("Expected FETCH FIRST query, got %+v (%v)", actual, err)
+	}
+	for _, query := range []string{
+		"COPY INTO '/tmp/out.csv' FROM (SELECT name FROM db.users",
+		"COPY INTO '/tmp/out.csv' FROM (db.users)",
+		"COPY INTO '/tmp/out.csv' FROM (SELECT name FROM db.users WITH (HEADER = TRUE)",
+	} {
+		if _, err := parse(query); err == nil {
+			t.Errorf("Expected error for %q", query)
+		}
+	}
+}
+
+func TestParseIntervalErrors(t *testing.T) {
+	for _, query := range []string{
+		"SELECT * FROM db.events WHERE created > 'soon' + INTERVAL 1 DAY",
+		"SELECT * FROM db.events WHERE created > '2024-01-01' + 1",
+		"SELECT * FROM db.events WHERE created > '2024-01-01' + INTERVAL 'a' DAY",
+		"SELECT * FROM db.events WHERE created > '2024-01-01' + INTERVAL 1 WEEK",
+		"SELECT created + INTERVAL 1.5 DAY FROM db.events",
+	} {
+		if _, err := parse(query); err == nil {
+			t.Errorf("Expected %q to fail to parse", query)
+		}
+	}
+}
+
+func TestParseRollbackRejectsTrailingTokens(t *testing.T) {
+	for _, query := range []string{
+		"ROLLBACK WORK NOW",
+		"ROLLBACK TO",
+		"ROLLBACK TO SAVEPOINT a b",
+		"SAVEPOINT",
+		"RELEASE SAVEPOINT",
+	} {
+		if _, err := parse(query); err == nil {
+			t.Errorf("Expected %q to fail to parse", query)
+		}
+	}
+}
@@ -90,6 +90,32 @@ func BenchmarkSelectWithWhere(b *testing.B) {
 	}
 }
 
+// BenchmarkSelectByPrimaryKey compares a primary key equality lookup with
+// the same query forced onto the full scan path
+func BenchmarkSelectByPrimaryKey(b *testing.B) {
+	engine := setupBenchmarkDB(b)
+
+	queries := []struct {
+		name  string
+		query string
+	}{
+		{"Lookup", "SELECT * FROM bench.users WHERE id = 500"},
+		{"Scan", "SELECT /*+ NO_INDEX */ * FROM bench.users WHERE id = 500"},
+	}
+
+	for _, q := range queries {
+		b.Run(q.name, func(b *testing.B) {
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err := engine.Execute(q.query)
+				if err != nil {
+					b.Fatalf("Execute error: %v", err)
+				}
+			}
+		})
+	}
+}
+
 // BenchmarkSelectWithIn benchmarks SELECT with IN clause
 func BenchmarkSelectWithIn(b *testing.B) {
 	engine := setupBenchmarkDB(b)
@@ -142,6 +168,44 @@ func BenchmarkCount(b *testing.B) {
 	}
 }
 
+// BenchmarkCountLargeTable benchmarks COUNT(*) over 10,000 rows, which
+// counts rows without collecting them
+func BenchmarkCountLargeTable(b *testing.B) {
+	persistence, _ := ps.NewMemoryPersistence()
+	instance := CommitDB.Open(&persistence)
+	engine := instance.Engine(core.Identity{Name: "benchmark", Email: "bench@test.com"})
+	engine.Execute("CREATE DATABASE bench")
+	engine.Execute("CREATE TABLE bench.events (id INT PRIMARY KEY, kind STRING, payload STRING)")
+
+	engine.Execute("BEGIN")
+	for i := 1; i <= 10000; i++ {
+		engine.Execute(fmt.Sprintf("INSERT INTO bench.events (id, kind, payload) VALUES (%d, 'kind%d', 'payload %d')", i, i%10, i))
+	}
+	if _, err := engine.Execute("COMMIT"); err != nil {
+		b.Fatalf("Commit error: %v", err)
+	}
+
+	queries := []struct {
+		name  string
+		query string
+	}{
+		{"All", "SELECT COUNT(*) FROM bench.events"},
+		{"Where", "SELECT COUNT(*) FROM bench.events WHERE kind = 'kind3'"},
+	}
+
+	for _, q := range queries {
+		b.Run(q.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := engine.Execute(q.query); err != nil {
+					b.Fatalf("Execute error: %v", err)
+				}
+			}
+		})
+	}
+}
+
 // BenchmarkAggregates benchmarks aggregate functions
 func BenchmarkAggregates(b *testing.B) {
 	engine := setupBenchmarkDB(b)
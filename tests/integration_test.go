@@ -1,11 +1,20 @@
 package tests
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"os"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
 
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
 	"github.com/go-git/go-billy/v6/osfs"
 	"github.com/go-git/go-git/v6"
 	"github.com/go-git/go-git/v6/plumbing/cache"
@@ -557,6 +566,29 @@ func TestIntegrationDateFunctions(t *testing.T) {
 			t.Errorf("DATE_SUB: expected '2024-05-31 14:30:00', got '%s'", qr.Data[0][0])
 		}
 
+		// Test INTERVAL arithmetic
+		result, err = engine.Execute("SELECT id, created + INTERVAL 1 MONTH, created - INTERVAL 30 DAY AS earlier FROM datefunc_test.events WHERE id = 1")
+		if err != nil {
+			t.Fatalf("INTERVAL failed: %v", err)
+		}
+		qr = result.(db.QueryResult)
+		if !reflect.DeepEqual(qr.Columns, []string{"id", "created + INTERVAL 1 MONTH", "earlier"}) {
+			t.Errorf("INTERVAL: unexpected columns %v", qr.Columns)
+		}
+		if want := [][]string{{"1", "2024-07-15 14:30:00", "2024-05-16 14:30:00"}}; !reflect.DeepEqual(qr.Data, want) {
+			t.Errorf("INTERVAL: expected %v, got %v", want, qr.Data)
+		}
+
+		// Test INTERVAL arithmetic on a date literal in WHERE
+		result, err = engine.Execute("SELECT id FROM datefunc_test.events WHERE created > '2024-06-01' + INTERVAL '100' DAY")
+		if err != nil {
+			t.Fatalf("INTERVAL in WHERE failed: %v", err)
+		}
+		qr = result.(db.QueryResult)
+		if want := [][]string{{"2"}}; !reflect.DeepEqual(qr.Data, want) {
+			t.Errorf("INTERVAL in WHERE: expected %v, got %v", want, qr.Data)
+		}
+
 		// Test DATEDIFF
 		result, err = engine.Execute("SELECT DATEDIFF('2024-12-25', '2024-06-15') FROM datefunc_test.events WHERE id = 1")
 		if err != nil {
@@ -580,6 +612,63 @@ func TestIntegrationDateFunctions(t *testing.T) {
 }
 
 // TestIntegrationDateColumns tests DATE/TIMESTAMP column types and NOW() in INSERT
+// TestIntegrationGroupByFunction tests grouping and aggregating over function results
+func TestIntegrationGroupByFunction(t *testing.T) {
+	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {
+		engine.Execute("CREATE DATABASE fn_group")
+		engine.Execute("CREATE TABLE fn_group.events (id INT PRIMARY KEY, name STRING, created DATE)")
+		engine.Execute("INSERT INTO fn_group.events (id, name, created) VALUES " +
+			"(1, 'launch', '2023-03-01'), (2, 'beta', '2023-11-20'), (3, 'release', '2024-01-15'), " +
+			"(4, 'patch', '2024-06-30'), (5, 'summit', '2024-09-09')")
+
+		tests := []struct {
+			query   string
+			columns []string
+			data    [][]string
+		}{
+			{
+				"SELECT YEAR(created), COUNT(*) FROM fn_group.events GROUP BY YEAR(created)",
+				[]string{"YEAR(created)", "COUNT(*)"},
+				[][]string{{"2023", "2"}, {"2024", "3"}},
+			},
+			{
+				"SELECT YEAR(created) AS yr, COUNT(*) AS events FROM fn_group.events GROUP BY YEAR(created)",
+				[]string{"yr", "events"},
+				[][]string{{"2023", "2"}, {"2024", "3"}},
+			},
+			{
+				"SELECT YEAR(created) AS yr, SUM(LENGTH(name)) FROM fn_group.events GROUP BY yr",
+				[]string{"yr", "SUM(LENGTH(name))"},
+				[][]string{{"2023", "10"}, {"2024", "18"}},
+			},
+			{
+				"SELECT MAX(LENGTH(name)) AS longest FROM fn_group.events",
+				[]string{"longest"},
+				[][]string{{"7"}},
+			},
+		}
+
+		for _, test := range tests {
+			result, err := engine.Execute(test.query)
+			if err != nil {
+				t.Fatalf("%s: %v", test.query, err)
+			}
+			qr := result.(db.QueryResult)
+			if strings.Join(qr.Columns, ",") != strings.Join(test.columns, ",") {
+				t.Errorf("%s: expected columns %v, got %v", test.query, test.columns, qr.Columns)
+			}
+			if len(qr.Data) != len(test.data) {
+				t.Fatalf("%s: expected %v, got %v", test.query, test.data, qr.Data)
+			}
+			for i, row := range test.data {
+				if strings.Join(qr.Data[i], ",") != strings.Join(row, ",") {
+					t.Errorf("%s: row %d expected %v, got %v", test.query, i, row, qr.Data[i])
+				}
+			}
+		}
+	})
+}
+
 func TestIntegrationDateColumns(t *testing.T) {
 	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {
 
@@ -758,6 +847,57 @@ func TestIntegrationJsonType(t *testing.T) {
 	})
 }
 
+// TestIntegrationJsonDefault tests DEFAULT values on JSON columns and JSON formatting
+func TestIntegrationJsonDefault(t *testing.T) {
+	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {
+
+		engine.Execute("CREATE DATABASE json_default")
+		_, err := engine.Execute("CREATE TABLE json_default.docs (id INT PRIMARY KEY, data JSON DEFAULT '{}')")
+		if err != nil {
+			t.Fatalf("CREATE TABLE with DEFAULT failed: %v", err)
+		}
+
+		// Omitted column takes the default, explicit NULL does not
+		engine.Execute("INSERT INTO json_default.docs (id) VALUES (1)")
+		engine.Execute("INSERT INTO json_default.docs (id, data) VALUES (2, NULL)")
+		engine.Execute(`INSERT INTO json_default.docs (id, data) VALUES (3, '{ "a" : [1, 2] }')`)
+
+		result, err := engine.Execute("SELECT * FROM json_default.docs WHERE data = '{}'")
+		if err != nil {
+			t.Fatalf("SELECT default failed: %v", err)
+		}
+		qr := result.(db.QueryResult)
+		if len(qr.Data) != 1 || qr.Data[0][0] != "1" {
+			t.Errorf("Expected only row 1 to have the default object, got %v", qr.Data)
+		}
+
+		result, err = engine.Execute("SELECT * FROM json_default.docs WHERE data IS NULL")
+		if err != nil {
+			t.Fatalf("SELECT IS NULL failed: %v", err)
+		}
+		if qr := result.(db.QueryResult); len(qr.Data) != 1 || qr.Data[0][0] != "2" {
+			t.Errorf("Expected only row 2 to be NULL, got %v", qr.Data)
+		}
+
+		result, err = engine.Execute("SELECT JSON_COMPACT(data), JSON_PRETTY(data) FROM json_default.docs WHERE id = 3")
+		if err != nil {
+			t.Fatalf("JSON_COMPACT/JSON_PRETTY failed: %v", err)
+		}
+		qr = result.(db.QueryResult)
+		if len(qr.Data) != 1 || qr.Data[0][0] != `{"a":[1,2]}` {
+			t.Errorf("JSON_COMPACT: unexpected result %v", qr.Data)
+		} else if qr.Data[0][1] != "{\n  \"a\": [\n    1,\n    2\n  ]\n}" {
+			t.Errorf("JSON_PRETTY: unexpected result %q", qr.Data[0][1])
+		}
+
+		// Invalid JSON defaults are rejected up front
+		_, err = engine.Execute("CREATE TABLE json_default.bad (id INT PRIMARY KEY, data JSON DEFAULT 'nope')")
+		if err == nil {
+			t.Error("Expected error for invalid JSON default")
+		}
+	})
+}
+
 // TestIntegrationBulkInsert tests bulk INSERT with multiple value rows
 func TestIntegrationBulkInsert(t *testing.T) {
 	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {
@@ -860,6 +1000,437 @@ func TestIntegrationCopyInto(t *testing.T) {
 	})
 }
 
+// TestIntegrationCopyIntoFromQuery tests exporting the result of a query to CSV
+func TestIntegrationCopyIntoFromQuery(t *testing.T) {
+	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {
+		engine.Execute("CREATE DATABASE copy_query")
+		engine.Execute("CREATE TABLE copy_query.users (id INT PRIMARY KEY, name STRING, email STRING, active BOOL)")
+		engine.Execute("INSERT INTO copy_query.users (id, name, email, active) VALUES (1, 'Alice', 'alice@test.com', 'true')")
+		engine.Execute("INSERT INTO copy_query.users (id, name, email, active) VALUES (2, 'Bob', 'bob@test.com', 'false')")
+		engine.Execute("INSERT INTO copy_query.users (id, name, email, active) VALUES (3, 'Charlie', 'charlie@test.com', 'true')")
+
+		exportPath := t.TempDir() + "/active.csv"
+		result, err := engine.Execute("COPY INTO '" + exportPath + "' FROM (SELECT name, email FROM copy_query.users WHERE active = 'true' ORDER BY name)")
+		if err != nil {
+			t.Fatalf("COPY INTO file from query failed: %v", err)
+		}
+		if cr := result.(db.CommitResult); cr.RecordsWritten != 2 {
+			t.Errorf("Expected 2 records exported, got %d", cr.RecordsWritten)
+		}
+
+		content, err := os.ReadFile(exportPath)
+		if err != nil {
+			t.Fatalf("Failed to read exported file: %v", err)
+		}
+		expected := "name,email\nAlice,alice@test.com\nCharlie,charlie@test.com\n"
+		if string(content) != expected {
+			t.Errorf("Expected CSV %q, got %q", expected, string(content))
+		}
+
+		// A Parquet export types the columns read from the table
+		parquetPath := t.TempDir() + "/active.parquet"
+		result, err = engine.Execute("COPY INTO '" + parquetPath + "' FROM (SELECT u.id, name, active, UPPER(name) AS shout FROM copy_query.users u WHERE id > 1) WITH (FORMAT = 'PARQUET')")
+		if err != nil {
+			t.Fatalf("COPY INTO parquet from query failed: %v", err)
+		}
+		if cr := result.(db.CommitResult); cr.RecordsWritten != 2 {
+			t.Errorf("Expected 2 records exported, got %d", cr.RecordsWritten)
+		}
+		reader, err := file.OpenParquetFile(parquetPath, false)
+		if err != nil {
+			t.Fatalf("Failed to open exported file: %v", err)
+		}
+		defer reader.Close()
+		arrowReader, err := pqarrow.NewFileReader(reader, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+		if err != nil {
+			t.Fatalf("Failed to read exported file: %v", err)
+		}
+		table, err := arrowReader.ReadTable(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to read exported table: %v", err)
+		}
+		defer table.Release()
+		if table.NumRows() != 2 {
+			t.Errorf("Expected 2 rows, got %d", table.NumRows())
+		}
+		wantFields := []arrow.Field{
+			{Name: "u.id", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+			{Name: "name", Type: arrow.BinaryTypes.String, Nullable: true},
+			{Name: "active", Type: arrow.FixedWidthTypes.Boolean, Nullable: true},
+			{Name: "shout", Type: arrow.BinaryTypes.String, Nullable: true},
+		}
+		if fields := table.Schema().Fields(); len(fields) != len(wantFields) {
+			t.Errorf("Expected columns %v, got %v", wantFields, table.Schema())
+		} else {
+			for i, field := range fields {
+				if field.Name != wantFields[i].Name || !arrow.TypeEqual(field.Type, wantFields[i].Type) || field.Nullable != wantFields[i].Nullable {
+					t.Errorf("Column %d: expected %v, got %v", i, wantFields[i], field)
+				}
+			}
+		}
+		if ids, ok := table.Column(0).Data().Chunk(0).(*array.Int64); !ok || !reflect.DeepEqual(ids.Int64Values(), []int64{2, 3}) {
+			t.Errorf("Expected ids [2 3], got %v", table.Column(0).Data().Chunk(0))
+		}
+	})
+}
+
+// TestIntegrationCopyIntoCancel tests that cancelling COPY INTO aborts the import
+func TestIntegrationCopyIntoCancel(t *testing.T) {
+	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {
+
+		engine.Execute("CREATE DATABASE cancel_test")
+		engine.Execute("CREATE TABLE cancel_test.items (id INT PRIMARY KEY, name STRING)")
+
+		var csv strings.Builder
+		csv.WriteString("id,name\n")
+		for i := 1; i <= 2500; i++ {
+			csv.WriteString(strconv.Itoa(i) + ",Item" + strconv.Itoa(i) + "\n")
+		}
+		importPath := t.TempDir() + "/items.csv"
+		if err := os.WriteFile(importPath, []byte(csv.String()), 0644); err != nil {
+			t.Fatalf("Failed to write CSV: %v", err)
+		}
+
+		// Cancel as soon as the first progress report arrives
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var progress []int
+		engine.CopyProgress = func(rows int) {
+			progress = append(progress, rows)
+			cancel()
+		}
+
+		_, err := engine.ExecuteContext(ctx, "COPY INTO cancel_test.items FROM '"+importPath+"'")
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected context.Canceled, got %v", err)
+		}
+		if len(progress) != 1 || progress[0] != 1000 {
+			t.Errorf("Expected one progress report of 1000 rows, got %v", progress)
+		}
+
+		result, err := engine.Execute("SELECT * FROM cancel_test.items")
+		if err != nil {
+			t.Fatalf("SELECT after cancelled import failed: %v", err)
+		}
+		if len(result.(db.QueryResult).Data) != 0 {
+			t.Errorf("Expected no rows after cancelled import, got %d", len(result.(db.QueryResult).Data))
+		}
+	})
+}
+
+// TestIntegrationCopyIntoParquet tests exporting a table as a Parquet file typed from its columns
+func TestIntegrationCopyIntoParquet(t *testing.T) {
+	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {
+
+		engine.Execute("CREATE DATABASE parquet_test")
+		engine.Execute("CREATE TABLE parquet_test.events (id INT PRIMARY KEY, name STRING NOT NULL, score FLOAT, active BOOL, day DATE, at TIMESTAMP, payload BLOB)")
+
+		engine.Execute("BEGIN")
+		for i := 1; i <= 1500; i++ {
+			engine.Execute("INSERT INTO parquet_test.events (id, name, score, active, day, at, payload) VALUES (" +
+				strconv.Itoa(i) + ", 'Event" + strconv.Itoa(i) + "', '1.5', 'true', '2026-01-02', '2026-01-02 03:04:05', FROM_BASE64('AQI='))")
+		}
+		engine.Execute("INSERT INTO parquet_test.events (id, name) VALUES (1501, 'Sparse')")
+		engine.Execute("COMMIT")
+
+		exportPath := t.TempDir() + "/events.parquet"
+		result, err := engine.Execute("COPY INTO '" + exportPath + "' FROM parquet_test.events WITH (FORMAT = 'parquet')")
+		if err != nil {
+			t.Fatalf("COPY INTO parquet failed: %v", err)
+		}
+		if written := result.(db.CommitResult).RecordsWritten; written != 1501 {
+			t.Errorf("Expected 1501 records exported, got %d", written)
+		}
+
+		reader, err := file.OpenParquetFile(exportPath, false)
+		if err != nil {
+			t.Fatalf("Failed to open exported file: %v", err)
+		}
+		defer reader.Close()
+		arrowReader, err := pqarrow.NewFileReader(reader, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+		if err != nil {
+			t.Fatalf("Failed to read exported file: %v", err)
+		}
+		table, err := arrowReader.ReadTable(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to read exported table: %v", err)
+		}
+		defer table.Release()
+
+		if table.NumRows() != 1501 {
+			t.Errorf("Expected 1501 rows, got %d", table.NumRows())
+		}
+		expected := []struct {
+			name     string
+			typ      arrow.DataType
+			nullable bool
+		}{
+			{"id", arrow.PrimitiveTypes.Int64, false},
+			{"name", arrow.BinaryTypes.String, false},
+			{"score", arrow.PrimitiveTypes.Float64, true},
+			{"active", arrow.FixedWidthTypes.Boolean, true},
+			{"day", arrow.FixedWidthTypes.Date32, true},
+			{"at", arrow.FixedWidthTypes.Timestamp_us, true},
+			{"payload", arrow.BinaryTypes.Binary, true},
+		}
+		fields := table.Schema().Fields()
+		if len(fields) != len(expected) {
+			t.Fatalf("Expected %d columns, got %v", len(expected), table.Schema())
+		}
+		for i, field := range fields {
+			if field.Name != expected[i].name || !arrow.TypeEqual(field.Type, expected[i].typ) || field.Nullable != expected[i].nullable {
+				t.Errorf("Column %d: expected %s %s (nullable=%v), got %s %s (nullable=%v)",
+					i, expected[i].name, expected[i].typ, expected[i].nullable, field.Name, field.Type, field.Nullable)
+			}
+		}
+
+		// Missing values of the sparse row are NULL
+		if nulls := table.Column(2).Data().NullN(); nulls != 1 {
+			t.Errorf("Expected 1 NULL score, got %d", nulls)
+		}
+
+		if _, err := engine.Execute("COPY INTO parquet_test.events FROM '" + exportPath + "' WITH (FORMAT = 'PARQUET')"); err == nil {
+			t.Error("Expected error importing a Parquet file")
+		}
+	})
+}
+
+// TestIntegrationCopyIntoJSON tests importing a JSON array of objects
+func TestIntegrationCopyIntoJSON(t *testing.T) {
+	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {
+
+		engine.Execute("CREATE DATABASE json_test")
+		engine.Execute("CREATE TABLE json_test.users (id INT PRIMARY KEY, name STRING, active BOOL, role STRING DEFAULT 'member', profile JSON)")
+
+		importPath := t.TempDir() + "/users.json"
+		content := `[
+  {"id": 1, "name": "Alice", "active": true, "profile": {"city": "Oslo", "tags": ["a", "b"]}},
+  {"id": 2, "name": "Bob", "active": false, "role": "admin", "profile": null},
+  {"name": "Charlie", "id": "3", "profile": [1, 2]}
+]`
+		if err := os.WriteFile(importPath, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write JSON: %v", err)
+		}
+
+		result, err := engine.Execute("COPY INTO json_test.users FROM '" + importPath + "' WITH (FORMAT = 'JSON')")
+		if err != nil {
+			t.Fatalf("COPY INTO from JSON failed: %v", err)
+		}
+		if written := result.(db.CommitResult).RecordsWritten; written != 3 {
+			t.Errorf("Expected 3 records imported, got %d", written)
+		}
+
+		result, err = engine.Execute("SELECT id, name, active, role, profile FROM json_test.users")
+		if err != nil {
+			t.Fatalf("SELECT after import failed: %v", err)
+		}
+		expected := [][]string{
+			{"1", "Alice", "true", "member", `{"city":"Oslo","tags":["a","b"]}`},
+			{"2", "Bob", "false", "admin", ""},
+			{"3", "Charlie", "", "member", "[1,2]"},
+		}
+		if got := result.(db.QueryResult).Data; !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+		result, _ = engine.Execute("SELECT id FROM json_test.users WHERE profile IS NULL")
+		if got := result.(db.QueryResult).Data; len(got) != 1 || got[0][0] != "2" {
+			t.Errorf("Expected null profile to be stored as NULL, got %v", got)
+		}
+
+		// Objects may also follow each other line by line (JSON Lines)
+		if err := os.WriteFile(importPath, []byte("{\"id\": 5, \"name\": \"Eve\"}\n{\"id\": 6, \"name\": \"Frank\"}\n"), 0644); err != nil {
+			t.Fatalf("Failed to write JSON: %v", err)
+		}
+		result, err = engine.Execute("COPY INTO json_test.users FROM '" + importPath + "' WITH (FORMAT = 'JSON')")
+		if err != nil {
+			t.Fatalf("COPY INTO from JSON Lines failed: %v", err)
+		}
+		if written := result.(db.CommitResult).RecordsWritten; written != 2 {
+			t.Errorf("Expected 2 records imported from JSON Lines, got %d", written)
+		}
+
+		// Unknown keys and nested values in scalar columns are rejected
+		for _, bad := range []string{`[{"id": 4, "nmae": "Dave"}]`, `[{"id": 4, "name": {"first": "Dave"}}]`} {
+			if err := os.WriteFile(importPath, []byte(bad), 0644); err != nil {
+				t.Fatalf("Failed to write JSON: %v", err)
+			}
+			if _, err := engine.Execute("COPY INTO json_test.users FROM '" + importPath + "' WITH (FORMAT = 'JSON')"); err == nil {
+				t.Errorf("Expected error importing %s", bad)
+			}
+		}
+	})
+}
+
+// TestIntegrationCopyIntoMissingPrimaryKey tests that a CSV row without a primary key is rejected
+func TestIntegrationCopyIntoMissingPrimaryKey(t *testing.T) {
+	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {
+
+		engine.Execute("CREATE DATABASE pk_test")
+		engine.Execute("CREATE TABLE pk_test.items (id INT PRIMARY KEY, name STRING)")
+
+		importPath := t.TempDir() + "/items.csv"
+		if err := os.WriteFile(importPath, []byte("1,Apple\n,Banana\n"), 0644); err != nil {
+			t.Fatalf("Failed to write CSV: %v", err)
+		}
+
+		_, err := engine.Execute("COPY INTO pk_test.items FROM '" + importPath + "' WITH (HEADER = FALSE)")
+		if err == nil || !strings.Contains(err.Error(), "row 2 missing primary key column id") {
+			t.Fatalf("Expected missing primary key error for row 2, got %v", err)
+		}
+
+		result, err := engine.Execute("SELECT * FROM pk_test.items")
+		if err != nil {
+			t.Fatalf("SELECT after failed import failed: %v", err)
+		}
+		if len(result.(db.QueryResult).Data) != 0 {
+			t.Errorf("Expected no rows after failed import, got %d", len(result.(db.QueryResult).Data))
+		}
+	})
+}
+
+func TestIntegrationCopyIntoWarnings(t *testing.T) {
+	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {
+
+		engine.Execute("CREATE DATABASE warn_test")
+		engine.Execute("CREATE TABLE warn_test.items (id INT PRIMARY KEY, name STRING)")
+
+		importPath := t.TempDir() + "/items.csv"
+		if err := os.WriteFile(importPath, []byte("id,name\n1,Apple\n2,Banana\n1,Cherry\n"), 0644); err != nil {
+			t.Fatalf("Failed to write CSV: %v", err)
+		}
+
+		result, err := engine.Execute("COPY INTO warn_test.items FROM '" + importPath + "'")
+		if err != nil {
+			t.Fatalf("COPY failed: %v", err)
+		}
+		if written := result.(db.CommitResult).RecordsWritten; written != 2 {
+			t.Errorf("Expected 2 records written, got %d", written)
+		}
+
+		result, err = engine.Execute("SHOW WARNINGS")
+		if err != nil {
+			t.Fatalf("SHOW WARNINGS failed: %v", err)
+		}
+		data := result.(db.QueryResult).Data
+		if len(data) != 1 || !strings.Contains(data[0][1], "COPY skipped row 2: row 4 has the same primary key 1") {
+			t.Fatalf("Expected a warning for the skipped row, got %v", data)
+		}
+
+		// The next statement clears them
+		engine.Execute("SELECT * FROM warn_test.items")
+		result, _ = engine.Execute("SHOW WARNINGS")
+		if data := result.(db.QueryResult).Data; len(data) != 0 {
+			t.Errorf("Expected no warnings after a clean SELECT, got %v", data)
+		}
+	})
+}
+
+// TestIntegrationCopyIntoMissingColumns tests that columns a CSV header leaves out take their DEFAULT or NULL
+func TestIntegrationCopyIntoMissingColumns(t *testing.T) {
+	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {
+
+		engine.Execute("CREATE DATABASE default_test")
+		engine.Execute("CREATE TABLE default_test.items (id INT PRIMARY KEY, name STRING, status STRING DEFAULT 'active', note STRING)")
+
+		importPath := t.TempDir() + "/items.csv"
+		if err := os.WriteFile(importPath, []byte("name,id\nApple,1\nBanana,2\n"), 0644); err != nil {
+			t.Fatalf("Failed to write CSV: %v", err)
+		}
+
+		if _, err := engine.Execute("COPY INTO default_test.items FROM '" + importPath + "'"); err != nil {
+			t.Fatalf("COPY failed: %v", err)
+		}
+
+		result, err := engine.Execute("SELECT id, name, status FROM default_test.items WHERE note IS NULL")
+		if err != nil {
+			t.Fatalf("SELECT failed: %v", err)
+		}
+		want := [][]string{{"1", "Apple", "active"}, {"2", "Banana", "active"}}
+		if data := result.(db.QueryResult).Data; !reflect.DeepEqual(data, want) {
+			t.Errorf("Expected defaulted rows %v, got %v", want, data)
+		}
+
+		// The primary key must still be in the file
+		if err := os.WriteFile(importPath, []byte("name,status\nCherry,sold\n"), 0644); err != nil {
+			t.Fatalf("Failed to write CSV: %v", err)
+		}
+		_, err = engine.Execute("COPY INTO default_test.items FROM '" + importPath + "'")
+		if err == nil || !strings.Contains(err.Error(), "missing primary key column id") {
+			t.Errorf("Expected missing primary key error, got %v", err)
+		}
+
+		// A header naming a column the table lacks is an error, not positional
+		if err := os.WriteFile(importPath, []byte("id,label\n3,Cherry\n"), 0644); err != nil {
+			t.Fatalf("Failed to write CSV: %v", err)
+		}
+		_, err = engine.Execute("COPY INTO default_test.items FROM '" + importPath + "'")
+		if err == nil || !strings.Contains(err.Error(), "column label") {
+			t.Errorf("Expected unknown header column error, got %v", err)
+		}
+	})
+}
+
+// TestIntegrationCopyIntoOnConflict tests importing over existing rows with
+// ON_CONFLICT = 'UPDATE' and 'IGNORE'
+func TestIntegrationCopyIntoOnConflict(t *testing.T) {
+	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {
+
+		engine.Execute("CREATE DATABASE conflict_test")
+		importPath := t.TempDir() + "/stock.csv"
+		if err := os.WriteFile(importPath, []byte("id,qty\n1,15\n3,30\n"), 0644); err != nil {
+			t.Fatalf("Failed to write CSV: %v", err)
+		}
+
+		for _, tc := range []struct {
+			policy  string
+			written int
+			want    [][]string
+		}{
+			// Existing rows take the file's qty and keep their name
+			{"UPDATE", 2, [][]string{{"1", "bolt", "15"}, {"2", "nut", "20"}, {"3", "", "30"}}},
+			// Existing rows are left as they are
+			{"IGNORE", 1, [][]string{{"1", "bolt", "10"}, {"2", "nut", "20"}, {"3", "", "30"}}},
+		} {
+			table := "conflict_test.stock_" + strings.ToLower(tc.policy)
+			engine.Execute("CREATE TABLE " + table + " (id INT PRIMARY KEY, name STRING, qty INT)")
+			engine.Execute("CREATE INDEX idx_qty ON " + table + "(qty)")
+			engine.Execute("INSERT INTO " + table + " (id, name, qty) VALUES (1, 'bolt', 10), (2, 'nut', 20)")
+
+			result, err := engine.Execute("COPY INTO " + table + " FROM '" + importPath + "' WITH (HEADER = TRUE, ON_CONFLICT = '" + tc.policy + "')")
+			if err != nil {
+				t.Fatalf("COPY with ON_CONFLICT = %s failed: %v", tc.policy, err)
+			}
+			if written := result.(db.CommitResult).RecordsWritten; written != tc.written {
+				t.Errorf("%s: expected %d records written, got %d", tc.policy, tc.written, written)
+			}
+
+			result, err = engine.Execute("SELECT id, name, qty FROM " + table + " ORDER BY id")
+			if err != nil {
+				t.Fatalf("SELECT failed: %v", err)
+			}
+			if data := result.(db.QueryResult).Data; !reflect.DeepEqual(data, tc.want) {
+				t.Errorf("%s: expected %v, got %v", tc.policy, tc.want, data)
+			}
+		}
+
+		// The index on qty follows the imported values
+		result, err := engine.Execute("CHECK DATABASE conflict_test")
+		if err != nil {
+			t.Fatalf("CHECK DATABASE failed: %v", err)
+		}
+		if data := result.(db.QueryResult).Data; len(data) != 0 {
+			t.Errorf("Expected consistent indexes, got %v", data)
+		}
+		result, err = engine.Execute("DELETE FROM conflict_test.stock_update WHERE qty = 15")
+		if err != nil {
+			t.Fatalf("DELETE failed: %v", err)
+		}
+		if deleted := result.(db.CommitResult).RecordsDeleted; deleted != 1 {
+			t.Errorf("Expected the updated row to be found by its new qty, got %d deleted", deleted)
+		}
+	})
+}
+
 // TestIntegrationOffsetLimit tests OFFSET and LIMIT
 func TestIntegrationOffsetLimit(t *testing.T) {
 	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {
@@ -901,6 +1472,45 @@ func TestIntegrationOffsetLimit(t *testing.T) {
 	})
 }
 
+// TestIntegrationOrderByTieBreak tests that rows with equal ORDER BY values come back in primary key order
+func TestIntegrationOrderByTieBreak(t *testing.T) {
+	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {
+		engine.Execute("CREATE DATABASE tie_test")
+		engine.Execute("CREATE TABLE tie_test.items (id INT PRIMARY KEY, grp STRING)")
+
+		for _, id := range []int{12, 3, 7, 1, 10, 5} {
+			grp := "a"
+			if id%2 == 0 {
+				grp = "b"
+			}
+			engine.Execute("INSERT INTO tie_test.items (id, grp) VALUES (" + strconv.Itoa(id) + ", '" + grp + "')")
+		}
+
+		tests := []struct {
+			query    string
+			expected []string
+		}{
+			{"SELECT id FROM tie_test.items ORDER BY grp", []string{"1", "3", "5", "7", "10", "12"}},
+			{"SELECT id FROM tie_test.items ORDER BY grp DESC", []string{"10", "12", "1", "3", "5", "7"}},
+			{"SELECT id FROM tie_test.items ORDER BY grp LIMIT 2 OFFSET 3", []string{"7", "10"}},
+		}
+
+		for _, test := range tests {
+			result, err := engine.Execute(test.query)
+			if err != nil {
+				t.Fatalf("%s: %v", test.query, err)
+			}
+			var ids []string
+			for _, row := range result.(db.QueryResult).Data {
+				ids = append(ids, row[0])
+			}
+			if strings.Join(ids, ",") != strings.Join(test.expected, ",") {
+				t.Errorf("%s: expected %v, got %v", test.query, test.expected, ids)
+			}
+		}
+	})
+}
+
 // TestIntegrationErrorHandling tests error cases
 func TestIntegrationErrorHandling(t *testing.T) {
 	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {
@@ -1336,6 +1946,102 @@ func TestAbortMergeSQL(t *testing.T) {
 	})
 }
 
+// TestShowMergeConflictsPaging tests LIMIT/OFFSET on SHOW MERGE CONFLICTS
+func TestShowMergeConflictsPaging(t *testing.T) {
+	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {
+		engine.Execute("CREATE DATABASE pgtest")
+		engine.Execute("CREATE TABLE pgtest.data (id INT PRIMARY KEY, val STRING)")
+		for i := 1; i <= 25; i++ {
+			engine.Execute("INSERT INTO pgtest.data (id, val) VALUES (" + strconv.Itoa(i) + ", 'Original')")
+		}
+
+		// Change every row differently on both branches
+		engine.Execute("CREATE BRANCH feature")
+		for _, branch := range []string{"feature", "master"} {
+			engine.Execute("CHECKOUT " + branch)
+			for i := 1; i <= 25; i++ {
+				engine.Execute("UPDATE pgtest.data SET val = '" + branch + "' WHERE id = " + strconv.Itoa(i))
+			}
+		}
+
+		if _, err := engine.Execute("MERGE feature WITH MANUAL RESOLUTION"); err != nil {
+			t.Fatalf("MERGE WITH MANUAL RESOLUTION failed: %v", err)
+		}
+
+		result, err := engine.Execute("SHOW MERGE CONFLICTS LIMIT 10")
+		if err != nil {
+			t.Fatalf("SHOW MERGE CONFLICTS LIMIT failed: %v", err)
+		}
+		qr := result.(db.QueryResult)
+		if len(qr.Data) != 10 || qr.TotalRecords != 25 {
+			t.Fatalf("Expected 10 of 25 conflicts, got %d of %d", len(qr.Data), qr.TotalRecords)
+		}
+
+		result, err = engine.Execute("SHOW MERGE CONFLICTS LIMIT 10 OFFSET 20")
+		if err != nil {
+			t.Fatalf("SHOW MERGE CONFLICTS OFFSET failed: %v", err)
+		}
+		last := result.(db.QueryResult)
+		if len(last.Data) != 5 || last.TotalRecords != 25 {
+			t.Errorf("Expected the last 5 of 25 conflicts, got %d of %d", len(last.Data), last.TotalRecords)
+		}
+		if len(last.Data) > 0 && last.Data[0][2] == qr.Data[0][2] {
+			t.Errorf("Expected OFFSET to skip the first page, got key %s again", last.Data[0][2])
+		}
+
+		engine.Execute("ABORT MERGE")
+	})
+}
+
+// TestResolveAllConflictsSQL tests RESOLVE ALL CONFLICTS USING SOURCE
+func TestResolveAllConflictsSQL(t *testing.T) {
+	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {
+		engine.Execute("CREATE DATABASE ratest")
+		engine.Execute("CREATE TABLE ratest.data (id INT PRIMARY KEY, val STRING)")
+		for i := 1; i <= 5; i++ {
+			engine.Execute("INSERT INTO ratest.data (id, val) VALUES (" + strconv.Itoa(i) + ", 'Original')")
+		}
+
+		engine.Execute("CREATE BRANCH feature")
+		for _, branch := range []string{"feature", "master"} {
+			engine.Execute("CHECKOUT " + branch)
+			for i := 1; i <= 5; i++ {
+				engine.Execute("UPDATE ratest.data SET val = '" + branch + "' WHERE id = " + strconv.Itoa(i))
+			}
+		}
+
+		if _, err := engine.Execute("MERGE feature WITH MANUAL RESOLUTION"); err != nil {
+			t.Fatalf("MERGE WITH MANUAL RESOLUTION failed: %v", err)
+		}
+
+		result, err := engine.Execute("RESOLVE ALL CONFLICTS USING SOURCE")
+		if err != nil {
+			t.Fatalf("RESOLVE ALL CONFLICTS failed: %v", err)
+		}
+		qr := result.(db.QueryResult)
+		if qr.Data[0][0] != "5" || qr.Data[0][1] != "0" {
+			t.Errorf("Expected 5 resolved and 0 remaining, got %v", qr.Data)
+		}
+
+		result, _ = engine.Execute("SHOW MERGE CONFLICTS")
+		if remaining := len(result.(db.QueryResult).Data); remaining != 0 {
+			t.Errorf("Expected 0 conflicts after RESOLVE ALL, got %d", remaining)
+		}
+
+		if _, err := engine.Execute("COMMIT MERGE"); err != nil {
+			t.Fatalf("COMMIT MERGE failed: %v", err)
+		}
+
+		result, err = engine.Execute("SELECT * FROM ratest.data WHERE val = 'feature'")
+		if err != nil {
+			t.Fatalf("SELECT failed: %v", err)
+		}
+		if rows := len(result.(db.QueryResult).Data); rows != 5 {
+			t.Errorf("Expected all 5 rows to take the SOURCE value, got %d", rows)
+		}
+	})
+}
+
 // TestRemoteManagementSQL tests remote management SQL commands
 func TestRemoteManagementSQL(t *testing.T) {
 	// This test only runs with file persistence as memory persistence
@@ -1736,6 +2442,23 @@ func TestShareManagementSQL(t *testing.T) {
 		}
 		t.Log("JOIN between local and share succeeded")
 
+		// Test COPY INTO a file from a shared table
+		exportPath := tmpDir + "/shared_users.csv"
+		result, err = engine.Execute("COPY INTO '" + exportPath + "' FROM external.sample.users")
+		if err != nil {
+			t.Fatalf("COPY from share failed: %v", err)
+		}
+		if written := result.(db.CommitResult).RecordsWritten; written != 2 {
+			t.Errorf("Expected 2 rows exported from share, got %d", written)
+		}
+		exported, err := os.ReadFile(exportPath)
+		if err != nil {
+			t.Fatalf("Failed to read export: %v", err)
+		}
+		if expected := "id,name\n1,Alice\n2,Bob\n"; string(exported) != expected {
+			t.Errorf("Expected export %q, got %q", expected, exported)
+		}
+
 		// Test SYNC SHARE - should succeed now that the share exists
 		_, err = engine.Execute("SYNC SHARE external")
 		if err != nil {
@@ -1954,6 +2677,392 @@ func TestIntegrationViewErrors(t *testing.T) {
 	})
 }
 
+// TestIntegrationDropMaterializedViewClearsCache tests that dropping a materialized view removes its cached data
+func TestIntegrationDropMaterializedViewClearsCache(t *testing.T) {
+	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {
+		engine.Execute("CREATE DATABASE cachedb")
+		engine.Execute("CREATE TABLE cachedb.items (id INT PRIMARY KEY, name STRING)")
+		engine.Execute("INSERT INTO cachedb.items (id, name) VALUES (1, 'A')")
+
+		_, err := engine.Execute("CREATE MATERIALIZED VIEW cachedb.snapshot AS SELECT * FROM cachedb.items")
+		if err != nil {
+			t.Fatalf("CREATE MATERIALIZED VIEW failed: %v", err)
+		}
+
+		_, err = engine.Execute("DROP VIEW cachedb.snapshot")
+		if err != nil {
+			t.Fatalf("DROP VIEW failed: %v", err)
+		}
+
+		// Cached data should be removed together with the definition
+		if _, err := engine.Persistence.ReadMaterializedViewData("cachedb", "snapshot"); err == nil {
+			t.Error("Expected materialized view data to be removed after DROP VIEW")
+		}
+
+		// Recreate with the same name after the underlying data changed
+		engine.Execute("INSERT INTO cachedb.items (id, name) VALUES (2, 'B')")
+		_, err = engine.Execute("CREATE MATERIALIZED VIEW cachedb.snapshot AS SELECT * FROM cachedb.items")
+		if err != nil {
+			t.Fatalf("Re-CREATE MATERIALIZED VIEW failed: %v", err)
+		}
+
+		result, err := engine.Execute("SELECT * FROM cachedb.snapshot")
+		if err != nil {
+			t.Fatalf("SELECT from recreated view failed: %v", err)
+		}
+		qr := result.(db.QueryResult)
+		if len(qr.Data) != 2 {
+			t.Errorf("Expected 2 rows from recreated view, got %d", len(qr.Data))
+		}
+	})
+}
+
+// TestIntegrationCreateOrReplaceView tests replacing view definitions in place
+func TestIntegrationCreateOrReplaceView(t *testing.T) {
+	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {
+		engine.Execute("CREATE DATABASE repdb")
+		engine.Execute("CREATE TABLE repdb.users (id INT PRIMARY KEY, name STRING, active INT)")
+		engine.Execute("INSERT INTO repdb.users (id, name, active) VALUES (1, 'Alice', 1)")
+		engine.Execute("INSERT INTO repdb.users (id, name, active) VALUES (2, 'Bob', 0)")
+		engine.Execute("INSERT INTO repdb.users (id, name, active) VALUES (3, 'Charlie', 1)")
+
+		// OR REPLACE creates the view when it does not exist yet
+		_, err := engine.Execute("CREATE OR REPLACE VIEW repdb.filtered AS SELECT * FROM repdb.users WHERE active = 1")
+		if err != nil {
+			t.Fatalf("CREATE OR REPLACE VIEW failed: %v", err)
+		}
+		result, err := engine.Execute("SELECT * FROM repdb.filtered")
+		if err != nil {
+			t.Fatalf("SELECT from view failed: %v", err)
+		}
+		if len(result.(db.QueryResult).Data) != 2 {
+			t.Errorf("Expected 2 active users, got %d", len(result.(db.QueryResult).Data))
+		}
+
+		// Plain CREATE VIEW on an existing name should fail
+		_, err = engine.Execute("CREATE VIEW repdb.filtered AS SELECT * FROM repdb.users")
+		if err == nil {
+			t.Error("CREATE VIEW on existing view should error")
+		}
+
+		// Replacing the definition changes subsequent results
+		_, err = engine.Execute("CREATE OR REPLACE VIEW repdb.filtered AS SELECT * FROM repdb.users WHERE active = 0")
+		if err != nil {
+			t.Fatalf("CREATE OR REPLACE VIEW (replace) failed: %v", err)
+		}
+		result, err = engine.Execute("SELECT * FROM repdb.filtered")
+		if err != nil {
+			t.Fatalf("SELECT from replaced view failed: %v", err)
+		}
+		if len(result.(db.QueryResult).Data) != 1 {
+			t.Errorf("Expected 1 inactive user after replace, got %d", len(result.(db.QueryResult).Data))
+		}
+
+		// Replacing a materialized view re-populates its cache in one commit
+		commits := func() int { return len(engine.TransactionsFrom(engine.LatestTransaction().Id)) }
+		engine.Execute("CREATE MATERIALIZED VIEW repdb.cached AS SELECT * FROM repdb.users WHERE active = 1")
+		before := commits()
+		_, err = engine.Execute("CREATE OR REPLACE MATERIALIZED VIEW repdb.cached AS SELECT * FROM repdb.users")
+		if err != nil {
+			t.Fatalf("CREATE OR REPLACE MATERIALIZED VIEW failed: %v", err)
+		}
+		if got := commits() - before; got != 1 {
+			t.Errorf("Expected replacing a materialized view to make one commit, got %d", got)
+		}
+		result, err = engine.Execute("SELECT * FROM repdb.cached")
+		if err != nil {
+			t.Fatalf("SELECT from replaced materialized view failed: %v", err)
+		}
+		if len(result.(db.QueryResult).Data) != 3 {
+			t.Errorf("Expected 3 cached rows after replace, got %d", len(result.(db.QueryResult).Data))
+		}
+
+		// Replacing it with a regular view drops the cache in the same commit
+		before = commits()
+		_, err = engine.Execute("CREATE OR REPLACE VIEW repdb.cached AS SELECT * FROM repdb.users WHERE active = 0")
+		if err != nil {
+			t.Fatalf("CREATE OR REPLACE VIEW over a materialized view failed: %v", err)
+		}
+		if got := commits() - before; got != 1 {
+			t.Errorf("Expected replacing with a regular view to make one commit, got %d", got)
+		}
+		if _, err := engine.Persistence.ReadMaterializedViewData("repdb", "cached"); err == nil {
+			t.Error("Expected the materialized cache to be removed")
+		}
+		result, err = engine.Execute("SELECT * FROM repdb.cached")
+		if err != nil {
+			t.Fatalf("SELECT from the regular view failed: %v", err)
+		}
+		if len(result.(db.QueryResult).Data) != 1 {
+			t.Errorf("Expected 1 inactive user from the regular view, got %d", len(result.(db.QueryResult).Data))
+		}
+	})
+}
+
+// TestIntegrationAggregateAndJoinViews tests views over GROUP BY/aggregate and JOIN queries
+func TestIntegrationAggregateAndJoinViews(t *testing.T) {
+	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {
+		engine.Execute("CREATE DATABASE aggview")
+		engine.Execute("CREATE TABLE aggview.orders (id INT PRIMARY KEY, customer_id INT, region STRING, amount INT)")
+		engine.Execute("CREATE TABLE aggview.customers (cid INT PRIMARY KEY, name STRING)")
+		engine.Execute("INSERT INTO aggview.customers (cid, name) VALUES (1, 'Alice')")
+		engine.Execute("INSERT INTO aggview.customers (cid, name) VALUES (2, 'Bob')")
+		engine.Execute("INSERT INTO aggview.orders (id, customer_id, region, amount) VALUES (1, 1, 'East', 100)")
+		engine.Execute("INSERT INTO aggview.orders (id, customer_id, region, amount) VALUES (2, 2, 'West', 50)")
+		engine.Execute("INSERT INTO aggview.orders (id, customer_id, region, amount) VALUES (3, 1, 'East', 150)")
+		engine.Execute("INSERT INTO aggview.orders (id, customer_id, region, amount) VALUES (4, 2, 'North', 300)")
+
+		_, err := engine.Execute("CREATE VIEW aggview.summary AS SELECT region, SUM(amount) AS total FROM aggview.orders GROUP BY region")
+		if err != nil {
+			t.Fatalf("CREATE VIEW with GROUP BY failed: %v", err)
+		}
+
+		// Outer WHERE is applied to the grouped output
+		result, err := engine.Execute("SELECT region, total FROM aggview.summary WHERE total > 200 ORDER BY region")
+		if err != nil {
+			t.Fatalf("SELECT from aggregate view failed: %v", err)
+		}
+		qr := result.(db.QueryResult)
+		if len(qr.Data) != 2 {
+			t.Fatalf("Expected 2 regions with total > 200, got %d: %v", len(qr.Data), qr.Data)
+		}
+		if qr.Data[0][0] != "East" || qr.Data[0][1] != "250" {
+			t.Errorf("Expected East/250 first, got %v", qr.Data[0])
+		}
+		if qr.Data[1][0] != "North" || qr.Data[1][1] != "300" {
+			t.Errorf("Expected North/300 second, got %v", qr.Data[1])
+		}
+
+		// Aggregates can be applied on top of the view's output
+		result, err = engine.Execute("SELECT COUNT(*) FROM aggview.summary")
+		if err != nil {
+			t.Fatalf("COUNT(*) on aggregate view failed: %v", err)
+		}
+		if result.(db.QueryResult).Data[0][0] != "3" {
+			t.Errorf("Expected 3 grouped rows, got %s", result.(db.QueryResult).Data[0][0])
+		}
+
+		// Views over JOINs
+		_, err = engine.Execute("CREATE VIEW aggview.customer_orders AS SELECT * FROM aggview.orders INNER JOIN aggview.customers ON customer_id = cid")
+		if err != nil {
+			t.Fatalf("CREATE VIEW with JOIN failed: %v", err)
+		}
+		result, err = engine.Execute("SELECT id, name FROM aggview.customer_orders WHERE name = 'Alice'")
+		if err != nil {
+			t.Fatalf("SELECT from join view failed: %v", err)
+		}
+		if len(result.(db.QueryResult).Data) != 2 {
+			t.Errorf("Expected 2 orders for Alice, got %d", len(result.(db.QueryResult).Data))
+		}
+
+		// Materialized aggregate views keep the query's column order
+		_, err = engine.Execute("CREATE MATERIALIZED VIEW aggview.summary_cached AS SELECT region, SUM(amount) AS total FROM aggview.orders GROUP BY region")
+		if err != nil {
+			t.Fatalf("CREATE MATERIALIZED VIEW with GROUP BY failed: %v", err)
+		}
+		result, err = engine.Execute("SELECT * FROM aggview.summary_cached WHERE region = 'West'")
+		if err != nil {
+			t.Fatalf("SELECT from materialized aggregate view failed: %v", err)
+		}
+		qr = result.(db.QueryResult)
+		if len(qr.Columns) != 2 || qr.Columns[0] != "region" || qr.Columns[1] != "total" {
+			t.Errorf("Expected columns [region total], got %v", qr.Columns)
+		}
+		if len(qr.Data) != 1 || qr.Data[0][1] != "50" {
+			t.Errorf("Expected West total 50, got %v", qr.Data)
+		}
+	})
+}
+
+// TestIntegrationInsertReturning tests INSERT ... RETURNING
+func TestIntegrationInsertReturning(t *testing.T) {
+	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {
+		engine.Execute("CREATE DATABASE retdb")
+		engine.Execute("CREATE TABLE retdb.events (id INT PRIMARY KEY, name STRING, created TIMESTAMP)")
+
+		result, err := engine.Execute("INSERT INTO retdb.events (id, name, created) VALUES (1, 'a', NOW()), (2, 'b', NOW()) RETURNING id, created")
+		if err != nil {
+			t.Fatalf("INSERT RETURNING failed: %v", err)
+		}
+		cr := result.(db.CommitResult)
+		if cr.Returning == nil {
+			t.Fatal("Expected RETURNING rows")
+		}
+		if len(cr.Returning.Columns) != 2 || cr.Returning.Columns[0] != "id" || cr.Returning.Columns[1] != "created" {
+			t.Errorf("Expected columns [id created], got %v", cr.Returning.Columns)
+		}
+		if len(cr.Returning.Data) != 2 || cr.Returning.Data[0][0] != "1" || cr.Returning.Data[1][0] != "2" {
+			t.Errorf("Expected ids 1 and 2, got %v", cr.Returning.Data)
+		}
+		// NOW() is returned as the stored timestamp, not the literal
+		if cr.Returning.Data[0][1] == "" || cr.Returning.Data[0][1] == "NOW()" {
+			t.Errorf("Expected expanded timestamp, got %q", cr.Returning.Data[0][1])
+		}
+
+		// RETURNING * returns every table column
+		result, err = engine.Execute("INSERT INTO retdb.events (id, name, created) VALUES (3, 'c', '2024-01-01 00:00:00') RETURNING *")
+		if err != nil {
+			t.Fatalf("INSERT RETURNING * failed: %v", err)
+		}
+		cr = result.(db.CommitResult)
+		if len(cr.Returning.Columns) != 3 || cr.Returning.Data[0][1] != "c" {
+			t.Errorf("Expected full row, got %v %v", cr.Returning.Columns, cr.Returning.Data)
+		}
+
+		// Unknown columns are rejected before anything is written
+		_, err = engine.Execute("INSERT INTO retdb.events (id, name, created) VALUES (4, 'd', NOW()) RETURNING missing")
+		if err == nil {
+			t.Error("Expected error for unknown RETURNING column")
+		}
+		result, _ = engine.Execute("SELECT * FROM retdb.events WHERE id = 4")
+		if len(result.(db.QueryResult).Data) != 0 {
+			t.Error("Row should not be written when RETURNING is invalid")
+		}
+
+		// Without RETURNING there is no payload
+		result, _ = engine.Execute("INSERT INTO retdb.events (id, name, created) VALUES (5, 'e', NOW())")
+		if result.(db.CommitResult).Returning != nil {
+			t.Error("Expected no RETURNING rows without a RETURNING clause")
+		}
+	})
+}
+
+// TestIntegrationUpdateDeleteReturning tests UPDATE/DELETE ... RETURNING
+func TestIntegrationUpdateDeleteReturning(t *testing.T) {
+	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {
+		engine.Execute("CREATE DATABASE retdb")
+		engine.Execute("CREATE TABLE retdb.users (id INT PRIMARY KEY, name STRING, age INT)")
+		engine.Execute("INSERT INTO retdb.users (id, name, age) VALUES (1, 'Alice', 30), (2, 'Bob', 25)")
+
+		// UPDATE returns post-update values
+		result, err := engine.Execute("UPDATE retdb.users SET age = 31 WHERE id = 1 RETURNING id, age")
+		if err != nil {
+			t.Fatalf("UPDATE RETURNING failed: %v", err)
+		}
+		cr := result.(db.CommitResult)
+		if cr.Returning == nil || len(cr.Returning.Data) != 1 || cr.Returning.Data[0][1] != "31" {
+			t.Errorf("Expected updated age 31, got %v", cr.Returning)
+		}
+
+		// DELETE returns the removed row
+		result, err = engine.Execute("DELETE FROM retdb.users WHERE id = 2 RETURNING *")
+		if err != nil {
+			t.Fatalf("DELETE RETURNING failed: %v", err)
+		}
+		cr = result.(db.CommitResult)
+		if cr.Returning == nil || len(cr.Returning.Data) != 1 {
+			t.Fatalf("Expected one deleted row, got %v", cr.Returning)
+		}
+		row := cr.Returning.Data[0]
+		if len(row) != 3 || row[0] != "2" || row[1] != "Bob" || row[2] != "25" {
+			t.Errorf("Expected deleted row [2 Bob 25], got %v", row)
+		}
+
+		result, _ = engine.Execute("SELECT * FROM retdb.users WHERE id = 2")
+		if len(result.(db.QueryResult).Data) != 0 {
+			t.Error("Row should be deleted")
+		}
+	})
+}
+
+// TestIntegrationSchemaDriftRepair tests reading and repairing rows after column renames
+func TestIntegrationSchemaDriftRepair(t *testing.T) {
+	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {
+		engine.Execute("CREATE DATABASE driftdb")
+		engine.Execute("CREATE TABLE driftdb.users (id INT PRIMARY KEY, name STRING, age INT)")
+		engine.Execute("INSERT INTO driftdb.users (id, name, age) VALUES (1, 'Alice', 30), (2, 'Bob', 25)")
+
+		// Rows still hold the old key after renames, including chained ones
+		engine.Execute("ALTER TABLE driftdb.users RENAME COLUMN name TO full_name")
+		engine.Execute("ALTER TABLE driftdb.users RENAME COLUMN full_name TO display_name")
+		engine.Execute("ALTER TABLE driftdb.users DROP COLUMN age")
+		engine.Execute("ALTER TABLE driftdb.users ADD COLUMN email STRING")
+
+		result, err := engine.Execute("SELECT display_name FROM driftdb.users WHERE display_name = 'Alice'")
+		if err != nil {
+			t.Fatalf("SELECT failed: %v", err)
+		}
+		qr := result.(db.QueryResult)
+		if len(qr.Data) != 1 || qr.Data[0][0] != "Alice" {
+			t.Errorf("Expected renamed column to read old values, got %v", qr.Data)
+		}
+
+		// REPAIR TABLE rewrites both rows to the current schema
+		result, err = engine.Execute("REPAIR TABLE driftdb.users")
+		if err != nil {
+			t.Fatalf("REPAIR TABLE failed: %v", err)
+		}
+		if written := result.(db.CommitResult).RecordsWritten; written != 2 {
+			t.Errorf("Expected 2 repaired rows, got %d", written)
+		}
+
+		data, _ := engine.Persistence.GetRecord("driftdb", "users", "1")
+		var row map[string]string
+		if err := json.Unmarshal(data, &row); err != nil {
+			t.Fatalf("Failed to parse repaired row: %v", err)
+		}
+		if len(row) != 2 || row["display_name"] != "Alice" || row["id"] != "1" {
+			t.Errorf("Expected row with id and display_name, got %v", row)
+		}
+		if _, ok := row["email"]; ok {
+			t.Error("Expected missing column to stay NULL")
+		}
+
+		// A second repair has nothing to do
+		result, _ = engine.Execute("REPAIR TABLE driftdb.users")
+		if written := result.(db.CommitResult).RecordsWritten; written != 0 {
+			t.Errorf("Expected no rows to repair, got %d", written)
+		}
+	})
+}
+
+// TestIntegrationRenameReusesOldName tests giving a column the former name of
+// a renamed one
+func TestIntegrationRenameReusesOldName(t *testing.T) {
+	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {
+		engine.Execute("CREATE DATABASE reusedb")
+		engine.Execute("CREATE TABLE reusedb.users (id INT PRIMARY KEY, name STRING, city STRING)")
+		engine.Execute("INSERT INTO reusedb.users (id, name, city) VALUES (1, 'Alice', 'Oslo')")
+
+		// A new column named like the old one starts out NULL and keeps its values
+		for _, query := range []string{
+			"ALTER TABLE reusedb.users RENAME COLUMN name TO full_name",
+			"ALTER TABLE reusedb.users ADD COLUMN name STRING",
+			"INSERT INTO reusedb.users (id, full_name, name) VALUES (2, 'Bob Smith', 'Bob')",
+		} {
+			if _, err := engine.Execute(query); err != nil {
+				t.Fatalf("%s failed: %v", query, err)
+			}
+		}
+		result, err := engine.Execute("SELECT id, full_name, name FROM reusedb.users ORDER BY id")
+		if err != nil {
+			t.Fatalf("SELECT failed: %v", err)
+		}
+		want := [][]string{{"1", "Alice", ""}, {"2", "Bob Smith", "Bob"}}
+		if data := result.(db.QueryResult).Data; !reflect.DeepEqual(data, want) {
+			t.Errorf("Expected %v after ADD COLUMN, got %v", want, data)
+		}
+
+		// So does a column renamed to the old name
+		for _, query := range []string{
+			"ALTER TABLE reusedb.users RENAME COLUMN city TO town",
+			"ALTER TABLE reusedb.users RENAME COLUMN full_name TO city",
+		} {
+			if _, err := engine.Execute(query); err != nil {
+				t.Fatalf("%s failed: %v", query, err)
+			}
+		}
+		result, err = engine.Execute("SELECT id, city, town FROM reusedb.users ORDER BY id")
+		if err != nil {
+			t.Fatalf("SELECT failed: %v", err)
+		}
+		want = [][]string{{"1", "Alice", "Oslo"}, {"2", "Bob Smith", ""}}
+		if data := result.(db.QueryResult).Data; !reflect.DeepEqual(data, want) {
+			t.Errorf("Expected %v after RENAME COLUMN, got %v", want, data)
+		}
+	})
+}
+
 // TestIntegrationTimeTravelQueries tests querying data at specific transactions
 func TestIntegrationTimeTravelQueries(t *testing.T) {
 	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {
@@ -2110,6 +3219,54 @@ func TestIntegrationTimeTravelQueries(t *testing.T) {
 	})
 }
 
+// TestIntegrationTimeTravelAggregatesAndJoins tests that aggregates and joins
+// in a time-travel query read every table at the transaction
+func TestIntegrationTimeTravelAggregatesAndJoins(t *testing.T) {
+	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {
+		engine.Execute("CREATE DATABASE ttagg")
+		engine.Execute("CREATE TABLE ttagg.customers (id INT PRIMARY KEY, name STRING)")
+		engine.Execute("CREATE TABLE ttagg.orders (id INT PRIMARY KEY, customer_id INT, region STRING, amount INT)")
+		engine.Execute("INSERT INTO ttagg.customers (id, name) VALUES (1, 'Alice'), (2, 'Bob')")
+		result, err := engine.Execute("INSERT INTO ttagg.orders (id, customer_id, region, amount) VALUES (1, 1, 'east', 10), (2, 2, 'west', 20), (3, 1, 'east', 5)")
+		if err != nil {
+			t.Fatalf("INSERT failed: %v", err)
+		}
+		txn := result.(db.CommitResult).Transaction.Id
+
+		// Later changes to both tables must not show up AS OF txn
+		engine.Execute("INSERT INTO ttagg.orders (id, customer_id, region, amount) VALUES (4, 2, 'east', 100)")
+		engine.Execute("UPDATE ttagg.orders SET amount = 50 WHERE id = 2")
+		engine.Execute("UPDATE ttagg.customers SET name = 'Robert' WHERE id = 2")
+
+		result, err = engine.Execute("SELECT region, SUM(amount) FROM ttagg.orders AS OF '" + txn + "' GROUP BY region ORDER BY region")
+		if err != nil {
+			t.Fatalf("Time-travel aggregate failed: %v", err)
+		}
+		qr := result.(db.QueryResult)
+		if len(qr.Data) != 2 || qr.Data[0][0] != "east" || qr.Data[0][1] != "15" || qr.Data[1][0] != "west" || qr.Data[1][1] != "20" {
+			t.Errorf("Expected east=15, west=20 at txn, got %v", qr.Data)
+		}
+		if qr.Transaction.Id != txn {
+			t.Errorf("Expected result transaction %s, got %s", txn, qr.Transaction.Id)
+		}
+
+		result, err = engine.Execute("SELECT customers.name, orders.amount FROM ttagg.orders AS OF '" + txn + "' JOIN ttagg.customers ON orders.customer_id = customers.id ORDER BY orders.id")
+		if err != nil {
+			t.Fatalf("Time-travel join failed: %v", err)
+		}
+		qr = result.(db.QueryResult)
+		expected := [][]string{{"Alice", "10"}, {"Bob", "20"}, {"Alice", "5"}}
+		if len(qr.Data) != len(expected) {
+			t.Fatalf("Expected %d joined rows at txn, got %v", len(expected), qr.Data)
+		}
+		for i, row := range expected {
+			if qr.Data[i][0] != row[0] || qr.Data[i][1] != row[1] {
+				t.Errorf("Row %d: expected %v, got %v", i, row, qr.Data[i])
+			}
+		}
+	})
+}
+
 // TestIntegrationTimeTravelOnViews tests time-travel queries on views
 func TestIntegrationTimeTravelOnViews(t *testing.T) {
 	runWithBothPersistence(t, func(t *testing.T, engine *db.Engine) {